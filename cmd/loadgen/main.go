@@ -0,0 +1,86 @@
+// Command loadgen drives simulated shoppers against a deployed environment
+// and reports latency percentiles per endpoint.
+//
+// Each shopper starts a guest session, then repeatedly browses the catalog,
+// adds a product to the cart, checks out and pays until the run ends:
+//
+//	go run ./cmd/loadgen -concurrency 50 -duration 2m
+package main
+
+import (
+	"flag"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+)
+
+type options struct {
+	AuthURL      string
+	ProductURL   string
+	OrderURL     string
+	PaymentURL   string
+	Concurrency  int
+	Duration     time.Duration
+	Think        time.Duration // Pause between steps, like a shopper reading the page
+	PayMethod    string
+	Phone        string
+	ProductPages int // Catalog pages shoppers pick products from
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.AuthURL, "auth", "http://localhost:8001", "auth service base URL")
+	flag.StringVar(&opts.ProductURL, "product", "http://localhost:8003", "product service base URL")
+	flag.StringVar(&opts.OrderURL, "order", "http://localhost:8004", "order service base URL")
+	flag.StringVar(&opts.PaymentURL, "payment", "http://localhost:8005", "payment service base URL")
+	gateway := flag.String("gateway", "", "single base URL for all services, overrides the per-service URLs")
+	flag.IntVar(&opts.Concurrency, "concurrency", 10, "number of simulated shoppers")
+	flag.DurationVar(&opts.Duration, "duration", time.Minute, "how long to run")
+	flag.DurationVar(&opts.Think, "think", 500*time.Millisecond, "pause between steps of a flow")
+	flag.StringVar(&opts.PayMethod, "pay-method", "telebirr", "payment method: telebirr, cbe_birr or cash")
+	flag.StringVar(&opts.Phone, "phone", "+251911000000", "phone used for checkout contact and mobile payments")
+	flag.IntVar(&opts.ProductPages, "pages", 5, "catalog pages to browse")
+	flag.Parse()
+
+	if *gateway != "" {
+		opts.AuthURL, opts.ProductURL, opts.OrderURL, opts.PaymentURL = *gateway, *gateway, *gateway, *gateway
+	}
+	for _, u := range []*string{&opts.AuthURL, &opts.ProductURL, &opts.OrderURL, &opts.PaymentURL} {
+		*u = strings.TrimRight(*u, "/") + "/api/v1"
+	}
+	if opts.Concurrency < 1 || opts.ProductPages < 1 {
+		log.Fatal("concurrency and pages must be at least 1")
+	}
+
+	stats := newStats()
+	start := time.Now()
+	deadline := start.Add(opts.Duration)
+
+	// Stop early on Ctrl-C but still print what was measured
+	stop := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		close(stop)
+	}()
+
+	log.Printf("Running %d shoppers for %s", opts.Concurrency, opts.Duration)
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			s := newShopper(opts, stats, rand.New(rand.NewSource(seed)))
+			s.run(deadline, stop)
+		}(time.Now().UnixNano() + int64(i))
+	}
+	wg.Wait()
+
+	stats.report(os.Stdout, time.Since(start))
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"playful-marketplace/shared/contracts"
+	"playful-marketplace/shared/models"
+)
+
+// apiResponse mirrors utils.Response
+type apiResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type shopper struct {
+	opts   options
+	stats  *stats
+	rng    *rand.Rand
+	client *http.Client
+	token  string
+}
+
+func newShopper(opts options, stats *stats, rng *rand.Rand) *shopper {
+	return &shopper{
+		opts:   opts,
+		stats:  stats,
+		rng:    rng,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// run repeats the browse → cart → checkout → pay flow until the deadline.
+// A failed step abandons the current flow and starts the next one.
+func (s *shopper) run(deadline time.Time, stop <-chan struct{}) {
+	for time.Now().Before(deadline) {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		if err := s.flow(); err != nil {
+			s.stats.abandon()
+			s.think()
+			continue
+		}
+		s.stats.complete()
+	}
+}
+
+func (s *shopper) flow() error {
+	if s.token == "" {
+		var session struct {
+			Token string `json:"token"`
+		}
+		if err := s.call("POST /auth/guest", http.MethodPost, s.opts.AuthURL+"/auth/guest", nil, &session); err != nil {
+			return err
+		}
+		s.token = session.Token
+	}
+
+	// Browse
+	var list struct {
+		Products []struct {
+			ID string `json:"id"`
+		} `json:"products"`
+	}
+	page := s.rng.Intn(s.opts.ProductPages) + 1
+	if err := s.call("GET /products", http.MethodGet, fmt.Sprintf("%s/products?page=%d", s.opts.ProductURL, page), nil, &list); err != nil {
+		return err
+	}
+	if len(list.Products) == 0 {
+		return errors.New("no products to browse")
+	}
+	s.think()
+
+	product := list.Products[s.rng.Intn(len(list.Products))]
+	if err := s.call("GET /products/:id", http.MethodGet, s.opts.ProductURL+"/products/"+product.ID, nil, nil); err != nil {
+		return err
+	}
+	s.think()
+
+	// Cart
+	item := map[string]interface{}{"product_id": product.ID, "quantity": 1}
+	if err := s.call("POST /cart/items", http.MethodPost, s.opts.OrderURL+"/cart/items", item, nil); err != nil {
+		return err
+	}
+	s.think()
+
+	// Checkout from the cart
+	var order struct {
+		ID             string                            `json:"id"`
+		PaymentRequest *contracts.InitiatePaymentRequest `json:"payment_request"`
+	}
+	checkout := map[string]interface{}{
+		"shipping_address": "Bole Road, Addis Ababa",
+		"contact_phone":    s.opts.Phone,
+	}
+	if err := s.call("POST /orders", http.MethodPost, s.opts.OrderURL+"/orders", checkout, &order); err != nil {
+		return err
+	}
+	s.think()
+
+	// Pay with the request the order service prefilled
+	if order.PaymentRequest == nil {
+		return fmt.Errorf("order %s has no payment request", order.ID)
+	}
+	pay := *order.PaymentRequest
+	pay.Method = models.PaymentMethod(s.opts.PayMethod)
+	pay.Phone = s.opts.Phone
+	var payment contracts.PaymentResponse
+	if err := s.call("POST /payments/initiate", http.MethodPost, s.opts.PaymentURL+"/payments/initiate", pay, &payment); err != nil {
+		return err
+	}
+	s.think()
+
+	return s.call("GET /payments/status/:id", http.MethodGet, s.opts.PaymentURL+"/payments/status/"+payment.TransactionID, nil, nil)
+}
+
+// call sends a request, records its latency under endpoint and decodes the
+// response data into out when given.
+func (s *shopper) call(endpoint, method, url string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.stats.record(endpoint, time.Since(start), false)
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	elapsed := time.Since(start)
+	ok := err == nil && resp.StatusCode < 400
+	s.stats.record(endpoint, elapsed, ok)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		// Start a new guest session on the next flow
+		s.token = ""
+	}
+	if !ok {
+		return fmt.Errorf("%s: status %d", endpoint, resp.StatusCode)
+	}
+
+	if out == nil {
+		return nil
+	}
+	var decoded apiResponse
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return fmt.Errorf("%s: %w", endpoint, err)
+	}
+	return json.Unmarshal(decoded.Data, out)
+}
+
+func (s *shopper) think() {
+	if s.opts.Think <= 0 {
+		return
+	}
+	// Jitter so shoppers don't move in lockstep
+	time.Sleep(s.opts.Think/2 + time.Duration(s.rng.Int63n(int64(s.opts.Think))))
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+type endpointStats struct {
+	latencies []time.Duration
+	errors    int
+}
+
+type stats struct {
+	mu        sync.Mutex
+	endpoints map[string]*endpointStats
+	completed int
+	abandoned int
+}
+
+func newStats() *stats {
+	return &stats{endpoints: make(map[string]*endpointStats)}
+}
+
+func (s *stats) record(endpoint string, latency time.Duration, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, found := s.endpoints[endpoint]
+	if !found {
+		e = &endpointStats{}
+		s.endpoints[endpoint] = e
+	}
+	e.latencies = append(e.latencies, latency)
+	if !ok {
+		e.errors++
+	}
+}
+
+func (s *stats) complete() {
+	s.mu.Lock()
+	s.completed++
+	s.mu.Unlock()
+}
+
+func (s *stats) abandon() {
+	s.mu.Lock()
+	s.abandoned++
+	s.mu.Unlock()
+}
+
+// report prints request counts, error counts and latency percentiles per
+// endpoint, followed by flow totals.
+func (s *stats) report(w io.Writer, elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.endpoints))
+	for name := range s.endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "endpoint\trequests\terrors\trps\tp50\tp95\tp99\tmax\t")
+	for _, name := range names {
+		e := s.endpoints[name]
+		sort.Slice(e.latencies, func(i, j int) bool { return e.latencies[i] < e.latencies[j] })
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%s\t%s\t%s\t%s\t\n",
+			name,
+			len(e.latencies),
+			e.errors,
+			float64(len(e.latencies))/elapsed.Seconds(),
+			percentile(e.latencies, 50),
+			percentile(e.latencies, 95),
+			percentile(e.latencies, 99),
+			percentile(e.latencies, 100),
+		)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\nflows completed: %d, abandoned: %d, elapsed: %s\n", s.completed, s.abandoned, elapsed.Round(time.Second))
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1].Round(time.Millisecond)
+}
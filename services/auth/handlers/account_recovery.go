@@ -0,0 +1,458 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/mail"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/regions"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const maxRecoveryAttempts = 5
+
+var errRecoveryReviewed = errors.New("recovery has already been reviewed")
+
+type StartRecoveryRequest struct {
+	Email    string `json:"email" validate:"required"`     // The account's email address
+	NewPhone string `json:"new_phone" validate:"required"` // Number to move the account to
+}
+
+type SubmitRecoveryRequest struct {
+	EmailCode         string `json:"email_code" validate:"required"`
+	PhoneCode         string `json:"phone_code" validate:"required"` // Sent by SMS to the new number
+	IDDocumentURL     string `json:"id_document_url" validate:"required"`
+	RecentOrderNumber string `json:"recent_order_number"` // Helps the reviewer confirm it's your account
+	Details           string `json:"details"`
+}
+
+type RecoveryReviewRequest struct {
+	Decision       string `json:"decision" validate:"required"` // approve or reject
+	Notes          string `json:"notes"`
+	ResetTwoFactor bool   `json:"reset_two_factor"` // Also turn off two-factor when the authenticator was lost with the phone
+}
+
+// StartRecoveryResponse is returned whether or not an account uses the
+// email, so the endpoint can't be used to find accounts
+type StartRecoveryResponse struct {
+	RecoveryID uuid.UUID `json:"recovery_id"`
+	ExpiresIn  int       `json:"expires_in"` // Seconds the codes stay valid
+}
+
+// RecoveryStatusResponse is what the requester sees of their recovery
+type RecoveryStatusResponse struct {
+	ID         uuid.UUID                    `json:"id"`
+	Status     models.AccountRecoveryStatus `json:"status"`
+	CreatedAt  time.Time                    `json:"created_at"`
+	ReviewedAt *time.Time                   `json:"reviewed_at,omitempty"`
+	Notes      string                       `json:"notes,omitempty"` // Reviewer's reason when rejected
+}
+
+type AccountRecoveryListResponse struct {
+	Recoveries []models.AccountRecovery `json:"recoveries"`
+	Total      int64                    `json:"total"`
+	Page       int                      `json:"page"`
+	Limit      int                      `json:"limit"`
+}
+
+// pendingRecovery holds the codes between starting and submitting a recovery
+type pendingRecovery struct {
+	EmailCode string `json:"email_code"`
+	PhoneCode string `json:"phone_code"`
+	Attempts  int    `json:"attempts"`
+}
+
+// @Summary Start account recovery
+// @Description Start moving an account to a new phone number after losing the old one. Codes are sent to the account's email and to the new number; submit them with identity evidence for an admin to review. The response is the same whether or not an account uses the email.
+// @Tags auth
+// @Param request body StartRecoveryRequest true "Account email and new phone"
+// @Success 200 {object} utils.Response{data=StartRecoveryResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /auth/recovery [post]
+func (h *AuthHandler) StartAccountRecovery(c *fiber.Ctx) error {
+	var req StartRecoveryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if address, err := mail.ParseAddress(email); err != nil || address.Address != email {
+		return utils.ValidationErrorResponse(c, "Email address is not valid")
+	}
+	if _, ok := regions.ForPhone(req.NewPhone); !ok {
+		return utils.ValidationErrorResponse(c, unsupportedPhoneMessage)
+	}
+
+	var count int64
+	database.DB.Model(&models.User{}).Unscoped().Where("phone = ?", req.NewPhone).Count(&count)
+	if count > 0 {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "Phone number is already in use", nil)
+	}
+
+	response := StartRecoveryResponse{ExpiresIn: int(redis.AccountRecoveryTTL.Seconds())}
+	message := "If an account uses this email, we sent it a code. We also sent a code to the new phone number."
+
+	var user models.User
+	if err := database.DB.Where("LOWER(email) = ? AND role <> ? AND is_active = ?", email, models.RoleGuest, true).
+		First(&user).Error; err != nil {
+		response.RecoveryID = uuid.New()
+		return utils.SuccessResponse(c, message, response)
+	}
+
+	device := newDeviceInfo(c)
+	recovery := models.AccountRecovery{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		UserID:    user.ID,
+		Email:     user.Email,
+		OldPhone:  user.Phone,
+		NewPhone:  req.NewPhone,
+		Status:    models.RecoveryPendingVerification,
+		IP:        device.IP,
+		UserAgent: device.UserAgent,
+	}
+
+	pending := pendingRecovery{}
+	var err error
+	if pending.EmailCode, err = recoveryCode(); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to generate verification code", err)
+	}
+	if pending.PhoneCode, err = recoveryCode(); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to generate verification code", err)
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Only the latest unverified request can be completed
+		if err := tx.Model(&models.AccountRecovery{}).
+			Where("user_id = ? AND status = ?", user.ID, models.RecoveryPendingVerification).
+			Update("status", models.RecoveryCancelled).Error; err != nil {
+			return err
+		}
+		return tx.Create(&recovery).Error
+	})
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to start account recovery", err)
+	}
+	if err := redis.Set(redis.AccountRecoveryKey(recovery.ID), pending); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to store verification codes", err)
+	}
+
+	notifications.SendEmail(user.Email, "Recover your Playful Marketplace account",
+		fmt.Sprintf("Use this code to move your account to a new phone number: %s. If you didn't ask for this, ignore this email and contact support.", pending.EmailCode))
+	notifications.SendSMS(req.NewPhone, fmt.Sprintf("Your Playful Marketplace recovery code is %s", pending.PhoneCode))
+	notifications.Send(user.ID, models.NotificationSecurity, "Account recovery requested",
+		"Someone asked to move your account to a new phone number. If this wasn't you, contact support immediately.")
+
+	response.RecoveryID = recovery.ID
+	return utils.SuccessResponse(c, message, response)
+}
+
+// @Summary Submit account recovery
+// @Description Confirm the codes sent to the account's email and the new phone, and submit identity evidence. The request then waits for an admin to review it.
+// @Tags auth
+// @Param id path string true "Recovery ID"
+// @Param request body SubmitRecoveryRequest true "Codes and identity evidence"
+// @Success 200 {object} utils.Response{data=RecoveryStatusResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 401 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /auth/recovery/{id} [post]
+func (h *AuthHandler) SubmitAccountRecovery(c *fiber.Ctx) error {
+	recoveryID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid recovery ID")
+	}
+
+	var req SubmitRecoveryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+	if req.EmailCode == "" || req.PhoneCode == "" {
+		return utils.ValidationErrorResponse(c, "Both verification codes are required")
+	}
+	if req.IDDocumentURL == "" {
+		return utils.ValidationErrorResponse(c, "An ID document is required")
+	}
+
+	var recovery models.AccountRecovery
+	if err := database.DB.Where("id = ? AND status = ?", recoveryID, models.RecoveryPendingVerification).
+		First(&recovery).Error; err != nil {
+		return utils.NotFoundResponse(c, "Recovery request not found or expired")
+	}
+
+	key := redis.AccountRecoveryKey(recovery.ID)
+	var pending pendingRecovery
+	if err := redis.Get(key, &pending); err != nil {
+		return utils.NotFoundResponse(c, "Recovery request not found or expired")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(req.EmailCode), []byte(pending.EmailCode)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(req.PhoneCode), []byte(pending.PhoneCode)) != 1 {
+		// Drop the request after too many wrong codes so it can't be brute-forced
+		pending.Attempts++
+		if pending.Attempts >= maxRecoveryAttempts {
+			redis.Delete(key)
+		} else {
+			redis.Set(key, pending)
+		}
+		return utils.UnauthorizedResponse(c, "Invalid verification code")
+	}
+
+	orderNumber := strings.TrimSpace(req.RecentOrderNumber)
+	var matches int64
+	if orderNumber != "" {
+		database.DB.Model(&models.Order{}).Where("buyer_id = ? AND order_number = ?", recovery.UserID, orderNumber).Count(&matches)
+	}
+
+	now := time.Now()
+	if err := database.DB.Model(&recovery).Updates(map[string]interface{}{
+		"status":               models.RecoveryPendingReview,
+		"verified_at":          now,
+		"id_document_url":      req.IDDocumentURL,
+		"recent_order_number":  orderNumber,
+		"order_number_matches": matches > 0,
+		"details":              req.Details,
+	}).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to submit account recovery", err)
+	}
+	redis.Delete(key)
+	recovery.Status = models.RecoveryPendingReview
+
+	return utils.SuccessResponse(c, "Recovery submitted. We'll let you know by email once it has been reviewed.", recoveryStatus(&recovery))
+}
+
+// @Summary Get account recovery status
+// @Description Check whether an account recovery has been reviewed yet
+// @Tags auth
+// @Param id path string true "Recovery ID"
+// @Success 200 {object} utils.Response{data=RecoveryStatusResponse}
+// @Failure 404 {object} utils.Problem
+// @Router /auth/recovery/{id} [get]
+func (h *AuthHandler) GetAccountRecovery(c *fiber.Ctx) error {
+	recoveryID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid recovery ID")
+	}
+
+	var recovery models.AccountRecovery
+	if err := database.DB.First(&recovery, recoveryID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Recovery request not found")
+	}
+
+	return utils.SuccessResponse(c, "Recovery retrieved successfully", recoveryStatus(&recovery))
+}
+
+// @Summary Get account recoveries
+// @Description Get account recoveries awaiting review, oldest first (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param status query string false "Filter by status" default(pending_review)
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} utils.Response{data=AccountRecoveryListResponse}
+// @Router /admin/account-recoveries [get]
+func (h *AuthHandler) GetAccountRecoveries(c *fiber.Ctx) error {
+	status := c.Query("status", string(models.RecoveryPendingReview))
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+
+	if page < 1 {
+		page = 1
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	query := database.DB.Model(&models.AccountRecovery{}).Where("status = ?", status)
+
+	var total int64
+	query.Count(&total)
+
+	var recoveries []models.AccountRecovery
+	if err := query.Preload("User").
+		Order("created_at ASC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&recoveries).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get account recoveries", err)
+	}
+
+	response := AccountRecoveryListResponse{
+		Recoveries: recoveries,
+		Total:      total,
+		Page:       page,
+		Limit:      limit,
+	}
+
+	return utils.SuccessResponse(c, "Account recoveries retrieved successfully", response)
+}
+
+// @Summary Review account recovery
+// @Description Approve or reject an account recovery (admin only). Approval moves the account to the new phone number, signs out every session and unlinks the old number, which is also removed from saved payment phones.
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Recovery ID"
+// @Param request body RecoveryReviewRequest true "Review decision"
+// @Success 200 {object} utils.Response{data=models.AccountRecovery}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /admin/account-recoveries/{id} [post]
+func (h *AuthHandler) ReviewAccountRecovery(c *fiber.Ctx) error {
+	recoveryID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid recovery ID")
+	}
+
+	adminID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req RecoveryReviewRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.Decision != "approve" && req.Decision != "reject" {
+		return utils.ValidationErrorResponse(c, "Decision must be 'approve' or 'reject'")
+	}
+
+	var recovery models.AccountRecovery
+	if err := database.DB.First(&recovery, recoveryID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Recovery request not found")
+	}
+
+	status := models.RecoveryRejected
+	if req.Decision == "approve" {
+		status = models.RecoveryApproved
+	}
+
+	now := time.Now()
+	err = database.ActingAs(adminID).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&recovery).Where("status = ?", models.RecoveryPendingReview).Updates(map[string]interface{}{
+			"status":      status,
+			"reviewed_by": adminID,
+			"reviewed_at": now,
+			"notes":       req.Notes,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errRecoveryReviewed
+		}
+
+		if status != models.RecoveryApproved {
+			return nil
+		}
+		return h.rebindPhone(tx, &recovery, req.ResetTwoFactor)
+	})
+	if errors.Is(err, errRecoveryReviewed) {
+		return utils.ValidationErrorResponse(c, "Recovery has already been reviewed")
+	}
+	if errors.Is(err, errPhoneTaken) {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "The new phone number has since been taken, reject this recovery", nil)
+	}
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to review account recovery", err)
+	}
+
+	if status == models.RecoveryApproved {
+		// Sessions on the lost phone must not outlive the move
+		utils.RevokeTokens(recovery.UserID)
+		redis.Delete(redis.OTPKey(recovery.OldPhone))
+
+		notifications.SendSMS(recovery.NewPhone, "Your Playful Marketplace account has been recovered. Log in with this phone number.")
+		notifications.SendEmail(recovery.Email, "Your account has been recovered",
+			fmt.Sprintf("Your Playful Marketplace account now uses the phone number %s and your other sessions were signed out.", recovery.NewPhone))
+		notifications.Send(recovery.UserID, models.NotificationSecurity, "Account recovered",
+			fmt.Sprintf("Your account phone number was changed to %s through account recovery.", recovery.NewPhone))
+	} else {
+		message := "We couldn't confirm your request to move your Playful Marketplace account to a new phone number."
+		if req.Notes != "" {
+			message += " " + req.Notes
+		}
+		notifications.SendEmail(recovery.Email, "Account recovery declined", message)
+	}
+
+	database.DB.First(&recovery, recovery.ID)
+
+	return utils.SuccessResponse(c, "Account recovery reviewed successfully", recovery)
+}
+
+// rebindPhone moves the account to the recovery's new phone number and
+// unlinks the old one
+func (h *AuthHandler) rebindPhone(tx *gorm.DB, recovery *models.AccountRecovery, resetTwoFactor bool) error {
+	var count int64
+	if err := tx.Model(&models.User{}).Unscoped().Where("phone = ? AND id <> ?", recovery.NewPhone, recovery.UserID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errPhoneTaken
+	}
+
+	updates := map[string]interface{}{"phone": recovery.NewPhone}
+	if resetTwoFactor {
+		updates["totp_enabled"] = false
+		updates["totp_secret"] = ""
+		if err := tx.Where("user_id = ?", recovery.UserID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+	}
+	// The unique index on phone rejects a concurrent claim of the same number
+	if err := tx.Model(&models.User{}).Where("id = ?", recovery.UserID).Updates(updates).Error; err != nil {
+		return errPhoneTaken
+	}
+
+	// Whoever has the old SIM now must not be able to pay with it either
+	if err := tx.Unscoped().Where("user_id = ? AND phone = ?", recovery.UserID, recovery.OldPhone).
+		Delete(&models.PaymentPhone{}).Error; err != nil {
+		return err
+	}
+
+	change := models.PhoneChange{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		UserID:    recovery.UserID,
+		OldPhone:  recovery.OldPhone,
+		NewPhone:  recovery.NewPhone,
+		IP:        recovery.IP,
+		UserAgent: recovery.UserAgent,
+	}
+	return tx.Create(&change).Error
+}
+
+func recoveryStatus(recovery *models.AccountRecovery) RecoveryStatusResponse {
+	response := RecoveryStatusResponse{
+		ID:         recovery.ID,
+		Status:     recovery.Status,
+		CreatedAt:  recovery.CreatedAt,
+		ReviewedAt: recovery.ReviewedAt,
+	}
+	if recovery.Status == models.RecoveryRejected {
+		response.Notes = recovery.Notes
+	}
+	return response
+}
+
+// recoveryCode returns a random 6-digit code
+func recoveryCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
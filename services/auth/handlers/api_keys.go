@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/middleware"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" validate:"required"`
+	Scopes        []string `json:"scopes" validate:"required"` // e.g. products:read, orders:write
+	ExpiresInDays int      `json:"expires_in_days"`            // 0 means no expiry
+}
+
+type CreateAPIKeyResponse struct {
+	Key    string        `json:"key"` // Shown once, store it securely
+	APIKey models.APIKey `json:"api_key"`
+}
+
+// @Summary Create API key
+// @Description Create a scoped API key for server-to-server integrations. Scopes cannot exceed the owner's permissions.
+// @Tags auth
+// @Security BearerAuth
+// @Param request body CreateAPIKeyRequest true "Create API key request"
+// @Success 201 {object} utils.Response{data=CreateAPIKeyResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Router /auth/api-keys [post]
+func (h *AuthHandler) CreateAPIKey(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+	userRole, _ := c.Locals("user_role").(models.UserRole)
+
+	var req CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.Name == "" || len(req.Scopes) == 0 {
+		return utils.ValidationErrorResponse(c, "Name and at least one scope are required")
+	}
+	if req.ExpiresInDays < 0 {
+		return utils.ValidationErrorResponse(c, "Expiry cannot be negative")
+	}
+
+	for _, scope := range req.Scopes {
+		if !strings.Contains(scope, ":") {
+			return utils.ValidationErrorResponse(c, "Scopes must be in resource:action format")
+		}
+		if !middleware.HasPermission(userRole, scope) {
+			return utils.ErrorResponse(c, fiber.StatusForbidden, "You cannot grant the "+scope+" scope", nil)
+		}
+	}
+
+	key, prefix, err := utils.GenerateAPIKey()
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to generate API key", err)
+	}
+
+	apiKey := models.APIKey{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		OwnerID:   userID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   utils.HashAPIKey(key),
+		Scopes:    strings.Join(req.Scopes, ","),
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	if err := database.DB.Create(&apiKey).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create API key", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "API key created successfully",
+		Data: CreateAPIKeyResponse{
+			Key:    key,
+			APIKey: apiKey,
+		},
+	})
+}
+
+// @Summary Get API keys
+// @Description List the current user's API keys
+// @Tags auth
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=[]models.APIKey}
+// @Router /auth/api-keys [get]
+func (h *AuthHandler) GetAPIKeys(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var apiKeys []models.APIKey
+	if err := database.DB.Where("owner_id = ?", userID).Order("created_at DESC").Find(&apiKeys).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get API keys", err)
+	}
+
+	return utils.SuccessResponse(c, "API keys retrieved successfully", apiKeys)
+}
+
+// @Summary Revoke API key
+// @Description Revoke one of the current user's API keys
+// @Tags auth
+// @Security BearerAuth
+// @Param id path string true "API key ID"
+// @Success 200 {object} utils.Response{data=models.APIKey}
+// @Failure 404 {object} utils.Problem
+// @Router /auth/api-keys/{id} [delete]
+func (h *AuthHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	keyID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid API key ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var apiKey models.APIKey
+	if err := database.DB.Where("id = ? AND owner_id = ?", keyID, userID).First(&apiKey).Error; err != nil {
+		return utils.NotFoundResponse(c, "API key not found")
+	}
+
+	if apiKey.RevokedAt == nil {
+		now := time.Now()
+		apiKey.RevokedAt = &now
+		if err := database.DB.Save(&apiKey).Error; err != nil {
+			return utils.InternalServerErrorResponse(c, "Failed to revoke API key", err)
+		}
+	}
+
+	return utils.SuccessResponse(c, "API key revoked successfully", apiKey)
+}
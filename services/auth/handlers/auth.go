@@ -1,19 +1,26 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"math/rand"
 	"time"
 
+	"playful-marketplace/shared/activity"
+	"playful-marketplace/shared/captcha"
 	"playful-marketplace/shared/config"
 	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/jobs"
 	"playful-marketplace/shared/models"
 	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/referrals"
+	"playful-marketplace/shared/regions"
 	"playful-marketplace/shared/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthHandler struct {
@@ -25,20 +32,34 @@ type SignupRequest struct {
 	Name  string           `json:"name" validate:"required"`
 	Email string           `json:"email"`
 	Role  models.UserRole  `json:"role" validate:"required"`
+
+	CaptchaToken string `json:"captcha_token"` // Required when CAPTCHA is enabled
+	ReferralCode string `json:"referral_code"` // Another seller's code, sellers only
 }
 
 type LoginRequest struct {
-	Phone string `json:"phone" validate:"required"`
-	OTP   string `json:"otp" validate:"required"`
+	Phone        string `json:"phone" validate:"required"`
+	OTP          string `json:"otp" validate:"required"`
+	TOTPCode     string `json:"totp_code"`     // Required when two-factor authentication is enabled
+	RecoveryCode string `json:"recovery_code"` // Alternative to totp_code
 }
 
 type OTPRequest struct {
-	Phone string `json:"phone" validate:"required"`
+	Phone        string `json:"phone" validate:"required"`
+	CaptchaToken string `json:"captcha_token"` // Required when CAPTCHA is enabled
 }
 
 type AuthResponse struct {
-	Token string       `json:"token"`
-	User  *models.User `json:"user"`
+	Token           string       `json:"token"`
+	User            *models.User `json:"user"`
+	EvictedSessions int          `json:"evicted_sessions,omitempty"` // Oldest sessions logged out to stay within the session limit
+}
+
+// Background job kinds
+const jobEarlyBirdBadge = "auth.early_bird_badge"
+
+type earlyBirdJob struct {
+	UserID uuid.UUID `json:"user_id"`
 }
 
 func NewAuthHandler(cfg *config.Config) *AuthHandler {
@@ -47,6 +68,11 @@ func NewAuthHandler(cfg *config.Config) *AuthHandler {
 	}
 }
 
+// RegisterJobs registers the handlers for the jobs this service enqueues
+func (h *AuthHandler) RegisterJobs() {
+	jobs.Handle(jobEarlyBirdBadge, h.checkEarlyBirdBadge)
+}
+
 // @Summary Sign up a new user
 // @Description Create a new user account
 // @Tags auth
@@ -54,8 +80,8 @@ func NewAuthHandler(cfg *config.Config) *AuthHandler {
 // @Produce json
 // @Param request body SignupRequest true "Signup request"
 // @Success 201 {object} utils.Response{data=AuthResponse}
-// @Failure 400 {object} utils.Response
-// @Failure 409 {object} utils.Response
+// @Failure 400 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
 // @Router /auth/signup [post]
 func (h *AuthHandler) Signup(c *fiber.Ctx) error {
 	var req SignupRequest
@@ -64,8 +90,20 @@ func (h *AuthHandler) Signup(c *fiber.Ctx) error {
 	}
 
 	// Validate required fields
-	if req.Phone == "" || req.Name == "" || req.Role == "" {
-		return utils.ValidationErrorResponse(c, "Phone, name, and role are required")
+	var missing []utils.FieldError
+	for _, field := range []struct{ name, value string }{
+		{"phone", req.Phone}, {"name", req.Name}, {"role", string(req.Role)},
+	} {
+		if field.value == "" {
+			missing = append(missing, utils.FieldError{Field: field.name, Message: "This field is required"})
+		}
+	}
+	if len(missing) > 0 {
+		return utils.FieldErrorsResponse(c, "Phone, name, and role are required", missing...)
+	}
+
+	if _, ok := regions.ForPhone(req.Phone); !ok {
+		return utils.FieldErrorsResponse(c, unsupportedPhoneMessage, utils.FieldError{Field: "phone", Message: unsupportedPhoneMessage})
 	}
 
 	// Validate role
@@ -73,12 +111,28 @@ func (h *AuthHandler) Signup(c *fiber.Ctx) error {
 		return utils.ValidationErrorResponse(c, "Role must be 'buyer' or 'seller'")
 	}
 
+	// Keep bots from farming signups
+	if err := captcha.Verify(h.config.Captcha, req.CaptchaToken, c.IP()); err != nil {
+		return captchaErrorResponse(c, err)
+	}
+
 	// Check if user already exists
 	var existingUser models.User
 	if err := database.DB.Where("phone = ?", req.Phone).First(&existingUser).Error; err == nil {
 		return utils.ErrorResponse(c, fiber.StatusConflict, "User with this phone number already exists", nil)
 	}
 
+	var referrer *models.User
+	if req.ReferralCode != "" {
+		if req.Role != models.RoleSeller {
+			return utils.ValidationErrorResponse(c, "Referral codes are for new sellers")
+		}
+		var err error
+		if referrer, err = referrals.ResolveCode(req.ReferralCode); err != nil {
+			return utils.ValidationErrorResponse(c, "Referral code is not valid")
+		}
+	}
+
 	// Create new user
 	user := models.User{
 		BaseModel: models.BaseModel{
@@ -88,6 +142,7 @@ func (h *AuthHandler) Signup(c *fiber.Ctx) error {
 		Name:     req.Name,
 		Email:    req.Email,
 		Role:     req.Role,
+		Roles:    models.RolesFor(req.Role),
 		Level:    models.LevelBronze,
 		TotalXP:  0,
 		IsActive: true,
@@ -97,6 +152,12 @@ func (h *AuthHandler) Signup(c *fiber.Ctx) error {
 		return utils.InternalServerErrorResponse(c, "Failed to create user", err)
 	}
 
+	if referrer != nil {
+		if err := referrals.Create(database.DB, referrer.ID, user.ID); err != nil {
+			log.Printf("Failed to record seller referral for %s: %v", user.ID, err)
+		}
+	}
+
 	// Generate JWT token
 	token, err := utils.GenerateJWT(&user, h.config)
 	if err != nil {
@@ -107,7 +168,7 @@ func (h *AuthHandler) Signup(c *fiber.Ctx) error {
 	session := &models.Session{
 		UserID:    user.ID,
 		Token:     token,
-		ExpiresAt: time.Now().Add(time.Duration(h.config.JWT.ExpiryHours) * time.Hour),
+		ExpiresAt: time.Now().Add(utils.TokenTTL(user.Role, h.config)),
 		CreatedAt: time.Now(),
 	}
 
@@ -115,8 +176,11 @@ func (h *AuthHandler) Signup(c *fiber.Ctx) error {
 		return utils.InternalServerErrorResponse(c, "Failed to create session", err)
 	}
 
+	// Register the signup device as trusted
+	go h.trackDevice(&user, newDeviceInfo(c), true)
+
 	// Award early bird badge if user is among first 100
-	go h.checkEarlyBirdBadge(&user)
+	jobs.Enqueue(jobEarlyBirdBadge, earlyBirdJob{UserID: user.ID})
 
 	response := AuthResponse{
 		Token: token,
@@ -133,8 +197,8 @@ func (h *AuthHandler) Signup(c *fiber.Ctx) error {
 // @Produce json
 // @Param request body OTPRequest true "OTP request"
 // @Success 200 {object} utils.Response
-// @Failure 400 {object} utils.Response
-// @Failure 404 {object} utils.Response
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
 // @Router /auth/request-otp [post]
 func (h *AuthHandler) RequestOTP(c *fiber.Ctx) error {
 	var req OTPRequest
@@ -146,6 +210,10 @@ func (h *AuthHandler) RequestOTP(c *fiber.Ctx) error {
 		return utils.ValidationErrorResponse(c, "Phone number is required")
 	}
 
+	if err := captcha.Verify(h.config.Captcha, req.CaptchaToken, c.IP()); err != nil {
+		return captchaErrorResponse(c, err)
+	}
+
 	// Check if user exists
 	var user models.User
 	if err := database.DB.Where("phone = ?", req.Phone).First(&user).Error; err != nil {
@@ -155,9 +223,8 @@ func (h *AuthHandler) RequestOTP(c *fiber.Ctx) error {
 	// Generate mock OTP (in production, integrate with SMS service)
 	otp := h.generateMockOTP()
 
-	// Store OTP in Redis with 5-minute expiration
-	otpKey := fmt.Sprintf("otp:%s", req.Phone)
-	if err := redis.Set(otpKey, otp, 5*time.Minute); err != nil {
+	// Store OTP in Redis until it expires
+	if err := redis.Set(redis.OTPKey(req.Phone), otp); err != nil {
 		return utils.InternalServerErrorResponse(c, "Failed to store OTP", err)
 	}
 
@@ -176,8 +243,9 @@ func (h *AuthHandler) RequestOTP(c *fiber.Ctx) error {
 // @Produce json
 // @Param request body LoginRequest true "Login request"
 // @Success 200 {object} utils.Response{data=AuthResponse}
-// @Failure 400 {object} utils.Response
-// @Failure 401 {object} utils.Response
+// @Failure 400 {object} utils.Problem
+// @Failure 401 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	var req LoginRequest
@@ -189,23 +257,55 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		return utils.ValidationErrorResponse(c, "Phone and OTP are required")
 	}
 
+	device := newDeviceInfo(c)
+
 	// Verify OTP
-	otpKey := fmt.Sprintf("otp:%s", req.Phone)
+	otpKey := redis.OTPKey(req.Phone)
 	var storedOTP string
 	if err := redis.Get(otpKey, &storedOTP); err != nil {
+		go h.recordLoginEvent(models.LoginEventLogin, req.Phone, nil, device, false, "expired_otp")
 		return utils.UnauthorizedResponse(c, "Invalid or expired OTP")
 	}
 
 	if storedOTP != req.OTP {
+		go h.recordLoginEvent(models.LoginEventLogin, req.Phone, nil, device, false, "invalid_otp")
 		return utils.UnauthorizedResponse(c, "Invalid OTP")
 	}
 
 	// Get user
 	var user models.User
 	if err := database.DB.Where("phone = ?", req.Phone).First(&user).Error; err != nil {
+		go h.recordLoginEvent(models.LoginEventLogin, req.Phone, nil, device, false, "user_not_found")
 		return utils.NotFoundResponse(c, "User not found")
 	}
 
+	if user.IsSuspended() {
+		go h.recordLoginEvent(models.LoginEventLogin, req.Phone, &user.ID, device, false, "suspended")
+		return suspendedResponse(c, &user)
+	}
+
+	// Accounts with 2FA enabled must also present an authenticator or recovery code
+	if user.TOTPEnabled {
+		if req.TOTPCode == "" && req.RecoveryCode == "" {
+			return twoFactorRequiredResponse(c)
+		}
+		if !h.verifySecondFactor(&user, req.TOTPCode, req.RecoveryCode) {
+			// Burn the SMS OTP so codes can't be brute-forced against it
+			redis.Delete(otpKey)
+			go h.recordLoginEvent(models.LoginEventLogin, req.Phone, &user.ID, device, false, "invalid_2fa")
+			return utils.UnauthorizedResponse(c, "Invalid two-factor code")
+		}
+	}
+
+	evicted, err := h.enforceSessionLimit(user.ID)
+	if errors.Is(err, errSessionLimit) {
+		go h.recordLoginEvent(models.LoginEventLogin, req.Phone, &user.ID, device, false, "session_limit")
+		return h.sessionLimitResponse(c)
+	}
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to check active sessions", err)
+	}
+
 	// Update last login
 	now := time.Now()
 	user.LastLoginAt = &now
@@ -221,7 +321,7 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	session := &models.Session{
 		UserID:    user.ID,
 		Token:     token,
-		ExpiresAt: time.Now().Add(time.Duration(h.config.JWT.ExpiryHours) * time.Hour),
+		ExpiresAt: time.Now().Add(utils.TokenTTL(user.Role, h.config)),
 		CreatedAt: time.Now(),
 	}
 
@@ -232,9 +332,14 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	// Delete used OTP
 	redis.Delete(otpKey)
 
+	// Record device and alert on new device/location
+	go h.trackDevice(&user, device, false)
+	go h.recordLoginEvent(models.LoginEventLogin, user.Phone, &user.ID, device, true, "")
+
 	response := AuthResponse{
-		Token: token,
-		User:  &user,
+		Token:           token,
+		User:            &user,
+		EvictedSessions: evicted,
 	}
 
 	return utils.SuccessResponse(c, "Login successful", response)
@@ -245,7 +350,7 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 // @Tags auth
 // @Security BearerAuth
 // @Success 200 {object} utils.Response
-// @Failure 401 {object} utils.Response
+// @Failure 401 {object} utils.Problem
 // @Router /auth/logout [post]
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
 	// Get session from context (set by auth middleware)
@@ -259,15 +364,78 @@ func (h *AuthHandler) Logout(c *fiber.Ctx) error {
 		return utils.InternalServerErrorResponse(c, "Failed to logout", err)
 	}
 
+	phone, _ := c.Locals("user_phone").(string)
+	go h.recordLoginEvent(models.LoginEventLogout, phone, &session.UserID, newDeviceInfo(c), true, "")
+
 	return utils.SuccessResponse(c, "Logout successful", nil)
 }
 
+// @Summary Logout everywhere
+// @Description Sign out of every session, including tokens issued to other devices and scoped tokens
+// @Tags auth
+// @Security BearerAuth
+// @Success 200 {object} utils.Response
+// @Router /auth/logout-all [post]
+func (h *AuthHandler) LogoutAll(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	if err := utils.RevokeTokens(userID); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to logout", err)
+	}
+
+	phone, _ := c.Locals("user_phone").(string)
+	go h.recordLoginEvent(models.LoginEventLogout, phone, &userID, newDeviceInfo(c), true, "")
+
+	return utils.SuccessResponse(c, "Logged out of all sessions", nil)
+}
+
+// @Summary Refresh token
+// @Description Exchange the current token for a new one with a fresh expiry. The old token stops working.
+// @Tags auth
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=AuthResponse}
+// @Failure 401 {object} utils.Problem
+// @Router /auth/refresh [post]
+func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
+	session, ok := c.Locals("session").(*models.Session)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "Session not found")
+	}
+
+	device := newDeviceInfo(c)
+
+	var user models.User
+	if err := database.DB.First(&user, session.UserID).Error; err != nil || !user.IsActive || user.IsSuspended() {
+		go h.recordLoginEvent(models.LoginEventRefresh, "", &session.UserID, device, false, "user_inactive")
+		return utils.UnauthorizedResponse(c, "User not found or inactive")
+	}
+
+	token, err := h.createSession(&user)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create session", err)
+	}
+
+	redis.DeleteSession(session.Token)
+
+	go h.recordLoginEvent(models.LoginEventRefresh, user.Phone, &user.ID, device, true, "")
+
+	response := AuthResponse{
+		Token: token,
+		User:  &user,
+	}
+
+	return utils.SuccessResponse(c, "Token refreshed successfully", response)
+}
+
 // @Summary Verify token
 // @Description Verify if the provided token is valid
 // @Tags auth
 // @Security BearerAuth
 // @Success 200 {object} utils.Response{data=models.User}
-// @Failure 401 {object} utils.Response
+// @Failure 401 {object} utils.Problem
 // @Router /auth/verify [get]
 func (h *AuthHandler) VerifyToken(c *fiber.Ctx) error {
 	userID, ok := c.Locals("user_id").(uuid.UUID)
@@ -284,16 +452,101 @@ func (h *AuthHandler) VerifyToken(c *fiber.Ctx) error {
 	return utils.SuccessResponse(c, "Token is valid", user)
 }
 
+var errSessionLimit = errors.New("active session limit reached")
+
+// enforceSessionLimit makes room for a new session under the configured
+// limit, logging out the oldest sessions or refusing with errSessionLimit
+// depending on the policy. It returns how many sessions were evicted.
+func (h *AuthHandler) enforceSessionLimit(userID uuid.UUID) (int, error) {
+	limit := h.config.Sessions.MaxActive
+	if limit <= 0 {
+		return 0, nil
+	}
+
+	sessions, err := redis.GetUserSessions(userID.String())
+	if err != nil {
+		return 0, err
+	}
+
+	excess := len(sessions) - limit + 1
+	if excess <= 0 {
+		return 0, nil
+	}
+	if h.config.Sessions.LimitPolicy == "reject" {
+		return 0, errSessionLimit
+	}
+
+	for _, session := range sessions[:excess] {
+		redis.DeleteSession(session.Token)
+	}
+	return excess, nil
+}
+
+func (h *AuthHandler) sessionLimitResponse(c *fiber.Ctx) error {
+	return utils.ProblemResponse(c, utils.Problem{
+		Status: fiber.StatusConflict,
+		Code:   "session_limit_reached",
+		Detail: fmt.Sprintf("You are already logged in on %d devices. Log out of one to continue.", h.config.Sessions.MaxActive),
+		Data:   fiber.Map{"session_limit_reached": true, "max_sessions": h.config.Sessions.MaxActive},
+	})
+}
+
+func twoFactorRequiredResponse(c *fiber.Ctx) error {
+	return utils.ProblemResponse(c, utils.Problem{
+		Status: fiber.StatusUnauthorized,
+		Code:   "two_factor_required",
+		Detail: "Two-factor authentication code required",
+		Data:   fiber.Map{"two_factor_required": true},
+	})
+}
+
+func suspendedResponse(c *fiber.Ctx, user *models.User) error {
+	data := fiber.Map{"account_status": user.AccountStatus, "reason": user.SuspensionReason}
+	if user.SuspendedUntil != nil {
+		data["suspended_until"] = user.SuspendedUntil
+	}
+	return utils.ProblemResponse(c, utils.Problem{
+		Status: fiber.StatusForbidden,
+		Code:   "account_" + string(user.AccountStatus),
+		Detail: fmt.Sprintf("Account is %s", user.AccountStatus),
+		Data:   data,
+	})
+}
+
+// New phone numbers must be from a region the marketplace operates in
+const unsupportedPhoneMessage = "Phone must be a mobile number in international format from a region we operate in"
+
+func captchaErrorResponse(c *fiber.Ctx, err error) error {
+	message := "CAPTCHA verification failed"
+	if errors.Is(err, captcha.ErrMissingToken) {
+		message = "CAPTCHA token is required"
+	}
+	return utils.ProblemResponse(c, utils.Problem{
+		Status: fiber.StatusBadRequest,
+		Code:   "captcha_failed",
+		Detail: message,
+		Data:   fiber.Map{"captcha_required": true},
+	})
+}
+
 func (h *AuthHandler) generateMockOTP() string {
 	// Generate 6-digit OTP
 	rand.Seed(time.Now().UnixNano())
 	return fmt.Sprintf("%06d", rand.Intn(1000000))
 }
 
-func (h *AuthHandler) checkEarlyBirdBadge(user *models.User) {
+func (h *AuthHandler) checkEarlyBirdBadge(payload []byte) error {
+	var job earlyBirdJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+	user := models.User{BaseModel: models.BaseModel{ID: job.UserID}}
+
 	// Count total users
 	var userCount int64
-	database.DB.Model(&models.User{}).Count(&userCount)
+	if err := database.DB.Model(&models.User{}).Count(&userCount).Error; err != nil {
+		return err
+	}
 
 	if userCount <= 100 {
 		// Award early bird badge
@@ -309,7 +562,9 @@ func (h *AuthHandler) checkEarlyBirdBadge(user *models.User) {
 					BadgeID:   badge.ID,
 					EarnedAt:  time.Now(),
 				}
-				database.DB.Create(&userBadge)
+				if err := database.DB.Create(&userBadge).Error; err == nil {
+					activity.BadgeEarned(user.ID, &badge)
+				}
 
 				// Award XP
 				if badge.XPReward > 0 {
@@ -318,6 +573,7 @@ func (h *AuthHandler) checkEarlyBirdBadge(user *models.User) {
 			}
 		}
 	}
+	return nil
 }
 
 func (h *AuthHandler) awardXP(userID uuid.UUID, amount int, reason string) {
@@ -339,6 +595,7 @@ func (h *AuthHandler) awardXP(userID uuid.UUID, amount int, reason string) {
 		newLevel := h.calculateLevel(user.TotalXP)
 		if newLevel != user.Level {
 			database.DB.Model(&user).Update("level", newLevel)
+			activity.LevelUp(user.ID, newLevel)
 		}
 	}
 }
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type UpdateDeviceRequest struct {
+	Trusted bool `json:"trusted"`
+}
+
+// deviceInfo captures request details needed after the handler returns
+type deviceInfo struct {
+	Fingerprint string
+	UserAgent   string
+	IP          string
+}
+
+func newDeviceInfo(c *fiber.Ctx) deviceInfo {
+	return deviceInfo{
+		Fingerprint: utils.DeviceFingerprint(c),
+		UserAgent:   c.Get("User-Agent"),
+		IP:          c.IP(),
+	}
+}
+
+// @Summary Get trusted devices
+// @Description Get all devices the current user has logged in from
+// @Tags auth
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=[]models.UserDevice}
+// @Failure 401 {object} utils.Problem
+// @Router /auth/devices [get]
+func (h *AuthHandler) GetDevices(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var devices []models.UserDevice
+	if err := database.DB.Where("user_id = ?", userID).Order("last_seen_at DESC").Find(&devices).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get devices", err)
+	}
+
+	return utils.SuccessResponse(c, "Devices retrieved successfully", devices)
+}
+
+// @Summary Update device trust
+// @Description Mark a device as trusted or untrusted. Logins from a trusted device don't raise new location alerts.
+// @Tags auth
+// @Security BearerAuth
+// @Param id path string true "Device ID"
+// @Param request body UpdateDeviceRequest true "Update device request"
+// @Success 200 {object} utils.Response{data=models.UserDevice}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /auth/devices/{id} [put]
+func (h *AuthHandler) UpdateDevice(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	deviceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid device ID")
+	}
+
+	var req UpdateDeviceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	var device models.UserDevice
+	if err := database.DB.Where("id = ? AND user_id = ?", deviceID, userID).First(&device).Error; err != nil {
+		return utils.NotFoundResponse(c, "Device not found")
+	}
+
+	if err := database.DB.Model(&device).Update("is_trusted", req.Trusted).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update device", err)
+	}
+
+	return utils.SuccessResponse(c, "Device updated successfully", device)
+}
+
+// @Summary Remove device
+// @Description Remove a device from the current user's device list
+// @Tags auth
+// @Security BearerAuth
+// @Param id path string true "Device ID"
+// @Success 200 {object} utils.Response
+// @Failure 404 {object} utils.Problem
+// @Router /auth/devices/{id} [delete]
+func (h *AuthHandler) DeleteDevice(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	deviceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid device ID")
+	}
+
+	result := database.DB.Where("id = ? AND user_id = ?", deviceID, userID).Delete(&models.UserDevice{})
+	if result.Error != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to remove device", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return utils.NotFoundResponse(c, "Device not found")
+	}
+
+	return utils.SuccessResponse(c, "Device removed successfully", nil)
+}
+
+func (h *AuthHandler) trackDevice(user *models.User, info deviceInfo, trusted bool) {
+	now := time.Now()
+
+	var device models.UserDevice
+	if err := database.DB.Where("user_id = ? AND fingerprint = ?", user.ID, info.Fingerprint).First(&device).Error; err != nil {
+		// First login from this device
+		var deviceCount int64
+		database.DB.Model(&models.UserDevice{}).Where("user_id = ?", user.ID).Count(&deviceCount)
+
+		device = models.UserDevice{
+			BaseModel:   models.BaseModel{ID: uuid.New()},
+			UserID:      user.ID,
+			Fingerprint: info.Fingerprint,
+			UserAgent:   info.UserAgent,
+			LastIP:      info.IP,
+			IsTrusted:   trusted,
+			LastSeenAt:  now,
+		}
+		database.DB.Create(&device)
+
+		// Only alert when the user already had other devices
+		if deviceCount > 0 && !trusted {
+			notifications.Send(user.ID, models.NotificationSecurity, "New device login",
+				fmt.Sprintf("Your account was accessed from a new device (%s) at IP %s. If this wasn't you, remove the device and contact support.", info.UserAgent, info.IP))
+		}
+		return
+	}
+
+	// Trusted devices, such as the user's own phone, change networks routinely
+	if device.LastIP != info.IP && !device.IsTrusted {
+		notifications.Send(user.ID, models.NotificationSecurity, "New login location",
+			fmt.Sprintf("Your account was accessed from a new location (IP %s).", info.IP))
+	}
+
+	database.DB.Model(&device).Updates(map[string]interface{}{
+		"last_ip":      info.IP,
+		"user_agent":   info.UserAgent,
+		"last_seen_at": now,
+	})
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/jobs"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/regions"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ConvertGuestRequest struct {
+	Phone string `json:"phone" validate:"required"`
+	Name  string `json:"name" validate:"required"`
+	Email string `json:"email"`
+	OTP   string `json:"otp"` // Required when the phone already has an account
+}
+
+// @Summary Start a guest session
+// @Description Issue an anonymous guest token that can browse, build a cart and check out with only a phone number
+// @Tags auth
+// @Produce json
+// @Success 201 {object} utils.Response{data=AuthResponse}
+// @Router /auth/guest [post]
+func (h *AuthHandler) CreateGuestSession(c *fiber.Ctx) error {
+	guestID := uuid.New()
+	user := models.User{
+		BaseModel: models.BaseModel{ID: guestID},
+		Phone:     fmt.Sprintf("guest:%s", guestID),
+		Name:      "Guest",
+		Email:     fmt.Sprintf("guest-%s@invalid", guestID),
+		Role:      models.RoleGuest,
+		Roles:     models.RolesFor(models.RoleGuest),
+		Level:     models.LevelBronze,
+		IsActive:  true,
+	}
+
+	if err := database.DB.Create(&user).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create guest", err)
+	}
+
+	token, err := h.createSession(&user)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create session", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Guest session started",
+		Data: AuthResponse{
+			Token: token,
+			User:  &user,
+		},
+	})
+}
+
+// @Summary Convert guest to account
+// @Description Turn the current guest session into a full buyer account. If the phone already has an account, confirm it with an OTP and the guest's cart and orders are moved into it.
+// @Tags auth
+// @Security BearerAuth
+// @Param request body ConvertGuestRequest true "Account details"
+// @Success 200 {object} utils.Response{data=AuthResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 401 {object} utils.Problem
+// @Router /auth/guest/convert [post]
+func (h *AuthHandler) ConvertGuest(c *fiber.Ctx) error {
+	guest, err := h.currentUser(c)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, "User not found")
+	}
+
+	var req ConvertGuestRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.Phone == "" || req.Name == "" {
+		return utils.ValidationErrorResponse(c, "Phone and name are required")
+	}
+	if _, ok := regions.ForPhone(req.Phone); !ok {
+		return utils.ValidationErrorResponse(c, unsupportedPhoneMessage)
+	}
+
+	device := newDeviceInfo(c)
+
+	var user models.User
+	err = database.DB.Where("phone = ?", req.Phone).First(&user).Error
+	switch {
+	case err == nil:
+		// Existing account: prove ownership before merging into it
+		otpKey := redis.OTPKey(req.Phone)
+		var storedOTP string
+		if req.OTP == "" || redis.Get(otpKey, &storedOTP) != nil || storedOTP != req.OTP {
+			go h.recordLoginEvent(models.LoginEventLogin, req.Phone, nil, device, false, "invalid_otp")
+			return utils.UnauthorizedResponse(c, "Invalid or expired OTP")
+		}
+		redis.Delete(otpKey)
+
+		if user.TOTPEnabled {
+			return utils.ErrorResponse(c, fiber.StatusForbidden, "Log in to this account to merge your guest orders", nil)
+		}
+
+		if err := database.DB.Transaction(func(tx *gorm.DB) error {
+			return h.mergeGuest(tx, guest, &user)
+		}); err != nil {
+			return utils.InternalServerErrorResponse(c, "Failed to merge guest session", err)
+		}
+		database.DB.First(&user, user.ID)
+
+	case err == gorm.ErrRecordNotFound:
+		// New account: upgrade the guest in place so its cart and orders stay attached
+		if err := database.ActingAs(guest.ID).Model(guest).Updates(map[string]interface{}{
+			"phone": req.Phone,
+			"name":  req.Name,
+			"email": req.Email,
+			"role":  models.RoleBuyer,
+			"roles": models.RolesFor(models.RoleBuyer),
+		}).Error; err != nil {
+			return utils.InternalServerErrorResponse(c, "Failed to create account", err)
+		}
+		user = *guest
+		jobs.Enqueue(jobEarlyBirdBadge, earlyBirdJob{UserID: user.ID})
+
+	default:
+		return utils.InternalServerErrorResponse(c, "Failed to look up account", err)
+	}
+
+	// Guest tokens carry the guest role, so retire them
+	utils.RevokeTokens(guest.ID)
+
+	now := time.Now()
+	user.LastLoginAt = &now
+	database.DB.Model(&user).Update("last_login_at", now)
+
+	token, err := h.createSession(&user)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create session", err)
+	}
+
+	go h.trackDevice(&user, device, user.ID == guest.ID)
+	go h.recordLoginEvent(models.LoginEventLogin, user.Phone, &user.ID, device, true, "")
+
+	response := AuthResponse{
+		Token: token,
+		User:  &user,
+	}
+
+	return utils.SuccessResponse(c, "Account created successfully", response)
+}
+
+// Helper functions
+
+func (h *AuthHandler) createSession(user *models.User) (string, error) {
+	token, err := utils.GenerateJWT(user, h.config)
+	if err != nil {
+		return "", err
+	}
+
+	session := &models.Session{
+		UserID:    user.ID,
+		Token:     token,
+		ExpiresAt: time.Now().Add(utils.TokenTTL(user.Role, h.config)),
+		CreatedAt: time.Now(),
+	}
+	// While Redis is down the token works on its own, and the client signs
+	// in again once Redis is back and the token turns out to have no session
+	if err := redis.SetSession(session); err != nil && !errors.Is(err, redis.ErrUnavailable) {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// reissueSession signs the user out everywhere and returns a fresh token
+// for the current client
+func (h *AuthHandler) reissueSession(user *models.User) (string, error) {
+	if err := utils.RevokeTokens(user.ID); err != nil {
+		return "", err
+	}
+	return h.createSession(user)
+}
+
+// mergeGuest moves everything the guest created onto an existing account
+func (h *AuthHandler) mergeGuest(tx *gorm.DB, guest, user *models.User) error {
+	for _, table := range []string{"orders", "return_requests", "review_solicitations", "order_issues", "offers"} {
+		if err := tx.Table(table).Where("buyer_id = ?", guest.ID).Update("buyer_id", user.ID).Error; err != nil {
+			return err
+		}
+	}
+	for _, model := range []interface{}{&models.XPTransaction{}, &models.Coupon{}, &models.CouponRedemption{}} {
+		if err := tx.Model(model).Where("user_id = ?", guest.ID).Update("user_id", user.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	// Badges the account already holds aren't awarded twice
+	held := tx.Model(&models.UserBadge{}).Select("badge_id").Where("user_id = ?", user.ID)
+	if err := tx.Where("user_id = ? AND badge_id IN (?)", guest.ID, held).Delete(&models.UserBadge{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.UserBadge{}).Where("user_id = ?", guest.ID).Update("user_id", user.ID).Error; err != nil {
+		return err
+	}
+
+	if err := h.mergeGuestCart(tx, guest.ID, user.ID); err != nil {
+		return err
+	}
+
+	totalXP := user.TotalXP + guest.TotalXP
+	if err := tx.Model(user).Updates(map[string]interface{}{
+		"total_xp":    totalXP,
+		"total_spent": gorm.Expr("total_spent + ?", guest.TotalSpent),
+		"level":       h.calculateLevel(totalXP),
+	}).Error; err != nil {
+		return err
+	}
+
+	return tx.Delete(guest).Error
+}
+
+func (h *AuthHandler) mergeGuestCart(tx *gorm.DB, guestID, userID uuid.UUID) error {
+	var guestCart models.Cart
+	if err := tx.Preload("Items").Where("user_id = ?", guestID).First(&guestCart).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+
+	cart := models.Cart{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		UserID:    userID,
+	}
+	if err := tx.Preload("Items").Where(models.Cart{UserID: userID}).FirstOrCreate(&cart).Error; err != nil {
+		return err
+	}
+
+	// Cart lines are per product and variant
+	type line struct{ product, variant uuid.UUID }
+	lineOf := func(item models.CartItem) line {
+		if item.VariantID == nil {
+			return line{product: item.ProductID}
+		}
+		return line{product: item.ProductID, variant: *item.VariantID}
+	}
+
+	existing := make(map[line]models.CartItem, len(cart.Items))
+	for _, item := range cart.Items {
+		existing[lineOf(item)] = item
+	}
+
+	for _, item := range guestCart.Items {
+		if current, ok := existing[lineOf(item)]; ok {
+			if err := tx.Model(&current).Update("quantity", current.Quantity+item.Quantity).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Delete(&item).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err := tx.Model(&item).Update("cart_id", cart.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Model(&cart).Update("last_activity_at", time.Now()).Error; err != nil {
+		return err
+	}
+
+	return tx.Unscoped().Delete(&guestCart).Error
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/middleware"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type IntrospectRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// IntrospectResponse follows RFC 7662: inactive tokens only report active=false
+type IntrospectResponse struct {
+	Active         bool             `json:"active"`
+	UserID         *uuid.UUID       `json:"user_id,omitempty"`
+	Phone          string           `json:"phone,omitempty"`
+	Role           models.UserRole  `json:"role,omitempty"` // Active profile
+	Roles          models.UserRoles `json:"roles,omitempty"`
+	Scopes         []string         `json:"scopes,omitempty"`
+	SellerVerified bool             `json:"seller_verified,omitempty"`
+	Features       []string         `json:"features,omitempty"`
+	ExpiresAt      int64            `json:"exp,omitempty"`
+	IssuedAt       int64            `json:"iat,omitempty"`
+}
+
+// @Summary Introspect token
+// @Description Validate a user token on behalf of another service or gateway and return who it belongs to. Requires an API key with the tokens:write scope.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Param request body IntrospectRequest true "Token to introspect"
+// @Success 200 {object} utils.Response{data=IntrospectResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 401 {object} utils.Problem
+// @Router /auth/introspect [post]
+func (h *AuthHandler) IntrospectToken(c *fiber.Ctx) error {
+	var req IntrospectRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.Token == "" {
+		return utils.ValidationErrorResponse(c, "Token is required")
+	}
+
+	inactive := IntrospectResponse{Active: false}
+
+	claims, err := utils.ValidateJWT(req.Token, h.config)
+	if err != nil {
+		return utils.SuccessResponse(c, "Token is not active", inactive)
+	}
+
+	// Logged out and revoked tokens have no session. While Redis is down
+	// the token is judged on its own, as the auth middleware does.
+	if _, err := redis.GetSession(req.Token); err != nil && !errors.Is(err, redis.ErrUnavailable) {
+		return utils.SuccessResponse(c, "Token is not active", inactive)
+	}
+
+	var user models.User
+	if err := database.DB.Select("id", "is_active", "account_status", "suspended_until").First(&user, claims.UserID).Error; err != nil || !user.IsActive || user.IsSuspended() {
+		return utils.SuccessResponse(c, "Token is not active", inactive)
+	}
+
+	response := IntrospectResponse{
+		Active:         true,
+		UserID:         &claims.UserID,
+		Phone:          claims.Phone,
+		Role:           claims.Role,
+		Roles:          claims.Roles,
+		Scopes:         claims.Scopes,
+		SellerVerified: claims.SellerVerified,
+		Features:       claims.Features,
+	}
+	if len(response.Scopes) == 0 {
+		response.Scopes = middleware.RolePermissions(claims.Role)
+	}
+	if claims.ExpiresAt != nil {
+		response.ExpiresAt = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		response.IssuedAt = claims.IssuedAt.Unix()
+	}
+
+	return utils.SuccessResponse(c, "Token is active", response)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type LoginEventListResponse struct {
+	Events []models.LoginEvent `json:"events"`
+	Total  int64               `json:"total"`
+	Page   int                 `json:"page"`
+	Limit  int                 `json:"limit"`
+}
+
+// @Summary Get login history
+// @Description Get the current user's logins, failed attempts, logouts and token refreshes
+// @Tags auth
+// @Security BearerAuth
+// @Param event query string false "Filter by event: login, logout or refresh"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} utils.Response{data=LoginEventListResponse}
+// @Router /auth/login-history [get]
+func (h *AuthHandler) GetLoginHistory(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	query := database.DB.Model(&models.LoginEvent{}).Where("user_id = ?", userID)
+	if event := c.Query("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+
+	return h.sendLoginEvents(c, query)
+}
+
+// @Summary Query login events
+// @Description Search the login audit trail across all users (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param user_id query string false "Filter by user ID"
+// @Param phone query string false "Filter by phone number"
+// @Param ip query string false "Filter by IP address"
+// @Param event query string false "Filter by event: login, logout or refresh"
+// @Param success query bool false "Filter by result"
+// @Param from query string false "Start date (YYYY-MM-DD)"
+// @Param to query string false "End date (YYYY-MM-DD), inclusive"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} utils.Response{data=LoginEventListResponse}
+// @Failure 400 {object} utils.Problem
+// @Router /admin/login-events [get]
+func (h *AuthHandler) GetLoginEvents(c *fiber.Ctx) error {
+	query := database.DB.Model(&models.LoginEvent{})
+
+	if userIDParam := c.Query("user_id"); userIDParam != "" {
+		userID, err := uuid.Parse(userIDParam)
+		if err != nil {
+			return utils.ValidationErrorResponse(c, "Invalid user ID")
+		}
+		query = query.Where("user_id = ?", userID)
+	}
+	if phone := c.Query("phone"); phone != "" {
+		query = query.Where("phone = ?", phone)
+	}
+	if ip := c.Query("ip"); ip != "" {
+		query = query.Where("ip = ?", ip)
+	}
+	if event := c.Query("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+	if success := c.Query("success"); success != "" {
+		query = query.Where("success = ?", success == "true")
+	}
+	if from := c.Query("from"); from != "" {
+		fromDate, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return utils.ValidationErrorResponse(c, "From date must be in YYYY-MM-DD format")
+		}
+		query = query.Where("created_at >= ?", fromDate)
+	}
+	if to := c.Query("to"); to != "" {
+		toDate, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return utils.ValidationErrorResponse(c, "To date must be in YYYY-MM-DD format")
+		}
+		query = query.Where("created_at < ?", toDate.AddDate(0, 0, 1))
+	}
+
+	return h.sendLoginEvents(c, query)
+}
+
+// Helper functions
+
+func (h *AuthHandler) recordLoginEvent(event models.LoginEventType, phone string, userID *uuid.UUID, info deviceInfo, success bool, failureReason string) {
+	// Resolve the user for failed attempts against a known phone number
+	if userID == nil {
+		var user models.User
+		if err := database.DB.Select("id").Where("phone = ?", phone).First(&user).Error; err == nil {
+			userID = &user.ID
+		}
+	}
+
+	loginEvent := models.LoginEvent{
+		BaseModel:     models.BaseModel{ID: uuid.New()},
+		Event:         event,
+		UserID:        userID,
+		Phone:         phone,
+		IP:            info.IP,
+		UserAgent:     info.UserAgent,
+		Fingerprint:   info.Fingerprint,
+		Success:       success,
+		FailureReason: failureReason,
+	}
+	database.DB.Create(&loginEvent)
+}
+
+func (h *AuthHandler) sendLoginEvents(c *fiber.Ctx, query *gorm.DB) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+
+	if page < 1 {
+		page = 1
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var events []models.LoginEvent
+	if err := query.Order("created_at DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&events).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get login events", err)
+	}
+
+	response := LoginEventListResponse{
+		Events: events,
+		Total:  total,
+		Page:   page,
+		Limit:  limit,
+	}
+
+	return utils.SuccessResponse(c, "Login events retrieved successfully", response)
+}
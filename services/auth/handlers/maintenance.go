@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"time"
+
+	"playful-marketplace/shared/middleware"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type MaintenanceRequest struct {
+	Service         string `json:"service" validate:"required"` // A service name, or "all"
+	Enabled         bool   `json:"enabled"`
+	Message         string `json:"message"`          // Defaults to the configured maintenance message
+	RetryAfter      int    `json:"retry_after"`      // Seconds, defaults to the configured retry interval
+	DurationMinutes int    `json:"duration_minutes"` // Turns itself off after this long, 0 lasts until turned off
+}
+
+type MaintenanceStatus struct {
+	Service string                    `json:"service"`
+	Window  *models.MaintenanceWindow `json:"window"` // Nil while the service accepts writes
+}
+
+// @Summary Get maintenance status
+// @Description Get the maintenance windows set at runtime, globally and per service (admin only). Services started with MAINTENANCE_MODE are not listed.
+// @Tags admin
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=[]MaintenanceStatus}
+// @Router /admin/maintenance [get]
+func (h *AuthHandler) GetMaintenance(c *fiber.Ctx) error {
+	services := append([]string{middleware.MaintenanceAll}, middleware.MaintenanceServices...)
+
+	statuses := make([]MaintenanceStatus, 0, len(services))
+	for _, service := range services {
+		window, err := redis.GetMaintenance(service)
+		if err != nil {
+			return utils.InternalServerErrorResponse(c, "Failed to get maintenance status", err)
+		}
+		statuses = append(statuses, MaintenanceStatus{Service: service, Window: window})
+	}
+
+	return utils.SuccessResponse(c, "Maintenance status retrieved successfully", statuses)
+}
+
+// @Summary Toggle maintenance mode
+// @Description Turn maintenance mode on or off for one service or all of them (admin only). While on, writes return 503 with Retry-After and reads keep working.
+// @Tags admin
+// @Security BearerAuth
+// @Param request body MaintenanceRequest true "Maintenance settings"
+// @Success 200 {object} utils.Response{data=MaintenanceStatus}
+// @Failure 400 {object} utils.Problem
+// @Router /admin/maintenance [put]
+func (h *AuthHandler) SetMaintenance(c *fiber.Ctx) error {
+	adminID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req MaintenanceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if !validMaintenanceService(req.Service) {
+		return utils.ValidationErrorResponse(c, "Service must be all or one of auth, user, product, order, payment, gamification")
+	}
+	if req.RetryAfter < 0 || req.DurationMinutes < 0 {
+		return utils.ValidationErrorResponse(c, "Retry after and duration must not be negative")
+	}
+
+	if !req.Enabled {
+		if err := redis.ClearMaintenance(req.Service); err != nil {
+			return utils.InternalServerErrorResponse(c, "Failed to turn off maintenance mode", err)
+		}
+		return utils.SuccessResponse(c, "Maintenance mode turned off", MaintenanceStatus{Service: req.Service})
+	}
+
+	window := models.MaintenanceWindow{
+		Service:    req.Service,
+		Message:    req.Message,
+		RetryAfter: req.RetryAfter,
+		StartedBy:  &adminID,
+		StartedAt:  time.Now(),
+	}
+	if window.Message == "" {
+		window.Message = h.config.Maintenance.Message
+	}
+	if window.RetryAfter == 0 {
+		window.RetryAfter = int(h.config.Maintenance.RetryAfter / time.Second)
+	}
+
+	duration := time.Duration(req.DurationMinutes) * time.Minute
+	if duration > 0 {
+		endsAt := window.StartedAt.Add(duration)
+		window.EndsAt = &endsAt
+	}
+
+	if err := redis.SetMaintenance(window, duration); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to turn on maintenance mode", err)
+	}
+
+	return utils.SuccessResponse(c, "Maintenance mode turned on", MaintenanceStatus{Service: req.Service, Window: &window})
+}
+
+// Helper functions
+
+func validMaintenanceService(service string) bool {
+	if service == middleware.MaintenanceAll {
+		return true
+	}
+	for _, s := range middleware.MaintenanceServices {
+		if s == service {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/regions"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const maxPhoneChangeAttempts = 5
+
+var errPhoneTaken = errors.New("phone number already in use")
+
+type PhoneChangeRequest struct {
+	NewPhone string `json:"new_phone" validate:"required"`
+}
+
+type ConfirmPhoneChangeRequest struct {
+	OTP          string `json:"otp" validate:"required"`
+	TOTPCode     string `json:"totp_code"`     // Required when two-factor authentication is enabled
+	RecoveryCode string `json:"recovery_code"` // Alternative to totp_code
+}
+
+// pendingPhoneChange is kept in Redis between the request and confirm steps
+type pendingPhoneChange struct {
+	NewPhone string `json:"new_phone"`
+	OTP      string `json:"otp"`
+	Attempts int    `json:"attempts"`
+}
+
+// @Summary Request phone number change
+// @Description Send a verification code to the new phone number. The change takes effect once confirmed.
+// @Tags auth
+// @Security BearerAuth
+// @Param request body PhoneChangeRequest true "New phone number"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /auth/phone/change [post]
+func (h *AuthHandler) RequestPhoneChange(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, "User not found")
+	}
+
+	if user.Role == models.RoleGuest {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "Create an account before changing your phone number", nil)
+	}
+
+	var req PhoneChangeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.NewPhone == "" {
+		return utils.ValidationErrorResponse(c, "New phone number is required")
+	}
+	if req.NewPhone == user.Phone {
+		return utils.ValidationErrorResponse(c, "New phone number must differ from the current one")
+	}
+	if _, ok := regions.ForPhone(req.NewPhone); !ok {
+		return utils.ValidationErrorResponse(c, unsupportedPhoneMessage)
+	}
+
+	var count int64
+	database.DB.Model(&models.User{}).Unscoped().Where("phone = ?", req.NewPhone).Count(&count)
+	if count > 0 {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "Phone number is already in use", nil)
+	}
+
+	pending := pendingPhoneChange{
+		NewPhone: req.NewPhone,
+		OTP:      h.generateMockOTP(),
+	}
+	if err := redis.Set(redis.PhoneChangeKey(user.ID), pending); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to store verification code", err)
+	}
+
+	notifications.SendSMS(req.NewPhone, fmt.Sprintf("Your Playful Marketplace verification code is %s", pending.OTP))
+
+	return utils.SuccessResponse(c, "Verification code sent to the new phone number", nil)
+}
+
+// @Summary Confirm phone number change
+// @Description Confirm the new phone number with the code sent to it. All existing sessions are signed out and a new token is returned.
+// @Tags auth
+// @Security BearerAuth
+// @Param request body ConfirmPhoneChangeRequest true "Verification code"
+// @Success 200 {object} utils.Response{data=AuthResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 401 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /auth/phone/confirm [post]
+func (h *AuthHandler) ConfirmPhoneChange(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, "User not found")
+	}
+
+	var req ConfirmPhoneChangeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	key := redis.PhoneChangeKey(user.ID)
+	var pending pendingPhoneChange
+	if err := redis.Get(key, &pending); err != nil {
+		return utils.ValidationErrorResponse(c, "No pending phone change or the code has expired")
+	}
+
+	if req.OTP != pending.OTP {
+		// Drop the request after too many wrong codes so it can't be brute-forced
+		pending.Attempts++
+		if pending.Attempts >= maxPhoneChangeAttempts {
+			redis.Delete(key)
+		} else {
+			redis.Set(key, pending)
+		}
+		return utils.UnauthorizedResponse(c, "Invalid verification code")
+	}
+
+	if user.TOTPEnabled && !h.verifySecondFactor(user, req.TOTPCode, req.RecoveryCode) {
+		return utils.UnauthorizedResponse(c, "Invalid two-factor code")
+	}
+
+	device := newDeviceInfo(c)
+	oldPhone := user.Phone
+
+	err = database.ActingAs(user.ID).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.User{}).Unscoped().Where("phone = ? AND id <> ?", pending.NewPhone, user.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errPhoneTaken
+		}
+
+		// The unique index on phone rejects a concurrent claim of the same number
+		if err := tx.Model(user).Update("phone", pending.NewPhone).Error; err != nil {
+			return errPhoneTaken
+		}
+
+		change := models.PhoneChange{
+			BaseModel: models.BaseModel{ID: uuid.New()},
+			UserID:    user.ID,
+			OldPhone:  oldPhone,
+			NewPhone:  pending.NewPhone,
+			IP:        device.IP,
+			UserAgent: device.UserAgent,
+		}
+		return tx.Create(&change).Error
+	})
+	if errors.Is(err, errPhoneTaken) {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "Phone number is already in use", nil)
+	}
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to change phone number", err)
+	}
+
+	user.Phone = pending.NewPhone
+	redis.Delete(key)
+	redis.Delete(redis.OTPKey(oldPhone))
+
+	// Tokens carry the old phone, so sign out everywhere and issue a fresh one
+	token, err := h.reissueSession(user)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create session", err)
+	}
+
+	notifications.SendSMS(oldPhone, "The phone number on your Playful Marketplace account was changed. If this wasn't you, contact support immediately.")
+	notifications.Send(user.ID, models.NotificationSecurity, "Phone number changed",
+		fmt.Sprintf("Your account phone number was changed to %s.", pending.NewPhone))
+
+	response := AuthResponse{
+		Token: token,
+		User:  user,
+	}
+
+	return utils.SuccessResponse(c, "Phone number changed successfully", response)
+}
+
+// Helper functions
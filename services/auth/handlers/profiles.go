@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type SwitchProfileRequest struct {
+	Role models.UserRole `json:"role" validate:"required"` // buyer or seller
+}
+
+// @Summary Switch profile
+// @Description Switch an account holding both buyer and seller profiles to the other one. The returned token replaces the current one, and role checks on later requests apply to the chosen profile. New sessions also start in it.
+// @Tags auth
+// @Security BearerAuth
+// @Param request body SwitchProfileRequest true "Profile to switch to"
+// @Success 200 {object} utils.Response{data=AuthResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Router /auth/profile [post]
+func (h *AuthHandler) SwitchProfile(c *fiber.Ctx) error {
+	session, ok := c.Locals("session").(*models.Session)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "Session not found")
+	}
+	// Store tokens act for someone else's store, not the staff member's own profiles
+	if c.Locals("actor_id") != nil {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "Store tokens cannot switch profiles", nil)
+	}
+
+	var req SwitchProfileRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+	if req.Role != models.RoleBuyer && req.Role != models.RoleSeller {
+		return utils.ValidationErrorResponse(c, "Role must be 'buyer' or 'seller'")
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, session.UserID).Error; err != nil || !user.IsActive || user.IsSuspended() {
+		return utils.UnauthorizedResponse(c, "User not found or inactive")
+	}
+	if !user.HasRole(req.Role) {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You don't have a "+string(req.Role)+" profile", nil)
+	}
+
+	if user.Role != req.Role {
+		if err := database.ActingAs(user.ID).Model(&user).Update("role", req.Role).Error; err != nil {
+			return utils.InternalServerErrorResponse(c, "Failed to switch profile", err)
+		}
+	}
+
+	token, err := h.createSession(&user)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create session", err)
+	}
+	redis.DeleteSession(session.Token)
+
+	response := AuthResponse{
+		Token: token,
+		User:  &user,
+	}
+
+	return utils.SuccessResponse(c, "Switched to your "+string(req.Role)+" profile", response)
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/referrals"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type SellerReferralsResponse struct {
+	ReferralCode    string                  `json:"referral_code"`
+	QualifyingSales int                     `json:"qualifying_sales"` // Delivered sales a referred seller needs before you're rewarded
+	Referrals       []models.SellerReferral `json:"referrals"`
+	TotalRewardXP   int                     `json:"total_reward_xp"`
+	TotalEarned     float64                 `json:"total_earned"`
+}
+
+// @Summary Get seller referrals
+// @Description Get the current seller's referral code and the progress of each seller they referred. Referrers are rewarded once a referred seller completes the qualifying delivered sales.
+// @Tags auth
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=SellerReferralsResponse}
+// @Router /auth/referrals [get]
+func (h *AuthHandler) GetSellerReferrals(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return utils.NotFoundResponse(c, "User not found")
+	}
+
+	code, err := referrals.SellerCode(&user)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get referral code", err)
+	}
+
+	var list []models.SellerReferral
+	if err := database.DB.Preload("Referred").Where("referrer_id = ?", userID).
+		Order("created_at DESC").Find(&list).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get referrals", err)
+	}
+
+	response := SellerReferralsResponse{
+		ReferralCode:    code,
+		QualifyingSales: h.config.Referrals.SellerQualifyingSales,
+		Referrals:       list,
+	}
+	for _, referral := range list {
+		response.TotalRewardXP += referral.RewardXP
+		response.TotalEarned += referral.RewardAmount
+	}
+
+	return utils.SuccessResponse(c, "Referrals retrieved successfully", response)
+}
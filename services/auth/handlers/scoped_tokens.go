@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/middleware"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type CreateScopedTokenRequest struct {
+	Scopes         []string `json:"scopes" validate:"required"` // e.g. payments:read
+	ExpiresInHours int      `json:"expires_in_hours"`           // Defaults to, and cannot exceed, the role's token lifetime
+}
+
+type ScopedTokenResponse struct {
+	Token     string    `json:"token"`
+	Scopes    []string  `json:"scopes"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// @Summary Create scoped token
+// @Description Mint a least-privilege token for the current user, limited to the given scopes, for the mobile SDK and partner integrations. Scopes cannot exceed the user's permissions. Scoped tokens only work on route groups that accept them and cannot refresh or mint other tokens.
+// @Tags auth
+// @Security BearerAuth
+// @Param request body CreateScopedTokenRequest true "Scoped token request"
+// @Success 201 {object} utils.Response{data=ScopedTokenResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Router /auth/tokens [post]
+func (h *AuthHandler) CreateScopedToken(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+	userRole, _ := c.Locals("user_role").(models.UserRole)
+
+	var req CreateScopedTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if len(req.Scopes) == 0 {
+		return utils.ValidationErrorResponse(c, "At least one scope is required")
+	}
+	if req.ExpiresInHours < 0 {
+		return utils.ValidationErrorResponse(c, "Expiry cannot be negative")
+	}
+
+	for _, scope := range req.Scopes {
+		if !strings.Contains(scope, ":") {
+			return utils.ValidationErrorResponse(c, "Scopes must be in resource:action format")
+		}
+		if !middleware.HasPermission(userRole, scope) {
+			return utils.ErrorResponse(c, fiber.StatusForbidden, "You cannot grant the "+scope+" scope", nil)
+		}
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return utils.NotFoundResponse(c, "User not found")
+	}
+
+	ttl := utils.TokenTTL(user.Role, h.config)
+	if requested := time.Duration(req.ExpiresInHours) * time.Hour; requested > 0 && requested < ttl {
+		ttl = requested
+	}
+
+	token, err := utils.GenerateScopedJWT(&user, h.config, req.Scopes, ttl)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to generate token", err)
+	}
+
+	// Scoped tokens get their own session so logging out everywhere revokes them too
+	session := &models.Session{
+		UserID:    user.ID,
+		Token:     token,
+		ExpiresAt: time.Now().Add(ttl),
+		CreatedAt: time.Now(),
+	}
+	if err := redis.SetSession(session); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create session", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Scoped token created successfully",
+		Data: ScopedTokenResponse{
+			Token:     token,
+			Scopes:    req.Scopes,
+			ExpiresAt: session.ExpiresAt,
+		},
+	})
+}
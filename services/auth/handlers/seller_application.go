@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/referrals"
+	"playful-marketplace/shared/stores"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var errApplicationReviewed = errors.New("application has already been reviewed")
+
+type SellerApplicationRequest struct {
+	BusinessName  string `json:"business_name" validate:"required"`
+	IDDocumentURL string `json:"id_document_url" validate:"required"`
+	PayoutMethod  string `json:"payout_method" validate:"required"` // bank or mobile_money
+	PayoutAccount string `json:"payout_account" validate:"required"`
+	ReferralCode  string `json:"referral_code"` // Code of the seller who referred you
+}
+
+type SellerApplicationReviewRequest struct {
+	Decision string `json:"decision" validate:"required"` // approve or reject
+	Notes    string `json:"notes"`
+}
+
+type SellerApplicationListResponse struct {
+	Applications []models.SellerApplication `json:"applications"`
+	Total        int64                      `json:"total"`
+	Page         int                        `json:"page"`
+	Limit        int                        `json:"limit"`
+}
+
+// @Summary Apply to become a seller
+// @Description Submit business and payout details to add a seller profile to a buyer account. An admin reviews the application.
+// @Tags auth
+// @Security BearerAuth
+// @Param request body SellerApplicationRequest true "Seller details"
+// @Success 201 {object} utils.Response{data=models.SellerApplication}
+// @Failure 400 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /auth/upgrade-to-seller [post]
+func (h *AuthHandler) ApplyForSeller(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+	if roles, _ := c.Locals("user_roles").(models.UserRoles); roles.Has(models.RoleSeller) {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "You already have a seller profile", nil)
+	}
+
+	var req SellerApplicationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.BusinessName == "" || req.IDDocumentURL == "" || req.PayoutAccount == "" {
+		return utils.ValidationErrorResponse(c, "Business name, ID document and payout account are required")
+	}
+	if req.PayoutMethod != "bank" && req.PayoutMethod != "mobile_money" {
+		return utils.ValidationErrorResponse(c, "Payout method must be 'bank' or 'mobile_money'")
+	}
+
+	var count int64
+	database.DB.Model(&models.SellerApplication{}).
+		Where("user_id = ? AND status = ?", userID, models.SellerApplicationPending).Count(&count)
+	if count > 0 {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "You already have an application under review", nil)
+	}
+
+	var referrerID *uuid.UUID
+	if req.ReferralCode != "" {
+		referrer, err := referrals.ResolveCode(req.ReferralCode)
+		if err != nil {
+			return utils.ValidationErrorResponse(c, "Referral code is not valid")
+		}
+		referrerID = &referrer.ID
+	}
+
+	application := models.SellerApplication{
+		BaseModel:     models.BaseModel{ID: uuid.New()},
+		UserID:        userID,
+		BusinessName:  req.BusinessName,
+		IDDocumentURL: req.IDDocumentURL,
+		PayoutMethod:  req.PayoutMethod,
+		PayoutAccount: req.PayoutAccount,
+		ReferrerID:    referrerID,
+		Status:        models.SellerApplicationPending,
+	}
+	if err := database.DB.Create(&application).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to submit application", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Application submitted. We'll notify you once it has been reviewed.",
+		Data:    application,
+	})
+}
+
+// @Summary Get seller application
+// @Description Get the current user's most recent seller application
+// @Tags auth
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=models.SellerApplication}
+// @Failure 404 {object} utils.Problem
+// @Router /auth/upgrade-to-seller [get]
+func (h *AuthHandler) GetSellerApplication(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var application models.SellerApplication
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at DESC").First(&application).Error; err != nil {
+		return utils.NotFoundResponse(c, "No seller application found")
+	}
+
+	return utils.SuccessResponse(c, "Application retrieved successfully", application)
+}
+
+// @Summary Get seller applications
+// @Description Get seller applications awaiting review (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param status query string false "Filter by status" default(pending)
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} utils.Response{data=SellerApplicationListResponse}
+// @Router /admin/seller-applications [get]
+func (h *AuthHandler) GetSellerApplications(c *fiber.Ctx) error {
+	status := c.Query("status", string(models.SellerApplicationPending))
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+
+	if page < 1 {
+		page = 1
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	query := database.DB.Model(&models.SellerApplication{}).Where("status = ?", status)
+
+	var total int64
+	query.Count(&total)
+
+	var applications []models.SellerApplication
+	if err := query.Preload("User").
+		Order("created_at ASC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&applications).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get seller applications", err)
+	}
+
+	response := SellerApplicationListResponse{
+		Applications: applications,
+		Total:        total,
+		Page:         page,
+		Limit:        limit,
+	}
+
+	return utils.SuccessResponse(c, "Seller applications retrieved successfully", response)
+}
+
+// @Summary Review seller application
+// @Description Approve or reject a seller application (admin only). Approval adds a seller profile to the applicant's account, which they switch to with /auth/profile. The verified badge is earned separately through seller verification.
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Application ID"
+// @Param request body SellerApplicationReviewRequest true "Review decision"
+// @Success 200 {object} utils.Response{data=models.SellerApplication}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /admin/seller-applications/{id} [post]
+func (h *AuthHandler) ReviewSellerApplication(c *fiber.Ctx) error {
+	applicationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid application ID")
+	}
+
+	adminID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req SellerApplicationReviewRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.Decision != "approve" && req.Decision != "reject" {
+		return utils.ValidationErrorResponse(c, "Decision must be 'approve' or 'reject'")
+	}
+
+	var application models.SellerApplication
+	if err := database.DB.First(&application, applicationID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Application not found")
+	}
+
+	status := models.SellerApplicationRejected
+	if req.Decision == "approve" {
+		status = models.SellerApplicationApproved
+	}
+
+	now := time.Now()
+	err = database.ActingAs(adminID).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&application).Where("status = ?", models.SellerApplicationPending).Updates(map[string]interface{}{
+			"status":      status,
+			"reviewed_by": adminID,
+			"reviewed_at": now,
+			"notes":       req.Notes,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errApplicationReviewed
+		}
+
+		if status != models.SellerApplicationApproved {
+			return nil
+		}
+		// Sellers keep their buyer profile and switch between the two
+		if err := tx.Model(&models.User{}).Where("id = ?", application.UserID).
+			Update("roles", models.RolesFor(models.RoleSeller)).Error; err != nil {
+			return err
+		}
+		// The business details become the store that owns the listings and payouts
+		if _, err := stores.Create(tx, application.UserID, application.BusinessName, application.PayoutMethod,
+			application.PayoutAccount); err != nil && !errors.Is(err, stores.ErrStoreExists) {
+			return err
+		}
+		if application.ReferrerID != nil {
+			return referrals.Create(tx, *application.ReferrerID, application.UserID)
+		}
+		return nil
+	})
+	if errors.Is(err, errApplicationReviewed) {
+		return utils.ValidationErrorResponse(c, "Application has already been reviewed")
+	}
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to review application", err)
+	}
+
+	if status == models.SellerApplicationApproved {
+		notifications.Send(application.UserID, models.NotificationAccount, "You're now a seller",
+			fmt.Sprintf("Your seller application for %s was approved. Switch to your seller profile to start listing products.", application.BusinessName))
+	} else {
+		message := fmt.Sprintf("Your seller application for %s was not approved.", application.BusinessName)
+		if req.Notes != "" {
+			message += " " + req.Notes
+		}
+		notifications.Send(application.UserID, models.NotificationAccount, "Seller application declined", message)
+	}
+
+	database.DB.First(&application, application.ID)
+
+	return utils.SuccessResponse(c, "Application reviewed successfully", application)
+}
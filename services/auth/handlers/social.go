@@ -0,0 +1,425 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/captcha"
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/jobs"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/regions"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SocialLoginRequest struct {
+	IDToken      string `json:"id_token" validate:"required"`
+	Name         string `json:"name"`          // Apple only shares the name on first sign-in, so clients pass it along
+	Phone        string `json:"phone"`         // Required when no account matches the token
+	OTP          string `json:"otp"`           // Code sent to phone, required to create an account
+	TOTPCode     string `json:"totp_code"`     // Required when two-factor authentication is enabled
+	RecoveryCode string `json:"recovery_code"` // Alternative to totp_code
+	CaptchaToken string `json:"captcha_token"` // Required with otp left out when CAPTCHA is enabled
+}
+
+// Codes sent to a phone for social sign-ups, per window
+const (
+	signupOTPLimit  = 3
+	signupOTPWindow = time.Hour
+)
+
+type LinkIdentityRequest struct {
+	IDToken string `json:"id_token" validate:"required"`
+}
+
+// @Summary Log in with Google or Apple
+// @Description Exchange a Google or Apple ID token for a marketplace token. The identity is matched to a linked account, then to an account with the same verified email; an account whose email is unverified has to log in and link the provider instead. Otherwise a new buyer account is created, which requires a phone number verified with a code: leave otp out to have one sent, passing captcha_token, then repeat the request with it.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param provider path string true "Provider: google or apple"
+// @Param request body SocialLoginRequest true "Social login request"
+// @Success 200 {object} utils.Response{data=AuthResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 401 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /auth/social/{provider} [post]
+func (h *AuthHandler) SocialLogin(c *fiber.Ctx) error {
+	provider, ok := h.oidcProvider(c.Params("provider"))
+	if !ok {
+		return utils.ValidationErrorResponse(c, "Provider must be 'google' or 'apple'")
+	}
+
+	var req SocialLoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.IDToken == "" {
+		return utils.ValidationErrorResponse(c, "ID token is required")
+	}
+
+	claims, err := utils.VerifyIDToken(provider, req.IDToken)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, "Invalid ID token")
+	}
+
+	device := newDeviceInfo(c)
+	email := normalizeEmail(claims.Email)
+
+	var user models.User
+	var identity models.Identity
+	created := false
+	secondFactorChecked := false
+
+	err = database.DB.Where("provider = ? AND subject = ?", provider.Name, claims.Subject).First(&identity).Error
+	switch {
+	case err == nil:
+		if err := database.DB.Where("id = ? AND is_active = ?", identity.UserID, true).First(&user).Error; err != nil {
+			return utils.UnauthorizedResponse(c, "Account is not active")
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// Only link to an existing account when both the provider and the
+		// account owner have verified the email
+		linked := false
+		if email != "" && claims.IsEmailVerified() {
+			if err := database.DB.Where("LOWER(email) = ? AND role <> ?", email, models.RoleGuest).First(&user).Error; err == nil {
+				if !user.IsActive {
+					return utils.UnauthorizedResponse(c, "Account is not active")
+				}
+				if user.EmailVerifiedAt == nil {
+					return utils.ErrorResponse(c, fiber.StatusConflict, "An account with this email already exists. Log in and link this provider instead.", nil)
+				}
+				linked = true
+			}
+		}
+
+		if !linked {
+			if req.Phone == "" {
+				return utils.ProblemResponse(c, utils.Problem{
+					Status: fiber.StatusBadRequest,
+					Code:   "phone_required",
+					Detail: "Phone number required to create an account",
+					Errors: []utils.FieldError{{Field: "phone", Message: "Phone number is required"}},
+					Data:   fiber.Map{"phone_required": true},
+				})
+			}
+
+			if _, ok := regions.ForPhone(req.Phone); !ok {
+				return utils.FieldErrorsResponse(c, unsupportedPhoneMessage, utils.FieldError{Field: "phone", Message: unsupportedPhoneMessage})
+			}
+
+			var existing models.User
+			if err := database.DB.Unscoped().Where("phone = ?", req.Phone).First(&existing).Error; err == nil {
+				return utils.ErrorResponse(c, fiber.StatusConflict, "User with this phone number already exists. Log in and link this provider instead.", nil)
+			}
+
+			name := req.Name
+			if name == "" {
+				name = claims.Name
+			}
+			if name == "" {
+				return utils.ValidationErrorResponse(c, "Name is required")
+			}
+
+			if req.OTP == "" {
+				// Keep bots from pumping texts to the phone
+				if err := captcha.Verify(h.config.Captcha, req.CaptchaToken, c.IP()); err != nil {
+					return captchaErrorResponse(c, err)
+				}
+				if err := h.sendSignupOTP(req.Phone); errors.Is(err, errSignupOTPLimit) {
+					return utils.ErrorResponse(c, fiber.StatusTooManyRequests, "Too many codes sent to this phone. Try again later.", nil)
+				} else if err != nil {
+					return utils.InternalServerErrorResponse(c, "Failed to send OTP", err)
+				}
+				return utils.ProblemResponse(c, utils.Problem{
+					Status: fiber.StatusBadRequest,
+					Code:   "otp_required",
+					Detail: "Enter the code sent to your phone to create an account",
+					Errors: []utils.FieldError{{Field: "otp", Message: "OTP is required"}},
+					Data:   fiber.Map{"otp_required": true},
+				})
+			}
+			var storedOTP string
+			if err := redis.Get(redis.OTPKey(req.Phone), &storedOTP); err != nil || storedOTP != req.OTP {
+				// Burn the code so it can't be brute-forced
+				redis.Delete(redis.OTPKey(req.Phone))
+				return utils.UnauthorizedResponse(c, "Invalid or expired OTP")
+			}
+
+			user = models.User{
+				BaseModel: models.BaseModel{ID: uuid.New()},
+				Phone:     req.Phone,
+				Name:      name,
+				Role:      models.RoleBuyer,
+				Roles:     models.RolesFor(models.RoleBuyer),
+				Level:     models.LevelBronze,
+				IsActive:  true,
+			}
+			if claims.IsEmailVerified() {
+				user.Email = email
+				verifiedAt := time.Now()
+				user.EmailVerifiedAt = &verifiedAt
+			}
+			created = true
+		}
+
+		identity = models.Identity{
+			BaseModel: models.BaseModel{ID: uuid.New()},
+			UserID:    user.ID,
+			Provider:  provider.Name,
+			Subject:   claims.Subject,
+			Email:     email,
+		}
+
+		// Checked once, before linking: verifying spends the code
+		if err := h.checkSocialSecondFactor(&user, req.TOTPCode, req.RecoveryCode); err != nil {
+			return h.socialSecondFactorResponse(c, &user, device, err)
+		}
+		secondFactorChecked = true
+
+		if err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if created {
+				if err := tx.Create(&user).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Create(&identity).Error
+		}); err != nil {
+			return utils.InternalServerErrorResponse(c, "Failed to link account", err)
+		}
+		if created {
+			redis.Delete(redis.OTPKey(req.Phone))
+		}
+	default:
+		return utils.InternalServerErrorResponse(c, "Failed to look up identity", err)
+	}
+
+	if user.IsSuspended() {
+		go h.recordLoginEvent(models.LoginEventLogin, user.Phone, &user.ID, device, false, "suspended")
+		return suspendedResponse(c, &user)
+	}
+
+	if !secondFactorChecked {
+		if err := h.checkSocialSecondFactor(&user, req.TOTPCode, req.RecoveryCode); err != nil {
+			return h.socialSecondFactorResponse(c, &user, device, err)
+		}
+	}
+
+	evicted, err := h.enforceSessionLimit(user.ID)
+	if errors.Is(err, errSessionLimit) {
+		go h.recordLoginEvent(models.LoginEventLogin, user.Phone, &user.ID, device, false, "session_limit")
+		return h.sessionLimitResponse(c)
+	}
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to check active sessions", err)
+	}
+
+	now := time.Now()
+	user.LastLoginAt = &now
+	database.DB.Model(&user).Update("last_login_at", now)
+
+	token, err := h.createSession(&user)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create session", err)
+	}
+
+	go h.trackDevice(&user, device, created)
+	go h.recordLoginEvent(models.LoginEventLogin, user.Phone, &user.ID, device, true, "")
+	if created {
+		jobs.Enqueue(jobEarlyBirdBadge, earlyBirdJob{UserID: user.ID})
+	}
+
+	response := AuthResponse{
+		Token:           token,
+		User:            &user,
+		EvictedSessions: evicted,
+	}
+
+	return utils.SuccessResponse(c, "Login successful", response)
+}
+
+// @Summary Link a login provider
+// @Description Link a Google or Apple identity to the current account so it can be used to log in
+// @Tags auth
+// @Security BearerAuth
+// @Param provider path string true "Provider: google or apple"
+// @Param request body LinkIdentityRequest true "ID token"
+// @Success 201 {object} utils.Response{data=models.Identity}
+// @Failure 400 {object} utils.Problem
+// @Failure 401 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /auth/identities/{provider} [post]
+func (h *AuthHandler) LinkIdentity(c *fiber.Ctx) error {
+	provider, ok := h.oidcProvider(c.Params("provider"))
+	if !ok {
+		return utils.ValidationErrorResponse(c, "Provider must be 'google' or 'apple'")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	if role, _ := c.Locals("user_role").(models.UserRole); role == models.RoleGuest {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "Create an account before linking a login provider", nil)
+	}
+
+	var req LinkIdentityRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	claims, err := utils.VerifyIDToken(provider, req.IDToken)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, "Invalid ID token")
+	}
+
+	var existing models.Identity
+	if err := database.DB.Where("provider = ? AND subject = ?", provider.Name, claims.Subject).First(&existing).Error; err == nil {
+		if existing.UserID == userID {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "This identity is already linked to your account", nil)
+		}
+		return utils.ErrorResponse(c, fiber.StatusConflict, "This identity is linked to another account", nil)
+	}
+
+	identity := models.Identity{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		UserID:    userID,
+		Provider:  provider.Name,
+		Subject:   claims.Subject,
+		Email:     normalizeEmail(claims.Email),
+	}
+	if err := database.DB.Create(&identity).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to link identity", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Identity linked successfully",
+		Data:    identity,
+	})
+}
+
+// @Summary Get linked login providers
+// @Description List the Google and Apple identities linked to the current account
+// @Tags auth
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=[]models.Identity}
+// @Router /auth/identities [get]
+func (h *AuthHandler) GetIdentities(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var identities []models.Identity
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at ASC").Find(&identities).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get identities", err)
+	}
+
+	return utils.SuccessResponse(c, "Identities retrieved successfully", identities)
+}
+
+// @Summary Unlink a login provider
+// @Description Remove a linked identity. Phone login keeps working after every provider is unlinked.
+// @Tags auth
+// @Security BearerAuth
+// @Param id path string true "Identity ID"
+// @Success 200 {object} utils.Response
+// @Failure 404 {object} utils.Problem
+// @Router /auth/identities/{id} [delete]
+func (h *AuthHandler) UnlinkIdentity(c *fiber.Ctx) error {
+	identityID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid identity ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	// Hard delete so the identity can be linked again later
+	result := database.DB.Unscoped().Where("id = ? AND user_id = ?", identityID, userID).Delete(&models.Identity{})
+	if result.Error != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to unlink identity", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return utils.NotFoundResponse(c, "Identity not found")
+	}
+
+	return utils.SuccessResponse(c, "Identity unlinked successfully", nil)
+}
+
+// Helper functions
+
+var (
+	errSecondFactorRequired = errors.New("two-factor code required")
+	errSecondFactorInvalid  = errors.New("invalid two-factor code")
+	errSignupOTPLimit       = errors.New("too many sign-up codes sent")
+)
+
+func (h *AuthHandler) oidcProvider(name string) (utils.OIDCProvider, bool) {
+	switch name {
+	case utils.GoogleProvider.Name:
+		provider := utils.GoogleProvider
+		provider.ClientID = h.config.OAuth.GoogleClientID
+		return provider, true
+	case utils.AppleProvider.Name:
+		provider := utils.AppleProvider
+		provider.ClientID = h.config.OAuth.AppleClientID
+		return provider, true
+	}
+	return utils.OIDCProvider{}, false
+}
+
+func (h *AuthHandler) checkSocialSecondFactor(user *models.User, totpCode, recoveryCode string) error {
+	if !user.TOTPEnabled {
+		return nil
+	}
+	if totpCode == "" && recoveryCode == "" {
+		return errSecondFactorRequired
+	}
+	if !h.verifySecondFactor(user, totpCode, recoveryCode) {
+		return errSecondFactorInvalid
+	}
+	return nil
+}
+
+func (h *AuthHandler) socialSecondFactorResponse(c *fiber.Ctx, user *models.User, device deviceInfo, err error) error {
+	if errors.Is(err, errSecondFactorRequired) {
+		return twoFactorRequiredResponse(c)
+	}
+	go h.recordLoginEvent(models.LoginEventLogin, user.Phone, &user.ID, device, false, "invalid_2fa")
+	return utils.UnauthorizedResponse(c, "Invalid two-factor code")
+}
+
+// sendSignupOTP texts a code verifying the phone a social sign-up creates
+// an account with, at most signupOTPLimit times per window
+func (h *AuthHandler) sendSignupOTP(phone string) error {
+	count, err := redis.CountRequest("signup-otp:"+phone, time.Now().Truncate(signupOTPWindow), signupOTPWindow)
+	if err != nil {
+		return err
+	}
+	if count > signupOTPLimit {
+		return errSignupOTPLimit
+	}
+
+	otp := h.generateMockOTP()
+	if err := redis.Set(redis.OTPKey(phone), otp); err != nil {
+		return err
+	}
+	return notifications.SendSMS(phone, "Your Playful Marketplace verification code is "+otp)
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,431 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/middleware"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const staffInvitationTTL = 7 * 24 * time.Hour
+
+// Token scopes each staff permission grants on the seller's store. Payouts
+// and payments are never delegated.
+var staffPermissionScopes = map[models.StaffPermission][]string{
+	models.StaffPermProducts: {middleware.PermProductsRead, middleware.PermProductsWrite, middleware.PermInventoryWrite},
+	models.StaffPermOrders:   {middleware.PermOrdersRead, middleware.PermOrdersWrite},
+}
+
+// Token scopes store managers get on the store: the listings, inventory and
+// orders staff can be given, plus read access to payments for payouts. Like
+// staff tokens they never reach the seller's account.
+var storeManagerScopes = []string{
+	middleware.PermProductsRead, middleware.PermProductsWrite, middleware.PermInventoryWrite,
+	middleware.PermOrdersRead, middleware.PermOrdersWrite,
+	middleware.PermPaymentsRead,
+}
+
+type InviteStaffRequest struct {
+	Phone       string   `json:"phone" validate:"required"`
+	Permissions []string `json:"permissions" validate:"required"` // products, orders
+}
+
+type UpdateStaffRequest struct {
+	Permissions []string `json:"permissions" validate:"required"`
+}
+
+type StoreTokenResponse struct {
+	Token     string    `json:"token"`
+	SellerID  uuid.UUID `json:"seller_id"`
+	Scopes    []string  `json:"scopes"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// @Summary Invite staff
+// @Description Invite a user by phone number to help run the current seller's store with the given permissions: products (manage listings and inventory) and orders (fulfill orders). Staff never get access to payouts.
+// @Tags staff
+// @Security BearerAuth
+// @Param request body InviteStaffRequest true "Invitation"
+// @Success 201 {object} utils.Response{data=models.StoreStaff}
+// @Failure 400 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /auth/staff [post]
+func (h *AuthHandler) InviteStaff(c *fiber.Ctx) error {
+	sellerID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req InviteStaffRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.Phone == "" {
+		return utils.ValidationErrorResponse(c, "Phone number is required")
+	}
+	permissions, err := parseStaffPermissions(req.Permissions)
+	if err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	var seller models.User
+	if err := database.DB.First(&seller, sellerID).Error; err != nil {
+		return utils.NotFoundResponse(c, "User not found")
+	}
+	if seller.Phone == req.Phone {
+		return utils.ValidationErrorResponse(c, "You cannot invite yourself")
+	}
+
+	var count int64
+	database.DB.Model(&models.StoreStaff{}).
+		Where("seller_id = ? AND phone = ? AND (status = ? OR (status = ? AND expires_at > ?))",
+			sellerID, req.Phone, models.StaffActive, models.StaffInvited, time.Now()).
+		Count(&count)
+	if count > 0 {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "This user is already on your staff or has a pending invitation", nil)
+	}
+
+	staff := models.StoreStaff{
+		BaseModel:   models.BaseModel{ID: uuid.New()},
+		SellerID:    sellerID,
+		Phone:       req.Phone,
+		Permissions: permissions,
+		Status:      models.StaffInvited,
+		ExpiresAt:   time.Now().Add(staffInvitationTTL),
+	}
+	if err := database.DB.Create(&staff).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to invite staff", err)
+	}
+
+	message := fmt.Sprintf("%s invited you to help run their store on Playful Marketplace.", seller.Name)
+	var invitee models.User
+	if err := database.DB.Where("phone = ?", req.Phone).First(&invitee).Error; err == nil {
+		notifications.SendWithLink(invitee.ID, models.NotificationAccount, "Store staff invitation",
+			message, "playful://staff/invitations")
+	} else {
+		go notifications.SendSMS(req.Phone, message+" Sign up with this number to accept.")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Invitation sent",
+		Data:    staff,
+	})
+}
+
+// @Summary Get staff
+// @Description List the current seller's staff and pending invitations
+// @Tags staff
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=[]models.StoreStaff}
+// @Router /auth/staff [get]
+func (h *AuthHandler) GetStaff(c *fiber.Ctx) error {
+	sellerID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var staff []models.StoreStaff
+	if err := database.DB.Where("seller_id = ? AND status IN ?", sellerID,
+		[]models.StaffStatus{models.StaffInvited, models.StaffActive}).
+		Order("created_at DESC").Find(&staff).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get staff", err)
+	}
+
+	return utils.SuccessResponse(c, "Staff retrieved successfully", staff)
+}
+
+// @Summary Update staff permissions
+// @Description Change what a staff member can do. Their store tokens are revoked so the new permissions apply straight away.
+// @Tags staff
+// @Security BearerAuth
+// @Param id path string true "Staff ID"
+// @Param request body UpdateStaffRequest true "Permissions"
+// @Success 200 {object} utils.Response{data=models.StoreStaff}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /auth/staff/{id} [put]
+func (h *AuthHandler) UpdateStaff(c *fiber.Ctx) error {
+	staff, err := h.sellerStaff(c)
+	if err != nil {
+		return utils.NotFoundResponse(c, "Staff member not found")
+	}
+
+	var req UpdateStaffRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	permissions, err := parseStaffPermissions(req.Permissions)
+	if err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	if err := database.DB.Model(staff).Update("permissions", permissions).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update staff", err)
+	}
+	staff.Permissions = permissions
+
+	redis.DeleteStaffSessions(staff.ID.String())
+
+	return utils.SuccessResponse(c, "Staff updated successfully", staff)
+}
+
+// @Summary Remove staff
+// @Description Remove a staff member or cancel a pending invitation. Their store tokens are revoked.
+// @Tags staff
+// @Security BearerAuth
+// @Param id path string true "Staff ID"
+// @Success 200 {object} utils.Response
+// @Failure 404 {object} utils.Problem
+// @Router /auth/staff/{id} [delete]
+func (h *AuthHandler) RemoveStaff(c *fiber.Ctx) error {
+	staff, err := h.sellerStaff(c)
+	if err != nil {
+		return utils.NotFoundResponse(c, "Staff member not found")
+	}
+
+	if err := database.DB.Model(staff).Update("status", models.StaffRemoved).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to remove staff", err)
+	}
+
+	redis.DeleteStaffSessions(staff.ID.String())
+
+	if staff.UserID != nil {
+		notifications.Send(*staff.UserID, models.NotificationAccount, "Store access removed",
+			"You have been removed from a store's staff.")
+	}
+
+	return utils.SuccessResponse(c, "Staff removed successfully", nil)
+}
+
+// @Summary Get staff invitations
+// @Description List pending store staff invitations for the current user's phone number
+// @Tags staff
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=[]models.StoreStaff}
+// @Router /auth/staff/invitations [get]
+func (h *AuthHandler) GetStaffInvitations(c *fiber.Ctx) error {
+	phone, _ := c.Locals("user_phone").(string)
+
+	var invitations []models.StoreStaff
+	if err := database.DB.Preload("Seller").
+		Where("phone = ? AND status = ? AND expires_at > ?", phone, models.StaffInvited, time.Now()).
+		Order("created_at DESC").Find(&invitations).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get invitations", err)
+	}
+
+	return utils.SuccessResponse(c, "Invitations retrieved successfully", invitations)
+}
+
+// @Summary Respond to staff invitation
+// @Description Accept or decline a store staff invitation sent to the current user's phone number
+// @Tags staff
+// @Security BearerAuth
+// @Param id path string true "Invitation ID"
+// @Param action path string true "accept or decline"
+// @Success 200 {object} utils.Response{data=models.StoreStaff}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /auth/staff/invitations/{id}/{action} [post]
+func (h *AuthHandler) RespondToStaffInvitation(c *fiber.Ctx) error {
+	invitationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid invitation ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+	phone, _ := c.Locals("user_phone").(string)
+
+	if role, _ := c.Locals("user_role").(models.UserRole); role == models.RoleGuest {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "Create an account before joining a store", nil)
+	}
+
+	updates := map[string]interface{}{}
+	switch c.Params("action") {
+	case "accept":
+		updates["status"] = models.StaffActive
+		updates["user_id"] = userID
+		updates["accepted_at"] = time.Now()
+	case "decline":
+		updates["status"] = models.StaffDeclined
+	default:
+		return utils.ValidationErrorResponse(c, "Action must be 'accept' or 'decline'")
+	}
+
+	var staff models.StoreStaff
+	if err := database.DB.Where("id = ? AND phone = ?", invitationID, phone).First(&staff).Error; err != nil {
+		return utils.NotFoundResponse(c, "Invitation not found")
+	}
+
+	if staff.Status != models.StaffInvited || time.Now().After(staff.ExpiresAt) {
+		return utils.ValidationErrorResponse(c, "Invitation is no longer valid")
+	}
+
+	result := database.DB.Model(&staff).Where("status = ?", models.StaffInvited).Updates(updates)
+	if result.Error != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to respond to invitation", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return utils.ValidationErrorResponse(c, "Invitation is no longer valid")
+	}
+
+	if updates["status"] == models.StaffActive {
+		notifications.Send(staff.SellerID, models.NotificationAccount, "Staff invitation accepted",
+			fmt.Sprintf("%s joined your store staff.", phone))
+	}
+
+	database.DB.First(&staff, staff.ID)
+
+	return utils.SuccessResponse(c, "Invitation updated successfully", staff)
+}
+
+// @Summary Get my stores
+// @Description List the stores the current user is staff on
+// @Tags staff
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=[]models.StoreStaff}
+// @Router /auth/stores [get]
+func (h *AuthHandler) GetStaffStores(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var stores []models.StoreStaff
+	if err := database.DB.Preload("Seller").Where("user_id = ? AND status = ?", userID, models.StaffActive).
+		Order("accepted_at DESC").Find(&stores).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get stores", err)
+	}
+
+	return utils.SuccessResponse(c, "Stores retrieved successfully", stores)
+}
+
+// @Summary Get store token
+// @Description Get a token to act on a store as staff or as a store manager. It carries the seller's identity, limited to the scopes the staff permissions allow (managers also get payouts), and stops working when the staff member or manager is removed or their permissions change.
+// @Tags staff
+// @Security BearerAuth
+// @Param seller_id path string true "Seller ID"
+// @Success 201 {object} utils.Response{data=StoreTokenResponse}
+// @Failure 403 {object} utils.Problem
+// @Router /auth/stores/{seller_id}/token [post]
+func (h *AuthHandler) CreateStoreToken(c *fiber.Ctx) error {
+	sellerID, err := uuid.Parse(c.Params("seller_id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid seller ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	// Store managers get the manager scopes; other staff are limited to
+	// their permissions
+	var scopes []string
+	var trackID string
+	var member models.StoreMember
+	if err := database.DB.Joins("JOIN stores ON stores.id = store_members.store_id").
+		Where("stores.owner_id = ? AND store_members.user_id = ? AND store_members.role = ?", sellerID, userID, models.StoreManager).
+		First(&member).Error; err == nil {
+		scopes = storeManagerScopes
+		trackID = member.ID.String()
+	} else {
+		var staff models.StoreStaff
+		if err := database.DB.Where("seller_id = ? AND user_id = ? AND status = ?", sellerID, userID, models.StaffActive).
+			First(&staff).Error; err != nil {
+			return utils.ErrorResponse(c, fiber.StatusForbidden, "You are not on this store's staff", nil)
+		}
+		for _, permission := range staff.PermissionList() {
+			scopes = append(scopes, staffPermissionScopes[permission]...)
+		}
+		trackID = staff.ID.String()
+	}
+
+	var seller models.User
+	if err := database.DB.First(&seller, sellerID).Error; err != nil || !seller.IsActive ||
+		!seller.HasRole(models.RoleSeller) || seller.IsSuspended() {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "Store is not active", nil)
+	}
+
+	ttl := utils.TokenTTL(models.RoleSeller, h.config)
+	token, err := utils.GenerateStoreJWT(&seller, userID, h.config, scopes, ttl)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to generate token", err)
+	}
+
+	// The session belongs to the staff member so their own revocations cover it
+	session := &models.Session{
+		UserID:    userID,
+		Token:     token,
+		ExpiresAt: time.Now().Add(ttl),
+		CreatedAt: time.Now(),
+	}
+	if err := redis.SetSession(session); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create session", err)
+	}
+	redis.TrackStaffSession(trackID, token, ttl)
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Store token created successfully",
+		Data: StoreTokenResponse{
+			Token:     token,
+			SellerID:  sellerID,
+			Scopes:    scopes,
+			ExpiresAt: session.ExpiresAt,
+		},
+	})
+}
+
+// Helper functions
+
+// parseStaffPermissions validates the permissions and joins them for storage
+func parseStaffPermissions(permissions []string) (string, error) {
+	if len(permissions) == 0 {
+		return "", fmt.Errorf("at least one permission is required")
+	}
+
+	seen := make(map[string]bool, len(permissions))
+	var list []string
+	for _, p := range permissions {
+		if _, ok := staffPermissionScopes[models.StaffPermission(p)]; !ok {
+			return "", fmt.Errorf("unknown permission %q, must be 'products' or 'orders'", p)
+		}
+		if !seen[p] {
+			seen[p] = true
+			list = append(list, p)
+		}
+	}
+	return strings.Join(list, ","), nil
+}
+
+// sellerStaff loads an invited or active staff member of the current seller
+func (h *AuthHandler) sellerStaff(c *fiber.Ctx) (*models.StoreStaff, error) {
+	staffID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, err
+	}
+
+	sellerID, _ := c.Locals("user_id").(uuid.UUID)
+
+	var staff models.StoreStaff
+	if err := database.DB.Where("id = ? AND seller_id = ? AND status IN ?", staffID, sellerID,
+		[]models.StaffStatus{models.StaffInvited, models.StaffActive}).First(&staff).Error; err != nil {
+		return nil, err
+	}
+	return &staff, nil
+}
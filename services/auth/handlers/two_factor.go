@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	totpIssuer        = "Playful Marketplace"
+	recoveryCodeCount = 10
+)
+
+type TwoFactorCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"` // Render as a QR code for authenticator apps
+}
+
+type TwoFactorActivateResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"` // Shown once, store them safely
+	Token         string   `json:"token"`          // Replaces the current token, other sessions are signed out
+}
+
+type TwoFactorDisableResponse struct {
+	Token string `json:"token"` // Replaces the current token, other sessions are signed out
+}
+
+// @Summary Enroll in two-factor authentication
+// @Description Generate a TOTP secret and provisioning URI. 2FA is enabled once a code is confirmed via /auth/2fa/activate.
+// @Tags auth
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=TwoFactorEnrollResponse}
+// @Failure 409 {object} utils.Problem
+// @Router /auth/2fa/enroll [post]
+func (h *AuthHandler) EnrollTwoFactor(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, "User not found")
+	}
+
+	if user.TOTPEnabled {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "Two-factor authentication is already enabled", nil)
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to generate secret", err)
+	}
+
+	if err := database.DB.Model(user).Update("totp_secret", secret).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to save secret", err)
+	}
+
+	response := TwoFactorEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(secret, totpIssuer, user.Phone),
+	}
+
+	return utils.SuccessResponse(c, "Scan the code with your authenticator app, then confirm with a code", response)
+}
+
+// @Summary Activate two-factor authentication
+// @Description Confirm enrollment with a code from the authenticator app and receive one-time recovery codes. Other sessions are signed out and a new token is returned.
+// @Tags auth
+// @Security BearerAuth
+// @Param request body TwoFactorCodeRequest true "Authenticator code"
+// @Success 200 {object} utils.Response{data=TwoFactorActivateResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 401 {object} utils.Problem
+// @Router /auth/2fa/activate [post]
+func (h *AuthHandler) ActivateTwoFactor(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, "User not found")
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if user.TOTPEnabled {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "Two-factor authentication is already enabled", nil)
+	}
+	if user.TOTPSecret == "" {
+		return utils.ValidationErrorResponse(c, "Start enrollment before activating two-factor authentication")
+	}
+
+	if !h.verifyTOTP(user, req.Code) {
+		return utils.UnauthorizedResponse(c, "Invalid authenticator code")
+	}
+
+	codes, err := generateRecoveryCodes()
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to generate recovery codes", err)
+	}
+
+	err = database.ActingAs(user.ID).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		for _, code := range codes {
+			recoveryCode := models.RecoveryCode{
+				BaseModel: models.BaseModel{ID: uuid.New()},
+				UserID:    user.ID,
+				CodeHash:  hashRecoveryCode(code),
+			}
+			if err := tx.Create(&recoveryCode).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(user).Update("totp_enabled", true).Error
+	})
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to enable two-factor authentication", err)
+	}
+
+	token, err := h.reissueSession(user)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create session", err)
+	}
+
+	return utils.SuccessResponse(c, "Two-factor authentication enabled", TwoFactorActivateResponse{RecoveryCodes: codes, Token: token})
+}
+
+// @Summary Disable two-factor authentication
+// @Description Turn off 2FA after confirming with an authenticator or recovery code. Other sessions are signed out and a new token is returned.
+// @Tags auth
+// @Security BearerAuth
+// @Param request body TwoFactorCodeRequest true "Authenticator or recovery code"
+// @Success 200 {object} utils.Response{data=TwoFactorDisableResponse}
+// @Failure 401 {object} utils.Problem
+// @Router /auth/2fa/disable [post]
+func (h *AuthHandler) DisableTwoFactor(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, "User not found")
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if !user.TOTPEnabled {
+		return utils.ValidationErrorResponse(c, "Two-factor authentication is not enabled")
+	}
+
+	if !h.verifySecondFactor(user, req.Code, req.RecoveryCode) {
+		return utils.UnauthorizedResponse(c, "Invalid two-factor code")
+	}
+
+	err = database.ActingAs(user.ID).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled": false,
+			"totp_secret":  "",
+		}).Error
+	})
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to disable two-factor authentication", err)
+	}
+
+	token, err := h.reissueSession(user)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create session", err)
+	}
+
+	notifications.Send(user.ID, models.NotificationSecurity, "Two-factor authentication disabled",
+		"Two-factor authentication was turned off for your account and your other sessions were signed out.")
+
+	return utils.SuccessResponse(c, "Two-factor authentication disabled", TwoFactorDisableResponse{Token: token})
+}
+
+// Helper functions
+
+func (h *AuthHandler) currentUser(c *fiber.Ctx) (*models.User, error) {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return nil, fmt.Errorf("user ID not found")
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code
+func (h *AuthHandler) verifySecondFactor(user *models.User, code, recoveryCode string) bool {
+	if code != "" {
+		return h.verifyTOTP(user, code)
+	}
+	if recoveryCode != "" {
+		return h.useRecoveryCode(user, recoveryCode)
+	}
+	return false
+}
+
+func (h *AuthHandler) verifyTOTP(user *models.User, code string) bool {
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return false
+	}
+
+	// Each code can only be used once
+	return redis.AcquireLock(redis.TOTPUsedKey(user.ID, step))
+}
+
+func (h *AuthHandler) useRecoveryCode(user *models.User, code string) bool {
+	now := time.Now()
+	result := database.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashRecoveryCode(code)).
+		Update("used_at", &now)
+	return result.Error == nil && result.RowsAffected > 0
+}
+
+func generateRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		encoded := hex.EncodeToString(raw)
+		codes[i] = encoded[:5] + "-" + encoded[5:]
+	}
+	return codes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+	hash := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/utils"
+	"playful-marketplace/shared/webhooks"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const maxWebhookSubscriptions = 10
+
+type CreateWebhookRequest struct {
+	URL            string   `json:"url" validate:"required"` // HTTPS endpoint receiving deliveries
+	EventTypes     []string `json:"event_types"`             // Leave empty for every event
+	Filter         string   `json:"filter"`                  // e.g. data.amount >= `100`, leave empty for every payload
+	PayloadVersion int      `json:"payload_version"`         // Leave out for the current version
+}
+
+type UpdateWebhookRequest struct {
+	URL            *string   `json:"url"`
+	EventTypes     *[]string `json:"event_types"`
+	Filter         *string   `json:"filter"`
+	PayloadVersion *int      `json:"payload_version"`
+	IsActive       *bool     `json:"is_active"`
+	RotateSecret   bool      `json:"rotate_secret"`
+}
+
+type WebhookResponse struct {
+	Secret       string                     `json:"secret,omitempty"` // Only returned when generated, store it securely
+	Subscription models.WebhookSubscription `json:"subscription"`
+}
+
+// @Summary Create webhook subscription
+// @Description Subscribe an HTTPS endpoint to the seller's order and payment events. Deliveries can be limited to chosen event types and to payloads matching a filter, and keep the payload version they were created with. Endpoints must be on public hosts. Each delivery is signed with the subscription secret: X-Signature is the hex HMAC-SHA256 of the X-Timestamp value, a dot and the body; reject stale timestamps and repeated envelope IDs.
+// @Tags auth
+// @Security BearerAuth
+// @Param request body CreateWebhookRequest true "Create webhook request"
+// @Success 201 {object} utils.Response{data=WebhookResponse}
+// @Failure 400 {object} utils.Problem
+// @Router /auth/webhooks [post]
+func (h *AuthHandler) CreateWebhook(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req CreateWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.PayloadVersion == 0 {
+		req.PayloadVersion = webhooks.CurrentPayloadVersion
+	}
+	if message := validateWebhook(req.URL, req.EventTypes, req.Filter, req.PayloadVersion); message != "" {
+		return utils.ValidationErrorResponse(c, message)
+	}
+
+	var count int64
+	database.DB.Model(&models.WebhookSubscription{}).Where("owner_id = ?", userID).Count(&count)
+	if count >= maxWebhookSubscriptions {
+		return utils.ValidationErrorResponse(c, "You can have at most 10 webhook subscriptions")
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to generate webhook secret", err)
+	}
+
+	subscription := models.WebhookSubscription{
+		BaseModel:      models.BaseModel{ID: uuid.New()},
+		OwnerID:        userID,
+		URL:            req.URL,
+		Secret:         secret,
+		EventTypes:     strings.Join(req.EventTypes, ","),
+		Filter:         strings.TrimSpace(req.Filter),
+		PayloadVersion: req.PayloadVersion,
+		IsActive:       true,
+	}
+
+	if err := database.DB.Create(&subscription).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create webhook subscription", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Webhook subscription created successfully",
+		Data: WebhookResponse{
+			Secret:       secret,
+			Subscription: subscription,
+		},
+	})
+}
+
+// @Summary Get webhook subscriptions
+// @Description List the current user's webhook subscriptions
+// @Tags auth
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=[]models.WebhookSubscription}
+// @Router /auth/webhooks [get]
+func (h *AuthHandler) GetWebhooks(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var subscriptions []models.WebhookSubscription
+	if err := database.DB.Where("owner_id = ?", userID).Order("created_at DESC").Find(&subscriptions).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get webhook subscriptions", err)
+	}
+
+	return utils.SuccessResponse(c, "Webhook subscriptions retrieved successfully", subscriptions)
+}
+
+// @Summary Update webhook subscription
+// @Description Change a webhook subscription's endpoint, event types, filter or payload version, pause or resume it, or rotate its secret
+// @Tags auth
+// @Security BearerAuth
+// @Param id path string true "Webhook subscription ID"
+// @Param request body UpdateWebhookRequest true "Update webhook request"
+// @Success 200 {object} utils.Response{data=WebhookResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /auth/webhooks/{id} [put]
+func (h *AuthHandler) UpdateWebhook(c *fiber.Ctx) error {
+	subscription, err := ownWebhook(c)
+	if err != nil {
+		return utils.NotFoundResponse(c, "Webhook subscription not found")
+	}
+
+	var req UpdateWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.URL != nil {
+		subscription.URL = *req.URL
+	}
+	if req.EventTypes != nil {
+		subscription.EventTypes = strings.Join(*req.EventTypes, ",")
+	}
+	if req.Filter != nil {
+		subscription.Filter = strings.TrimSpace(*req.Filter)
+	}
+	if req.PayloadVersion != nil {
+		subscription.PayloadVersion = *req.PayloadVersion
+	}
+	if req.IsActive != nil {
+		subscription.IsActive = *req.IsActive
+	}
+
+	if message := validateWebhook(subscription.URL, subscription.EventTypeList(), subscription.Filter, subscription.PayloadVersion); message != "" {
+		return utils.ValidationErrorResponse(c, message)
+	}
+
+	response := WebhookResponse{}
+	if req.RotateSecret {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			return utils.InternalServerErrorResponse(c, "Failed to generate webhook secret", err)
+		}
+		subscription.Secret = secret
+		response.Secret = secret
+	}
+
+	if err := database.DB.Save(subscription).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update webhook subscription", err)
+	}
+	response.Subscription = *subscription
+
+	return utils.SuccessResponse(c, "Webhook subscription updated successfully", response)
+}
+
+// @Summary Delete webhook subscription
+// @Description Stop and remove a webhook subscription. Deliveries already queued are dropped.
+// @Tags auth
+// @Security BearerAuth
+// @Param id path string true "Webhook subscription ID"
+// @Success 200 {object} utils.Response
+// @Failure 404 {object} utils.Problem
+// @Router /auth/webhooks/{id} [delete]
+func (h *AuthHandler) DeleteWebhook(c *fiber.Ctx) error {
+	subscription, err := ownWebhook(c)
+	if err != nil {
+		return utils.NotFoundResponse(c, "Webhook subscription not found")
+	}
+
+	if err := database.DB.Delete(subscription).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to delete webhook subscription", err)
+	}
+
+	return utils.SuccessResponse(c, "Webhook subscription deleted successfully", nil)
+}
+
+// ownWebhook loads the subscription in the route belonging to the current user
+func ownWebhook(c *fiber.Ctx) (*models.WebhookSubscription, error) {
+	subscriptionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, err
+	}
+	userID, _ := c.Locals("user_id").(uuid.UUID)
+
+	var subscription models.WebhookSubscription
+	if err := database.DB.Where("id = ? AND owner_id = ?", subscriptionID, userID).First(&subscription).Error; err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// validateWebhook returns what is wrong with a subscription's settings, or
+// an empty string when they are valid
+func validateWebhook(endpoint string, eventTypes []string, filter string, payloadVersion int) string {
+	if err := webhooks.ValidateURL(endpoint); errors.Is(err, webhooks.ErrBlockedTarget) {
+		return "URL must point to a public host"
+	} else if err != nil {
+		return err.Error()
+	}
+	for _, eventType := range eventTypes {
+		if !webhooks.IsEventType(eventType) {
+			return "Unknown event type " + eventType + ", expected one of " + strings.Join(webhooks.EventTypes, ", ")
+		}
+	}
+	if strings.TrimSpace(filter) != "" {
+		if _, err := webhooks.CompileFilter(filter); err != nil {
+			return "Invalid filter: " + err.Error()
+		}
+	}
+	if !webhooks.IsPayloadVersion(payloadVersion) {
+		return "Unsupported payload version"
+	}
+	return ""
+}
+
+func generateWebhookSecret() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
@@ -7,8 +7,11 @@ import (
 	"playful-marketplace/services/auth/routes"
 	"playful-marketplace/shared/config"
 	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/jobs"
 	"playful-marketplace/shared/middleware"
 	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/regions"
+	"playful-marketplace/shared/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/recover"
@@ -33,34 +36,39 @@ func main() {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
+	// Seed default admin user
+	if err := database.SeedAdmin(cfg); err != nil {
+		log.Fatal("Failed to seed admin user:", err)
+	}
+
 	// Connect to Redis
 	if err := redis.Connect(cfg); err != nil {
 		log.Fatal("Failed to connect to Redis:", err)
 	}
 
+	// Load region configuration
+	regions.Init(cfg)
+
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
-		AppName: "Playful Marketplace Auth Service",
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			code := fiber.StatusInternalServerError
-			if e, ok := err.(*fiber.Error); ok {
-				code = e.Code
-			}
-			return c.Status(code).JSON(fiber.Map{
-				"success": false,
-				"message": "Internal Server Error",
-				"error":   err.Error(),
-			})
-		},
+		AppName:      "Playful Marketplace Auth Service",
+		ErrorHandler: utils.FiberErrorHandler,
 	})
 
 	// Middleware
+	app.Use(middleware.TraceMiddleware())
 	app.Use(recover.New())
 	app.Use(middleware.CORSMiddleware())
 	app.Use(middleware.LoggingMiddleware())
+	app.Use(middleware.FaultMiddleware(cfg))
+	app.Use(middleware.MaintenanceMiddleware(cfg, "auth"))
+	app.Use(middleware.RateLimitMiddleware(cfg))
+	app.Use(middleware.UsageMiddleware())
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(cfg)
+	authHandler.RegisterJobs()
+	jobs.Start(cfg.Jobs)
 
 	// Health check
 	app.Get("/health", func(c *fiber.Ctx) error {
@@ -4,6 +4,7 @@ import (
 	"playful-marketplace/services/auth/handlers"
 	"playful-marketplace/shared/config"
 	"playful-marketplace/shared/middleware"
+	"playful-marketplace/shared/models"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -15,9 +16,95 @@ func SetupAuthRoutes(api fiber.Router, authHandler *handlers.AuthHandler, cfg *c
 	auth.Post("/signup", authHandler.Signup)
 	auth.Post("/request-otp", authHandler.RequestOTP)
 	auth.Post("/login", authHandler.Login)
+	auth.Post("/guest", authHandler.CreateGuestSession)
+	auth.Post("/social/:provider", authHandler.SocialLogin)
+
+	// Recovery for accounts whose phone number was lost
+	auth.Post("/recovery", authHandler.StartAccountRecovery)
+	auth.Post("/recovery/:id", authHandler.SubmitAccountRecovery)
+	auth.Get("/recovery/:id", authHandler.GetAccountRecovery)
+
+	// Token introspection for other services, authenticated by API key
+	auth.Post("/introspect", middleware.APIKeyMiddleware("tokens"), middleware.PermissionMiddleware(middleware.PermTokensWrite), authHandler.IntrospectToken)
 
 	// Protected routes
 	protected := auth.Group("", middleware.AuthMiddleware(cfg))
 	protected.Post("/logout", authHandler.Logout)
+	protected.Post("/logout-all", authHandler.LogoutAll)
 	protected.Get("/verify", authHandler.VerifyToken)
+	protected.Post("/refresh", authHandler.RefreshToken)
+	protected.Get("/login-history", authHandler.GetLoginHistory)
+
+	// Phone number change with re-verification
+	protected.Post("/phone/change", authHandler.RequestPhoneChange)
+	protected.Post("/phone/confirm", authHandler.ConfirmPhoneChange)
+	protected.Post("/guest/convert", middleware.RoleMiddleware(models.RoleGuest), authHandler.ConvertGuest)
+
+	// Accounts holding both buyer and seller profiles switch between them
+	protected.Post("/profile", authHandler.SwitchProfile)
+
+	// Buyer to seller upgrade
+	protected.Post("/upgrade-to-seller", middleware.RoleMiddleware(models.RoleBuyer), authHandler.ApplyForSeller)
+	protected.Get("/upgrade-to-seller", authHandler.GetSellerApplication)
+
+	// Refer-a-seller program
+	protected.Get("/referrals", middleware.RoleMiddleware(models.RoleSeller), authHandler.GetSellerReferrals)
+
+	// Store staff: sellers invite and manage staff, staff accept and get store tokens
+	sellerOnly := middleware.RoleMiddleware(models.RoleSeller)
+	protected.Post("/staff", sellerOnly, authHandler.InviteStaff)
+	protected.Get("/staff", sellerOnly, authHandler.GetStaff)
+	protected.Get("/staff/invitations", authHandler.GetStaffInvitations)
+	protected.Post("/staff/invitations/:id/:action", authHandler.RespondToStaffInvitation)
+	protected.Put("/staff/:id", sellerOnly, authHandler.UpdateStaff)
+	protected.Delete("/staff/:id", sellerOnly, authHandler.RemoveStaff)
+	protected.Get("/stores", authHandler.GetStaffStores)
+	protected.Post("/stores/:seller_id/token", authHandler.CreateStoreToken)
+
+	// Linked Google and Apple identities
+	protected.Get("/identities", authHandler.GetIdentities)
+	protected.Post("/identities/:provider", authHandler.LinkIdentity)
+	protected.Delete("/identities/:id", authHandler.UnlinkIdentity)
+
+	// Trusted device management
+	protected.Get("/devices", authHandler.GetDevices)
+	protected.Put("/devices/:id", authHandler.UpdateDevice)
+	protected.Delete("/devices/:id", authHandler.DeleteDevice)
+
+	// Two-factor authentication
+	protected.Post("/2fa/enroll", middleware.RoleMiddleware(models.RoleSeller, models.RoleAdmin), authHandler.EnrollTwoFactor)
+	protected.Post("/2fa/activate", authHandler.ActivateTwoFactor)
+	protected.Post("/2fa/disable", authHandler.DisableTwoFactor)
+
+	// API keys for server-to-server integrations
+	protected.Post("/api-keys", authHandler.CreateAPIKey)
+	protected.Get("/api-keys", authHandler.GetAPIKeys)
+	protected.Delete("/api-keys/:id", authHandler.RevokeAPIKey)
+
+	// Outbound webhooks for sellers' order and payment events
+	webhooks := protected.Group("/webhooks", middleware.RoleMiddleware(models.RoleSeller))
+	webhooks.Post("/", authHandler.CreateWebhook)
+	webhooks.Get("/", authHandler.GetWebhooks)
+	webhooks.Put("/:id", authHandler.UpdateWebhook)
+	webhooks.Delete("/:id", authHandler.DeleteWebhook)
+
+	// Least-privilege tokens for the mobile SDK and partner integrations
+	protected.Post("/tokens", authHandler.CreateScopedToken)
+
+	// Maintenance mode, registered ahead of /admin so only its own permission applies
+	maintenance := api.Group("/admin/maintenance", middleware.AuthMiddleware(cfg), middleware.PermissionMiddleware(middleware.PermMaintenance))
+	maintenance.Get("/", authHandler.GetMaintenance)
+	maintenance.Put("/", authHandler.SetMaintenance)
+
+	// Admin login audit trail and seller applications
+	admin := api.Group("/admin", middleware.AuthMiddleware(cfg), middleware.PermissionMiddleware(middleware.PermUsersRead))
+	admin.Get("/login-events", authHandler.GetLoginEvents)
+
+	// Seller application review
+	admin.Get("/seller-applications", authHandler.GetSellerApplications)
+	admin.Post("/seller-applications/:id", middleware.PermissionMiddleware(middleware.PermUsersWrite), authHandler.ReviewSellerApplication)
+
+	// Admin-assisted account recovery
+	admin.Get("/account-recoveries", authHandler.GetAccountRecoveries)
+	admin.Post("/account-recoveries/:id", middleware.PermissionMiddleware(middleware.PermUsersWrite), authHandler.ReviewAccountRecovery)
 }
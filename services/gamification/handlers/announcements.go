@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/jobs"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/segments"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const jobPushAnnouncement = "gamification.push_announcement"
+
+type announcementJob struct {
+	AnnouncementID uuid.UUID `json:"announcement_id"`
+}
+
+type AnnouncementRequest struct {
+	Title    string                       `json:"title"`
+	Body     string                       `json:"body"`
+	Kind     *models.AnnouncementKind     `json:"kind"`     // campaign or notice
+	Audience *models.AnnouncementAudience `json:"audience"` // everyone, buyers or sellers
+	Link     *string                      `json:"link"`
+	StartsAt *time.Time                   `json:"starts_at"` // Defaults to now
+	EndsAt   *time.Time                   `json:"ends_at"`
+	Push     *bool                        `json:"push"` // Also send as a notification when it starts
+	IsActive *bool                        `json:"is_active"`
+}
+
+// @Summary Get announcements
+// @Description Get the banners and notices currently running for the caller's audience. Works without signing in, showing announcements for everyone.
+// @Tags announcements
+// @Success 200 {object} utils.Response{data=[]models.Announcement}
+// @Router /announcements [get]
+func (h *GamificationHandler) GetAnnouncements(c *fiber.Ctx) error {
+	audiences := []models.AnnouncementAudience{models.AudienceEveryone}
+	switch role, _ := c.Locals("user_role").(models.UserRole); role {
+	case models.RoleBuyer:
+		audiences = append(audiences, models.AudienceBuyers)
+	case models.RoleSeller:
+		audiences = append(audiences, models.AudienceSellers)
+	case models.RoleAdmin:
+		audiences = append(audiences, models.AudienceBuyers, models.AudienceSellers)
+	}
+
+	now := time.Now()
+	var announcements []models.Announcement
+	if err := database.DB.Where("is_active = ? AND audience IN ? AND starts_at <= ?", true, audiences, now).
+		Where("ends_at IS NULL OR ends_at > ?", now).
+		Order("starts_at DESC").
+		Find(&announcements).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get announcements", err)
+	}
+
+	return utils.SuccessResponse(c, "Announcements retrieved successfully", announcements)
+}
+
+// @Summary Create announcement
+// @Description Schedule a banner or notice for an audience, optionally pushed as a notification when it starts (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param request body AnnouncementRequest true "Announcement"
+// @Success 201 {object} utils.Response{data=models.Announcement}
+// @Failure 400 {object} utils.Problem
+// @Router /admin/announcements [post]
+func (h *GamificationHandler) CreateAnnouncement(c *fiber.Ctx) error {
+	var req AnnouncementRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.Title == "" || req.Body == "" {
+		return utils.ValidationErrorResponse(c, "Title and body are required")
+	}
+
+	announcement := models.Announcement{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		Kind:      models.AnnouncementNotice,
+		Audience:  models.AudienceEveryone,
+		StartsAt:  time.Now(),
+		IsActive:  true,
+	}
+	if err := applyAnnouncementRequest(&announcement, &req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	if err := database.DB.Create(&announcement).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create announcement", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Announcement created successfully",
+		Data:    announcement,
+	})
+}
+
+// @Summary Get all announcements
+// @Description List announcements including scheduled, ended and inactive ones (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=[]models.Announcement}
+// @Router /admin/announcements [get]
+func (h *GamificationHandler) GetAllAnnouncements(c *fiber.Ctx) error {
+	var announcements []models.Announcement
+	if err := database.DB.Order("starts_at DESC").Find(&announcements).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get announcements", err)
+	}
+
+	return utils.SuccessResponse(c, "Announcements retrieved successfully", announcements)
+}
+
+// @Summary Update announcement
+// @Description Edit, reschedule or deactivate an announcement (admin only). Announcements already pushed aren't pushed again.
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Announcement ID"
+// @Param request body AnnouncementRequest true "Announcement fields to update"
+// @Success 200 {object} utils.Response{data=models.Announcement}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /admin/announcements/{id} [put]
+func (h *GamificationHandler) UpdateAnnouncement(c *fiber.Ctx) error {
+	announcementID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid announcement ID")
+	}
+
+	var req AnnouncementRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	var announcement models.Announcement
+	if err := database.DB.First(&announcement, announcementID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Announcement not found")
+	}
+
+	if err := applyAnnouncementRequest(&announcement, &req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	if err := database.DB.Save(&announcement).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update announcement", err)
+	}
+
+	return utils.SuccessResponse(c, "Announcement updated successfully", announcement)
+}
+
+// PushAnnouncements queues a notification push for each announcement that
+// has started and asked to be pushed. Run periodically by the scheduler.
+func (h *GamificationHandler) PushAnnouncements() {
+	now := time.Now()
+	var announcements []models.Announcement
+	if err := database.DB.Where("is_active = ? AND push = ? AND pushed_at IS NULL AND starts_at <= ?", true, true, now).
+		Where("ends_at IS NULL OR ends_at > ?", now).
+		Find(&announcements).Error; err != nil {
+		log.Printf("Failed to load announcements: %v", err)
+		return
+	}
+
+	for _, announcement := range announcements {
+		// Claim it so another instance or a later tick doesn't push it twice
+		result := database.DB.Model(&models.Announcement{}).
+			Where("id = ? AND pushed_at IS NULL", announcement.ID).
+			Update("pushed_at", now)
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+		if err := jobs.Enqueue(jobPushAnnouncement, announcementJob{AnnouncementID: announcement.ID}); err != nil {
+			log.Printf("Failed to queue announcement %s: %v", announcement.ID, err)
+		}
+	}
+}
+
+// Helper functions
+
+func (h *GamificationHandler) pushAnnouncement(payload []byte) error {
+	var job announcementJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+
+	var announcement models.Announcement
+	if err := database.DB.First(&announcement, job.AnnouncementID).Error; err != nil {
+		return err
+	}
+	if !announcement.IsActive {
+		return nil
+	}
+
+	// Promotions respect marketing preferences, notices go to everyone
+	notificationType := models.NotificationAnnouncement
+	if announcement.Kind == models.AnnouncementCampaign {
+		notificationType = models.NotificationMarketing
+	}
+
+	sent := 0
+	var batch []models.User
+	err := database.DB.Scopes(segments.Audience(announcement.Audience)).Select("id").
+		FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+			for _, user := range batch {
+				if err := notifications.SendWithLink(user.ID, notificationType, announcement.Title, announcement.Body, announcement.Link); err != nil {
+					log.Printf("Failed to send announcement %s to user %s: %v", announcement.ID, user.ID, err)
+					continue
+				}
+				sent++
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Announcement %q pushed to %d user(s)", announcement.Title, sent)
+	return nil
+}
+
+func applyAnnouncementRequest(announcement *models.Announcement, req *AnnouncementRequest) error {
+	if req.Title != "" {
+		announcement.Title = req.Title
+	}
+	if req.Body != "" {
+		announcement.Body = req.Body
+	}
+	if req.Kind != nil {
+		if *req.Kind != models.AnnouncementCampaign && *req.Kind != models.AnnouncementNotice {
+			return fmt.Errorf("kind must be campaign or notice")
+		}
+		announcement.Kind = *req.Kind
+	}
+	if req.Audience != nil {
+		switch *req.Audience {
+		case models.AudienceEveryone, models.AudienceBuyers, models.AudienceSellers:
+			announcement.Audience = *req.Audience
+		default:
+			return fmt.Errorf("audience must be everyone, buyers or sellers")
+		}
+	}
+	if req.Link != nil {
+		announcement.Link = *req.Link
+	}
+	if req.StartsAt != nil {
+		announcement.StartsAt = *req.StartsAt
+	}
+	if req.EndsAt != nil {
+		announcement.EndsAt = req.EndsAt
+	}
+	if announcement.EndsAt != nil && !announcement.EndsAt.After(announcement.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+	if req.Push != nil {
+		announcement.Push = *req.Push
+	}
+	if req.IsActive != nil {
+		announcement.IsActive = *req.IsActive
+	}
+	return nil
+}
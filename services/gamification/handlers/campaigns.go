@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"playful-marketplace/shared/coupons"
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/segments"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CampaignRequest struct {
+	Name            string   `json:"name"`
+	InactiveDays    *int     `json:"inactive_days"`
+	Title           string   `json:"title"`
+	Message         string   `json:"message"`
+	BonusXP         *int     `json:"bonus_xp"`
+	CouponPercent   *float64 `json:"coupon_percent"`
+	CouponValidDays *int     `json:"coupon_valid_days"`
+	MaxSendsPerUser *int     `json:"max_sends_per_user"`
+	CooldownDays    *int     `json:"cooldown_days"`
+	DailySendLimit  *int     `json:"daily_send_limit"`
+	IsActive        *bool    `json:"is_active"`
+}
+
+// @Summary Create re-engagement campaign
+// @Description Create a campaign that messages users inactive for a number of days, optionally with bonus XP or a coupon (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param request body CampaignRequest true "Campaign"
+// @Success 201 {object} utils.Response{data=models.Campaign}
+// @Failure 400 {object} utils.Problem
+// @Router /admin/campaigns [post]
+func (h *GamificationHandler) CreateCampaign(c *fiber.Ctx) error {
+	var req CampaignRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.Name == "" || req.Title == "" || req.Message == "" || req.InactiveDays == nil {
+		return utils.ValidationErrorResponse(c, "Name, title, message and inactive_days are required")
+	}
+
+	campaign := models.Campaign{
+		BaseModel:       models.BaseModel{ID: uuid.New()},
+		CouponValidDays: 14,
+		MaxSendsPerUser: 1,
+		CooldownDays:    30,
+		DailySendLimit:  1000,
+		IsActive:        true,
+	}
+	if err := applyCampaignRequest(&campaign, &req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	if err := database.DB.Create(&campaign).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create campaign", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Campaign created successfully",
+		Data:    campaign,
+	})
+}
+
+// @Summary Get campaigns
+// @Description List re-engagement campaigns (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=[]models.Campaign}
+// @Router /admin/campaigns [get]
+func (h *GamificationHandler) GetCampaigns(c *fiber.Ctx) error {
+	var campaigns []models.Campaign
+	if err := database.DB.Order("created_at DESC").Find(&campaigns).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get campaigns", err)
+	}
+
+	return utils.SuccessResponse(c, "Campaigns retrieved successfully", campaigns)
+}
+
+// @Summary Update campaign
+// @Description Update or pause a re-engagement campaign (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Campaign ID"
+// @Param request body CampaignRequest true "Campaign fields to update"
+// @Success 200 {object} utils.Response{data=models.Campaign}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /admin/campaigns/{id} [put]
+func (h *GamificationHandler) UpdateCampaign(c *fiber.Ctx) error {
+	campaignID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid campaign ID")
+	}
+
+	var req CampaignRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	var campaign models.Campaign
+	if err := database.DB.First(&campaign, campaignID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Campaign not found")
+	}
+
+	if err := applyCampaignRequest(&campaign, &req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	if err := database.DB.Save(&campaign).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update campaign", err)
+	}
+
+	return utils.SuccessResponse(c, "Campaign updated successfully", campaign)
+}
+
+// RunCampaigns sends every active campaign to its eligible dormant users.
+// Run periodically by the scheduler.
+func (h *GamificationHandler) RunCampaigns() {
+	var campaigns []models.Campaign
+	if err := database.DB.Where("is_active = ?", true).Find(&campaigns).Error; err != nil {
+		log.Printf("Failed to load campaigns: %v", err)
+		return
+	}
+
+	for i := range campaigns {
+		sent := h.runCampaign(&campaigns[i])
+		if sent > 0 {
+			log.Printf("Campaign %q sent to %d user(s)", campaigns[i].Name, sent)
+		}
+	}
+}
+
+// Helper functions
+
+func (h *GamificationHandler) runCampaign(campaign *models.Campaign) int {
+	now := time.Now()
+	startOfDay := now.Truncate(24 * time.Hour)
+
+	// Respect the campaign's daily send cap
+	var sentToday int64
+	database.DB.Model(&models.CampaignSend{}).
+		Where("campaign_id = ? AND created_at >= ?", campaign.ID, startOfDay).
+		Count(&sentToday)
+	remaining := campaign.DailySendLimit - int(sentToday)
+	if remaining <= 0 {
+		return 0
+	}
+
+	var users []models.User
+	if err := database.DB.Scopes(segments.Dormant(campaign.InactiveDays)).
+		Where("marketing_opt_out = ?", false).
+		Where("id NOT IN (SELECT user_id FROM campaign_sends WHERE campaign_id = ? AND deleted_at IS NULL GROUP BY user_id HAVING COUNT(*) >= ?)",
+			campaign.ID, campaign.MaxSendsPerUser).
+		Where("id NOT IN (SELECT user_id FROM campaign_sends WHERE campaign_id = ? AND deleted_at IS NULL AND created_at > ?)",
+			campaign.ID, now.AddDate(0, 0, -campaign.CooldownDays)).
+		Limit(remaining).
+		Find(&users).Error; err != nil {
+		log.Printf("Failed to select users for campaign %s: %v", campaign.ID, err)
+		return 0
+	}
+
+	sent := 0
+	for i := range users {
+		if err := h.sendCampaign(campaign, &users[i]); err != nil {
+			log.Printf("Failed to send campaign %s to user %s: %v", campaign.ID, users[i].ID, err)
+			continue
+		}
+		sent++
+	}
+
+	database.DB.Model(campaign).Update("last_run_at", now)
+	return sent
+}
+
+func (h *GamificationHandler) sendCampaign(campaign *models.Campaign, user *models.User) error {
+	send := models.CampaignSend{
+		BaseModel:  models.BaseModel{ID: uuid.New()},
+		CampaignID: campaign.ID,
+		UserID:     user.ID,
+	}
+	message := campaign.Message
+
+	if campaign.CouponPercent > 0 {
+		coupon, err := coupons.Issue(user.ID, campaign.CouponPercent, campaign.CouponValidDays, "campaign")
+		if err != nil {
+			return err
+		}
+		send.CouponID = &coupon.ID
+		message += fmt.Sprintf(" Use code %s for %.0f%% off your next order.", coupon.Code, campaign.CouponPercent)
+	}
+
+	if campaign.BonusXP > 0 {
+		xpTransaction := models.XPTransaction{
+			BaseModel: models.BaseModel{ID: uuid.New()},
+			UserID:    user.ID,
+			Amount:    campaign.BonusXP,
+			Reason:    "Welcome back bonus",
+			Reference: campaign.ID.String(),
+		}
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&xpTransaction).Error; err != nil {
+				return err
+			}
+			return tx.Model(&models.User{}).Where("id = ?", user.ID).
+				Update("total_xp", gorm.Expr("total_xp + ?", campaign.BonusXP)).Error
+		})
+		if err != nil {
+			return err
+		}
+		send.BonusXP = campaign.BonusXP
+		message += fmt.Sprintf(" We've added %d bonus XP to your account.", campaign.BonusXP)
+	}
+
+	if err := database.DB.Create(&send).Error; err != nil {
+		return err
+	}
+
+	return notifications.Send(user.ID, models.NotificationMarketing, campaign.Title, message)
+}
+
+func applyCampaignRequest(campaign *models.Campaign, req *CampaignRequest) error {
+	if req.Name != "" {
+		campaign.Name = req.Name
+	}
+	if req.Title != "" {
+		campaign.Title = req.Title
+	}
+	if req.Message != "" {
+		campaign.Message = req.Message
+	}
+	if req.InactiveDays != nil {
+		if *req.InactiveDays < 1 {
+			return fmt.Errorf("inactive_days must be at least 1")
+		}
+		campaign.InactiveDays = *req.InactiveDays
+	}
+	if req.BonusXP != nil {
+		if *req.BonusXP < 0 {
+			return fmt.Errorf("bonus_xp cannot be negative")
+		}
+		campaign.BonusXP = *req.BonusXP
+	}
+	if req.CouponPercent != nil {
+		if *req.CouponPercent < 0 || *req.CouponPercent > 100 {
+			return fmt.Errorf("coupon_percent must be between 0 and 100")
+		}
+		campaign.CouponPercent = *req.CouponPercent
+	}
+	if req.CouponValidDays != nil {
+		if *req.CouponValidDays < 1 {
+			return fmt.Errorf("coupon_valid_days must be at least 1")
+		}
+		campaign.CouponValidDays = *req.CouponValidDays
+	}
+	if req.MaxSendsPerUser != nil {
+		if *req.MaxSendsPerUser < 1 {
+			return fmt.Errorf("max_sends_per_user must be at least 1")
+		}
+		campaign.MaxSendsPerUser = *req.MaxSendsPerUser
+	}
+	if req.CooldownDays != nil {
+		if *req.CooldownDays < 0 {
+			return fmt.Errorf("cooldown_days cannot be negative")
+		}
+		campaign.CooldownDays = *req.CooldownDays
+	}
+	if req.DailySendLimit != nil {
+		if *req.DailySendLimit < 1 {
+			return fmt.Errorf("daily_send_limit must be at least 1")
+		}
+		campaign.DailySendLimit = *req.DailySendLimit
+	}
+	if req.IsActive != nil {
+		campaign.IsActive = *req.IsActive
+	}
+	return nil
+}
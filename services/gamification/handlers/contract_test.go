@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"testing"
+
+	"playful-marketplace/shared/contracts/contracttest"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestOrderContract(t *testing.T) {
+	pact := contracttest.Load(t, "order", "gamification")
+
+	for _, description := range []string{
+		"award XP for a delivered order",
+		"award XP without a reference",
+	} {
+		var req AddXPRequest
+		pact.Interaction(t, description).DecodeRequest(t, &req)
+		if err := req.Validate(); err != nil {
+			t.Errorf("%s: request rejected: %v", description, err)
+		}
+	}
+
+	// Requests the gamification service rejects before looking the user up
+	h := NewGamificationHandler(nil)
+	app := fiber.New()
+	app.Post("/gamify/xp", h.AddXP)
+
+	pact.Interaction(t, "reject an award without a reason").Replay(t, app)
+}
@@ -1,13 +1,20 @@
 package handlers
 
 import (
+	"playful-marketplace/shared/activity"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"playful-marketplace/shared/config"
+	"playful-marketplace/shared/contracts"
 	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/jobs"
 	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
 	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/users"
 	"playful-marketplace/shared/utils"
 
 	"github.com/gofiber/fiber/v2"
@@ -18,12 +25,7 @@ type GamificationHandler struct {
 	config *config.Config
 }
 
-type AddXPRequest struct {
-	UserID    uuid.UUID `json:"user_id" validate:"required"`
-	Amount    int       `json:"amount" validate:"required"`
-	Reason    string    `json:"reason" validate:"required"`
-	Reference string    `json:"reference"`
-}
+type AddXPRequest = contracts.AwardXPRequest
 
 type CheckBadgesRequest struct {
 	UserID uuid.UUID `json:"user_id" validate:"required"`
@@ -35,19 +37,33 @@ type LeaderboardResponse struct {
 	Entries []models.LeaderboardEntry   `json:"entries"`
 }
 
+// Background job kinds
+const jobUpdateLeaderboards = "gamification.update_leaderboards"
+
+type leaderboardJob struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
 func NewGamificationHandler(cfg *config.Config) *GamificationHandler {
 	return &GamificationHandler{
 		config: cfg,
 	}
 }
 
+// RegisterJobs registers the handlers for the jobs this service enqueues
+func (h *GamificationHandler) RegisterJobs() {
+	jobs.Handle(jobUpdateLeaderboards, h.updateLeaderboards)
+	jobs.Handle(jobPushAnnouncement, h.pushAnnouncement)
+	jobs.Handle(jobMaterializeSegment, h.materializeSegment)
+}
+
 // @Summary Add XP to user
 // @Description Award XP points to a user for completing actions
 // @Tags gamification
 // @Security BearerAuth
 // @Param request body AddXPRequest true "Add XP request"
 // @Success 200 {object} utils.Response{data=map[string]interface{}}
-// @Failure 400 {object} utils.Response
+// @Failure 400 {object} utils.Problem
 // @Router /gamify/xp [post]
 func (h *GamificationHandler) AddXP(c *fiber.Ctx) error {
 	var req AddXPRequest
@@ -55,7 +71,7 @@ func (h *GamificationHandler) AddXP(c *fiber.Ctx) error {
 		return utils.ValidationErrorResponse(c, "Invalid request body")
 	}
 
-	if req.UserID == uuid.Nil || req.Amount == 0 || req.Reason == "" {
+	if err := req.Validate(); err != nil {
 		return utils.ValidationErrorResponse(c, "User ID, amount, and reason are required")
 	}
 
@@ -93,10 +109,12 @@ func (h *GamificationHandler) AddXP(c *fiber.Ctx) error {
 
 	if leveledUp {
 		database.DB.Model(&user).Update("level", newLevel)
+		users.Invalidate(user.ID)
+		activity.LevelUp(user.ID, newLevel)
 	}
 
 	// Update leaderboards
-	go h.updateLeaderboards(&user, newXP)
+	jobs.Enqueue(jobUpdateLeaderboards, leaderboardJob{UserID: user.ID})
 
 	response := map[string]interface{}{
 		"user_id":     req.UserID,
@@ -118,7 +136,7 @@ func (h *GamificationHandler) AddXP(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Param userId path string true "User ID"
 // @Success 200 {object} utils.Response{data=map[string]interface{}}
-// @Failure 404 {object} utils.Response
+// @Failure 404 {object} utils.Problem
 // @Router /gamify/xp/{userId} [get]
 func (h *GamificationHandler) GetUserXP(c *fiber.Ctx) error {
 	userIDParam := c.Params("userId")
@@ -152,7 +170,7 @@ func (h *GamificationHandler) GetUserXP(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Param userId path string true "User ID"
 // @Success 200 {object} utils.Response{data=[]models.UserBadge}
-// @Failure 404 {object} utils.Response
+// @Failure 404 {object} utils.Problem
 // @Router /gamify/badges/{userId} [get]
 func (h *GamificationHandler) GetUserBadges(c *fiber.Ctx) error {
 	userIDParam := c.Params("userId")
@@ -182,7 +200,7 @@ func (h *GamificationHandler) GetUserBadges(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Param request body CheckBadgesRequest true "Check badges request"
 // @Success 200 {object} utils.Response{data=[]models.UserBadge}
-// @Failure 400 {object} utils.Response
+// @Failure 400 {object} utils.Problem
 // @Router /gamify/badges/check [post]
 func (h *GamificationHandler) CheckAndAwardBadges(c *fiber.Ctx) error {
 	var req CheckBadgesRequest
@@ -211,7 +229,7 @@ func (h *GamificationHandler) CheckAndAwardBadges(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Param userId path string true "User ID"
 // @Success 200 {object} utils.Response{data=map[string]interface{}}
-// @Failure 404 {object} utils.Response
+// @Failure 404 {object} utils.Problem
 // @Router /gamify/level/{userId} [get]
 func (h *GamificationHandler) GetUserLevel(c *fiber.Ctx) error {
 	userIDParam := c.Params("userId")
@@ -243,7 +261,7 @@ func (h *GamificationHandler) GetUserLevel(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Param userId path string true "User ID"
 // @Success 200 {object} utils.Response{data=map[string]interface{}}
-// @Failure 404 {object} utils.Response
+// @Failure 404 {object} utils.Problem
 // @Router /gamify/level/update/{userId} [post]
 func (h *GamificationHandler) UpdateUserLevel(c *fiber.Ctx) error {
 	userIDParam := c.Params("userId")
@@ -265,6 +283,7 @@ func (h *GamificationHandler) UpdateUserLevel(c *fiber.Ctx) error {
 		if err := database.DB.Model(&user).Update("level", newLevel).Error; err != nil {
 			return utils.InternalServerErrorResponse(c, "Failed to update user level", err)
 		}
+		users.Invalidate(user.ID)
 	}
 
 	response := map[string]interface{}{
@@ -296,6 +315,7 @@ func (h *GamificationHandler) GetBuyerLeaderboard(c *fiber.Ctx) error {
 		// Fallback to database if Redis fails
 		entries = h.generateBuyerLeaderboardFromDB(limit)
 	}
+	hydrateLeaderboard(entries)
 
 	response := LeaderboardResponse{
 		Type:    "buyers",
@@ -324,6 +344,7 @@ func (h *GamificationHandler) GetSellerLeaderboard(c *fiber.Ctx) error {
 		// Fallback to database if Redis fails
 		entries = h.generateSellerLeaderboardFromDB(limit)
 	}
+	hydrateLeaderboard(entries)
 
 	response := LeaderboardResponse{
 		Type:    "sellers",
@@ -452,8 +473,9 @@ func (h *GamificationHandler) checkAndAwardBadges(user *models.User) []models.Us
 			shouldAward = userCount <= 100
 
 		case models.BadgeReviewer:
-			// This would require a reviews table - placeholder for now
-			shouldAward = false
+			var reviewCount int64
+			database.DB.Model(&models.Review{}).Where("buyer_id = ?", user.ID).Count(&reviewCount)
+			shouldAward = reviewCount >= 10
 
 		case models.BadgeReferrer:
 			// This would require a referrals table - placeholder for now
@@ -469,6 +491,8 @@ func (h *GamificationHandler) checkAndAwardBadges(user *models.User) []models.Us
 			}
 
 			if err := database.DB.Create(&userBadge).Error; err == nil {
+				users.Invalidate(user.ID)
+				activity.BadgeEarned(user.ID, &badge)
 				// Award XP for badge
 				if badge.XPReward > 0 {
 					h.awardXP(user.ID, badge.XPReward, fmt.Sprintf("Badge: %s", badge.Name))
@@ -492,23 +516,70 @@ func (h *GamificationHandler) awardXP(userID uuid.UUID, amount int, reason strin
 	database.DB.Model(&models.User{}).Where("id = ?", userID).Update("total_xp", database.DB.Raw("total_xp + ?", amount))
 }
 
-func (h *GamificationHandler) updateLeaderboards(user *models.User, newXP int) {
-	userData := map[string]interface{}{
-		"name":        user.Name,
-		"level":       user.Level,
-		"badge_count": 0, // This would be calculated
+func (h *GamificationHandler) updateLeaderboards(payload []byte) error {
+	var job leaderboardJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, job.UserID).Error; err != nil {
+		return err
+	}
+
+	// Accounts with both profiles rank on both leaderboards
+	for _, board := range leaderboards {
+		if !user.HasRole(board.Role) {
+			continue
+		}
+		if err := updateLeaderboard(&user, board.Key, board.Score(&user)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateLeaderboard sets the user's score, with any admin adjustment, and
+// tells them when their rank moves. Users an admin removed stay off.
+func updateLeaderboard(user *models.User, leaderboard string, score float64) error {
+	if redis.IsLeaderboardExcluded(leaderboard, user.ID.String()) {
+		return nil
+	}
+	score += redis.GetLeaderboardOffset(leaderboard, user.ID.String())
+
+	previousRank := redis.GetLeaderboardRank(leaderboard, user.ID.String())
+	if err := redis.SetLeaderboardEntry(leaderboard, user.ID.String(), score); err != nil {
+		return err
+	}
+
+	// Position changes are non-urgent, so they are batched into the digest
+	rank := redis.GetLeaderboardRank(leaderboard, user.ID.String())
+	if rank > 0 && rank != previousRank {
+		notifications.Send(user.ID, models.NotificationLeaderboard, "Leaderboard position changed",
+			fmt.Sprintf("You're now #%d on the %s leaderboard.", rank, strings.ReplaceAll(leaderboard, "_", " ")))
+	}
+	return nil
+}
+
+// hydrateLeaderboard fills in each entry's name, level and badge count
+func hydrateLeaderboard(entries []models.LeaderboardEntry) {
+	userIDs := make([]uuid.UUID, len(entries))
+	for i, entry := range entries {
+		userIDs[i] = entry.UserID
 	}
 
-	if user.Role == models.RoleBuyer {
-		redis.SetLeaderboardEntry("weekly_buyers", user.ID.String(), user.TotalSpent, userData)
-	} else if user.Role == models.RoleSeller {
-		redis.SetLeaderboardEntry("monthly_sellers", user.ID.String(), user.TotalSales, userData)
+	summaries := users.Summaries(userIDs)
+	for i := range entries {
+		summary := summaries[entries[i].UserID]
+		entries[i].Name = summary.Name
+		entries[i].Level = summary.Level
+		entries[i].BadgeCount = summary.BadgeCount
 	}
 }
 
 func (h *GamificationHandler) generateBuyerLeaderboardFromDB(limit int) []models.LeaderboardEntry {
 	var users []models.User
-	database.DB.Where("role = ?", models.RoleBuyer).
+	database.DB.Where("? = ANY(roles)", models.RoleBuyer).
 		Order("total_spent DESC").
 		Limit(limit).
 		Find(&users)
@@ -529,7 +600,7 @@ func (h *GamificationHandler) generateBuyerLeaderboardFromDB(limit int) []models
 
 func (h *GamificationHandler) generateSellerLeaderboardFromDB(limit int) []models.LeaderboardEntry {
 	var users []models.User
-	database.DB.Where("role = ?", models.RoleSeller).
+	database.DB.Where("? = ANY(roles)", models.RoleSeller).
 		Order("total_sales DESC").
 		Limit(limit).
 		Find(&users)
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/jobs"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type JobStat struct {
+	Kind   string           `json:"kind"`
+	Status models.JobStatus `json:"status"`
+	Count  int64            `json:"count"`
+}
+
+// @Summary Get background jobs
+// @Description List background jobs across services, newest first, e.g. status=failed to see what ran out of retries (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param status query string false "pending, running, completed or failed"
+// @Param kind query string false "Job kind, e.g. payment.award_xp"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} utils.Response{data=[]models.Job}
+// @Router /admin/jobs [get]
+func (h *GamificationHandler) GetJobs(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := database.DB.Model(&models.Job{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	var jobList []models.Job
+	if err := query.Order("created_at DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&jobList).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get jobs", err)
+	}
+
+	return utils.SuccessResponse(c, "Jobs retrieved successfully", jobList)
+}
+
+// @Summary Get background job stats
+// @Description Count background jobs by kind and status (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=[]JobStat}
+// @Router /admin/jobs/stats [get]
+func (h *GamificationHandler) GetJobStats(c *fiber.Ctx) error {
+	var stats []JobStat
+	if err := database.DB.Model(&models.Job{}).
+		Select("kind, status, COUNT(*) AS count").
+		Group("kind, status").
+		Order("kind, status").
+		Scan(&stats).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get job stats", err)
+	}
+
+	return utils.SuccessResponse(c, "Job stats retrieved successfully", stats)
+}
+
+// @Summary Retry background job
+// @Description Queue a failed job to run again with a fresh set of attempts (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Job ID"
+// @Success 200 {object} utils.Response{data=models.Job}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /admin/jobs/{id}/retry [post]
+func (h *GamificationHandler) RetryJob(c *fiber.Ctx) error {
+	jobID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid job ID")
+	}
+
+	job, err := jobs.Retry(jobID)
+	if errors.Is(err, jobs.ErrNotFailed) {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+	if err != nil {
+		return utils.NotFoundResponse(c, "Job not found")
+	}
+
+	return utils.SuccessResponse(c, "Job queued for retry", job)
+}
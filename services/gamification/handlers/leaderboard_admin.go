@@ -0,0 +1,510 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/users"
+	"playful-marketplace/shared/utils"
+	"playful-marketplace/shared/xp"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	errLeaderboardNotFound = errors.New("leaderboard not found")
+	errNoAdmin             = errors.New("admin ID not found")
+	errInvalidUserID       = errors.New("invalid user ID")
+	errNotOnLeaderboard    = errors.New("user is not on the leaderboard")
+)
+
+// leaderboard is a ranking computed from one of the users' totals
+type leaderboard struct {
+	Name  string // As used in admin routes
+	Key   string // Redis sorted set
+	Role  models.UserRole
+	Score func(user *models.User) float64
+}
+
+var leaderboards = []leaderboard{
+	{Name: "buyers", Key: "weekly_buyers", Role: models.RoleBuyer, Score: func(u *models.User) float64 { return u.TotalSpent }},
+	{Name: "sellers", Key: "monthly_sellers", Role: models.RoleSeller, Score: func(u *models.User) float64 { return u.TotalSales }},
+}
+
+func findLeaderboard(name string) (leaderboard, bool) {
+	for _, board := range leaderboards {
+		if board.Name == name {
+			return board, true
+		}
+	}
+	return leaderboard{}, false
+}
+
+type AdminLeaderboardEntry struct {
+	models.LeaderboardEntry
+	ComputedScore float64 `json:"computed_score"` // From the user's totals, before adjustments
+	Adjustment    float64 `json:"adjustment"`
+}
+
+type AdminLeaderboardResponse struct {
+	Leaderboard string                  `json:"leaderboard"`
+	Total       int64                   `json:"total"`
+	Entries     []AdminLeaderboardEntry `json:"entries"`
+}
+
+type LeaderboardAdjustmentRequest struct {
+	Delta  float64 `json:"delta"` // Added to the score, negative to lower it
+	Reason string  `json:"reason" validate:"required"`
+}
+
+type LeaderboardReasonRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+type XPRulesPreviewRequest struct {
+	Rules xp.Rules `json:"rules"`
+	Days  int      `json:"days"` // History to replay, defaults to 90
+}
+
+type XPRulesPreviewResponse struct {
+	Current       xp.Rules          `json:"current"`
+	Proposed      xp.Rules          `json:"proposed"`
+	Since         time.Time         `json:"since"`
+	Orders        int               `json:"orders"`      // Delivered orders replayed
+	CurrentXP     int               `json:"current_xp"`  // XP those orders earn under the current rules
+	ProposedXP    int               `json:"proposed_xp"` // XP they would have earned under the proposed rules
+	UsersAffected int               `json:"users_affected"`
+	LevelUps      int               `json:"level_ups"`
+	LevelDowns    int               `json:"level_downs"`
+	TopChanges    []XPPreviewChange `json:"top_changes"` // Largest changes first
+}
+
+type XPPreviewChange struct {
+	UserID        uuid.UUID        `json:"user_id"`
+	Name          string           `json:"name"`
+	Delta         int              `json:"delta"`
+	CurrentXP     int              `json:"current_xp"`
+	ProposedXP    int              `json:"proposed_xp"`
+	CurrentLevel  models.UserLevel `json:"current_level"`
+	ProposedLevel models.UserLevel `json:"proposed_level"`
+}
+
+// @Summary Get raw leaderboard
+// @Description Page through a leaderboard with each entry's raw score, the score computed from the user's totals and any admin adjustment (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param leaderboard path string true "buyers or sellers"
+// @Param limit query int false "Number of entries to return" default(50)
+// @Param offset query int false "Number of entries to skip" default(0)
+// @Success 200 {object} utils.Response{data=AdminLeaderboardResponse}
+// @Failure 404 {object} utils.Problem
+// @Router /admin/gamification/leaderboards/{leaderboard} [get]
+func (h *GamificationHandler) GetRawLeaderboard(c *fiber.Ctx) error {
+	board, ok := findLeaderboard(c.Params("leaderboard"))
+	if !ok {
+		return utils.NotFoundResponse(c, "Leaderboard not found")
+	}
+
+	limit := c.QueryInt("limit", 50)
+	offset := c.QueryInt("offset", 0)
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	entries, total, err := redis.GetLeaderboardPage(board.Key, offset, limit)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get leaderboard", err)
+	}
+	hydrateLeaderboard(entries)
+
+	userIDs := make([]uuid.UUID, len(entries))
+	for i, entry := range entries {
+		userIDs[i] = entry.UserID
+	}
+	var totals []models.User
+	if len(userIDs) > 0 {
+		database.DB.Select("id", "total_spent", "total_sales").Where("id IN ?", userIDs).Find(&totals)
+	}
+	computed := make(map[uuid.UUID]float64, len(totals))
+	for i := range totals {
+		computed[totals[i].ID] = board.Score(&totals[i])
+	}
+
+	response := AdminLeaderboardResponse{
+		Leaderboard: board.Name,
+		Total:       total,
+		Entries:     make([]AdminLeaderboardEntry, len(entries)),
+	}
+	for i, entry := range entries {
+		response.Entries[i] = AdminLeaderboardEntry{
+			LeaderboardEntry: entry,
+			ComputedScore:    computed[entry.UserID],
+			Adjustment:       redis.GetLeaderboardOffset(board.Key, entry.UserID.String()),
+		}
+	}
+
+	return utils.SuccessResponse(c, "Leaderboard retrieved successfully", response)
+}
+
+// @Summary Adjust leaderboard entry
+// @Description Shift a user's leaderboard score by a delta, which later score updates keep applying (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param leaderboard path string true "buyers or sellers"
+// @Param userId path string true "User ID"
+// @Param request body LeaderboardAdjustmentRequest true "Adjustment"
+// @Success 200 {object} utils.Response{data=models.LeaderboardAdjustment}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /admin/gamification/leaderboards/{leaderboard}/{userId} [put]
+func (h *GamificationHandler) AdjustLeaderboardEntry(c *fiber.Ctx) error {
+	board, user, adminID, err := h.leaderboardEntryParams(c)
+	if err != nil {
+		return leaderboardEntryErrorResponse(c, err)
+	}
+
+	var req LeaderboardAdjustmentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+	if req.Delta == 0 || math.IsNaN(req.Delta) || math.IsInf(req.Delta, 0) || req.Reason == "" {
+		return utils.ValidationErrorResponse(c, "A non-zero delta and a reason are required")
+	}
+	if redis.IsLeaderboardExcluded(board.Key, user.ID.String()) {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "User has been removed from this leaderboard", nil)
+	}
+
+	previous, _ := redis.GetLeaderboardScore(board.Key, user.ID.String())
+	score, err := redis.AdjustLeaderboardEntry(board.Key, user.ID.String(), req.Delta)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to adjust leaderboard entry", err)
+	}
+
+	return h.recordLeaderboardAdjustment(c, models.LeaderboardAdjustment{
+		Leaderboard:   board.Name,
+		UserID:        user.ID,
+		AdminID:       adminID,
+		Action:        models.LeaderboardAdjust,
+		Delta:         req.Delta,
+		PreviousScore: previous,
+		NewScore:      score,
+		Reason:        req.Reason,
+	})
+}
+
+// @Summary Remove leaderboard entry
+// @Description Take a user off a leaderboard, e.g. for fraud, and keep later score updates from putting them back (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param leaderboard path string true "buyers or sellers"
+// @Param userId path string true "User ID"
+// @Param request body LeaderboardReasonRequest true "Reason"
+// @Success 200 {object} utils.Response{data=models.LeaderboardAdjustment}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /admin/gamification/leaderboards/{leaderboard}/{userId} [delete]
+func (h *GamificationHandler) RemoveLeaderboardEntry(c *fiber.Ctx) error {
+	board, user, adminID, err := h.leaderboardEntryParams(c)
+	if err != nil {
+		return leaderboardEntryErrorResponse(c, err)
+	}
+
+	var req LeaderboardReasonRequest
+	if err := c.BodyParser(&req); err != nil || req.Reason == "" {
+		return utils.ValidationErrorResponse(c, "Reason is required")
+	}
+
+	previous, _ := redis.GetLeaderboardScore(board.Key, user.ID.String())
+	if err := redis.ExcludeLeaderboardEntry(board.Key, user.ID.String()); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to remove leaderboard entry", err)
+	}
+
+	return h.recordLeaderboardAdjustment(c, models.LeaderboardAdjustment{
+		Leaderboard:   board.Name,
+		UserID:        user.ID,
+		AdminID:       adminID,
+		Action:        models.LeaderboardRemove,
+		PreviousScore: previous,
+		Reason:        req.Reason,
+	})
+}
+
+// @Summary Restore leaderboard entry
+// @Description Put a removed user back on a leaderboard with their current score (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param leaderboard path string true "buyers or sellers"
+// @Param userId path string true "User ID"
+// @Param request body LeaderboardReasonRequest true "Reason"
+// @Success 200 {object} utils.Response{data=models.LeaderboardAdjustment}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /admin/gamification/leaderboards/{leaderboard}/{userId}/restore [post]
+func (h *GamificationHandler) RestoreLeaderboardEntry(c *fiber.Ctx) error {
+	board, user, adminID, err := h.leaderboardEntryParams(c)
+	if err != nil {
+		return leaderboardEntryErrorResponse(c, err)
+	}
+
+	var req LeaderboardReasonRequest
+	if err := c.BodyParser(&req); err != nil || req.Reason == "" {
+		return utils.ValidationErrorResponse(c, "Reason is required")
+	}
+	if !redis.IsLeaderboardExcluded(board.Key, user.ID.String()) {
+		return utils.ValidationErrorResponse(c, "User has not been removed from this leaderboard")
+	}
+
+	score := board.Score(user) + redis.GetLeaderboardOffset(board.Key, user.ID.String())
+	if err := redis.IncludeLeaderboardEntry(board.Key, user.ID.String()); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to restore leaderboard entry", err)
+	}
+	if err := redis.SetLeaderboardEntry(board.Key, user.ID.String(), score); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to restore leaderboard entry", err)
+	}
+
+	return h.recordLeaderboardAdjustment(c, models.LeaderboardAdjustment{
+		Leaderboard: board.Name,
+		UserID:      user.ID,
+		AdminID:     adminID,
+		Action:      models.LeaderboardRestore,
+		NewScore:    score,
+		Reason:      req.Reason,
+	})
+}
+
+// @Summary Get leaderboard adjustments
+// @Description Get the history of admin corrections to a leaderboard, newest first (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param leaderboard path string true "buyers or sellers"
+// @Param user_id query string false "Only corrections to this user"
+// @Param limit query int false "Number of adjustments to return" default(50)
+// @Param offset query int false "Number of adjustments to skip" default(0)
+// @Success 200 {object} utils.Response{data=[]models.LeaderboardAdjustment}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /admin/gamification/leaderboards/{leaderboard}/adjustments [get]
+func (h *GamificationHandler) GetLeaderboardAdjustments(c *fiber.Ctx) error {
+	board, ok := findLeaderboard(c.Params("leaderboard"))
+	if !ok {
+		return utils.NotFoundResponse(c, "Leaderboard not found")
+	}
+
+	limit := c.QueryInt("limit", 50)
+	offset := c.QueryInt("offset", 0)
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	query := database.DB.Where("leaderboard = ?", board.Name)
+	if userParam := c.Query("user_id"); userParam != "" {
+		userID, err := uuid.Parse(userParam)
+		if err != nil {
+			return utils.ValidationErrorResponse(c, "Invalid user ID")
+		}
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var adjustments []models.LeaderboardAdjustment
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&adjustments).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get leaderboard adjustments", err)
+	}
+
+	return utils.SuccessResponse(c, "Leaderboard adjustments retrieved successfully", adjustments)
+}
+
+// @Summary Get XP rules
+// @Description Get the XP rules in effect for orders (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=xp.Rules}
+// @Router /admin/gamification/xp-rules [get]
+func (h *GamificationHandler) GetXPRules(c *fiber.Ctx) error {
+	return utils.SuccessResponse(c, "XP rules retrieved successfully", xp.FromConfig(h.config))
+}
+
+// @Summary Preview XP rules
+// @Description Replay recent delivered orders under proposed XP rules and compare with the current rules: total XP, users affected, level changes and the largest per-user changes. Nothing is changed; the rules take effect once deployed in config. (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param request body XPRulesPreviewRequest true "Proposed rules"
+// @Success 200 {object} utils.Response{data=XPRulesPreviewResponse}
+// @Failure 400 {object} utils.Problem
+// @Router /admin/gamification/xp-rules/preview [post]
+func (h *GamificationHandler) PreviewXPRules(c *fiber.Ctx) error {
+	var req XPRulesPreviewRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+	if req.Rules.BuyerPer100 < 0 || req.Rules.SellerPer100 < 0 || req.Rules.FirstOrder < 0 {
+		return utils.ValidationErrorResponse(c, "XP rates cannot be negative")
+	}
+	if req.Days <= 0 {
+		req.Days = 90
+	}
+	if req.Days > 365 {
+		return utils.ValidationErrorResponse(c, "Previews replay at most 365 days")
+	}
+
+	current := xp.FromConfig(h.config)
+	response := XPRulesPreviewResponse{
+		Current:  current,
+		Proposed: req.Rules,
+		Since:    time.Now().AddDate(0, 0, -req.Days),
+	}
+
+	// XP each user would gain or lose under the proposed rules
+	deltas := make(map[uuid.UUID]int)
+	var batch []models.Order
+	err := database.DB.Preload("Items.Product").
+		Where("status = ? AND delivered_at >= ?", models.OrderDelivered, response.Since).
+		FindInBatches(&batch, 200, func(tx *gorm.DB, _ int) error {
+			for _, order := range batch {
+				response.Orders++
+				response.CurrentXP += current.Purchase(order.TotalAmount)
+				response.ProposedXP += req.Rules.Purchase(order.TotalAmount)
+				deltas[order.BuyerID] += req.Rules.Purchase(order.TotalAmount) - current.Purchase(order.TotalAmount)
+
+				for _, item := range order.Items {
+					sale := item.Price * float64(item.Quantity)
+					response.CurrentXP += current.Sale(sale)
+					response.ProposedXP += req.Rules.Sale(sale)
+					deltas[item.Product.SellerID] += req.Rules.Sale(sale) - current.Sale(sale)
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to replay orders", err)
+	}
+
+	// First orders are found by the XP they were awarded
+	var firstOrders []uuid.UUID
+	database.DB.Model(&models.XPTransaction{}).
+		Where("reason = ? AND created_at >= ?", "First Order", response.Since).
+		Pluck("user_id", &firstOrders)
+	for _, userID := range firstOrders {
+		response.CurrentXP += current.FirstOrder
+		response.ProposedXP += req.Rules.FirstOrder
+		deltas[userID] += req.Rules.FirstOrder - current.FirstOrder
+	}
+
+	var changes []XPPreviewChange
+	var changedIDs []uuid.UUID
+	for userID, delta := range deltas {
+		if delta != 0 {
+			changedIDs = append(changedIDs, userID)
+		}
+	}
+	response.UsersAffected = len(changedIDs)
+
+	var affected []models.User
+	if len(changedIDs) > 0 {
+		database.DB.Select("id", "total_xp").Where("id IN ?", changedIDs).Find(&affected)
+	}
+	for _, user := range affected {
+		delta := deltas[user.ID]
+		proposedXP := user.TotalXP + delta
+		if proposedXP < 0 {
+			proposedXP = 0
+		}
+		change := XPPreviewChange{
+			UserID:        user.ID,
+			Delta:         delta,
+			CurrentXP:     user.TotalXP,
+			ProposedXP:    proposedXP,
+			CurrentLevel:  h.calculateLevel(user.TotalXP),
+			ProposedLevel: h.calculateLevel(proposedXP),
+		}
+		if change.ProposedLevel != change.CurrentLevel {
+			if delta > 0 {
+				response.LevelUps++
+			} else {
+				response.LevelDowns++
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return absInt(changes[i].Delta) > absInt(changes[j].Delta)
+	})
+	if len(changes) > 20 {
+		changes = changes[:20]
+	}
+	topIDs := make([]uuid.UUID, len(changes))
+	for i, change := range changes {
+		topIDs[i] = change.UserID
+	}
+	summaries := users.Summaries(topIDs)
+	for i := range changes {
+		changes[i].Name = summaries[changes[i].UserID].Name
+	}
+	response.TopChanges = changes
+
+	return utils.SuccessResponse(c, "XP rules preview generated successfully", response)
+}
+
+// leaderboardEntryParams resolves the leaderboard, the user and the acting
+// admin of a request on one entry
+func (h *GamificationHandler) leaderboardEntryParams(c *fiber.Ctx) (leaderboard, *models.User, uuid.UUID, error) {
+	board, ok := findLeaderboard(c.Params("leaderboard"))
+	if !ok {
+		return board, nil, uuid.Nil, errLeaderboardNotFound
+	}
+
+	adminID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return board, nil, uuid.Nil, errNoAdmin
+	}
+
+	userID, err := uuid.Parse(c.Params("userId"))
+	if err != nil {
+		return board, nil, uuid.Nil, errInvalidUserID
+	}
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil || !user.HasRole(board.Role) {
+		return board, nil, uuid.Nil, errNotOnLeaderboard
+	}
+
+	return board, &user, adminID, nil
+}
+
+func leaderboardEntryErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errLeaderboardNotFound):
+		return utils.NotFoundResponse(c, "Leaderboard not found")
+	case errors.Is(err, errNoAdmin):
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	case errors.Is(err, errInvalidUserID):
+		return utils.ValidationErrorResponse(c, "Invalid user ID")
+	}
+	return utils.NotFoundResponse(c, "User is not on this leaderboard")
+}
+
+func (h *GamificationHandler) recordLeaderboardAdjustment(c *fiber.Ctx, adjustment models.LeaderboardAdjustment) error {
+	adjustment.ID = uuid.New()
+	if err := database.DB.Create(&adjustment).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to record leaderboard adjustment", err)
+	}
+
+	return utils.SuccessResponse(c, "Leaderboard entry updated successfully", adjustment)
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
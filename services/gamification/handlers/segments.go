@@ -0,0 +1,448 @@
+package handlers
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/jobs"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/segments"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const jobMaterializeSegment = "gamification.materialize_segment"
+
+type segmentJob struct {
+	SegmentID uuid.UUID `json:"segment_id"`
+}
+
+// Tags are short lowercase slugs, e.g. vip or wholesale-buyer
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,39}$`)
+
+type SegmentRequest struct {
+	Name        string               `json:"name"`
+	Description *string              `json:"description"`
+	Rules       *models.SegmentRules `json:"rules"`
+	IsActive    *bool                `json:"is_active"`
+}
+
+type SegmentPreviewResponse struct {
+	Count int64 `json:"count"` // Users the rules match now
+}
+
+type SegmentUsersResponse struct {
+	SegmentID      uuid.UUID   `json:"segment_id"`
+	MaterializedAt *time.Time  `json:"materialized_at"`
+	Total          int64       `json:"total"`
+	UserIDs        []uuid.UUID `json:"user_ids"`
+}
+
+type TagUsersRequest struct {
+	Tag     string      `json:"tag"`
+	UserIDs []uuid.UUID `json:"user_ids"`
+}
+
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Users int64  `json:"users"`
+}
+
+// @Summary Create segment
+// @Description Define a marketing segment by rules on level, total spent, last order date, category affinity and tags. Memberships are materialized right away and then nightly. (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param request body SegmentRequest true "Segment"
+// @Success 201 {object} utils.Response{data=models.Segment}
+// @Failure 400 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /admin/segments [post]
+func (h *GamificationHandler) CreateSegment(c *fiber.Ctx) error {
+	adminID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req SegmentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || req.Rules == nil {
+		return utils.ValidationErrorResponse(c, "Name and rules are required")
+	}
+	if err := segments.Validate(*req.Rules); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	segment := models.Segment{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		Name:      req.Name,
+		Rules:     *req.Rules,
+		IsActive:  true,
+		CreatedBy: adminID,
+	}
+	if req.Description != nil {
+		segment.Description = *req.Description
+	}
+	if req.IsActive != nil {
+		segment.IsActive = *req.IsActive
+	}
+
+	var count int64
+	database.DB.Model(&models.Segment{}).Where("name = ?", segment.Name).Count(&count)
+	if count > 0 {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "A segment with this name already exists", nil)
+	}
+
+	if err := database.DB.Create(&segment).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create segment", err)
+	}
+	jobs.Enqueue(jobMaterializeSegment, segmentJob{SegmentID: segment.ID})
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Segment created successfully",
+		Data:    segment,
+	})
+}
+
+// @Summary Get segments
+// @Description List marketing segments with their member counts (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=[]models.Segment}
+// @Router /admin/segments [get]
+func (h *GamificationHandler) GetSegments(c *fiber.Ctx) error {
+	var list []models.Segment
+	if err := database.DB.Order("name ASC").Find(&list).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get segments", err)
+	}
+
+	return utils.SuccessResponse(c, "Segments retrieved successfully", list)
+}
+
+// @Summary Get segment
+// @Description Get a marketing segment (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Segment ID"
+// @Success 200 {object} utils.Response{data=models.Segment}
+// @Failure 404 {object} utils.Problem
+// @Router /admin/segments/{id} [get]
+func (h *GamificationHandler) GetSegment(c *fiber.Ctx) error {
+	segment, err := findSegment(c.Params("id"))
+	if err != nil {
+		return utils.NotFoundResponse(c, "Segment not found")
+	}
+
+	return utils.SuccessResponse(c, "Segment retrieved successfully", segment)
+}
+
+// @Summary Update segment
+// @Description Rename, change the rules of or pause a marketing segment. Changed rules are materialized right away. (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Segment ID"
+// @Param request body SegmentRequest true "Segment fields to update"
+// @Success 200 {object} utils.Response{data=models.Segment}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /admin/segments/{id} [put]
+func (h *GamificationHandler) UpdateSegment(c *fiber.Ctx) error {
+	segment, err := findSegment(c.Params("id"))
+	if err != nil {
+		return utils.NotFoundResponse(c, "Segment not found")
+	}
+
+	var req SegmentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if name := strings.TrimSpace(req.Name); name != "" && name != segment.Name {
+		var count int64
+		database.DB.Model(&models.Segment{}).Where("name = ? AND id <> ?", name, segment.ID).Count(&count)
+		if count > 0 {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "A segment with this name already exists", nil)
+		}
+		segment.Name = name
+	}
+	if req.Description != nil {
+		segment.Description = *req.Description
+	}
+	if req.Rules != nil {
+		if err := segments.Validate(*req.Rules); err != nil {
+			return utils.ValidationErrorResponse(c, err.Error())
+		}
+		segment.Rules = *req.Rules
+	}
+	if req.IsActive != nil {
+		segment.IsActive = *req.IsActive
+	}
+
+	if err := database.DB.Save(segment).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update segment", err)
+	}
+	if req.Rules != nil && segment.IsActive {
+		jobs.Enqueue(jobMaterializeSegment, segmentJob{SegmentID: segment.ID})
+	}
+
+	return utils.SuccessResponse(c, "Segment updated successfully", segment)
+}
+
+// @Summary Delete segment
+// @Description Delete a marketing segment and its memberships (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Segment ID"
+// @Success 200 {object} utils.Response
+// @Failure 404 {object} utils.Problem
+// @Router /admin/segments/{id} [delete]
+func (h *GamificationHandler) DeleteSegment(c *fiber.Ctx) error {
+	segment, err := findSegment(c.Params("id"))
+	if err != nil {
+		return utils.NotFoundResponse(c, "Segment not found")
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("segment_id = ?", segment.ID).Delete(&models.SegmentMember{}).Error; err != nil {
+			return err
+		}
+		// Hard delete so the name can be reused
+		return tx.Unscoped().Delete(segment).Error
+	})
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to delete segment", err)
+	}
+
+	return utils.SuccessResponse(c, "Segment deleted successfully", nil)
+}
+
+// @Summary Refresh segment
+// @Description Materialize a segment's memberships now instead of waiting for the nightly run (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Segment ID"
+// @Success 200 {object} utils.Response{data=models.Segment}
+// @Failure 404 {object} utils.Problem
+// @Router /admin/segments/{id}/refresh [post]
+func (h *GamificationHandler) RefreshSegment(c *fiber.Ctx) error {
+	segment, err := findSegment(c.Params("id"))
+	if err != nil {
+		return utils.NotFoundResponse(c, "Segment not found")
+	}
+
+	if _, err := segments.Materialize(segment); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to refresh segment", err)
+	}
+
+	return utils.SuccessResponse(c, "Segment refreshed successfully", segment)
+}
+
+// @Summary Preview segment rules
+// @Description Count the users segment rules match now, without saving anything (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param request body models.SegmentRules true "Rules"
+// @Success 200 {object} utils.Response{data=SegmentPreviewResponse}
+// @Failure 400 {object} utils.Problem
+// @Router /admin/segments/preview [post]
+func (h *GamificationHandler) PreviewSegment(c *fiber.Ctx) error {
+	var rules models.SegmentRules
+	if err := c.BodyParser(&rules); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+	if err := segments.Validate(rules); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	var response SegmentPreviewResponse
+	if err := database.DB.Scopes(segments.Matching(rules)).Count(&response.Count).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to preview segment", err)
+	}
+
+	return utils.SuccessResponse(c, "Segment preview generated successfully", response)
+}
+
+// @Summary Get segment users
+// @Description Page through the IDs of the users in a segment as of its last materialization, for targeting campaigns. Accepts an API key with the segments:read scope.
+// @Tags segments
+// @Security BearerAuth
+// @Param id path string true "Segment ID"
+// @Param limit query int false "Number of users to return" default(1000)
+// @Param offset query int false "Number of users to skip" default(0)
+// @Success 200 {object} utils.Response{data=SegmentUsersResponse}
+// @Failure 404 {object} utils.Problem
+// @Router /segments/{id}/users [get]
+func (h *GamificationHandler) GetSegmentUsers(c *fiber.Ctx) error {
+	segment, err := findSegment(c.Params("id"))
+	if err != nil {
+		return utils.NotFoundResponse(c, "Segment not found")
+	}
+
+	limit := c.QueryInt("limit", 1000)
+	offset := c.QueryInt("offset", 0)
+	if limit <= 0 || limit > 5000 {
+		limit = 1000
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	response := SegmentUsersResponse{
+		SegmentID:      segment.ID,
+		MaterializedAt: segment.MaterializedAt,
+		UserIDs:        []uuid.UUID{},
+	}
+	query := database.DB.Model(&models.SegmentMember{}).Where("segment_id = ?", segment.ID)
+	query.Count(&response.Total)
+	if err := query.Order("user_id").Limit(limit).Offset(offset).Pluck("user_id", &response.UserIDs).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get segment users", err)
+	}
+
+	return utils.SuccessResponse(c, "Segment users retrieved successfully", response)
+}
+
+// @Summary Get tags
+// @Description List the tags put on users and how many users have each (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=[]TagCount}
+// @Router /admin/tags [get]
+func (h *GamificationHandler) GetTags(c *fiber.Ctx) error {
+	var tags []TagCount
+	if err := database.DB.Model(&models.UserTag{}).
+		Select("tag, COUNT(*) AS users").
+		Group("tag").
+		Order("tag ASC").
+		Scan(&tags).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get tags", err)
+	}
+
+	return utils.SuccessResponse(c, "Tags retrieved successfully", tags)
+}
+
+// @Summary Tag users
+// @Description Put a tag on users, e.g. vip, for segments to select. Users who already have it are skipped. (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param request body TagUsersRequest true "Tag and users"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Problem
+// @Router /admin/tags [post]
+func (h *GamificationHandler) TagUsers(c *fiber.Ctx) error {
+	adminID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req TagUsersRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	req.Tag = strings.ToLower(strings.TrimSpace(req.Tag))
+	if !tagPattern.MatchString(req.Tag) {
+		return utils.ValidationErrorResponse(c, "Tags are up to 40 lowercase letters, digits, dashes and underscores")
+	}
+	if len(req.UserIDs) == 0 || len(req.UserIDs) > 1000 {
+		return utils.ValidationErrorResponse(c, "Between 1 and 1000 user IDs are required")
+	}
+
+	var existing []uuid.UUID
+	database.DB.Model(&models.User{}).Where("id IN ?", req.UserIDs).Pluck("id", &existing)
+	var tagged []uuid.UUID
+	database.DB.Model(&models.UserTag{}).Where("tag = ? AND user_id IN ?", req.Tag, existing).Pluck("user_id", &tagged)
+	already := make(map[uuid.UUID]bool, len(tagged))
+	for _, userID := range tagged {
+		already[userID] = true
+	}
+
+	tags := make([]models.UserTag, 0, len(existing))
+	for _, userID := range existing {
+		if already[userID] {
+			continue
+		}
+		already[userID] = true
+		tags = append(tags, models.UserTag{
+			BaseModel: models.BaseModel{ID: uuid.New()},
+			UserID:    userID,
+			Tag:       req.Tag,
+			CreatedBy: adminID,
+		})
+	}
+	if len(tags) > 0 {
+		if err := database.DB.Create(&tags).Error; err != nil {
+			return utils.InternalServerErrorResponse(c, "Failed to tag users", err)
+		}
+	}
+
+	return utils.SuccessResponse(c, "Users tagged successfully", fiber.Map{
+		"tag":     req.Tag,
+		"tagged":  len(tags),
+		"skipped": len(req.UserIDs) - len(tags),
+	})
+}
+
+// @Summary Untag user
+// @Description Take a tag off a user (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param tag path string true "Tag"
+// @Param userId path string true "User ID"
+// @Success 200 {object} utils.Response
+// @Failure 404 {object} utils.Problem
+// @Router /admin/tags/{tag}/users/{userId} [delete]
+func (h *GamificationHandler) UntagUser(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("userId"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid user ID")
+	}
+
+	result := database.DB.Unscoped().Where("tag = ? AND user_id = ?", c.Params("tag"), userID).Delete(&models.UserTag{})
+	if result.Error != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to untag user", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return utils.NotFoundResponse(c, "User does not have this tag")
+	}
+
+	return utils.SuccessResponse(c, "User untagged successfully", nil)
+}
+
+func (h *GamificationHandler) materializeSegment(payload []byte) error {
+	var job segmentJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+
+	var segment models.Segment
+	if err := database.DB.First(&segment, job.SegmentID).Error; err != nil {
+		// Deleted before the job ran
+		return nil
+	}
+	_, err := segments.Materialize(&segment)
+	return err
+}
+
+func findSegment(id string) (*models.Segment, error) {
+	segmentID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var segment models.Segment
+	if err := database.DB.First(&segment, segmentID).Error; err != nil {
+		return nil, err
+	}
+	return &segment, nil
+}
@@ -2,13 +2,19 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"playful-marketplace/services/gamification/handlers"
 	"playful-marketplace/services/gamification/routes"
 	"playful-marketplace/shared/config"
 	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/jobs"
 	"playful-marketplace/shared/middleware"
+	"playful-marketplace/shared/notifications"
 	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/scheduler"
+	"playful-marketplace/shared/segments"
+	"playful-marketplace/shared/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/recover"
@@ -35,27 +41,30 @@ func main() {
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
-		AppName: "Playful Marketplace Gamification Service",
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			code := fiber.StatusInternalServerError
-			if e, ok := err.(*fiber.Error); ok {
-				code = e.Code
-			}
-			return c.Status(code).JSON(fiber.Map{
-				"success": false,
-				"message": "Internal Server Error",
-				"error":   err.Error(),
-			})
-		},
+		AppName:      "Playful Marketplace Gamification Service",
+		ErrorHandler: utils.FiberErrorHandler,
 	})
 
 	// Middleware
+	app.Use(middleware.TraceMiddleware())
 	app.Use(recover.New())
 	app.Use(middleware.CORSMiddleware())
 	app.Use(middleware.LoggingMiddleware())
+	app.Use(middleware.FaultMiddleware(cfg))
+	app.Use(middleware.MaintenanceMiddleware(cfg, "gamification"))
+	app.Use(middleware.RateLimitMiddleware(cfg))
+	app.Use(middleware.UsageMiddleware())
 
 	// Initialize handlers
 	gamificationHandler := handlers.NewGamificationHandler(cfg)
+	gamificationHandler.RegisterJobs()
+	jobs.Start(cfg.Jobs)
+
+	// Background jobs
+	scheduler.Every("reengagement-campaigns", time.Hour, gamificationHandler.RunCampaigns)
+	scheduler.Every("notification-digests", time.Hour, notifications.SendDigests)
+	scheduler.Every("announcement-pushes", 5*time.Minute, gamificationHandler.PushAnnouncements)
+	scheduler.Every("segment-memberships", 24*time.Hour, segments.MaterializeAll)
 
 	// Health check
 	app.Get("/health", func(c *fiber.Ctx) error {
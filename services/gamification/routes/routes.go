@@ -26,4 +26,50 @@ func SetupGamificationRoutes(api fiber.Router, gamificationHandler *handlers.Gam
 	// Leaderboard routes
 	gamify.Get("/leaderboard/buyers", gamificationHandler.GetBuyerLeaderboard)
 	gamify.Get("/leaderboard/sellers", gamificationHandler.GetSellerLeaderboard)
+
+	// Announcements and banners, signed-in users also see their audience's
+	api.Get("/announcements", middleware.OptionalAuthMiddleware(cfg), gamificationHandler.GetAnnouncements)
+
+	// Segment members for services targeting campaigns, by admin token or API key
+	api.Get("/segments/:id/users", middleware.AuthOrAPIKeyMiddleware(cfg, "segments"),
+		middleware.PermissionMiddleware(middleware.PermSegmentsRead), gamificationHandler.GetSegmentUsers)
+
+	// Admin view of the background job queue, registered ahead of the
+	// /admin group so only the jobs permission applies
+	jobs := api.Group("/admin/jobs", middleware.AuthMiddleware(cfg), middleware.PermissionMiddleware(middleware.PermJobsManage))
+	jobs.Get("/", gamificationHandler.GetJobs)
+	jobs.Get("/stats", gamificationHandler.GetJobStats)
+	jobs.Post("/:id/retry", gamificationHandler.RetryJob)
+
+	// Admin leaderboard corrections and XP rule previews, registered ahead
+	// of the /admin group so only the gamification permission applies
+	gamifyAdmin := api.Group("/admin/gamification", middleware.AuthMiddleware(cfg), middleware.PermissionMiddleware(middleware.PermGamifyWrite))
+	gamifyAdmin.Get("/leaderboards/:leaderboard", gamificationHandler.GetRawLeaderboard)
+	gamifyAdmin.Get("/leaderboards/:leaderboard/adjustments", gamificationHandler.GetLeaderboardAdjustments)
+	gamifyAdmin.Put("/leaderboards/:leaderboard/:userId", gamificationHandler.AdjustLeaderboardEntry)
+	gamifyAdmin.Delete("/leaderboards/:leaderboard/:userId", gamificationHandler.RemoveLeaderboardEntry)
+	gamifyAdmin.Post("/leaderboards/:leaderboard/:userId/restore", gamificationHandler.RestoreLeaderboardEntry)
+	gamifyAdmin.Get("/xp-rules", gamificationHandler.GetXPRules)
+	gamifyAdmin.Post("/xp-rules/preview", gamificationHandler.PreviewXPRules)
+
+	// Admin re-engagement campaigns and announcements
+	admin := api.Group("/admin", middleware.AuthMiddleware(cfg), middleware.PermissionMiddleware(middleware.PermCampaignsWrite))
+	admin.Post("/campaigns", gamificationHandler.CreateCampaign)
+	admin.Get("/campaigns", gamificationHandler.GetCampaigns)
+	admin.Put("/campaigns/:id", gamificationHandler.UpdateCampaign)
+	admin.Post("/announcements", gamificationHandler.CreateAnnouncement)
+	admin.Get("/announcements", gamificationHandler.GetAllAnnouncements)
+	admin.Put("/announcements/:id", gamificationHandler.UpdateAnnouncement)
+
+	// Marketing segments and the user tags they can select on
+	admin.Post("/segments/preview", gamificationHandler.PreviewSegment)
+	admin.Post("/segments", gamificationHandler.CreateSegment)
+	admin.Get("/segments", gamificationHandler.GetSegments)
+	admin.Get("/segments/:id", gamificationHandler.GetSegment)
+	admin.Put("/segments/:id", gamificationHandler.UpdateSegment)
+	admin.Delete("/segments/:id", gamificationHandler.DeleteSegment)
+	admin.Post("/segments/:id/refresh", gamificationHandler.RefreshSegment)
+	admin.Get("/tags", gamificationHandler.GetTags)
+	admin.Post("/tags", gamificationHandler.TagUsers)
+	admin.Delete("/tags/:tag/users/:userId", gamificationHandler.UntagUser)
 }
@@ -0,0 +1,461 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"playful-marketplace/shared/analytics"
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/shipping"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CartItemRequest struct {
+	ProductID uuid.UUID  `json:"product_id" validate:"required"`
+	VariantID *uuid.UUID `json:"variant_id"` // Required for products with variants
+	Quantity  int        `json:"quantity" validate:"required,min=1"`
+}
+
+type UpdateCartItemRequest struct {
+	Quantity int `json:"quantity" validate:"required,min=1"`
+}
+
+type CartResponse struct {
+	Cart     models.Cart `json:"cart"`
+	Subtotal float64     `json:"subtotal"`
+	Shipping float64     `json:"shipping"`           // Sellers' shipping fees, as charged at checkout
+	Warnings []string    `json:"warnings,omitempty"` // Items that can't ship to the delivery zone
+}
+
+// @Summary Get cart
+// @Description Get the current user's or guest's cart, with shipping and warnings for items that can't ship to the delivery zone
+// @Tags cart
+// @Security BearerAuth
+// @Param delivery_zone query string false "Delivery zone code to check shipping against"
+// @Success 200 {object} utils.Response{data=CartResponse}
+// @Router /cart [get]
+func (h *OrderHandler) GetCart(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	cart, err := h.getOrCreateCart(userID)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get cart", err)
+	}
+
+	return utils.SuccessResponse(c, "Cart retrieved successfully", h.cartResponse(cart, c.Query("delivery_zone")))
+}
+
+// @Summary Add item to cart
+// @Description Add a product, or one of its variants, to the cart, increasing the quantity if it is already there
+// @Tags cart
+// @Security BearerAuth
+// @Param request body CartItemRequest true "Cart item"
+// @Success 200 {object} utils.Response{data=CartResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /cart/items [post]
+func (h *OrderHandler) AddCartItem(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req CartItemRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.Quantity < 1 {
+		return utils.ValidationErrorResponse(c, "Quantity must be at least 1")
+	}
+
+	var product models.Product
+	if err := database.DB.Where("id = ? AND is_active = ?", req.ProductID, true).First(&product).Error; err != nil {
+		return utils.NotFoundResponse(c, "Product not found")
+	}
+
+	variant, err := orderVariant(database.DB, &product, req.VariantID)
+	if err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+	stock := product.Stock
+	if variant != nil {
+		stock = variant.Stock
+	}
+
+	cart, err := h.getOrCreateCart(userID)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get cart", err)
+	}
+
+	// Stock is per variant, quantity rules are per product
+	quantity := req.Quantity
+	productQuantity := req.Quantity
+	for _, item := range cart.Items {
+		if item.ProductID == product.ID {
+			productQuantity += item.Quantity
+			if sameVariant(item.VariantID, req.VariantID) {
+				quantity += item.Quantity
+			}
+		}
+	}
+
+	if quantity > stock && !product.AcceptsBackorders() {
+		return utils.ValidationErrorResponse(c, "Requested quantity exceeds available stock")
+	}
+
+	if err := checkQuantityRules(database.DB, &product, userID, productQuantity); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	if err := h.setCartItem(cart, product.ID, req.VariantID, quantity); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update cart", err)
+	}
+
+	analytics.Track(analytics.EventAddToCart, product.ID)
+
+	cart, err = h.getOrCreateCart(userID)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get cart", err)
+	}
+
+	return utils.SuccessResponse(c, "Item added to cart", h.cartResponse(cart, c.Query("delivery_zone")))
+}
+
+// @Summary Update cart item
+// @Description Change the quantity of a product in the cart
+// @Tags cart
+// @Security BearerAuth
+// @Param product_id path string true "Product ID"
+// @Param variant_id query string false "Variant ID, for products with variants"
+// @Param request body UpdateCartItemRequest true "Quantity"
+// @Success 200 {object} utils.Response{data=CartResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /cart/items/{product_id} [put]
+func (h *OrderHandler) UpdateCartItem(c *fiber.Ctx) error {
+	productID, err := uuid.Parse(c.Params("product_id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid product ID")
+	}
+
+	variantID, err := variantQuery(c)
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid variant ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req UpdateCartItemRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.Quantity < 1 {
+		return utils.ValidationErrorResponse(c, "Quantity must be at least 1")
+	}
+
+	cart, err := h.getOrCreateCart(userID)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get cart", err)
+	}
+
+	var line *models.CartItem
+	productQuantity := req.Quantity
+	for i, item := range cart.Items {
+		if item.ProductID != productID {
+			continue
+		}
+		if sameVariant(item.VariantID, variantID) {
+			line = &cart.Items[i]
+		} else {
+			productQuantity += item.Quantity
+		}
+	}
+	if line == nil {
+		return utils.NotFoundResponse(c, "Item not in cart")
+	}
+
+	stock := line.Product.Stock
+	if line.Variant != nil {
+		stock = line.Variant.Stock
+	}
+	if req.Quantity > stock && !line.Product.AcceptsBackorders() {
+		return utils.ValidationErrorResponse(c, "Requested quantity exceeds available stock")
+	}
+	if err := checkQuantityRules(database.DB, &line.Product, userID, productQuantity); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	if err := h.setCartItem(cart, productID, variantID, req.Quantity); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update cart", err)
+	}
+
+	cart, err = h.getOrCreateCart(userID)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get cart", err)
+	}
+
+	return utils.SuccessResponse(c, "Cart updated successfully", h.cartResponse(cart, c.Query("delivery_zone")))
+}
+
+// @Summary Remove cart item
+// @Description Remove a product from the cart
+// @Tags cart
+// @Security BearerAuth
+// @Param product_id path string true "Product ID"
+// @Param variant_id query string false "Variant ID, for products with variants"
+// @Success 200 {object} utils.Response{data=CartResponse}
+// @Router /cart/items/{product_id} [delete]
+func (h *OrderHandler) RemoveCartItem(c *fiber.Ctx) error {
+	productID, err := uuid.Parse(c.Params("product_id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid product ID")
+	}
+
+	variantID, err := variantQuery(c)
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid variant ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	cart, err := h.getOrCreateCart(userID)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get cart", err)
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := cartLine(tx.Unscoped(), cart.ID, productID, variantID).Delete(&models.CartItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(cart).Update("last_activity_at", time.Now()).Error
+	})
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update cart", err)
+	}
+
+	cart, err = h.getOrCreateCart(userID)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get cart", err)
+	}
+
+	return utils.SuccessResponse(c, "Item removed from cart", h.cartResponse(cart, c.Query("delivery_zone")))
+}
+
+// @Summary Clear cart
+// @Description Remove every item from the cart
+// @Tags cart
+// @Security BearerAuth
+// @Success 200 {object} utils.Response
+// @Router /cart [delete]
+func (h *OrderHandler) ClearCart(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	if err := clearCart(database.DB, userID); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to clear cart", err)
+	}
+
+	return utils.SuccessResponse(c, "Cart cleared successfully", nil)
+}
+
+// Helper functions
+
+func (h *OrderHandler) getOrCreateCart(userID uuid.UUID) (*models.Cart, error) {
+	cart := models.Cart{
+		BaseModel:      models.BaseModel{ID: uuid.New()},
+		UserID:         userID,
+		LastActivityAt: time.Now(),
+	}
+	if err := database.DB.Preload("Items.Product.PriceTiers").Preload("Items.Variant").
+		Where(models.Cart{UserID: userID}).
+		FirstOrCreate(&cart).Error; err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+func (h *OrderHandler) setCartItem(cart *models.Cart, productID uuid.UUID, variantID *uuid.UUID, quantity int) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var item models.CartItem
+		err := cartLine(tx, cart.ID, productID, variantID).First(&item).Error
+		if err == gorm.ErrRecordNotFound {
+			item = models.CartItem{
+				BaseModel: models.BaseModel{ID: uuid.New()},
+				CartID:    cart.ID,
+				ProductID: productID,
+				VariantID: variantID,
+				Quantity:  quantity,
+			}
+			if err := tx.Create(&item).Error; err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		} else if err := tx.Model(&item).Update("quantity", quantity).Error; err != nil {
+			return err
+		}
+		return tx.Model(cart).Update("last_activity_at", time.Now()).Error
+	})
+}
+
+func (h *OrderHandler) cartResponse(cart *models.Cart, zone string) CartResponse {
+	response := CartResponse{Cart: *cart}
+	sellerSubtotals := make(map[uuid.UUID]float64)
+	sellerShipping := make(map[uuid.UUID]models.ShippingSettings)
+	for _, item := range cart.Items {
+		line := item.Product.VariantUnitPrice(item.Variant, item.Quantity) * float64(item.Quantity)
+		response.Subtotal += line
+		sellerSubtotals[item.Product.SellerID] += line
+
+		settings, ok := sellerShipping[item.Product.SellerID]
+		if !ok {
+			settings = shipping.SettingsForSeller(item.Product.SellerID)
+			sellerShipping[item.Product.SellerID] = settings
+		}
+		if !shipping.ShipsTo(settings, zone) {
+			if zone == "" {
+				response.Warnings = append(response.Warnings, fmt.Sprintf("%s only ships to some delivery zones", item.Product.Name))
+			} else {
+				response.Warnings = append(response.Warnings, fmt.Sprintf("%s doesn't ship to your delivery zone", item.Product.Name))
+			}
+		}
+	}
+
+	for sellerID, subtotal := range sellerSubtotals {
+		response.Shipping += shipping.Fee(sellerShipping[sellerID], subtotal)
+	}
+	return response
+}
+
+// cartOrderItems turns the user's cart into order line items for checkout
+func cartOrderItems(userID uuid.UUID) ([]OrderItemRequest, error) {
+	var items []models.CartItem
+	if err := database.DB.Joins("JOIN carts ON carts.id = cart_items.cart_id").
+		Where("carts.user_id = ?", userID).
+		Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	requests := make([]OrderItemRequest, len(items))
+	for i, item := range items {
+		requests[i] = OrderItemRequest{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Quantity}
+	}
+	return requests, nil
+}
+
+func clearCart(db *gorm.DB, userID uuid.UUID) error {
+	if err := db.Unscoped().
+		Where("cart_id IN (SELECT id FROM carts WHERE user_id = ?)", userID).
+		Delete(&models.CartItem{}).Error; err != nil {
+		return err
+	}
+
+	// An emptied cart starts a fresh reminder cycle
+	return db.Model(&models.Cart{}).Where("user_id = ?", userID).Updates(map[string]interface{}{
+		"reminders_sent":   0,
+		"last_reminded_at": nil,
+	}).Error
+}
+
+// cartLine scopes db to the cart's line for the product and variant
+func cartLine(db *gorm.DB, cartID, productID uuid.UUID, variantID *uuid.UUID) *gorm.DB {
+	db = db.Where("cart_id = ? AND product_id = ?", cartID, productID)
+	if variantID == nil {
+		return db.Where("variant_id IS NULL")
+	}
+	return db.Where("variant_id = ?", *variantID)
+}
+
+// variantQuery reads the optional variant_id query parameter
+func variantQuery(c *fiber.Ctx) (*uuid.UUID, error) {
+	if c.Query("variant_id") == "" {
+		return nil, nil
+	}
+	variantID, err := uuid.Parse(c.Query("variant_id"))
+	if err != nil {
+		return nil, err
+	}
+	return &variantID, nil
+}
+
+func sameVariant(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// orderVariant loads the variant being bought. Products with variants can
+// only be bought by variant, and other products without one.
+func orderVariant(db *gorm.DB, product *models.Product, variantID *uuid.UUID) (*models.ProductVariant, error) {
+	if variantID == nil {
+		var variants int64
+		if err := db.Model(&models.ProductVariant{}).Where("product_id = ? AND is_active = ?", product.ID, true).Count(&variants).Error; err != nil {
+			return nil, err
+		}
+		if variants > 0 {
+			return nil, fmt.Errorf("choose a variant of %s", product.Name)
+		}
+		return nil, nil
+	}
+
+	var variant models.ProductVariant
+	if err := db.Where("id = ? AND product_id = ? AND is_active = ?", *variantID, product.ID, true).First(&variant).Error; err != nil {
+		return nil, fmt.Errorf("the chosen variant of %s is not available", product.Name)
+	}
+	return &variant, nil
+}
+
+// checkQuantityRules enforces a product's per-order quantity range and its
+// per-customer limit, counting what the buyer has already ordered
+func checkQuantityRules(db *gorm.DB, product *models.Product, buyerID uuid.UUID, quantity int) error {
+	if quantity < 1 {
+		return fmt.Errorf("quantity of %s must be at least 1", product.Name)
+	}
+	if product.MinOrderQuantity > 0 && quantity < product.MinOrderQuantity {
+		return fmt.Errorf("%s has a minimum order quantity of %d", product.Name, product.MinOrderQuantity)
+	}
+	if product.MaxOrderQuantity > 0 && quantity > product.MaxOrderQuantity {
+		return fmt.Errorf("%s has a maximum order quantity of %d", product.Name, product.MaxOrderQuantity)
+	}
+
+	if product.PerCustomerLimit > 0 {
+		var purchased int
+		if err := db.Model(&models.OrderItem{}).
+			Joins("JOIN orders ON orders.id = order_items.order_id").
+			Where("orders.buyer_id = ? AND order_items.product_id = ?", buyerID, product.ID).
+			Where("orders.status <> ? AND order_items.status <> ?", models.OrderCancelled, models.ItemCancelled).
+			Select("COALESCE(SUM(order_items.quantity), 0)").
+			Scan(&purchased).Error; err != nil {
+			return err
+		}
+
+		remaining := product.PerCustomerLimit - purchased
+		if quantity > remaining {
+			if remaining <= 0 {
+				return fmt.Errorf("you have reached the limit of %d per customer for %s", product.PerCustomerLimit, product.Name)
+			}
+			return fmt.Errorf("you can buy %d more of %s (limit %d per customer)", remaining, product.Name, product.PerCustomerLimit)
+		}
+	}
+
+	return nil
+}
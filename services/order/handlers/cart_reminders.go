@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"playful-marketplace/shared/coupons"
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+)
+
+const (
+	maxCartReminders      = 2
+	cartReminderValidDays = 7
+)
+
+// SendAbandonedCartReminders nudges buyers whose carts have sat untouched for
+// the configured period, at most twice per cart. The final reminder carries a
+// coupon when one is configured. Run periodically by the scheduler.
+func (h *OrderHandler) SendAbandonedCartReminders() {
+	idle := time.Now().Add(-time.Duration(h.config.Orders.CartReminderHours) * time.Hour)
+
+	var carts []models.Cart
+	database.DB.Preload("Items.Product").
+		Joins("JOIN users ON users.id = carts.user_id").
+		Where("carts.reminders_sent < ? AND carts.last_activity_at < ?", maxCartReminders, idle).
+		Where("carts.last_reminded_at IS NULL OR carts.last_reminded_at < ?", idle).
+		Where("users.role <> ? AND users.is_active = ? AND users.marketing_opt_out = ?", models.RoleGuest, true, false).
+		Where("EXISTS (SELECT 1 FROM cart_items WHERE cart_items.cart_id = carts.id)").
+		Limit(500).
+		Find(&carts)
+
+	for i := range carts {
+		if err := h.sendCartReminder(&carts[i]); err != nil {
+			log.Printf("Failed to send cart reminder for cart %s: %v", carts[i].ID, err)
+		}
+	}
+}
+
+// Helper functions
+
+func (h *OrderHandler) sendCartReminder(cart *models.Cart) error {
+	if len(cart.Items) == 0 {
+		return nil
+	}
+
+	name := cart.Items[0].Product.Name
+	message := fmt.Sprintf("You left %s in your cart.", name)
+	if len(cart.Items) > 1 {
+		message = fmt.Sprintf("You left %s and %d more item(s) in your cart.", name, len(cart.Items)-1)
+	}
+
+	final := cart.RemindersSent+1 == maxCartReminders
+	if percent := h.config.Orders.CartReminderCouponPercent; final && percent > 0 {
+		coupon, err := coupons.Issue(cart.UserID, percent, cartReminderValidDays, "abandoned_cart")
+		if err != nil {
+			return err
+		}
+		message += fmt.Sprintf(" Use code %s for %.0f%% off if you check out in the next %d days.", coupon.Code, percent, cartReminderValidDays)
+	}
+
+	if err := notifications.SendWithLink(cart.UserID, models.NotificationOrder, "Still thinking it over?", message, "playful://cart"); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return database.DB.Model(cart).Updates(map[string]interface{}{
+		"reminders_sent":   cart.RemindersSent + 1,
+		"last_reminded_at": now,
+	}).Error
+}
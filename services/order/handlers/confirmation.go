@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/escrow"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// @Summary Confirm order receipt
+// @Description Confirm that a delivered order was received, releasing payment to the seller (buyer only, own orders)
+// @Tags orders
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Success 200 {object} utils.Response{data=models.Order}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /orders/{id}/confirm-receipt [post]
+func (h *OrderHandler) ConfirmReceipt(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid order ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var order models.Order
+	if err := database.DB.First(&order, orderID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Order not found")
+	}
+
+	if order.BuyerID != userID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only confirm your own orders", nil)
+	}
+
+	if order.Status != models.OrderDelivered {
+		return utils.ValidationErrorResponse(c, "Only delivered orders can be confirmed")
+	}
+
+	if order.ReceiptConfirmedAt != nil {
+		return utils.ValidationErrorResponse(c, "Order receipt has already been confirmed")
+	}
+
+	if err := h.confirmReceipt(&order, false); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to confirm receipt", err)
+	}
+
+	return utils.SuccessResponse(c, "Order receipt confirmed successfully", order)
+}
+
+// AutoConfirmDeliveredOrders confirms receipt for orders delivered more than
+// the configured number of days ago. Run periodically by the scheduler.
+func (h *OrderHandler) AutoConfirmDeliveredOrders() {
+	cutoff := time.Now().AddDate(0, 0, -h.config.Orders.AutoConfirmDays)
+
+	var orders []models.Order
+	database.DB.Where("status = ? AND receipt_confirmed_at IS NULL AND delivered_at <= ?", models.OrderDelivered, cutoff).
+		Limit(500).
+		Find(&orders)
+
+	for i := range orders {
+		if err := h.confirmReceipt(&orders[i], true); err != nil {
+			log.Printf("Failed to auto-confirm order %s: %v", orders[i].ID, err)
+		}
+	}
+}
+
+// confirmReceipt marks the order as received and releases escrow
+func (h *OrderHandler) confirmReceipt(order *models.Order, auto bool) error {
+	now := time.Now()
+	if err := database.DB.Model(order).Updates(map[string]interface{}{
+		"receipt_confirmed_at": now,
+		"auto_confirmed":       auto,
+	}).Error; err != nil {
+		return err
+	}
+
+	if err := escrow.Release(order.ID, h.config.Payments.PlatformFeePercent); err != nil {
+		log.Printf("Escrow not released for order %s: %v", order.ID, err)
+	}
+
+	h.scheduleReviewRequest(order)
+
+	if auto {
+		notifications.Send(order.BuyerID, models.NotificationOrder, "Order receipt confirmed",
+			fmt.Sprintf("Order %s was confirmed as received automatically %d days after delivery.", order.OrderNumber, h.config.Orders.AutoConfirmDays))
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"testing"
+
+	"playful-marketplace/shared/contracts"
+	"playful-marketplace/shared/contracts/contracttest"
+	"playful-marketplace/shared/models"
+
+	"github.com/google/uuid"
+)
+
+func TestPaymentContract(t *testing.T) {
+	pact := contracttest.Load(t, "order", "payment")
+
+	order := models.Order{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		Status:    models.OrderPending,
+		Region:    "ET",
+		Buyer:     models.User{Phone: "+251911000000"},
+	}
+	req := paymentRequest(&order)
+	if req == nil {
+		t.Fatal("pending order has no payment request")
+	}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("payment request isn't valid: %v", err)
+	}
+	pact.Interaction(t, "initiate a mobile payment for a pending order").AssertRequest(t, req)
+
+	cash := *req
+	cash.Method, cash.Phone = models.PaymentCash, ""
+	pact.Interaction(t, "initiate a cash on delivery payment").AssertRequest(t, cash)
+
+	// The status endpoint takes the transaction ID the payment service returns
+	var resp contracts.PaymentResponse
+	pact.Interaction(t, "initiate a mobile payment for a pending order").DecodeResponse(t, &resp)
+	if resp.TransactionID == "" {
+		t.Fatal("payment response has no transaction ID")
+	}
+}
+
+func TestPaymentRequestOnlyWhileUnpaid(t *testing.T) {
+	tests := []struct {
+		name  string
+		order models.Order
+		want  bool
+	}{
+		{"pending", models.Order{Status: models.OrderPending}, true},
+		{"failed payment", models.Order{Status: models.OrderPending, Payment: &models.Payment{Status: models.PaymentFailed}}, true},
+		{"payment in progress", models.Order{Status: models.OrderPending, Payment: &models.Payment{Status: models.PaymentPending}}, false},
+		{"confirmed", models.Order{Status: models.OrderConfirmed}, false},
+	}
+	for _, tt := range tests {
+		if got := paymentRequest(&tt.order) != nil; got != tt.want {
+			t.Errorf("%s: got payment request %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPaymentRequestSkipsInvalidPhone(t *testing.T) {
+	order := models.Order{Status: models.OrderPending, Region: "ET", ContactPhone: "0911000000"}
+	if req := paymentRequest(&order); req == nil || req.Phone != "" {
+		t.Fatalf("got %+v, want a request without the phone", req)
+	}
+}
+
+func TestGamificationContract(t *testing.T) {
+	pact := contracttest.Load(t, "order", "gamification")
+	userID, orderID := uuid.New(), uuid.New()
+
+	delivered := contracts.AwardXPRequest{UserID: userID, Amount: 50, Reason: "Order Completed", Reference: orderID.String()}
+	pact.Interaction(t, "award XP for a delivered order").AssertRequest(t, delivered)
+
+	firstOrder := contracts.AwardXPRequest{UserID: userID, Amount: 25, Reason: firstOrderReason}
+	pact.Interaction(t, "award XP without a reference").AssertRequest(t, firstOrder)
+
+	for _, req := range []contracts.AwardXPRequest{delivered, firstOrder} {
+		if err := req.Validate(); err != nil {
+			t.Errorf("%s: %v", req.Reason, err)
+		}
+	}
+}
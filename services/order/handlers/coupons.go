@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"playful-marketplace/shared/coupons"
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type CreateCouponRequest struct {
+	Code            string     `json:"code"` // Generated when empty
+	DiscountPercent float64    `json:"discount_percent" validate:"required"`
+	MaxDiscount     float64    `json:"max_discount"` // 0 means no cap
+	UsageLimit      int        `json:"usage_limit" validate:"required,min=1"`
+	ExpiresAt       *time.Time `json:"expires_at"`
+}
+
+type CouponAnalytics struct {
+	Coupon models.Coupon `json:"coupon"`
+	Stats  coupons.Stats `json:"stats"`
+}
+
+type CouponListResponse struct {
+	Coupons []CouponAnalytics `json:"coupons"`
+	Total   int64             `json:"total"`
+	Page    int               `json:"page"`
+	Limit   int               `json:"limit"`
+}
+
+// @Summary Create coupon
+// @Description Create a shared coupon code. Seller coupons only discount the seller's own items and are funded from their payout; admin coupons apply to the whole order.
+// @Tags coupons
+// @Security BearerAuth
+// @Param request body CreateCouponRequest true "Coupon"
+// @Success 201 {object} utils.Response{data=models.Coupon}
+// @Failure 400 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /coupons [post]
+func (h *OrderHandler) CreateCoupon(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req CreateCouponRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.DiscountPercent <= 0 || req.DiscountPercent > 100 {
+		return utils.ValidationErrorResponse(c, "Discount must be between 0 and 100 percent")
+	}
+	if req.MaxDiscount < 0 {
+		return utils.ValidationErrorResponse(c, "Max discount cannot be negative")
+	}
+	if req.UsageLimit < 1 {
+		return utils.ValidationErrorResponse(c, "Usage limit must be at least 1")
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return utils.ValidationErrorResponse(c, "Expiry must be in the future")
+	}
+
+	var sellerID *uuid.UUID
+	if role, _ := c.Locals("user_role").(models.UserRole); role == models.RoleSeller {
+		sellerID = &userID
+	}
+
+	coupon, err := coupons.IssueShared(sellerID, req.Code, req.DiscountPercent, req.MaxDiscount, req.UsageLimit, req.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, coupons.ErrCodeTaken) {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "Coupon code is already in use", nil)
+		}
+		return utils.InternalServerErrorResponse(c, "Failed to create coupon", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Coupon created successfully",
+		Data:    coupon,
+	})
+}
+
+// @Summary Get coupon campaigns
+// @Description List coupons with redemptions, attributed revenue and new vs returning buyers. Sellers see their own coupons; admins see all and can filter by seller.
+// @Tags coupons
+// @Security BearerAuth
+// @Param seller_id query string false "Filter by seller (admin only)"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} utils.Response{data=CouponListResponse}
+// @Router /coupons [get]
+func (h *OrderHandler) GetCoupons(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+
+	if page < 1 {
+		page = 1
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	offset := (page - 1) * limit
+
+	query := database.DB.Model(&models.Coupon{})
+	if role, _ := c.Locals("user_role").(models.UserRole); role == models.RoleSeller {
+		query = query.Where("seller_id = ?", userID)
+	} else if sellerID := c.Query("seller_id"); sellerID != "" {
+		query = query.Where("seller_id = ?", sellerID)
+	} else {
+		// Single-use coupons from campaigns and cart reminders would swamp the list
+		query = query.Where("user_id IS NULL")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var list []models.Coupon
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&list).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get coupons", err)
+	}
+
+	ids := make([]uuid.UUID, len(list))
+	for i, coupon := range list {
+		ids[i] = coupon.ID
+	}
+
+	stats := map[uuid.UUID]coupons.Stats{}
+	if len(ids) > 0 {
+		var err error
+		if stats, err = coupons.GetStats(ids); err != nil {
+			return utils.InternalServerErrorResponse(c, "Failed to get coupon stats", err)
+		}
+	}
+
+	results := make([]CouponAnalytics, len(list))
+	for i, coupon := range list {
+		results[i] = CouponAnalytics{Coupon: coupon, Stats: stats[coupon.ID]}
+	}
+
+	response := CouponListResponse{
+		Coupons: results,
+		Total:   total,
+		Page:    page,
+		Limit:   limit,
+	}
+
+	return utils.SuccessResponse(c, "Coupons retrieved successfully", response)
+}
+
+// @Summary Get coupon analytics
+// @Description Get redemptions, attributed revenue and new vs returning buyers for a coupon (issuing seller or admin)
+// @Tags coupons
+// @Security BearerAuth
+// @Param id path string true "Coupon ID"
+// @Success 200 {object} utils.Response{data=CouponAnalytics}
+// @Failure 404 {object} utils.Problem
+// @Router /coupons/{id}/analytics [get]
+func (h *OrderHandler) GetCouponAnalytics(c *fiber.Ctx) error {
+	couponID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid coupon ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var coupon models.Coupon
+	if err := database.DB.First(&coupon, couponID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Coupon not found")
+	}
+
+	if role, _ := c.Locals("user_role").(models.UserRole); role == models.RoleSeller &&
+		(coupon.SellerID == nil || *coupon.SellerID != userID) {
+		return utils.NotFoundResponse(c, "Coupon not found")
+	}
+
+	stats, err := coupons.GetStats([]uuid.UUID{coupon.ID})
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get coupon stats", err)
+	}
+
+	response := CouponAnalytics{
+		Coupon: coupon,
+		Stats:  stats[coupon.ID],
+	}
+
+	return utils.SuccessResponse(c, "Coupon analytics retrieved successfully", response)
+}
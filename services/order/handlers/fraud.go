@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/inventory"
+	"playful-marketplace/shared/invoices"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type FraudReviewRequest struct {
+	Decision string `json:"decision" validate:"required"` // approve or reject
+	Notes    string `json:"notes"`
+}
+
+type FraudReviewListResponse struct {
+	Assessments []models.FraudAssessment `json:"assessments"`
+	Total       int64                    `json:"total"`
+	Page        int                      `json:"page"`
+	Limit       int                      `json:"limit"`
+}
+
+// @Summary Get fraud review queue
+// @Description Get orders held for manual fraud review (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param status query string false "Filter by review status" default(pending)
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} utils.Response{data=FraudReviewListResponse}
+// @Failure 403 {object} utils.Problem
+// @Router /admin/fraud/reviews [get]
+func (h *OrderHandler) GetFraudReviews(c *fiber.Ctx) error {
+	status := c.Query("status", string(models.FraudReviewPending))
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+
+	if page < 1 {
+		page = 1
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	offset := (page - 1) * limit
+
+	query := database.DB.Model(&models.FraudAssessment{}).Where("status = ?", status)
+
+	var total int64
+	query.Count(&total)
+
+	var assessments []models.FraudAssessment
+	if err := query.Preload("Order.Items").Preload("User").
+		Order("score DESC, created_at ASC").
+		Offset(offset).
+		Limit(limit).
+		Find(&assessments).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get fraud reviews", err)
+	}
+
+	response := FraudReviewListResponse{
+		Assessments: assessments,
+		Total:       total,
+		Page:        page,
+		Limit:       limit,
+	}
+
+	return utils.SuccessResponse(c, "Fraud reviews retrieved successfully", response)
+}
+
+// @Summary Review held order
+// @Description Approve or reject an order held for fraud review (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Assessment ID"
+// @Param request body FraudReviewRequest true "Review decision"
+// @Success 200 {object} utils.Response{data=models.FraudAssessment}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /admin/fraud/reviews/{id} [post]
+func (h *OrderHandler) ReviewFraudAssessment(c *fiber.Ctx) error {
+	assessmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid assessment ID")
+	}
+
+	adminID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req FraudReviewRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.Decision != "approve" && req.Decision != "reject" {
+		return utils.ValidationErrorResponse(c, "Decision must be 'approve' or 'reject'")
+	}
+
+	var assessment models.FraudAssessment
+	if err := database.DB.First(&assessment, assessmentID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Assessment not found")
+	}
+
+	if assessment.Status != models.FraudReviewPending {
+		return utils.ValidationErrorResponse(c, "Assessment has already been reviewed")
+	}
+
+	var order models.Order
+	if err := database.DB.Preload("Items").First(&order, assessment.OrderID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Order not found")
+	}
+
+	// Orders held at settlement have already been paid for
+	var paid int64
+	database.DB.Model(&models.Payment{}).Where("order_id = ? AND status = ?", order.ID, models.PaymentCompleted).Count(&paid)
+
+	now := time.Now()
+	reviewStatus := models.FraudReviewApproved
+	orderStatus := models.OrderPending
+	if paid > 0 {
+		orderStatus = models.OrderConfirmed
+	}
+	if req.Decision == "reject" {
+		reviewStatus = models.FraudReviewRejected
+		orderStatus = models.OrderCancelled
+	}
+
+	// A cancelled order stays cancelled and its stock is already back
+	wasCancelled := order.Status == models.OrderCancelled
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&assessment).Updates(map[string]interface{}{
+			"status":      reviewStatus,
+			"reviewed_by": adminID,
+			"reviewed_at": now,
+			"notes":       req.Notes,
+		}).Error; err != nil {
+			return err
+		}
+
+		if wasCancelled {
+			return nil
+		}
+
+		if err := tx.Model(&order).Update("status", orderStatus).Error; err != nil {
+			return err
+		}
+
+		if orderStatus == models.OrderCancelled {
+			return restoreOrderInventory(tx, &order)
+		}
+
+		// An unpaid order gets its full reservation time back to be paid
+		return inventory.ExtendReservations(tx, order.ID, h.config.Orders.ReservationTTL)
+	})
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to review assessment", err)
+	}
+
+	// Paid orders are invoiced once they're confirmed
+	if orderStatus == models.OrderConfirmed && !wasCancelled {
+		if _, err := invoices.Issue(order.ID); err != nil {
+			log.Printf("Failed to issue invoices for order %s: %v", order.ID, err)
+		}
+	}
+
+	// Restored stock, or a released hold, can fill waiting backorders
+	for _, item := range order.Items {
+		go inventory.AllocateItemBackorders(item.ProductID, item.VariantID)
+	}
+
+	return utils.SuccessResponse(c, "Assessment reviewed successfully", assessment)
+}
+
+// restoreOrderInventory returns stock for a cancelled order and reverses the buyer's spend
+func restoreOrderInventory(tx *gorm.DB, order *models.Order) error {
+	if _, err := inventory.ReleaseReservations(tx, order.ID, models.ReservationHeld, models.ReservationConfirmed); err != nil {
+		return err
+	}
+
+	// Orders placed before reservations restock item by item
+	var reserved []uuid.UUID
+	if err := tx.Model(&models.StockReservation{}).Where("order_id = ?", order.ID).Pluck("order_item_id", &reserved).Error; err != nil {
+		return err
+	}
+	isReserved := make(map[uuid.UUID]bool, len(reserved))
+	for _, id := range reserved {
+		isReserved[id] = true
+	}
+	for _, item := range order.Items {
+		// Backorders never took stock
+		if item.AwaitingStock || isReserved[item.ID] {
+			continue
+		}
+		if err := inventory.Restock(tx, item.ProductID, item.VariantID, item.Quantity); err != nil {
+			return err
+		}
+	}
+
+	return tx.Model(&models.User{}).Where("id = ?", order.BuyerID).
+		Update("total_spent", gorm.Expr("total_spent - ?", order.TotalAmount)).Error
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type PickListItem struct {
+	ProductID  uuid.UUID `json:"product_id"`
+	Name       string    `json:"name"`
+	SKU        string    `json:"sku"`
+	Quantity   int       `json:"quantity"`
+	OrderCount int       `json:"order_count"`
+}
+
+type PickListResponse struct {
+	SellerID   uuid.UUID      `json:"seller_id"`
+	Date       string         `json:"date"`
+	Items      []PickListItem `json:"items"`
+	TotalUnits int            `json:"total_units"`
+}
+
+type PackingSlipItem struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Name      string    `json:"name"`
+	SKU       string    `json:"sku"`
+	Quantity  int       `json:"quantity"`
+	Price     float64   `json:"price,omitempty"` // Hidden on gift slips
+}
+
+type PackingSlipResponse struct {
+	OrderNumber     string            `json:"order_number"`
+	OrderDate       time.Time         `json:"order_date"`
+	BuyerName       string            `json:"buyer_name"`
+	BuyerPhone      string            `json:"buyer_phone"`
+	ShippingAddress string            `json:"shipping_address"`
+	Notes           string            `json:"notes"`
+	IsGift          bool              `json:"is_gift"`
+	GiftMessage     string            `json:"gift_message,omitempty"`
+	Items           []PackingSlipItem `json:"items"`
+	TotalUnits      int               `json:"total_units"`
+}
+
+// Order statuses that still need to be picked and packed
+var fulfillableStatuses = []models.OrderStatus{models.OrderConfirmed, models.OrderProcessing}
+
+// @Summary Get seller pick list
+// @Description Aggregate items to pick across a day's confirmed and processing orders (seller only, own orders)
+// @Tags fulfillment
+// @Security BearerAuth
+// @Param id path string true "Seller ID"
+// @Param date query string false "Order date (YYYY-MM-DD)" default(today)
+// @Success 200 {object} utils.Response{data=PickListResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Router /sellers/{id}/pick-list [get]
+func (h *OrderHandler) GetPickList(c *fiber.Ctx) error {
+	sellerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid seller ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok || userID != sellerID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only view your own pick list", nil)
+	}
+
+	day := time.Now().Truncate(24 * time.Hour)
+	if dateParam := c.Query("date"); dateParam != "" {
+		day, err = time.Parse("2006-01-02", dateParam)
+		if err != nil {
+			return utils.ValidationErrorResponse(c, "Date must be in YYYY-MM-DD format")
+		}
+	}
+
+	var items []PickListItem
+	if err := database.DB.Model(&models.OrderItem{}).
+		Select("products.id AS product_id, products.name, products.sku, SUM(order_items.quantity) AS quantity, COUNT(DISTINCT orders.id) AS order_count").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Joins("JOIN products ON products.id = order_items.product_id").
+		Where("products.seller_id = ? AND orders.status IN ? AND orders.created_at >= ? AND orders.created_at < ?",
+			sellerID, fulfillableStatuses, day, day.AddDate(0, 0, 1)).
+		Where("order_items.status = ?", models.ItemPending).
+		Group("products.id, products.name, products.sku").
+		Order("products.name ASC").
+		Scan(&items).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to build pick list", err)
+	}
+
+	totalUnits := 0
+	for _, item := range items {
+		totalUnits += item.Quantity
+	}
+
+	response := PickListResponse{
+		SellerID:   sellerID,
+		Date:       day.Format("2006-01-02"),
+		Items:      items,
+		TotalUnits: totalUnits,
+	}
+
+	return utils.SuccessResponse(c, "Pick list retrieved successfully", response)
+}
+
+// @Summary Get packing slip
+// @Description Generate a packing slip with the seller's items for an order (seller only)
+// @Tags fulfillment
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Success 200 {object} utils.Response{data=PackingSlipResponse}
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /orders/{id}/packing-slip [get]
+func (h *OrderHandler) GetPackingSlip(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid order ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var order models.Order
+	if err := database.DB.Preload("Buyer").Preload("Items.Product").First(&order, orderID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Order not found")
+	}
+
+	// Only include this seller's items
+	var items []PackingSlipItem
+	totalUnits := 0
+	for _, item := range order.Items {
+		if item.Product.SellerID != userID {
+			continue
+		}
+		slipItem := PackingSlipItem{
+			ProductID: item.ProductID,
+			Name:      item.Product.Name,
+			SKU:       item.Product.SKU,
+			Quantity:  item.Quantity,
+		}
+		if !order.IsGift {
+			slipItem.Price = item.Price
+		}
+		items = append(items, slipItem)
+		totalUnits += item.Quantity
+	}
+
+	if len(items) == 0 {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only view packing slips for orders containing your products", nil)
+	}
+
+	response := PackingSlipResponse{
+		OrderNumber:     order.OrderNumber,
+		OrderDate:       order.CreatedAt,
+		BuyerName:       order.Buyer.Name,
+		BuyerPhone:      order.Buyer.Phone,
+		ShippingAddress: order.ShippingAddress,
+		Notes:           order.Notes,
+		Items:           items,
+		TotalUnits:      totalUnits,
+	}
+
+	// Gift slips are addressed to the recipient
+	if order.IsGift {
+		response.IsGift = true
+		response.BuyerName = order.GiftRecipientName
+		response.BuyerPhone = order.GiftRecipientPhone
+		response.GiftMessage = order.GiftMessage
+	}
+
+	return utils.SuccessResponse(c, "Packing slip generated successfully", response)
+}
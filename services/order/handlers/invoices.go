@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type InvoiceListResponse struct {
+	Invoices []models.Invoice `json:"invoices"`
+	Total    int64            `json:"total"`
+	Page     int              `json:"page"`
+	Limit    int              `json:"limit"`
+}
+
+// @Summary Get order invoices
+// @Description Get the sellers' invoices for a paid order. Buyers see every invoice on the order, sellers only their own.
+// @Tags orders
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Success 200 {object} utils.Response{data=[]models.Invoice}
+// @Failure 404 {object} utils.Problem
+// @Router /orders/{id}/invoices [get]
+func (h *OrderHandler) GetOrderInvoices(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid order ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var order models.Order
+	if err := database.DB.Preload("Items.Product").First(&order, orderID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Order not found")
+	}
+
+	if !h.isOrderParticipant(&order, userID) {
+		return utils.NotFoundResponse(c, "Order not found")
+	}
+
+	query := database.DB.Where("order_id = ?", orderID)
+	if order.BuyerID != userID {
+		query = query.Where("seller_id = ?", userID)
+	}
+
+	var invoices []models.Invoice
+	if err := query.Order("issued_at ASC").Find(&invoices).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get invoices", err)
+	}
+
+	return utils.SuccessResponse(c, "Invoices retrieved successfully", invoices)
+}
+
+// @Summary Get seller invoices
+// @Description Get a seller's invoices in number order, newest first (seller only, own invoices)
+// @Tags fulfillment
+// @Security BearerAuth
+// @Param id path string true "Seller ID"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} utils.Response{data=InvoiceListResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Router /sellers/{id}/invoices [get]
+func (h *OrderHandler) GetSellerInvoices(c *fiber.Ctx) error {
+	sellerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid seller ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok || userID != sellerID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only view your own invoices", nil)
+	}
+
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+
+	if page < 1 {
+		page = 1
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	offset := (page - 1) * limit
+
+	query := database.DB.Model(&models.Invoice{}).Where("seller_id = ?", sellerID)
+
+	var total int64
+	query.Count(&total)
+
+	var invoices []models.Invoice
+	if err := query.Order("number DESC").Offset(offset).Limit(limit).Find(&invoices).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get invoices", err)
+	}
+
+	response := InvoiceListResponse{
+		Invoices: invoices,
+		Total:    total,
+		Page:     page,
+		Limit:    limit,
+	}
+
+	return utils.SuccessResponse(c, "Invoices retrieved successfully", response)
+}
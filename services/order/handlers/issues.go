@@ -0,0 +1,504 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/escrow"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ReportIssueRequest struct {
+	Type        models.OrderIssueType `json:"type" validate:"required"` // item_missing, damaged, wrong_item, late or other
+	OrderItemID *uuid.UUID            `json:"order_item_id"`            // Required for item_missing, damaged and wrong_item
+	Quantity    int                   `json:"quantity"`                 // Affected quantity, defaults to the whole line
+	Description string                `json:"description"`
+}
+
+type RespondToIssueRequest struct {
+	Response string `json:"response" validate:"required"`
+}
+
+type ResolveIssueRequest struct {
+	Resolution string `json:"resolution" validate:"required"`
+}
+
+// Workflow each issue type is routed to
+var issueWorkflows = map[models.OrderIssueType]models.IssueWorkflow{
+	models.IssueItemMissing: models.WorkflowRefund,
+	models.IssueDamaged:     models.WorkflowRefund,
+	models.IssueWrongItem:   models.WorkflowRefund,
+	models.IssueLate:        models.WorkflowDispute,
+	models.IssueOther:       models.WorkflowSupport,
+}
+
+var issueLabels = map[models.OrderIssueType]string{
+	models.IssueItemMissing: "Item missing",
+	models.IssueDamaged:     "Item damaged",
+	models.IssueWrongItem:   "Wrong item received",
+	models.IssueLate:        "Order late",
+	models.IssueOther:       "Order issue",
+}
+
+// @Summary Report order issue
+// @Description Report a problem with an order in one step. Missing, damaged and wrong items open a refund request with the seller, late orders open a dispute that keeps payment held, and anything else opens a support ticket. The seller must respond and the issue be resolved within the SLA.
+// @Tags issues
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param request body ReportIssueRequest true "Report issue request"
+// @Success 201 {object} utils.Response{data=models.OrderIssue}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /orders/{id}/issues [post]
+func (h *OrderHandler) ReportIssue(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid order ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req ReportIssueRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	workflow, ok := issueWorkflows[req.Type]
+	if !ok {
+		return utils.ValidationErrorResponse(c, "Issue type must be item_missing, damaged, wrong_item, late or other")
+	}
+
+	var order models.Order
+	if err := database.DB.Preload("Items.Product").First(&order, orderID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Order not found")
+	}
+
+	if order.BuyerID != userID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only report issues with your own orders", nil)
+	}
+
+	var item *models.OrderItem
+	if req.OrderItemID != nil {
+		for i := range order.Items {
+			if order.Items[i].ID == *req.OrderItemID {
+				item = &order.Items[i]
+				break
+			}
+		}
+		if item == nil {
+			return utils.NotFoundResponse(c, "Order item not found")
+		}
+	}
+
+	switch req.Type {
+	case models.IssueItemMissing, models.IssueDamaged, models.IssueWrongItem:
+		if order.Status != models.OrderDelivered {
+			return utils.ValidationErrorResponse(c, "This issue can only be reported once the order is delivered")
+		}
+		if item == nil {
+			return utils.ValidationErrorResponse(c, "Order item is required for this issue")
+		}
+	case models.IssueLate:
+		if order.Status == models.OrderDelivered || order.Status == models.OrderCancelled {
+			return utils.ValidationErrorResponse(c, "Only undelivered orders can be reported late")
+		}
+		if due := h.expectedBy(&order); time.Now().Before(due) {
+			return utils.ValidationErrorResponse(c, fmt.Sprintf("Order isn't late yet, it's expected by %s", due.Format("2006-01-02")))
+		}
+	}
+
+	// Refunds can't exceed what hasn't already been returned
+	quantity := 0
+	if workflow == models.WorkflowRefund {
+		var returnedQuantity int64
+		database.DB.Model(&models.ReturnRequest{}).
+			Where("order_item_id = ? AND status != ?", item.ID, models.ReturnRejected).
+			Select("COALESCE(SUM(quantity), 0)").
+			Scan(&returnedQuantity)
+
+		remaining := item.Quantity - int(returnedQuantity)
+		quantity = req.Quantity
+		if quantity == 0 {
+			quantity = remaining
+		}
+		if quantity < 1 || quantity > remaining {
+			return utils.ValidationErrorResponse(c, fmt.Sprintf("Only %d of this item can still be refunded", remaining))
+		}
+	}
+
+	sellerID, err := issueSeller(&order, item)
+	if err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	// One open issue of each type per order line
+	duplicate := database.DB.Model(&models.OrderIssue{}).
+		Where("order_id = ? AND type = ? AND status = ?", order.ID, req.Type, models.IssueOpen)
+	if item != nil {
+		duplicate = duplicate.Where("order_item_id = ?", item.ID)
+	}
+	var count int64
+	duplicate.Count(&count)
+	if count > 0 {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "You already reported this issue", nil)
+	}
+
+	now := time.Now()
+	issue := models.OrderIssue{
+		BaseModel:   models.BaseModel{ID: uuid.New()},
+		OrderID:     order.ID,
+		BuyerID:     userID,
+		SellerID:    sellerID,
+		Type:        req.Type,
+		Workflow:    workflow,
+		Status:      models.IssueOpen,
+		Description: req.Description,
+		RespondBy:   now.Add(time.Duration(h.config.Orders.IssueResponseHours) * time.Hour),
+		ResolveBy:   now.Add(time.Duration(h.config.Orders.IssueResolutionHours) * time.Hour),
+	}
+	if item != nil {
+		issue.OrderItemID = &item.ID
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if workflow == models.WorkflowRefund {
+			returnRequest, err := h.openIssueReturn(tx, &order, item, quantity, req)
+			if err != nil {
+				return err
+			}
+			issue.ReturnID = &returnRequest.ID
+		}
+		return tx.Create(&issue).Error
+	})
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to report issue", err)
+	}
+
+	notifications.Send(sellerID, models.NotificationOrder, issueLabels[req.Type],
+		fmt.Sprintf("A buyer reported a problem with order %s. Please respond by %s.",
+			order.OrderNumber, issue.RespondBy.Format("2006-01-02 15:04")))
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Issue reported successfully",
+		Data:    issue,
+	})
+}
+
+// @Summary Get order issues
+// @Description Get the issues reported on an order (buyer or seller)
+// @Tags issues
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Success 200 {object} utils.Response{data=[]models.OrderIssue}
+// @Router /orders/{id}/issues [get]
+func (h *OrderHandler) GetOrderIssues(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid order ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var issues []models.OrderIssue
+	if err := database.DB.Where("order_id = ? AND (buyer_id = ? OR seller_id = ?)", orderID, userID, userID).
+		Order("created_at DESC").Find(&issues).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get issues", err)
+	}
+
+	return utils.SuccessResponse(c, "Issues retrieved successfully", issues)
+}
+
+// @Summary Respond to order issue
+// @Description Reply to an issue a buyer reported on one of your orders, meeting the response SLA (seller only)
+// @Tags issues
+// @Security BearerAuth
+// @Param id path string true "Issue ID"
+// @Param request body RespondToIssueRequest true "Respond to issue request"
+// @Success 200 {object} utils.Response{data=models.OrderIssue}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Router /issues/{id}/respond [post]
+func (h *OrderHandler) RespondToIssue(c *fiber.Ctx) error {
+	issueID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid issue ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req RespondToIssueRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+	if req.Response == "" {
+		return utils.ValidationErrorResponse(c, "Response is required")
+	}
+
+	var issue models.OrderIssue
+	if err := database.DB.Preload("Order").First(&issue, issueID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Issue not found")
+	}
+
+	if issue.SellerID != userID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only respond to issues on your own orders", nil)
+	}
+	if issue.Status != models.IssueOpen {
+		return utils.ValidationErrorResponse(c, "Issue is already resolved")
+	}
+
+	updates := map[string]interface{}{"response": req.Response}
+	if issue.RespondedAt == nil {
+		updates["responded_at"] = time.Now()
+	}
+	if err := database.DB.Model(&issue).Updates(updates).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to respond to issue", err)
+	}
+
+	notifications.Send(issue.BuyerID, models.NotificationOrder, "Seller responded",
+		fmt.Sprintf("The seller responded to your issue with order %s: %s", issue.Order.OrderNumber, req.Response))
+
+	return utils.SuccessResponse(c, "Issue response sent successfully", issue)
+}
+
+// @Summary Get issue queue
+// @Description Get reported order issues for the support team, most urgent first (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param status query string false "Filter by status" default(open)
+// @Param workflow query string false "Filter by workflow"
+// @Param breached query bool false "Only issues past an SLA"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} utils.Response{data=[]models.OrderIssue}
+// @Router /admin/issues [get]
+func (h *OrderHandler) GetIssueQueue(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := database.DB.Model(&models.OrderIssue{}).Where("status = ?", c.Query("status", string(models.IssueOpen)))
+	if workflow := c.Query("workflow"); workflow != "" {
+		query = query.Where("workflow = ?", workflow)
+	}
+	if c.QueryBool("breached") {
+		query = query.Where("sla_breached = ?", true)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var issues []models.OrderIssue
+	if err := query.Preload("Order").Order("resolve_by ASC").
+		Offset((page - 1) * limit).Limit(limit).Find(&issues).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get issues", err)
+	}
+
+	return utils.SuccessResponse(c, "Issues retrieved successfully", fiber.Map{
+		"issues": issues,
+		"total":  total,
+		"page":   page,
+		"limit":  limit,
+	})
+}
+
+// @Summary Resolve order issue
+// @Description Close a dispute or support ticket with a resolution. Resolving a dispute releases held payment if the buyer has confirmed receipt (admin only).
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Issue ID"
+// @Param request body ResolveIssueRequest true "Resolve issue request"
+// @Success 200 {object} utils.Response{data=models.OrderIssue}
+// @Failure 400 {object} utils.Problem
+// @Router /admin/issues/{id}/resolve [post]
+func (h *OrderHandler) ResolveIssue(c *fiber.Ctx) error {
+	issueID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid issue ID")
+	}
+
+	adminID, _ := c.Locals("user_id").(uuid.UUID)
+
+	var req ResolveIssueRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+	if req.Resolution == "" {
+		return utils.ValidationErrorResponse(c, "Resolution is required")
+	}
+
+	var issue models.OrderIssue
+	if err := database.DB.Preload("Order").First(&issue, issueID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Issue not found")
+	}
+	if issue.Status != models.IssueOpen {
+		return utils.ValidationErrorResponse(c, "Issue is already resolved")
+	}
+
+	if err := h.resolveIssue(database.DB, &issue, req.Resolution, &adminID); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to resolve issue", err)
+	}
+
+	// Funds held by the dispute can now go to the seller
+	if issue.Workflow == models.WorkflowDispute && issue.Order.ReceiptConfirmedAt != nil {
+		if err := escrow.Release(issue.OrderID, h.config.Payments.PlatformFeePercent); err != nil {
+			log.Printf("Escrow not released for order %s: %v", issue.OrderID, err)
+		}
+	}
+
+	return utils.SuccessResponse(c, "Issue resolved successfully", issue)
+}
+
+// FlagIssueSLABreaches marks open issues that missed their response or
+// resolution deadline so support can prioritise them. Run periodically by
+// the scheduler.
+func (h *OrderHandler) FlagIssueSLABreaches() {
+	now := time.Now()
+
+	var issues []models.OrderIssue
+	database.DB.Preload("Order").
+		Where("status = ? AND sla_breached = ? AND (resolve_by <= ? OR (responded_at IS NULL AND respond_by <= ?))",
+			models.IssueOpen, false, now, now).
+		Limit(500).
+		Find(&issues)
+
+	for _, issue := range issues {
+		if err := database.DB.Model(&issue).Update("sla_breached", true).Error; err != nil {
+			log.Printf("Failed to flag SLA breach for issue %s: %v", issue.ID, err)
+			continue
+		}
+		if issue.RespondedAt == nil {
+			notifications.Send(issue.SellerID, models.NotificationOrder, "Issue response overdue",
+				fmt.Sprintf("You haven't responded to the issue reported on order %s. It has been escalated to support.", issue.Order.OrderNumber))
+		}
+	}
+}
+
+// Helper functions
+
+// expectedBy returns when an undelivered order should have arrived
+func (h *OrderHandler) expectedBy(order *models.Order) time.Time {
+	due := order.CreatedAt.AddDate(0, 0, h.config.Orders.LateAfterDays)
+	for _, item := range order.Items {
+		if item.ExpectedAt != nil && item.ExpectedAt.After(due) {
+			due = *item.ExpectedAt
+		}
+	}
+	return due
+}
+
+// issueSeller works out which seller an issue is against
+func issueSeller(order *models.Order, item *models.OrderItem) (uuid.UUID, error) {
+	if item != nil {
+		return item.Product.SellerID, nil
+	}
+
+	var sellerID uuid.UUID
+	for _, orderItem := range order.Items {
+		if sellerID != uuid.Nil && orderItem.Product.SellerID != sellerID {
+			return uuid.Nil, fmt.Errorf("order has items from several sellers, please choose an order item")
+		}
+		sellerID = orderItem.Product.SellerID
+	}
+	if sellerID == uuid.Nil {
+		return uuid.Nil, fmt.Errorf("order has no items")
+	}
+	return sellerID, nil
+}
+
+// openIssueReturn opens the return request for a refund issue. The seller is
+// at fault, so final-sale rules and restocking fees don't apply.
+func (h *OrderHandler) openIssueReturn(tx *gorm.DB, order *models.Order, item *models.OrderItem, quantity int, req ReportIssueRequest) (*models.ReturnRequest, error) {
+	reason := issueLabels[req.Type]
+	if req.Description != "" {
+		reason += ": " + req.Description
+	}
+
+	returnRequest := models.ReturnRequest{
+		BaseModel:    models.BaseModel{ID: uuid.New()},
+		RMANumber:    h.generateRMANumber(),
+		OrderID:      order.ID,
+		OrderItemID:  item.ID,
+		BuyerID:      order.BuyerID,
+		SellerID:     item.Product.SellerID,
+		Quantity:     quantity,
+		Reason:       reason,
+		Status:       models.ReturnRequested,
+		RefundAmount: item.Price * float64(quantity),
+	}
+	if err := tx.Create(&returnRequest).Error; err != nil {
+		return nil, err
+	}
+	return &returnRequest, nil
+}
+
+// resolveIssue closes an issue and lets the buyer know the outcome
+func (h *OrderHandler) resolveIssue(db *gorm.DB, issue *models.OrderIssue, resolution string, resolvedBy *uuid.UUID) error {
+	now := time.Now()
+	if err := db.Model(issue).Updates(map[string]interface{}{
+		"status":      models.IssueResolved,
+		"resolution":  resolution,
+		"resolved_by": resolvedBy,
+		"resolved_at": now,
+	}).Error; err != nil {
+		return err
+	}
+
+	notifications.Send(issue.BuyerID, models.NotificationOrder, "Issue resolved",
+		fmt.Sprintf("Your issue with order %s was resolved: %s", issue.Order.OrderNumber, resolution))
+	return nil
+}
+
+// syncIssueWithReturn moves a refund issue along with its return request:
+// refunds resolve it and rejections escalate it to a dispute.
+func (h *OrderHandler) syncIssueWithReturn(returnRequest *models.ReturnRequest) {
+	var issue models.OrderIssue
+	if err := database.DB.Preload("Order").
+		Where("return_id = ? AND status = ?", returnRequest.ID, models.IssueOpen).
+		First(&issue).Error; err != nil {
+		return
+	}
+
+	switch returnRequest.Status {
+	case models.ReturnRefunded:
+		resolution := fmt.Sprintf("Refunded %.2f", returnRequest.RefundAmount)
+		if err := h.resolveIssue(database.DB, &issue, resolution, &returnRequest.SellerID); err != nil {
+			log.Printf("Failed to resolve issue %s: %v", issue.ID, err)
+		}
+	case models.ReturnRejected:
+		now := time.Now()
+		if err := database.DB.Model(&issue).Updates(map[string]interface{}{
+			"workflow":     models.WorkflowDispute,
+			"responded_at": now,
+			"resolve_by":   now.Add(time.Duration(h.config.Orders.IssueResolutionHours) * time.Hour),
+		}).Error; err != nil {
+			log.Printf("Failed to escalate issue %s: %v", issue.ID, err)
+			return
+		}
+		notifications.Send(issue.BuyerID, models.NotificationOrder, "Issue escalated",
+			fmt.Sprintf("The seller declined your refund for order %s. Our support team will review it.", issue.Order.OrderNumber))
+	}
+}
@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/jobs"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type CreateShipmentRequest struct {
+	ItemIDs        []uuid.UUID `json:"item_ids" validate:"required"`
+	Carrier        string      `json:"carrier"`
+	TrackingNumber string      `json:"tracking_number"`
+}
+
+type UpdateOrderItemStatusRequest struct {
+	Status models.OrderItemStatus `json:"status" validate:"required"` // backordered or pending
+}
+
+// @Summary Get order shipments
+// @Description List every shipment of an order with the items it contains (buyer or seller)
+// @Tags fulfillment
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Success 200 {object} utils.Response{data=[]models.Shipment}
+// @Failure 404 {object} utils.Problem
+// @Router /orders/{id}/shipments [get]
+func (h *OrderHandler) GetShipments(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid order ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var order models.Order
+	if err := database.DB.Preload("Items.Product").First(&order, orderID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Order not found")
+	}
+
+	if !h.isOrderParticipant(&order, userID) {
+		return utils.NotFoundResponse(c, "Order not found")
+	}
+
+	var shipments []models.Shipment
+	if err := database.DB.Preload("Items").Where("order_id = ?", orderID).Order("created_at ASC").Find(&shipments).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get shipments", err)
+	}
+
+	return utils.SuccessResponse(c, "Shipments retrieved successfully", shipments)
+}
+
+// @Summary Ship order items
+// @Description Ship some of an order's items as a partial shipment. The order status follows the item states (seller only, own items).
+// @Tags fulfillment
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param request body CreateShipmentRequest true "Items to ship"
+// @Success 201 {object} utils.Response{data=models.Shipment}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /orders/{id}/shipments [post]
+func (h *OrderHandler) CreatePartialShipment(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid order ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req CreateShipmentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if len(req.ItemIDs) == 0 {
+		return utils.ValidationErrorResponse(c, "At least one item is required")
+	}
+
+	var order models.Order
+	if err := database.DB.Preload("Items.Product").First(&order, orderID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Order not found")
+	}
+
+	if order.Status == models.OrderCancelled || order.Status == models.OrderOnHold || order.Status == models.OrderPending {
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("Cannot ship items of a %s order", order.Status))
+	}
+
+	requested := make(map[uuid.UUID]bool, len(req.ItemIDs))
+	for _, id := range req.ItemIDs {
+		requested[id] = true
+	}
+
+	var items []models.OrderItem
+	for _, item := range order.Items {
+		if !requested[item.ID] {
+			continue
+		}
+		if item.Product.SellerID != userID {
+			return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only ship your own items", nil)
+		}
+		if !isUnshipped(item.Status) {
+			return utils.ValidationErrorResponse(c, fmt.Sprintf("Item %s is already %s", item.Product.Name, item.Status))
+		}
+		if item.AwaitingStock {
+			return utils.ValidationErrorResponse(c, fmt.Sprintf("Item %s is waiting for stock", item.Product.Name))
+		}
+		items = append(items, item)
+	}
+	if len(items) != len(requested) {
+		return utils.NotFoundResponse(c, "One or more items are not part of this order")
+	}
+
+	shipment, err := h.createShipment(&order, items, req.Carrier, req.TrackingNumber)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create shipment", err)
+	}
+
+	if err := h.syncOrderStatus(order.ID); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update order status", err)
+	}
+
+	database.DB.Preload("Items").First(shipment, shipment.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Shipment created successfully",
+		Data:    shipment,
+	})
+}
+
+// @Summary Mark shipment delivered
+// @Description Mark one shipment and its items delivered, with proof of delivery (required for cash on delivery). The order is delivered once every item is (seller only).
+// @Tags fulfillment
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param shipment_id path string true "Shipment ID"
+// @Param request body DeliveryProofRequest false "Delivery proof"
+// @Success 200 {object} utils.Response{data=models.Shipment}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /orders/{id}/shipments/{shipment_id}/deliver [post]
+func (h *OrderHandler) DeliverShipment(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid order ID")
+	}
+
+	shipmentID, err := uuid.Parse(c.Params("shipment_id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid shipment ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req DeliveryProofRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return utils.ValidationErrorResponse(c, "Invalid request body")
+		}
+	}
+	req.ShipmentID = &shipmentID
+
+	var order models.Order
+	if err := database.DB.Preload("Items.Product").First(&order, orderID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Order not found")
+	}
+
+	if !h.isOrderSeller(&order, userID) {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only deliver orders containing your products", nil)
+	}
+
+	shipment, err := h.captureDeliveryProof(&order, userID, &req)
+	if err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	h.markItemsDelivered(order.ID, &shipment.ID, time.Now())
+
+	if err := h.syncOrderStatus(order.ID); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update order status", err)
+	}
+
+	database.DB.Preload("Items").First(shipment, shipment.ID)
+
+	return utils.SuccessResponse(c, "Shipment delivered", shipment)
+}
+
+// @Summary Update order item status
+// @Description Mark an item backordered, or back to pending once it can be fulfilled (seller only, own items)
+// @Tags fulfillment
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param item_id path string true "Order item ID"
+// @Param request body UpdateOrderItemStatusRequest true "Item status"
+// @Success 200 {object} utils.Response{data=models.OrderItem}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /orders/{id}/items/{item_id}/status [put]
+func (h *OrderHandler) UpdateOrderItemStatus(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid order ID")
+	}
+
+	itemID, err := uuid.Parse(c.Params("item_id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid item ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req UpdateOrderItemStatusRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	// Shipping and delivery go through shipments
+	if req.Status != models.ItemBackordered && req.Status != models.ItemPending {
+		return utils.ValidationErrorResponse(c, "Status must be backordered or pending")
+	}
+
+	var item models.OrderItem
+	if err := database.DB.Preload("Product").Where("id = ? AND order_id = ?", itemID, orderID).First(&item).Error; err != nil {
+		return utils.NotFoundResponse(c, "Order item not found")
+	}
+
+	if item.Product.SellerID != userID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only update your own items", nil)
+	}
+
+	if !isUnshipped(item.Status) {
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("Item is already %s", item.Status))
+	}
+
+	// Backorders and pre-orders are released automatically once stock is allocated
+	if item.AwaitingStock {
+		return utils.ValidationErrorResponse(c, "Item is waiting for stock and is allocated automatically when it arrives")
+	}
+
+	if err := database.DB.Model(&item).Update("status", req.Status).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update item status", err)
+	}
+
+	if req.Status == models.ItemBackordered {
+		var order models.Order
+		if err := database.DB.First(&order, orderID).Error; err == nil {
+			notifications.Send(order.BuyerID, models.NotificationOrder, "Item backordered",
+				fmt.Sprintf("%s from order %s is backordered and will ship separately once available.", item.Product.Name, order.OrderNumber))
+		}
+	}
+
+	if err := h.syncOrderStatus(orderID); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update order status", err)
+	}
+
+	return utils.SuccessResponse(c, "Item status updated successfully", item)
+}
+
+// Helper functions
+
+func isUnshipped(status models.OrderItemStatus) bool {
+	return status == models.ItemPending || status == models.ItemBackordered || status == models.ItemPreordered || status == ""
+}
+
+func unshippedItems(order *models.Order) []models.OrderItem {
+	var items []models.OrderItem
+	for _, item := range order.Items {
+		if isUnshipped(item.Status) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// deriveOrderStatus computes the order status from its item states, keeping
+// the current status while nothing has been fulfilled yet
+func deriveOrderStatus(current models.OrderStatus, items []models.OrderItem) models.OrderStatus {
+	var active, shipped, delivered, started int
+	for _, item := range items {
+		switch item.Status {
+		case models.ItemCancelled:
+			continue
+		case models.ItemDelivered:
+			delivered++
+			shipped++
+		case models.ItemShipped:
+			shipped++
+		case models.ItemBackordered:
+			started++
+		}
+		active++
+	}
+
+	switch {
+	case active == 0:
+		return models.OrderCancelled
+	case delivered == active:
+		return models.OrderDelivered
+	case shipped == active:
+		return models.OrderShipped
+	case shipped > 0 || started > 0:
+		return models.OrderProcessing
+	default:
+		return current
+	}
+}
+
+// syncOrderStatus updates the order status from its items and runs the
+// delivery side effects once the last item arrives
+func (h *OrderHandler) syncOrderStatus(orderID uuid.UUID) error {
+	var order models.Order
+	if err := database.DB.Preload("Items.Product").First(&order, orderID).Error; err != nil {
+		return err
+	}
+
+	status := deriveOrderStatus(order.Status, order.Items)
+	if status == order.Status {
+		return nil
+	}
+
+	updates := map[string]interface{}{"status": status}
+	if status == models.OrderDelivered && order.DeliveredAt == nil {
+		now := time.Now()
+		order.DeliveredAt = &now
+		updates["delivered_at"] = now
+	}
+	if err := database.DB.Model(&order).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	if status == models.OrderDelivered {
+		order.Status = status
+		h.onOrderDelivered(&order)
+	}
+	return nil
+}
+
+// markItemsDelivered marks one shipment, or every shipment when shipmentID is
+// nil, and its items delivered
+func (h *OrderHandler) markItemsDelivered(orderID uuid.UUID, shipmentID *uuid.UUID, at time.Time) {
+	shipments := database.DB.Model(&models.Shipment{}).Where("order_id = ? AND delivered_at IS NULL", orderID)
+	items := database.DB.Model(&models.OrderItem{}).Where("order_id = ? AND status <> ?", orderID, models.ItemCancelled)
+	if shipmentID != nil {
+		shipments = shipments.Where("id = ?", *shipmentID)
+		items = items.Where("shipment_id = ?", *shipmentID)
+	}
+	shipments.Update("delivered_at", at)
+	items.Update("status", models.ItemDelivered)
+}
+
+// onOrderDelivered awards XP and updates seller stats for a delivered order
+func (h *OrderHandler) onOrderDelivered(order *models.Order) {
+	if order.IsGift {
+		go notifications.SendSMS(order.GiftRecipientPhone, fmt.Sprintf("Your gift (order %s) has been delivered. Enjoy!", order.OrderNumber))
+	}
+	jobs.Enqueue(jobDeliveredOrder, deliveredOrderJob{OrderID: order.ID})
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/live"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type LiveTicketResponse struct {
+	Ticket    string `json:"ticket"`
+	URL       string `json:"url"`        // Open a WebSocket to this path with the ticket
+	ExpiresIn int    `json:"expires_in"` // Seconds
+}
+
+// liveTicket is kept in Redis until the connection it opens redeems it. The
+// session token lets the connection end when the session does.
+type liveTicket struct {
+	SellerID uuid.UUID `json:"seller_id"`
+	Token    string    `json:"token"`
+}
+
+// @Summary Get a live orders ticket
+// @Description Get a one-time ticket for opening the seller's live order dashboard. Browsers can't send an Authorization header with a WebSocket, so the connection authenticates with this ticket instead. It must be used within 30 seconds. (seller only, own dashboard)
+// @Tags fulfillment
+// @Security BearerAuth
+// @Param id path string true "Seller ID"
+// @Success 201 {object} utils.Response{data=LiveTicketResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Router /sellers/{id}/live-ticket [post]
+func (h *OrderHandler) CreateLiveTicket(c *fiber.Ctx) error {
+	sellerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid seller ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok || userID != sellerID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only watch your own orders", nil)
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create ticket", err)
+	}
+	ticket := hex.EncodeToString(raw)
+
+	session, ok := c.Locals("session").(*models.Session)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "Session not found")
+	}
+
+	if err := redis.Set(redis.LiveTicketKey(ticket), liveTicket{SellerID: sellerID, Token: session.Token}); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create ticket", err)
+	}
+
+	response := LiveTicketResponse{
+		Ticket:    ticket,
+		URL:       "/api/v1/live/orders?ticket=" + ticket,
+		ExpiresIn: int(redis.LiveTicketTTL.Seconds()),
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Live orders ticket created successfully",
+		Data:    response,
+	})
+}
+
+// @Summary Live order updates
+// @Description WebSocket pushing the seller's new orders and payment results as they happen, as JSON messages of the form {"type": "order.created" | "payment.completed" | "payment.failed", "order_id", "order_number", "order_status", "amount", "items", "at"}. Amount and items only count the seller's own items. A "connected" message is sent first. The connection is closed when the session the ticket was issued to is logged out, revoked or suspended.
+// @Tags fulfillment
+// @Param ticket query string true "Ticket from POST /sellers/{id}/live-ticket"
+// @Success 101
+// @Failure 401 {object} utils.Problem
+// @Failure 426 {object} utils.Problem
+// @Router /live/orders [get]
+func (h *OrderHandler) LiveOrders(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return utils.ErrorResponse(c, fiber.StatusUpgradeRequired, "Expected a WebSocket upgrade request", nil)
+	}
+
+	// Tickets are single use, so they're read and deleted in one step
+	var ticket liveTicket
+	if err := redis.Take(redis.LiveTicketKey(c.Query("ticket")), &ticket); err != nil {
+		return utils.UnauthorizedResponse(c, "Invalid or expired ticket")
+	}
+	if !h.liveSessionActive(ticket) {
+		return utils.UnauthorizedResponse(c, "Session expired or invalid")
+	}
+
+	c.Locals("live_ticket", ticket)
+	return c.Next()
+}
+
+// StreamLiveOrders serves the connection LiveOrders upgraded
+func (h *OrderHandler) StreamLiveOrders(conn *websocket.Conn) {
+	ticket, ok := conn.Locals("live_ticket").(liveTicket)
+	if !ok {
+		return
+	}
+
+	live.Stream(conn, ticket.SellerID, func() bool {
+		return h.liveSessionActive(ticket)
+	})
+}
+
+// liveSessionActive checks the session a live ticket was issued to is still
+// good: not expired, logged out or revoked, and its seller not suspended
+func (h *OrderHandler) liveSessionActive(ticket liveTicket) bool {
+	claims, err := utils.ValidateJWT(ticket.Token, h.config)
+	if err != nil || claims.UserID != ticket.SellerID {
+		return false
+	}
+
+	// While Redis is down the token is judged on its own, as the auth
+	// middleware does
+	if _, err := redis.GetSession(ticket.Token); err != nil && !errors.Is(err, redis.ErrUnavailable) {
+		return false
+	}
+
+	var seller models.User
+	if err := database.DB.Select("id", "is_active", "account_status", "suspended_until").
+		First(&seller, ticket.SellerID).Error; err != nil {
+		return false
+	}
+	return seller.IsActive && !seller.IsSuspended()
+}
@@ -1,45 +1,102 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"math"
 	"math/rand"
+	"strings"
 	"time"
 
+	"playful-marketplace/shared/activity"
+	"playful-marketplace/shared/analytics"
 	"playful-marketplace/shared/config"
+	"playful-marketplace/shared/contracts"
+	"playful-marketplace/shared/coupons"
 	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/fraud"
+	"playful-marketplace/shared/inventory"
+	"playful-marketplace/shared/jobs"
+	"playful-marketplace/shared/live"
 	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/referrals"
+	"playful-marketplace/shared/regions"
+	"playful-marketplace/shared/shipping"
 	"playful-marketplace/shared/utils"
+	"playful-marketplace/shared/webhooks"
+	"playful-marketplace/shared/xp"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OrderHandler struct {
 	config *config.Config
 }
 
+// Background job kinds
+const (
+	jobFirstOrderXP   = "order.first_order_xp"
+	jobDeliveredOrder = "order.process_delivered"
+)
+
+type firstOrderJob struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+type deliveredOrderJob struct {
+	OrderID uuid.UUID `json:"order_id"`
+}
+
 type CreateOrderRequest struct {
-	Items           []OrderItemRequest `json:"items" validate:"required"`
+	Items           []OrderItemRequest `json:"items"` // Defaults to the contents of the cart
 	ShippingAddress string             `json:"shipping_address" validate:"required"`
 	Notes           string             `json:"notes"`
+	Gift            *GiftRequest       `json:"gift"`
+	CouponCode      string             `json:"coupon_code"`
+	ContactPhone    string             `json:"contact_phone"` // Required for guest checkout
+	OfferToken      string             `json:"offer_token"`   // Check out an accepted offer at its agreed price
+	Region          string             `json:"region"`        // Defaults to the marketplace's default region
+	DeliveryZone    string             `json:"delivery_zone"` // One of the region's delivery zones
+}
+
+type GiftRequest struct {
+	RecipientName    string `json:"recipient_name" validate:"required"`
+	RecipientPhone   string `json:"recipient_phone" validate:"required"`
+	RecipientAddress string `json:"recipient_address" validate:"required"`
+	Message          string `json:"message"`
 }
 
 type OrderItemRequest struct {
-	ProductID uuid.UUID `json:"product_id" validate:"required"`
-	Quantity  int       `json:"quantity" validate:"required,min=1"`
+	ProductID uuid.UUID  `json:"product_id" validate:"required"`
+	VariantID *uuid.UUID `json:"variant_id"` // Required for products with variants
+	Quantity  int        `json:"quantity" validate:"required,min=1"`
 }
 
 type UpdateOrderStatusRequest struct {
-	Status models.OrderStatus `json:"status" validate:"required"`
-	Notes  string             `json:"notes"`
+	Status         models.OrderStatus    `json:"status" validate:"required"`
+	Notes          string                `json:"notes"`
+	Carrier        string                `json:"carrier"`         // When marking shipped
+	TrackingNumber string                `json:"tracking_number"` // When marking shipped
+	Proof          *DeliveryProofRequest `json:"proof"`           // When marking delivered
 }
 
 type OrderListResponse struct {
-	Orders []models.Order `json:"orders"`
-	Total  int64          `json:"total"`
-	Page   int            `json:"page"`
-	Limit  int            `json:"limit"`
+	Orders   []models.Order       `json:"orders"`
+	Total    int64                `json:"total"`
+	Page     int                  `json:"page"`
+	Limit    int                  `json:"limit"`
+	Currency utils.CurrencyFormat `json:"currency_format"`
+}
+
+type OrderDetailResponse struct {
+	*models.Order
+	Currency       utils.CurrencyFormat              `json:"currency_format"`
+	PaymentRequest *contracts.InitiatePaymentRequest `json:"payment_request,omitempty"` // Body for POST /payments/initiate, while the buyer still has to pay
 }
 
 func NewOrderHandler(cfg *config.Config) *OrderHandler {
@@ -48,14 +105,21 @@ func NewOrderHandler(cfg *config.Config) *OrderHandler {
 	}
 }
 
+// RegisterJobs registers the handlers for the jobs this service enqueues
+func (h *OrderHandler) RegisterJobs() {
+	jobs.Handle(jobFirstOrderXP, h.awardFirstOrderXP)
+	jobs.Handle(jobDeliveredOrder, h.processDeliveredOrder)
+	webhooks.RegisterJobs()
+}
+
 // @Summary Create new order
 // @Description Create a new order from cart items
 // @Tags orders
 // @Security BearerAuth
 // @Param request body CreateOrderRequest true "Create order request"
-// @Success 201 {object} utils.Response{data=models.Order}
-// @Failure 400 {object} utils.Response
-// @Failure 404 {object} utils.Response
+// @Success 201 {object} utils.Response{data=OrderDetailResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
 // @Router /orders [post]
 func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 	userID, ok := c.Locals("user_id").(uuid.UUID)
@@ -68,14 +132,78 @@ func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 		return utils.ValidationErrorResponse(c, "Invalid request body")
 	}
 
+	// An accepted offer checks out on its own at the agreed price
+	var offer *models.Offer
+	if req.OfferToken != "" {
+		offer = &models.Offer{}
+		if err := database.DB.Where("checkout_token = ? AND buyer_id = ? AND status = ?", req.OfferToken, userID, models.OfferAccepted).
+			First(offer).Error; err != nil || offer.AgreedPrice == nil {
+			return utils.ValidationErrorResponse(c, "Offer is not valid")
+		}
+		if time.Now().After(offer.ExpiresAt) {
+			return utils.ValidationErrorResponse(c, "Offer checkout link has expired")
+		}
+		// Offers are made on the product, the buyer picks the variant at checkout
+		var variantID *uuid.UUID
+		if len(req.Items) == 1 {
+			variantID = req.Items[0].VariantID
+		}
+		req.Items = []OrderItemRequest{{ProductID: offer.ProductID, VariantID: variantID, Quantity: offer.Quantity}}
+	}
+
+	// Check out the cart when no items are given
+	fromCart := len(req.Items) == 0
+	if fromCart {
+		items, err := cartOrderItems(userID)
+		if err != nil {
+			return utils.InternalServerErrorResponse(c, "Failed to load cart", err)
+		}
+		req.Items = items
+	}
+
 	if len(req.Items) == 0 {
 		return utils.ValidationErrorResponse(c, "Order must contain at least one item")
 	}
+	for _, item := range req.Items {
+		if item.Quantity < 1 {
+			return utils.ValidationErrorResponse(c, "Quantity must be at least 1")
+		}
+	}
+
+	// Guests have no verified phone on file
+	userRole, _ := c.Locals("user_role").(models.UserRole)
+	if userRole == models.RoleGuest && req.ContactPhone == "" {
+		return utils.ValidationErrorResponse(c, "Contact phone is required for guest checkout")
+	}
+
+	// Gift orders ship to the recipient
+	if req.Gift != nil {
+		if req.Gift.RecipientName == "" || req.Gift.RecipientPhone == "" || req.Gift.RecipientAddress == "" {
+			return utils.ValidationErrorResponse(c, "Gift recipient name, phone and address are required")
+		}
+		req.ShippingAddress = req.Gift.RecipientAddress
+	}
 
 	if req.ShippingAddress == "" {
 		return utils.ValidationErrorResponse(c, "Shipping address is required")
 	}
 
+	region := regions.Default()
+	if req.Region != "" {
+		var ok bool
+		if region, ok = regions.Get(req.Region); !ok {
+			return utils.ValidationErrorResponse(c, "We don't deliver to this region yet")
+		}
+	}
+	if req.DeliveryZone != "" {
+		if _, ok := region.Zone(req.DeliveryZone); !ok {
+			return utils.ValidationErrorResponse(c, fmt.Sprintf("Unknown delivery zone for %s", region.Name))
+		}
+	}
+	if req.ContactPhone != "" && !region.ValidPhone(req.ContactPhone) {
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("Contact phone must be a %s mobile number", region.Name))
+	}
+
 	// Start transaction
 	tx := database.DB.Begin()
 	defer func() {
@@ -95,16 +223,34 @@ func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 		Status:          models.OrderPending,
 		ShippingAddress: req.ShippingAddress,
 		Notes:           req.Notes,
+		ContactPhone:    req.ContactPhone,
+		Region:          region.Code,
+		DeliveryZone:    strings.ToLower(req.DeliveryZone),
+	}
+
+	if req.Gift != nil {
+		order.IsGift = true
+		order.GiftRecipientName = req.Gift.RecipientName
+		order.GiftRecipientPhone = req.Gift.RecipientPhone
+		order.GiftMessage = req.Gift.Message
 	}
 
-	var totalAmount float64
+	var totalAmount, taxAmount float64
 	var orderItems []models.OrderItem
+	sellerSubtotals := make(map[uuid.UUID]float64)
+	sellerShipping := make(map[uuid.UUID]models.ShippingSettings)
+
+	// Quantity rules apply to the product's total across the order
+	quantities := make(map[uuid.UUID]int, len(req.Items))
+	for _, item := range req.Items {
+		quantities[item.ProductID] += item.Quantity
+	}
 
 	// Process each item
 	for _, item := range req.Items {
 		// Get product
 		var product models.Product
-		if err := tx.First(&product, item.ProductID).Error; err != nil {
+		if err := tx.Preload("PriceTiers").First(&product, item.ProductID).Error; err != nil {
 			tx.Rollback()
 			return utils.NotFoundResponse(c, fmt.Sprintf("Product %s not found", item.ProductID))
 		}
@@ -115,34 +261,136 @@ func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 			return utils.ValidationErrorResponse(c, fmt.Sprintf("Product %s is not available", product.Name))
 		}
 
-		// Check stock
-		if product.Stock < item.Quantity {
+		if err := checkQuantityRules(tx, &product, userID, quantities[product.ID]); err != nil {
+			tx.Rollback()
+			return utils.ValidationErrorResponse(c, err.Error())
+		}
+
+		// The seller has to ship to the buyer's delivery zone
+		settings, ok := sellerShipping[product.SellerID]
+		if !ok {
+			settings = shipping.SettingsForSeller(product.SellerID)
+			sellerShipping[product.SellerID] = settings
+		}
+		if !shipping.ShipsTo(settings, order.DeliveryZone) {
 			tx.Rollback()
-			return utils.ValidationErrorResponse(c, fmt.Sprintf("Insufficient stock for product %s. Available: %d, Requested: %d", product.Name, product.Stock, item.Quantity))
+			if order.DeliveryZone == "" {
+				return utils.ValidationErrorResponse(c, fmt.Sprintf("%s only ships to some delivery zones. Choose a delivery zone to check out.", product.Name))
+			}
+			return utils.ValidationErrorResponse(c, fmt.Sprintf("%s doesn't ship to your delivery zone", product.Name))
 		}
 
-		// Calculate item total
-		itemTotal := product.Price * float64(item.Quantity)
+		// Products with variants are bought by variant, from the variant's stock
+		variant, err := orderVariant(tx, &product, item.VariantID)
+		if err != nil {
+			tx.Rollback()
+			return utils.ValidationErrorResponse(c, err.Error())
+		}
+		stock := product.Stock
+		if variant != nil {
+			stock = variant.Stock
+		}
+
+		// Check stock, letting backorder and pre-order products sell beyond it
+		awaitingStock := stock < item.Quantity
+		if awaitingStock && !product.AcceptsBackorders() {
+			tx.Rollback()
+			return utils.ValidationErrorResponse(c, fmt.Sprintf("Insufficient stock for product %s. Available: %d, Requested: %d", product.Name, stock, item.Quantity))
+		}
+
+		// Calculate item total, with any quantity-break discount
+		unitPrice := product.VariantUnitPrice(variant, quantities[product.ID])
+		if offer != nil {
+			unitPrice = *offer.AgreedPrice
+		}
+		itemTotal := unitPrice * float64(item.Quantity)
 		totalAmount += itemTotal
+		taxAmount += region.TaxOn(itemTotal, product.Category)
+		sellerSubtotals[product.SellerID] += itemTotal
 
 		// Create order item
 		orderItem := models.OrderItem{
 			BaseModel: models.BaseModel{ID: uuid.New()},
 			OrderID:   order.ID,
 			ProductID: item.ProductID,
+			VariantID: item.VariantID,
 			Quantity:  item.Quantity,
-			Price:     product.Price, // Store price at time of order
+			Price:     unitPrice, // Store price at time of order
+			Status:    models.ItemPending,
+		}
+
+		// Unallocated items take no stock now and are allocated when it arrives
+		if awaitingStock {
+			orderItem.Status = models.ItemBackordered
+			if product.Availability == models.AvailabilityPreorder {
+				orderItem.Status = models.ItemPreordered
+			}
+			orderItem.AwaitingStock = true
+			orderItem.ExpectedAt = product.ExpectedAt
 		}
 
 		orderItems = append(orderItems, orderItem)
 
-		// Update product stock
-		if err := tx.Model(&product).Update("stock", product.Stock-item.Quantity).Error; err != nil {
+		if awaitingStock {
+			continue
+		}
+
+		// Update product stock, failing if a concurrent checkout took it first
+		var variantID *uuid.UUID
+		if variant != nil {
+			variantID = &variant.ID
+		}
+		if err := inventory.Take(tx, product.ID, variantID, item.Quantity); err != nil {
 			tx.Rollback()
+			if errors.Is(err, inventory.ErrInsufficientStock) {
+				return utils.ValidationErrorResponse(c, fmt.Sprintf("Insufficient stock for product %s", product.Name))
+			}
 			return utils.InternalServerErrorResponse(c, "Failed to update product stock", err)
 		}
 	}
 
+	// Apply coupon discount
+	var coupon *models.Coupon
+	var couponSubtotal float64
+	if req.CouponCode != "" {
+		var discount float64
+		var err error
+		coupon, discount, couponSubtotal, err = coupons.Apply(tx, req.CouponCode, userID, sellerSubtotals)
+		if err != nil {
+			tx.Rollback()
+			return utils.ValidationErrorResponse(c, err.Error())
+		}
+		order.CouponCode = coupon.Code
+		order.DiscountAmount = discount
+		if totalAmount > 0 {
+			taxAmount *= (totalAmount - discount) / totalAmount
+		}
+		totalAmount -= discount
+	}
+
+	// Tax charged on top of prices is added to the total
+	order.TaxAmount = math.Round(taxAmount*100) / 100
+	if !region.Tax.Inclusive {
+		totalAmount += order.TaxAmount
+	}
+
+	// Each seller charges shipping on their share of the order, before any
+	// coupon, and ships within their handling time
+	var shippingCharges []models.OrderShipping
+	for sellerID, subtotal := range sellerSubtotals {
+		settings := sellerShipping[sellerID]
+		charge := models.OrderShipping{
+			BaseModel: models.BaseModel{ID: uuid.New()},
+			OrderID:   order.ID,
+			SellerID:  sellerID,
+			Fee:       shipping.Fee(settings, subtotal),
+			ShipBy:    shipping.ShipBy(settings, time.Now()),
+		}
+		order.ShippingAmount += charge.Fee
+		shippingCharges = append(shippingCharges, charge)
+	}
+	totalAmount += order.ShippingAmount
+
 	order.TotalAmount = totalAmount
 
 	// Save order
@@ -151,6 +399,22 @@ func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 		return utils.InternalServerErrorResponse(c, "Failed to create order", err)
 	}
 
+	if offer != nil {
+		result := tx.Model(offer).Where("status = ?", models.OfferAccepted).
+			Updates(map[string]interface{}{"status": models.OfferRedeemed, "order_id": order.ID})
+		if result.Error != nil || result.RowsAffected == 0 {
+			tx.Rollback()
+			return utils.ValidationErrorResponse(c, "Offer has already been used")
+		}
+	}
+
+	if coupon != nil {
+		if err := coupons.Redeem(tx, coupon, order.ID, userID, order.DiscountAmount, couponSubtotal); err != nil {
+			tx.Rollback()
+			return utils.InternalServerErrorResponse(c, "Failed to redeem coupon", err)
+		}
+	}
+
 	// Save order items
 	for _, item := range orderItems {
 		if err := tx.Create(&item).Error; err != nil {
@@ -159,27 +423,71 @@ func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 		}
 	}
 
+	// Stock taken for the order is held until it's paid for
+	if err := inventory.Reserve(tx, order.ID, orderItems, h.config.Orders.ReservationTTL); err != nil {
+		tx.Rollback()
+		return utils.InternalServerErrorResponse(c, "Failed to reserve stock", err)
+	}
+
+	if err := tx.Create(&shippingCharges).Error; err != nil {
+		tx.Rollback()
+		return utils.InternalServerErrorResponse(c, "Failed to save order shipping", err)
+	}
+
+	if fromCart {
+		if err := clearCart(tx, userID); err != nil {
+			tx.Rollback()
+			return utils.InternalServerErrorResponse(c, "Failed to clear cart", err)
+		}
+	}
+
 	// Update user's total spent
 	if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("total_spent", gorm.Expr("total_spent + ?", totalAmount)).Error; err != nil {
 		tx.Rollback()
 		return utils.InternalServerErrorResponse(c, "Failed to update user stats", err)
 	}
 
+	// Score the checkout and hold high-risk orders for manual review, so a
+	// hold that can't be saved fails the whole checkout
+	var buyer models.User
+	if err := tx.First(&buyer, userID).Error; err == nil {
+		if assessment := fraud.ScoreOrder(tx, &buyer, &order); assessment.Score >= h.config.Fraud.ReviewThreshold {
+			if err := fraud.Hold(tx, &order, fraud.StageOrder, assessment); err != nil {
+				tx.Rollback()
+				return utils.InternalServerErrorResponse(c, "Failed to hold order for review", err)
+			}
+		}
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		return utils.InternalServerErrorResponse(c, "Failed to commit transaction", err)
 	}
 
 	// Load order with relationships
-	database.DB.Preload("Buyer").Preload("Items.Product").First(&order, order.ID)
+	database.DB.Preload("Buyer").Preload("Items.Product").Preload("Items.Variant").Preload("Shipping").First(&order, order.ID)
+
+	// Count purchases for seller funnel analytics
+	purchasedIDs := make([]uuid.UUID, len(orderItems))
+	for i, item := range orderItems {
+		purchasedIDs[i] = item.ProductID
+	}
+	analytics.Track(analytics.EventPurchase, purchasedIDs...)
+
+	activity.Record(userID, models.ActivityOrderPlaced, fmt.Sprintf("Placed order %s", order.OrderNumber), order.ID.String())
+	go live.PublishOrder(order.ID, live.EventOrderCreated)
 
 	// Award XP for first order (async)
-	go h.awardFirstOrderXP(userID)
+	jobs.Enqueue(jobFirstOrderXP, firstOrderJob{UserID: userID})
 
 	return c.Status(fiber.StatusCreated).JSON(utils.Response{
 		Success: true,
-		Message: "Order created successfully",
-		Data:    order,
+		Message: utils.Translate(c, "Order created successfully"),
+		Data: OrderDetailResponse{
+			Order:          &order,
+			Currency:       utils.CurrencyHint(c, h.config),
+			PaymentRequest: paymentRequest(&order),
+		},
 	})
 }
 
@@ -188,8 +496,8 @@ func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 // @Tags orders
 // @Security BearerAuth
 // @Param id path string true "Order ID"
-// @Success 200 {object} utils.Response{data=models.Order}
-// @Failure 404 {object} utils.Response
+// @Success 200 {object} utils.Response{data=OrderDetailResponse}
+// @Failure 404 {object} utils.Problem
 // @Router /orders/{id} [get]
 func (h *OrderHandler) GetOrder(c *fiber.Ctx) error {
 	orderIDParam := c.Params("id")
@@ -205,11 +513,11 @@ func (h *OrderHandler) GetOrder(c *fiber.Ctx) error {
 
 	// Get order with relationships
 	var order models.Order
-	query := database.DB.Preload("Buyer").Preload("Items.Product.Seller").Preload("Payment")
+	query := database.DB.Preload("Buyer").Preload("Items.Product.Seller.Profile").Preload("Items.Variant").Preload("Payment").Preload("Shipping")
 
 	// Users can only see their own orders (buyers see orders they placed, sellers see orders for their products)
 	userRole, _ := c.Locals("user_role").(models.UserRole)
-	if userRole == models.RoleBuyer {
+	if userRole == models.RoleBuyer || userRole == models.RoleGuest {
 		query = query.Where("buyer_id = ?", userID)
 	} else if userRole == models.RoleSeller {
 		query = query.Joins("JOIN order_items ON orders.id = order_items.order_id").
@@ -221,7 +529,52 @@ func (h *OrderHandler) GetOrder(c *fiber.Ctx) error {
 		return utils.NotFoundResponse(c, "Order not found")
 	}
 
-	return utils.SuccessResponse(c, "Order retrieved successfully", order)
+	detail := OrderDetailResponse{
+		Order:    &order,
+		Currency: utils.CurrencyHint(c, h.config),
+	}
+	if order.BuyerID == userID {
+		detail.PaymentRequest = paymentRequest(&order)
+	}
+
+	return utils.SuccessResponse(c, "Order retrieved successfully", detail)
+}
+
+// paymentRequest prefills the payment service's initiate request for an
+// order that hasn't been paid, with the first payment method of the
+// order's region and the buyer's phone when the method needs one. Nil once
+// a payment is in progress or the order has moved on.
+func paymentRequest(order *models.Order) *contracts.InitiatePaymentRequest {
+	if order.Status != models.OrderPending {
+		return nil
+	}
+	if order.Payment != nil && order.Payment.Status != models.PaymentFailed {
+		return nil
+	}
+
+	region, ok := regions.Get(order.Region)
+	if !ok {
+		region = regions.Default()
+	}
+	if len(region.PaymentMethods) == 0 {
+		return nil
+	}
+	method := region.PaymentMethods[0]
+
+	req := &contracts.InitiatePaymentRequest{
+		OrderID: order.ID,
+		Method:  method.Method,
+	}
+	if method.RequiresPhone {
+		phone := order.ContactPhone
+		if phone == "" {
+			phone = order.Buyer.Phone
+		}
+		if region.ValidPhone(phone) {
+			req.Phone = phone
+		}
+	}
+	return req
 }
 
 // @Summary Get user orders
@@ -233,7 +586,7 @@ func (h *OrderHandler) GetOrder(c *fiber.Ctx) error {
 // @Param limit query int false "Items per page" default(20)
 // @Param status query string false "Filter by status"
 // @Success 200 {object} utils.Response{data=OrderListResponse}
-// @Failure 404 {object} utils.Response
+// @Failure 404 {object} utils.Problem
 // @Router /users/{id}/orders [get]
 func (h *OrderHandler) GetUserOrders(c *fiber.Ctx) error {
 	userIDParam := c.Params("id")
@@ -278,7 +631,7 @@ func (h *OrderHandler) GetUserOrders(c *fiber.Ctx) error {
 
 	// Get orders
 	var orders []models.Order
-	if err := query.Preload("Items.Product").Preload("Payment").
+	if err := query.Preload("Items.Product").Preload("Items.Variant").Preload("Payment").
 		Order("created_at DESC").
 		Offset(offset).
 		Limit(limit).
@@ -287,10 +640,11 @@ func (h *OrderHandler) GetUserOrders(c *fiber.Ctx) error {
 	}
 
 	response := OrderListResponse{
-		Orders: orders,
-		Total:  total,
-		Page:   page,
-		Limit:  limit,
+		Orders:   orders,
+		Total:    total,
+		Page:     page,
+		Limit:    limit,
+		Currency: utils.CurrencyHint(c, h.config),
 	}
 
 	return utils.SuccessResponse(c, "Orders retrieved successfully", response)
@@ -303,9 +657,9 @@ func (h *OrderHandler) GetUserOrders(c *fiber.Ctx) error {
 // @Param id path string true "Order ID"
 // @Param request body UpdateOrderStatusRequest true "Update status request"
 // @Success 200 {object} utils.Response{data=models.Order}
-// @Failure 400 {object} utils.Response
-// @Failure 403 {object} utils.Response
-// @Failure 404 {object} utils.Response
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
 // @Router /orders/{id}/status [put]
 func (h *OrderHandler) UpdateOrderStatus(c *fiber.Ctx) error {
 	orderIDParam := c.Params("id")
@@ -365,8 +719,28 @@ func (h *OrderHandler) UpdateOrderStatus(c *fiber.Ctx) error {
 		return utils.ErrorResponse(c, fiber.StatusForbidden, "Only sellers can update order status", nil)
 	}
 
+	// Items still waiting for stock have to ship separately
+	if req.Status == models.OrderShipped || req.Status == models.OrderDelivered {
+		for _, item := range order.Items {
+			if item.AwaitingStock && item.Status != models.ItemCancelled {
+				return utils.ValidationErrorResponse(c, fmt.Sprintf("%s is waiting for stock. Ship the available items as a partial shipment.", item.Product.Name))
+			}
+		}
+	}
+
+	// Capture proof of delivery (required for cash on delivery)
+	if req.Status == models.OrderDelivered {
+		if _, err := h.captureDeliveryProof(&order, userID, req.Proof); err != nil {
+			return utils.ValidationErrorResponse(c, err.Error())
+		}
+	}
+
 	// Update order status
 	order.Status = req.Status
+	if req.Status == models.OrderDelivered && order.DeliveredAt == nil {
+		now := time.Now()
+		order.DeliveredAt = &now
+	}
 	if req.Notes != "" {
 		order.Notes = req.Notes
 	}
@@ -375,13 +749,24 @@ func (h *OrderHandler) UpdateOrderStatus(c *fiber.Ctx) error {
 		return utils.InternalServerErrorResponse(c, "Failed to update order status", err)
 	}
 
-	// Award XP and update seller stats if order is delivered
-	if req.Status == models.OrderDelivered {
-		go h.processDeliveredOrder(&order)
+	// Apply the order-wide status to every item
+	switch req.Status {
+	case models.OrderShipped:
+		if unshipped := unshippedItems(&order); len(unshipped) > 0 {
+			if _, err := h.createShipment(&order, unshipped, req.Carrier, req.TrackingNumber); err != nil {
+				return utils.InternalServerErrorResponse(c, "Failed to create shipment", err)
+			}
+		}
+	case models.OrderDelivered:
+		h.markItemsDelivered(order.ID, nil, *order.DeliveredAt)
+		h.onOrderDelivered(&order)
+	case models.OrderCancelled:
+		database.DB.Model(&models.OrderItem{}).Where("order_id = ? AND status <> ?", order.ID, models.ItemDelivered).
+			Update("status", models.ItemCancelled)
 	}
 
 	// Load updated order with relationships
-	database.DB.Preload("Buyer").Preload("Items.Product.Seller").Preload("Payment").First(&order, order.ID)
+	database.DB.Preload("Buyer").Preload("Items.Product.Seller.Profile").Preload("Payment").First(&order, order.ID)
 
 	return utils.SuccessResponse(c, "Order status updated successfully", order)
 }
@@ -396,83 +781,205 @@ func (h *OrderHandler) generateOrderNumber() string {
 	return fmt.Sprintf("ORD-%s-%06d", dateStr, randomNum)
 }
 
-func (h *OrderHandler) awardFirstOrderXP(userID uuid.UUID) {
-	// Check if this is user's first order
-	var orderCount int64
-	database.DB.Model(&models.Order{}).Where("buyer_id = ?", userID).Count(&orderCount)
+// Reason recorded on the first order's XP, which marks it as awarded
+const firstOrderReason = "First Order"
+
+// awardFirstOrderXP runs under a lock on the buyer and skips buyers already
+// awarded, so a repeated job doesn't award twice
+func (h *OrderHandler) awardFirstOrderXP(payload []byte) error {
+	var job firstOrderJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+	userID := job.UserID
+
+	var earned *models.Badge
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockUser(tx, userID); err != nil {
+			return err
+		}
+
+		// Check if this is user's first order
+		var orderCount int64
+		if err := tx.Model(&models.Order{}).Where("buyer_id = ?", userID).Count(&orderCount).Error; err != nil {
+			return err
+		}
+		if orderCount != 1 {
+			return nil
+		}
+
+		var awarded int64
+		if err := tx.Model(&models.XPTransaction{}).Where("user_id = ? AND reason = ?", userID, firstOrderReason).
+			Count(&awarded).Error; err != nil {
+			return err
+		}
+		if awarded > 0 {
+			return nil
+		}
 
-	if orderCount == 1 {
 		// Award first order badge and XP
-		h.callGamificationService(userID, 50, "First Order", "")
-		h.checkAndAwardBadge(userID, models.BadgeFirstOrder)
+		if firstOrderXP := xp.FromConfig(h.config).FirstOrder; firstOrderXP > 0 {
+			if err := h.callGamificationService(tx, userID, firstOrderXP, firstOrderReason, ""); err != nil {
+				return err
+			}
+		}
+		badge, err := h.checkAndAwardBadge(tx, userID, models.BadgeFirstOrder)
+		earned = badge
+		return err
+	})
+	if err == nil && earned != nil {
+		activity.BadgeEarned(userID, earned)
 	}
+	return err
 }
 
-func (h *OrderHandler) processDeliveredOrder(order *models.Order) {
-	// Update seller stats and award XP
-	for _, item := range order.Items {
-		sellerID := item.Product.SellerID
-		saleAmount := item.Price * float64(item.Quantity)
+// processDeliveredOrder credits sellers' sales and the order's XP and
+// badges. The order is marked in the same transaction, so a repeated job
+// finds it done and awards nothing.
+func (h *OrderHandler) processDeliveredOrder(payload []byte) error {
+	var job deliveredOrderJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
 
-		// Update seller's total sales
-		database.DB.Model(&models.User{}).Where("id = ?", sellerID).
-			Update("total_sales", gorm.Expr("total_sales + ?", saleAmount))
+	var order models.Order
+	if err := database.DB.Preload("Items.Product").First(&order, job.OrderID).Error; err != nil {
+		return err
+	}
 
-		// Award XP to seller (10 XP per ₵100 in sales)
-		xpAmount := int(saleAmount / 100 * 10)
-		if xpAmount > 0 {
-			h.callGamificationService(sellerID, xpAmount, "Product Sale", order.ID.String())
-		}
+	rules := xp.FromConfig(h.config)
+	processed := false
+	type earnedBadge struct {
+		userID uuid.UUID
+		badge  *models.Badge
 	}
+	var earned []earnedBadge
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Order{}).Where("id = ? AND rewards_processed_at IS NULL", order.ID).
+			Update("rewards_processed_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil // Already processed
+		}
+		processed = true
+
+		// Update seller stats and award XP
+		for _, item := range order.Items {
+			sellerID := item.Product.SellerID
+			saleAmount := item.Price * float64(item.Quantity)
+
+			// Update seller's total sales
+			if err := tx.Model(&models.User{}).Where("id = ?", sellerID).
+				Update("total_sales", gorm.Expr("total_sales + ?", saleAmount)).Error; err != nil {
+				return err
+			}
+
+			// Award XP to seller
+			if xpAmount := rules.Sale(saleAmount); xpAmount > 0 {
+				if err := h.callGamificationService(tx, sellerID, xpAmount, "Product Sale", order.ID.String()); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Award XP to buyer
+		if buyerXP := rules.Purchase(order.TotalAmount); buyerXP > 0 {
+			if err := h.callGamificationService(tx, order.BuyerID, buyerXP, "Order Completed", order.ID.String()); err != nil {
+				return err
+			}
+		}
 
-	// Award XP to buyer (5 XP per ₵100 spent)
-	buyerXP := int(order.TotalAmount / 100 * 5)
-	if buyerXP > 0 {
-		h.callGamificationService(order.BuyerID, buyerXP, "Order Completed", order.ID.String())
+		// Check for badges
+		award := func(userID uuid.UUID, badgeType models.BadgeType) error {
+			badge, err := h.checkAndAwardBadge(tx, userID, badgeType)
+			if badge != nil {
+				earned = append(earned, earnedBadge{userID: userID, badge: badge})
+			}
+			return err
+		}
+		if err := award(order.BuyerID, models.BadgeBigSpender); err != nil {
+			return err
+		}
+		for _, item := range order.Items {
+			if err := award(item.Product.SellerID, models.BadgeTopSeller); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil || !processed {
+		return err
+	}
+	for _, award := range earned {
+		activity.BadgeEarned(award.userID, award.badge)
 	}
 
-	// Check for badges
-	h.checkAndAwardBadge(order.BuyerID, models.BadgeBigSpender)
-	
-	// Check top seller badge for all sellers in this order
+	// Referral progress is recounted from delivered orders, so it's safe to repeat
+	sellers := make(map[uuid.UUID]bool)
 	for _, item := range order.Items {
-		h.checkAndAwardBadge(item.Product.SellerID, models.BadgeTopSeller)
+		sellerID := item.Product.SellerID
+		if !sellers[sellerID] {
+			sellers[sellerID] = true
+			if err := referrals.RecordDeliveredSale(h.config, sellerID, order.ID); err != nil {
+				log.Printf("Failed to update seller referral for %s: %v", sellerID, err)
+			}
+		}
 	}
+	return nil
 }
 
-func (h *OrderHandler) callGamificationService(userID uuid.UUID, xpAmount int, reason, reference string) {
+func (h *OrderHandler) callGamificationService(tx *gorm.DB, userID uuid.UUID, xpAmount int, reason, reference string) error {
 	// In a real microservices setup, this would be an HTTP call to the gamification service
-	// For now, we'll directly create the XP transaction
-	xpTransaction := models.XPTransaction{
-		BaseModel: models.BaseModel{ID: uuid.New()},
+	// For now, we'll directly create the XP transaction, shaped by the same
+	// contract the gamification service accepts
+	req := contracts.AwardXPRequest{
 		UserID:    userID,
 		Amount:    xpAmount,
 		Reason:    reason,
 		Reference: reference,
 	}
-	database.DB.Create(&xpTransaction)
-	database.DB.Model(&models.User{}).Where("id = ?", userID).Update("total_xp", gorm.Expr("total_xp + ?", xpAmount))
+	if err := req.Validate(); err != nil {
+		log.Printf("Skipping XP award for user %s: %v", userID, err)
+		return nil
+	}
+
+	xpTransaction := models.XPTransaction{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		UserID:    req.UserID,
+		Amount:    req.Amount,
+		Reason:    req.Reason,
+		Reference: req.Reference,
+	}
+	if err := tx.Create(&xpTransaction).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.User{}).Where("id = ?", req.UserID).Update("total_xp", gorm.Expr("total_xp + ?", req.Amount)).Error
 }
 
-func (h *OrderHandler) checkAndAwardBadge(userID uuid.UUID, badgeType models.BadgeType) {
+// checkAndAwardBadge awards the badge when the user qualifies and doesn't
+// have it yet, returning it if awarded. The user is locked first, so two
+// jobs can't both award it.
+func (h *OrderHandler) checkAndAwardBadge(tx *gorm.DB, userID uuid.UUID, badgeType models.BadgeType) (*models.Badge, error) {
 	// Get user
 	var user models.User
-	if err := database.DB.First(&user, userID).Error; err != nil {
-		return
+	if err := tx.Clauses(clause.Locking{Strength: "NO KEY UPDATE"}).First(&user, userID).Error; err != nil {
+		return nil, nil
 	}
 
 	// Check if user already has this badge
 	var existingBadge models.UserBadge
-	if err := database.DB.Joins("JOIN badges ON user_badges.badge_id = badges.id").
+	if err := tx.Joins("JOIN badges ON user_badges.badge_id = badges.id").
 		Where("user_badges.user_id = ? AND badges.type = ?", userID, badgeType).
 		First(&existingBadge).Error; err == nil {
-		return // User already has this badge
+		return nil, nil // User already has this badge
 	}
 
 	// Get badge
 	var badge models.Badge
-	if err := database.DB.Where("type = ?", badgeType).First(&badge).Error; err != nil {
-		return
+	if err := tx.Where("type = ?", badgeType).First(&badge).Error; err != nil {
+		return nil, nil
 	}
 
 	shouldAward := false
@@ -480,12 +987,12 @@ func (h *OrderHandler) checkAndAwardBadge(userID uuid.UUID, badgeType models.Bad
 	switch badgeType {
 	case models.BadgeFirstOrder:
 		var orderCount int64
-		database.DB.Model(&models.Order{}).Where("buyer_id = ?", userID).Count(&orderCount)
+		tx.Model(&models.Order{}).Where("buyer_id = ?", userID).Count(&orderCount)
 		shouldAward = orderCount >= 1
 
 	case models.BadgeTopSeller:
 		var salesCount int64
-		database.DB.Model(&models.Order{}).
+		tx.Model(&models.Order{}).
 			Joins("JOIN order_items ON orders.id = order_items.order_id").
 			Joins("JOIN products ON order_items.product_id = products.id").
 			Where("products.seller_id = ? AND orders.status = ?", userID, models.OrderDelivered).
@@ -496,18 +1003,31 @@ func (h *OrderHandler) checkAndAwardBadge(userID uuid.UUID, badgeType models.Bad
 		shouldAward = user.TotalSpent >= 5000
 	}
 
-	if shouldAward {
-		userBadge := models.UserBadge{
-			BaseModel: models.BaseModel{ID: uuid.New()},
-			UserID:    userID,
-			BadgeID:   badge.ID,
-			EarnedAt:  time.Now(),
-		}
-		database.DB.Create(&userBadge)
+	if !shouldAward {
+		return nil, nil
+	}
 
-		// Award XP for badge
-		if badge.XPReward > 0 {
-			h.callGamificationService(userID, badge.XPReward, fmt.Sprintf("Badge: %s", badge.Name), "")
+	userBadge := models.UserBadge{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		UserID:    userID,
+		BadgeID:   badge.ID,
+		EarnedAt:  time.Now(),
+	}
+	if err := tx.Create(&userBadge).Error; err != nil {
+		return nil, err
+	}
+
+	// Award XP for badge
+	if badge.XPReward > 0 {
+		if err := h.callGamificationService(tx, userID, badge.XPReward, fmt.Sprintf("Badge: %s", badge.Name), ""); err != nil {
+			return nil, err
 		}
 	}
+	return &badge, nil
+}
+
+// lockUser takes a row lock on the user for the rest of tx
+func lockUser(tx *gorm.DB, userID uuid.UUID) error {
+	var user models.User
+	return tx.Clauses(clause.Locking{Strength: "NO KEY UPDATE"}).Select("id").First(&user, userID).Error
 }
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/escrow"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ProtectionClaimRequest struct {
+	Description string `json:"description"`
+}
+
+// Outcomes of checking a protection claim against the order's shipments
+type protectionVerdict int
+
+const (
+	protectionApproved  protectionVerdict = iota // Nothing was delivered, pay the buyer
+	protectionDenied                             // Delivery was proven
+	protectionEscalated                          // Delivery reported without proof, support decides
+)
+
+// @Summary File buyer protection claim
+// @Description Claim a refund for an order paid online that still hasn't been delivered after the protection window. The claim is checked against the order's shipments: with no delivery on record the payment is refunded straight away, proven deliveries are declined and deliveries without proof go to support.
+// @Tags issues
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param request body ProtectionClaimRequest false "Protection claim request"
+// @Success 201 {object} utils.Response{data=models.OrderIssue}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /orders/{id}/protection [post]
+func (h *OrderHandler) FileProtectionClaim(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid order ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req ProtectionClaimRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return utils.ValidationErrorResponse(c, "Invalid request body")
+		}
+	}
+
+	var order models.Order
+	if err := database.DB.Preload("Items.Product").Preload("Payment").First(&order, orderID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Order not found")
+	}
+
+	if order.BuyerID != userID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only claim buyer protection on your own orders", nil)
+	}
+
+	// Eligibility: paid online and not delivered within the protection window
+	if order.Status == models.OrderDelivered || order.Status == models.OrderCancelled {
+		return utils.ValidationErrorResponse(c, "Only undelivered orders are covered by buyer protection")
+	}
+	if order.Payment == nil || order.Payment.Status != models.PaymentCompleted {
+		return utils.ValidationErrorResponse(c, "Buyer protection only covers paid orders")
+	}
+	if order.Payment.Method == models.PaymentCash {
+		return utils.ValidationErrorResponse(c, "Buyer protection only covers orders paid online")
+	}
+	if due := h.protectionDueBy(&order); time.Now().Before(due) {
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("Buyer protection covers this order from %s", due.Format("2006-01-02")))
+	}
+
+	var count int64
+	database.DB.Model(&models.OrderIssue{}).Where("order_id = ? AND type = ?", order.ID, models.IssueProtection).Count(&count)
+	if count > 0 {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "You already filed a buyer protection claim for this order", nil)
+	}
+
+	var shipments []models.Shipment
+	if err := database.DB.Where("order_id = ?", order.ID).Find(&shipments).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to check shipments", err)
+	}
+	verdict, finding := checkProtectionClaim(&order, shipments)
+
+	sellers := orderSellers(&order)
+	if len(sellers) == 0 {
+		return utils.ValidationErrorResponse(c, "Order has no items")
+	}
+
+	now := time.Now()
+	issue := models.OrderIssue{
+		BaseModel:   models.BaseModel{ID: uuid.New()},
+		OrderID:     order.ID,
+		BuyerID:     userID,
+		SellerID:    sellers[0],
+		Type:        models.IssueProtection,
+		Workflow:    models.WorkflowProtection,
+		Status:      models.IssueOpen,
+		Description: req.Description,
+		RespondBy:   now.Add(time.Duration(h.config.Orders.IssueResponseHours) * time.Hour),
+		ResolveBy:   now.Add(time.Duration(h.config.Orders.IssueResolutionHours) * time.Hour),
+	}
+
+	switch verdict {
+	case protectionApproved:
+		issue.Status = models.IssueResolved
+		issue.PayoutAmount = order.Payment.Amount
+		issue.Resolution = fmt.Sprintf("%s. Refunded %.2f under buyer protection", finding, issue.PayoutAmount)
+		issue.ResolvedAt = &now
+	case protectionDenied:
+		issue.Status = models.IssueResolved
+		issue.Resolution = finding
+		issue.ResolvedAt = &now
+	case protectionEscalated:
+		// Escrow stays held while support checks the delivery
+		issue.Workflow = models.WorkflowDispute
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&issue).Error; err != nil {
+			return err
+		}
+		if verdict == protectionApproved {
+			return escrow.PayProtectionClaim(tx, &issue)
+		}
+		return nil
+	})
+	if errors.Is(err, escrow.ErrPaymentNotCompleted) || errors.Is(err, escrow.ErrAlreadyRefunded) {
+		return utils.ValidationErrorResponse(c, "Order payment can no longer be refunded")
+	}
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to file buyer protection claim", err)
+	}
+
+	switch verdict {
+	case protectionApproved:
+		notifications.Send(userID, models.NotificationOrder, "Buyer protection refund",
+			fmt.Sprintf("Order %s wasn't delivered, so we refunded %.2f under buyer protection.", order.OrderNumber, issue.PayoutAmount))
+		for _, sellerID := range sellers {
+			notifications.Send(sellerID, models.NotificationOrder, "Order refunded",
+				fmt.Sprintf("Order %s was refunded under buyer protection because it wasn't delivered.", order.OrderNumber))
+		}
+	case protectionDenied:
+		notifications.Send(userID, models.NotificationOrder, "Buyer protection claim declined",
+			fmt.Sprintf("Your claim for order %s was declined: %s", order.OrderNumber, finding))
+	case protectionEscalated:
+		notifications.Send(userID, models.NotificationOrder, "Buyer protection claim under review",
+			fmt.Sprintf("Order %s is marked delivered without proof. Our support team will review your claim by %s.",
+				order.OrderNumber, issue.ResolveBy.Format("2006-01-02 15:04")))
+		for _, sellerID := range sellers {
+			notifications.Send(sellerID, models.NotificationOrder, "Buyer protection claim",
+				fmt.Sprintf("The buyer of order %s says it never arrived. Please add proof of delivery by %s.",
+					order.OrderNumber, issue.RespondBy.Format("2006-01-02 15:04")))
+		}
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Buyer protection claim filed successfully",
+		Data:    issue,
+	})
+}
+
+// @Summary Pay buyer protection claim
+// @Description Refund the buyer for a protection claim support has reviewed, resolving it. Held funds are returned from escrow, otherwise the protection fund pays (admin only).
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Issue ID"
+// @Param request body ResolveIssueRequest true "Resolve issue request"
+// @Success 200 {object} utils.Response{data=models.OrderIssue}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /admin/issues/{id}/protection-payout [post]
+func (h *OrderHandler) PayProtectionClaim(c *fiber.Ctx) error {
+	issueID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid issue ID")
+	}
+
+	adminID, _ := c.Locals("user_id").(uuid.UUID)
+
+	var req ResolveIssueRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+	if req.Resolution == "" {
+		return utils.ValidationErrorResponse(c, "Resolution is required")
+	}
+
+	var issue models.OrderIssue
+	if err := database.DB.Preload("Order.Payment").First(&issue, issueID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Issue not found")
+	}
+	if issue.Type != models.IssueProtection {
+		return utils.ValidationErrorResponse(c, "Issue is not a buyer protection claim")
+	}
+	if issue.Status != models.IssueOpen {
+		return utils.ValidationErrorResponse(c, "Issue is already resolved")
+	}
+	if issue.Order.Payment == nil {
+		return utils.ValidationErrorResponse(c, "Order payment can no longer be refunded")
+	}
+
+	issue.PayoutAmount = issue.Order.Payment.Amount
+	resolution := fmt.Sprintf("%s. Refunded %.2f under buyer protection", req.Resolution, issue.PayoutAmount)
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&issue).Update("payout_amount", issue.PayoutAmount).Error; err != nil {
+			return err
+		}
+		if err := escrow.PayProtectionClaim(tx, &issue); err != nil {
+			return err
+		}
+		return h.resolveIssue(tx, &issue, resolution, &adminID)
+	})
+	if errors.Is(err, escrow.ErrPaymentNotCompleted) || errors.Is(err, escrow.ErrAlreadyRefunded) {
+		return utils.ValidationErrorResponse(c, "Order payment can no longer be refunded")
+	}
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to pay buyer protection claim", err)
+	}
+
+	return utils.SuccessResponse(c, "Buyer protection claim paid successfully", issue)
+}
+
+// Helper functions
+
+// protectionDueBy returns when buyer protection starts covering an
+// undelivered order. Backordered and pre-ordered items push it back.
+func (h *OrderHandler) protectionDueBy(order *models.Order) time.Time {
+	due := order.CreatedAt.AddDate(0, 0, h.config.Orders.ProtectionAfterDays)
+	if expected := h.expectedBy(order); expected.After(due) {
+		due = expected
+	}
+	return due
+}
+
+// checkProtectionClaim validates a claim against the order's shipment
+// records and describes what they show
+func checkProtectionClaim(order *models.Order, shipments []models.Shipment) (protectionVerdict, string) {
+	for _, shipment := range shipments {
+		if shipment.HasProof() {
+			return protectionDenied, fmt.Sprintf("Shipment %s was delivered on %s with proof of delivery",
+				shipment.TrackingNumber, shipment.ProofCapturedAt.Format("2006-01-02"))
+		}
+	}
+
+	for _, shipment := range shipments {
+		if shipment.DeliveredAt != nil {
+			return protectionEscalated, fmt.Sprintf("Shipment %s was marked delivered on %s without proof of delivery",
+				shipment.TrackingNumber, shipment.DeliveredAt.Format("2006-01-02"))
+		}
+	}
+	for _, item := range order.Items {
+		if item.Status == models.ItemDelivered {
+			return protectionEscalated, "Some items were marked delivered without proof of delivery"
+		}
+	}
+
+	if len(shipments) == 0 {
+		return protectionApproved, "Order never shipped"
+	}
+	return protectionApproved, "No shipment was delivered"
+}
+
+// orderSellers lists the sellers with items on an order
+func orderSellers(order *models.Order) []uuid.UUID {
+	var sellers []uuid.UUID
+	seen := make(map[uuid.UUID]bool)
+	for _, item := range order.Items {
+		if !seen[item.Product.SellerID] {
+			seen[item.Product.SellerID] = true
+			sellers = append(sellers, item.Product.SellerID)
+		}
+	}
+	return sellers
+}
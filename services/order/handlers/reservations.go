@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"playful-marketplace/shared/coupons"
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/inventory"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ReleaseExpiredReservations cancels pending orders that went unpaid past
+// their reservation and puts any stock they still hold back. Orders with a
+// payment in progress are left for the payment to settle. Run periodically
+// by the scheduler.
+func (h *OrderHandler) ReleaseExpiredReservations() {
+	var orderIDs []uuid.UUID
+	database.DB.Model(&models.StockReservation{}).
+		Joins("JOIN orders ON orders.id = stock_reservations.order_id").
+		Where("stock_reservations.status IN ? AND stock_reservations.expires_at <= ? AND orders.status = ?",
+			[]models.ReservationStatus{models.ReservationHeld, models.ReservationReleased}, time.Now(), models.OrderPending).
+		Where("NOT EXISTS (SELECT 1 FROM payments WHERE payments.order_id = orders.id AND payments.status IN ?)",
+			[]models.PaymentStatus{models.PaymentPending, models.PaymentCompleted}).
+		Distinct().
+		Limit(500).
+		Pluck("stock_reservations.order_id", &orderIDs)
+
+	for _, orderID := range orderIDs {
+		if err := h.expireOrder(orderID); err != nil {
+			log.Printf("Failed to release reservation for order %s: %v", orderID, err)
+		}
+	}
+}
+
+// expireOrder cancels an unpaid order and releases the stock and coupon it
+// held
+func (h *OrderHandler) expireOrder(orderID uuid.UUID) error {
+	var order models.Order
+	var released []models.StockReservation
+	expired := false
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&order, orderID).Error; err != nil {
+			return err
+		}
+
+		// Paid for or otherwise moved on since it was picked up
+		result := tx.Model(&models.Order{}).Where("id = ? AND status = ?", orderID, models.OrderPending).
+			Update("status", models.OrderCancelled)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		expired = true
+
+		if err := tx.Model(&models.OrderItem{}).Where("order_id = ?", orderID).
+			Update("status", models.ItemCancelled).Error; err != nil {
+			return err
+		}
+
+		var err error
+		if released, err = inventory.ReleaseReservations(tx, orderID, models.ReservationHeld); err != nil {
+			return err
+		}
+
+		// The coupon is given back with the order
+		if err := coupons.Release(tx, orderID); err != nil {
+			return err
+		}
+
+		return tx.Model(&models.User{}).Where("id = ?", order.BuyerID).
+			Update("total_spent", gorm.Expr("total_spent - ?", order.TotalAmount)).Error
+	})
+	if err != nil || !expired {
+		return err
+	}
+
+	notifications.Send(order.BuyerID, models.NotificationOrder, "Order cancelled",
+		fmt.Sprintf("Order %s was cancelled because it wasn't paid for within %s.", order.OrderNumber, h.config.Orders.ReservationTTL))
+
+	// Released stock can fill waiting backorders
+	for _, reservation := range released {
+		go inventory.AllocateItemBackorders(reservation.ProductID, reservation.VariantID)
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/inventory"
+	"playful-marketplace/shared/ledger"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/returns"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CreateReturnRequest struct {
+	OrderItemID uuid.UUID `json:"order_item_id" validate:"required"`
+	Quantity    int       `json:"quantity" validate:"required,min=1"`
+	Reason      string    `json:"reason" validate:"required"`
+}
+
+type UpdateReturnStatusRequest struct {
+	Status models.ReturnStatus `json:"status" validate:"required"`
+	Notes  string              `json:"notes"`
+}
+
+// Allowed return status transitions
+var returnTransitions = map[models.ReturnStatus][]models.ReturnStatus{
+	models.ReturnRequested: {models.ReturnApproved, models.ReturnRejected},
+	models.ReturnApproved:  {models.ReturnReceived, models.ReturnRefunded}, // Refund without the goods coming back, e.g. missing items
+	models.ReturnReceived:  {models.ReturnRefunded},
+}
+
+// @Summary Request a return
+// @Description Request a return for an item in a delivered order, subject to the seller's return policy
+// @Tags returns
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param request body CreateReturnRequest true "Create return request"
+// @Success 201 {object} utils.Response{data=models.ReturnRequest}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /orders/{id}/returns [post]
+func (h *OrderHandler) CreateReturn(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid order ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req CreateReturnRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.OrderItemID == uuid.Nil || req.Quantity < 1 || req.Reason == "" {
+		return utils.ValidationErrorResponse(c, "Order item, quantity and reason are required")
+	}
+
+	var order models.Order
+	if err := database.DB.Preload("Items.Product").First(&order, orderID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Order not found")
+	}
+
+	if order.BuyerID != userID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only return items from your own orders", nil)
+	}
+
+	if order.Status != models.OrderDelivered || order.DeliveredAt == nil {
+		return utils.ValidationErrorResponse(c, "Only delivered orders can be returned")
+	}
+
+	var item *models.OrderItem
+	for i := range order.Items {
+		if order.Items[i].ID == req.OrderItemID {
+			item = &order.Items[i]
+			break
+		}
+	}
+	if item == nil {
+		return utils.NotFoundResponse(c, "Order item not found")
+	}
+
+	// Enforce the seller's return policy
+	policy := returns.PolicyForSeller(item.Product.SellerID)
+
+	if returns.IsFinalSale(policy, item.Product.Category) {
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("Items in category %s are final sale", item.Product.Category))
+	}
+
+	deadline := order.DeliveredAt.AddDate(0, 0, policy.WindowDays)
+	if time.Now().After(deadline) {
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("Return window of %d days has expired", policy.WindowDays))
+	}
+
+	// Quantity already requested for return (excluding rejected requests)
+	var returnedQuantity int64
+	database.DB.Model(&models.ReturnRequest{}).
+		Where("order_item_id = ? AND status != ?", item.ID, models.ReturnRejected).
+		Select("COALESCE(SUM(quantity), 0)").
+		Scan(&returnedQuantity)
+
+	if int(returnedQuantity)+req.Quantity > item.Quantity {
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("Only %d of this item can still be returned", item.Quantity-int(returnedQuantity)))
+	}
+
+	amount := item.Price * float64(req.Quantity)
+	fee := returns.RestockingFee(policy, amount)
+
+	returnRequest := models.ReturnRequest{
+		BaseModel:     models.BaseModel{ID: uuid.New()},
+		RMANumber:     h.generateRMANumber(),
+		OrderID:       order.ID,
+		OrderItemID:   item.ID,
+		BuyerID:       userID,
+		SellerID:      item.Product.SellerID,
+		Quantity:      req.Quantity,
+		Reason:        req.Reason,
+		Status:        models.ReturnRequested,
+		RestockingFee: fee,
+		RefundAmount:  amount - fee,
+	}
+
+	if err := database.DB.Create(&returnRequest).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create return request", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Return requested successfully",
+		Data:    returnRequest,
+	})
+}
+
+// @Summary Get order returns
+// @Description Get return requests for an order (buyer or seller)
+// @Tags returns
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Success 200 {object} utils.Response{data=[]models.ReturnRequest}
+// @Failure 404 {object} utils.Problem
+// @Router /orders/{id}/returns [get]
+func (h *OrderHandler) GetOrderReturns(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid order ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var returnRequests []models.ReturnRequest
+	if err := database.DB.Preload("OrderItem.Product").
+		Where("order_id = ? AND (buyer_id = ? OR seller_id = ?)", orderID, userID, userID).
+		Order("created_at DESC").
+		Find(&returnRequests).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get return requests", err)
+	}
+
+	return utils.SuccessResponse(c, "Return requests retrieved successfully", returnRequests)
+}
+
+// @Summary Update return status
+// @Description Approve, reject, receive or refund a return request (seller only, own products)
+// @Tags returns
+// @Security BearerAuth
+// @Param id path string true "Return request ID"
+// @Param request body UpdateReturnStatusRequest true "Update return status request"
+// @Success 200 {object} utils.Response{data=models.ReturnRequest}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /returns/{id}/status [put]
+func (h *OrderHandler) UpdateReturnStatus(c *fiber.Ctx) error {
+	returnID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid return request ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req UpdateReturnStatusRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	var returnRequest models.ReturnRequest
+	if err := database.DB.Preload("OrderItem").First(&returnRequest, returnID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Return request not found")
+	}
+
+	if returnRequest.SellerID != userID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only update returns for your products", nil)
+	}
+
+	allowed := false
+	for _, next := range returnTransitions[returnRequest.Status] {
+		if req.Status == next {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("Cannot change return from %s to %s", returnRequest.Status, req.Status))
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"status": req.Status}
+		if req.Notes != "" {
+			updates["seller_notes"] = req.Notes
+		}
+		if err := tx.Model(&returnRequest).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		// Returned goods go back into stock once received
+		if req.Status == models.ReturnReceived {
+			return inventory.Restock(tx, returnRequest.OrderItem.ProductID, returnRequest.OrderItem.VariantID, returnRequest.Quantity)
+		}
+		if req.Status == models.ReturnRefunded {
+			return ledger.RecordRefund(tx, &returnRequest)
+		}
+		return nil
+	})
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update return status", err)
+	}
+
+	if req.Status == models.ReturnReceived {
+		go inventory.AllocateItemBackorders(returnRequest.OrderItem.ProductID, returnRequest.OrderItem.VariantID)
+	}
+	returnRequest.Status = req.Status
+	h.syncIssueWithReturn(&returnRequest)
+
+	return utils.SuccessResponse(c, "Return status updated successfully", returnRequest)
+}
+
+func (h *OrderHandler) generateRMANumber() string {
+	// Generate RMA number: RMA-YYYYMMDD-XXXXXX
+	return fmt.Sprintf("RMA-%s-%06d", time.Now().Format("20060102"), rand.Intn(999999))
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+
+	"github.com/google/uuid"
+)
+
+// scheduleReviewRequest queues a review-request notification for a confirmed order
+func (h *OrderHandler) scheduleReviewRequest(order *models.Order) {
+	solicitation := models.ReviewSolicitation{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		OrderID:   order.ID,
+		BuyerID:   order.BuyerID,
+		SendAt:    time.Now().Add(time.Duration(h.config.Orders.ReviewRequestDelayHours) * time.Hour),
+		Status:    models.SolicitationScheduled,
+	}
+
+	// Unique index on order_id keeps this idempotent
+	database.DB.Where("order_id = ?", order.ID).FirstOrCreate(&solicitation)
+}
+
+// SendDueReviewRequests sends scheduled review requests, suppressing those
+// where the buyer has already reviewed every product. Run periodically by the scheduler.
+func (h *OrderHandler) SendDueReviewRequests() {
+	var solicitations []models.ReviewSolicitation
+	database.DB.Where("status = ? AND send_at <= ?", models.SolicitationScheduled, time.Now()).
+		Limit(500).
+		Find(&solicitations)
+
+	for _, solicitation := range solicitations {
+		status := models.SolicitationSuppressed
+
+		var order models.Order
+		if err := database.DB.Preload("Items.Product").First(&order, solicitation.OrderID).Error; err == nil {
+			if unreviewed := h.unreviewedProducts(&order); len(unreviewed) > 0 {
+				link := fmt.Sprintf("playful://orders/%s/review", order.ID)
+				message := fmt.Sprintf("How was your order %s? Tell others what you think of %s.", order.OrderNumber, strings.Join(unreviewed, ", "))
+				if err := notifications.SendWithLink(order.BuyerID, models.NotificationReview, "Rate your purchase", message, link); err != nil {
+					continue // Retry on next run
+				}
+				status = models.SolicitationSent
+			}
+		}
+
+		now := time.Now()
+		database.DB.Model(&solicitation).Updates(map[string]interface{}{
+			"status":       status,
+			"processed_at": now,
+		})
+	}
+}
+
+func (h *OrderHandler) unreviewedProducts(order *models.Order) []string {
+	productIDs := make([]uuid.UUID, 0, len(order.Items))
+	for _, item := range order.Items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+
+	var reviewedIDs []uuid.UUID
+	database.DB.Model(&models.Review{}).Where("buyer_id = ? AND product_id IN ?", order.BuyerID, productIDs).Pluck("product_id", &reviewedIDs)
+
+	reviewed := make(map[uuid.UUID]bool, len(reviewedIDs))
+	for _, id := range reviewedIDs {
+		reviewed[id] = true
+	}
+
+	var names []string
+	for _, item := range order.Items {
+		if !reviewed[item.ProductID] {
+			names = append(names, item.Product.Name)
+			reviewed[item.ProductID] = true
+		}
+	}
+	return names
+}
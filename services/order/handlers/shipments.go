@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type DeliveryProofRequest struct {
+	ShipmentID   *uuid.UUID `json:"shipment_id"` // Defaults to the most recent shipment
+	PhotoURL     string     `json:"photo_url"`
+	SignatureURL string     `json:"signature_url"`
+	OTP          string     `json:"otp"` // Delivery code entered by the buyer
+}
+
+// @Summary Get order shipment
+// @Description Get the most recent shipment and its proof of delivery for an order (buyer or seller)
+// @Tags fulfillment
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Success 200 {object} utils.Response{data=models.Shipment}
+// @Failure 404 {object} utils.Problem
+// @Router /orders/{id}/shipment [get]
+func (h *OrderHandler) GetShipment(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid order ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var order models.Order
+	if err := database.DB.Preload("Items.Product").First(&order, orderID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Order not found")
+	}
+
+	if !h.isOrderParticipant(&order, userID) {
+		return utils.NotFoundResponse(c, "Order not found")
+	}
+
+	var shipment models.Shipment
+	if err := database.DB.Preload("Items").Where("order_id = ?", orderID).Order("created_at DESC").First(&shipment).Error; err != nil {
+		return utils.NotFoundResponse(c, "Shipment not found")
+	}
+
+	return utils.SuccessResponse(c, "Shipment retrieved successfully", shipment)
+}
+
+// @Summary Attach proof of delivery
+// @Description Attach a delivery photo, signature, or buyer-entered delivery code to an order's shipment (seller only)
+// @Tags fulfillment
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param request body DeliveryProofRequest true "Delivery proof"
+// @Success 200 {object} utils.Response{data=models.Shipment}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /orders/{id}/delivery-proof [post]
+func (h *OrderHandler) AttachDeliveryProof(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid order ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req DeliveryProofRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	var order models.Order
+	if err := database.DB.Preload("Items.Product").First(&order, orderID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Order not found")
+	}
+
+	if !h.isOrderSeller(&order, userID) {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only attach proof for orders containing your products", nil)
+	}
+
+	shipment, err := h.captureDeliveryProof(&order, userID, &req)
+	if err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	return utils.SuccessResponse(c, "Proof of delivery attached successfully", shipment)
+}
+
+// Helper functions
+
+// createShipment ships the given items of an order together and sends the
+// delivery code for them
+func (h *OrderHandler) createShipment(order *models.Order, items []models.OrderItem, carrier, trackingNumber string) (*models.Shipment, error) {
+	// Delivery code the buyer gives the courier on handover
+	otp := fmt.Sprintf("%06d", rand.Intn(1000000))
+	now := time.Now()
+
+	shipment := models.Shipment{
+		BaseModel:       models.BaseModel{ID: uuid.New()},
+		OrderID:         order.ID,
+		Carrier:         carrier,
+		TrackingNumber:  trackingNumber,
+		ShippedAt:       &now,
+		DeliveryOTPHash: hashDeliveryOTP(otp),
+	}
+
+	itemIDs := make([]uuid.UUID, len(items))
+	for i, item := range items {
+		itemIDs[i] = item.ID
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&shipment).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.OrderItem{}).Where("id IN ?", itemIDs).Updates(map[string]interface{}{
+			"status":      models.ItemShipped,
+			"shipment_id": shipment.ID,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	what := fmt.Sprintf("Order %s is", order.OrderNumber)
+	if len(items) < len(order.Items) {
+		what = fmt.Sprintf("Part of order %s (%d of %d items) is", order.OrderNumber, len(items), len(order.Items))
+	}
+
+	// Gift recipients receive the package, so they get the delivery code
+	if order.IsGift {
+		notifications.Send(order.BuyerID, models.NotificationOrder, "Your gift has shipped",
+			fmt.Sprintf("%s on its way to %s.", what, order.GiftRecipientName))
+		notifications.SendSMS(order.GiftRecipientPhone,
+			fmt.Sprintf("Hi %s, a gift is on its way to you! Share delivery code %s with the courier when you receive it.", order.GiftRecipientName, otp))
+		return &shipment, nil
+	}
+
+	notifications.Send(order.BuyerID, models.NotificationOrder, "Your order has shipped",
+		fmt.Sprintf("%s on its way. Share delivery code %s with the courier when you receive it.", what, otp))
+
+	return &shipment, nil
+}
+
+// captureDeliveryProof validates and stores proof of delivery on one of the order's shipments
+func (h *OrderHandler) captureDeliveryProof(order *models.Order, capturedBy uuid.UUID, req *DeliveryProofRequest) (*models.Shipment, error) {
+	var shipment models.Shipment
+	if req != nil && req.ShipmentID != nil {
+		if err := database.DB.Where("id = ? AND order_id = ?", *req.ShipmentID, order.ID).First(&shipment).Error; err != nil {
+			return nil, errors.New("Shipment not found")
+		}
+	} else if err := database.DB.Where("order_id = ?", order.ID).Order("created_at DESC").First(&shipment).Error; err != nil {
+		shipment = models.Shipment{
+			BaseModel: models.BaseModel{ID: uuid.New()},
+			OrderID:   order.ID,
+		}
+	}
+
+	if req == nil || (req.PhotoURL == "" && req.SignatureURL == "" && req.OTP == "") {
+		if h.isCashOnDelivery(order.ID) {
+			return nil, errors.New("Proof of delivery (photo, signature or delivery code) is required for cash on delivery orders")
+		}
+		return &shipment, nil
+	}
+
+	if req.OTP != "" {
+		if shipment.DeliveryOTPHash == "" || hashDeliveryOTP(req.OTP) != shipment.DeliveryOTPHash {
+			return nil, errors.New("Invalid delivery code")
+		}
+		shipment.ProofOTPVerified = true
+		shipment.ProofType = models.ProofOTP
+	}
+	if req.SignatureURL != "" {
+		shipment.ProofSignatureURL = req.SignatureURL
+		if shipment.ProofType == "" {
+			shipment.ProofType = models.ProofSignature
+		}
+	}
+	if req.PhotoURL != "" {
+		shipment.ProofPhotoURL = req.PhotoURL
+		if shipment.ProofType == "" {
+			shipment.ProofType = models.ProofPhoto
+		}
+	}
+
+	now := time.Now()
+	shipment.ProofCapturedBy = &capturedBy
+	shipment.ProofCapturedAt = &now
+
+	if err := database.DB.Save(&shipment).Error; err != nil {
+		return nil, errors.New("Failed to save proof of delivery")
+	}
+
+	return &shipment, nil
+}
+
+func (h *OrderHandler) isCashOnDelivery(orderID uuid.UUID) bool {
+	var count int64
+	database.DB.Model(&models.Payment{}).Where("order_id = ? AND method = ?", orderID, models.PaymentCash).Count(&count)
+	return count > 0
+}
+
+func (h *OrderHandler) isOrderSeller(order *models.Order, userID uuid.UUID) bool {
+	for _, item := range order.Items {
+		if item.Product.SellerID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *OrderHandler) isOrderParticipant(order *models.Order, userID uuid.UUID) bool {
+	return order.BuyerID == userID || h.isOrderSeller(order, userID)
+}
+
+func hashDeliveryOTP(otp string) string {
+	hash := sha256.Sum256([]byte(otp))
+	return hex.EncodeToString(hash[:])
+}
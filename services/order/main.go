@@ -2,13 +2,18 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"playful-marketplace/services/order/handlers"
 	"playful-marketplace/services/order/routes"
 	"playful-marketplace/shared/config"
 	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/jobs"
 	"playful-marketplace/shared/middleware"
 	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/regions"
+	"playful-marketplace/shared/scheduler"
+	"playful-marketplace/shared/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/recover"
@@ -33,29 +38,36 @@ func main() {
 		log.Fatal("Failed to connect to Redis:", err)
 	}
 
+	// Load region configuration
+	regions.Init(cfg)
+
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
-		AppName: "Playful Marketplace Order Service",
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			code := fiber.StatusInternalServerError
-			if e, ok := err.(*fiber.Error); ok {
-				code = e.Code
-			}
-			return c.Status(code).JSON(fiber.Map{
-				"success": false,
-				"message": "Internal Server Error",
-				"error":   err.Error(),
-			})
-		},
+		AppName:      "Playful Marketplace Order Service",
+		ErrorHandler: utils.FiberErrorHandler,
 	})
 
 	// Middleware
+	app.Use(middleware.TraceMiddleware())
 	app.Use(recover.New())
 	app.Use(middleware.CORSMiddleware())
 	app.Use(middleware.LoggingMiddleware())
+	app.Use(middleware.FaultMiddleware(cfg))
+	app.Use(middleware.MaintenanceMiddleware(cfg, "order"))
+	app.Use(middleware.RateLimitMiddleware(cfg))
+	app.Use(middleware.UsageMiddleware())
 
 	// Initialize handlers
 	orderHandler := handlers.NewOrderHandler(cfg)
+	orderHandler.RegisterJobs()
+	jobs.Start(cfg.Jobs)
+
+	// Background jobs
+	scheduler.Every("auto-confirm-receipt", time.Hour, orderHandler.AutoConfirmDeliveredOrders)
+	scheduler.Every("review-requests", 15*time.Minute, orderHandler.SendDueReviewRequests)
+	scheduler.Every("abandoned-cart-reminders", 15*time.Minute, orderHandler.SendAbandonedCartReminders)
+	scheduler.Every("issue-sla", 15*time.Minute, orderHandler.FlagIssueSLABreaches)
+	scheduler.Every("stock-reservations", time.Minute, orderHandler.ReleaseExpiredReservations)
 
 	// Health check
 	app.Get("/health", func(c *fiber.Ctx) error {
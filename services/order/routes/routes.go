@@ -6,21 +6,84 @@ import (
 	"playful-marketplace/shared/middleware"
 	"playful-marketplace/shared/models"
 
+	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
 )
 
 func SetupOrderRoutes(api fiber.Router, orderHandler *handlers.OrderHandler, cfg *config.Config) {
-	orders := api.Group("/orders", middleware.AuthMiddleware(cfg))
+	orders := api.Group("/orders", middleware.AuthOrAPIKeyMiddleware(cfg, "orders"))
 
 	// Order routes
 	orders.Post("/", orderHandler.CreateOrder)
 	orders.Get("/:id", orderHandler.GetOrder)
+
+	// Return requests
+	orders.Post("/:id/returns", orderHandler.CreateReturn)
+	orders.Get("/:id/returns", orderHandler.GetOrderReturns)
+	orders.Get("/:id/shipment", orderHandler.GetShipment)
+	orders.Get("/:id/shipments", orderHandler.GetShipments)
+	orders.Post("/:id/confirm-receipt", orderHandler.ConfirmReceipt)
+	orders.Get("/:id/invoices", orderHandler.GetOrderInvoices)
+
+	// Order issue quick-actions
+	orders.Post("/:id/issues", orderHandler.ReportIssue)
+	orders.Get("/:id/issues", orderHandler.GetOrderIssues)
+	orders.Post("/:id/protection", orderHandler.FileProtectionClaim)
 	
 	// Seller-only routes
 	sellerOnly := orders.Group("", middleware.RoleMiddleware(models.RoleSeller))
 	sellerOnly.Put("/:id/status", orderHandler.UpdateOrderStatus)
+	sellerOnly.Get("/:id/packing-slip", orderHandler.GetPackingSlip)
+	sellerOnly.Post("/:id/delivery-proof", orderHandler.AttachDeliveryProof)
+
+	// Item-level fulfillment and partial shipments
+	sellerOnly.Post("/:id/shipments", orderHandler.CreatePartialShipment)
+	sellerOnly.Post("/:id/shipments/:shipment_id/deliver", orderHandler.DeliverShipment)
+	sellerOnly.Put("/:id/items/:item_id/status", orderHandler.UpdateOrderItemStatus)
 
 	// User orders
-	users := api.Group("/users", middleware.AuthMiddleware(cfg))
+	users := api.Group("/users", middleware.AuthOrAPIKeyMiddleware(cfg, "orders"))
 	users.Get("/:id/orders", orderHandler.GetUserOrders)
+
+	// Seller fulfillment
+	sellers := api.Group("/sellers", middleware.AuthOrAPIKeyMiddleware(cfg, "orders"), middleware.RoleMiddleware(models.RoleSeller))
+	sellers.Get("/:id/pick-list", orderHandler.GetPickList)
+	sellers.Get("/:id/invoices", orderHandler.GetSellerInvoices)
+	sellers.Post("/:id/live-ticket", orderHandler.CreateLiveTicket)
+
+	// Live order dashboard, authenticated by its ticket
+	api.Get("/live/orders", orderHandler.LiveOrders, websocket.New(orderHandler.StreamLiveOrders))
+
+	// Seller return handling
+	returns := api.Group("/returns", middleware.AuthOrAPIKeyMiddleware(cfg, "orders"), middleware.RoleMiddleware(models.RoleSeller))
+	returns.Put("/:id/status", orderHandler.UpdateReturnStatus)
+
+	// Seller responses to reported order issues
+	issues := api.Group("/issues", middleware.AuthOrAPIKeyMiddleware(cfg, "orders"), middleware.RoleMiddleware(models.RoleSeller))
+	issues.Post("/:id/respond", orderHandler.RespondToIssue)
+
+	// Server-side cart, shared by guests and signed-in buyers
+	cart := api.Group("/cart", middleware.ScopedAuthMiddleware(cfg, "orders"))
+	cart.Get("/", orderHandler.GetCart)
+	cart.Delete("/", orderHandler.ClearCart)
+	cart.Post("/items", orderHandler.AddCartItem)
+	cart.Put("/items/:product_id", orderHandler.UpdateCartItem)
+	cart.Delete("/items/:product_id", orderHandler.RemoveCartItem)
+
+	// Seller and platform coupon campaigns
+	coupons := api.Group("/coupons", middleware.AuthMiddleware(cfg), middleware.RoleMiddleware(models.RoleSeller, models.RoleAdmin))
+	coupons.Post("/", orderHandler.CreateCoupon)
+	coupons.Get("/", orderHandler.GetCoupons)
+	coupons.Get("/:id/analytics", orderHandler.GetCouponAnalytics)
+
+	// Admin fraud review queue
+	admin := api.Group("/admin", middleware.AuthMiddleware(cfg), middleware.PermissionMiddleware(middleware.PermFraudReview))
+	admin.Get("/fraud/reviews", orderHandler.GetFraudReviews)
+	admin.Post("/fraud/reviews/:id", orderHandler.ReviewFraudAssessment)
+
+	// Support queue for disputes and tickets raised from order issues
+	support := api.Group("/admin/issues", middleware.AuthMiddleware(cfg), middleware.PermissionMiddleware(middleware.PermSupport))
+	support.Get("/", orderHandler.GetIssueQueue)
+	support.Post("/:id/resolve", orderHandler.ResolveIssue)
+	support.Post("/:id/protection-payout", orderHandler.PayProtectionClaim)
 }
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+
+	"playful-marketplace/shared/analytics"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CohortTotals struct {
+	Customers        int64   `json:"customers"`
+	Purchasers       int64   `json:"purchasers"`
+	RepeatPurchasers int64   `json:"repeat_purchasers"`
+	RepeatRate       float64 `json:"repeat_rate"`
+	Orders           int64   `json:"orders"`
+	Revenue          float64 `json:"revenue"`
+	LTV              float64 `json:"ltv"`
+}
+
+type CohortReportResponse struct {
+	From    string             `json:"from"`
+	To      string             `json:"to"`
+	Cohorts []analytics.Cohort `json:"cohorts"`
+	Totals  CohortTotals       `json:"totals"`
+}
+
+// @Summary Get customer cohort report
+// @Description Monthly signup cohorts with repeat purchase rates, retention and lifetime value per customer, from orders with a completed payment (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param from query string false "First signup month (YYYY-MM)" default(11 months ago)
+// @Param to query string false "Last signup month (YYYY-MM), inclusive" default(this month)
+// @Param format query string false "Response format: json or csv" default(json)
+// @Param metric query string false "Monthly columns in the CSV: retention or ltv" default(retention)
+// @Success 200 {object} utils.Response{data=CohortReportResponse}
+// @Failure 400 {object} utils.Problem
+// @Router /admin/reports/cohorts [get]
+func (h *PaymentHandler) GetCohortReport(c *fiber.Ctx) error {
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	from := to.AddDate(0, -11, 0)
+	var err error
+	if fromParam := c.Query("from"); fromParam != "" {
+		if from, err = time.Parse("2006-01", fromParam); err != nil {
+			return utils.ValidationErrorResponse(c, "From month must be in YYYY-MM format")
+		}
+	}
+	if toParam := c.Query("to"); toParam != "" {
+		if to, err = time.Parse("2006-01", toParam); err != nil {
+			return utils.ValidationErrorResponse(c, "To month must be in YYYY-MM format")
+		}
+	}
+	if to.Before(from) {
+		return utils.ValidationErrorResponse(c, "To month must not be before from month")
+	}
+
+	metric := c.Query("metric", "retention")
+	if metric != "retention" && metric != "ltv" {
+		return utils.ValidationErrorResponse(c, "Metric must be retention or ltv")
+	}
+
+	cohorts, err := analytics.Cohorts(from, to.AddDate(0, 1, 0))
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to build cohort report", err)
+	}
+
+	if c.Query("format") == "csv" {
+		return h.sendCohortCSV(c, cohorts, metric, from, to)
+	}
+
+	var totals CohortTotals
+	for _, cohort := range cohorts {
+		totals.Customers += cohort.Customers
+		totals.Purchasers += cohort.Purchasers
+		totals.RepeatPurchasers += cohort.RepeatPurchasers
+		totals.Orders += cohort.Orders
+		totals.Revenue += cohort.Revenue
+	}
+	if totals.Purchasers > 0 {
+		totals.RepeatRate = float64(totals.RepeatPurchasers) / float64(totals.Purchasers)
+	}
+	if totals.Customers > 0 {
+		totals.LTV = totals.Revenue / float64(totals.Customers)
+	}
+
+	response := CohortReportResponse{
+		From:    from.Format("2006-01"),
+		To:      to.Format("2006-01"),
+		Cohorts: cohorts,
+		Totals:  totals,
+	}
+
+	return utils.SuccessResponse(c, "Cohort report generated successfully", response)
+}
+
+// sendCohortCSV writes a row per cohort followed by the metric for each
+// month since signup, blank for months still to come
+func (h *PaymentHandler) sendCohortCSV(c *fiber.Ctx, cohorts []analytics.Cohort, metric string, from, to time.Time) error {
+	months := 0
+	for _, cohort := range cohorts {
+		if len(cohort.Retention) > months {
+			months = len(cohort.Retention)
+		}
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	header := []string{"cohort", "customers", "purchasers", "repeat_purchasers", "repeat_rate", "orders", "revenue", "ltv"}
+	for i := 0; i < months; i++ {
+		header = append(header, "month_"+strconv.Itoa(i))
+	}
+	writer.Write(header)
+
+	for _, cohort := range cohorts {
+		row := []string{
+			cohort.Month.Format("2006-01"),
+			strconv.FormatInt(cohort.Customers, 10),
+			strconv.FormatInt(cohort.Purchasers, 10),
+			strconv.FormatInt(cohort.RepeatPurchasers, 10),
+			fmt.Sprintf("%.4f", cohort.RepeatRate),
+			strconv.FormatInt(cohort.Orders, 10),
+			fmt.Sprintf("%.2f", cohort.Revenue),
+			fmt.Sprintf("%.2f", cohort.LTV),
+		}
+		values := cohort.Retention
+		format := "%.4f"
+		if metric == "ltv" {
+			values = cohort.CumulativeLTV
+			format = "%.2f"
+		}
+		for i := 0; i < months; i++ {
+			if i < len(values) {
+				row = append(row, fmt.Sprintf(format, values[i]))
+			} else {
+				row = append(row, "")
+			}
+		}
+		writer.Write(row)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to write CSV", err)
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="cohorts-%s-%s-to-%s.csv"`,
+		metric, from.Format("200601"), to.Format("200601")))
+
+	return c.Send(buf.Bytes())
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"testing"
+
+	"playful-marketplace/shared/contracts/contracttest"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+func TestOrderContract(t *testing.T) {
+	pact := contracttest.Load(t, "order", "payment")
+
+	for _, description := range []string{
+		"initiate a mobile payment for a pending order",
+		"initiate a cash on delivery payment",
+	} {
+		interaction := pact.Interaction(t, description)
+
+		var req InitiatePaymentRequest
+		interaction.DecodeRequest(t, &req)
+		if err := req.Validate(); err != nil {
+			t.Errorf("%s: request rejected: %v", description, err)
+		}
+
+		var resp MockPaymentResponse
+		interaction.DecodeResponse(t, &resp)
+	}
+
+	// Requests the payment service rejects before looking the order up
+	h := NewPaymentHandler(nil)
+	app := fiber.New()
+	app.Post("/payments/initiate", func(c *fiber.Ctx) error {
+		c.Locals("user_id", uuid.New())
+		return c.Next()
+	}, h.InitiatePayment)
+
+	pact.Interaction(t, "reject a payment without a method").Replay(t, app)
+}
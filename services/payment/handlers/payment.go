@@ -1,15 +1,28 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"math/rand"
 	"time"
 
 	"playful-marketplace/shared/config"
+	"playful-marketplace/shared/contracts"
 	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/fraud"
+	"playful-marketplace/shared/inventory"
+	"playful-marketplace/shared/invoices"
+	"playful-marketplace/shared/jobs"
+	"playful-marketplace/shared/ledger"
+	"playful-marketplace/shared/live"
 	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
 	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/regions"
 	"playful-marketplace/shared/utils"
+	"playful-marketplace/shared/webhooks"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -20,24 +33,25 @@ type PaymentHandler struct {
 	config *config.Config
 }
 
-type InitiatePaymentRequest struct {
-	OrderID uuid.UUID             `json:"order_id" validate:"required"`
-	Method  models.PaymentMethod  `json:"method" validate:"required"`
-	Phone   string                `json:"phone"` // Required for mobile payments
+// Background job kinds
+const (
+	jobSimulatePayment = "payment.simulate_completion"
+	jobPaymentXP       = "payment.award_xp"
+	jobIssueInvoices   = "payment.issue_invoices"
+)
+
+type paymentJob struct {
+	PaymentID uuid.UUID `json:"payment_id"`
 }
 
+type InitiatePaymentRequest = contracts.InitiatePaymentRequest
+
 type PaymentStatusResponse struct {
 	*models.Payment
 	Order *models.Order `json:"order,omitempty"`
 }
 
-type MockPaymentResponse struct {
-	TransactionID string `json:"transaction_id"`
-	Reference     string `json:"reference"`
-	Status        string `json:"status"`
-	Message       string `json:"message"`
-	RedirectURL   string `json:"redirect_url,omitempty"`
-}
+type MockPaymentResponse = contracts.PaymentResponse
 
 func NewPaymentHandler(cfg *config.Config) *PaymentHandler {
 	return &PaymentHandler{
@@ -45,14 +59,23 @@ func NewPaymentHandler(cfg *config.Config) *PaymentHandler {
 	}
 }
 
+// RegisterJobs registers the handlers for the jobs this service enqueues
+func (h *PaymentHandler) RegisterJobs() {
+	jobs.Handle(jobSimulatePayment, h.simulateAsyncPaymentCompletion)
+	jobs.Handle(jobPaymentXP, h.awardPaymentXP)
+	jobs.Handle(jobIssueInvoices, h.issueInvoices)
+	jobs.Handle(jobSimulatePromotionPayment, h.simulatePromotionPayment)
+	webhooks.RegisterJobs()
+}
+
 // @Summary Initiate payment
-// @Description Initiate payment for an order using Telebirr, CBE Birr, or Cash
+// @Description Initiate payment for an order using one of the payment methods available in its region. Mobile payments take a phone number or a saved, verified payment_phone_id.
 // @Tags payments
 // @Security BearerAuth
 // @Param request body InitiatePaymentRequest true "Initiate payment request"
 // @Success 200 {object} utils.Response{data=MockPaymentResponse}
-// @Failure 400 {object} utils.Response
-// @Failure 404 {object} utils.Response
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
 // @Router /payments/initiate [post]
 func (h *PaymentHandler) InitiatePayment(c *fiber.Ctx) error {
 	userID, ok := c.Locals("user_id").(uuid.UUID)
@@ -65,26 +88,8 @@ func (h *PaymentHandler) InitiatePayment(c *fiber.Ctx) error {
 		return utils.ValidationErrorResponse(c, "Invalid request body")
 	}
 
-	// Validate payment method
-	validMethods := []models.PaymentMethod{
-		models.PaymentTelebirr, models.PaymentCBEBirr, models.PaymentCash,
-	}
-	
-	isValidMethod := false
-	for _, method := range validMethods {
-		if req.Method == method {
-			isValidMethod = true
-			break
-		}
-	}
-	
-	if !isValidMethod {
-		return utils.ValidationErrorResponse(c, "Invalid payment method")
-	}
-
-	// Validate phone for mobile payments
-	if (req.Method == models.PaymentTelebirr || req.Method == models.PaymentCBEBirr) && req.Phone == "" {
-		return utils.ValidationErrorResponse(c, "Phone number is required for mobile payments")
+	if err := req.Validate(); err != nil {
+		return utils.ValidationErrorResponse(c, "Order ID and payment method are required")
 	}
 
 	// Get order
@@ -103,6 +108,32 @@ func (h *PaymentHandler) InitiatePayment(c *fiber.Ctx) error {
 		return utils.ValidationErrorResponse(c, "Order is not in pending status")
 	}
 
+	// Payment methods and mobile numbers depend on where the order was placed
+	region, ok := regions.Get(order.Region)
+	if !ok {
+		region = regions.Default()
+	}
+	method, ok := region.PaymentMethod(req.Method)
+	if !ok {
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("Payment method is not available in %s", region.Name))
+	}
+	if req.PaymentPhoneID != nil {
+		var saved models.PaymentPhone
+		if err := database.DB.Where("id = ? AND user_id = ?", *req.PaymentPhoneID, userID).First(&saved).Error; err != nil {
+			return utils.NotFoundResponse(c, "Payment phone not found")
+		}
+		if saved.VerifiedAt == nil {
+			return utils.ValidationErrorResponse(c, "Verify the payment phone before paying with it")
+		}
+		req.Phone = saved.Phone
+	}
+	if method.RequiresPhone && req.Phone == "" {
+		return utils.ValidationErrorResponse(c, "Phone number is required for mobile payments")
+	}
+	if method.RequiresPhone && !region.ValidPhone(req.Phone) {
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("Phone must be a %s mobile number", region.Name))
+	}
+
 	// Check if payment already exists
 	var existingPayment models.Payment
 	if err := database.DB.Where("order_id = ?", req.OrderID).First(&existingPayment).Error; err == nil {
@@ -114,6 +145,27 @@ func (h *PaymentHandler) InitiatePayment(c *fiber.Ctx) error {
 		}
 	}
 
+	// Score the payment attempt and hold high-risk orders for manual review
+	var buyer models.User
+	if err := database.DB.First(&buyer, userID).Error; err == nil {
+		if assessment := fraud.ScorePayment(&buyer, req.Phone); assessment.Score >= h.config.Fraud.ReviewThreshold {
+			if err := fraud.Hold(database.DB, &order, fraud.StagePayment, assessment); err != nil {
+				return utils.InternalServerErrorResponse(c, "Failed to hold order for review", err)
+			}
+			return utils.ErrorResponse(c, fiber.StatusForbidden, "Order has been held for review", nil)
+		}
+	}
+
+	// Stock released after an earlier payment failed has to be taken again
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return inventory.Rereserve(tx, order.ID, h.config.Orders.ReservationTTL)
+	}); err != nil {
+		if errors.Is(err, inventory.ErrInsufficientStock) {
+			return utils.ValidationErrorResponse(c, "Some items in this order are no longer in stock")
+		}
+		return utils.InternalServerErrorResponse(c, "Failed to reserve stock", err)
+	}
+
 	// Create payment record
 	payment := models.Payment{
 		BaseModel: models.BaseModel{ID: uuid.New()},
@@ -165,8 +217,7 @@ func (h *PaymentHandler) InitiatePayment(c *fiber.Ctx) error {
 		"created_at":     time.Now(),
 	}
 	
-	sessionKey := fmt.Sprintf("payment_session:%s", response.TransactionID)
-	redis.Set(sessionKey, paymentSession, 30*60) // 30 minutes
+	redis.Set(redis.PaymentSessionKey(response.TransactionID), paymentSession)
 
 	return utils.SuccessResponse(c, "Payment initiated successfully", response)
 }
@@ -177,7 +228,7 @@ func (h *PaymentHandler) InitiatePayment(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Param id path string true "Payment ID or Transaction ID"
 // @Success 200 {object} utils.Response{data=PaymentStatusResponse}
-// @Failure 404 {object} utils.Response
+// @Failure 404 {object} utils.Problem
 // @Router /payments/status/{id} [get]
 func (h *PaymentHandler) GetPaymentStatus(c *fiber.Ctx) error {
 	paymentID := c.Params("id")
@@ -217,39 +268,22 @@ func (h *PaymentHandler) GetPaymentStatus(c *fiber.Ctx) error {
 }
 
 // @Summary Get payment methods
-// @Description Get available payment methods with their details
+// @Description Get the payment methods available in a region with their details
 // @Tags payments
-// @Success 200 {object} utils.Response{data=[]map[string]interface{}}
+// @Param region query string false "Region code" default(ET)
+// @Success 200 {object} utils.Response{data=[]regions.PaymentMethod}
+// @Failure 400 {object} utils.Problem
 // @Router /payments/methods [get]
 func (h *PaymentHandler) GetPaymentMethods(c *fiber.Ctx) error {
-	methods := []map[string]interface{}{
-		{
-			"method":      models.PaymentTelebirr,
-			"name":        "Telebirr",
-			"description": "Pay using Telebirr mobile wallet",
-			"icon":        "telebirr-icon.png",
-			"requires_phone": true,
-			"processing_fee": 0.02, // 2%
-		},
-		{
-			"method":      models.PaymentCBEBirr,
-			"name":        "CBE Birr",
-			"description": "Pay using Commercial Bank of Ethiopia mobile banking",
-			"icon":        "cbe-icon.png",
-			"requires_phone": true,
-			"processing_fee": 0.015, // 1.5%
-		},
-		{
-			"method":      models.PaymentCash,
-			"name":        "Cash on Delivery",
-			"description": "Pay with cash when your order is delivered",
-			"icon":        "cash-icon.png",
-			"requires_phone": false,
-			"processing_fee": 0.0, // No fee
-		},
-	}
-
-	return utils.SuccessResponse(c, "Payment methods retrieved successfully", methods)
+	region := regions.Default()
+	if code := c.Query("region"); code != "" {
+		var ok bool
+		if region, ok = regions.Get(code); !ok {
+			return utils.ValidationErrorResponse(c, "Unknown region")
+		}
+	}
+
+	return utils.SuccessResponse(c, "Payment methods retrieved successfully", region.PaymentMethods)
 }
 
 // Mock payment processing functions
@@ -273,7 +307,7 @@ func (h *PaymentHandler) processTelebirrPayment(payment *models.Payment, phone s
 	}
 
 	// Simulate async payment completion (in real scenario, this would be a webhook)
-	go h.simulateAsyncPaymentCompletion(payment, 10*time.Second)
+	jobs.EnqueueIn(jobSimulatePayment, paymentJob{PaymentID: payment.ID}, 10*time.Second)
 
 	return response, nil
 }
@@ -292,7 +326,7 @@ func (h *PaymentHandler) processCBEBirrPayment(payment *models.Payment, phone st
 	}
 
 	// Simulate async payment completion
-	go h.simulateAsyncPaymentCompletion(payment, 15*time.Second)
+	jobs.EnqueueIn(jobSimulatePayment, paymentJob{PaymentID: payment.ID}, 15*time.Second)
 
 	return response, nil
 }
@@ -308,9 +342,13 @@ func (h *PaymentHandler) processCashPayment(payment *models.Payment) (MockPaymen
 		"transaction_id": transactionID,
 		"reference":      reference,
 	})
+	ledger.RecordPayment(database.DB, payment)
+	inventory.ConfirmReservations(database.DB, payment.OrderID)
 
 	// Update order status to confirmed
 	database.DB.Model(&models.Order{}).Where("id = ?", payment.OrderID).Update("status", models.OrderConfirmed)
+	jobs.Enqueue(jobIssueInvoices, paymentJob{PaymentID: payment.ID})
+	go live.PublishOrder(payment.OrderID, live.EventPaymentCompleted)
 
 	response := MockPaymentResponse{
 		TransactionID: transactionID,
@@ -334,32 +372,84 @@ func (h *PaymentHandler) generateReference() string {
 	return fmt.Sprintf("REF%d%04d", time.Now().Unix(), rand.Intn(9999))
 }
 
-func (h *PaymentHandler) simulateAsyncPaymentCompletion(payment *models.Payment, delay time.Duration) {
-	time.Sleep(delay)
-	
+func (h *PaymentHandler) simulateAsyncPaymentCompletion(payload []byte) error {
+	var job paymentJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+
+	var payment models.Payment
+	if err := database.DB.First(&payment, job.PaymentID).Error; err != nil {
+		return err
+	}
+	// A status check may have settled it already
+	if payment.Status != models.PaymentPending {
+		return nil
+	}
+
 	// 85% success rate for mobile payments
 	if rand.Float32() < 0.85 {
-		h.completePayment(payment)
+		h.completePayment(&payment)
 	} else {
-		h.failPayment(payment, "Payment declined by provider")
+		h.failPayment(&payment, "Payment declined by provider")
 	}
+	return nil
 }
 
 func (h *PaymentHandler) completePayment(payment *models.Payment) {
 	// Update payment status
 	database.DB.Model(payment).Update("status", models.PaymentCompleted)
+	ledger.RecordPayment(database.DB, payment)
+	inventory.ConfirmReservations(database.DB, payment.OrderID)
+
+	// The order may have changed since the payment amount was fixed, so
+	// re-verify it and hold mismatches for manual review instead of confirming
+	if h.holdChangedOrder(payment) {
+		h.notifyBuyer(payment, "Payment under review", func(orderNumber string) string {
+			return fmt.Sprintf("We received your payment of %.2f for order %s. Your order changed since checkout, so we're reviewing it before it's confirmed.", payment.Amount, orderNumber)
+		})
+	} else {
+		// Update order status
+		database.DB.Model(&models.Order{}).Where("id = ?", payment.OrderID).Update("status", models.OrderConfirmed)
+		jobs.Enqueue(jobIssueInvoices, paymentJob{PaymentID: payment.ID})
+		h.notifyBuyer(payment, "Payment received", func(orderNumber string) string {
+			return fmt.Sprintf("We received your payment of %.2f for order %s.", payment.Amount, orderNumber)
+		})
+	}
 
-	// Update order status
-	database.DB.Model(&models.Order{}).Where("id = ?", payment.OrderID).Update("status", models.OrderConfirmed)
+	go live.PublishOrder(payment.OrderID, live.EventPaymentCompleted)
 
 	// Clear payment session
 	if payment.TransactionID != "" {
-		sessionKey := fmt.Sprintf("payment_session:%s", payment.TransactionID)
-		redis.Delete(sessionKey)
+		redis.Delete(redis.PaymentSessionKey(payment.TransactionID))
 	}
 
 	// Award XP for successful payment (async)
-	go h.awardPaymentXP(payment)
+	jobs.Enqueue(jobPaymentXP, paymentJob{PaymentID: payment.ID})
+}
+
+// holdChangedOrder places the payment's order on hold when its current
+// total no longer matches the amount paid, and reports whether it did
+func (h *PaymentHandler) holdChangedOrder(payment *models.Payment) bool {
+	var order models.Order
+	if err := database.DB.Preload("Items").First(&order, payment.OrderID).Error; err != nil {
+		return false
+	}
+
+	assessment := fraud.ScoreSettlement(&order, payment.Amount)
+	if len(assessment.Reasons) == 0 {
+		return false
+	}
+
+	// Cancelled orders stay cancelled, with the payment flagged for follow-up
+	hold := fraud.Hold
+	if order.Status == models.OrderCancelled {
+		hold = fraud.Flag
+	}
+	if err := hold(database.DB, &order, fraud.StageSettlement, assessment); err != nil {
+		log.Printf("Failed to hold order %s for settlement review: %v", order.ID, err)
+	}
+	return true
 }
 
 func (h *PaymentHandler) failPayment(payment *models.Payment, reason string) {
@@ -367,29 +457,96 @@ func (h *PaymentHandler) failPayment(payment *models.Payment, reason string) {
 	database.DB.Model(payment).Updates(map[string]interface{}{
 		"status": models.PaymentFailed,
 	})
+	h.releaseStock(payment)
+	h.notifyBuyer(payment, "Payment failed", func(orderNumber string) string {
+		return fmt.Sprintf("Your payment of %.2f for order %s didn't go through: %s.", payment.Amount, orderNumber, reason)
+	})
+	go live.PublishOrder(payment.OrderID, live.EventPaymentFailed)
 
 	// Clear payment session
 	if payment.TransactionID != "" {
-		sessionKey := fmt.Sprintf("payment_session:%s", payment.TransactionID)
-		redis.Delete(sessionKey)
+		redis.Delete(redis.PaymentSessionKey(payment.TransactionID))
 	}
 }
 
-func (h *PaymentHandler) awardPaymentXP(payment *models.Payment) {
-	// Get order to find buyer
+// releaseStock puts the stock held for the payment's order back until the
+// buyer tries paying again
+func (h *PaymentHandler) releaseStock(payment *models.Payment) {
+	var released []models.StockReservation
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		released, err = inventory.ReleaseReservations(tx, payment.OrderID, models.ReservationHeld)
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to release stock for order %s: %v", payment.OrderID, err)
+		return
+	}
+
+	// Released stock can fill waiting backorders
+	for _, reservation := range released {
+		go inventory.AllocateItemBackorders(reservation.ProductID, reservation.VariantID)
+	}
+}
+
+// notifyBuyer tells the order's buyer how their payment went
+func (h *PaymentHandler) notifyBuyer(payment *models.Payment, title string, message func(orderNumber string) string) {
 	var order models.Order
-	if err := database.DB.First(&order, payment.OrderID).Error; err != nil {
+	if err := database.DB.Select("id", "buyer_id", "order_number").First(&order, payment.OrderID).Error; err != nil {
 		return
 	}
+	notifications.Send(order.BuyerID, models.NotificationOrder, title, message(order.OrderNumber))
+}
+
+// issueInvoices invoices the sellers on a confirmed order. Orders held at
+// settlement are invoiced once the review approves them.
+func (h *PaymentHandler) issueInvoices(payload []byte) error {
+	var job paymentJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
 
-	// Award 10 XP for successful payment
-	xpTransaction := models.XPTransaction{
-		BaseModel: models.BaseModel{ID: uuid.New()},
-		UserID:    order.BuyerID,
-		Amount:    10,
-		Reason:    "Payment Completed",
-		Reference: payment.ID.String(),
+	var payment models.Payment
+	if err := database.DB.First(&payment, job.PaymentID).Error; err != nil {
+		return err
+	}
+
+	_, err := invoices.Issue(payment.OrderID)
+	return err
+}
+
+func (h *PaymentHandler) awardPaymentXP(payload []byte) error {
+	var job paymentJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
 	}
-	database.DB.Create(&xpTransaction)
-	database.DB.Model(&models.User{}).Where("id = ?", order.BuyerID).Update("total_xp", gorm.Expr("total_xp + ?", 10))
+
+	// Get order to find buyer
+	var order models.Order
+	if err := database.DB.Joins("JOIN payments ON payments.order_id = orders.id").
+		Where("payments.id = ?", job.PaymentID).First(&order).Error; err != nil {
+		return err
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		// Already awarded by an earlier run of this job
+		var count int64
+		tx.Model(&models.XPTransaction{}).Where("reference = ? AND reason = ?", job.PaymentID.String(), "Payment Completed").Count(&count)
+		if count > 0 {
+			return nil
+		}
+
+		// Award 10 XP for successful payment
+		xpTransaction := models.XPTransaction{
+			BaseModel: models.BaseModel{ID: uuid.New()},
+			UserID:    order.BuyerID,
+			Amount:    10,
+			Reason:    "Payment Completed",
+			Reference: job.PaymentID.String(),
+		}
+		if err := tx.Create(&xpTransaction).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", order.BuyerID).Update("total_xp", gorm.Expr("total_xp + ?", 10)).Error
+	})
 }
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/jobs"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/promotions"
+	"playful-marketplace/shared/regions"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const jobSimulatePromotionPayment = "payment.simulate_promotion"
+
+type promotionPaymentJob struct {
+	PromotionID uuid.UUID `json:"promotion_id"`
+}
+
+type PayPromotionRequest struct {
+	Method models.PaymentMethod `json:"method" validate:"required"` // A mobile payment method
+	Phone  string               `json:"phone"`                      // Required unless payment_phone_id is given
+
+	PaymentPhoneID *uuid.UUID `json:"payment_phone_id"` // A verified phone saved under /users/:id/payment-phones
+}
+
+// @Summary Pay for a promotion
+// @Description Pay a promotion's budget by mobile money. The promotion goes live once the payment completes.
+// @Tags payments
+// @Security BearerAuth
+// @Param id path string true "Promotion ID"
+// @Param request body PayPromotionRequest true "Payment"
+// @Success 200 {object} utils.Response{data=MockPaymentResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /payments/promotions/{id} [post]
+func (h *PaymentHandler) PayPromotion(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	promotionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid promotion ID")
+	}
+
+	var req PayPromotionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	var promotion models.Promotion
+	if err := database.DB.First(&promotion, promotionID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Promotion not found")
+	}
+	if promotion.SellerID != userID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only pay for your own promotions", nil)
+	}
+	if promotion.Status != models.PromotionPendingPayment {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "Promotion has already been paid for", nil)
+	}
+	if promotion.TransactionID != "" {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "Payment is already in progress", nil)
+	}
+	if !promotion.EndsAt.After(time.Now()) {
+		return utils.ValidationErrorResponse(c, "Promotion window has already ended")
+	}
+
+	if req.PaymentPhoneID != nil {
+		var saved models.PaymentPhone
+		if err := database.DB.Where("id = ? AND user_id = ?", *req.PaymentPhoneID, userID).First(&saved).Error; err != nil {
+			return utils.NotFoundResponse(c, "Payment phone not found")
+		}
+		if saved.VerifiedAt == nil {
+			return utils.ValidationErrorResponse(c, "Verify the payment phone before paying with it")
+		}
+		req.Phone = saved.Phone
+	}
+	if req.Phone == "" {
+		return utils.ValidationErrorResponse(c, "Phone number is required")
+	}
+
+	// Promotions aren't tied to an order, so the phone's region decides the methods
+	region, ok := regions.ForPhone(req.Phone)
+	if !ok {
+		return utils.ValidationErrorResponse(c, "Phone must be a mobile number in a region we operate in")
+	}
+	method, ok := region.PaymentMethod(req.Method)
+	if !ok || !method.RequiresPhone {
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("Promotions must be paid by mobile money available in %s", region.Name))
+	}
+
+	prefix, scheme := "TB", "telebirr"
+	if req.Method == models.PaymentCBEBirr {
+		prefix, scheme = "CBE", "cbebirr"
+	}
+	response := MockPaymentResponse{
+		TransactionID: h.generateTransactionID(prefix),
+		Reference:     h.generateReference(),
+		Status:        "pending",
+		Message:       fmt.Sprintf("Payment initiated. Please complete the transaction using phone %s", req.Phone),
+	}
+	response.RedirectURL = fmt.Sprintf("%s://pay?ref=%s&amount=%.2f", scheme, response.Reference, promotion.Budget)
+
+	// Claim the promotion so a second request can't start another payment
+	result := database.DB.Model(&promotion).
+		Where("status = ? AND (transaction_id IS NULL OR transaction_id = '')", models.PromotionPendingPayment).
+		Updates(map[string]interface{}{
+			"payment_method": req.Method,
+			"transaction_id": response.TransactionID,
+			"reference":      response.Reference,
+		})
+	if result.Error != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to initiate payment", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "Payment is already in progress", nil)
+	}
+
+	// Simulate async payment completion (in real scenario, this would be a webhook)
+	jobs.EnqueueIn(jobSimulatePromotionPayment, promotionPaymentJob{PromotionID: promotion.ID}, 10*time.Second)
+
+	return utils.SuccessResponse(c, "Payment initiated successfully", response)
+}
+
+func (h *PaymentHandler) simulatePromotionPayment(payload []byte) error {
+	var job promotionPaymentJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+
+	var promotion models.Promotion
+	if err := database.DB.Preload("Product").First(&promotion, job.PromotionID).Error; err != nil {
+		return err
+	}
+	if promotion.Status != models.PromotionPendingPayment || promotion.TransactionID == "" {
+		return nil
+	}
+
+	// 85% success rate for mobile payments
+	if rand.Float32() >= 0.85 {
+		// Clear the attempt so the seller can pay again
+		database.DB.Model(&promotion).Updates(map[string]interface{}{
+			"transaction_id": "",
+			"reference":      "",
+		})
+		notifications.Send(promotion.SellerID, models.NotificationAccount, "Promotion payment failed",
+			fmt.Sprintf("Your payment of %.2f to promote %s didn't go through: Payment declined by provider.", promotion.Budget, promotion.Product.Name))
+		return nil
+	}
+
+	if err := promotions.MarkPaid(database.DB, promotion.ID); err != nil {
+		if errors.Is(err, promotions.ErrNotPayable) {
+			return nil
+		}
+		return err
+	}
+	log.Printf("Promotion %s paid, %.2f budget", promotion.ID, promotion.Budget)
+
+	notifications.Send(promotion.SellerID, models.NotificationAccount, "Promotion is live",
+		fmt.Sprintf("We received your payment of %.2f. %s is promoted until %s.",
+			promotion.Budget, promotion.Product.Name, promotion.EndsAt.Format("Jan 2, 2006")))
+	return nil
+}
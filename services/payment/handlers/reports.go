@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Reporting periods accepted by date_trunc
+var reportPeriods = map[string]bool{"day": true, "week": true, "month": true}
+
+type RevenuePeriod struct {
+	Period        time.Time `json:"period"`
+	GrossPayments float64   `json:"gross_payments"`
+	PlatformFees  float64   `json:"platform_fees"`
+	SellerPayouts float64   `json:"seller_payouts"`
+	Refunds       float64   `json:"refunds"`      // Return refunds and held funds returned by protection claims
+	Discounts     float64   `json:"discounts"`    // Coupon discounts funded by the platform
+	Referrals     float64   `json:"referrals"`    // Referral commissions owed to sellers
+	Protection    float64   `json:"protection"`   // Protection claims paid out of the protection fund
+	NetRevenue    float64   `json:"net_revenue"`  // Platform fees less discounts, referral commissions and protection payouts
+	HeldBalance   float64   `json:"held_balance"` // Captured but not yet paid out, charged or refunded
+}
+
+type ReconciliationIssue struct {
+	Count  int64   `json:"count"`
+	Amount float64 `json:"amount"`
+}
+
+type Reconciliation struct {
+	Balanced           bool                `json:"balanced"`
+	UnrecordedPayments ReconciliationIssue `json:"unrecorded_payments"` // Completed payments with no ledger entry
+	UnrecordedPayouts  ReconciliationIssue `json:"unrecorded_payouts"`  // Released escrow with no payout entry
+	UnrecordedRefunds  ReconciliationIssue `json:"unrecorded_refunds"`  // Refunded returns with no ledger entry
+	AmountMismatches   int64               `json:"amount_mismatches"`   // Ledger payment amount differs from payment
+}
+
+type RevenueReportResponse struct {
+	From           string          `json:"from"`
+	To             string          `json:"to"`
+	Period         string          `json:"period"`
+	Periods        []RevenuePeriod `json:"periods"`
+	Totals         RevenuePeriod   `json:"totals"`
+	Reconciliation Reconciliation  `json:"reconciliation"`
+}
+
+// @Summary Get revenue report
+// @Description Platform fee revenue, refunds and seller payouts per period from the ledger, reconciled against payments and returns (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param from query string false "Start date (YYYY-MM-DD)" default(30 days ago)
+// @Param to query string false "End date (YYYY-MM-DD), inclusive" default(today)
+// @Param period query string false "Grouping: day, week or month" default(day)
+// @Param format query string false "Response format: json or csv" default(json)
+// @Success 200 {object} utils.Response{data=RevenueReportResponse}
+// @Failure 400 {object} utils.Problem
+// @Router /admin/reports/revenue [get]
+func (h *PaymentHandler) GetRevenueReport(c *fiber.Ctx) error {
+	period := c.Query("period", "day")
+	if !reportPeriods[period] {
+		return utils.ValidationErrorResponse(c, "Period must be day, week or month")
+	}
+
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	from := to.AddDate(0, 0, -30)
+	var err error
+	if fromParam := c.Query("from"); fromParam != "" {
+		if from, err = time.Parse("2006-01-02", fromParam); err != nil {
+			return utils.ValidationErrorResponse(c, "From date must be in YYYY-MM-DD format")
+		}
+	}
+	if toParam := c.Query("to"); toParam != "" {
+		if to, err = time.Parse("2006-01-02", toParam); err != nil {
+			return utils.ValidationErrorResponse(c, "To date must be in YYYY-MM-DD format")
+		}
+	}
+	if to.Before(from) {
+		return utils.ValidationErrorResponse(c, "To date must not be before from date")
+	}
+	end := to.AddDate(0, 0, 1)
+
+	var periods []RevenuePeriod
+	if err := database.DB.Model(&models.LedgerEntry{}).
+		Select("date_trunc(?, created_at) AS period, "+
+			"SUM(CASE WHEN type = ? THEN amount ELSE 0 END) AS gross_payments, "+
+			"SUM(CASE WHEN type = ? THEN amount ELSE 0 END) AS platform_fees, "+
+			"SUM(CASE WHEN type = ? THEN amount ELSE 0 END) AS seller_payouts, "+
+			"SUM(CASE WHEN type IN (?, ?) THEN amount ELSE 0 END) AS refunds, "+
+			"SUM(CASE WHEN type = ? THEN amount ELSE 0 END) AS discounts, "+
+			"SUM(CASE WHEN type = ? THEN amount ELSE 0 END) AS referrals, "+
+			"SUM(CASE WHEN type = ? THEN amount ELSE 0 END) AS protection",
+			period, models.LedgerPayment, models.LedgerPlatformFee, models.LedgerSellerPayout, models.LedgerRefund, models.LedgerEscrowRefund,
+			models.LedgerDiscount, models.LedgerReferral, models.LedgerProtection).
+		Where("created_at >= ? AND created_at < ?", from, end).
+		Group("period").
+		Order("period ASC").
+		Scan(&periods).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to build revenue report", err)
+	}
+
+	var totals RevenuePeriod
+	for i := range periods {
+		p := &periods[i]
+		p.NetRevenue = p.PlatformFees - p.Discounts - p.Referrals - p.Protection
+		p.HeldBalance = p.GrossPayments + p.Discounts - p.PlatformFees - p.SellerPayouts - p.Refunds
+		totals.GrossPayments += p.GrossPayments
+		totals.PlatformFees += p.PlatformFees
+		totals.SellerPayouts += p.SellerPayouts
+		totals.Refunds += p.Refunds
+		totals.Discounts += p.Discounts
+		totals.Referrals += p.Referrals
+		totals.Protection += p.Protection
+		totals.NetRevenue += p.NetRevenue
+		totals.HeldBalance += p.HeldBalance
+	}
+	totals.Period = from
+
+	if c.Query("format") == "csv" {
+		return h.sendRevenueCSV(c, periods, from, to)
+	}
+
+	response := RevenueReportResponse{
+		From:           from.Format("2006-01-02"),
+		To:             to.Format("2006-01-02"),
+		Period:         period,
+		Periods:        periods,
+		Totals:         totals,
+		Reconciliation: h.reconcileLedger(from, end),
+	}
+
+	return utils.SuccessResponse(c, "Revenue report generated successfully", response)
+}
+
+// Helper functions
+
+// reconcileLedger checks payments, escrow releases and refunds in the range
+// against the ledger entries that should have been written for them.
+func (h *PaymentHandler) reconcileLedger(from, end time.Time) Reconciliation {
+	var result Reconciliation
+
+	database.DB.Model(&models.Payment{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(payments.amount), 0) AS amount").
+		Joins("LEFT JOIN ledger_entries ON ledger_entries.payment_id = payments.id AND ledger_entries.type = ?", models.LedgerPayment).
+		Where("payments.status = ? AND payments.created_at >= ? AND payments.created_at < ? AND ledger_entries.id IS NULL",
+			models.PaymentCompleted, from, end).
+		Scan(&result.UnrecordedPayments)
+
+	database.DB.Model(&models.Payment{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(payments.amount), 0) AS amount").
+		Where("payments.escrow_status = ? AND payments.escrow_released_at >= ? AND payments.escrow_released_at < ?",
+			models.EscrowReleased, from, end).
+		Where("NOT EXISTS (SELECT 1 FROM ledger_entries WHERE ledger_entries.payment_id = payments.id AND ledger_entries.type = ?)",
+			models.LedgerSellerPayout).
+		Scan(&result.UnrecordedPayouts)
+
+	database.DB.Model(&models.ReturnRequest{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(return_requests.refund_amount), 0) AS amount").
+		Joins("LEFT JOIN ledger_entries ON ledger_entries.return_id = return_requests.id AND ledger_entries.type = ?", models.LedgerRefund).
+		Where("return_requests.status = ? AND return_requests.updated_at >= ? AND return_requests.updated_at < ? AND ledger_entries.id IS NULL",
+			models.ReturnRefunded, from, end).
+		Scan(&result.UnrecordedRefunds)
+
+	database.DB.Model(&models.Payment{}).
+		Joins("JOIN ledger_entries ON ledger_entries.payment_id = payments.id AND ledger_entries.type = ?", models.LedgerPayment).
+		Where("payments.created_at >= ? AND payments.created_at < ? AND ledger_entries.amount <> payments.amount", from, end).
+		Count(&result.AmountMismatches)
+
+	result.Balanced = result.UnrecordedPayments.Count == 0 &&
+		result.UnrecordedPayouts.Count == 0 &&
+		result.UnrecordedRefunds.Count == 0 &&
+		result.AmountMismatches == 0
+
+	return result
+}
+
+func (h *PaymentHandler) sendRevenueCSV(c *fiber.Ctx, periods []RevenuePeriod, from, to time.Time) error {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	writer.Write([]string{"period", "gross_payments", "platform_fees", "seller_payouts", "refunds", "discounts", "referrals", "protection", "net_revenue", "held_balance"})
+	for _, p := range periods {
+		writer.Write([]string{
+			p.Period.Format("2006-01-02"),
+			fmt.Sprintf("%.2f", p.GrossPayments),
+			fmt.Sprintf("%.2f", p.PlatformFees),
+			fmt.Sprintf("%.2f", p.SellerPayouts),
+			fmt.Sprintf("%.2f", p.Refunds),
+			fmt.Sprintf("%.2f", p.Discounts),
+			fmt.Sprintf("%.2f", p.Referrals),
+			fmt.Sprintf("%.2f", p.Protection),
+			fmt.Sprintf("%.2f", p.NetRevenue),
+			fmt.Sprintf("%.2f", p.HeldBalance),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to write CSV", err)
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="revenue-%s-to-%s.csv"`,
+		from.Format("20060102"), to.Format("20060102")))
+
+	return c.Send(buf.Bytes())
+}
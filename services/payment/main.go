@@ -7,8 +7,11 @@ import (
 	"playful-marketplace/services/payment/routes"
 	"playful-marketplace/shared/config"
 	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/jobs"
 	"playful-marketplace/shared/middleware"
 	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/regions"
+	"playful-marketplace/shared/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/recover"
@@ -33,29 +36,29 @@ func main() {
 		log.Fatal("Failed to connect to Redis:", err)
 	}
 
+	// Load region configuration
+	regions.Init(cfg)
+
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
-		AppName: "Playful Marketplace Payment Service",
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			code := fiber.StatusInternalServerError
-			if e, ok := err.(*fiber.Error); ok {
-				code = e.Code
-			}
-			return c.Status(code).JSON(fiber.Map{
-				"success": false,
-				"message": "Internal Server Error",
-				"error":   err.Error(),
-			})
-		},
+		AppName:      "Playful Marketplace Payment Service",
+		ErrorHandler: utils.FiberErrorHandler,
 	})
 
 	// Middleware
+	app.Use(middleware.TraceMiddleware())
 	app.Use(recover.New())
 	app.Use(middleware.CORSMiddleware())
 	app.Use(middleware.LoggingMiddleware())
+	app.Use(middleware.FaultMiddleware(cfg))
+	app.Use(middleware.MaintenanceMiddleware(cfg, "payment"))
+	app.Use(middleware.RateLimitMiddleware(cfg))
+	app.Use(middleware.UsageMiddleware())
 
 	// Initialize handlers
 	paymentHandler := handlers.NewPaymentHandler(cfg)
+	paymentHandler.RegisterJobs()
+	jobs.Start(cfg.Jobs)
 
 	// Health check
 	app.Get("/health", func(c *fiber.Ctx) error {
@@ -4,6 +4,7 @@ import (
 	"playful-marketplace/services/payment/handlers"
 	"playful-marketplace/shared/config"
 	"playful-marketplace/shared/middleware"
+	"playful-marketplace/shared/models"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -15,7 +16,15 @@ func SetupPaymentRoutes(api fiber.Router, paymentHandler *handlers.PaymentHandle
 	payments.Get("/methods", paymentHandler.GetPaymentMethods)
 
 	// Protected routes
-	protected := payments.Group("", middleware.AuthMiddleware(cfg))
+	protected := payments.Group("", middleware.ScopedAuthMiddleware(cfg, "payments"))
 	protected.Post("/initiate", paymentHandler.InitiatePayment)
 	protected.Get("/status/:id", paymentHandler.GetPaymentStatus)
+
+	// Sponsored placement budgets, paid by the seller
+	protected.Post("/promotions/:id", middleware.RoleMiddleware(models.RoleSeller), paymentHandler.PayPromotion)
+
+	// Admin finance reports
+	admin := api.Group("/admin", middleware.AuthMiddleware(cfg), middleware.PermissionMiddleware(middleware.PermReportsRead))
+	admin.Get("/reports/revenue", paymentHandler.GetRevenueReport)
+	admin.Get("/reports/cohorts", paymentHandler.GetCohortReport)
 }
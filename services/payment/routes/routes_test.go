@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"testing"
+
+	"playful-marketplace/services/payment/handlers"
+	"playful-marketplace/shared/config"
+	"playful-marketplace/shared/contracts/openapi"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRoutesServeOpenAPI(t *testing.T) {
+	cfg := &config.Config{}
+	app := fiber.New()
+	SetupPaymentRoutes(app.Group("/api/v1"), handlers.NewPaymentHandler(cfg), cfg)
+
+	openapi.Load(t, "payment").AssertRoutes(t, app, "/api/v1")
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"time"
+
+	"playful-marketplace/shared/analytics"
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type TrackEventRequest struct {
+	Type string `json:"type" validate:"required"` // add_to_cart
+}
+
+type ProductFunnel struct {
+	ProductID    uuid.UUID `json:"product_id"`
+	Name         string    `json:"name"`
+	Impressions  int       `json:"impressions"`
+	Views        int       `json:"views"`
+	AddToCarts   int       `json:"add_to_carts"`
+	Purchases    int       `json:"purchases"`
+	ViewRate     float64   `json:"view_rate"`     // Views per impression
+	CartRate     float64   `json:"cart_rate"`     // Add-to-carts per view
+	PurchaseRate float64   `json:"purchase_rate"` // Purchases per add-to-cart
+	Conversion   float64   `json:"conversion"`    // Purchases per view
+}
+
+type FunnelResponse struct {
+	SellerID uuid.UUID       `json:"seller_id"`
+	From     string          `json:"from"`
+	To       string          `json:"to"`
+	Totals   ProductFunnel   `json:"totals"`
+	Products []ProductFunnel `json:"products"`
+}
+
+// @Summary Track product event
+// @Description Record a client-side funnel event for a product, such as adding it to the cart
+// @Tags analytics
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param request body TrackEventRequest true "Event"
+// @Success 202 {object} utils.Response
+// @Failure 400 {object} utils.Problem
+// @Router /products/{id}/events [post]
+func (h *ProductHandler) TrackProductEvent(c *fiber.Ctx) error {
+	productID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid product ID")
+	}
+
+	var req TrackEventRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	// Impressions, views and purchases are recorded server-side
+	if req.Type != analytics.EventAddToCart {
+		return utils.ValidationErrorResponse(c, "Unsupported event type")
+	}
+
+	analytics.Track(req.Type, productID)
+
+	return c.Status(fiber.StatusAccepted).JSON(utils.Response{
+		Success: true,
+		Message: "Event recorded",
+	})
+}
+
+// @Summary Get seller conversion funnel
+// @Description Get impressions, detail views, add-to-carts and purchases per product with drop-off rates (seller only, own products)
+// @Tags analytics
+// @Security BearerAuth
+// @Param id path string true "Seller ID"
+// @Param from query string false "Start date (YYYY-MM-DD)" default(30 days ago)
+// @Param to query string false "End date (YYYY-MM-DD)" default(today)
+// @Success 200 {object} utils.Response{data=FunnelResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Router /sellers/{id}/analytics/funnel [get]
+func (h *ProductHandler) GetSellerFunnel(c *fiber.Ctx) error {
+	sellerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid seller ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok || userID != sellerID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only view your own analytics", nil)
+	}
+
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	from := to.AddDate(0, 0, -30)
+	if fromParam := c.Query("from"); fromParam != "" {
+		if from, err = time.Parse("2006-01-02", fromParam); err != nil {
+			return utils.ValidationErrorResponse(c, "From date must be in YYYY-MM-DD format")
+		}
+	}
+	if toParam := c.Query("to"); toParam != "" {
+		if to, err = time.Parse("2006-01-02", toParam); err != nil {
+			return utils.ValidationErrorResponse(c, "To date must be in YYYY-MM-DD format")
+		}
+	}
+	if to.Before(from) {
+		return utils.ValidationErrorResponse(c, "To date must not be before from date")
+	}
+
+	var funnels []ProductFunnel
+	if err := database.DB.Model(&models.ProductFunnelStat{}).
+		Select("products.id AS product_id, products.name, "+
+			"SUM(product_funnel_stats.impressions) AS impressions, SUM(product_funnel_stats.views) AS views, "+
+			"SUM(product_funnel_stats.add_to_carts) AS add_to_carts, SUM(product_funnel_stats.purchases) AS purchases").
+		Joins("JOIN products ON products.id = product_funnel_stats.product_id").
+		Where("products.seller_id = ? AND product_funnel_stats.date BETWEEN ? AND ?", sellerID, from, to).
+		Group("products.id, products.name").
+		Order("views DESC").
+		Scan(&funnels).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get funnel metrics", err)
+	}
+
+	totals := ProductFunnel{Name: "All products"}
+	for i := range funnels {
+		funnels[i].calculateRates()
+		totals.Impressions += funnels[i].Impressions
+		totals.Views += funnels[i].Views
+		totals.AddToCarts += funnels[i].AddToCarts
+		totals.Purchases += funnels[i].Purchases
+	}
+	totals.calculateRates()
+
+	response := FunnelResponse{
+		SellerID: sellerID,
+		From:     from.Format("2006-01-02"),
+		To:       to.Format("2006-01-02"),
+		Totals:   totals,
+		Products: funnels,
+	}
+
+	return utils.SuccessResponse(c, "Funnel metrics retrieved successfully", response)
+}
+
+// Helper functions
+
+func (f *ProductFunnel) calculateRates() {
+	f.ViewRate = rate(f.Views, f.Impressions)
+	f.CartRate = rate(f.AddToCarts, f.Views)
+	f.PurchaseRate = rate(f.Purchases, f.AddToCarts)
+	f.Conversion = rate(f.Purchases, f.Views)
+}
+
+func rate(numerator, denominator int) float64 {
+	if denominator == 0 {
+		return 0
+	}
+	return float64(numerator) / float64(denominator)
+}
+
+func productIDs(products []models.Product) []uuid.UUID {
+	ids := make([]uuid.UUID, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+	return ids
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type UpdateCategoryRequirementRequest struct {
+	RequiredAttributes []string `json:"required_attributes"`
+	MinImages          *int     `json:"min_images"`
+	MinPrice           *float64 `json:"min_price"`
+	MaxPrice           *float64 `json:"max_price"`
+}
+
+// @Summary Get category listing requirements
+// @Description Get the attributes, image minimum and price bounds required to list in a category
+// @Tags products
+// @Param category path string true "Category"
+// @Success 200 {object} utils.Response{data=models.CategoryRequirement}
+// @Router /products/categories/{category}/requirements [get]
+func (h *ProductHandler) GetCategoryRequirements(c *fiber.Ctx) error {
+	category := c.Params("category")
+
+	requirement, found := h.getCategoryRequirement(category)
+	if !found {
+		requirement = models.CategoryRequirement{Category: category}
+	}
+
+	return utils.SuccessResponse(c, "Category requirements retrieved successfully", requirement)
+}
+
+// @Summary Update category listing requirements
+// @Description Configure required attributes, image minimum and price bounds for a category (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param category path string true "Category"
+// @Param request body UpdateCategoryRequirementRequest true "Update category requirement request"
+// @Success 200 {object} utils.Response{data=models.CategoryRequirement}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Router /admin/categories/{category}/requirements [put]
+func (h *ProductHandler) UpdateCategoryRequirements(c *fiber.Ctx) error {
+	category := strings.TrimSpace(c.Params("category"))
+	if category == "" {
+		return utils.ValidationErrorResponse(c, "Category is required")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req UpdateCategoryRequirementRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	requirement, found := h.getCategoryRequirement(category)
+	if !found {
+		requirement = models.CategoryRequirement{
+			BaseModel: models.BaseModel{ID: uuid.New()},
+			Category:  category,
+		}
+	}
+
+	// Update fields
+	if req.RequiredAttributes != nil {
+		var attributes []string
+		for _, attribute := range req.RequiredAttributes {
+			if attribute = strings.TrimSpace(attribute); attribute != "" {
+				attributes = append(attributes, attribute)
+			}
+		}
+		requirement.RequiredAttributes = strings.Join(attributes, ",")
+	}
+	if req.MinImages != nil {
+		if *req.MinImages < 0 {
+			return utils.ValidationErrorResponse(c, "Minimum images cannot be negative")
+		}
+		requirement.MinImages = *req.MinImages
+	}
+	if req.MinPrice != nil {
+		if *req.MinPrice < 0 {
+			return utils.ValidationErrorResponse(c, "Minimum price cannot be negative")
+		}
+		requirement.MinPrice = *req.MinPrice
+	}
+	if req.MaxPrice != nil {
+		if *req.MaxPrice < 0 {
+			return utils.ValidationErrorResponse(c, "Maximum price cannot be negative")
+		}
+		requirement.MaxPrice = *req.MaxPrice
+	}
+	if requirement.MaxPrice > 0 && requirement.MinPrice > requirement.MaxPrice {
+		return utils.ValidationErrorResponse(c, "Minimum price cannot exceed maximum price")
+	}
+
+	requirement.UpdatedBy = &userID
+
+	if err := database.DB.Save(&requirement).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update category requirements", err)
+	}
+
+	return utils.SuccessResponse(c, "Category requirements updated successfully", requirement)
+}
+
+// Helper functions
+
+func (h *ProductHandler) getCategoryRequirement(category string) (models.CategoryRequirement, bool) {
+	var requirement models.CategoryRequirement
+	if category == "" {
+		return requirement, false
+	}
+	if err := database.DB.Where("LOWER(category) = LOWER(?)", category).First(&requirement).Error; err != nil {
+		return requirement, false
+	}
+	return requirement, true
+}
+
+// validateListing checks a product against its category's listing requirements
+// and returns a list of problems, empty if the listing is acceptable.
+// imageChange is the number of gallery images the change being checked adds,
+// or removes when negative.
+func (h *ProductHandler) validateListing(product *models.Product, imageChange int) []string {
+	requirement, found := h.getCategoryRequirement(product.Category)
+	if !found {
+		return nil
+	}
+
+	var problems []string
+
+	for _, attribute := range strings.Split(requirement.RequiredAttributes, ",") {
+		if attribute == "" {
+			continue
+		}
+		if strings.TrimSpace(product.Attributes[attribute]) == "" {
+			problems = append(problems, fmt.Sprintf("attribute %q is required", attribute))
+		}
+	}
+
+	if images := productImageCount(product) + imageChange; images < requirement.MinImages {
+		problems = append(problems, fmt.Sprintf("at least %d image(s) required, got %d", requirement.MinImages, images))
+	}
+
+	if requirement.MinPrice > 0 && product.Price < requirement.MinPrice {
+		problems = append(problems, fmt.Sprintf("price must be at least %.2f", requirement.MinPrice))
+	}
+	if requirement.MaxPrice > 0 && product.Price > requirement.MaxPrice {
+		problems = append(problems, fmt.Sprintf("price must be at most %.2f", requirement.MaxPrice))
+	}
+
+	return problems
+}
+
+// productImageCount counts the product's visible gallery images
+func productImageCount(product *models.Product) int {
+	var count int64
+	database.DB.Model(&models.ProductImage{}).Where("product_id = ? AND quarantined = ?", product.ID, false).Count(&count)
+	return int(count)
+}
+
+func listingErrorResponse(c *fiber.Ctx, category string, problems []string) error {
+	return utils.ErrorResponse(c, fiber.StatusBadRequest,
+		fmt.Sprintf("Listing does not meet %s category requirements", category),
+		errors.New(strings.Join(problems, "; ")))
+}
@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	duplicateScanWindow    = 24 * time.Hour // Listings created or edited this recently are compared
+	duplicateThreshold     = 0.8            // Name similarity at which listings are flagged
+	maxDuplicateCandidates = 500            // Most recent listings per seller compared against
+)
+
+var errListingInactive = errors.New("listing is no longer active")
+
+type ReviewDuplicateListingRequest struct {
+	Decision string `json:"decision" validate:"required"` // merge or dismiss
+}
+
+// duplicateCandidate is the slice of a product the similarity check needs
+type duplicateCandidate struct {
+	ID        uuid.UUID
+	SellerID  uuid.UUID
+	Name      string
+	ImageURL  string
+	CreatedAt time.Time
+}
+
+// @Summary Get duplicate listings
+// @Description Get listings that look like copies of an older listing from the same seller (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param status query string false "Review status" default(pending)
+// @Param seller_id query string false "Filter by seller"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} utils.Response{data=[]models.DuplicateListing}
+// @Router /admin/duplicates [get]
+func (h *ProductHandler) GetDuplicateListings(c *fiber.Ctx) error {
+	status := c.Query("status", string(models.DuplicatePending))
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+
+	if page < 1 {
+		page = 1
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	query := database.DB.Where("status = ?", status)
+	if sellerID := c.Query("seller_id"); sellerID != "" {
+		id, err := uuid.Parse(sellerID)
+		if err != nil {
+			return utils.ValidationErrorResponse(c, "Invalid seller ID")
+		}
+		query = query.Where("seller_id = ?", id)
+	}
+
+	var duplicates []models.DuplicateListing
+	if err := query.Preload("Product").Preload("Original").
+		Order("similarity DESC, created_at ASC").
+		Offset((page - 1) * limit).Limit(limit).
+		Find(&duplicates).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get duplicate listings", err)
+	}
+
+	return utils.SuccessResponse(c, "Duplicate listings retrieved successfully", duplicates)
+}
+
+// @Summary Review duplicate listing
+// @Description Merge a duplicate into the original listing or dismiss the suggestion (admin only). Merging moves the duplicate's stock to the original and unlists the duplicate.
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Duplicate listing ID"
+// @Param request body ReviewDuplicateListingRequest true "Review decision"
+// @Success 200 {object} utils.Response{data=models.DuplicateListing}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /admin/duplicates/{id} [put]
+func (h *ProductHandler) ReviewDuplicateListing(c *fiber.Ctx) error {
+	duplicateID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid duplicate listing ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req ReviewDuplicateListingRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	var duplicate models.DuplicateListing
+	if err := database.DB.Preload("Product").Preload("Original").First(&duplicate, duplicateID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Duplicate listing not found")
+	}
+
+	if duplicate.Status != models.DuplicatePending {
+		return utils.ValidationErrorResponse(c, "Duplicate listing has already been reviewed")
+	}
+
+	now := time.Now()
+	duplicate.ReviewedBy = &userID
+	duplicate.ReviewedAt = &now
+
+	switch req.Decision {
+	case "merge":
+		duplicate.Status = models.DuplicateMerged
+		err = database.DB.Transaction(func(tx *gorm.DB) error {
+			return mergeDuplicateListing(tx, &duplicate)
+		})
+		if errors.Is(err, errListingInactive) {
+			return utils.ValidationErrorResponse(c, "One of the listings is no longer active")
+		}
+		if err != nil {
+			return utils.InternalServerErrorResponse(c, "Failed to merge listings", err)
+		}
+
+		redis.Delete(redis.ProductKey(duplicate.ProductID))
+		redis.Delete(redis.ProductKey(duplicate.OriginalID))
+		notifications.SendWithLink(duplicate.SellerID, models.NotificationModeration, "Duplicate listing merged",
+			fmt.Sprintf("Your listing %q was merged into %q and its stock moved there.", duplicate.Product.Name, duplicate.Original.Name),
+			fmt.Sprintf("playful://products/%s", duplicate.OriginalID))
+	case "dismiss":
+		duplicate.Status = models.DuplicateDismissed
+		if err := database.DB.Omit(clause.Associations).Save(&duplicate).Error; err != nil {
+			return utils.InternalServerErrorResponse(c, "Failed to update duplicate listing", err)
+		}
+	default:
+		return utils.ValidationErrorResponse(c, "Decision must be merge or dismiss")
+	}
+
+	return utils.SuccessResponse(c, "Duplicate listing reviewed successfully", duplicate)
+}
+
+// DetectDuplicateListings compares recently created or edited listings with
+// the rest of the same seller's catalog and flags near-duplicates for review.
+// Run periodically by the scheduler; pairs already flagged, including
+// dismissed ones, are not flagged again.
+func (h *ProductHandler) DetectDuplicateListings() {
+	var recent []duplicateCandidate
+	if err := database.DB.Model(&models.Product{}).
+		Select("id, seller_id, name, image_url, created_at").
+		Where("is_active = ? AND updated_at >= ?", true, time.Now().Add(-duplicateScanWindow)).
+		Scan(&recent).Error; err != nil {
+		log.Printf("Failed to load recent listings: %v", err)
+		return
+	}
+
+	bySeller := make(map[uuid.UUID][]duplicateCandidate)
+	for _, product := range recent {
+		bySeller[product.SellerID] = append(bySeller[product.SellerID], product)
+	}
+
+	flagged := 0
+	for sellerID, products := range bySeller {
+		var catalog []duplicateCandidate
+		if err := database.DB.Model(&models.Product{}).
+			Select("id, seller_id, name, image_url, created_at").
+			Where("seller_id = ? AND is_active = ?", sellerID, true).
+			Order("created_at DESC").
+			Limit(maxDuplicateCandidates).
+			Scan(&catalog).Error; err != nil {
+			log.Printf("Failed to load listings for seller %s: %v", sellerID, err)
+			continue
+		}
+
+		for _, product := range products {
+			for _, other := range catalog {
+				if other.ID == product.ID {
+					continue
+				}
+				if n, err := flagDuplicate(product, other); err != nil {
+					log.Printf("Failed to flag duplicate of %s: %v", product.ID, err)
+				} else {
+					flagged += n
+				}
+			}
+		}
+	}
+
+	if flagged > 0 {
+		log.Printf("Flagged %d possible duplicate listing(s)", flagged)
+	}
+}
+
+// Helper functions
+
+// flagDuplicate records the pair when the listings look alike, with the
+// older one as the original. Returns how many new flags were created.
+func flagDuplicate(a, b duplicateCandidate) (int, error) {
+	similarity := nameSimilarity(a.Name, b.Name)
+	sameImage := a.ImageURL != "" && a.ImageURL == b.ImageURL
+	if similarity < duplicateThreshold && !sameImage {
+		return 0, nil
+	}
+
+	product, original := a, b
+	if a.CreatedAt.Before(b.CreatedAt) || (a.CreatedAt.Equal(b.CreatedAt) && a.ID.String() < b.ID.String()) {
+		product, original = b, a
+	}
+
+	duplicate := models.DuplicateListing{
+		BaseModel:  models.BaseModel{ID: uuid.New()},
+		ProductID:  product.ID,
+		OriginalID: original.ID,
+		SellerID:   product.SellerID,
+		Similarity: similarity,
+		SameImage:  sameImage,
+		Status:     models.DuplicatePending,
+	}
+	result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&duplicate)
+	return int(result.RowsAffected), result.Error
+}
+
+func mergeDuplicateListing(tx *gorm.DB, duplicate *models.DuplicateListing) error {
+	var product, original models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, duplicate.ProductID).Error; err != nil {
+		return err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&original, duplicate.OriginalID).Error; err != nil {
+		return err
+	}
+	if !product.IsActive || !original.IsActive {
+		return errListingInactive
+	}
+
+	if err := tx.Model(&original).Update("stock", gorm.Expr("stock + ?", product.Stock)).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&product).Updates(map[string]interface{}{
+		"stock":     0,
+		"is_active": false,
+	}).Error; err != nil {
+		return err
+	}
+
+	// Other suggestions involving the unlisted duplicate no longer apply
+	if err := tx.Model(&models.DuplicateListing{}).
+		Where("id <> ? AND status = ? AND (product_id = ? OR original_id = ?)",
+			duplicate.ID, models.DuplicatePending, product.ID, product.ID).
+		Updates(map[string]interface{}{
+			"status":      models.DuplicateDismissed,
+			"reviewed_by": duplicate.ReviewedBy,
+			"reviewed_at": duplicate.ReviewedAt,
+		}).Error; err != nil {
+		return err
+	}
+
+	return tx.Omit(clause.Associations).Save(duplicate).Error
+}
+
+// nameSimilarity compares listing names by their shared trigrams, the same
+// measure as Postgres pg_trgm's similarity(): 1 for identical word sets,
+// 0 for nothing in common
+func nameSimilarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for trigram := range ta {
+		if _, ok := tb[trigram]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+func trigrams(s string) map[string]struct{} {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	set := make(map[string]struct{})
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = struct{}{}
+		}
+	}
+	return set
+}
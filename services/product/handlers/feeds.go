@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"log"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// UpdateFeeds writes out the sitemap and catalog feed shards holding
+// products changed since they were last written
+func (h *ProductHandler) UpdateFeeds() {
+	shards, err := h.feeds.Update()
+	if err != nil {
+		log.Printf("Failed to update product feeds: %v", err)
+		return
+	}
+	if shards > 0 {
+		log.Printf("Updated %d product feed shard(s)", shards)
+	}
+}
+
+// RebuildFeeds rewrites every feed shard, catching anything the incremental
+// updates missed, such as a seller renaming themselves
+func (h *ProductHandler) RebuildFeeds() {
+	if err := h.feeds.Rebuild(); err != nil {
+		log.Printf("Failed to rebuild product feeds: %v", err)
+	}
+}
+
+// @Summary List product feeds
+// @Description List the published sitemap and Google Merchant/Facebook catalog feed files with their item counts and when they were written (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=[]models.FeedFile}
+// @Router /admin/feeds [get]
+func (h *ProductHandler) GetFeeds(c *fiber.Ctx) error {
+	var files []models.FeedFile
+	if err := database.DB.Order("kind, shard").Find(&files).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to fetch feeds", err)
+	}
+
+	return utils.SuccessResponse(c, "Feeds retrieved successfully", files)
+}
+
+// @Summary Rebuild product feeds
+// @Description Rewrite the sitemap and catalog feed from every active product now instead of waiting for the nightly rebuild (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=[]models.FeedFile}
+// @Failure 500 {object} utils.Problem
+// @Router /admin/feeds/rebuild [post]
+func (h *ProductHandler) RebuildFeedsNow(c *fiber.Ctx) error {
+	if err := h.feeds.Rebuild(); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to rebuild feeds", err)
+	}
+
+	var files []models.FeedFile
+	database.DB.Order("kind, shard").Find(&files)
+
+	return utils.SuccessResponse(c, "Feeds rebuilt successfully", files)
+}
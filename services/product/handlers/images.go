@@ -0,0 +1,425 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/moderation"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/storage"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	maxProductImages      = 10
+	maxImageAltTextLength = 250
+	productThumbnailSize  = 400 // Width and height in pixels
+)
+
+var productImageExtensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+var errImageNotFound = errors.New("image not found")
+
+type UpdateProductImageRequest struct {
+	AltText   *string `json:"alt_text"`
+	IsPrimary bool    `json:"is_primary"` // Make this the primary image
+}
+
+type ReorderProductImagesRequest struct {
+	ImageIDs []uuid.UUID `json:"image_ids" validate:"required"` // Every image of the product, in display order
+}
+
+// @Summary Get product images
+// @Description Get a product's image gallery in display order
+// @Tags products
+// @Param id path string true "Product ID"
+// @Success 200 {object} utils.Response{data=[]models.ProductImage}
+// @Failure 404 {object} utils.Problem
+// @Router /products/{id}/images [get]
+func (h *ProductHandler) GetProductImages(c *fiber.Ctx) error {
+	productID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid product ID")
+	}
+
+	var product models.Product
+	if err := database.DB.Select("id").Where("is_active = ?", true).First(&product, productID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Product not found")
+	}
+
+	var images []models.ProductImage
+	if err := visibleImages(database.DB, productID).Find(&images).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get images", err)
+	}
+
+	return utils.SuccessResponse(c, "Images retrieved successfully", images)
+}
+
+// @Summary Upload product image
+// @Description Add an image to a product's gallery as multipart form field "image" (JPEG, PNG or GIF), with optional "alt_text" and "primary" fields. A square thumbnail is generated alongside the original. The first image becomes the primary one (seller only, own products).
+// @Tags products
+// @Security BearerAuth
+// @Accept multipart/form-data
+// @Param id path string true "Product ID"
+// @Param image formData file true "Product image"
+// @Param alt_text formData string false "Description of the image for screen readers and search engines"
+// @Param primary formData bool false "Make this the primary image"
+// @Success 201 {object} utils.Response{data=models.ProductImage}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /products/{id}/images [post]
+func (h *ProductHandler) UploadProductImage(c *fiber.Ctx) error {
+	product, err := ownProduct(c)
+	if err != nil {
+		return productImageErrorResponse(c, err)
+	}
+	userID := product.SellerID
+
+	var count int64
+	database.DB.Model(&models.ProductImage{}).Where("product_id = ?", product.ID).Count(&count)
+	if count >= maxProductImages {
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("A product can have at most %d images", maxProductImages))
+	}
+
+	altText := strings.TrimSpace(c.FormValue("alt_text"))
+	if len([]rune(altText)) > maxImageAltTextLength {
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("Alt text must be at most %d characters", maxImageAltTextLength))
+	}
+
+	file, err := c.FormFile("image")
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Image file is required")
+	}
+
+	maxBytes := int64(h.config.Storage.MaxProductImageMB) << 20
+	if file.Size > maxBytes {
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("Image must be at most %d MB", h.config.Storage.MaxProductImageMB))
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Failed to read image file")
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, maxBytes+1))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Failed to read image file")
+	}
+
+	// Trust the file's contents over the client's declared type
+	contentType := http.DetectContentType(data)
+	extension, ok := productImageExtensions[contentType]
+	if !ok {
+		return utils.ValidationErrorResponse(c, storage.ErrUnsupportedImage.Error())
+	}
+
+	img, err := storage.DecodeImage(data)
+	if err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+	thumbnail, err := storage.SquareThumbnail(img, productThumbnailSize)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to generate thumbnail", err)
+	}
+
+	image := models.ProductImage{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		ProductID: product.ID,
+		AltText:   altText,
+		Position:  int(count),
+		IsPrimary: count == 0 || c.FormValue("primary") == "true",
+	}
+
+	image.URL, err = h.storage.Put(fmt.Sprintf("products/%s/%s.%s", product.ID, image.ID, extension), contentType, data)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to store image", err)
+	}
+	image.ThumbnailURL, err = h.storage.Put(fmt.Sprintf("products/%s/%s_thumb.jpg", product.ID, image.ID), "image/jpeg", thumbnail)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to store image", err)
+	}
+
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return addProductImage(tx, &image)
+	}); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to add image", err)
+	}
+
+	redis.Delete(redis.ProductKey(product.ID))
+	h.scanProductImage(&image, userID)
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Image uploaded successfully",
+		Data:    image,
+	})
+}
+
+// @Summary Update product image
+// @Description Change an image's alt text or make it the primary image (seller only, own products)
+// @Tags products
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param imageId path string true "Image ID"
+// @Param request body UpdateProductImageRequest true "Image changes"
+// @Success 200 {object} utils.Response{data=models.ProductImage}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /products/{id}/images/{imageId} [put]
+func (h *ProductHandler) UpdateProductImage(c *fiber.Ctx) error {
+	product, err := ownProduct(c)
+	if err != nil {
+		return productImageErrorResponse(c, err)
+	}
+
+	image, err := productImage(c, product.ID)
+	if err != nil {
+		return productImageErrorResponse(c, err)
+	}
+
+	var req UpdateProductImageRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.AltText != nil {
+		image.AltText = strings.TrimSpace(*req.AltText)
+		if len([]rune(image.AltText)) > maxImageAltTextLength {
+			return utils.ValidationErrorResponse(c, fmt.Sprintf("Alt text must be at most %d characters", maxImageAltTextLength))
+		}
+	}
+
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(image).Update("alt_text", image.AltText).Error; err != nil {
+			return err
+		}
+		if !req.IsPrimary || image.IsPrimary {
+			return nil
+		}
+		if err := tx.Model(&models.ProductImage{}).Where("product_id = ? AND id <> ?", product.ID, image.ID).
+			Update("is_primary", false).Error; err != nil {
+			return err
+		}
+		image.IsPrimary = true
+		if err := tx.Model(image).Update("is_primary", true).Error; err != nil {
+			return err
+		}
+		return syncPrimaryImage(tx, product.ID)
+	}); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update image", err)
+	}
+
+	redis.Delete(redis.ProductKey(product.ID))
+
+	return utils.SuccessResponse(c, "Image updated successfully", image)
+}
+
+// @Summary Reorder product images
+// @Description Set the display order of a product's images by listing all their IDs in order (seller only, own products)
+// @Tags products
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param request body ReorderProductImagesRequest true "Image order"
+// @Success 200 {object} utils.Response{data=[]models.ProductImage}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /products/{id}/images/order [put]
+func (h *ProductHandler) ReorderProductImages(c *fiber.Ctx) error {
+	product, err := ownProduct(c)
+	if err != nil {
+		return productImageErrorResponse(c, err)
+	}
+
+	var req ReorderProductImagesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	var images []models.ProductImage
+	if err := database.DB.Where("product_id = ?", product.ID).Find(&images).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get images", err)
+	}
+
+	existing := make(map[uuid.UUID]bool, len(images))
+	for _, image := range images {
+		existing[image.ID] = true
+	}
+	listed := make(map[uuid.UUID]bool, len(req.ImageIDs))
+	for _, imageID := range req.ImageIDs {
+		if !existing[imageID] || listed[imageID] {
+			return utils.ValidationErrorResponse(c, "Image IDs must list each of the product's images once")
+		}
+		listed[imageID] = true
+	}
+	if len(listed) != len(existing) {
+		return utils.ValidationErrorResponse(c, "Image IDs must list each of the product's images once")
+	}
+
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for position, imageID := range req.ImageIDs {
+			if err := tx.Model(&models.ProductImage{}).Where("id = ?", imageID).Update("position", position).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to reorder images", err)
+	}
+
+	redis.Delete(redis.ProductKey(product.ID))
+
+	database.DB.Where("product_id = ?", product.ID).Order("position ASC").Find(&images)
+	return utils.SuccessResponse(c, "Images reordered successfully", images)
+}
+
+// @Summary Delete product image
+// @Description Remove an image from a product's gallery. Removing the primary image makes the next one primary. An active listing can't drop below its category's minimum number of images. (seller only, own products)
+// @Tags products
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param imageId path string true "Image ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /products/{id}/images/{imageId} [delete]
+func (h *ProductHandler) DeleteProductImage(c *fiber.Ctx) error {
+	product, err := ownProduct(c)
+	if err != nil {
+		return productImageErrorResponse(c, err)
+	}
+
+	image, err := productImage(c, product.ID)
+	if err != nil {
+		return productImageErrorResponse(c, err)
+	}
+
+	// Active listings have to keep their category's minimum number of images
+	if product.IsActive && !image.Quarantined {
+		if problems := h.validateListing(product, -1); len(problems) > 0 {
+			return listingErrorResponse(c, product.Category, problems)
+		}
+	}
+
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(image).Error; err != nil {
+			return err
+		}
+		return syncPrimaryImage(tx, product.ID)
+	}); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to delete image", err)
+	}
+
+	redis.Delete(redis.ProductKey(product.ID))
+
+	return utils.SuccessResponse(c, "Image deleted successfully", nil)
+}
+
+// Helper functions
+
+func productImage(c *fiber.Ctx, productID uuid.UUID) (*models.ProductImage, error) {
+	imageID, err := uuid.Parse(c.Params("imageId"))
+	if err != nil {
+		return nil, errImageNotFound
+	}
+
+	var image models.ProductImage
+	if err := database.DB.Where("id = ? AND product_id = ?", imageID, productID).First(&image).Error; err != nil {
+		return nil, errImageNotFound
+	}
+	return &image, nil
+}
+
+func productImageErrorResponse(c *fiber.Ctx, err error) error {
+	if errors.Is(err, errImageNotFound) {
+		return utils.NotFoundResponse(c, "Image not found")
+	}
+	return variantErrorResponse(c, err)
+}
+
+// visibleImages scopes db to the product's images buyers can see, in display order
+func visibleImages(db *gorm.DB, productID uuid.UUID) *gorm.DB {
+	return db.Where("product_id = ? AND quarantined = ?", productID, false).Order("position ASC")
+}
+
+// addProductImage saves a new image inside tx, taking over as primary
+// when it's marked so
+func addProductImage(tx *gorm.DB, image *models.ProductImage) error {
+	if image.IsPrimary {
+		if err := tx.Model(&models.ProductImage{}).Where("product_id = ?", image.ProductID).
+			Update("is_primary", false).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Create(image).Error; err != nil {
+		return err
+	}
+	return syncPrimaryImage(tx, image.ProductID)
+}
+
+// syncPrimaryImage makes sure a product with visible images has a visible
+// primary one, falling back to the first in order, and mirrors its URL onto
+// the product
+func syncPrimaryImage(tx *gorm.DB, productID uuid.UUID) error {
+	var images []models.ProductImage
+	if err := visibleImages(tx, productID).Find(&images).Error; err != nil {
+		return err
+	}
+
+	var primary *models.ProductImage
+	for i := range images {
+		if images[i].IsPrimary {
+			primary = &images[i]
+			break
+		}
+	}
+	if primary == nil && len(images) > 0 {
+		primary = &images[0]
+		if err := tx.Model(&models.ProductImage{}).Where("product_id = ?", productID).
+			Update("is_primary", false).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(primary).Update("is_primary", true).Error; err != nil {
+			return err
+		}
+	}
+
+	imageURL := ""
+	if primary != nil {
+		imageURL = primary.URL
+	}
+	return tx.Model(&models.Product{}).Where("id = ?", productID).Update("image_url", imageURL).Error
+}
+
+// scanProductImage queues the image for moderation, hiding it if flagged
+func (h *ProductHandler) scanProductImage(image *models.ProductImage, userID uuid.UUID) {
+	imageID, productID := image.ID, image.ProductID
+	moderation.ScanImageAsync(moderation.ContentProductImage, productID, userID, image.URL, func() error {
+		if err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.ProductImage{}).Where("id = ?", imageID).Update("quarantined", true).Error; err != nil {
+				return err
+			}
+			return syncPrimaryImage(tx, productID)
+		}); err != nil {
+			return err
+		}
+		return redis.Delete(redis.ProductKey(productID))
+	})
+}
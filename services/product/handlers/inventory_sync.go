@@ -0,0 +1,478 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/inventory"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/utils"
+	"playful-marketplace/shared/webhooks"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type InventorySyncRequest struct {
+	Items []InventoryUpdate `json:"items" validate:"required"`
+}
+
+type InventoryUpdate struct {
+	SKU       string     `json:"sku" validate:"required"`
+	Stock     *int       `json:"stock"`
+	Price     *float64   `json:"price"`
+	UpdatedAt *time.Time `json:"updated_at"` // When the change happened in the external system
+}
+
+type InventorySyncResult struct {
+	SKU      string `json:"sku"`
+	Applied  bool   `json:"applied"`
+	Conflict bool   `json:"conflict"`
+	Message  string `json:"message"`
+}
+
+type UpdateSyncConfigRequest struct {
+	ConflictStrategy models.ConflictStrategy `json:"conflict_strategy"`
+	RotateSecret     bool                    `json:"rotate_secret"`
+}
+
+type SyncConfigResponse struct {
+	*models.InventorySyncConfig
+	WebhookSecret string `json:"webhook_secret,omitempty"` // Only returned when generated
+	WebhookURL    string `json:"webhook_url"`
+}
+
+const maxSyncBatchSize = 500
+
+// @Summary Bulk sync inventory
+// @Description Push stock and price updates per SKU from an external POS/ERP system (seller only, own products)
+// @Tags inventory
+// @Security BearerAuth
+// @Param id path string true "Seller ID"
+// @Param request body InventorySyncRequest true "Inventory sync request"
+// @Success 200 {object} utils.Response{data=[]InventorySyncResult}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Router /sellers/{id}/inventory/sync [post]
+func (h *ProductHandler) SyncInventory(c *fiber.Ctx) error {
+	sellerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid seller ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok || userID != sellerID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only sync your own inventory", nil)
+	}
+
+	var req InventorySyncRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if len(req.Items) == 0 || len(req.Items) > maxSyncBatchSize {
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("Sync batch must contain between 1 and %d items", maxSyncBatchSize))
+	}
+
+	config := h.getSyncConfig(sellerID)
+	results := h.applyInventoryUpdates(sellerID, config, "api", req.Items)
+
+	return utils.SuccessResponse(c, "Inventory synced successfully", results)
+}
+
+// @Summary Inventory sync webhook
+// @Description Webhook for external systems to push inventory updates, signed with the seller's webhook secret the way outgoing webhooks are: X-Signature is the hex HMAC-SHA256 of the X-Timestamp value, a dot and the body. Requests with a timestamp more than 5 minutes off are refused.
+// @Tags inventory
+// @Param sellerId path string true "Seller ID"
+// @Param X-Timestamp header string true "Unix time the request was signed at"
+// @Param X-Signature header string true "Hex HMAC-SHA256 signature of the timestamp, a dot and the request body"
+// @Param request body InventorySyncRequest true "Inventory sync request"
+// @Success 200 {object} utils.Response{data=[]InventorySyncResult}
+// @Failure 400 {object} utils.Problem
+// @Failure 401 {object} utils.Problem
+// @Router /webhooks/inventory/{sellerId} [post]
+func (h *ProductHandler) InventoryWebhook(c *fiber.Ctx) error {
+	sellerID, err := uuid.Parse(c.Params("sellerId"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid seller ID")
+	}
+
+	var config models.InventorySyncConfig
+	if err := database.DB.Where("seller_id = ?", sellerID).First(&config).Error; err != nil {
+		return utils.UnauthorizedResponse(c, "Inventory sync is not configured for this seller")
+	}
+
+	// Verify signature, refusing replays of old requests
+	if !webhooks.Verify(config.WebhookSecret, c.Get("X-Timestamp"), c.Get("X-Signature"), c.Body()) {
+		return utils.UnauthorizedResponse(c, "Invalid or expired webhook signature")
+	}
+
+	var req InventorySyncRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if len(req.Items) == 0 || len(req.Items) > maxSyncBatchSize {
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("Sync batch must contain between 1 and %d items", maxSyncBatchSize))
+	}
+
+	results := h.applyInventoryUpdates(sellerID, config, "webhook", req.Items)
+
+	return utils.SuccessResponse(c, "Inventory synced successfully", results)
+}
+
+// @Summary Configure inventory sync
+// @Description Set the conflict strategy and generate or rotate the webhook secret (seller only, own config)
+// @Tags inventory
+// @Security BearerAuth
+// @Param id path string true "Seller ID"
+// @Param request body UpdateSyncConfigRequest true "Sync config request"
+// @Success 200 {object} utils.Response{data=SyncConfigResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Router /sellers/{id}/inventory/sync-config [put]
+func (h *ProductHandler) UpdateSyncConfig(c *fiber.Ctx) error {
+	sellerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid seller ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok || userID != sellerID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only configure your own inventory sync", nil)
+	}
+
+	var req UpdateSyncConfigRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	switch req.ConflictStrategy {
+	case "", models.ConflictNewestWins, models.ConflictSourceWins, models.ConflictMarketplaceWins:
+	default:
+		return utils.ValidationErrorResponse(c, "Conflict strategy must be 'newest_wins', 'source_wins' or 'marketplace_wins'")
+	}
+
+	var config models.InventorySyncConfig
+	isNew := database.DB.Where("seller_id = ?", sellerID).First(&config).Error != nil
+
+	response := SyncConfigResponse{
+		InventorySyncConfig: &config,
+		WebhookURL:          fmt.Sprintf("/api/v1/webhooks/inventory/%s", sellerID),
+	}
+
+	if isNew || req.RotateSecret {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			return utils.InternalServerErrorResponse(c, "Failed to generate webhook secret", err)
+		}
+		config.WebhookSecret = secret
+		response.WebhookSecret = secret
+	}
+
+	if isNew {
+		config.ID = uuid.New()
+		config.SellerID = sellerID
+		config.ConflictStrategy = models.ConflictNewestWins
+	}
+	if req.ConflictStrategy != "" {
+		config.ConflictStrategy = req.ConflictStrategy
+	}
+
+	if err := database.DB.Save(&config).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to save sync config", err)
+	}
+
+	return utils.SuccessResponse(c, "Inventory sync configured successfully", response)
+}
+
+// @Summary Get inventory change log
+// @Description Get externally pushed inventory changes (seller only, own log)
+// @Tags inventory
+// @Security BearerAuth
+// @Param id path string true "Seller ID"
+// @Param sku query string false "Filter by SKU"
+// @Param conflicts query bool false "Only show conflicts"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(50)
+// @Success 200 {object} utils.Response{data=[]models.InventoryChangeLog}
+// @Failure 403 {object} utils.Problem
+// @Router /sellers/{id}/inventory/changes [get]
+func (h *ProductHandler) GetInventoryChanges(c *fiber.Ctx) error {
+	sellerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid seller ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok || userID != sellerID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only view your own inventory changes", nil)
+	}
+
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 50)
+
+	if page < 1 {
+		page = 1
+	}
+	if limit > 200 {
+		limit = 200
+	}
+
+	query := database.DB.Where("seller_id = ?", sellerID)
+	if sku := c.Query("sku"); sku != "" {
+		query = query.Where("sku = ?", sku)
+	}
+	if c.QueryBool("conflicts") {
+		query = query.Where("conflict = ?", true)
+	}
+
+	var changes []models.InventoryChangeLog
+	if err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&changes).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get inventory changes", err)
+	}
+
+	return utils.SuccessResponse(c, "Inventory changes retrieved successfully", changes)
+}
+
+// Helper functions
+
+func (h *ProductHandler) skuTaken(sellerID uuid.UUID, sku string, excludeID uuid.UUID) bool {
+	var count int64
+	database.DB.Model(&models.Product{}).Where("seller_id = ? AND sku = ? AND id != ?", sellerID, sku, excludeID).Count(&count)
+	if count > 0 {
+		return true
+	}
+
+	// Variant SKUs share the seller's SKU space
+	database.DB.Model(&models.ProductVariant{}).
+		Joins("JOIN products ON products.id = product_variants.product_id").
+		Where("products.seller_id = ? AND product_variants.sku = ? AND product_variants.id != ?", sellerID, sku, excludeID).
+		Count(&count)
+	return count > 0
+}
+
+func (h *ProductHandler) getSyncConfig(sellerID uuid.UUID) models.InventorySyncConfig {
+	var config models.InventorySyncConfig
+	if err := database.DB.Where("seller_id = ?", sellerID).First(&config).Error; err != nil {
+		return models.InventorySyncConfig{SellerID: sellerID, ConflictStrategy: models.ConflictNewestWins}
+	}
+	return config
+}
+
+func (h *ProductHandler) applyInventoryUpdates(sellerID uuid.UUID, config models.InventorySyncConfig, source string, updates []InventoryUpdate) []InventorySyncResult {
+	results := make([]InventorySyncResult, 0, len(updates))
+
+	for _, update := range updates {
+		result := InventorySyncResult{SKU: update.SKU}
+
+		var product models.Product
+		if update.SKU == "" || database.DB.Where("seller_id = ? AND sku = ?", sellerID, update.SKU).First(&product).Error != nil {
+			if variant := sellerVariant(sellerID, update.SKU); variant != nil {
+				results = append(results, h.applyVariantInventoryUpdate(sellerID, config, source, variant, update))
+				continue
+			}
+			result.Message = "Unknown SKU"
+			results = append(results, result)
+			h.logInventoryChange(sellerID, nil, update, source, "", 0, 0, false, false, result.Message)
+			continue
+		}
+
+		if update.Stock != nil && *update.Stock < 0 || update.Price != nil && *update.Price <= 0 {
+			result.Message = "Stock must be non-negative and price must be greater than 0"
+			results = append(results, result)
+			h.logInventoryChange(sellerID, &product.ID, update, source, "", 0, 0, false, false, result.Message)
+			continue
+		}
+		if update.Stock != nil && hasVariants(product.ID) {
+			result.Message = "Stock of a product with variants is synced by variant SKU"
+			results = append(results, result)
+			h.logInventoryChange(sellerID, &product.ID, update, source, "", 0, 0, false, false, result.Message)
+			continue
+		}
+
+		conflict, apply := syncConflict(config, update, product.InventoryEditedAt)
+
+		result.Conflict = conflict
+		result.Applied = apply
+		if apply {
+			result.Message = "Applied"
+		} else {
+			result.Message = "Skipped: product was modified locally after this change"
+		}
+
+		previousPrice := product.Price
+		changes := map[string]interface{}{}
+		if update.Stock != nil {
+			h.logInventoryChange(sellerID, &product.ID, update, source, "stock", float64(product.Stock), float64(*update.Stock), apply, conflict, result.Message)
+			changes["stock"] = *update.Stock
+		}
+		if update.Price != nil {
+			h.logInventoryChange(sellerID, &product.ID, update, source, "price", product.Price, *update.Price, apply, conflict, result.Message)
+			changes["price"] = *update.Price
+		}
+
+		if apply && len(changes) > 0 {
+			if err := database.DB.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Model(&product).Updates(changes).Error; err != nil {
+					return err
+				}
+				if update.Price == nil || *update.Price == previousPrice {
+					return nil
+				}
+				return recordPriceChange(tx, product.ID, &previousPrice, *update.Price, models.PriceSourceSync, &sellerID)
+			}); err != nil {
+				result.Applied = false
+				result.Message = "Failed to update product"
+			} else {
+				redis.Delete(redis.ProductKey(product.ID))
+				if update.Stock != nil {
+					previousStock := product.Stock
+					product.Stock = *update.Stock
+					go h.onStockChanged(product, previousStock)
+				}
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	// Record sync time for configured integrations
+	if config.ID != uuid.Nil {
+		now := time.Now()
+		database.DB.Model(&config).Update("last_sync_at", now)
+	}
+
+	return results
+}
+
+// applyVariantInventoryUpdate applies an update whose SKU is one of the
+// seller's product variants
+func (h *ProductHandler) applyVariantInventoryUpdate(sellerID uuid.UUID, config models.InventorySyncConfig, source string, variant *models.ProductVariant, update InventoryUpdate) InventorySyncResult {
+	result := InventorySyncResult{SKU: update.SKU}
+
+	if update.Stock != nil && *update.Stock < 0 || update.Price != nil && *update.Price <= 0 {
+		result.Message = "Stock must be non-negative and price must be greater than 0"
+		h.logInventoryChange(sellerID, &variant.ProductID, update, source, "", 0, 0, false, false, result.Message)
+		return result
+	}
+
+	conflict, apply := syncConflict(config, update, variant.InventoryEditedAt)
+	result.Conflict = conflict
+	result.Applied = apply
+	if apply {
+		result.Message = "Applied"
+	} else {
+		result.Message = "Skipped: product was modified locally after this change"
+	}
+
+	changes := map[string]interface{}{}
+	if update.Stock != nil {
+		h.logInventoryChange(sellerID, &variant.ProductID, update, source, "stock", float64(variant.Stock), float64(*update.Stock), apply, conflict, result.Message)
+		changes["stock"] = *update.Stock
+	}
+	if update.Price != nil {
+		var previousPrice float64
+		if variant.Price != nil {
+			previousPrice = *variant.Price
+		}
+		h.logInventoryChange(sellerID, &variant.ProductID, update, source, "price", previousPrice, *update.Price, apply, conflict, result.Message)
+		changes["price"] = *update.Price
+	}
+	if !apply || len(changes) == 0 {
+		return result
+	}
+
+	var previousProductStock int
+	database.DB.Model(&models.Product{}).Where("id = ?", variant.ProductID).Select("stock").Scan(&previousProductStock)
+
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(variant).Updates(changes).Error; err != nil {
+			return err
+		}
+		return inventory.SyncVariantStock(tx, variant.ProductID)
+	}); err != nil {
+		result.Applied = false
+		result.Message = "Failed to update product"
+		return result
+	}
+
+	redis.Delete(redis.ProductKey(variant.ProductID))
+	if update.Stock != nil {
+		previousStock := variant.Stock
+		variant.Stock = *update.Stock
+		go h.onVariantStockChanged(*variant, previousStock, previousProductStock)
+	}
+	return result
+}
+
+// syncConflict detects the seller's own edits made after the external
+// change, and whether the update applies anyway under the seller's conflict
+// strategy. Stock taken by marketplace orders isn't an edit: the external
+// system is expected to account for those sales.
+func syncConflict(config models.InventorySyncConfig, update InventoryUpdate, editedAt *time.Time) (bool, bool) {
+	if editedAt == nil {
+		return false, true
+	}
+	localUpdatedAt := *editedAt
+	conflict := update.UpdatedAt != nil && localUpdatedAt.After(*update.UpdatedAt) ||
+		config.LastSyncAt != nil && localUpdatedAt.After(*config.LastSyncAt)
+	if !conflict {
+		return false, true
+	}
+
+	switch config.ConflictStrategy {
+	case models.ConflictSourceWins:
+		return true, true
+	case models.ConflictMarketplaceWins:
+		return true, false
+	default: // newest_wins
+		return true, update.UpdatedAt != nil && update.UpdatedAt.After(localUpdatedAt)
+	}
+}
+
+// sellerVariant finds the seller's product variant with the SKU
+func sellerVariant(sellerID uuid.UUID, sku string) *models.ProductVariant {
+	if sku == "" {
+		return nil
+	}
+	var variant models.ProductVariant
+	if err := database.DB.Joins("JOIN products ON products.id = product_variants.product_id").
+		Where("products.seller_id = ? AND product_variants.sku = ?", sellerID, sku).
+		First(&variant).Error; err != nil {
+		return nil
+	}
+	return &variant
+}
+
+func (h *ProductHandler) logInventoryChange(sellerID uuid.UUID, productID *uuid.UUID, update InventoryUpdate, source, field string, oldValue, newValue float64, applied, conflict bool, message string) {
+	entry := models.InventoryChangeLog{
+		BaseModel:       models.BaseModel{ID: uuid.New()},
+		SellerID:        sellerID,
+		ProductID:       productID,
+		SKU:             update.SKU,
+		Source:          source,
+		Field:           field,
+		OldValue:        oldValue,
+		NewValue:        newValue,
+		Applied:         applied,
+		Conflict:        conflict,
+		Message:         message,
+		SourceTimestamp: update.UpdatedAt,
+	}
+	database.DB.Create(&entry)
+}
+
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
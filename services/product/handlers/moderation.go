@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/moderation"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ReviewContentFlagRequest struct {
+	Decision string `json:"decision" validate:"required"` // approve or remove
+}
+
+// @Summary Get flagged content
+// @Description Get content flagged by the moderation filter (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param status query string false "Flag status" default(pending)
+// @Param content_type query string false "Filter by content type (product, review, review_reply, product_image, review_image)"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} utils.Response{data=[]models.ContentFlag}
+// @Router /admin/moderation/flags [get]
+func (h *ProductHandler) GetContentFlags(c *fiber.Ctx) error {
+	status := c.Query("status", string(models.ContentFlagPending))
+	contentType := c.Query("content_type")
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+
+	if page < 1 {
+		page = 1
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	query := database.DB.Where("status = ?", status)
+	if contentType != "" {
+		query = query.Where("content_type = ?", contentType)
+	}
+
+	var flags []models.ContentFlag
+	if err := query.Order("created_at ASC").Offset((page - 1) * limit).Limit(limit).Find(&flags).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get flagged content", err)
+	}
+
+	return utils.SuccessResponse(c, "Flagged content retrieved successfully", flags)
+}
+
+// @Summary Review flagged content
+// @Description Approve flagged content or remove it (admin only). Removing unlists products and deletes reviews.
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Flag ID"
+// @Param request body ReviewContentFlagRequest true "Review decision"
+// @Success 200 {object} utils.Response{data=models.ContentFlag}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /admin/moderation/flags/{id} [put]
+func (h *ProductHandler) ReviewContentFlag(c *fiber.Ctx) error {
+	flagID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid flag ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req ReviewContentFlagRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	var flag models.ContentFlag
+	if err := database.DB.First(&flag, flagID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Flag not found")
+	}
+
+	if flag.Status != models.ContentFlagPending {
+		return utils.ValidationErrorResponse(c, "Flag has already been reviewed")
+	}
+
+	switch req.Decision {
+	case "approve":
+		flag.Status = models.ContentFlagApproved
+		if err := h.restoreQuarantinedImage(&flag); err != nil {
+			return utils.InternalServerErrorResponse(c, "Failed to restore image", err)
+		}
+	case "remove":
+		flag.Status = models.ContentFlagRemoved
+		if err := h.removeFlaggedContent(&flag); err != nil {
+			return utils.InternalServerErrorResponse(c, "Failed to remove content", err)
+		}
+	default:
+		return utils.ValidationErrorResponse(c, "Decision must be approve or remove")
+	}
+
+	now := time.Now()
+	flag.ReviewedBy = &userID
+	flag.ReviewedAt = &now
+
+	if err := database.DB.Save(&flag).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update flag", err)
+	}
+
+	return utils.SuccessResponse(c, "Flag reviewed successfully", flag)
+}
+
+// Helper functions
+
+func (h *ProductHandler) removeFlaggedContent(flag *models.ContentFlag) error {
+	switch flag.ContentType {
+	case moderation.ContentProduct:
+		if err := database.DB.Model(&models.Product{}).Where("id = ?", flag.ContentID).
+			Update("is_active", false).Error; err != nil {
+			return err
+		}
+		redis.Delete(redis.ProductKey(flag.ContentID))
+	case moderation.ContentReview:
+		if err := database.DB.Delete(&models.Review{}, flag.ContentID).Error; err != nil {
+			return err
+		}
+		// Removed reviews drop off the author's timeline too
+		return database.DB.Where("type = ? AND reference = ?", models.ActivityReviewWritten, flag.ContentID.String()).
+			Delete(&models.ActivityEvent{}).Error
+	case moderation.ContentReviewReply:
+		// The review stays up, only the seller's reply goes
+		return database.DB.Model(&models.Review{}).Where("id = ?", flag.ContentID).Updates(map[string]interface{}{
+			"seller_reply":           "",
+			"seller_replied_at":      nil,
+			"seller_reply_edited_at": nil,
+		}).Error
+	}
+	// Quarantined images are already hidden
+	return nil
+}
+
+func (h *ProductHandler) restoreQuarantinedImage(flag *models.ContentFlag) error {
+	switch flag.ContentType {
+	case moderation.ContentProductImage:
+		if err := database.DB.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&models.ProductImage{}).Where("product_id = ? AND url = ?", flag.ContentID, flag.ImageURL).
+				Update("quarantined", false)
+			if result.Error != nil {
+				return result.Error
+			}
+			// Images quarantined before products had galleries were only cleared from the product
+			if result.RowsAffected == 0 {
+				var count int64
+				tx.Model(&models.ProductImage{}).Where("product_id = ?", flag.ContentID).Count(&count)
+				return addProductImage(tx, &models.ProductImage{
+					BaseModel: models.BaseModel{ID: uuid.New()},
+					ProductID: flag.ContentID,
+					URL:       flag.ImageURL,
+					Position:  int(count),
+					IsPrimary: count == 0,
+				})
+			}
+			return syncPrimaryImage(tx, flag.ContentID)
+		}); err != nil {
+			return err
+		}
+		redis.Delete(redis.ProductKey(flag.ContentID))
+	case moderation.ContentReviewImage:
+		return database.DB.Model(&models.Review{}).Where("id = ? AND image_url = ''", flag.ContentID).
+			Update("image_url", flag.ImageURL).Error
+	}
+	return nil
+}
+
+func contentRejectedResponse(c *fiber.Ctx) error {
+	return utils.ValidationErrorResponse(c, "Content contains language that is not allowed")
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	offerResponseTTL = 48 * time.Hour // Time the other side has to respond
+	offerCheckoutTTL = 24 * time.Hour // Lifetime of the discounted checkout link
+)
+
+type MakeOfferRequest struct {
+	Price    float64 `json:"price" validate:"required"` // Proposed unit price
+	Quantity int     `json:"quantity"`                  // Defaults to 1
+	Message  string  `json:"message"`
+}
+
+type RespondOfferRequest struct {
+	Action       string  `json:"action" validate:"required"` // accept, counter or decline
+	CounterPrice float64 `json:"counter_price"`              // Required to counter (seller only)
+}
+
+type OfferResponse struct {
+	Offer        models.Offer `json:"offer"`
+	CheckoutLink string       `json:"checkout_link,omitempty"` // Only shown to the buyer once accepted
+}
+
+// @Summary Make an offer
+// @Description Propose a lower unit price for a product. The seller can accept, counter or decline.
+// @Tags offers
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param request body MakeOfferRequest true "Offer"
+// @Success 201 {object} utils.Response{data=models.Offer}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /products/{id}/offers [post]
+func (h *ProductHandler) MakeOffer(c *fiber.Ctx) error {
+	productID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid product ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req MakeOfferRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.Quantity == 0 {
+		req.Quantity = 1
+	}
+	if req.Quantity < 1 {
+		return utils.ValidationErrorResponse(c, "Quantity must be at least 1")
+	}
+
+	var product models.Product
+	if err := database.DB.Where("id = ? AND is_active = ?", productID, true).First(&product).Error; err != nil {
+		return utils.NotFoundResponse(c, "Product not found")
+	}
+
+	if product.SellerID == userID {
+		return utils.ValidationErrorResponse(c, "You cannot make an offer on your own product")
+	}
+	if req.Price <= 0 || req.Price >= product.Price {
+		return utils.ValidationErrorResponse(c, "Offer must be above 0 and below the listed price")
+	}
+
+	var count int64
+	database.DB.Model(&models.Offer{}).
+		Where("product_id = ? AND buyer_id = ? AND status IN ? AND expires_at > ?", productID, userID,
+			[]models.OfferStatus{models.OfferPending, models.OfferCountered}, time.Now()).
+		Count(&count)
+	if count > 0 {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "You already have an open offer on this product", nil)
+	}
+
+	offer := models.Offer{
+		BaseModel:  models.BaseModel{ID: uuid.New()},
+		ProductID:  productID,
+		BuyerID:    userID,
+		SellerID:   product.SellerID,
+		Quantity:   req.Quantity,
+		OfferPrice: req.Price,
+		Message:    req.Message,
+		Status:     models.OfferPending,
+		ExpiresAt:  time.Now().Add(offerResponseTTL),
+	}
+	if err := database.DB.Create(&offer).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to make offer", err)
+	}
+
+	notifications.SendWithLink(product.SellerID, models.NotificationOrder, "New offer",
+		fmt.Sprintf("A buyer offered %.2f for %s (listed at %.2f).", req.Price, product.Name, product.Price),
+		fmt.Sprintf("playful://offers/%s", offer.ID))
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Offer sent to the seller",
+		Data:    offer,
+	})
+}
+
+// @Summary Get offers
+// @Description List offers the current user made as a buyer, or received as a seller
+// @Tags offers
+// @Security BearerAuth
+// @Param as query string false "buyer or seller" default(buyer)
+// @Param status query string false "Filter by status"
+// @Success 200 {object} utils.Response{data=[]OfferResponse}
+// @Router /offers [get]
+func (h *ProductHandler) GetOffers(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	column := "buyer_id"
+	if c.Query("as", "buyer") == "seller" {
+		column = "seller_id"
+	}
+
+	query := database.DB.Preload("Product").Where(column+" = ?", userID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var offers []models.Offer
+	if err := query.Order("created_at DESC").Limit(100).Find(&offers).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get offers", err)
+	}
+
+	responses := make([]OfferResponse, len(offers))
+	for i, offer := range offers {
+		responses[i] = OfferResponse{Offer: offer}
+		if offer.Status == models.OfferAccepted && offer.BuyerID == userID && time.Now().Before(offer.ExpiresAt) {
+			responses[i].CheckoutLink = checkoutLink(offer.CheckoutToken)
+		}
+	}
+
+	return utils.SuccessResponse(c, "Offers retrieved successfully", responses)
+}
+
+// @Summary Respond to offer
+// @Description The seller accepts, counters or declines a pending offer; the buyer accepts or declines a counter-offer. Accepting issues a time-limited checkout link for the buyer at the agreed price.
+// @Tags offers
+// @Security BearerAuth
+// @Param id path string true "Offer ID"
+// @Param request body RespondOfferRequest true "Response"
+// @Success 200 {object} utils.Response{data=OfferResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /offers/{id}/respond [post]
+func (h *ProductHandler) RespondToOffer(c *fiber.Ctx) error {
+	offerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid offer ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req RespondOfferRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	var offer models.Offer
+	if err := database.DB.Preload("Product").First(&offer, offerID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Offer not found")
+	}
+
+	if offer.BuyerID != userID && offer.SellerID != userID {
+		return utils.NotFoundResponse(c, "Offer not found")
+	}
+
+	if !offer.IsOpen() {
+		if offer.Status == models.OfferPending || offer.Status == models.OfferCountered {
+			database.DB.Model(&offer).Update("status", models.OfferExpired)
+			return utils.ValidationErrorResponse(c, "Offer has expired")
+		}
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("Offer is already %s", offer.Status))
+	}
+
+	// Sellers answer pending offers, buyers answer counter-offers
+	sellerTurn := offer.Status == models.OfferPending
+	if (sellerTurn && userID != offer.SellerID) || (!sellerTurn && userID != offer.BuyerID) {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "Waiting for the other party to respond", nil)
+	}
+
+	updates := map[string]interface{}{}
+	notifyID := offer.BuyerID
+	if !sellerTurn {
+		notifyID = offer.SellerID
+	}
+	var title, message, checkoutToken string
+
+	switch req.Action {
+	case "accept":
+		agreed := offer.OfferPrice
+		if offer.CounterPrice != nil {
+			agreed = *offer.CounterPrice
+		}
+		checkoutToken, err = generateCheckoutToken()
+		if err != nil {
+			return utils.InternalServerErrorResponse(c, "Failed to generate checkout link", err)
+		}
+		updates["status"] = models.OfferAccepted
+		updates["agreed_price"] = agreed
+		updates["checkout_token"] = checkoutToken
+		updates["expires_at"] = time.Now().Add(offerCheckoutTTL)
+		title = "Offer accepted"
+		message = fmt.Sprintf("The offer of %.2f for %s was accepted.", agreed, offer.Product.Name)
+	case "counter":
+		if !sellerTurn {
+			return utils.ValidationErrorResponse(c, "Only the seller can counter an offer")
+		}
+		if req.CounterPrice <= offer.OfferPrice || req.CounterPrice >= offer.Product.Price {
+			return utils.ValidationErrorResponse(c, "Counter-offer must be between the offer and the listed price")
+		}
+		updates["status"] = models.OfferCountered
+		updates["counter_price"] = req.CounterPrice
+		updates["expires_at"] = time.Now().Add(offerResponseTTL)
+		title = "Counter-offer received"
+		message = fmt.Sprintf("The seller countered your offer on %s with %.2f.", offer.Product.Name, req.CounterPrice)
+	case "decline":
+		updates["status"] = models.OfferDeclined
+		title = "Offer declined"
+		message = fmt.Sprintf("The offer on %s was declined.", offer.Product.Name)
+	default:
+		return utils.ValidationErrorResponse(c, "Action must be 'accept', 'counter' or 'decline'")
+	}
+
+	// Guard against both sides responding at once
+	result := database.DB.Model(&offer).Where("status = ?", offer.Status).Updates(updates)
+	if result.Error != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to respond to offer", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "Offer was updated by the other party", nil)
+	}
+
+	link := fmt.Sprintf("playful://offers/%s", offer.ID)
+	if checkoutToken != "" && notifyID == offer.BuyerID {
+		link = checkoutLink(checkoutToken)
+		message += fmt.Sprintf(" Check out within %d hours to get this price.", int(offerCheckoutTTL.Hours()))
+	}
+	notifications.SendWithLink(notifyID, models.NotificationOrder, title, message, link)
+
+	database.DB.Preload("Product").First(&offer, offer.ID)
+
+	response := OfferResponse{Offer: offer}
+	if checkoutToken != "" && userID == offer.BuyerID {
+		response.CheckoutLink = checkoutLink(checkoutToken)
+	}
+
+	return utils.SuccessResponse(c, "Offer updated successfully", response)
+}
+
+// Helper functions
+
+func generateCheckoutToken() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+func checkoutLink(token string) string {
+	return fmt.Sprintf("playful://checkout?offer=%s", token)
+}
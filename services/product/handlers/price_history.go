@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// A sale's "was" price is the lowest the product sold for over this many
+// days before the sale price took effect
+const wasPriceDays = 30
+
+const maxScheduledPriceChanges = 20
+
+var errPriceChangeNotFound = errors.New("scheduled price change not found")
+
+type SchedulePriceChangeRequest struct {
+	Price       float64   `json:"price" validate:"required,min=0"`
+	EffectiveAt time.Time `json:"effective_at" validate:"required"` // RFC 3339, in the future
+}
+
+type PriceHistoryResponse struct {
+	History  []models.PriceHistory `json:"history"` // Newest first
+	WasPrice *float64              `json:"was_price,omitempty"`
+	Days     int                   `json:"days"`
+}
+
+// @Summary Get product price history
+// @Description Get the prices a product has had, newest first, with the "was" price when the current price is a reduction: the lowest price over the 30 days before it took effect
+// @Tags products
+// @Param id path string true "Product ID"
+// @Param days query int false "Days to look back, up to 365" default(90)
+// @Success 200 {object} utils.Response{data=PriceHistoryResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /products/{id}/price-history [get]
+func (h *ProductHandler) GetPriceHistory(c *fiber.Ctx) error {
+	productID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid product ID")
+	}
+
+	days := c.QueryInt("days", 90)
+	if days < 1 {
+		days = 1
+	}
+	if days > 365 {
+		days = 365
+	}
+
+	var product models.Product
+	if err := database.DB.Select("id", "price").Where("is_active = ?", true).First(&product, productID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Product not found")
+	}
+
+	history, err := priceHistory(productID, days)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get price history", err)
+	}
+
+	response := PriceHistoryResponse{
+		History:  history,
+		WasPrice: wasPrice(productID, product.Price),
+		Days:     days,
+	}
+
+	return utils.SuccessResponse(c, "Price history retrieved successfully", response)
+}
+
+// @Summary Get scheduled price changes
+// @Description Get a product's pending price changes, soonest first (seller only, own products)
+// @Tags products
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Success 200 {object} utils.Response{data=[]models.ScheduledPriceChange}
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /products/{id}/price-schedule [get]
+func (h *ProductHandler) GetScheduledPriceChanges(c *fiber.Ctx) error {
+	product, err := ownProduct(c)
+	if err != nil {
+		return priceChangeErrorResponse(c, err)
+	}
+
+	var changes []models.ScheduledPriceChange
+	if err := database.DB.Where("product_id = ? AND status = ?", product.ID, models.PriceChangePending).
+		Order("effective_at ASC").Find(&changes).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get scheduled price changes", err)
+	}
+
+	return utils.SuccessResponse(c, "Scheduled price changes retrieved successfully", changes)
+}
+
+// @Summary Schedule a price change
+// @Description Set a new price to take effect at a future time, e.g. the start or end of a sale (seller only, own products)
+// @Tags products
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param request body SchedulePriceChangeRequest true "Scheduled price change"
+// @Success 201 {object} utils.Response{data=models.ScheduledPriceChange}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /products/{id}/price-schedule [post]
+func (h *ProductHandler) SchedulePriceChange(c *fiber.Ctx) error {
+	product, err := ownProduct(c)
+	if err != nil {
+		return priceChangeErrorResponse(c, err)
+	}
+
+	var req SchedulePriceChangeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.Price <= 0 {
+		return utils.ValidationErrorResponse(c, "Price must be greater than 0")
+	}
+	if !req.EffectiveAt.After(time.Now()) {
+		return utils.ValidationErrorResponse(c, "Effective time must be in the future")
+	}
+
+	var pending int64
+	database.DB.Model(&models.ScheduledPriceChange{}).
+		Where("product_id = ? AND status = ?", product.ID, models.PriceChangePending).
+		Count(&pending)
+	if pending >= maxScheduledPriceChanges {
+		return utils.ValidationErrorResponse(c, "A product can have at most 20 scheduled price changes")
+	}
+
+	change := models.ScheduledPriceChange{
+		BaseModel:   models.BaseModel{ID: uuid.New()},
+		ProductID:   product.ID,
+		Price:       req.Price,
+		EffectiveAt: req.EffectiveAt,
+		Status:      models.PriceChangePending,
+		CreatedBy:   product.SellerID,
+	}
+
+	if err := database.DB.Create(&change).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to schedule price change", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Price change scheduled successfully",
+		Data:    change,
+	})
+}
+
+// @Summary Cancel a scheduled price change
+// @Description Cancel a price change that hasn't taken effect yet (seller only, own products)
+// @Tags products
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param changeId path string true "Scheduled price change ID"
+// @Success 200 {object} utils.Response{data=models.ScheduledPriceChange}
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /products/{id}/price-schedule/{changeId} [delete]
+func (h *ProductHandler) CancelScheduledPriceChange(c *fiber.Ctx) error {
+	product, err := ownProduct(c)
+	if err != nil {
+		return priceChangeErrorResponse(c, err)
+	}
+
+	changeID, err := uuid.Parse(c.Params("changeId"))
+	if err != nil {
+		return priceChangeErrorResponse(c, errPriceChangeNotFound)
+	}
+
+	var change models.ScheduledPriceChange
+	if err := database.DB.Where("id = ? AND product_id = ? AND status = ?", changeID, product.ID, models.PriceChangePending).
+		First(&change).Error; err != nil {
+		return priceChangeErrorResponse(c, errPriceChangeNotFound)
+	}
+
+	result := database.DB.Model(&change).Where("status = ?", models.PriceChangePending).
+		Update("status", models.PriceChangeCancelled)
+	if result.Error != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to cancel price change", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return utils.ValidationErrorResponse(c, "Price change has already taken effect")
+	}
+
+	return utils.SuccessResponse(c, "Price change cancelled successfully", change)
+}
+
+// ApplyScheduledPriceChanges puts scheduled prices that are due into effect.
+// Run periodically by the scheduler.
+func (h *ProductHandler) ApplyScheduledPriceChanges() {
+	var changes []models.ScheduledPriceChange
+	database.DB.Where("status = ? AND effective_at <= ?", models.PriceChangePending, time.Now()).
+		Order("effective_at ASC").
+		Limit(500).
+		Find(&changes)
+
+	for i := range changes {
+		if err := h.applyPriceChange(&changes[i]); err != nil {
+			log.Printf("Failed to apply price change %s: %v", changes[i].ID, err)
+		}
+	}
+}
+
+func (h *ProductHandler) applyPriceChange(change *models.ScheduledPriceChange) error {
+	var product models.Product
+	var previousPrice float64
+	applied := false
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Cancelled or applied since it was picked up
+		now := time.Now()
+		result := tx.Model(change).Where("status = ?", models.PriceChangePending).
+			Updates(map[string]interface{}{"status": models.PriceChangeApplied, "applied_at": now})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, change.ProductID).Error; err != nil {
+			return err
+		}
+		previousPrice = product.Price
+		applied = true
+		if product.Price == change.Price {
+			return nil
+		}
+
+		if err := tx.Model(&product).Updates(map[string]interface{}{
+			"price":               change.Price,
+			"inventory_edited_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		return recordPriceChange(tx, product.ID, &previousPrice, change.Price, models.PriceSourceSchedule, &change.CreatedBy)
+	})
+	if err != nil || !applied || previousPrice == change.Price {
+		return err
+	}
+
+	redis.Delete(redis.ProductKey(product.ID))
+	if product.Price < previousPrice {
+		go h.notifyPriceDrop(product, previousPrice)
+	}
+	return nil
+}
+
+// recordPriceChange adds a price to the product's history inside tx
+func recordPriceChange(tx *gorm.DB, productID uuid.UUID, previousPrice *float64, price float64, source models.PriceSource, changedBy *uuid.UUID) error {
+	return tx.Create(&models.PriceHistory{
+		BaseModel:     models.BaseModel{ID: uuid.New()},
+		ProductID:     productID,
+		PreviousPrice: previousPrice,
+		Price:         price,
+		Source:        source,
+		ChangedBy:     changedBy,
+		EffectiveAt:   time.Now(),
+	}).Error
+}
+
+// priceHistory returns the product's prices over the last days, newest first
+func priceHistory(productID uuid.UUID, days int) ([]models.PriceHistory, error) {
+	var history []models.PriceHistory
+	err := database.DB.Where("product_id = ? AND effective_at >= ?", productID, time.Now().AddDate(0, 0, -days)).
+		Order("effective_at DESC").
+		Find(&history).Error
+	return history, err
+}
+
+// wasPrice returns the lowest price the product had over the wasPriceDays
+// before its current price took effect, when that's above the current
+// price. Only a genuine reduction from a price that was actually charged
+// can be shown as a sale.
+func wasPrice(productID uuid.UUID, currentPrice float64) *float64 {
+	var current models.PriceHistory
+	if err := database.DB.Where("product_id = ?", productID).Order("effective_at DESC").First(&current).Error; err != nil {
+		return nil
+	}
+	since := current.EffectiveAt.AddDate(0, 0, -wasPriceDays)
+
+	// The prices set within the window, and the one already in effect when it opened
+	var prices []float64
+	database.DB.Model(&models.PriceHistory{}).
+		Where("product_id = ? AND effective_at >= ? AND effective_at < ?", productID, since, current.EffectiveAt).
+		Pluck("price", &prices)
+	var opening models.PriceHistory
+	if err := database.DB.Where("product_id = ? AND effective_at < ?", productID, since).
+		Order("effective_at DESC").First(&opening).Error; err == nil {
+		prices = append(prices, opening.Price)
+	}
+	if len(prices) == 0 {
+		return nil
+	}
+
+	lowest := prices[0]
+	for _, price := range prices[1:] {
+		if price < lowest {
+			lowest = price
+		}
+	}
+	if lowest <= currentPrice {
+		return nil
+	}
+	return &lowest
+}
+
+func priceChangeErrorResponse(c *fiber.Ctx, err error) error {
+	if errors.Is(err, errPriceChangeNotFound) {
+		return utils.NotFoundResponse(c, "Scheduled price change not found")
+	}
+	return variantErrorResponse(c, err)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const maxPriceTiers = 10
+
+type PriceTierRequest struct {
+	MinQuantity     int     `json:"min_quantity" validate:"required,min=2"`
+	DiscountPercent float64 `json:"discount_percent" validate:"required"`
+}
+
+type UpdatePriceTiersRequest struct {
+	Tiers []PriceTierRequest `json:"tiers"` // Empty removes all tiers
+}
+
+// @Summary Set price tiers
+// @Description Replace a product's quantity-break discounts, e.g. 10% off at 10+ units. Larger quantities must get larger discounts (seller only, own products).
+// @Tags products
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param request body UpdatePriceTiersRequest true "Price tiers"
+// @Success 200 {object} utils.Response{data=[]models.PriceTier}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /products/{id}/price-tiers [put]
+func (h *ProductHandler) UpdatePriceTiers(c *fiber.Ctx) error {
+	productID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid product ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var product models.Product
+	if err := database.DB.First(&product, productID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Product not found")
+	}
+
+	if product.SellerID != userID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only update your own products", nil)
+	}
+
+	var req UpdatePriceTiersRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if problem := validatePriceTiers(req.Tiers); problem != "" {
+		return utils.ValidationErrorResponse(c, problem)
+	}
+
+	tiers := make([]models.PriceTier, len(req.Tiers))
+	for i, tier := range req.Tiers {
+		tiers[i] = models.PriceTier{
+			BaseModel:       models.BaseModel{ID: uuid.New()},
+			ProductID:       productID,
+			MinQuantity:     tier.MinQuantity,
+			DiscountPercent: tier.DiscountPercent,
+		}
+	}
+
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("product_id = ?", productID).Delete(&models.PriceTier{}).Error; err != nil {
+			return err
+		}
+		if len(tiers) == 0 {
+			return nil
+		}
+		return tx.Create(&tiers).Error
+	}); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update price tiers", err)
+	}
+
+	redis.Delete(redis.ProductKey(productID))
+
+	return utils.SuccessResponse(c, "Price tiers updated successfully", tiers)
+}
+
+// Helper functions
+
+// validatePriceTiers sorts the tiers by quantity and checks each step down
+// in price is a real one
+func validatePriceTiers(tiers []PriceTierRequest) string {
+	if len(tiers) > maxPriceTiers {
+		return fmt.Sprintf("A product can have at most %d price tiers", maxPriceTiers)
+	}
+
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].MinQuantity < tiers[j].MinQuantity
+	})
+
+	for i, tier := range tiers {
+		if tier.MinQuantity < 2 {
+			return "Price tiers start at a quantity of 2"
+		}
+		if tier.DiscountPercent <= 0 || tier.DiscountPercent >= 100 {
+			return "Discount must be between 0 and 100 percent"
+		}
+		if i > 0 {
+			previous := tiers[i-1]
+			if tier.MinQuantity == previous.MinQuantity {
+				return fmt.Sprintf("Duplicate price tier for %d units", tier.MinQuantity)
+			}
+			if tier.DiscountPercent <= previous.DiscountPercent {
+				return "Larger quantities must get larger discounts"
+			}
+		}
+	}
+	return ""
+}
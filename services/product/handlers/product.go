@@ -1,51 +1,109 @@
 package handlers
 
 import (
+	"fmt"
 	"strings"
+	"time"
 
+	"playful-marketplace/shared/analytics"
 	"playful-marketplace/shared/config"
 	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/feeds"
 	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/moderation"
 	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/returns"
+	"playful-marketplace/shared/shipping"
+	"playful-marketplace/shared/storage"
+	"playful-marketplace/shared/stores"
 	"playful-marketplace/shared/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type ProductHandler struct {
-	config *config.Config
+	config  *config.Config
+	feeds   *feeds.Generator
+	storage storage.Storage
 }
 
 type CreateProductRequest struct {
 	Name        string  `json:"name" validate:"required"`
+	SKU         string  `json:"sku"`
 	Description string  `json:"description"`
 	Price       float64 `json:"price" validate:"required,min=0"`
 	Stock       int     `json:"stock" validate:"min=0"`
 	Category    string  `json:"category"`
 	ImageURL    string  `json:"image_url"`
+	ImageURLs   []string `json:"image_urls"` // More gallery images after image_url
+	Attributes  map[string]string `json:"attributes"`
+
+	Availability models.ProductAvailability `json:"availability"` // in_stock, backorder or preorder
+	ExpectedAt   *time.Time                 `json:"expected_at"`  // Required for backorder and preorder
+
+	MinOrderQuantity int `json:"min_order_quantity"`
+	MaxOrderQuantity int `json:"max_order_quantity"`
+	PerCustomerLimit int `json:"per_customer_limit"` // e.g. 2 per customer during a flash sale
+
+	LowStockThreshold int `json:"low_stock_threshold"` // Alert when stock falls to this, 0 only when sold out
+
+	Slug            string `json:"slug"` // Storefront URL, made from the name when omitted
+	MetaTitle       string `json:"meta_title"`
+	MetaDescription string `json:"meta_description"`
 }
 
 type UpdateProductRequest struct {
 	Name        string  `json:"name"`
+	SKU         string  `json:"sku"`
 	Description string  `json:"description"`
 	Price       *float64 `json:"price"`
 	Stock       *int    `json:"stock"`
 	Category    string  `json:"category"`
 	ImageURL    string  `json:"image_url"`
+	Attributes  map[string]string `json:"attributes"`
 	IsActive    *bool   `json:"is_active"`
+
+	Availability models.ProductAvailability `json:"availability"`
+	ExpectedAt   *time.Time                 `json:"expected_at"`
+
+	MinOrderQuantity *int `json:"min_order_quantity"` // 0 removes the limit
+	MaxOrderQuantity *int `json:"max_order_quantity"`
+	PerCustomerLimit *int `json:"per_customer_limit"`
+
+	LowStockThreshold *int `json:"low_stock_threshold"`
+
+	Slug            string  `json:"slug"`             // The old slug keeps working as a redirect
+	MetaTitle       *string `json:"meta_title"`       // Empty falls back to the name
+	MetaDescription *string `json:"meta_description"` // Empty falls back to the description
+}
+
+type ProductDetailResponse struct {
+	*models.Product
+	SEO          ProductSEO           `json:"seo"`
+	ReturnPolicy models.ReturnPolicy  `json:"return_policy"`
+	FinalSale    bool                 `json:"final_sale"`
+	Shipping     models.ShippingSettings `json:"shipping"`
+	PriceHistory []models.PriceHistory `json:"price_history"`        // Last 90 days, newest first
+	WasPrice     *float64             `json:"was_price,omitempty"` // Set when the current price is a genuine reduction
+	Currency     utils.CurrencyFormat `json:"currency_format"`
 }
 
 type ProductListResponse struct {
-	Products []models.Product `json:"products"`
-	Total    int64            `json:"total"`
-	Page     int              `json:"page"`
-	Limit    int              `json:"limit"`
+	Products  []models.Product     `json:"products"`
+	Sponsored []SponsoredProduct   `json:"sponsored,omitempty"` // Shown above the results on category and search pages
+	Total     int64                `json:"total"`
+	Page      int                  `json:"page"`
+	Limit     int                  `json:"limit"`
+	Currency  utils.CurrencyFormat `json:"currency_format"`
 }
 
 func NewProductHandler(cfg *config.Config) *ProductHandler {
 	return &ProductHandler{
-		config: cfg,
+		config:  cfg,
+		feeds:   feeds.New(cfg),
+		storage: storage.New(cfg.Storage),
 	}
 }
 
@@ -59,6 +117,8 @@ func NewProductHandler(cfg *config.Config) *ProductHandler {
 // @Param min_price query number false "Minimum price filter"
 // @Param max_price query number false "Maximum price filter"
 // @Param seller_id query string false "Filter by seller ID"
+// @Param verified_seller query bool false "Only products from verified sellers"
+// @Param on_sale query bool false "Only products on sale"
 // @Success 200 {object} utils.Response{data=ProductListResponse}
 // @Router /products [get]
 func (h *ProductHandler) GetProducts(c *fiber.Ctx) error {
@@ -69,6 +129,8 @@ func (h *ProductHandler) GetProducts(c *fiber.Ctx) error {
 	minPrice := c.QueryFloat("min_price", 0)
 	maxPrice := c.QueryFloat("max_price", 0)
 	sellerID := c.Query("seller_id")
+	verifiedSeller := c.QueryBool("verified_seller", false)
+	saleOnly := c.QueryBool("on_sale", false)
 
 	if page < 1 {
 		page = 1
@@ -90,12 +152,11 @@ func (h *ProductHandler) GetProducts(c *fiber.Ctx) error {
 		query = query.Where("name ILIKE ? OR description ILIKE ?", "%"+search+"%", "%"+search+"%")
 	}
 
-	if minPrice > 0 {
-		query = query.Where("price >= ?", minPrice)
-	}
+	// Prices filter on what's charged now, sale prices included
+	query = priceBetween(query, minPrice, maxPrice)
 
-	if maxPrice > 0 {
-		query = query.Where("price <= ?", maxPrice)
+	if saleOnly {
+		query = onSale(query)
 	}
 
 	if sellerID != "" {
@@ -104,21 +165,36 @@ func (h *ProductHandler) GetProducts(c *fiber.Ctx) error {
 		}
 	}
 
+	if verifiedSeller {
+		query = query.Where("seller_id IN (?)", verifiedSellers())
+	}
+
 	// Get total count
 	var total int64
 	query.Count(&total)
 
 	// Get products with seller info
 	var products []models.Product
-	if err := query.Preload("Seller").Offset(offset).Limit(limit).Order("created_at DESC").Find(&products).Error; err != nil {
+	if err := query.Preload("Seller.Profile").Offset(offset).Limit(limit).Order("created_at DESC").Find(&products).Error; err != nil {
 		return utils.InternalServerErrorResponse(c, "Failed to get products", err)
 	}
+	fillSales(products)
+
+	analytics.Track(analytics.EventImpression, productIDs(products)...)
 
 	response := ProductListResponse{
 		Products: products,
 		Total:    total,
 		Page:     page,
 		Limit:    limit,
+		Currency: utils.CurrencyHint(c, h.config),
+	}
+
+	// Category pages lead with sponsored products from the category
+	if category != "" && search == "" && sellerID == "" && page == 1 {
+		response.Sponsored = h.sponsoredProducts(models.PlacementCategory, func(db *gorm.DB) *gorm.DB {
+			return db.Where("products.category ILIKE ?", "%"+category+"%")
+		})
 	}
 
 	return utils.SuccessResponse(c, "Products retrieved successfully", response)
@@ -128,8 +204,8 @@ func (h *ProductHandler) GetProducts(c *fiber.Ctx) error {
 // @Description Get detailed information about a specific product
 // @Tags products
 // @Param id path string true "Product ID"
-// @Success 200 {object} utils.Response{data=models.Product}
-// @Failure 404 {object} utils.Response
+// @Success 200 {object} utils.Response{data=ProductDetailResponse}
+// @Failure 404 {object} utils.Problem
 // @Router /products/{id} [get]
 func (h *ProductHandler) GetProduct(c *fiber.Ctx) error {
 	productIDParam := c.Params("id")
@@ -138,21 +214,50 @@ func (h *ProductHandler) GetProduct(c *fiber.Ctx) error {
 		return utils.ValidationErrorResponse(c, "Invalid product ID")
 	}
 
+	return h.productDetail(c, productID)
+}
+
+// productDetail responds with the product's detail page, by ID or slug
+func (h *ProductHandler) productDetail(c *fiber.Ctx, productID uuid.UUID) error {
 	// Try to get from cache first
-	cacheKey := "product:" + productID.String()
+	cacheKey := redis.ProductKey(productID)
 	var product models.Product
 	
 	if err := redis.Get(cacheKey, &product); err != nil {
 		// Not in cache, get from database
-		if err := database.DB.Preload("Seller").First(&product, productID).Error; err != nil {
+		if err := database.DB.Preload("Seller.Profile").Preload("Seller.ReturnPolicy").Preload("PriceTiers", func(db *gorm.DB) *gorm.DB {
+			return db.Order("min_quantity ASC")
+		}).Preload("Variants", "is_active = ?", true, func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC")
+		}).Preload("Images", "quarantined = ?", false, func(db *gorm.DB) *gorm.DB {
+			return db.Order("position ASC")
+		}).First(&product, productID).Error; err != nil {
 			return utils.NotFoundResponse(c, "Product not found")
 		}
 
-		// Cache for 5 minutes
-		redis.Set(cacheKey, product, 5*60)
+		redis.Set(cacheKey, product)
 	}
 
-	return utils.SuccessResponse(c, "Product retrieved successfully", product)
+	analytics.Track(analytics.EventView, product.ID)
+	product.FillSale()
+
+	// Attach seller return policy
+	policy := returns.PolicyForSeller(product.SellerID)
+
+	history, _ := priceHistory(product.ID, 90)
+
+	response := ProductDetailResponse{
+		Product:      &product,
+		SEO:          productSEO(&product),
+		ReturnPolicy: policy,
+		FinalSale:    returns.IsFinalSale(policy, product.Category),
+		Shipping:     shipping.SettingsForSeller(product.SellerID),
+		PriceHistory: history,
+		WasPrice:     wasPrice(product.ID, product.Price),
+		Currency:     utils.CurrencyHint(c, h.config),
+	}
+
+	return utils.SuccessResponse(c, "Product retrieved successfully", response)
 }
 
 // @Summary Create new product
@@ -161,8 +266,8 @@ func (h *ProductHandler) GetProduct(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Param request body CreateProductRequest true "Create product request"
 // @Success 201 {object} utils.Response{data=models.Product}
-// @Failure 400 {object} utils.Response
-// @Failure 403 {object} utils.Response
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
 // @Router /products [post]
 func (h *ProductHandler) CreateProduct(c *fiber.Ctx) error {
 	// Check if user is a seller
@@ -186,25 +291,125 @@ func (h *ProductHandler) CreateProduct(c *fiber.Ctx) error {
 		return utils.ValidationErrorResponse(c, "Name and price are required, price must be greater than 0")
 	}
 
+	// SKUs must be unique per seller
+	if req.SKU != "" && h.skuTaken(userID, req.SKU, uuid.Nil) {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "A product with this SKU already exists", nil)
+	}
+
 	// Create product
 	product := models.Product{
 		BaseModel:   models.BaseModel{ID: uuid.New()},
 		Name:        req.Name,
+		SKU:         req.SKU,
 		Description: req.Description,
 		Price:       req.Price,
 		Stock:       req.Stock,
 		Category:    req.Category,
 		ImageURL:    req.ImageURL,
+		Attributes:  req.Attributes,
 		IsActive:    true,
 		SellerID:    userID,
+
+		Availability: models.AvailabilityInStock,
+		ExpectedAt:   req.ExpectedAt,
+
+		MinOrderQuantity: req.MinOrderQuantity,
+		MaxOrderQuantity: req.MaxOrderQuantity,
+		PerCustomerLimit: req.PerCustomerLimit,
+
+		LowStockThreshold: req.LowStockThreshold,
+
+		MetaTitle:       req.MetaTitle,
+		MetaDescription: req.MetaDescription,
+	}
+	if req.Availability != "" {
+		product.Availability = req.Availability
+	}
+	if problem := validateAvailability(&product); problem != "" {
+		return utils.ValidationErrorResponse(c, problem)
+	}
+	if problem := validateQuantityRules(&product); problem != "" {
+		return utils.ValidationErrorResponse(c, problem)
+	}
+	var slug *string
+	if req.Slug != "" {
+		slug = &req.Slug
+	}
+	if problem := validateSEO(slug, product.MetaTitle, product.MetaDescription); problem != "" {
+		return utils.ValidationErrorResponse(c, problem)
 	}
 
-	if err := database.DB.Create(&product).Error; err != nil {
+	// An image given by URL starts the product's gallery, followed by the rest
+	var images []*models.ProductImage
+	for _, url := range append([]string{product.ImageURL}, req.ImageURLs...) {
+		if url = strings.TrimSpace(url); url == "" {
+			continue
+		}
+		images = append(images, &models.ProductImage{
+			BaseModel: models.BaseModel{ID: uuid.New()},
+			ProductID: product.ID,
+			URL:       url,
+			Position:  len(images),
+			IsPrimary: len(images) == 0,
+		})
+	}
+	if len(images) > maxProductImages {
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("A product can have at most %d images", maxProductImages))
+	}
+	if len(images) > 0 {
+		product.ImageURL = images[0].URL
+	}
+
+	// Enforce category listing requirements
+	if problems := h.validateListing(&product, len(images)); len(problems) > 0 {
+		return listingErrorResponse(c, product.Category, problems)
+	}
+
+	// Screen listing text
+	screening := moderation.Check(product.Name, product.Description, product.MetaTitle, product.MetaDescription)
+	if moderation.Blocked(screening) {
+		return contentRejectedResponse(c)
+	}
+
+	// Sellers choosing a slug get it or a conflict, otherwise it comes from the name
+	if slug != nil {
+		if slugTaken(database.DB, *slug, product.ID) {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "Slug is already in use", nil)
+		}
+	} else {
+		generated := uniqueSlug(database.DB, slugify(product.Name), product.ID)
+		slug = &generated
+	}
+	product.Slug = slug
+
+	product.StoreID = stores.StoreID(database.DB, userID)
+
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&product).Error; err != nil {
+			return err
+		}
+		if err := recordPriceChange(tx, product.ID, nil, product.Price, models.PriceSourceListing, &userID); err != nil {
+			return err
+		}
+		for _, image := range images {
+			if err := addProductImage(tx, image); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
 		return utils.InternalServerErrorResponse(c, "Failed to create product", err)
 	}
 
+	if screening.Flagged {
+		moderation.Flag(moderation.ContentProduct, product.ID, userID, screening)
+	}
+	for _, image := range images {
+		h.scanProductImage(image, userID)
+	}
+
 	// Load seller information
-	database.DB.Preload("Seller").First(&product, product.ID)
+	database.DB.Preload("Seller.Profile").First(&product, product.ID)
 
 	return c.Status(fiber.StatusCreated).JSON(utils.Response{
 		Success: true,
@@ -220,9 +425,9 @@ func (h *ProductHandler) CreateProduct(c *fiber.Ctx) error {
 // @Param id path string true "Product ID"
 // @Param request body UpdateProductRequest true "Update product request"
 // @Success 200 {object} utils.Response{data=models.Product}
-// @Failure 400 {object} utils.Response
-// @Failure 403 {object} utils.Response
-// @Failure 404 {object} utils.Response
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
 // @Router /products/{id} [put]
 func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
 	productIDParam := c.Params("id")
@@ -256,36 +461,161 @@ func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
 	if req.Name != "" {
 		product.Name = req.Name
 	}
+	if req.SKU != "" && req.SKU != product.SKU {
+		if h.skuTaken(userID, req.SKU, product.ID) {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "A product with this SKU already exists", nil)
+		}
+		product.SKU = req.SKU
+	}
 	if req.Description != "" {
 		product.Description = req.Description
 	}
+	previousPrice := product.Price
 	if req.Price != nil && *req.Price > 0 {
 		product.Price = *req.Price
 	}
-	if req.Stock != nil && *req.Stock >= 0 {
+	previousStock := product.Stock
+	if req.Stock != nil && *req.Stock >= 0 && *req.Stock != product.Stock {
+		if hasVariants(product.ID) {
+			return utils.ValidationErrorResponse(c, "Set the stock of each of the product's variants instead")
+		}
 		product.Stock = *req.Stock
 	}
+	if product.Price != previousPrice || product.Stock != previousStock {
+		now := time.Now()
+		product.InventoryEditedAt = &now
+	}
 	if req.Category != "" {
 		product.Category = req.Category
 	}
-	if req.ImageURL != "" {
-		product.ImageURL = req.ImageURL
+	// An image given by URL is added to the gallery as the primary image
+	var image *models.ProductImage
+	if req.ImageURL != "" && req.ImageURL != product.ImageURL {
+		image = &models.ProductImage{
+			BaseModel: models.BaseModel{ID: uuid.New()},
+			ProductID: product.ID,
+			URL:       req.ImageURL,
+			IsPrimary: true,
+		}
+	}
+	if req.Attributes != nil {
+		product.Attributes = req.Attributes
 	}
 	if req.IsActive != nil {
 		product.IsActive = *req.IsActive
 	}
+	if req.Availability != "" {
+		product.Availability = req.Availability
+	}
+	if req.ExpectedAt != nil {
+		product.ExpectedAt = req.ExpectedAt
+	}
+	if product.Availability == models.AvailabilityInStock {
+		product.ExpectedAt = nil
+	}
+	if req.Availability != "" || req.ExpectedAt != nil {
+		if problem := validateAvailability(&product); problem != "" {
+			return utils.ValidationErrorResponse(c, problem)
+		}
+	}
+	if req.MinOrderQuantity != nil {
+		product.MinOrderQuantity = *req.MinOrderQuantity
+	}
+	if req.MaxOrderQuantity != nil {
+		product.MaxOrderQuantity = *req.MaxOrderQuantity
+	}
+	if req.PerCustomerLimit != nil {
+		product.PerCustomerLimit = *req.PerCustomerLimit
+	}
+	if req.LowStockThreshold != nil {
+		product.LowStockThreshold = *req.LowStockThreshold
+	}
+	if problem := validateQuantityRules(&product); problem != "" {
+		return utils.ValidationErrorResponse(c, problem)
+	}
+	if req.MetaTitle != nil {
+		product.MetaTitle = *req.MetaTitle
+	}
+	if req.MetaDescription != nil {
+		product.MetaDescription = *req.MetaDescription
+	}
+	var slug *string
+	if req.Slug != "" {
+		slug = &req.Slug
+	}
+	if problem := validateSEO(slug, product.MetaTitle, product.MetaDescription); problem != "" {
+		return utils.ValidationErrorResponse(c, problem)
+	}
+	if slug != nil && slugTaken(database.DB, *slug, product.ID) {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "Slug is already in use", nil)
+	}
+
+	// Enforce category listing requirements
+	newImages := 0
+	if image != nil {
+		newImages = 1
+	}
+	if problems := h.validateListing(&product, newImages); len(problems) > 0 {
+		return listingErrorResponse(c, product.Category, problems)
+	}
+
+	// Screen listing text
+	screening := moderation.Check(product.Name, product.Description, product.MetaTitle, product.MetaDescription)
+	if moderation.Blocked(screening) {
+		return contentRejectedResponse(c)
+	}
 
 	// Save changes
-	if err := database.DB.Save(&product).Error; err != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if slug != nil {
+			if err := setSlug(tx, &product, *slug); err != nil {
+				return err
+			}
+		}
+		if err := tx.Save(&product).Error; err != nil {
+			return err
+		}
+		if product.Price != previousPrice {
+			if err := recordPriceChange(tx, product.ID, &previousPrice, product.Price, models.PriceSourceSeller, &userID); err != nil {
+				return err
+			}
+		}
+		if image == nil {
+			return nil
+		}
+		var count int64
+		if err := tx.Model(&models.ProductImage{}).Where("product_id = ?", product.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		image.Position = int(count)
+		return addProductImage(tx, image)
+	})
+	if err != nil {
 		return utils.InternalServerErrorResponse(c, "Failed to update product", err)
 	}
 
+	if screening.Flagged {
+		moderation.Flag(moderation.ContentProduct, product.ID, userID, screening)
+	}
+	if image != nil {
+		h.scanProductImage(image, userID)
+	}
+	go h.onStockChanged(product, previousStock)
+	if product.Price < previousPrice {
+		go h.notifyPriceDrop(product, previousPrice)
+	}
+
+	// Keep buyers waiting on this product up to date with the new date
+	if req.ExpectedAt != nil {
+		database.DB.Model(&models.OrderItem{}).Where("product_id = ? AND awaiting_stock = ?", product.ID, true).
+			Update("expected_at", product.ExpectedAt)
+	}
+
 	// Clear cache
-	cacheKey := "product:" + productID.String()
-	redis.Delete(cacheKey)
+	redis.Delete(redis.ProductKey(productID))
 
 	// Load seller information
-	database.DB.Preload("Seller").First(&product, product.ID)
+	database.DB.Preload("Seller.Profile").First(&product, product.ID)
 
 	return utils.SuccessResponse(c, "Product updated successfully", product)
 }
@@ -296,8 +626,8 @@ func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Param id path string true "Product ID"
 // @Success 200 {object} utils.Response
-// @Failure 403 {object} utils.Response
-// @Failure 404 {object} utils.Response
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
 // @Router /products/{id} [delete]
 func (h *ProductHandler) DeleteProduct(c *fiber.Ctx) error {
 	productIDParam := c.Params("id")
@@ -328,8 +658,7 @@ func (h *ProductHandler) DeleteProduct(c *fiber.Ctx) error {
 	}
 
 	// Clear cache
-	cacheKey := "product:" + productID.String()
-	redis.Delete(cacheKey)
+	redis.Delete(redis.ProductKey(productID))
 
 	return utils.SuccessResponse(c, "Product deleted successfully", nil)
 }
@@ -341,21 +670,26 @@ func (h *ProductHandler) DeleteProduct(c *fiber.Ctx) error {
 // @Param category query string false "Category filter"
 // @Param min_price query number false "Minimum price"
 // @Param max_price query number false "Maximum price"
-// @Param sort query string false "Sort by: price_asc, price_desc, name_asc, name_desc, newest, oldest" default("newest")
+// @Param verified_seller query bool false "Only products from verified sellers"
+// @Param on_sale query bool false "Only products on sale"
+// @Param sort query string false "Sort by: relevance, price_asc, price_desc, name_asc, name_desc, newest, oldest" default("relevance")
 // @Param page query int false "Page number" default(1)
 // @Param limit query int false "Items per page" default(20)
 // @Success 200 {object} utils.Response{data=ProductListResponse}
 // @Router /products/search [get]
 func (h *ProductHandler) SearchProducts(c *fiber.Ctx) error {
 	query := c.Query("q")
-	if query == "" {
+	tsquery := searchQuery(query)
+	if tsquery == "" {
 		return utils.ValidationErrorResponse(c, "Search query is required")
 	}
 
 	category := c.Query("category")
 	minPrice := c.QueryFloat("min_price", 0)
 	maxPrice := c.QueryFloat("max_price", 0)
-	sort := c.Query("sort", "newest")
+	sort := c.Query("sort", "relevance")
+	verifiedSeller := c.QueryBool("verified_seller", false)
+	saleOnly := c.QueryBool("on_sale", false)
 	page := c.QueryInt("page", 1)
 	limit := c.QueryInt("limit", 20)
 
@@ -372,38 +706,37 @@ func (h *ProductHandler) SearchProducts(c *fiber.Ctx) error {
 	dbQuery := database.DB.Model(&models.Product{}).Where("is_active = ?", true)
 
 	// Text search
-	searchTerms := strings.Fields(strings.ToLower(query))
-	for _, term := range searchTerms {
-		dbQuery = dbQuery.Where("LOWER(name) LIKE ? OR LOWER(description) LIKE ? OR LOWER(category) LIKE ?", 
-			"%"+term+"%", "%"+term+"%", "%"+term+"%")
-	}
+	dbQuery = matchSearch(dbQuery, tsquery, query)
 
 	// Filters
 	if category != "" {
 		dbQuery = dbQuery.Where("category ILIKE ?", "%"+category+"%")
 	}
-	if minPrice > 0 {
-		dbQuery = dbQuery.Where("price >= ?", minPrice)
+	dbQuery = priceBetween(dbQuery, minPrice, maxPrice)
+	if saleOnly {
+		dbQuery = onSale(dbQuery)
 	}
-	if maxPrice > 0 {
-		dbQuery = dbQuery.Where("price <= ?", maxPrice)
+	if verifiedSeller {
+		dbQuery = dbQuery.Where("seller_id IN (?)", verifiedSellers())
 	}
 
 	// Sorting
-	var orderBy string
+	var orderBy interface{}
 	switch sort {
 	case "price_asc":
-		orderBy = "price ASC"
+		orderBy = priceOrder(false)
 	case "price_desc":
-		orderBy = "price DESC"
+		orderBy = priceOrder(true)
 	case "name_asc":
 		orderBy = "name ASC"
 	case "name_desc":
 		orderBy = "name DESC"
 	case "oldest":
 		orderBy = "created_at ASC"
-	default: // newest
+	case "newest":
 		orderBy = "created_at DESC"
+	default: // relevance
+		orderBy = searchRank(tsquery, query)
 	}
 
 	// Get total count
@@ -412,15 +745,34 @@ func (h *ProductHandler) SearchProducts(c *fiber.Ctx) error {
 
 	// Get products
 	var products []models.Product
-	if err := dbQuery.Preload("Seller").Order(orderBy).Offset(offset).Limit(limit).Find(&products).Error; err != nil {
+	if err := dbQuery.Preload("Seller.Profile").Order(orderBy).Offset(offset).Limit(limit).Find(&products).Error; err != nil {
 		return utils.InternalServerErrorResponse(c, "Failed to search products", err)
 	}
+	fillSales(products)
+
+	analytics.Track(analytics.EventImpression, productIDs(products)...)
+	if page == 1 {
+		// Later pages of the same search aren't new searches
+		analytics.TrackSearch(query, total)
+	}
 
 	response := ProductListResponse{
 		Products: products,
 		Total:    total,
 		Page:     page,
 		Limit:    limit,
+		Currency: utils.CurrencyHint(c, h.config),
+	}
+
+	// The first page leads with sponsored products matching the search
+	if page == 1 {
+		response.Sponsored = h.sponsoredProducts(models.PlacementSearch, func(db *gorm.DB) *gorm.DB {
+			db = matchSearch(db, tsquery, query)
+			if category != "" {
+				db = db.Where("products.category ILIKE ?", "%"+category+"%")
+			}
+			return db
+		})
 	}
 
 	return utils.SuccessResponse(c, "Products found successfully", response)
@@ -435,7 +787,7 @@ func (h *ProductHandler) GetCategories(c *fiber.Ctx) error {
 	var categories []string
 	
 	// Try to get from cache first
-	cacheKey := "product_categories"
+	cacheKey := redis.CategoriesKey()
 	if err := redis.Get(cacheKey, &categories); err != nil {
 		// Not in cache, get from database
 		if err := database.DB.Model(&models.Product{}).
@@ -445,9 +797,32 @@ func (h *ProductHandler) GetCategories(c *fiber.Ctx) error {
 			return utils.InternalServerErrorResponse(c, "Failed to get categories", err)
 		}
 
-		// Cache for 1 hour
-		redis.Set(cacheKey, categories, 3600)
+		redis.Set(cacheKey, categories)
 	}
 
 	return utils.SuccessResponse(c, "Categories retrieved successfully", categories)
 }
+
+// Helper functions
+
+// verifiedSellers selects the IDs of sellers who passed verification, for
+// use as a subquery
+func verifiedSellers() *gorm.DB {
+	return database.DB.Model(&models.User{}).Select("id").Where("seller_verified = ?", true)
+}
+
+func validateQuantityRules(product *models.Product) string {
+	if product.MinOrderQuantity < 0 || product.MaxOrderQuantity < 0 || product.PerCustomerLimit < 0 {
+		return "Quantity limits must be non-negative"
+	}
+	if product.MaxOrderQuantity > 0 && product.MinOrderQuantity > product.MaxOrderQuantity {
+		return "Minimum order quantity cannot exceed the maximum"
+	}
+	if product.PerCustomerLimit > 0 && product.MinOrderQuantity > product.PerCustomerLimit {
+		return "Minimum order quantity cannot exceed the per-customer limit"
+	}
+	if product.LowStockThreshold < 0 {
+		return "Low stock threshold must be non-negative"
+	}
+	return ""
+}
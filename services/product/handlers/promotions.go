@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"playful-marketplace/shared/analytics"
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/promotions"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const maxPromotionDays = 90 // Longest window a single promotion can run for
+
+var (
+	errPromotionNotFound = errors.New("promotion not found")
+	errNotPromotionOwner = errors.New("not the promotion's seller")
+)
+
+type CreatePromotionRequest struct {
+	ProductID   uuid.UUID                 `json:"product_id" validate:"required"`
+	Placement   models.PromotionPlacement `json:"placement" validate:"required"` // search or category
+	StartsAt    *time.Time                `json:"starts_at"`                     // Defaults to now
+	EndsAt      time.Time                 `json:"ends_at" validate:"required"`
+	BidPerClick float64                   `json:"bid_per_click" validate:"required"`
+	Budget      float64                   `json:"budget" validate:"required"` // Paid up front through the payment service
+}
+
+// PromotionResponse is a promotion with its delivery so far
+type PromotionResponse struct {
+	models.Promotion
+	ClickThroughRate float64 `json:"click_through_rate"`
+	Remaining        float64 `json:"remaining"` // Budget left to spend
+}
+
+// SponsoredProduct is a promoted product shown above listing results.
+// Clients report clicks with POST /promotions/{promotion_id}/click.
+type SponsoredProduct struct {
+	PromotionID uuid.UUID      `json:"promotion_id"`
+	Product     models.Product `json:"product"`
+}
+
+// @Summary Create a promotion
+// @Description Buy sponsored placement for one of your products in search results or its category page for a time window. Each click is charged at the bid against a prepaid budget; pay it with POST /payments/promotions/{id} to go live. Only sellers meeting the performance standards can promote.
+// @Tags promotions
+// @Security BearerAuth
+// @Param request body CreatePromotionRequest true "Promotion"
+// @Success 201 {object} utils.Response{data=PromotionResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /promotions [post]
+func (h *ProductHandler) CreatePromotion(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req CreatePromotionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	now := time.Now()
+	startsAt := now
+	if req.StartsAt != nil && req.StartsAt.After(now) {
+		startsAt = *req.StartsAt
+	}
+
+	var problems []utils.FieldError
+	if req.Placement != models.PlacementSearch && req.Placement != models.PlacementCategory {
+		problems = append(problems, utils.FieldError{Field: "placement", Message: "must be search or category"})
+	}
+	if !req.EndsAt.After(startsAt) {
+		problems = append(problems, utils.FieldError{Field: "ends_at", Message: "must be after the start"})
+	} else if req.EndsAt.Sub(startsAt) > maxPromotionDays*24*time.Hour {
+		problems = append(problems, utils.FieldError{Field: "ends_at", Message: fmt.Sprintf("promotions can run for at most %d days", maxPromotionDays)})
+	}
+	promotionConfig := h.config.Promotions
+	if req.BidPerClick < promotionConfig.MinBidPerClick {
+		problems = append(problems, utils.FieldError{Field: "bid_per_click", Message: fmt.Sprintf("must be at least %.2f", promotionConfig.MinBidPerClick)})
+	}
+	if req.Budget < promotionConfig.MinBudget {
+		problems = append(problems, utils.FieldError{Field: "budget", Message: fmt.Sprintf("must be at least %.2f", promotionConfig.MinBudget)})
+	} else if req.Budget < req.BidPerClick {
+		problems = append(problems, utils.FieldError{Field: "budget", Message: "must cover at least one click"})
+	}
+	if len(problems) > 0 {
+		return utils.FieldErrorsResponse(c, "Invalid promotion", problems...)
+	}
+
+	var product models.Product
+	if err := database.DB.Where("id = ? AND seller_id = ? AND is_active = ?", req.ProductID, userID, true).
+		First(&product).Error; err != nil {
+		return utils.NotFoundResponse(c, "Product not found")
+	}
+
+	if !analytics.PerksEligible(userID) {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "Sponsored placement is available to sellers meeting the performance standards", nil)
+	}
+
+	promotion := models.Promotion{
+		SellerID:    userID,
+		ProductID:   product.ID,
+		Placement:   req.Placement,
+		Status:      models.PromotionPendingPayment,
+		StartsAt:    startsAt,
+		EndsAt:      req.EndsAt,
+		BidPerClick: req.BidPerClick,
+		Budget:      req.Budget,
+	}
+	if err := database.DB.Create(&promotion).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create promotion", err)
+	}
+	promotion.Product = product
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Promotion created, pay the budget to go live",
+		Data:    promotionResponse(promotion),
+	})
+}
+
+// @Summary Get my promotions
+// @Description Get your promotions with their impressions, clicks and spend, newest first
+// @Tags promotions
+// @Security BearerAuth
+// @Param status query string false "Filter by status (pending_payment, active, paused, exhausted, cancelled)"
+// @Success 200 {object} utils.Response{data=[]PromotionResponse}
+// @Router /promotions [get]
+func (h *ProductHandler) GetPromotions(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	query := database.DB.Preload("Product").Where("seller_id = ?", userID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var found []models.Promotion
+	if err := query.Order("created_at DESC").Find(&found).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get promotions", err)
+	}
+
+	response := make([]PromotionResponse, len(found))
+	for i, promotion := range found {
+		response[i] = promotionResponse(promotion)
+	}
+
+	return utils.SuccessResponse(c, "Promotions retrieved successfully", response)
+}
+
+// @Summary Get a promotion
+// @Description Get one of your promotions with its impressions, clicks and spend
+// @Tags promotions
+// @Security BearerAuth
+// @Param id path string true "Promotion ID"
+// @Success 200 {object} utils.Response{data=PromotionResponse}
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /promotions/{id} [get]
+func (h *ProductHandler) GetPromotion(c *fiber.Ctx) error {
+	promotion, err := ownPromotion(c)
+	if err != nil {
+		return promotionErrorResponse(c, err)
+	}
+
+	return utils.SuccessResponse(c, "Promotion retrieved successfully", promotionResponse(*promotion))
+}
+
+// @Summary Pause a promotion
+// @Description Stop showing an active promotion. The remaining budget is kept for when it resumes.
+// @Tags promotions
+// @Security BearerAuth
+// @Param id path string true "Promotion ID"
+// @Success 200 {object} utils.Response{data=PromotionResponse}
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /promotions/{id}/pause [post]
+func (h *ProductHandler) PausePromotion(c *fiber.Ctx) error {
+	return h.setPromotionStatus(c, models.PromotionActive, models.PromotionPaused, "Promotion paused")
+}
+
+// @Summary Resume a promotion
+// @Description Show a paused promotion again until its window ends or its budget runs out
+// @Tags promotions
+// @Security BearerAuth
+// @Param id path string true "Promotion ID"
+// @Success 200 {object} utils.Response{data=PromotionResponse}
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /promotions/{id}/resume [post]
+func (h *ProductHandler) ResumePromotion(c *fiber.Ctx) error {
+	return h.setPromotionStatus(c, models.PromotionPaused, models.PromotionActive, "Promotion resumed")
+}
+
+// @Summary Cancel a promotion
+// @Description Cancel a promotion that hasn't been paid for yet
+// @Tags promotions
+// @Security BearerAuth
+// @Param id path string true "Promotion ID"
+// @Success 200 {object} utils.Response{data=PromotionResponse}
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /promotions/{id} [delete]
+func (h *ProductHandler) CancelPromotion(c *fiber.Ctx) error {
+	return h.setPromotionStatus(c, models.PromotionPendingPayment, models.PromotionCancelled, "Promotion cancelled")
+}
+
+// @Summary Record a sponsored click
+// @Description Record a click on a sponsored product, charging the bid against the promotion's budget. Repeat clicks by the same viewer are only charged once per half hour.
+// @Tags promotions
+// @Param id path string true "Promotion ID"
+// @Success 200 {object} utils.Response
+// @Failure 404 {object} utils.Problem
+// @Router /promotions/{id}/click [post]
+func (h *ProductHandler) TrackPromotionClick(c *fiber.Ctx) error {
+	promotionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid promotion ID")
+	}
+
+	var promotion models.Promotion
+	if err := database.DB.Where("id = ?", promotionID).First(&promotion).Error; err != nil {
+		return utils.NotFoundResponse(c, "Promotion not found")
+	}
+
+	viewer := c.IP()
+	if userID, ok := c.Locals("user_id").(uuid.UUID); ok {
+		viewer = userID.String()
+	}
+
+	// The product view itself is tracked when the product page loads
+	if _, err := promotions.Click(&promotion, viewer); err != nil {
+		log.Printf("Failed to charge click on promotion %s: %v", promotion.ID, err)
+	}
+
+	return utils.SuccessResponse(c, "Click recorded", nil)
+}
+
+// setPromotionStatus moves one of the seller's promotions from one status to another
+func (h *ProductHandler) setPromotionStatus(c *fiber.Ctx, from, to models.PromotionStatus, message string) error {
+	promotion, err := ownPromotion(c)
+	if err != nil {
+		return promotionErrorResponse(c, err)
+	}
+
+	result := database.DB.Model(promotion).Where("status = ?", from).Update("status", to)
+	if result.Error != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update promotion", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrorResponse(c, fiber.StatusConflict, fmt.Sprintf("Promotion is %s", promotion.Status), nil)
+	}
+
+	return utils.SuccessResponse(c, message, promotionResponse(*promotion))
+}
+
+// ownPromotion loads the promotion in the path, which the caller must have created
+func ownPromotion(c *fiber.Ctx) (*models.Promotion, error) {
+	promotionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, errPromotionNotFound
+	}
+
+	userID, _ := c.Locals("user_id").(uuid.UUID)
+
+	var promotion models.Promotion
+	if err := database.DB.Preload("Product").Where("id = ?", promotionID).First(&promotion).Error; err != nil {
+		return nil, errPromotionNotFound
+	}
+	if promotion.SellerID != userID {
+		return nil, errNotPromotionOwner
+	}
+	return &promotion, nil
+}
+
+func promotionErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errNotPromotionOwner):
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only manage your own promotions", nil)
+	default:
+		return utils.NotFoundResponse(c, "Promotion not found")
+	}
+}
+
+func promotionResponse(promotion models.Promotion) PromotionResponse {
+	response := PromotionResponse{
+		Promotion: promotion,
+		Remaining: promotion.Budget - promotion.Spent,
+	}
+	if promotion.Impressions > 0 {
+		response.ClickThroughRate = float64(promotion.Clicks) / float64(promotion.Impressions)
+	}
+	return response
+}
+
+// sponsoredProducts fills the sponsored slots above a listing
+func (h *ProductHandler) sponsoredProducts(placement models.PromotionPlacement, matching func(db *gorm.DB) *gorm.DB) []SponsoredProduct {
+	var sponsored []SponsoredProduct
+	for _, promotion := range promotions.Sponsored(placement, matching, h.config.Promotions.Slots) {
+		promotion.Product.FillSale()
+		sponsored = append(sponsored, SponsoredProduct{PromotionID: promotion.ID, Product: promotion.Product})
+	}
+	return sponsored
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"playful-marketplace/shared/regions"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary Get regions
+// @Description Get the regions the marketplace operates in, with their currency, tax rules, payment methods, phone prefixes and delivery zones
+// @Tags regions
+// @Success 200 {object} utils.Response{data=[]regions.Region}
+// @Router /regions [get]
+func (h *ProductHandler) GetRegions(c *fiber.Ctx) error {
+	return utils.SuccessResponse(c, "Regions retrieved successfully", regions.All())
+}
+
+// @Summary Get region
+// @Description Get one region's configuration
+// @Tags regions
+// @Param code path string true "Region code, e.g. ET"
+// @Success 200 {object} utils.Response{data=regions.Region}
+// @Failure 404 {object} utils.Problem
+// @Router /regions/{code} [get]
+func (h *ProductHandler) GetRegion(c *fiber.Ctx) error {
+	region, ok := regions.Get(c.Params("code"))
+	if !ok {
+		return utils.NotFoundResponse(c, "Region not found")
+	}
+
+	return utils.SuccessResponse(c, "Region retrieved successfully", region)
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/inventory"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+type RestockDemand struct {
+	ProductID   uuid.UUID `json:"product_id"`
+	Name        string    `json:"name"`
+	SKU         string    `json:"sku"`
+	Price       float64   `json:"price"`
+	Subscribers int64     `json:"subscribers"`
+}
+
+// @Summary Subscribe to restock alert
+// @Description Get notified when an out-of-stock product is available again
+// @Tags products
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Success 201 {object} utils.Response{data=models.RestockSubscription}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /products/{id}/restock-alerts [post]
+func (h *ProductHandler) SubscribeRestock(c *fiber.Ctx) error {
+	productID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid product ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var product models.Product
+	if err := database.DB.Where("id = ? AND is_active = ?", productID, true).First(&product).Error; err != nil {
+		return utils.NotFoundResponse(c, "Product not found")
+	}
+
+	if product.Stock > 0 {
+		return utils.ValidationErrorResponse(c, "Product is in stock")
+	}
+
+	// Re-subscribing after an earlier alert re-arms it
+	subscription := models.RestockSubscription{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		ProductID: productID,
+		UserID:    userID,
+	}
+	if err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "product_id"}, {Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"notified_at": nil, "deleted_at": nil}),
+	}).Create(&subscription).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to subscribe", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "You will be notified when this product is back in stock",
+		Data:    subscription,
+	})
+}
+
+// @Summary Unsubscribe from restock alert
+// @Description Stop waiting for a product to come back in stock
+// @Tags products
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Success 200 {object} utils.Response
+// @Router /products/{id}/restock-alerts [delete]
+func (h *ProductHandler) UnsubscribeRestock(c *fiber.Ctx) error {
+	productID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid product ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	if err := database.DB.Unscoped().Where("product_id = ? AND user_id = ?", productID, userID).
+		Delete(&models.RestockSubscription{}).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to unsubscribe", err)
+	}
+
+	return utils.SuccessResponse(c, "Restock alert removed", nil)
+}
+
+// @Summary Get restock demand
+// @Description List out-of-stock products by the number of buyers waiting for a restock (seller only, own products)
+// @Tags sellers
+// @Security BearerAuth
+// @Param id path string true "Seller ID"
+// @Success 200 {object} utils.Response{data=[]RestockDemand}
+// @Failure 403 {object} utils.Problem
+// @Router /sellers/{id}/restock-demand [get]
+func (h *ProductHandler) GetRestockDemand(c *fiber.Ctx) error {
+	sellerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid seller ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok || userID != sellerID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only view your own restock demand", nil)
+	}
+
+	var demand []RestockDemand
+	if err := database.DB.Model(&models.RestockSubscription{}).
+		Select("products.id AS product_id, products.name, products.sku, products.price, COUNT(*) AS subscribers").
+		Joins("JOIN products ON products.id = restock_subscriptions.product_id").
+		Where("products.seller_id = ? AND products.stock <= 0 AND products.deleted_at IS NULL", sellerID).
+		Where("restock_subscriptions.notified_at IS NULL").
+		Group("products.id, products.name, products.sku, products.price").
+		Order("subscribers DESC").
+		Scan(&demand).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get restock demand", err)
+	}
+
+	return utils.SuccessResponse(c, "Restock demand retrieved successfully", demand)
+}
+
+// Helper functions
+
+// onStockChanged fills waiting backorders from new stock first, then alerts
+// restock subscribers if any is left
+func (h *ProductHandler) onStockChanged(product models.Product, previousStock int) {
+	if product.Stock > previousStock {
+		remaining, err := inventory.AllocateBackorders(product.ID)
+		if err == nil {
+			product.Stock = remaining
+		}
+	}
+	h.notifyRestock(product, previousStock)
+}
+
+func validateAvailability(product *models.Product) string {
+	switch product.Availability {
+	case models.AvailabilityInStock:
+		return ""
+	case models.AvailabilityBackorder, models.AvailabilityPreorder:
+		if product.ExpectedAt == nil || !product.ExpectedAt.After(time.Now()) {
+			return "Backorder and pre-order products need an expected date in the future"
+		}
+		return ""
+	}
+	return "Availability must be 'in_stock', 'backorder' or 'preorder'"
+}
+
+// notifyRestock alerts waiting buyers when a product goes from out of stock to available
+func (h *ProductHandler) notifyRestock(product models.Product, previousStock int) {
+	if previousStock > 0 || product.Stock <= 0 || !product.IsActive {
+		return
+	}
+
+	var subscriptions []models.RestockSubscription
+	database.DB.Where("product_id = ? AND notified_at IS NULL", product.ID).Find(&subscriptions)
+
+	link := fmt.Sprintf("playful://products/%s", product.ID)
+	for _, subscription := range subscriptions {
+		if err := notifications.SendWithLink(subscription.UserID, models.NotificationRestock, "Back in stock",
+			fmt.Sprintf("%s is available again. Grab it before it sells out!", product.Name), link); err != nil {
+			continue
+		}
+		now := time.Now()
+		database.DB.Model(&subscription).Update("notified_at", now)
+	}
+}
+
+// notifyPriceDrop tells buyers with the product in their cart, on their
+// wishlist or on a restock list that it got cheaper. Price drops go out in the digest.
+func (h *ProductHandler) notifyPriceDrop(product models.Product, previousPrice float64) {
+	if !product.IsActive {
+		return
+	}
+
+	var userIDs []uuid.UUID
+	database.DB.Model(&models.CartItem{}).
+		Joins("JOIN carts ON carts.id = cart_items.cart_id").
+		Joins("JOIN users ON users.id = carts.user_id").
+		Where("cart_items.product_id = ? AND users.role <> ?", product.ID, models.RoleGuest).
+		Distinct().
+		Pluck("carts.user_id", &userIDs)
+
+	var subscribers []uuid.UUID
+	database.DB.Model(&models.RestockSubscription{}).Where("product_id = ?", product.ID).Pluck("user_id", &subscribers)
+
+	var savers []uuid.UUID
+	database.DB.Model(&models.WishlistItem{}).Where("product_id = ?", product.ID).Pluck("user_id", &savers)
+
+	userIDs = append(append(userIDs, subscribers...), savers...)
+	seen := make(map[uuid.UUID]bool, len(userIDs))
+	link := fmt.Sprintf("playful://products/%s", product.ID)
+	for _, userID := range userIDs {
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		notifications.SendWithLink(userID, models.NotificationPriceDrop, "Price drop",
+			fmt.Sprintf("%s dropped from %.2f to %.2f.", product.Name, previousPrice, product.Price), link)
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"strings"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/returns"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type UpdateReturnPolicyRequest struct {
+	WindowDays           *int     `json:"window_days"`
+	RestockingFeePercent *float64 `json:"restocking_fee_percent"`
+	FinalSaleCategories  []string `json:"final_sale_categories"`
+	Notes                string   `json:"notes"`
+}
+
+// @Summary Get seller return policy
+// @Description Get the return policy configured by a seller
+// @Tags sellers
+// @Param id path string true "Seller ID"
+// @Success 200 {object} utils.Response{data=models.ReturnPolicy}
+// @Failure 400 {object} utils.Problem
+// @Router /sellers/{id}/return-policy [get]
+func (h *ProductHandler) GetReturnPolicy(c *fiber.Ctx) error {
+	sellerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid seller ID")
+	}
+
+	policy := returns.PolicyForSeller(sellerID)
+
+	return utils.SuccessResponse(c, "Return policy retrieved successfully", policy)
+}
+
+// @Summary Update seller return policy
+// @Description Configure return window, restocking fee and final-sale categories (seller only, own policy)
+// @Tags sellers
+// @Security BearerAuth
+// @Param id path string true "Seller ID"
+// @Param request body UpdateReturnPolicyRequest true "Update return policy request"
+// @Success 200 {object} utils.Response{data=models.ReturnPolicy}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Router /sellers/{id}/return-policy [put]
+func (h *ProductHandler) UpdateReturnPolicy(c *fiber.Ctx) error {
+	sellerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid seller ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok || userID != sellerID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only update your own return policy", nil)
+	}
+
+	var req UpdateReturnPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	var policy models.ReturnPolicy
+	if err := database.DB.Where("seller_id = ?", sellerID).First(&policy).Error; err != nil {
+		policy = returns.PolicyForSeller(sellerID)
+		policy.ID = uuid.New()
+	}
+
+	// Update fields
+	if req.WindowDays != nil {
+		if *req.WindowDays < 0 || *req.WindowDays > 365 {
+			return utils.ValidationErrorResponse(c, "Return window must be between 0 and 365 days")
+		}
+		policy.WindowDays = *req.WindowDays
+	}
+	if req.RestockingFeePercent != nil {
+		if *req.RestockingFeePercent < 0 || *req.RestockingFeePercent > 100 {
+			return utils.ValidationErrorResponse(c, "Restocking fee must be between 0 and 100 percent")
+		}
+		policy.RestockingFeePercent = *req.RestockingFeePercent
+	}
+	if req.FinalSaleCategories != nil {
+		policy.FinalSaleCategories = strings.Join(req.FinalSaleCategories, ",")
+	}
+	if req.Notes != "" {
+		policy.Notes = req.Notes
+	}
+
+	if err := database.DB.Save(&policy).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update return policy", err)
+	}
+
+	return utils.SuccessResponse(c, "Return policy updated successfully", policy)
+}
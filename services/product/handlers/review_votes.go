@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"errors"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReviewVoteRequest struct {
+	Helpful *bool `json:"helpful" validate:"required"` // false votes not helpful
+}
+
+type ReviewVoteResponse struct {
+	ReviewID        uuid.UUID `json:"review_id"`
+	Helpful         *bool     `json:"helpful"` // The caller's vote, nil when they haven't voted
+	HelpfulCount    int       `json:"helpful_count"`
+	NotHelpfulCount int       `json:"not_helpful_count"`
+}
+
+// @Summary Vote on a review
+// @Description Mark a review helpful or not helpful. Voting again changes the vote; you can't vote on your own review.
+// @Tags reviews
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param reviewId path string true "Review ID"
+// @Param request body ReviewVoteRequest true "Vote"
+// @Success 200 {object} utils.Response{data=ReviewVoteResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /products/{id}/reviews/{reviewId}/vote [put]
+func (h *ProductHandler) VoteReview(c *fiber.Ctx) error {
+	review, userID, err := h.votableReview(c)
+	if err != nil {
+		return reviewErrorResponse(c, err)
+	}
+
+	if role, _ := c.Locals("user_role").(models.UserRole); role == models.RoleGuest {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "Create an account to vote on reviews", nil)
+	}
+	if review.BuyerID == userID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can't vote on your own review", nil)
+	}
+
+	var req ReviewVoteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+	if req.Helpful == nil {
+		return utils.ValidationErrorResponse(c, "Helpful is required")
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var vote models.ReviewVote
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("review_id = ? AND user_id = ?", review.ID, userID).First(&vote).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			vote = models.ReviewVote{
+				BaseModel: models.BaseModel{ID: uuid.New()},
+				ReviewID:  review.ID,
+				UserID:    userID,
+				Helpful:   *req.Helpful,
+			}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&vote)
+			// A concurrent vote from the same user already counted
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			return tx.Model(&models.Review{}).Where("id = ?", review.ID).
+				Update(voteColumn(vote.Helpful), gorm.Expr(voteColumn(vote.Helpful)+" + 1")).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		if vote.Helpful == *req.Helpful {
+			return nil
+		}
+		if err := tx.Model(&vote).Update("helpful", *req.Helpful).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Review{}).Where("id = ?", review.ID).Updates(map[string]interface{}{
+			voteColumn(*req.Helpful):  gorm.Expr(voteColumn(*req.Helpful) + " + 1"),
+			voteColumn(!*req.Helpful): gorm.Expr("GREATEST(" + voteColumn(!*req.Helpful) + " - 1, 0)"),
+		}).Error
+	})
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to save vote", err)
+	}
+
+	return h.reviewVoteResponse(c, "Vote saved successfully", review.ID, req.Helpful)
+}
+
+// @Summary Remove review vote
+// @Description Withdraw your helpful or not-helpful vote on a review
+// @Tags reviews
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param reviewId path string true "Review ID"
+// @Success 200 {object} utils.Response{data=ReviewVoteResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /products/{id}/reviews/{reviewId}/vote [delete]
+func (h *ProductHandler) RemoveReviewVote(c *fiber.Ctx) error {
+	review, userID, err := h.votableReview(c)
+	if err != nil {
+		return reviewErrorResponse(c, err)
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var vote models.ReviewVote
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("review_id = ? AND user_id = ?", review.ID, userID).First(&vote).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		if err := tx.Unscoped().Delete(&vote).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Review{}).Where("id = ?", review.ID).
+			Update(voteColumn(vote.Helpful), gorm.Expr("GREATEST("+voteColumn(vote.Helpful)+" - 1, 0)")).Error
+	})
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to remove vote", err)
+	}
+
+	return h.reviewVoteResponse(c, "Vote removed successfully", review.ID, nil)
+}
+
+// Helper functions
+
+// votableReview loads the review in the path along with the caller's ID
+func (h *ProductHandler) votableReview(c *fiber.Ctx) (*models.Review, uuid.UUID, error) {
+	productID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, uuid.Nil, errInvalidProductID
+	}
+	reviewID, err := uuid.Parse(c.Params("reviewId"))
+	if err != nil {
+		return nil, uuid.Nil, errInvalidReviewID
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return nil, uuid.Nil, errUserIDMissing
+	}
+
+	var review models.Review
+	if err := database.DB.Where("id = ? AND product_id = ?", reviewID, productID).First(&review).Error; err != nil {
+		return nil, uuid.Nil, errReviewNotFound
+	}
+	return &review, userID, nil
+}
+
+func (h *ProductHandler) reviewVoteResponse(c *fiber.Ctx, message string, reviewID uuid.UUID, helpful *bool) error {
+	var review models.Review
+	if err := database.DB.Select("id", "helpful_count", "not_helpful_count").First(&review, reviewID).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get vote counts", err)
+	}
+
+	response := ReviewVoteResponse{
+		ReviewID:        reviewID,
+		Helpful:         helpful,
+		HelpfulCount:    review.HelpfulCount,
+		NotHelpfulCount: review.NotHelpfulCount,
+	}
+
+	return utils.SuccessResponse(c, message, response)
+}
+
+func voteColumn(helpful bool) string {
+	if helpful {
+		return "helpful_count"
+	}
+	return "not_helpful_count"
+}
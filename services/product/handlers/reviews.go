@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/activity"
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/moderation"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	reviewReplyEditWindow = 48 * time.Hour
+	maxReviewReplyLength  = 1000
+)
+
+var (
+	errInvalidProductID = errors.New("invalid product ID")
+	errInvalidReviewID  = errors.New("invalid review ID")
+	errNotProductSeller = errors.New("not the product's seller")
+	errUserIDMissing    = errors.New("user ID not found")
+	errReviewNotFound   = errors.New("review not found")
+	errReplyRequired    = errors.New("reply is required")
+	errReplyTooLong     = errors.New("reply too long")
+	errReplyRejected    = errors.New("reply rejected by moderation")
+)
+
+type CreateReviewRequest struct {
+	Rating   int    `json:"rating" validate:"required,min=1,max=5"`
+	Comment  string `json:"comment"`
+	ImageURL string `json:"image_url"`
+}
+
+type ReviewReplyRequest struct {
+	Reply string `json:"reply" validate:"required"`
+}
+
+type ReviewListResponse struct {
+	Reviews       []models.Review `json:"reviews"`
+	Total         int64           `json:"total"`
+	AverageRating float64         `json:"average_rating"`
+	Page          int             `json:"page"`
+	Limit         int             `json:"limit"`
+}
+
+// @Summary Get product reviews
+// @Description Get paginated reviews for a product, with the seller's replies
+// @Tags reviews
+// @Param id path string true "Product ID"
+// @Param sort query string false "Sort order (newest, most_helpful, highest_rating, lowest_rating)" default(newest)
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} utils.Response{data=ReviewListResponse}
+// @Router /products/{id}/reviews [get]
+func (h *ProductHandler) GetProductReviews(c *fiber.Ctx) error {
+	productID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid product ID")
+	}
+
+	sort := c.Query("sort", "newest")
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+
+	if page < 1 {
+		page = 1
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	offset := (page - 1) * limit
+
+	// Sorting, newest breaks ties
+	var orderBy string
+	switch sort {
+	case "most_helpful":
+		orderBy = "helpful_count - not_helpful_count DESC, helpful_count DESC, created_at DESC"
+	case "highest_rating":
+		orderBy = "rating DESC, created_at DESC"
+	case "lowest_rating":
+		orderBy = "rating ASC, created_at DESC"
+	default: // newest
+		orderBy = "created_at DESC"
+	}
+
+	query := database.DB.Model(&models.Review{}).Where("product_id = ?", productID)
+
+	var total int64
+	query.Count(&total)
+
+	var averageRating float64
+	database.DB.Model(&models.Review{}).Where("product_id = ?", productID).
+		Select("COALESCE(AVG(rating), 0)").Scan(&averageRating)
+
+	var reviews []models.Review
+	if err := query.Preload("Buyer").Order(orderBy).Offset(offset).Limit(limit).Find(&reviews).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get reviews", err)
+	}
+
+	response := ReviewListResponse{
+		Reviews:       reviews,
+		Total:         total,
+		AverageRating: averageRating,
+		Page:          page,
+		Limit:         limit,
+	}
+
+	return utils.SuccessResponse(c, "Reviews retrieved successfully", response)
+}
+
+// @Summary Review a product
+// @Description Leave a review for a product from a delivered order (one review per product)
+// @Tags reviews
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param request body CreateReviewRequest true "Create review request"
+// @Success 201 {object} utils.Response{data=models.Review}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /products/{id}/reviews [post]
+func (h *ProductHandler) CreateReview(c *fiber.Ctx) error {
+	productID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid product ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req CreateReviewRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.Rating < 1 || req.Rating > 5 {
+		return utils.ValidationErrorResponse(c, "Rating must be between 1 and 5")
+	}
+
+	// Buyer must have received this product
+	var order models.Order
+	if err := database.DB.Joins("JOIN order_items ON order_items.order_id = orders.id").
+		Where("orders.buyer_id = ? AND order_items.product_id = ? AND orders.status = ?", userID, productID, models.OrderDelivered).
+		Order("orders.created_at DESC").
+		First(&order).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only review products from your delivered orders", nil)
+	}
+
+	var existing models.Review
+	if err := database.DB.Where("product_id = ? AND buyer_id = ?", productID, userID).First(&existing).Error; err == nil {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "You have already reviewed this product", nil)
+	}
+
+	// Screen review text
+	screening := moderation.Check(req.Comment)
+	if moderation.Blocked(screening) {
+		return contentRejectedResponse(c)
+	}
+
+	review := models.Review{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		ProductID: productID,
+		BuyerID:   userID,
+		OrderID:   order.ID,
+		Rating:    req.Rating,
+		Comment:   req.Comment,
+		ImageURL:  req.ImageURL,
+	}
+
+	if err := database.DB.Create(&review).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create review", err)
+	}
+
+	if screening.Flagged {
+		moderation.Flag(moderation.ContentReview, review.ID, userID, screening)
+	}
+
+	var product models.Product
+	database.DB.Select("id", "name").First(&product, productID)
+	activity.Record(userID, models.ActivityReviewWritten, "Reviewed "+product.Name, review.ID.String())
+	moderation.ScanImageAsync(moderation.ContentReviewImage, review.ID, userID, review.ImageURL, func() error {
+		return database.DB.Model(&models.Review{}).Where("id = ? AND image_url = ?", review.ID, review.ImageURL).
+			Update("image_url", "").Error
+	})
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Review created successfully",
+		Data:    review,
+	})
+}
+
+// @Summary Reply to a review
+// @Description Post the seller's public reply to a review of their product (seller only, one reply per review). The reviewer is notified.
+// @Tags reviews
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param reviewId path string true "Review ID"
+// @Param request body ReviewReplyRequest true "Reply"
+// @Success 201 {object} utils.Response{data=models.Review}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /products/{id}/reviews/{reviewId}/reply [post]
+func (h *ProductHandler) ReplyToReview(c *fiber.Ctx) error {
+	review, product, userID, err := h.sellerReview(c)
+	if err != nil {
+		return reviewErrorResponse(c, err)
+	}
+
+	if review.SellerRepliedAt != nil {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "You have already replied to this review", nil)
+	}
+
+	var req ReviewReplyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	screening, err := screenReviewReply(req.Reply)
+	if err != nil {
+		return reviewErrorResponse(c, err)
+	}
+
+	now := time.Now()
+	review.SellerReply = strings.TrimSpace(req.Reply)
+	review.SellerRepliedAt = &now
+	if err := database.DB.Model(review).Updates(map[string]interface{}{
+		"seller_reply":      review.SellerReply,
+		"seller_replied_at": now,
+	}).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to save reply", err)
+	}
+
+	if screening.Flagged {
+		moderation.Flag(moderation.ContentReviewReply, review.ID, userID, screening)
+	}
+
+	notifications.SendWithLink(review.BuyerID, models.NotificationReview, "The seller replied to your review",
+		fmt.Sprintf("The seller of %s replied to your review.", product.Name),
+		fmt.Sprintf("playful://products/%s/reviews", product.ID))
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Reply posted successfully",
+		Data:    review,
+	})
+}
+
+// @Summary Edit a review reply
+// @Description Edit the seller's reply to a review, allowed for 48 hours after posting it (seller only)
+// @Tags reviews
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param reviewId path string true "Review ID"
+// @Param request body ReviewReplyRequest true "Reply"
+// @Success 200 {object} utils.Response{data=models.Review}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /products/{id}/reviews/{reviewId}/reply [put]
+func (h *ProductHandler) UpdateReviewReply(c *fiber.Ctx) error {
+	review, _, userID, err := h.sellerReview(c)
+	if err != nil {
+		return reviewErrorResponse(c, err)
+	}
+
+	if review.SellerRepliedAt == nil {
+		return utils.NotFoundResponse(c, "You haven't replied to this review")
+	}
+	if time.Since(*review.SellerRepliedAt) > reviewReplyEditWindow {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "Replies can only be edited within 48 hours of posting", nil)
+	}
+
+	var req ReviewReplyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	screening, err := screenReviewReply(req.Reply)
+	if err != nil {
+		return reviewErrorResponse(c, err)
+	}
+
+	now := time.Now()
+	review.SellerReply = strings.TrimSpace(req.Reply)
+	review.SellerReplyEditedAt = &now
+	if err := database.DB.Model(review).Updates(map[string]interface{}{
+		"seller_reply":           review.SellerReply,
+		"seller_reply_edited_at": now,
+	}).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to save reply", err)
+	}
+
+	if screening.Flagged {
+		moderation.Flag(moderation.ContentReviewReply, review.ID, userID, screening)
+	}
+
+	return utils.SuccessResponse(c, "Reply updated successfully", review)
+}
+
+// Helper functions
+
+// sellerReview loads the review in the path, which must be of one of the
+// caller's products
+func (h *ProductHandler) sellerReview(c *fiber.Ctx) (*models.Review, *models.Product, uuid.UUID, error) {
+	productID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, nil, uuid.Nil, errInvalidProductID
+	}
+	reviewID, err := uuid.Parse(c.Params("reviewId"))
+	if err != nil {
+		return nil, nil, uuid.Nil, errInvalidReviewID
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return nil, nil, uuid.Nil, errUserIDMissing
+	}
+
+	var product models.Product
+	if err := database.DB.Select("id", "name", "seller_id").First(&product, productID).Error; err != nil {
+		return nil, nil, uuid.Nil, errReviewNotFound
+	}
+	if product.SellerID != userID {
+		return nil, nil, uuid.Nil, errNotProductSeller
+	}
+
+	var review models.Review
+	if err := database.DB.Where("id = ? AND product_id = ?", reviewID, productID).First(&review).Error; err != nil {
+		return nil, nil, uuid.Nil, errReviewNotFound
+	}
+	return &review, &product, userID, nil
+}
+
+func screenReviewReply(reply string) (moderation.Result, error) {
+	reply = strings.TrimSpace(reply)
+	if reply == "" {
+		return moderation.Result{}, errReplyRequired
+	}
+	if len(reply) > maxReviewReplyLength {
+		return moderation.Result{}, errReplyTooLong
+	}
+
+	screening := moderation.Check(reply)
+	if moderation.Blocked(screening) {
+		return screening, errReplyRejected
+	}
+	return screening, nil
+}
+
+func reviewErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errInvalidProductID):
+		return utils.ValidationErrorResponse(c, "Invalid product ID")
+	case errors.Is(err, errInvalidReviewID):
+		return utils.ValidationErrorResponse(c, "Invalid review ID")
+	case errors.Is(err, errUserIDMissing):
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	case errors.Is(err, errNotProductSeller):
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only reply to reviews of your own products", nil)
+	case errors.Is(err, errReviewNotFound):
+		return utils.NotFoundResponse(c, "Review not found")
+	case errors.Is(err, errReplyRequired):
+		return utils.ValidationErrorResponse(c, "Reply is required")
+	case errors.Is(err, errReplyTooLong):
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("Reply must be at most %d characters", maxReviewReplyLength))
+	case errors.Is(err, errReplyRejected):
+		return contentRejectedResponse(c)
+	}
+	return utils.InternalServerErrorResponse(c, "Failed to reply to review", err)
+}
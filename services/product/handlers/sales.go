@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// saleCondition matches products whose sale price is in effect at the time,
+// which is bound twice. Mirrors Product.SaleActive.
+const saleCondition = "products.sale_price IS NOT NULL AND products.sale_price < products.price" +
+	" AND (products.sale_starts_at IS NULL OR products.sale_starts_at <= ?)" +
+	" AND (products.sale_ends_at IS NULL OR products.sale_ends_at > ?)"
+
+// effectivePrice is the price charged at the time bound twice in saleCondition
+const effectivePrice = "CASE WHEN " + saleCondition + " THEN products.sale_price ELSE products.price END"
+
+type SetSaleRequest struct {
+	SalePrice float64    `json:"sale_price" validate:"required,min=0"`
+	StartsAt  *time.Time `json:"starts_at"` // Leave out to start now
+	EndsAt    *time.Time `json:"ends_at"`   // Leave out to run until the sale is ended
+}
+
+// @Summary Put a product on sale
+// @Description Sell a product at a lower price, now or between the given times. The sale price must be below the product's price and takes the same share off variant prices. Replaces any sale already set. (seller only, own products)
+// @Tags products
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param request body SetSaleRequest true "Sale"
+// @Success 200 {object} utils.Response{data=models.Product}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /products/{id}/sale [put]
+func (h *ProductHandler) SetSale(c *fiber.Ctx) error {
+	product, err := ownProduct(c)
+	if err != nil {
+		return variantErrorResponse(c, err)
+	}
+
+	var req SetSaleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.SalePrice <= 0 || req.SalePrice >= product.Price {
+		return utils.ValidationErrorResponse(c, "Sale price must be greater than 0 and below the product's price")
+	}
+	if req.EndsAt != nil {
+		if !req.EndsAt.After(time.Now()) {
+			return utils.ValidationErrorResponse(c, "Sale end must be in the future")
+		}
+		if req.StartsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+			return utils.ValidationErrorResponse(c, "Sale end must be after its start")
+		}
+	}
+
+	if err := database.DB.Model(product).Updates(map[string]interface{}{
+		"sale_price":     req.SalePrice,
+		"sale_starts_at": req.StartsAt,
+		"sale_ends_at":   req.EndsAt,
+	}).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to set sale", err)
+	}
+
+	redis.Delete(redis.ProductKey(product.ID))
+	product.FillSale()
+
+	return utils.SuccessResponse(c, "Sale set successfully", product)
+}
+
+// @Summary End a product's sale
+// @Description Remove a product's sale price, ending the sale now or cancelling one that hasn't started (seller only, own products)
+// @Tags products
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Success 200 {object} utils.Response{data=models.Product}
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /products/{id}/sale [delete]
+func (h *ProductHandler) EndSale(c *fiber.Ctx) error {
+	product, err := ownProduct(c)
+	if err != nil {
+		return variantErrorResponse(c, err)
+	}
+
+	if err := database.DB.Model(product).Updates(map[string]interface{}{
+		"sale_price":     nil,
+		"sale_starts_at": nil,
+		"sale_ends_at":   nil,
+	}).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to end sale", err)
+	}
+
+	redis.Delete(redis.ProductKey(product.ID))
+	product.FillSale()
+
+	return utils.SuccessResponse(c, "Sale ended successfully", product)
+}
+
+// onSale limits a products query to those on sale now
+func onSale(db *gorm.DB) *gorm.DB {
+	now := time.Now()
+	return db.Where(saleCondition, now, now)
+}
+
+// priceBetween limits a products query to those whose price charged now,
+// sale price included, is within the bounds. A bound of 0 isn't applied.
+func priceBetween(db *gorm.DB, minPrice, maxPrice float64) *gorm.DB {
+	now := time.Now()
+	if minPrice > 0 {
+		db = db.Where(effectivePrice+" >= ?", now, now, minPrice)
+	}
+	if maxPrice > 0 {
+		db = db.Where(effectivePrice+" <= ?", now, now, maxPrice)
+	}
+	return db
+}
+
+// priceOrder sorts products by the price charged now
+func priceOrder(desc bool) clause.OrderBy {
+	direction := " ASC"
+	if desc {
+		direction = " DESC"
+	}
+	now := time.Now()
+	return clause.OrderBy{Expression: clause.Expr{
+		SQL:                effectivePrice + direction,
+		Vars:               []interface{}{now, now},
+		WithoutParentheses: true,
+	}}
+}
+
+// fillSales marks which of the products are on sale now
+func fillSales(products []models.Product) {
+	for i := range products {
+		products[i].FillSale()
+	}
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TrendingSearch struct {
+	Query    string `json:"query"`
+	Searches int    `json:"searches"`
+}
+
+type ZeroResultSearch struct {
+	Query        string    `json:"query"`
+	Searches     int       `json:"searches"`
+	ZeroResults  int       `json:"zero_results"`
+	LastResults  int64     `json:"last_results"` // Non-zero once the catalog covers the query
+	LastSearched time.Time `json:"last_searched"`
+}
+
+type ZeroResultsResponse struct {
+	Searches []ZeroResultSearch `json:"searches"`
+	Days     int                `json:"days"`
+	Page     int                `json:"page"`
+	Limit    int                `json:"limit"`
+}
+
+// @Summary Get trending searches
+// @Description Get the most searched queries that found products over recent days
+// @Tags products
+// @Param days query int false "Days to look back, up to 30" default(7)
+// @Param limit query int false "Number of queries to return, up to 50" default(10)
+// @Success 200 {object} utils.Response{data=[]TrendingSearch}
+// @Router /products/search/trending [get]
+func (h *ProductHandler) GetTrendingSearches(c *fiber.Ctx) error {
+	days := c.QueryInt("days", 7)
+	limit := c.QueryInt("limit", 10)
+
+	if days < 1 || days > 30 {
+		days = 7
+	}
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+
+	var trending []TrendingSearch
+	cacheKey := redis.TrendingSearchesKey(days, limit)
+	if err := redis.Get(cacheKey, &trending); err == nil {
+		return utils.SuccessResponse(c, "Trending searches retrieved successfully", trending)
+	}
+
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -(days - 1))
+	if err := database.DB.Model(&models.SearchStat{}).
+		Select("query, SUM(searches) AS searches").
+		Where("date >= ?", since).
+		Group("query").
+		// Queries that never found anything belong in the zero-results report
+		Having("SUM(searches) > SUM(zero_results)").
+		Order("searches DESC").
+		Limit(limit).
+		Scan(&trending).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get trending searches", err)
+	}
+
+	redis.Set(cacheKey, trending)
+
+	return utils.SuccessResponse(c, "Trending searches retrieved successfully", trending)
+}
+
+// @Summary Get zero-result searches
+// @Description Get the queries that most often found no products, to spot unmet demand and catalog gaps (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param days query int false "Days to look back, up to 90" default(30)
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} utils.Response{data=ZeroResultsResponse}
+// @Router /admin/search/zero-results [get]
+func (h *ProductHandler) GetZeroResultSearches(c *fiber.Ctx) error {
+	days := c.QueryInt("days", 30)
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+
+	if days < 1 || days > 90 {
+		days = 30
+	}
+	if page < 1 {
+		page = 1
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	offset := (page - 1) * limit
+
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -(days - 1))
+	var searches []ZeroResultSearch
+	if err := database.DB.Model(&models.SearchStat{}).
+		Select("query, SUM(searches) AS searches, SUM(zero_results) AS zero_results, "+
+			"(ARRAY_AGG(last_results ORDER BY date DESC))[1] AS last_results, MAX(updated_at) AS last_searched").
+		Where("date >= ?", since).
+		Group("query").
+		Having("SUM(zero_results) > 0").
+		Order("zero_results DESC, last_searched DESC").
+		Offset(offset).
+		Limit(limit).
+		Scan(&searches).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get zero-result searches", err)
+	}
+
+	response := ZeroResultsResponse{
+		Searches: searches,
+		Days:     days,
+		Page:     page,
+		Limit:    limit,
+	}
+
+	return utils.SuccessResponse(c, "Zero-result searches retrieved successfully", response)
+}
+
+// searchQuery turns a search into a tsquery matching every term as a word
+// prefix. Terms keep only their letters and digits so input can't break the
+// tsquery syntax; it's empty when nothing searchable is left.
+func searchQuery(query string) string {
+	var lexemes []string
+	for _, term := range strings.Fields(query) {
+		term = strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, term)
+		if term != "" {
+			lexemes = append(lexemes, term+":*")
+		}
+	}
+	return strings.Join(lexemes, " & ")
+}
+
+// matchSearch limits db to products matching the search in the full-text
+// index, or with a name close enough to it to be a typo
+func matchSearch(db *gorm.DB, tsquery, query string) *gorm.DB {
+	return db.Where("products.search_vector @@ to_tsquery('simple', ?) OR ? <% products.name", tsquery, query)
+}
+
+// searchRank orders search results by relevance: full-text rank, which
+// weighs name over category over description, plus name similarity so typo
+// matches still rank by how close they are
+func searchRank(tsquery, query string) clause.OrderBy {
+	return clause.OrderBy{Expression: clause.Expr{
+		SQL:                "ts_rank(products.search_vector, to_tsquery('simple', ?)) + word_similarity(?, products.name) DESC, products.created_at DESC",
+		Vars:               []interface{}{tsquery, query},
+		WithoutParentheses: true,
+	}}
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"playful-marketplace/shared/analytics"
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/middleware"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type SellerPerformanceResponse struct {
+	Current   *models.SellerPerformance  `json:"current"` // Nil until the first nightly recalculation
+	History   []models.SellerPerformance `json:"history"` // Oldest first
+	Standards SellerStandards            `json:"standards"`
+}
+
+// SellerStandards are the thresholds sellers are measured against
+type SellerStandards struct {
+	WindowDays          int     `json:"window_days"`
+	MinOrders           int     `json:"min_orders"`
+	ShipWithinHours     int     `json:"ship_within_hours"`
+	MinOnTimeRate       float64 `json:"min_on_time_rate"`
+	MaxCancellationRate float64 `json:"max_cancellation_rate"`
+	MaxDisputeRate      float64 `json:"max_dispute_rate"`
+}
+
+// @Summary Get seller performance
+// @Description Get a seller's on-time shipping, cancellation and dispute rates, their standing against the marketplace standards and the nightly history (own account or admin)
+// @Tags analytics
+// @Security BearerAuth
+// @Param id path string true "Seller ID"
+// @Param days query int false "Days of history" default(30)
+// @Success 200 {object} utils.Response{data=SellerPerformanceResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Router /sellers/{id}/performance [get]
+func (h *ProductHandler) GetSellerPerformance(c *fiber.Ctx) error {
+	sellerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid seller ID")
+	}
+
+	userID, _ := c.Locals("user_id").(uuid.UUID)
+	userRole, _ := c.Locals("user_role").(models.UserRole)
+	if userID != sellerID && !middleware.HasPermission(userRole, middleware.PermReportsRead) {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only view your own performance", nil)
+	}
+
+	days := c.QueryInt("days", 30)
+	if days <= 0 || days > 365 {
+		days = 30
+	}
+
+	sla := h.config.SellerSLA
+	response := SellerPerformanceResponse{
+		History: []models.SellerPerformance{},
+		Standards: SellerStandards{
+			WindowDays:          sla.WindowDays,
+			MinOrders:           sla.MinOrders,
+			ShipWithinHours:     sla.ShipWithinHours,
+			MinOnTimeRate:       sla.MinOnTimeRate,
+			MaxCancellationRate: sla.MaxCancellationRate,
+			MaxDisputeRate:      sla.MaxDisputeRate,
+		},
+	}
+
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -days)
+	if err := database.DB.Where("seller_id = ? AND date >= ?", sellerID, since).
+		Order("date ASC").Find(&response.History).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get seller performance", err)
+	}
+	response.Current, _ = analytics.LatestSellerPerformance(sellerID)
+
+	return utils.SuccessResponse(c, "Seller performance retrieved successfully", response)
+}
+
+// @Summary Get seller performance overview
+// @Description Get every seller's latest performance snapshot, worst on-time shipping first (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param standing query string false "Filter by standing (new, good, warning)"
+// @Param limit query int false "Number of sellers to return" default(50)
+// @Param offset query int false "Number of sellers to skip" default(0)
+// @Success 200 {object} utils.Response{data=[]models.SellerPerformance}
+// @Router /admin/sellers/performance [get]
+func (h *ProductHandler) GetSellerPerformanceOverview(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 50)
+	offset := c.QueryInt("offset", 0)
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	latest := database.DB.Model(&models.SellerPerformance{}).Select("seller_id, MAX(date)").Group("seller_id")
+	query := database.DB.Where("(seller_id, date) IN (?)", latest)
+	if standing := c.Query("standing"); standing != "" {
+		query = query.Where("standing = ?", standing)
+	}
+
+	var snapshots []models.SellerPerformance
+	if err := query.Order("on_time_shipping_rate ASC, dispute_rate DESC").
+		Limit(limit).Offset(offset).Find(&snapshots).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get seller performance", err)
+	}
+
+	return utils.SuccessResponse(c, "Seller performance retrieved successfully", snapshots)
+}
+
+// RecalculateSellerPerformance refreshes every seller's metrics. Run nightly.
+func (h *ProductHandler) RecalculateSellerPerformance() {
+	if err := analytics.RecalculateSellerPerformance(h.config.SellerSLA); err != nil {
+		log.Printf("Failed to recalculate seller performance: %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/moderation"
+	"playful-marketplace/shared/returns"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const maxSocialLinks = 10
+
+var weekdays = map[string]bool{"mon": true, "tue": true, "wed": true, "thu": true, "fri": true, "sat": true, "sun": true}
+
+// Opening hours per day, e.g. 09:00-18:00
+var openingHoursPattern = regexp.MustCompile(`^([01]\d|2[0-3]):[0-5]\d-([01]\d|2[0-3]):[0-5]\d$`)
+
+type UpdateSellerProfileRequest struct {
+	StoreName    string            `json:"store_name"` // Required when creating the profile
+	Description  *string           `json:"description"`
+	LogoURL      *string           `json:"logo_url"`
+	OpeningHours map[string]string `json:"opening_hours"` // Replaces all days; mon to sun, "HH:MM-HH:MM" or "closed"
+	SocialLinks  map[string]string `json:"social_links"`  // Replaces all links; network name to https URL
+}
+
+// @Summary Get seller business profile
+// @Description Get the public business profile of a seller, with their return policy
+// @Tags sellers
+// @Param id path string true "Seller ID"
+// @Success 200 {object} utils.Response{data=models.SellerSummary}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /sellers/{id}/profile [get]
+func (h *ProductHandler) GetSellerProfile(c *fiber.Ctx) error {
+	sellerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid seller ID")
+	}
+
+	var seller models.SellerSummary
+	if err := database.DB.Preload("Profile").
+		Where("? = ANY(roles) AND is_active = ? AND deleted_at IS NULL", models.RoleSeller, true).
+		First(&seller, sellerID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Seller not found")
+	}
+
+	// Sellers who never set a return policy get the marketplace default
+	policy := returns.PolicyForSeller(sellerID)
+	seller.ReturnPolicy = &policy
+
+	return utils.SuccessResponse(c, "Seller profile retrieved successfully", seller)
+}
+
+// @Summary Update seller business profile
+// @Description Create or update the business details shown with a seller's listings (seller only, own profile). The return policy is managed separately.
+// @Tags sellers
+// @Security BearerAuth
+// @Param id path string true "Seller ID"
+// @Param request body UpdateSellerProfileRequest true "Business profile fields"
+// @Success 200 {object} utils.Response{data=models.SellerProfile}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Router /sellers/{id}/profile [put]
+func (h *ProductHandler) UpdateSellerProfile(c *fiber.Ctx) error {
+	sellerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid seller ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok || userID != sellerID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only update your own business profile", nil)
+	}
+
+	var req UpdateSellerProfileRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	var profile models.SellerProfile
+	err = database.DB.Where("seller_id = ?", sellerID).First(&profile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		profile = models.SellerProfile{
+			BaseModel: models.BaseModel{ID: uuid.New()},
+			SellerID:  sellerID,
+		}
+	} else if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get business profile", err)
+	}
+
+	// Update fields
+	if req.StoreName != "" {
+		profile.StoreName = req.StoreName
+	}
+	if profile.StoreName == "" {
+		return utils.ValidationErrorResponse(c, "Store name is required")
+	}
+	if req.Description != nil {
+		profile.Description = *req.Description
+	}
+	if req.LogoURL != nil {
+		if *req.LogoURL != "" && !isWebURL(*req.LogoURL) {
+			return utils.ValidationErrorResponse(c, "Logo URL must be an http or https URL")
+		}
+		profile.LogoURL = *req.LogoURL
+	}
+	if req.OpeningHours != nil {
+		for day, hours := range req.OpeningHours {
+			if !weekdays[day] {
+				return utils.ValidationErrorResponse(c, "Opening hours days must be mon, tue, wed, thu, fri, sat or sun")
+			}
+			if hours != "closed" && !openingHoursPattern.MatchString(hours) {
+				return utils.ValidationErrorResponse(c, fmt.Sprintf("Opening hours for %s must be HH:MM-HH:MM or closed", day))
+			}
+		}
+		profile.OpeningHours = req.OpeningHours
+	}
+	if req.SocialLinks != nil {
+		if len(req.SocialLinks) > maxSocialLinks {
+			return utils.ValidationErrorResponse(c, fmt.Sprintf("At most %d social links are allowed", maxSocialLinks))
+		}
+		for network, link := range req.SocialLinks {
+			if network == "" || !isWebURL(link) {
+				return utils.ValidationErrorResponse(c, "Social links must map a network name to an http or https URL")
+			}
+		}
+		profile.SocialLinks = req.SocialLinks
+	}
+
+	// Screen the text buyers see
+	if moderation.Blocked(moderation.Check(profile.StoreName, profile.Description)) {
+		return contentRejectedResponse(c)
+	}
+
+	if err := database.DB.Save(&profile).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update business profile", err)
+	}
+
+	return utils.SuccessResponse(c, "Business profile updated successfully", profile)
+}
+
+// @Summary Delete seller business profile
+// @Description Remove a seller's business details, so listings show only their name and level (seller only, own profile)
+// @Tags sellers
+// @Security BearerAuth
+// @Param id path string true "Seller ID"
+// @Success 200 {object} utils.Response
+// @Failure 403 {object} utils.Problem
+// @Router /sellers/{id}/profile [delete]
+func (h *ProductHandler) DeleteSellerProfile(c *fiber.Ctx) error {
+	sellerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid seller ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok || userID != sellerID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only delete your own business profile", nil)
+	}
+
+	// Hard delete so a new profile doesn't collide with the old one's seller_id
+	if err := database.DB.Unscoped().Where("seller_id = ?", sellerID).Delete(&models.SellerProfile{}).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to delete business profile", err)
+	}
+
+	return utils.SuccessResponse(c, "Business profile deleted successfully", nil)
+}
+
+// Helper functions
+
+func isWebURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
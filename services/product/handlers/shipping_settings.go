@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/regions"
+	"playful-marketplace/shared/shipping"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type UpdateShippingSettingsRequest struct {
+	HandlingDays          *int     `json:"handling_days"`
+	ShippingFee           *float64 `json:"shipping_fee"`
+	FreeShippingThreshold *float64 `json:"free_shipping_threshold"` // 0 removes the threshold
+	Zones                 []string `json:"zones"`                   // Delivery zone codes, empty to ship to every zone
+}
+
+// @Summary Get seller shipping settings
+// @Description Get the handling time, shipping fee and delivery zones configured by a seller
+// @Tags sellers
+// @Param id path string true "Seller ID"
+// @Success 200 {object} utils.Response{data=models.ShippingSettings}
+// @Failure 400 {object} utils.Problem
+// @Router /sellers/{id}/shipping [get]
+func (h *ProductHandler) GetShippingSettings(c *fiber.Ctx) error {
+	sellerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid seller ID")
+	}
+
+	settings := shipping.SettingsForSeller(sellerID)
+
+	return utils.SuccessResponse(c, "Shipping settings retrieved successfully", settings)
+}
+
+// @Summary Update seller shipping settings
+// @Description Configure handling time, shipping fee, free-shipping threshold and the delivery zones shipped to (seller only, own settings)
+// @Tags sellers
+// @Security BearerAuth
+// @Param id path string true "Seller ID"
+// @Param request body UpdateShippingSettingsRequest true "Update shipping settings request"
+// @Success 200 {object} utils.Response{data=models.ShippingSettings}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Router /sellers/{id}/shipping [put]
+func (h *ProductHandler) UpdateShippingSettings(c *fiber.Ctx) error {
+	sellerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid seller ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok || userID != sellerID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only update your own shipping settings", nil)
+	}
+
+	var req UpdateShippingSettingsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	var settings models.ShippingSettings
+	if err := database.DB.Where("seller_id = ?", sellerID).First(&settings).Error; err != nil {
+		settings = shipping.SettingsForSeller(sellerID)
+		settings.ID = uuid.New()
+	}
+
+	// Update fields
+	if req.HandlingDays != nil {
+		if *req.HandlingDays < 0 || *req.HandlingDays > 30 {
+			return utils.ValidationErrorResponse(c, "Handling time must be between 0 and 30 days")
+		}
+		settings.HandlingDays = *req.HandlingDays
+	}
+	if req.ShippingFee != nil {
+		if *req.ShippingFee < 0 {
+			return utils.ValidationErrorResponse(c, "Shipping fee can't be negative")
+		}
+		settings.ShippingFee = *req.ShippingFee
+	}
+	if req.FreeShippingThreshold != nil {
+		if *req.FreeShippingThreshold < 0 {
+			return utils.ValidationErrorResponse(c, "Free shipping threshold can't be negative")
+		}
+		settings.FreeShippingThreshold = *req.FreeShippingThreshold
+	}
+	if req.Zones != nil {
+		zones := make([]string, 0, len(req.Zones))
+		for _, code := range req.Zones {
+			code = strings.ToLower(strings.TrimSpace(code))
+			if !knownZone(code) {
+				return utils.ValidationErrorResponse(c, fmt.Sprintf("Unknown delivery zone %q", code))
+			}
+			zones = append(zones, code)
+		}
+		settings.Zones = strings.Join(zones, ",")
+	}
+
+	if err := database.DB.Save(&settings).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update shipping settings", err)
+	}
+
+	return utils.SuccessResponse(c, "Shipping settings updated successfully", settings)
+}
+
+// knownZone reports whether any region has a delivery zone with the code
+func knownZone(code string) bool {
+	for _, region := range regions.All() {
+		if _, ok := region.Zone(code); ok {
+			return true
+		}
+	}
+	return false
+}
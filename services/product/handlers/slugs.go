@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxSlugLength            = 80
+	maxMetaTitleLength       = 70  // Longer titles are cut off in search results
+	maxMetaDescriptionLength = 160 // Likewise for descriptions
+)
+
+// ProductSEO is what the storefront puts in a product page's head
+type ProductSEO struct {
+	Title         string `json:"title"`       // Meta title, or the product name
+	Description   string `json:"description"` // Meta description, or the start of the description
+	CanonicalPath string `json:"canonical_path,omitempty"`
+}
+
+// SlugRedirectResponse points an old slug at the product's current one
+type SlugRedirectResponse struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Slug      string    `json:"slug"`
+}
+
+// @Summary Get product by slug
+// @Description Get a product by its storefront URL slug. Slugs the product used before answer 301 with the current slug in the Location header and body.
+// @Tags products
+// @Param slug path string true "Product slug"
+// @Success 200 {object} utils.Response{data=ProductDetailResponse}
+// @Success 301 {object} utils.Response{data=SlugRedirectResponse}
+// @Failure 404 {object} utils.Problem
+// @Router /products/slug/{slug} [get]
+func (h *ProductHandler) GetProductBySlug(c *fiber.Ctx) error {
+	slug, err := url.PathUnescape(c.Params("slug"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid slug")
+	}
+	slug = strings.ToLower(slug)
+
+	var product models.Product
+	if err := database.DB.Select("id").Where("slug = ?", slug).Take(&product).Error; err == nil {
+		return h.productDetail(c, product.ID)
+	}
+
+	var redirect models.ProductSlugRedirect
+	if err := database.DB.Where("slug = ?", slug).First(&redirect).Error; err != nil {
+		return utils.NotFoundResponse(c, "Product not found")
+	}
+	if err := database.DB.Select("id", "slug").First(&product, redirect.ProductID).Error; err != nil || product.Slug == nil {
+		return utils.NotFoundResponse(c, "Product not found")
+	}
+
+	c.Set(fiber.HeaderLocation, "/api/v1/products/slug/"+url.PathEscape(*product.Slug))
+	return c.Status(fiber.StatusMovedPermanently).JSON(utils.Response{
+		Success: true,
+		Message: "Product has moved",
+		Data:    SlugRedirectResponse{ProductID: product.ID, Slug: *product.Slug},
+	})
+}
+
+// BackfillSlugs gives products without a slug one from their name
+func (h *ProductHandler) BackfillSlugs() {
+	assigned := 0
+	for {
+		var products []models.Product
+		if err := database.DB.Unscoped().Select("id", "name").Where("slug IS NULL").Limit(500).Find(&products).Error; err != nil {
+			log.Printf("Failed to find products without slugs: %v", err)
+			break
+		}
+
+		batch := 0
+		for _, product := range products {
+			slug := uniqueSlug(database.DB, slugify(product.Name), product.ID)
+			if err := database.DB.Unscoped().Model(&models.Product{}).Where("id = ? AND slug IS NULL", product.ID).
+				Update("slug", slug).Error; err != nil {
+				log.Printf("Failed to assign slug to product %s: %v", product.ID, err)
+				continue
+			}
+			batch++
+		}
+		assigned += batch
+
+		// Stop on the last batch, or when nothing could be assigned
+		if len(products) < 500 || batch == 0 {
+			break
+		}
+	}
+
+	if assigned > 0 {
+		log.Printf("Assigned slugs to %d product(s)", assigned)
+	}
+}
+
+// setSlug changes the product's slug inside tx, keeping the previous one as
+// a redirect. A slug the product used before is taken back from its redirects.
+func setSlug(tx *gorm.DB, product *models.Product, slug string) error {
+	if product.Slug != nil && *product.Slug == slug {
+		return nil
+	}
+
+	if err := tx.Unscoped().Where("slug = ? AND product_id = ?", slug, product.ID).
+		Delete(&models.ProductSlugRedirect{}).Error; err != nil {
+		return err
+	}
+	if product.Slug != nil {
+		redirect := models.ProductSlugRedirect{Slug: *product.Slug, ProductID: product.ID}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "slug"}},
+			DoUpdates: clause.AssignmentColumns([]string{"product_id", "updated_at"}),
+		}).Create(&redirect).Error; err != nil {
+			return err
+		}
+	}
+
+	product.Slug = &slug
+	return tx.Model(product).Update("slug", slug).Error
+}
+
+// slugTaken reports whether another product uses the slug, now or as a redirect
+func slugTaken(db *gorm.DB, slug string, productID uuid.UUID) bool {
+	var count int64
+	db.Unscoped().Model(&models.Product{}).Where("slug = ? AND id <> ?", slug, productID).Count(&count)
+	if count > 0 {
+		return true
+	}
+	db.Unscoped().Model(&models.ProductSlugRedirect{}).Where("slug = ? AND product_id <> ?", slug, productID).Count(&count)
+	return count > 0
+}
+
+// uniqueSlug returns base, or base with a number appended when it's taken
+func uniqueSlug(db *gorm.DB, base string, productID uuid.UUID) string {
+	if !slugTaken(db, base, productID) {
+		return base
+	}
+	for i := 2; i <= 20; i++ {
+		if candidate := fmt.Sprintf("%s-%d", base, i); !slugTaken(db, candidate, productID) {
+			return candidate
+		}
+	}
+	return fmt.Sprintf("%s-%s", base, strings.ReplaceAll(productID.String(), "-", "")[:8])
+}
+
+// slugify lowercases the name and joins its words with hyphens. Letters
+// outside the Latin alphabet are kept, so Amharic names get readable slugs.
+func slugify(name string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			hyphen = false
+			b.WriteRune(r)
+		} else {
+			hyphen = true
+		}
+		if b.Len() >= maxSlugLength {
+			break
+		}
+	}
+	if b.Len() == 0 {
+		return "product"
+	}
+	return b.String()
+}
+
+// validSlug reports whether a seller-chosen slug is already in slug form
+func validSlug(slug string) bool {
+	return slug != "" && len(slug) <= maxSlugLength && slugify(slug) == slug
+}
+
+// validateSEO checks seller-chosen slug and meta fields
+func validateSEO(slug *string, metaTitle, metaDescription string) string {
+	if slug != nil && !validSlug(*slug) {
+		return "Slug may only contain lowercase letters, digits and single hyphens between words"
+	}
+	if len([]rune(metaTitle)) > maxMetaTitleLength {
+		return fmt.Sprintf("Meta title must be at most %d characters", maxMetaTitleLength)
+	}
+	if len([]rune(metaDescription)) > maxMetaDescriptionLength {
+		return fmt.Sprintf("Meta description must be at most %d characters", maxMetaDescriptionLength)
+	}
+	return ""
+}
+
+func productSEO(product *models.Product) ProductSEO {
+	seo := ProductSEO{
+		Title:       product.MetaTitle,
+		Description: product.MetaDescription,
+	}
+	if seo.Title == "" {
+		seo.Title = product.Name
+	}
+	if seo.Description == "" {
+		seo.Description = truncateWords(product.Description, maxMetaDescriptionLength)
+	}
+	if product.Slug != nil {
+		seo.CanonicalPath = "/products/" + *product.Slug
+	}
+	return seo
+}
+
+// truncateWords shortens text to at most limit characters without cutting a word
+func truncateWords(text string, limit int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	cut := string(runes[:limit-1])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// InventoryAlert is one of a seller's products running low
+type InventoryAlert struct {
+	ProductID         uuid.UUID                  `json:"product_id"`
+	Name              string                     `json:"name"`
+	SKU               string                     `json:"sku"`
+	Stock             int                        `json:"stock"`
+	LowStockThreshold int                        `json:"low_stock_threshold"`
+	Level             models.StockLevel          `json:"level"`
+	Availability      models.ProductAvailability `json:"availability"`
+}
+
+// Products named in a low stock notification, the rest are counted
+const maxAlertNames = 3
+
+// @Summary Get inventory alerts
+// @Description List the seller's active products that are out of stock or at or below their low stock threshold, emptiest first (seller only, own products)
+// @Tags inventory
+// @Security BearerAuth
+// @Param id path string true "Seller ID"
+// @Param level query string false "Only 'low' or 'out_of_stock' items"
+// @Success 200 {object} utils.Response{data=[]InventoryAlert}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Router /sellers/{id}/inventory/alerts [get]
+func (h *ProductHandler) GetInventoryAlerts(c *fiber.Ctx) error {
+	sellerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid seller ID")
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok || userID != sellerID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only view your own inventory alerts", nil)
+	}
+
+	query := database.DB.Where("seller_id = ? AND is_active = ? AND stock <= low_stock_threshold", sellerID, true)
+	switch models.StockLevel(c.Query("level")) {
+	case "":
+	case models.StockLevelLow:
+		query = query.Where("stock > 0")
+	case models.StockLevelOut:
+		query = query.Where("stock <= 0")
+	default:
+		return utils.ValidationErrorResponse(c, "Level must be 'low' or 'out_of_stock'")
+	}
+
+	var products []models.Product
+	if err := query.Order("stock ASC, name ASC").Find(&products).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get inventory alerts", err)
+	}
+
+	alerts := make([]InventoryAlert, len(products))
+	for i, product := range products {
+		alerts[i] = InventoryAlert{
+			ProductID:         product.ID,
+			Name:              product.Name,
+			SKU:               product.SKU,
+			Stock:             product.Stock,
+			LowStockThreshold: product.LowStockThreshold,
+			Level:             stockLevel(&product),
+			Availability:      product.Availability,
+		}
+	}
+
+	return utils.SuccessResponse(c, "Inventory alerts retrieved successfully", alerts)
+}
+
+// CheckLowStock notifies sellers once for each product whose stock has
+// fallen to its low stock threshold, and again if it then sells out.
+// Products restocked above their threshold are re-armed. Backordered and
+// pre-ordered products sell without stock, so they aren't alerted on. Run
+// periodically by the scheduler.
+func (h *ProductHandler) CheckLowStock() {
+	if err := database.DB.Model(&models.Product{}).
+		Where("stock_alert <> '' AND stock > low_stock_threshold").
+		Update("stock_alert", "").Error; err != nil {
+		log.Printf("Failed to re-arm low stock alerts: %v", err)
+		return
+	}
+
+	var products []models.Product
+	if err := database.DB.
+		Where("is_active = ? AND availability = ? AND stock <= low_stock_threshold", true, models.AvailabilityInStock).
+		Where("stock_alert <> CASE WHEN stock <= 0 THEN ? ELSE ? END", models.StockLevelOut, models.StockLevelLow).
+		Order("stock ASC").
+		Find(&products).Error; err != nil {
+		log.Printf("Failed to check low stock: %v", err)
+		return
+	}
+
+	var sellers []uuid.UUID
+	bySeller := make(map[uuid.UUID][]models.Product)
+	for _, product := range products {
+		if _, ok := bySeller[product.SellerID]; !ok {
+			sellers = append(sellers, product.SellerID)
+		}
+		bySeller[product.SellerID] = append(bySeller[product.SellerID], product)
+	}
+
+	for _, sellerID := range sellers {
+		alerted := bySeller[sellerID]
+		title, message := lowStockMessage(alerted)
+		if err := notifications.SendWithLink(sellerID, models.NotificationInventory, title, message,
+			"playful://seller/inventory/alerts"); err != nil {
+			log.Printf("Failed to send low stock alert to seller %s: %v", sellerID, err)
+			continue
+		}
+
+		for _, product := range alerted {
+			database.DB.Model(&product).Update("stock_alert", stockLevel(&product))
+		}
+	}
+}
+
+func stockLevel(product *models.Product) models.StockLevel {
+	if product.Stock <= 0 {
+		return models.StockLevelOut
+	}
+	return models.StockLevelLow
+}
+
+// lowStockMessage summarizes a seller's newly low products, naming the
+// first few
+func lowStockMessage(products []models.Product) (string, string) {
+	if len(products) == 1 {
+		product := products[0]
+		if product.Stock <= 0 {
+			return "Out of stock", fmt.Sprintf("%s has sold out. Restock it to keep selling.", product.Name)
+		}
+		return "Running low on stock", fmt.Sprintf("%s is down to %d left.", product.Name, product.Stock)
+	}
+
+	var names []string
+	for i, product := range products {
+		if i == maxAlertNames {
+			names = append(names, fmt.Sprintf("%d more", len(products)-maxAlertNames))
+			break
+		}
+		names = append(names, product.Name)
+	}
+	return "Running low on stock", fmt.Sprintf("%d of your products are low or out of stock: %s.", len(products), strings.Join(names, ", "))
+}
@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/stores"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CreateStoreRequest struct {
+	Name          string `json:"name" validate:"required"`
+	Description   string `json:"description"`
+	LogoURL       string `json:"logo_url"`
+	PayoutMethod  string `json:"payout_method"` // bank or mobile_money
+	PayoutAccount string `json:"payout_account"`
+}
+
+type UpdateStoreRequest struct {
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	LogoURL       string `json:"logo_url"`
+	PayoutMethod  string `json:"payout_method"`
+	PayoutAccount string `json:"payout_account"`
+}
+
+type AddStoreMemberRequest struct {
+	Phone string                 `json:"phone" validate:"required"`
+	Role  models.StoreMemberRole `json:"role"` // Only manager can be granted
+}
+
+type StoreBalance struct {
+	StoreID   uuid.UUID `json:"store_id"`
+	Payouts   float64   `json:"payouts"`   // Released order funds after platform fees
+	Referrals float64   `json:"referrals"` // Seller referral commissions
+	Refunds   float64   `json:"refunds"`
+	Balance   float64   `json:"balance"`
+}
+
+// @Summary Create store
+// @Description Set up the business identity the current seller account sells through. Stores are created automatically when a seller application is approved; this covers sellers approved before stores existed.
+// @Tags stores
+// @Security BearerAuth
+// @Param request body CreateStoreRequest true "Create store request"
+// @Success 201 {object} utils.Response{data=models.Store}
+// @Failure 400 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /stores [post]
+func (h *ProductHandler) CreateStore(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req CreateStoreRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return utils.ValidationErrorResponse(c, "Store name is required")
+	}
+	if req.PayoutMethod != "" && !validPayoutMethod(req.PayoutMethod) {
+		return utils.ValidationErrorResponse(c, "Payout method must be bank or mobile_money")
+	}
+
+	var store *models.Store
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		store, err = stores.Create(tx, userID, req.Name, req.PayoutMethod, req.PayoutAccount)
+		if err != nil {
+			return err
+		}
+		if req.Description != "" || req.LogoURL != "" {
+			store.Description = req.Description
+			store.LogoURL = req.LogoURL
+			return tx.Save(store).Error
+		}
+		return nil
+	})
+	if errors.Is(err, stores.ErrStoreExists) {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "You already have a store", nil)
+	}
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create store", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Store created successfully",
+		Data:    store,
+	})
+}
+
+// @Summary Get store
+// @Description Get a store's public profile by ID or slug
+// @Tags stores
+// @Param id path string true "Store ID or slug"
+// @Success 200 {object} utils.Response{data=models.Store}
+// @Failure 404 {object} utils.Problem
+// @Router /stores/{id} [get]
+func (h *ProductHandler) GetStore(c *fiber.Ctx) error {
+	store, err := h.findStore(c.Params("id"))
+	if err != nil || !store.IsActive {
+		return utils.NotFoundResponse(c, "Store not found")
+	}
+
+	// Payout details are only shown to the store's own members
+	store.PayoutMethod = ""
+	store.PayoutAccount = ""
+
+	return utils.SuccessResponse(c, "Store retrieved successfully", store)
+}
+
+// @Summary Update store
+// @Description Update a store's profile and payout details (store owner or manager)
+// @Tags stores
+// @Security BearerAuth
+// @Param id path string true "Store ID or slug"
+// @Param request body UpdateStoreRequest true "Update store request"
+// @Success 200 {object} utils.Response{data=models.Store}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Router /stores/{id} [put]
+func (h *ProductHandler) UpdateStore(c *fiber.Ctx) error {
+	store, err := h.findStore(c.Params("id"))
+	if err != nil {
+		return utils.NotFoundResponse(c, "Store not found")
+	}
+
+	userID, _ := c.Locals("user_id").(uuid.UUID)
+	if _, ok := stores.MemberRole(store.ID, userID); !ok {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You are not a member of this store", nil)
+	}
+
+	var req UpdateStoreRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	// Update fields
+	if name := strings.TrimSpace(req.Name); name != "" {
+		store.Name = name
+	}
+	if req.Description != "" {
+		store.Description = req.Description
+	}
+	if req.LogoURL != "" {
+		store.LogoURL = req.LogoURL
+	}
+	if req.PayoutMethod != "" {
+		if !validPayoutMethod(req.PayoutMethod) {
+			return utils.ValidationErrorResponse(c, "Payout method must be bank or mobile_money")
+		}
+		store.PayoutMethod = req.PayoutMethod
+	}
+	if req.PayoutAccount != "" {
+		store.PayoutAccount = req.PayoutAccount
+	}
+
+	if err := database.DB.Save(store).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update store", err)
+	}
+
+	return utils.SuccessResponse(c, "Store updated successfully", store)
+}
+
+// @Summary Get store members
+// @Description List the users linked to a store (store members only)
+// @Tags stores
+// @Security BearerAuth
+// @Param id path string true "Store ID or slug"
+// @Success 200 {object} utils.Response{data=[]models.StoreMember}
+// @Failure 403 {object} utils.Problem
+// @Router /stores/{id}/members [get]
+func (h *ProductHandler) GetStoreMembers(c *fiber.Ctx) error {
+	store, err := h.findStore(c.Params("id"))
+	if err != nil {
+		return utils.NotFoundResponse(c, "Store not found")
+	}
+
+	userID, _ := c.Locals("user_id").(uuid.UUID)
+	if _, ok := stores.MemberRole(store.ID, userID); !ok {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You are not a member of this store", nil)
+	}
+
+	var members []models.StoreMember
+	if err := database.DB.Preload("User").Where("store_id = ?", store.ID).
+		Order("created_at ASC").Find(&members).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get store members", err)
+	}
+
+	return utils.SuccessResponse(c, "Store members retrieved successfully", members)
+}
+
+// @Summary Add store member
+// @Description Link an existing user to the store as a manager. Managers can act as the store with full access, including payouts (store owner only).
+// @Tags stores
+// @Security BearerAuth
+// @Param id path string true "Store ID or slug"
+// @Param request body AddStoreMemberRequest true "Add store member request"
+// @Success 201 {object} utils.Response{data=models.StoreMember}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /stores/{id}/members [post]
+func (h *ProductHandler) AddStoreMember(c *fiber.Ctx) error {
+	store, err := h.findStore(c.Params("id"))
+	if err != nil {
+		return utils.NotFoundResponse(c, "Store not found")
+	}
+
+	userID, _ := c.Locals("user_id").(uuid.UUID)
+	if role, _ := stores.MemberRole(store.ID, userID); role != models.StoreOwner {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "Only the store owner can add members", nil)
+	}
+
+	var req AddStoreMemberRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+	if req.Phone == "" {
+		return utils.ValidationErrorResponse(c, "Phone number is required")
+	}
+	if req.Role == "" {
+		req.Role = models.StoreManager
+	}
+	if req.Role != models.StoreManager {
+		return utils.ValidationErrorResponse(c, "Members can only be added as managers")
+	}
+
+	var user models.User
+	if err := database.DB.Where("phone = ? AND is_active = ?", req.Phone, true).First(&user).Error; err != nil {
+		return utils.NotFoundResponse(c, "No user found with that phone number")
+	}
+
+	var count int64
+	database.DB.Model(&models.StoreMember{}).Where("user_id = ?", user.ID).Count(&count)
+	if count > 0 {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "User already belongs to a store", nil)
+	}
+
+	member := models.StoreMember{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		StoreID:   store.ID,
+		UserID:    user.ID,
+		Role:      req.Role,
+	}
+	if err := database.DB.Create(&member).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to add store member", err)
+	}
+	member.User = user
+
+	notifications.Send(user.ID, models.NotificationAccount, "Added to a store",
+		fmt.Sprintf("You were added as a %s of %s.", member.Role, store.Name))
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Store member added successfully",
+		Data:    member,
+	})
+}
+
+// @Summary Remove store member
+// @Description Unlink a manager from the store (store owner only). The owner cannot be removed.
+// @Tags stores
+// @Security BearerAuth
+// @Param id path string true "Store ID or slug"
+// @Param user_id path string true "Member user ID"
+// @Success 200 {object} utils.Response
+// @Failure 403 {object} utils.Problem
+// @Router /stores/{id}/members/{user_id} [delete]
+func (h *ProductHandler) RemoveStoreMember(c *fiber.Ctx) error {
+	store, err := h.findStore(c.Params("id"))
+	if err != nil {
+		return utils.NotFoundResponse(c, "Store not found")
+	}
+
+	memberID, err := uuid.Parse(c.Params("user_id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid user ID")
+	}
+
+	userID, _ := c.Locals("user_id").(uuid.UUID)
+	if role, _ := stores.MemberRole(store.ID, userID); role != models.StoreOwner {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "Only the store owner can remove members", nil)
+	}
+	if memberID == store.OwnerID {
+		return utils.ValidationErrorResponse(c, "The store owner cannot be removed")
+	}
+
+	var member models.StoreMember
+	if err := database.DB.Where("store_id = ? AND user_id = ?", store.ID, memberID).First(&member).Error; err != nil {
+		return utils.NotFoundResponse(c, "Store member not found")
+	}
+	if err := database.DB.Delete(&member).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to remove store member", err)
+	}
+
+	// Revoke any store tokens the manager was issued
+	redis.DeleteStaffSessions(member.ID.String())
+
+	return utils.SuccessResponse(c, "Store member removed successfully", nil)
+}
+
+// @Summary Get store balance
+// @Description Get the store's earnings from the ledger: released payouts and referral commissions, less refunds (store owner or manager)
+// @Tags stores
+// @Security BearerAuth
+// @Param id path string true "Store ID or slug"
+// @Success 200 {object} utils.Response{data=StoreBalance}
+// @Failure 403 {object} utils.Problem
+// @Router /stores/{id}/balance [get]
+func (h *ProductHandler) GetStoreBalance(c *fiber.Ctx) error {
+	store, err := h.findStore(c.Params("id"))
+	if err != nil {
+		return utils.NotFoundResponse(c, "Store not found")
+	}
+
+	userID, _ := c.Locals("user_id").(uuid.UUID)
+	if _, ok := stores.MemberRole(store.ID, userID); !ok {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You are not a member of this store", nil)
+	}
+
+	var totals []struct {
+		Type  models.LedgerEntryType
+		Total float64
+	}
+	if err := database.DB.Model(&models.LedgerEntry{}).
+		Select("type, SUM(amount) AS total").
+		Where("store_id = ? AND type IN ?", store.ID,
+			[]models.LedgerEntryType{models.LedgerSellerPayout, models.LedgerReferral, models.LedgerRefund}).
+		Group("type").
+		Scan(&totals).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get store balance", err)
+	}
+
+	balance := StoreBalance{StoreID: store.ID}
+	for _, total := range totals {
+		switch total.Type {
+		case models.LedgerSellerPayout:
+			balance.Payouts = total.Total
+		case models.LedgerReferral:
+			balance.Referrals = total.Total
+		case models.LedgerRefund:
+			balance.Refunds = total.Total
+		}
+	}
+	balance.Balance = math.Round((balance.Payouts+balance.Referrals-balance.Refunds)*100) / 100
+
+	return utils.SuccessResponse(c, "Store balance retrieved successfully", balance)
+}
+
+// Helper functions
+
+func (h *ProductHandler) findStore(idOrSlug string) (*models.Store, error) {
+	var store models.Store
+	query := database.DB.Where("slug = ?", idOrSlug)
+	if id, err := uuid.Parse(idOrSlug); err == nil {
+		query = database.DB.Where("id = ?", id)
+	}
+	if err := query.First(&store).Error; err != nil {
+		return nil, err
+	}
+
+	var count int64
+	database.DB.Model(&models.User{}).Where("id = ? AND seller_verified = ?", store.OwnerID, true).Count(&count)
+	store.Verified = count > 0
+	return &store, nil
+}
+
+func validPayoutMethod(method string) bool {
+	return method == "bank" || method == "mobile_money"
+}
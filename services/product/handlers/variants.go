@@ -0,0 +1,400 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/inventory"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	maxVariants          = 100
+	maxVariantAttributes = 5
+)
+
+var (
+	errProductNotFound = errors.New("product not found")
+	errVariantNotFound = errors.New("variant not found")
+)
+
+type CreateVariantRequest struct {
+	SKU        string            `json:"sku"`
+	Attributes map[string]string `json:"attributes" validate:"required"` // e.g. {"size": "M", "color": "red"}
+	Price      *float64          `json:"price"`                          // Leave out to sell at the product price
+	Stock      int               `json:"stock" validate:"min=0"`
+}
+
+type UpdateVariantRequest struct {
+	SKU        *string           `json:"sku"`
+	Attributes map[string]string `json:"attributes"`
+	Price      *float64          `json:"price"`
+	ClearPrice bool              `json:"clear_price"` // Go back to the product price
+	Stock      *int              `json:"stock"`
+	IsActive   *bool             `json:"is_active"`
+}
+
+// @Summary Get product variants
+// @Description Get the variants a product can be bought in, such as sizes and colors, with their price and stock
+// @Tags products
+// @Param id path string true "Product ID"
+// @Success 200 {object} utils.Response{data=[]models.ProductVariant}
+// @Failure 404 {object} utils.Problem
+// @Router /products/{id}/variants [get]
+func (h *ProductHandler) GetVariants(c *fiber.Ctx) error {
+	productID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid product ID")
+	}
+
+	var product models.Product
+	if err := database.DB.Select("id").Where("is_active = ?", true).First(&product, productID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Product not found")
+	}
+
+	var variants []models.ProductVariant
+	if err := database.DB.Where("product_id = ? AND is_active = ?", productID, true).
+		Order("created_at ASC").Find(&variants).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get variants", err)
+	}
+
+	return utils.SuccessResponse(c, "Variants retrieved successfully", variants)
+}
+
+// @Summary Create product variant
+// @Description Add a variant to a product. Once a product has variants buyers choose one when ordering, and the product's stock is the total of its variants' (seller only, own products).
+// @Tags products
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param request body CreateVariantRequest true "Variant"
+// @Success 201 {object} utils.Response{data=models.ProductVariant}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /products/{id}/variants [post]
+func (h *ProductHandler) CreateVariant(c *fiber.Ctx) error {
+	product, err := ownProduct(c)
+	if err != nil {
+		return variantErrorResponse(c, err)
+	}
+
+	var req CreateVariantRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	variant := models.ProductVariant{
+		BaseModel:  models.BaseModel{ID: uuid.New()},
+		ProductID:  product.ID,
+		SKU:        strings.TrimSpace(req.SKU),
+		Attributes: normalizeVariantAttributes(req.Attributes),
+		Price:      req.Price,
+		Stock:      req.Stock,
+		IsActive:   true,
+	}
+
+	var count int64
+	database.DB.Model(&models.ProductVariant{}).Where("product_id = ?", product.ID).Count(&count)
+	if count >= maxVariants {
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("A product can have at most %d variants", maxVariants))
+	}
+
+	if problem := validateVariant(&variant); problem != "" {
+		return utils.ValidationErrorResponse(c, problem)
+	}
+	if conflict := h.variantConflict(product, &variant); conflict != "" {
+		return utils.ErrorResponse(c, fiber.StatusConflict, conflict, nil)
+	}
+
+	previousStock := product.Stock
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&variant).Error; err != nil {
+			return err
+		}
+		return inventory.SyncVariantStock(tx, product.ID)
+	}); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to create variant", err)
+	}
+
+	redis.Delete(redis.ProductKey(product.ID))
+	go h.onVariantStockChanged(variant, 0, previousStock)
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Variant created successfully",
+		Data:    variant,
+	})
+}
+
+// @Summary Update product variant
+// @Description Change a variant's SKU, attributes, price or stock, or deactivate it (seller only, own products)
+// @Tags products
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param variantId path string true "Variant ID"
+// @Param request body UpdateVariantRequest true "Variant changes"
+// @Success 200 {object} utils.Response{data=models.ProductVariant}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /products/{id}/variants/{variantId} [put]
+func (h *ProductHandler) UpdateVariant(c *fiber.Ctx) error {
+	product, err := ownProduct(c)
+	if err != nil {
+		return variantErrorResponse(c, err)
+	}
+
+	variant, err := productVariant(c, product.ID)
+	if err != nil {
+		return variantErrorResponse(c, err)
+	}
+
+	var req UpdateVariantRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	previousStock := variant.Stock
+	if !variant.IsActive {
+		previousStock = 0
+	}
+	if req.SKU != nil {
+		variant.SKU = strings.TrimSpace(*req.SKU)
+	}
+	if req.Attributes != nil {
+		variant.Attributes = normalizeVariantAttributes(req.Attributes)
+	}
+	if req.ClearPrice || req.Price != nil || req.Stock != nil {
+		now := time.Now()
+		variant.InventoryEditedAt = &now
+	}
+	if req.ClearPrice {
+		variant.Price = nil
+	} else if req.Price != nil {
+		variant.Price = req.Price
+	}
+	if req.Stock != nil {
+		variant.Stock = *req.Stock
+	}
+	if req.IsActive != nil {
+		variant.IsActive = *req.IsActive
+	}
+
+	if problem := validateVariant(variant); problem != "" {
+		return utils.ValidationErrorResponse(c, problem)
+	}
+	if conflict := h.variantConflict(product, variant); conflict != "" {
+		return utils.ErrorResponse(c, fiber.StatusConflict, conflict, nil)
+	}
+
+	previousProductStock := product.Stock
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(variant).Error; err != nil {
+			return err
+		}
+		return inventory.SyncVariantStock(tx, product.ID)
+	}); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update variant", err)
+	}
+
+	redis.Delete(redis.ProductKey(product.ID))
+	if variant.IsActive && variant.Stock > previousStock {
+		go h.onVariantStockChanged(*variant, previousStock, previousProductStock)
+	}
+
+	return utils.SuccessResponse(c, "Variant updated successfully", variant)
+}
+
+// @Summary Delete product variant
+// @Description Remove a variant from sale. Orders that bought it keep referring to it (seller only, own products).
+// @Tags products
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param variantId path string true "Variant ID"
+// @Success 200 {object} utils.Response
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /products/{id}/variants/{variantId} [delete]
+func (h *ProductHandler) DeleteVariant(c *fiber.Ctx) error {
+	product, err := ownProduct(c)
+	if err != nil {
+		return variantErrorResponse(c, err)
+	}
+
+	variant, err := productVariant(c, product.ID)
+	if err != nil {
+		return variantErrorResponse(c, err)
+	}
+
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(variant).Error; err != nil {
+			return err
+		}
+		if err := inventory.SyncVariantStock(tx, product.ID); err != nil {
+			return err
+		}
+		// Stock the product tracked through its last variant isn't sellable without one
+		var remaining int64
+		if err := tx.Model(&models.ProductVariant{}).Where("product_id = ?", product.ID).Count(&remaining).Error; err != nil {
+			return err
+		}
+		if remaining == 0 {
+			return tx.Model(product).Update("stock", 0).Error
+		}
+		return nil
+	}); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to delete variant", err)
+	}
+
+	redis.Delete(redis.ProductKey(product.ID))
+
+	return utils.SuccessResponse(c, "Variant deleted successfully", nil)
+}
+
+// Helper functions
+
+// ownProduct loads the product in the path, which must be the caller's
+func ownProduct(c *fiber.Ctx) (*models.Product, error) {
+	productID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, errInvalidProductID
+	}
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return nil, errUserIDMissing
+	}
+
+	var product models.Product
+	if err := database.DB.First(&product, productID).Error; err != nil {
+		return nil, errProductNotFound
+	}
+	if product.SellerID != userID {
+		return nil, errNotProductSeller
+	}
+	return &product, nil
+}
+
+func productVariant(c *fiber.Ctx, productID uuid.UUID) (*models.ProductVariant, error) {
+	variantID, err := uuid.Parse(c.Params("variantId"))
+	if err != nil {
+		return nil, errVariantNotFound
+	}
+
+	var variant models.ProductVariant
+	if err := database.DB.Where("id = ? AND product_id = ?", variantID, productID).First(&variant).Error; err != nil {
+		return nil, errVariantNotFound
+	}
+	return &variant, nil
+}
+
+func hasVariants(productID uuid.UUID) bool {
+	var count int64
+	database.DB.Model(&models.ProductVariant{}).Where("product_id = ?", productID).Count(&count)
+	return count > 0
+}
+
+// normalizeVariantAttributes lowercases the attribute names and trims the
+// values, so "Size" and "size " are the same attribute
+func variantErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errInvalidProductID):
+		return utils.ValidationErrorResponse(c, "Invalid product ID")
+	case errors.Is(err, errUserIDMissing):
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	case errors.Is(err, errProductNotFound):
+		return utils.NotFoundResponse(c, "Product not found")
+	case errors.Is(err, errNotProductSeller):
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only update your own products", nil)
+	case errors.Is(err, errVariantNotFound):
+		return utils.NotFoundResponse(c, "Variant not found")
+	}
+	return utils.InternalServerErrorResponse(c, "Failed to load variant", err)
+}
+
+func normalizeVariantAttributes(attributes map[string]string) map[string]string {
+	normalized := make(map[string]string, len(attributes))
+	for key, value := range attributes {
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if key != "" && value != "" {
+			normalized[key] = value
+		}
+	}
+	return normalized
+}
+
+func validateVariant(variant *models.ProductVariant) string {
+	if len(variant.Attributes) == 0 {
+		return "A variant needs at least one attribute, such as size or color"
+	}
+	if len(variant.Attributes) > maxVariantAttributes {
+		return fmt.Sprintf("A variant can have at most %d attributes", maxVariantAttributes)
+	}
+	if variant.Price != nil && *variant.Price <= 0 {
+		return "Variant price must be greater than zero"
+	}
+	if variant.Stock < 0 {
+		return "Stock cannot be negative"
+	}
+	return ""
+}
+
+// variantConflict reports why the variant clashes with another of the
+// product's variants or the seller's SKUs, or "" when it doesn't
+func (h *ProductHandler) variantConflict(product *models.Product, variant *models.ProductVariant) string {
+	var siblings []models.ProductVariant
+	database.DB.Where("product_id = ? AND id <> ?", product.ID, variant.ID).Find(&siblings)
+
+	key := variantKey(variant.Attributes)
+	for _, sibling := range siblings {
+		if variantKey(sibling.Attributes) == key {
+			return "The product already has a variant with these attributes"
+		}
+	}
+
+	if variant.SKU != "" && h.skuTaken(product.SellerID, variant.SKU, variant.ID) {
+		return "A product with this SKU already exists"
+	}
+	return ""
+}
+
+// variantKey identifies a combination of attribute values regardless of
+// order or case
+func variantKey(attributes map[string]string) string {
+	pairs := make([]string, 0, len(attributes))
+	for key, value := range attributes {
+		pairs = append(pairs, key+"="+strings.ToLower(value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// onVariantStockChanged hands arrived stock to buyers waiting on the variant
+// and alerts restock subscribers when the product comes back in stock
+func (h *ProductHandler) onVariantStockChanged(variant models.ProductVariant, previousStock, previousProductStock int) {
+	if variant.Stock > previousStock {
+		if _, err := inventory.AllocateVariantBackorders(variant.ID); err != nil {
+			log.Printf("Failed to allocate backorders for variant %s: %v", variant.ID, err)
+		}
+	}
+
+	var product models.Product
+	if err := database.DB.First(&product, variant.ProductID).Error; err != nil {
+		return
+	}
+	h.notifyRestock(product, previousProductStock)
+}
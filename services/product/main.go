@@ -2,13 +2,18 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"playful-marketplace/services/product/handlers"
 	"playful-marketplace/services/product/routes"
 	"playful-marketplace/shared/config"
 	"playful-marketplace/shared/database"
 	"playful-marketplace/shared/middleware"
+	"playful-marketplace/shared/moderation"
 	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/regions"
+	"playful-marketplace/shared/scheduler"
+	"playful-marketplace/shared/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/recover"
@@ -33,30 +38,40 @@ func main() {
 		log.Fatal("Failed to connect to Redis:", err)
 	}
 
+	// Configure content moderation
+	moderation.Init(cfg)
+
+	// Load region configuration
+	regions.Init(cfg)
+
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
-		AppName: "Playful Marketplace Product Service",
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			code := fiber.StatusInternalServerError
-			if e, ok := err.(*fiber.Error); ok {
-				code = e.Code
-			}
-			return c.Status(code).JSON(fiber.Map{
-				"success": false,
-				"message": "Internal Server Error",
-				"error":   err.Error(),
-			})
-		},
+		AppName:      "Playful Marketplace Product Service",
+		ErrorHandler: utils.FiberErrorHandler,
 	})
 
 	// Middleware
+	app.Use(middleware.TraceMiddleware())
 	app.Use(recover.New())
 	app.Use(middleware.CORSMiddleware())
 	app.Use(middleware.LoggingMiddleware())
+	app.Use(middleware.FaultMiddleware(cfg))
+	app.Use(middleware.MaintenanceMiddleware(cfg, "product"))
+	app.Use(middleware.RateLimitMiddleware(cfg))
+	app.Use(middleware.UsageMiddleware())
 
 	// Initialize handlers
 	productHandler := handlers.NewProductHandler(cfg)
 
+	// Background jobs
+	scheduler.Every("duplicate-listings", time.Hour, productHandler.DetectDuplicateListings)
+	scheduler.Every("seller-performance", 24*time.Hour, productHandler.RecalculateSellerPerformance)
+	scheduler.Every("product-slugs", time.Hour, productHandler.BackfillSlugs)
+	scheduler.Every("scheduled-prices", time.Minute, productHandler.ApplyScheduledPriceChanges)
+	scheduler.Every("low-stock-alerts", 5*time.Minute, productHandler.CheckLowStock)
+	scheduler.Every("product-feeds", cfg.Feeds.UpdateInterval, productHandler.UpdateFeeds)
+	scheduler.Every("product-feeds-rebuild", 24*time.Hour, productHandler.RebuildFeeds)
+
 	// Health check
 	app.Get("/health", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{
@@ -15,15 +15,128 @@ func SetupProductRoutes(api fiber.Router, productHandler *handlers.ProductHandle
 	// Public routes
 	products.Get("/", productHandler.GetProducts)
 	products.Get("/search", productHandler.SearchProducts)
+	products.Get("/search/trending", productHandler.GetTrendingSearches)
 	products.Get("/categories", productHandler.GetCategories)
+	products.Get("/categories/:category/requirements", productHandler.GetCategoryRequirements)
+	products.Get("/slug/:slug", productHandler.GetProductBySlug)
 	products.Get("/:id", productHandler.GetProduct)
+	products.Get("/:id/reviews", productHandler.GetProductReviews)
+	products.Get("/:id/variants", productHandler.GetVariants)
+	products.Get("/:id/images", productHandler.GetProductImages)
+	products.Get("/:id/price-history", productHandler.GetPriceHistory)
 
 	// Protected routes
-	protected := products.Group("", middleware.AuthMiddleware(cfg))
+	protected := products.Group("", middleware.AuthOrAPIKeyMiddleware(cfg, "products"))
+	protected.Post("/:id/reviews", middleware.RoleMiddleware(models.RoleBuyer), productHandler.CreateReview)
+	protected.Put("/:id/reviews/:reviewId/vote", productHandler.VoteReview)
+	protected.Delete("/:id/reviews/:reviewId/vote", productHandler.RemoveReviewVote)
+	protected.Post("/:id/events", productHandler.TrackProductEvent)
+	protected.Post("/:id/restock-alerts", productHandler.SubscribeRestock)
+	protected.Delete("/:id/restock-alerts", productHandler.UnsubscribeRestock)
+	protected.Post("/:id/offers", middleware.RoleMiddleware(models.RoleBuyer), productHandler.MakeOffer)
 	
 	// Seller-only routes
 	sellerOnly := protected.Group("", middleware.RoleMiddleware(models.RoleSeller))
 	sellerOnly.Post("/", productHandler.CreateProduct)
 	sellerOnly.Put("/:id", productHandler.UpdateProduct)
 	sellerOnly.Delete("/:id", productHandler.DeleteProduct)
+	sellerOnly.Put("/:id/price-tiers", productHandler.UpdatePriceTiers)
+	sellerOnly.Get("/:id/price-schedule", productHandler.GetScheduledPriceChanges)
+	sellerOnly.Post("/:id/price-schedule", productHandler.SchedulePriceChange)
+	sellerOnly.Delete("/:id/price-schedule/:changeId", productHandler.CancelScheduledPriceChange)
+	sellerOnly.Put("/:id/sale", productHandler.SetSale)
+	sellerOnly.Delete("/:id/sale", productHandler.EndSale)
+	sellerOnly.Post("/:id/variants", productHandler.CreateVariant)
+	sellerOnly.Put("/:id/variants/:variantId", productHandler.UpdateVariant)
+	sellerOnly.Delete("/:id/variants/:variantId", productHandler.DeleteVariant)
+	sellerOnly.Post("/:id/images", productHandler.UploadProductImage)
+	sellerOnly.Put("/:id/images/order", productHandler.ReorderProductImages)
+	sellerOnly.Put("/:id/images/:imageId", productHandler.UpdateProductImage)
+	sellerOnly.Delete("/:id/images/:imageId", productHandler.DeleteProductImage)
+	sellerOnly.Post("/:id/reviews/:reviewId/reply", productHandler.ReplyToReview)
+	sellerOnly.Put("/:id/reviews/:reviewId/reply", productHandler.UpdateReviewReply)
+
+	// Regions the marketplace operates in
+	api.Get("/regions", productHandler.GetRegions)
+	api.Get("/regions/:code", productHandler.GetRegion)
+
+	// Seller return policies
+	sellers := api.Group("/sellers")
+	sellerAuth := []fiber.Handler{middleware.AuthOrAPIKeyMiddleware(cfg, "products"), middleware.RoleMiddleware(models.RoleSeller)}
+	sellers.Get("/:id/return-policy", productHandler.GetReturnPolicy)
+	sellers.Put("/:id/return-policy", append(sellerAuth, productHandler.UpdateReturnPolicy)...)
+
+	// Seller shipping settings, applied at checkout
+	sellers.Get("/:id/shipping", productHandler.GetShippingSettings)
+	sellers.Put("/:id/shipping", append(sellerAuth, productHandler.UpdateShippingSettings)...)
+
+	// Seller business profiles, shown with their listings
+	sellers.Get("/:id/profile", productHandler.GetSellerProfile)
+	sellers.Put("/:id/profile", append(sellerAuth, productHandler.UpdateSellerProfile)...)
+	sellers.Delete("/:id/profile", append(sellerAuth, productHandler.DeleteSellerProfile)...)
+
+	// Inventory sync for external POS/ERP systems
+	sellers.Post("/:id/inventory/sync", append(sellerAuth, productHandler.SyncInventory)...)
+	sellers.Put("/:id/inventory/sync-config", append(sellerAuth, productHandler.UpdateSyncConfig)...)
+	sellers.Get("/:id/inventory/changes", append(sellerAuth, productHandler.GetInventoryChanges)...)
+	sellers.Get("/:id/inventory/alerts", append(sellerAuth, productHandler.GetInventoryAlerts)...)
+
+	// Seller analytics
+	sellers.Get("/:id/analytics/funnel", append(sellerAuth, productHandler.GetSellerFunnel)...)
+	sellers.Get("/:id/restock-demand", append(sellerAuth, productHandler.GetRestockDemand)...)
+
+	// Seller performance against the marketplace standards, for the seller and admins
+	sellers.Get("/:id/performance", middleware.AuthOrAPIKeyMiddleware(cfg, "products"), productHandler.GetSellerPerformance)
+
+	// Sponsored placement sellers buy for their products; clicks are public
+	promotionsGroup := api.Group("/promotions")
+	promotionsGroup.Post("/:id/click", middleware.OptionalAuthMiddleware(cfg), productHandler.TrackPromotionClick)
+	promotionAuth := promotionsGroup.Group("", middleware.AuthMiddleware(cfg), middleware.RoleMiddleware(models.RoleSeller))
+	promotionAuth.Post("/", productHandler.CreatePromotion)
+	promotionAuth.Get("/", productHandler.GetPromotions)
+	promotionAuth.Get("/:id", productHandler.GetPromotion)
+	promotionAuth.Post("/:id/pause", productHandler.PausePromotion)
+	promotionAuth.Post("/:id/resume", productHandler.ResumePromotion)
+	promotionAuth.Delete("/:id", productHandler.CancelPromotion)
+
+	// Negotiated offers, for both the buyer and the seller
+	offers := api.Group("/offers", middleware.AuthOrAPIKeyMiddleware(cfg, "products"))
+	offers.Get("/", productHandler.GetOffers)
+	offers.Post("/:id/respond", productHandler.RespondToOffer)
+
+	// Stores, the business identity sellers list and get paid through
+	storesGroup := api.Group("/stores")
+	storesGroup.Get("/:id", productHandler.GetStore)
+	storeAuth := storesGroup.Group("", middleware.AuthMiddleware(cfg))
+	storeAuth.Post("/", middleware.RoleMiddleware(models.RoleSeller), productHandler.CreateStore)
+	storeAuth.Put("/:id", productHandler.UpdateStore)
+	storeAuth.Get("/:id/members", productHandler.GetStoreMembers)
+	storeAuth.Post("/:id/members", productHandler.AddStoreMember)
+	storeAuth.Delete("/:id/members/:user_id", productHandler.RemoveStoreMember)
+	storeAuth.Get("/:id/balance", productHandler.GetStoreBalance)
+
+	// Admin catalog configuration
+	admin := api.Group("/admin", middleware.AuthMiddleware(cfg))
+	admin.Put("/categories/:category/requirements", middleware.PermissionMiddleware(middleware.PermCatalogWrite), productHandler.UpdateCategoryRequirements)
+
+	// Sitemap and shopping catalog feed files
+	admin.Get("/feeds", middleware.PermissionMiddleware(middleware.PermCatalogWrite), productHandler.GetFeeds)
+	admin.Post("/feeds/rebuild", middleware.PermissionMiddleware(middleware.PermCatalogWrite), productHandler.RebuildFeedsNow)
+
+	// Content moderation queue
+	admin.Get("/moderation/flags", middleware.PermissionMiddleware(middleware.PermModeration), productHandler.GetContentFlags)
+	admin.Put("/moderation/flags/:id", middleware.PermissionMiddleware(middleware.PermModeration), productHandler.ReviewContentFlag)
+
+	// Suggested merges of near-duplicate listings
+	admin.Get("/duplicates", middleware.PermissionMiddleware(middleware.PermModeration), productHandler.GetDuplicateListings)
+	admin.Put("/duplicates/:id", middleware.PermissionMiddleware(middleware.PermModeration), productHandler.ReviewDuplicateListing)
+
+	// Searches that found nothing, to spot catalog gaps
+	admin.Get("/search/zero-results", middleware.PermissionMiddleware(middleware.PermReportsRead), productHandler.GetZeroResultSearches)
+
+	// Sellers' latest performance snapshots
+	admin.Get("/sellers/performance", middleware.PermissionMiddleware(middleware.PermReportsRead), productHandler.GetSellerPerformanceOverview)
+
+	webhooks := api.Group("/webhooks")
+	webhooks.Post("/inventory/:sellerId", productHandler.InventoryWebhook)
 }
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/middleware"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/users"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Placeholder written over personal data on deleted accounts
+const anonymizedValue = "[deleted]"
+
+type AccountExport struct {
+	ExportedAt time.Time              `json:"exported_at"`
+	Profile    models.User            `json:"profile"`
+	Orders     []models.Order         `json:"orders"`
+	Payments   []models.Payment       `json:"payments"`
+	XPHistory  []models.XPTransaction `json:"xp_history"`
+	Badges     []models.UserBadge     `json:"badges"`
+	Wishlist   []models.WishlistItem  `json:"wishlist"`
+
+	PaymentPhones []models.PaymentPhone `json:"payment_phones"`
+
+	SellerVerifications []models.SellerVerification `json:"seller_verifications,omitempty"`
+	SellerProfile       *models.SellerProfile       `json:"seller_profile,omitempty"`
+
+	NotificationPreferences models.NotificationPreferences `json:"notification_preferences"`
+}
+
+// @Summary Delete user account
+// @Description Soft-delete an account, anonymize personal data on orders and revoke all sessions (own account or admin)
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} utils.Response
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /users/{id}/account [delete]
+func (h *UserHandler) DeleteAccount(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid user ID")
+	}
+
+	if !h.canAccessAccount(c, userID, middleware.PermUsersWrite) {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only delete your own account", nil)
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return utils.NotFoundResponse(c, "User not found")
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Orders and payments are kept for accounting, stripped of personal data.
+		// Payments reference the order only, so anonymizing orders covers both.
+		if err := tx.Model(&models.Order{}).Where("buyer_id = ?", userID).Updates(map[string]interface{}{
+			"shipping_address":     anonymizedValue,
+			"notes":                "",
+			"gift_recipient_name":  "",
+			"gift_recipient_phone": "",
+			"gift_message":         "",
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.LoginEvent{}).Where("user_id = ?", userID).Updates(map[string]interface{}{
+			"phone":       anonymizedValue,
+			"ip":          "",
+			"user_agent":  "",
+			"fingerprint": "",
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserDevice{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Product{}).
+			Where("id IN (?)", tx.Model(&models.WishlistItem{}).Select("product_id").Where("user_id = ?", userID)).
+			Update("saved_count", gorm.Expr("GREATEST(saved_count - 1, 0)")).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.WishlistItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.PaymentPhone{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.UserTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.SegmentMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.NotificationPreferences{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.ActivityEvent{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("seller_id = ?", userID).Delete(&models.SellerProfile{}).Error; err != nil {
+			return err
+		}
+		// Paid promotions are kept for the books but stop showing
+		if err := tx.Model(&models.Promotion{}).
+			Where("seller_id = ? AND status IN ?", userID, []models.PromotionStatus{models.PromotionPendingPayment, models.PromotionActive, models.PromotionPaused}).
+			Update("status", models.PromotionCancelled).Error; err != nil {
+			return err
+		}
+
+		// Free the phone and email so they can be used for a new account
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"phone":     fmt.Sprintf("deleted:%s", userID),
+			"email":     fmt.Sprintf("deleted-%s@invalid", userID),
+			"name":      anonymizedValue,
+			"is_active": false,
+
+			"avatar_url":           "",
+			"avatar_thumbnail_url": "",
+
+			"pending_email":            "",
+			"pending_email_expires_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+		// The audit history holds the personal data just cleared
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.UserChange{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&user).Error
+	})
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to delete account", err)
+	}
+
+	// Revoke all sessions
+	utils.RevokeTokens(userID)
+	users.Invalidate(userID)
+	redis.Delete(redis.EmailChangeKey(userID))
+
+	return utils.SuccessResponse(c, "Account deleted successfully", nil)
+}
+
+// @Summary Export user data
+// @Description Download a JSON archive of the user's profile, orders, payments, XP history and badges (own account or admin)
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} utils.Response{data=AccountExport}
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /users/{id}/export [get]
+func (h *UserHandler) ExportAccount(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid user ID")
+	}
+
+	if !h.canAccessAccount(c, userID, middleware.PermUsersRead) {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only export your own data", nil)
+	}
+
+	export := AccountExport{ExportedAt: time.Now()}
+
+	if err := database.DB.First(&export.Profile, userID).Error; err != nil {
+		return utils.NotFoundResponse(c, "User not found")
+	}
+
+	database.DB.Preload("Items.Product").Where("buyer_id = ?", userID).Order("created_at DESC").Find(&export.Orders)
+	database.DB.Joins("JOIN orders ON orders.id = payments.order_id").
+		Where("orders.buyer_id = ?", userID).
+		Order("payments.created_at DESC").
+		Find(&export.Payments)
+	database.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&export.XPHistory)
+	database.DB.Preload("Badge").Where("user_id = ?", userID).Find(&export.Badges)
+	database.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&export.Wishlist)
+	database.DB.Where("user_id = ?", userID).Order("created_at ASC").Find(&export.PaymentPhones)
+	database.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&export.SellerVerifications)
+	var sellerProfile models.SellerProfile
+	if database.DB.Where("seller_id = ?", userID).First(&sellerProfile).Error == nil {
+		export.SellerProfile = &sellerProfile
+	}
+	export.NotificationPreferences = notifications.PreferencesFor(userID)
+
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="account-%s.json"`, userID))
+
+	return utils.SuccessResponse(c, "Account data exported successfully", export)
+}
+
+// Helper functions
+
+// canAccessAccount allows the account owner, or a user whose role grants the permission
+func (h *UserHandler) canAccessAccount(c *fiber.Ctx, userID uuid.UUID, permission string) bool {
+	currentUserID, _ := c.Locals("user_id").(uuid.UUID)
+	userRole, _ := c.Locals("user_role").(models.UserRole)
+	return currentUserID == userID || middleware.HasPermission(userRole, permission)
+}
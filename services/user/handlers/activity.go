@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/middleware"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+type ActivityResponse struct {
+	Events     []models.ActivityEvent `json:"events"`
+	NextCursor string                 `json:"next_cursor,omitempty"` // Pass as cursor for older events, empty on the last page
+}
+
+// @Summary Get activity timeline
+// @Description Get a user's orders, badges, level-ups and reviews, newest first. Orders are only shown on your own timeline.
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param cursor query string false "Cursor from the previous page"
+// @Param limit query int false "Number of events to return" default(20)
+// @Success 200 {object} utils.Response{data=ActivityResponse}
+// @Failure 400 {object} utils.Problem
+// @Router /users/{id}/activity [get]
+func (h *UserHandler) GetActivity(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid user ID")
+	}
+
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100 // Cap at 100 for performance
+	}
+
+	query := database.DB.Where("user_id = ?", userID)
+	if !h.canAccessAccount(c, userID, middleware.PermUsersRead) {
+		query = query.Where("type <> ?", models.ActivityOrderPlaced)
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		createdAt, id, err := decodeActivityCursor(cursor)
+		if err != nil {
+			return utils.ValidationErrorResponse(c, "Invalid cursor")
+		}
+		query = query.Where("(created_at, id) < (?, ?)", createdAt, id)
+	}
+
+	// One extra row tells us whether there's another page
+	var events []models.ActivityEvent
+	if err := query.Order("created_at DESC, id DESC").Limit(limit + 1).Find(&events).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get activity", err)
+	}
+
+	response := ActivityResponse{Events: events}
+	if len(events) > limit {
+		response.Events = events[:limit]
+		last := response.Events[limit-1]
+		response.NextCursor = encodeActivityCursor(last.CreatedAt, last.ID)
+	}
+
+	return utils.SuccessResponse(c, "Activity retrieved successfully", response)
+}
+
+// Helper functions
+
+func encodeActivityCursor(createdAt time.Time, id uuid.UUID) string {
+	raw := fmt.Sprintf("%s|%s", createdAt.UTC().Format(time.RFC3339Nano), id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeActivityCursor(cursor string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, errInvalidCursor
+	}
+
+	timestamp, idPart, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return time.Time{}, uuid.Nil, errInvalidCursor
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return time.Time{}, uuid.Nil, errInvalidCursor
+	}
+	id, err := uuid.Parse(idPart)
+	if err != nil {
+		return time.Time{}, uuid.Nil, errInvalidCursor
+	}
+	return createdAt, id, nil
+}
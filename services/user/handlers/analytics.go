@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/middleware"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Orders that count as spending; pending, held and cancelled orders don't
+var spendingStatuses = []models.OrderStatus{
+	models.OrderConfirmed,
+	models.OrderProcessing,
+	models.OrderShipped,
+	models.OrderDelivered,
+}
+
+type MonthlySpending struct {
+	Month    string  `json:"month"` // YYYY-MM
+	Spent    float64 `json:"spent"`
+	Orders   int64   `json:"orders"`
+	XPEarned int64   `json:"xp_earned"`
+}
+
+type CategorySpending struct {
+	Category string  `json:"category"`
+	Spent    float64 `json:"spent"`
+	Items    int64   `json:"items"`
+}
+
+type SpendingAnalytics struct {
+	UserID            uuid.UUID          `json:"user_id"`
+	Year              int                `json:"year"`
+	TotalSpent        float64            `json:"total_spent"`
+	OrderCount        int64              `json:"order_count"`
+	AverageOrderValue float64            `json:"average_order_value"`
+	XPEarned          int64              `json:"xp_earned"`
+	Months            []MonthlySpending  `json:"months"`     // January to December
+	Categories        []CategorySpending `json:"categories"` // Highest spend first
+}
+
+type SpendingAnalyticsResponse struct {
+	SpendingAnalytics
+	Currency utils.CurrencyFormat `json:"currency_format"`
+}
+
+// @Summary Get buyer spending analytics
+// @Description Get a buyer's spend, orders and XP per month, spend per category and average order value for a year (own account or admin)
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param year query int false "Calendar year" default(current year)
+// @Success 200 {object} utils.Response{data=SpendingAnalyticsResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Router /users/{id}/analytics [get]
+func (h *UserHandler) GetSpendingAnalytics(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid user ID")
+	}
+
+	if !h.canAccessAccount(c, userID, middleware.PermUsersRead) {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only view your own analytics", nil)
+	}
+
+	now := time.Now().UTC()
+	year := c.QueryInt("year", now.Year())
+	if year < 2000 || year > now.Year() {
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("Year must be between 2000 and %d", now.Year()))
+	}
+
+	var analytics SpendingAnalytics
+	cacheKey := redis.BuyerAnalyticsKey(userID, year)
+	if err := redis.Get(cacheKey, &analytics); err != nil {
+		if analytics, err = buildSpendingAnalytics(userID, year); err != nil {
+			return utils.InternalServerErrorResponse(c, "Failed to get spending analytics", err)
+		}
+		redis.Set(cacheKey, analytics)
+	}
+
+	response := SpendingAnalyticsResponse{
+		SpendingAnalytics: analytics,
+		Currency:          utils.CurrencyHint(c, h.config),
+	}
+
+	return utils.SuccessResponse(c, "Spending analytics retrieved successfully", response)
+}
+
+// Helper functions
+
+func buildSpendingAnalytics(userID uuid.UUID, year int) (SpendingAnalytics, error) {
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(1, 0, 0)
+
+	analytics := SpendingAnalytics{
+		UserID:     userID,
+		Year:       year,
+		Months:     make([]MonthlySpending, 12),
+		Categories: []CategorySpending{},
+	}
+	for i := range analytics.Months {
+		analytics.Months[i].Month = from.AddDate(0, i, 0).Format("2006-01")
+	}
+
+	var spending []MonthlySpending
+	if err := database.DB.Model(&models.Order{}).
+		Select("TO_CHAR(DATE_TRUNC('month', created_at), 'YYYY-MM') AS month, SUM(total_amount) AS spent, COUNT(*) AS orders").
+		Where("buyer_id = ? AND status IN ? AND created_at >= ? AND created_at < ?", userID, spendingStatuses, from, to).
+		Group("month").
+		Scan(&spending).Error; err != nil {
+		return analytics, err
+	}
+
+	var xp []MonthlySpending
+	if err := database.DB.Model(&models.XPTransaction{}).
+		Select("TO_CHAR(DATE_TRUNC('month', created_at), 'YYYY-MM') AS month, SUM(amount) AS xp_earned").
+		Where("user_id = ? AND amount > 0 AND created_at >= ? AND created_at < ?", userID, from, to).
+		Group("month").
+		Scan(&xp).Error; err != nil {
+		return analytics, err
+	}
+
+	months := make(map[string]*MonthlySpending, len(analytics.Months))
+	for i := range analytics.Months {
+		months[analytics.Months[i].Month] = &analytics.Months[i]
+	}
+	for _, row := range spending {
+		if month, ok := months[row.Month]; ok {
+			month.Spent = row.Spent
+			month.Orders = row.Orders
+			analytics.TotalSpent += row.Spent
+			analytics.OrderCount += row.Orders
+		}
+	}
+	for _, row := range xp {
+		if month, ok := months[row.Month]; ok {
+			month.XPEarned = row.XPEarned
+			analytics.XPEarned += row.XPEarned
+		}
+	}
+	if analytics.OrderCount > 0 {
+		analytics.AverageOrderValue = analytics.TotalSpent / float64(analytics.OrderCount)
+	}
+
+	if err := database.DB.Model(&models.OrderItem{}).
+		Select("COALESCE(NULLIF(products.category, ''), 'Uncategorized') AS category, "+
+			"SUM(order_items.price * order_items.quantity) AS spent, SUM(order_items.quantity) AS items").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Joins("JOIN products ON products.id = order_items.product_id").
+		Where("orders.buyer_id = ? AND orders.status IN ? AND orders.created_at >= ? AND orders.created_at < ? AND orders.deleted_at IS NULL",
+			userID, spendingStatuses, from, to).
+		Group("1").
+		Order("spent DESC").
+		Scan(&analytics.Categories).Error; err != nil {
+		return analytics, err
+	}
+
+	return analytics, nil
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/storage"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Width and height of the generated avatar thumbnail, in pixels
+const avatarThumbnailSize = 128
+
+var avatarExtensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+type AvatarResponse struct {
+	AvatarURL          string `json:"avatar_url"`
+	AvatarThumbnailURL string `json:"avatar_thumbnail_url"`
+}
+
+// @Summary Upload avatar
+// @Description Upload a profile image as multipart form field "avatar" (JPEG, PNG or GIF). A square thumbnail is generated alongside the original (own profile only).
+// @Tags users
+// @Security BearerAuth
+// @Accept multipart/form-data
+// @Param id path string true "User ID"
+// @Param avatar formData file true "Profile image"
+// @Success 200 {object} utils.Response{data=AvatarResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Router /users/{id}/avatar [post]
+func (h *UserHandler) UploadAvatar(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid user ID")
+	}
+
+	currentUserID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok || currentUserID != userID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only update your own avatar", nil)
+	}
+
+	file, err := c.FormFile("avatar")
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Avatar file is required")
+	}
+
+	maxBytes := int64(h.config.Storage.MaxAvatarMB) << 20
+	if file.Size > maxBytes {
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("Avatar must be at most %d MB", h.config.Storage.MaxAvatarMB))
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Failed to read avatar file")
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, maxBytes+1))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Failed to read avatar file")
+	}
+
+	// Trust the file's contents over the client's declared type
+	contentType := http.DetectContentType(data)
+	extension, ok := avatarExtensions[contentType]
+	if !ok {
+		return utils.ValidationErrorResponse(c, storage.ErrUnsupportedImage.Error())
+	}
+
+	img, err := storage.DecodeImage(data)
+	if err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+	thumbnail, err := storage.SquareThumbnail(img, avatarThumbnailSize)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to generate thumbnail", err)
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return utils.NotFoundResponse(c, "User not found")
+	}
+
+	// A new key per upload so cached copies of the old avatar aren't served
+	version := uuid.New().String()
+	avatarURL, err := h.storage.Put(fmt.Sprintf("avatars/%s/%s.%s", userID, version, extension), contentType, data)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to store avatar", err)
+	}
+	thumbnailURL, err := h.storage.Put(fmt.Sprintf("avatars/%s/%s_thumb.jpg", userID, version), "image/jpeg", thumbnail)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to store avatar", err)
+	}
+
+	if err := database.ActingAs(currentUserID).Model(&user).Updates(map[string]interface{}{
+		"avatar_url":           avatarURL,
+		"avatar_thumbnail_url": thumbnailURL,
+	}).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update avatar", err)
+	}
+
+	return utils.SuccessResponse(c, "Avatar updated successfully", AvatarResponse{
+		AvatarURL:          avatarURL,
+		AvatarThumbnailURL: thumbnailURL,
+	})
+}
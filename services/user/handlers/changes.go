@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// @Summary Get user change history
+// @Description Get the audit history of a user's profile fields: who changed which field, when, and the old and new values (admin only). Changes made by the system have no actor.
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param field query string false "Only changes to this field, e.g. phone"
+// @Param actor_id query string false "Only changes made by this user"
+// @Param since query string false "Only changes after this time (RFC 3339)"
+// @Param limit query int false "Number of changes to return" default(50)
+// @Param offset query int false "Number of changes to skip" default(0)
+// @Success 200 {object} utils.Response{data=[]models.UserChange}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Router /users/{id}/changes [get]
+func (h *UserHandler) GetUserChanges(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid user ID")
+	}
+
+	limit := c.QueryInt("limit", 50)
+	offset := c.QueryInt("offset", 0)
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	query := database.DB.Where("user_id = ?", userID)
+	if field := c.Query("field"); field != "" {
+		query = query.Where("field = ?", field)
+	}
+	if actor := c.Query("actor_id"); actor != "" {
+		actorID, err := uuid.Parse(actor)
+		if err != nil {
+			return utils.ValidationErrorResponse(c, "Invalid actor ID")
+		}
+		query = query.Where("actor_id = ?", actorID)
+	}
+	if since := c.Query("since"); since != "" {
+		sinceTime, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return utils.ValidationErrorResponse(c, "Since must be an RFC 3339 time")
+		}
+		query = query.Where("created_at > ?", sinceTime)
+	}
+
+	var changes []models.UserChange
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&changes).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get user changes", err)
+	}
+
+	return utils.SuccessResponse(c, "User changes retrieved successfully", changes)
+}
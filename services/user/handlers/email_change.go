@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/users"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const maxEmailChangeAttempts = 5
+
+var (
+	errNotOwnAccount  = errors.New("not the caller's account")
+	errGuestAccount   = errors.New("guest account")
+	errInvalidEmail   = errors.New("invalid email address")
+	errEmailUnchanged = errors.New("email address unchanged")
+	errEmailTaken     = errors.New("email address already in use")
+)
+
+type EmailChangeRequest struct {
+	NewEmail string `json:"new_email" validate:"required"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// pendingEmailChange is kept in Redis between the request and confirm steps
+type pendingEmailChange struct {
+	NewEmail string `json:"new_email"`
+	Token    string `json:"token"`
+	Attempts int    `json:"attempts"`
+}
+
+// @Summary Request email change
+// @Description Send a confirmation token to the new email address and let the current one know. The change takes effect once confirmed.
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body EmailChangeRequest true "New email address"
+// @Success 200 {object} utils.Response{data=models.User}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /users/{id}/email [post]
+func (h *UserHandler) RequestEmailChange(c *fiber.Ctx) error {
+	user, err := h.ownAccount(c)
+	if err != nil {
+		return emailChangeErrorResponse(c, err)
+	}
+
+	var req EmailChangeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if err := h.beginEmailChange(user, req.NewEmail); err != nil {
+		return emailChangeErrorResponse(c, err)
+	}
+
+	return utils.SuccessResponse(c, "Confirmation sent to the new email address", user)
+}
+
+// @Summary Confirm email change
+// @Description Apply the pending email change with the token sent to the new address
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body ConfirmEmailChangeRequest true "Confirmation token"
+// @Success 200 {object} utils.Response{data=models.User}
+// @Failure 400 {object} utils.Problem
+// @Failure 401 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /users/{id}/email/confirm [post]
+func (h *UserHandler) ConfirmEmailChange(c *fiber.Ctx) error {
+	user, err := h.ownAccount(c)
+	if err != nil {
+		return emailChangeErrorResponse(c, err)
+	}
+
+	var req ConfirmEmailChangeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	key := redis.EmailChangeKey(user.ID)
+	var pending pendingEmailChange
+	if err := redis.Get(key, &pending); err != nil {
+		clearPendingEmail(user)
+		return utils.ValidationErrorResponse(c, "No pending email change or the token has expired")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(req.Token), []byte(pending.Token)) != 1 {
+		// Drop the request after too many wrong tokens so it can't be brute-forced
+		pending.Attempts++
+		if pending.Attempts >= maxEmailChangeAttempts {
+			redis.Delete(key)
+			clearPendingEmail(user)
+		} else {
+			redis.Set(key, pending)
+		}
+		return utils.UnauthorizedResponse(c, "Invalid confirmation token")
+	}
+
+	oldEmail := user.Email
+	now := time.Now()
+	err = database.ActingAs(user.ID).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.User{}).Unscoped().Where("email = ? AND id <> ?", pending.NewEmail, user.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errEmailTaken
+		}
+
+		// The unique index on email rejects a concurrent claim of the same address
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"email":                    pending.NewEmail,
+			"email_verified_at":        now,
+			"pending_email":            "",
+			"pending_email_expires_at": nil,
+		}).Error; err != nil {
+			return errEmailTaken
+		}
+		return nil
+	})
+	if err != nil {
+		return emailChangeErrorResponse(c, err)
+	}
+
+	user.Email = pending.NewEmail
+	user.EmailVerifiedAt = &now
+	user.PendingEmail = ""
+	user.PendingEmailExpiresAt = nil
+	redis.Delete(key)
+	users.Invalidate(user.ID)
+
+	if oldEmail != "" {
+		notifications.SendEmail(oldEmail, "Your email address was changed",
+			fmt.Sprintf("The email on your Playful Marketplace account was changed to %s. If this wasn't you, contact support immediately.", pending.NewEmail))
+	}
+	notifications.Send(user.ID, models.NotificationSecurity, "Email address changed",
+		fmt.Sprintf("Your account email address was changed to %s.", pending.NewEmail))
+
+	return utils.SuccessResponse(c, "Email address changed successfully", user)
+}
+
+// @Summary Cancel email change
+// @Description Discard the pending email change, keeping the current address
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} utils.Response{data=models.User}
+// @Failure 403 {object} utils.Problem
+// @Router /users/{id}/email [delete]
+func (h *UserHandler) CancelEmailChange(c *fiber.Ctx) error {
+	user, err := h.ownAccount(c)
+	if err != nil {
+		return emailChangeErrorResponse(c, err)
+	}
+
+	if err := redis.Delete(redis.EmailChangeKey(user.ID)); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to cancel email change", err)
+	}
+	clearPendingEmail(user)
+
+	return utils.SuccessResponse(c, "Email change cancelled", user)
+}
+
+// Helper functions
+
+// ownAccount loads the user in the path, who must be the caller
+func (h *UserHandler) ownAccount(c *fiber.Ctx) (*models.User, error) {
+	userID, err := uuid.Parse(c.Params("id"))
+	currentUserID, ok := c.Locals("user_id").(uuid.UUID)
+	if err != nil || !ok || currentUserID != userID {
+		return nil, errNotOwnAccount
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// beginEmailChange records newEmail as the user's pending address, sends the
+// confirmation token to it and warns the current address. The user's
+// pending fields are updated in place.
+func (h *UserHandler) beginEmailChange(user *models.User, newEmail string) error {
+	if user.Role == models.RoleGuest {
+		return errGuestAccount
+	}
+
+	newEmail = strings.ToLower(strings.TrimSpace(newEmail))
+	if address, err := mail.ParseAddress(newEmail); err != nil || address.Address != newEmail {
+		return errInvalidEmail
+	}
+	if newEmail == user.Email {
+		return errEmailUnchanged
+	}
+
+	var count int64
+	database.DB.Model(&models.User{}).Unscoped().Where("email = ?", newEmail).Count(&count)
+	if count > 0 {
+		return errEmailTaken
+	}
+
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	pending := pendingEmailChange{
+		NewEmail: newEmail,
+		Token:    hex.EncodeToString(raw),
+	}
+	key := redis.EmailChangeKey(user.ID)
+	if err := redis.Set(key, pending); err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(key.TTL)
+	if err := database.ActingAs(user.ID).Model(user).Updates(map[string]interface{}{
+		"pending_email":            newEmail,
+		"pending_email_expires_at": expiresAt,
+	}).Error; err != nil {
+		return err
+	}
+	user.PendingEmail = newEmail
+	user.PendingEmailExpiresAt = &expiresAt
+
+	notifications.SendEmail(newEmail, "Confirm your new email address",
+		fmt.Sprintf("Use this token to confirm your new Playful Marketplace email address: %s", pending.Token))
+	if user.Email != "" {
+		notifications.SendEmail(user.Email, "Email change requested",
+			fmt.Sprintf("A change of your Playful Marketplace email address to %s was requested. If this wasn't you, cancel it in the app and contact support.", newEmail))
+	}
+
+	return nil
+}
+
+func clearPendingEmail(user *models.User) {
+	if user.PendingEmail == "" {
+		return
+	}
+	database.ActingAs(user.ID).Model(user).Updates(map[string]interface{}{
+		"pending_email":            "",
+		"pending_email_expires_at": nil,
+	})
+	user.PendingEmail = ""
+	user.PendingEmailExpiresAt = nil
+}
+
+func emailChangeErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errNotOwnAccount):
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only change your own email address", nil)
+	case errors.Is(err, errGuestAccount):
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "Create an account before changing your email address", nil)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return utils.NotFoundResponse(c, "User not found")
+	case errors.Is(err, errInvalidEmail):
+		return utils.ValidationErrorResponse(c, "Invalid email address")
+	case errors.Is(err, errEmailUnchanged):
+		return utils.ValidationErrorResponse(c, "New email address must differ from the current one")
+	case errors.Is(err, errEmailTaken):
+		return utils.ErrorResponse(c, fiber.StatusConflict, "Email address is already in use", nil)
+	}
+	return utils.InternalServerErrorResponse(c, "Failed to change email address", err)
+}
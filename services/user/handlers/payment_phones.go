@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/regions"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var errInvalidPaymentPhone = errors.New("invalid payment phone ID")
+
+const (
+	maxPaymentPhones              = 5
+	maxPaymentPhoneVerifyAttempts = 5
+)
+
+type PaymentPhoneRequest struct {
+	Phone string `json:"phone" validate:"required"`
+	Label string `json:"label"`
+}
+
+type UpdatePaymentPhoneRequest struct {
+	Label string `json:"label" validate:"required"`
+}
+
+type VerifyPaymentPhoneRequest struct {
+	OTP string `json:"otp" validate:"required"`
+}
+
+// pendingPaymentPhone is kept in Redis until the saved number is verified
+type pendingPaymentPhone struct {
+	OTP      string `json:"otp"`
+	Attempts int    `json:"attempts"`
+}
+
+// @Summary Get payment phones
+// @Description Get the phone numbers saved for Telebirr and CBE Birr payments (own account only)
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} utils.Response{data=[]models.PaymentPhone}
+// @Failure 403 {object} utils.Problem
+// @Router /users/{id}/payment-phones [get]
+func (h *UserHandler) GetPaymentPhones(c *fiber.Ctx) error {
+	user, err := h.ownAccount(c)
+	if err != nil {
+		return paymentPhoneErrorResponse(c, err)
+	}
+
+	var phones []models.PaymentPhone
+	if err := database.DB.Where("user_id = ?", user.ID).Order("created_at ASC").Find(&phones).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get payment phones", err)
+	}
+
+	return utils.SuccessResponse(c, "Payment phones retrieved successfully", phones)
+}
+
+// @Summary Add payment phone
+// @Description Save a phone number for mobile payments and send a verification code to it. Saving an unverified number again resends the code. (own account only)
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body PaymentPhoneRequest true "Phone number and label"
+// @Success 201 {object} utils.Response{data=models.PaymentPhone}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /users/{id}/payment-phones [post]
+func (h *UserHandler) AddPaymentPhone(c *fiber.Ctx) error {
+	user, err := h.ownAccount(c)
+	if err != nil {
+		return paymentPhoneErrorResponse(c, err)
+	}
+	if user.Role == models.RoleGuest {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "Create an account before saving payment phones", nil)
+	}
+
+	var req PaymentPhoneRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	req.Phone = strings.TrimSpace(req.Phone)
+	req.Label = strings.TrimSpace(req.Label)
+	if _, ok := regions.ForPhone(req.Phone); !ok {
+		return utils.FieldErrorsResponse(c, "Phone number is not valid", utils.FieldError{
+			Field:   "phone",
+			Message: "Phone must be a mobile number in international format from a region we operate in",
+		})
+	}
+	if len(req.Label) > 50 {
+		return utils.ValidationErrorResponse(c, "Label must be at most 50 characters")
+	}
+
+	var phone models.PaymentPhone
+	if err := database.DB.Where("user_id = ? AND phone = ?", user.ID, req.Phone).First(&phone).Error; err == nil {
+		if phone.VerifiedAt != nil {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "Phone number is already saved", nil)
+		}
+	} else {
+		var count int64
+		database.DB.Model(&models.PaymentPhone{}).Where("user_id = ?", user.ID).Count(&count)
+		if count >= maxPaymentPhones {
+			return utils.ValidationErrorResponse(c, fmt.Sprintf("You can save at most %d payment phones", maxPaymentPhones))
+		}
+
+		phone = models.PaymentPhone{
+			BaseModel: models.BaseModel{ID: uuid.New()},
+			UserID:    user.ID,
+			Label:     req.Label,
+			Phone:     req.Phone,
+		}
+		// The unique index rejects a concurrent save of the same number
+		if err := database.DB.Create(&phone).Error; err != nil {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "Phone number is already saved", nil)
+		}
+	}
+
+	if err := sendPaymentPhoneCode(&phone); err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to store verification code", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Verification code sent to the phone number",
+		Data:    phone,
+	})
+}
+
+// @Summary Verify payment phone
+// @Description Confirm ownership of a saved payment phone with the code sent to it, so it can be used for payments (own account only)
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param phoneId path string true "Payment phone ID"
+// @Param request body VerifyPaymentPhoneRequest true "Verification code"
+// @Success 200 {object} utils.Response{data=models.PaymentPhone}
+// @Failure 400 {object} utils.Problem
+// @Failure 401 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /users/{id}/payment-phones/{phoneId}/verify [post]
+func (h *UserHandler) VerifyPaymentPhone(c *fiber.Ctx) error {
+	phone, err := h.ownPaymentPhone(c)
+	if err != nil {
+		return paymentPhoneErrorResponse(c, err)
+	}
+	if phone.VerifiedAt != nil {
+		return utils.SuccessResponse(c, "Payment phone is already verified", phone)
+	}
+
+	var req VerifyPaymentPhoneRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	key := redis.PaymentPhoneKey(phone.ID)
+	var pending pendingPaymentPhone
+	if err := redis.Get(key, &pending); err != nil {
+		return utils.ValidationErrorResponse(c, "No pending verification or the code has expired")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(req.OTP), []byte(pending.OTP)) != 1 {
+		// Drop the code after too many wrong guesses so it can't be brute-forced
+		pending.Attempts++
+		if pending.Attempts >= maxPaymentPhoneVerifyAttempts {
+			redis.Delete(key)
+		} else {
+			redis.Set(key, pending)
+		}
+		return utils.UnauthorizedResponse(c, "Invalid verification code")
+	}
+
+	now := time.Now()
+	if err := database.DB.Model(phone).Update("verified_at", now).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to verify payment phone", err)
+	}
+	redis.Delete(key)
+
+	return utils.SuccessResponse(c, "Payment phone verified successfully", phone)
+}
+
+// @Summary Update payment phone
+// @Description Rename a saved payment phone (own account only)
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param phoneId path string true "Payment phone ID"
+// @Param request body UpdatePaymentPhoneRequest true "New label"
+// @Success 200 {object} utils.Response{data=models.PaymentPhone}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /users/{id}/payment-phones/{phoneId} [put]
+func (h *UserHandler) UpdatePaymentPhone(c *fiber.Ctx) error {
+	phone, err := h.ownPaymentPhone(c)
+	if err != nil {
+		return paymentPhoneErrorResponse(c, err)
+	}
+
+	var req UpdatePaymentPhoneRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+	req.Label = strings.TrimSpace(req.Label)
+	if len(req.Label) > 50 {
+		return utils.ValidationErrorResponse(c, "Label must be at most 50 characters")
+	}
+
+	if err := database.DB.Model(phone).Update("label", req.Label).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update payment phone", err)
+	}
+
+	return utils.SuccessResponse(c, "Payment phone updated successfully", phone)
+}
+
+// @Summary Delete payment phone
+// @Description Remove a saved payment phone (own account only)
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param phoneId path string true "Payment phone ID"
+// @Success 200 {object} utils.Response
+// @Failure 404 {object} utils.Problem
+// @Router /users/{id}/payment-phones/{phoneId} [delete]
+func (h *UserHandler) DeletePaymentPhone(c *fiber.Ctx) error {
+	phone, err := h.ownPaymentPhone(c)
+	if err != nil {
+		return paymentPhoneErrorResponse(c, err)
+	}
+
+	// Hard delete so the number can be saved again
+	if err := database.DB.Unscoped().Delete(phone).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to delete payment phone", err)
+	}
+	redis.Delete(redis.PaymentPhoneKey(phone.ID))
+
+	return utils.SuccessResponse(c, "Payment phone deleted successfully", nil)
+}
+
+// Helper functions
+
+// ownPaymentPhone loads the payment phone in the path, which must belong to
+// the caller
+func (h *UserHandler) ownPaymentPhone(c *fiber.Ctx) (*models.PaymentPhone, error) {
+	user, err := h.ownAccount(c)
+	if err != nil {
+		return nil, errNotOwnAccount
+	}
+
+	phoneID, err := uuid.Parse(c.Params("phoneId"))
+	if err != nil {
+		return nil, errInvalidPaymentPhone
+	}
+
+	var phone models.PaymentPhone
+	if err := database.DB.Where("id = ? AND user_id = ?", phoneID, user.ID).First(&phone).Error; err != nil {
+		return nil, err
+	}
+	return &phone, nil
+}
+
+func paymentPhoneErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errNotOwnAccount):
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only manage your own payment phones", nil)
+	case errors.Is(err, errInvalidPaymentPhone):
+		return utils.ValidationErrorResponse(c, "Invalid payment phone ID")
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return utils.NotFoundResponse(c, "Payment phone not found")
+	}
+	return utils.InternalServerErrorResponse(c, "Failed to get payment phone", err)
+}
+
+// sendPaymentPhoneCode texts a new verification code to the phone,
+// replacing any earlier one
+func sendPaymentPhoneCode(phone *models.PaymentPhone) error {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return err
+	}
+	pending := pendingPaymentPhone{OTP: fmt.Sprintf("%06d", n.Int64())}
+	if err := redis.Set(redis.PaymentPhoneKey(phone.ID), pending); err != nil {
+		return err
+	}
+
+	notifications.SendSMS(phone.Phone, fmt.Sprintf("Your Playful Marketplace code to save this number for payments is %s", pending.OTP))
+	return nil
+}
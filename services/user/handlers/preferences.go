@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/middleware"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Omitted fields keep their current value
+type UpdateNotificationPreferencesRequest struct {
+	OrderUpdates *bool `json:"order_updates"`
+	Marketing    *bool `json:"marketing"`
+	Gamification *bool `json:"gamification"`
+	SMS          *bool `json:"sms"`
+	Email        *bool `json:"email"`
+	Push         *bool `json:"push"`
+}
+
+// @Summary Get notification preferences
+// @Description Get which notification categories and channels a user receives (own account or admin)
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} utils.Response{data=models.NotificationPreferences}
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /users/{id}/notification-preferences [get]
+func (h *UserHandler) GetNotificationPreferences(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid user ID")
+	}
+
+	if !h.canAccessAccount(c, userID, middleware.PermUsersRead) {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only view your own notification preferences", nil)
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return utils.NotFoundResponse(c, "User not found")
+	}
+
+	return utils.SuccessResponse(c, "Notification preferences retrieved successfully", notifications.PreferencesFor(userID))
+}
+
+// @Summary Update notification preferences
+// @Description Turn notification categories (order updates, marketing, gamification) and delivery channels (SMS, email, push) on or off. Security and account notices are always sent (own account only).
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body UpdateNotificationPreferencesRequest true "Preferences to change"
+// @Success 200 {object} utils.Response{data=models.NotificationPreferences}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Router /users/{id}/notification-preferences [put]
+func (h *UserHandler) UpdateNotificationPreferences(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid user ID")
+	}
+
+	currentUserID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok || currentUserID != userID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only update your own notification preferences", nil)
+	}
+
+	var req UpdateNotificationPreferencesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	prefs := notifications.PreferencesFor(userID)
+	applyPreference(&prefs.OrderUpdates, req.OrderUpdates)
+	applyPreference(&prefs.Marketing, req.Marketing)
+	applyPreference(&prefs.Gamification, req.Gamification)
+	applyPreference(&prefs.SMS, req.SMS)
+	applyPreference(&prefs.Email, req.Email)
+	applyPreference(&prefs.Push, req.Push)
+
+	err = database.ActingAs(userID).Transaction(func(tx *gorm.DB) error {
+		if prefs.ID == uuid.Nil {
+			prefs.ID = uuid.New()
+			if err := tx.Create(&prefs).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Save(&prefs).Error; err != nil {
+			return err
+		}
+
+		// Campaign and reminder selection still filters on the user flag
+		return tx.Model(&models.User{}).Where("id = ?", userID).
+			Update("marketing_opt_out", !prefs.Marketing).Error
+	})
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update notification preferences", err)
+	}
+
+	return utils.SuccessResponse(c, "Notification preferences updated successfully", prefs)
+}
+
+// Helper functions
+
+func applyPreference(field *bool, value *bool) {
+	if value != nil {
+		*field = *value
+	}
+}
@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/jobs"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/users"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const jobPurgeAccounts = "user.purge_accounts"
+
+// Phone of the placeholder account purged users' orders and XP history are
+// moved to, so the records kept for accounting point at nobody
+const purgedAccountPhone = "purged"
+
+var errHasListings = errors.New("account still has product listings")
+
+type StartAccountPurgeRequest struct {
+	DryRun bool `json:"dry_run"` // Only report what would be purged
+}
+
+type purgeAccountsPayload struct {
+	PurgeID uuid.UUID `json:"purge_id"`
+}
+
+// Records a purged account's data is detached from
+type purgeCounts struct {
+	Orders         int64
+	Payments       int64
+	XPTransactions int64
+}
+
+// @Summary Start deleted account purge
+// @Description Queue a purge of accounts deleted more than the configured number of days ago. Their orders, payments and XP history are kept for accounting but detached from them, and the accounts are removed for good. A dry run only reports what would be purged (admin only).
+// @Tags admin
+// @Security BearerAuth
+// @Param request body StartAccountPurgeRequest false "Purge options"
+// @Success 202 {object} utils.Response{data=models.AccountPurge}
+// @Failure 400 {object} utils.Problem
+// @Router /admin/accounts/purges [post]
+func (h *UserHandler) StartAccountPurge(c *fiber.Ctx) error {
+	adminID, _ := c.Locals("user_id").(uuid.UUID)
+
+	var req StartAccountPurgeRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return utils.ValidationErrorResponse(c, "Invalid request body")
+		}
+	}
+
+	if h.config.Accounts.PurgeAfterDays <= 0 {
+		return utils.ValidationErrorResponse(c, "Account purging is disabled")
+	}
+
+	purge, err := h.queueAccountPurge(req.DryRun, &adminID)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to start account purge", err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(utils.Response{
+		Success: true,
+		Message: "Account purge started",
+		Data:    purge,
+	})
+}
+
+// @Summary Get account purge reports
+// @Description Get the summary reports of past deleted account purges, newest first (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} utils.Response{data=[]models.AccountPurge}
+// @Router /admin/accounts/purges [get]
+func (h *UserHandler) GetAccountPurges(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+
+	if page < 1 {
+		page = 1
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	var purges []models.AccountPurge
+	if err := database.DB.Order("created_at DESC").
+		Offset((page - 1) * limit).Limit(limit).
+		Find(&purges).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get account purges", err)
+	}
+
+	return utils.SuccessResponse(c, "Account purges retrieved successfully", purges)
+}
+
+// @Summary Get account purge report
+// @Description Get the summary report of one deleted account purge (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Purge ID"
+// @Success 200 {object} utils.Response{data=models.AccountPurge}
+// @Failure 404 {object} utils.Problem
+// @Router /admin/accounts/purges/{id} [get]
+func (h *UserHandler) GetAccountPurge(c *fiber.Ctx) error {
+	purgeID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid purge ID")
+	}
+
+	var purge models.AccountPurge
+	if err := database.DB.First(&purge, purgeID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Account purge not found")
+	}
+
+	return utils.SuccessResponse(c, "Account purge retrieved successfully", purge)
+}
+
+// ScheduleAccountPurge queues the daily purge of deleted accounts, as a dry
+// run when configured. Run periodically by the scheduler.
+func (h *UserHandler) ScheduleAccountPurge() {
+	if h.config.Accounts.PurgeAfterDays <= 0 {
+		return
+	}
+	if _, err := h.queueAccountPurge(h.config.Accounts.PurgeDryRun, nil); err != nil {
+		log.Printf("Failed to queue account purge: %v", err)
+	}
+}
+
+// Helper functions
+
+// queueAccountPurge creates the purge's report and queues the job that fills it in
+func (h *UserHandler) queueAccountPurge(dryRun bool, triggeredBy *uuid.UUID) (*models.AccountPurge, error) {
+	purge := models.AccountPurge{
+		BaseModel:     models.BaseModel{ID: uuid.New()},
+		DryRun:        dryRun,
+		DeletedBefore: time.Now().AddDate(0, 0, -h.config.Accounts.PurgeAfterDays),
+		TriggeredBy:   triggeredBy,
+	}
+	if err := database.DB.Create(&purge).Error; err != nil {
+		return nil, err
+	}
+	if err := jobs.Enqueue(jobPurgeAccounts, purgeAccountsPayload{PurgeID: purge.ID}); err != nil {
+		return nil, err
+	}
+	return &purge, nil
+}
+
+// purgeAccounts runs a queued purge and records its summary. Each account is
+// purged in its own transaction, so one that can't be purged doesn't hold
+// back the rest.
+func (h *UserHandler) purgeAccounts(payload []byte) error {
+	var job purgeAccountsPayload
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+
+	var purge models.AccountPurge
+	if err := database.DB.First(&purge, job.PurgeID).Error; err != nil {
+		return err
+	}
+	if purge.CompletedAt != nil {
+		return nil
+	}
+
+	placeholderID, err := purgedAccount()
+	if err != nil {
+		return err
+	}
+
+	var userIDs []uuid.UUID
+	if err := database.DB.Unscoped().Model(&models.User{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ? AND id <> ?", purge.DeletedBefore, placeholderID).
+		Pluck("id", &userIDs).Error; err != nil {
+		return err
+	}
+
+	var failures []string
+	for _, userID := range userIDs {
+		var counts purgeCounts
+		if purge.DryRun {
+			counts, err = countPurgeRecords(database.DB, userID)
+		} else {
+			err = database.DB.Transaction(func(tx *gorm.DB) error {
+				counts, err = purgeAccount(tx, userID, placeholderID)
+				return err
+			})
+		}
+		if err != nil {
+			purge.Skipped++
+			failures = append(failures, fmt.Sprintf("%s: %v", userID, err))
+			continue
+		}
+
+		if !purge.DryRun {
+			users.Invalidate(userID)
+			redis.Delete(redis.UserSummaryKey(userID))
+		}
+
+		purge.Users++
+		purge.Orders += counts.Orders
+		purge.Payments += counts.Payments
+		purge.XPTransactions += counts.XPTransactions
+	}
+
+	now := time.Now()
+	purge.Errors = strings.Join(failures, "\n")
+	purge.CompletedAt = &now
+	if err := database.DB.Save(&purge).Error; err != nil {
+		return err
+	}
+
+	log.Printf("Account purge %s (dry run: %t): %d purged, %d skipped", purge.ID, purge.DryRun, purge.Users, purge.Skipped)
+	return nil
+}
+
+// purgedAccount returns the placeholder account, creating it on first use.
+// It's deleted itself so it never shows up as a real user.
+func purgedAccount() (uuid.UUID, error) {
+	var placeholder models.User
+	err := database.DB.Unscoped().Where("phone = ?", purgedAccountPhone).First(&placeholder).Error
+	if err == nil {
+		return placeholder.ID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return uuid.Nil, err
+	}
+
+	placeholder = models.User{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		Phone:     purgedAccountPhone,
+		Email:     "purged@invalid",
+		Name:      anonymizedValue,
+		Role:      models.RoleBuyer,
+		Roles:     models.RolesFor(models.RoleBuyer),
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&placeholder).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&placeholder).Update("is_active", false).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&placeholder).Error
+	})
+	return placeholder.ID, err
+}
+
+// countPurgeRecords reports what purging an account would detach, without
+// changing anything
+func countPurgeRecords(db *gorm.DB, userID uuid.UUID) (purgeCounts, error) {
+	var counts purgeCounts
+
+	var listings int64
+	db.Unscoped().Model(&models.Product{}).Where("seller_id = ?", userID).Count(&listings)
+	if listings > 0 {
+		return counts, errHasListings
+	}
+
+	orderIDs := db.Model(&models.Order{}).Select("id").Where("buyer_id = ?", userID)
+	if err := db.Model(&models.Order{}).Where("buyer_id = ?", userID).Count(&counts.Orders).Error; err != nil {
+		return counts, err
+	}
+	if err := db.Model(&models.Payment{}).Where("order_id IN (?)", orderIDs).Count(&counts.Payments).Error; err != nil {
+		return counts, err
+	}
+	if err := db.Model(&models.XPTransaction{}).Where("user_id = ?", userID).Count(&counts.XPTransactions).Error; err != nil {
+		return counts, err
+	}
+	return counts, nil
+}
+
+// purgeAccount moves a deleted account's orders, payments and XP history to
+// the placeholder account, clearing anything that still identifies the
+// user, then removes the account and its personal records for good
+func purgeAccount(tx *gorm.DB, userID, placeholderID uuid.UUID) (purgeCounts, error) {
+	var counts purgeCounts
+
+	// Sellers' listings would lose their seller, they must be removed first
+	var listings int64
+	tx.Unscoped().Model(&models.Product{}).Where("seller_id = ?", userID).Count(&listings)
+	if listings > 0 {
+		return counts, errHasListings
+	}
+
+	// Payments are found through the orders, so clear them before the orders move
+	orderIDs := tx.Model(&models.Order{}).Select("id").Where("buyer_id = ?", userID)
+	result := tx.Model(&models.Payment{}).Where("order_id IN (?)", orderIDs).Update("reference", "")
+	if result.Error != nil {
+		return counts, result.Error
+	}
+	counts.Payments = result.RowsAffected
+
+	result = tx.Model(&models.Order{}).Where("buyer_id = ?", userID).Updates(map[string]interface{}{
+		"buyer_id":             placeholderID,
+		"shipping_address":     anonymizedValue,
+		"notes":                "",
+		"contact_phone":        "",
+		"gift_recipient_name":  "",
+		"gift_recipient_phone": "",
+		"gift_message":         "",
+	})
+	if result.Error != nil {
+		return counts, result.Error
+	}
+	counts.Orders = result.RowsAffected
+
+	result = tx.Model(&models.XPTransaction{}).Where("user_id = ?", userID).Updates(map[string]interface{}{
+		"user_id":   placeholderID,
+		"reference": "",
+	})
+	if result.Error != nil {
+		return counts, result.Error
+	}
+	counts.XPTransactions = result.RowsAffected
+
+	for _, record := range []interface{}{
+		&models.UserBadge{},
+		&models.Notification{},
+		&models.UserDevice{},
+		&models.PaymentPhone{},
+		&models.AccountRecovery{},
+		&models.UserTag{},
+		&models.SegmentMember{},
+		&models.LoginEvent{},
+		&models.ActivityEvent{},
+		&models.UserChange{},
+	} {
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(record).Error; err != nil {
+			return counts, err
+		}
+	}
+
+	// Anything else still pointing at the account fails the delete and
+	// rolls the purge back, leaving the account for a later run
+	if err := tx.Unscoped().Delete(&models.User{}, userID).Error; err != nil {
+		return counts, err
+	}
+	return counts, nil
+}
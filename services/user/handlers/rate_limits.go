@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type UpdateRateLimitTierRequest struct {
+	Tier models.RateLimitTier `json:"tier" validate:"required"` // standard, power_seller or partner
+}
+
+// @Summary Set user rate limit tier
+// @Description Set the request allowance of a user's tokens and API keys. API keys use the new tier straight away, tokens from their next refresh (admin only).
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body UpdateRateLimitTierRequest true "Tier"
+// @Success 200 {object} utils.Response{data=models.User}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /users/{id}/rate-limit-tier [put]
+func (h *UserHandler) UpdateRateLimitTier(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid user ID")
+	}
+
+	adminID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req UpdateRateLimitTierRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+	if !req.Tier.IsValid() {
+		return utils.ValidationErrorResponse(c, "Tier must be standard, power_seller or partner")
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return utils.NotFoundResponse(c, "User not found")
+	}
+
+	if err := database.ActingAs(adminID).Model(&user).Update("rate_limit_tier", req.Tier).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update rate limit tier", err)
+	}
+
+	return utils.SuccessResponse(c, "Rate limit tier updated successfully", user)
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/jobs"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const jobPurgeRetention = "user.purge_retention"
+
+// Rows deleted per statement, so a large backlog doesn't lock a table for long
+const retentionBatchSize = 5000
+
+type StartRetentionPurgeRequest struct {
+	DryRun bool `json:"dry_run"` // Only report what would be deleted
+}
+
+type purgeRetentionPayload struct {
+	PurgeID uuid.UUID `json:"purge_id"`
+}
+
+// retentionRule selects the rows of one table past a retention period
+type retentionRule struct {
+	model interface{}
+	scope func(db *gorm.DB, cutoff time.Time) *gorm.DB
+}
+
+// @Summary Start retention purge
+// @Description Queue deletion of OTP login attempts, session history, notifications and profile audit history older than their configured retention periods. A dry run only reports how much would be deleted (admin only).
+// @Tags admin
+// @Security BearerAuth
+// @Param request body StartRetentionPurgeRequest false "Purge options"
+// @Success 202 {object} utils.Response{data=models.RetentionPurge}
+// @Router /admin/retention/purges [post]
+func (h *UserHandler) StartRetentionPurge(c *fiber.Ctx) error {
+	adminID, _ := c.Locals("user_id").(uuid.UUID)
+
+	var req StartRetentionPurgeRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return utils.ValidationErrorResponse(c, "Invalid request body")
+		}
+	}
+
+	purge, err := h.queueRetentionPurge(req.DryRun, &adminID)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to start retention purge", err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(utils.Response{
+		Success: true,
+		Message: "Retention purge started",
+		Data:    purge,
+	})
+}
+
+// @Summary Get retention purge reports
+// @Description Get the reports of past retention purges with how many rows each deleted per category, newest first (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} utils.Response{data=[]models.RetentionPurge}
+// @Router /admin/retention/purges [get]
+func (h *UserHandler) GetRetentionPurges(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+
+	if page < 1 {
+		page = 1
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	var purges []models.RetentionPurge
+	if err := database.DB.Order("created_at DESC").
+		Offset((page - 1) * limit).Limit(limit).
+		Find(&purges).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get retention purges", err)
+	}
+
+	return utils.SuccessResponse(c, "Retention purges retrieved successfully", purges)
+}
+
+// @Summary Get retention purge report
+// @Description Get the report of one retention purge (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Purge ID"
+// @Success 200 {object} utils.Response{data=models.RetentionPurge}
+// @Failure 404 {object} utils.Problem
+// @Router /admin/retention/purges/{id} [get]
+func (h *UserHandler) GetRetentionPurge(c *fiber.Ctx) error {
+	purgeID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid purge ID")
+	}
+
+	var purge models.RetentionPurge
+	if err := database.DB.First(&purge, purgeID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Retention purge not found")
+	}
+
+	return utils.SuccessResponse(c, "Retention purge retrieved successfully", purge)
+}
+
+// ScheduleRetentionPurge queues the daily retention purge, as a dry run when
+// configured. Run periodically by the scheduler.
+func (h *UserHandler) ScheduleRetentionPurge() {
+	if _, err := h.queueRetentionPurge(h.config.Retention.DryRun, nil); err != nil {
+		log.Printf("Failed to queue retention purge: %v", err)
+	}
+}
+
+// Helper functions
+
+// queueRetentionPurge creates the purge's report and queues the job that fills it in
+func (h *UserHandler) queueRetentionPurge(dryRun bool, triggeredBy *uuid.UUID) (*models.RetentionPurge, error) {
+	purge := models.RetentionPurge{
+		BaseModel:   models.BaseModel{ID: uuid.New()},
+		DryRun:      dryRun,
+		TriggeredBy: triggeredBy,
+	}
+	if err := database.DB.Create(&purge).Error; err != nil {
+		return nil, err
+	}
+	if err := jobs.Enqueue(jobPurgeRetention, purgeRetentionPayload{PurgeID: purge.ID}); err != nil {
+		return nil, err
+	}
+	return &purge, nil
+}
+
+// purgeRetention runs a queued retention purge and records what it deleted.
+// A category that fails keeps what it deleted so far and the rest still run.
+func (h *UserHandler) purgeRetention(payload []byte) error {
+	var job purgeRetentionPayload
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+
+	var purge models.RetentionPurge
+	if err := database.DB.First(&purge, job.PurgeID).Error; err != nil {
+		return err
+	}
+	if purge.CompletedAt != nil {
+		return nil
+	}
+
+	retention := h.config.Retention
+	categories := []struct {
+		name  string
+		days  int
+		count *int64
+		rules []retentionRule
+	}{
+		{"otp_logs", retention.OTPLogDays, &purge.OTPLogs, []retentionRule{
+			{&models.LoginEvent{}, func(db *gorm.DB, cutoff time.Time) *gorm.DB {
+				return db.Where("event = ? AND created_at < ?", models.LoginEventLogin, cutoff)
+			}},
+		}},
+		{"sessions", retention.SessionDays, &purge.Sessions, []retentionRule{
+			{&models.LoginEvent{}, func(db *gorm.DB, cutoff time.Time) *gorm.DB {
+				return db.Where("event IN ? AND created_at < ?", []models.LoginEventType{models.LoginEventLogout, models.LoginEventRefresh}, cutoff)
+			}},
+			// A device coming back after this long is treated as new again
+			{&models.UserDevice{}, func(db *gorm.DB, cutoff time.Time) *gorm.DB {
+				return db.Where("last_seen_at < ?", cutoff)
+			}},
+		}},
+		{"notifications", retention.NotificationDays, &purge.Notifications, []retentionRule{
+			{&models.Notification{}, func(db *gorm.DB, cutoff time.Time) *gorm.DB {
+				return db.Where("created_at < ? AND digest_pending = ?", cutoff, false)
+			}},
+		}},
+		{"audit_logs", retention.AuditLogDays, &purge.AuditLogs, []retentionRule{
+			{&models.UserChange{}, func(db *gorm.DB, cutoff time.Time) *gorm.DB {
+				return db.Where("created_at < ?", cutoff)
+			}},
+		}},
+	}
+
+	var failures []string
+	for _, category := range categories {
+		if category.days <= 0 {
+			continue
+		}
+		cutoff := time.Now().AddDate(0, 0, -category.days)
+
+		for _, rule := range category.rules {
+			rows, err := applyRetentionRule(rule, cutoff, purge.DryRun)
+			*category.count += rows
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", category.name, err))
+				break
+			}
+		}
+	}
+
+	now := time.Now()
+	purge.Errors = strings.Join(failures, "\n")
+	purge.CompletedAt = &now
+	if err := database.DB.Save(&purge).Error; err != nil {
+		return err
+	}
+
+	log.Printf("Retention purge %s (dry run: %t): %d OTP logs, %d sessions, %d notifications, %d audit logs",
+		purge.ID, purge.DryRun, purge.OTPLogs, purge.Sessions, purge.Notifications, purge.AuditLogs)
+	return nil
+}
+
+// applyRetentionRule deletes the rule's rows older than cutoff for good, in
+// batches, and returns how many went. A dry run only counts them.
+func applyRetentionRule(rule retentionRule, cutoff time.Time, dryRun bool) (int64, error) {
+	if dryRun {
+		var count int64
+		err := rule.scope(database.DB.Unscoped().Model(rule.model), cutoff).Count(&count).Error
+		return count, err
+	}
+
+	var deleted int64
+	for {
+		batch := rule.scope(database.DB.Unscoped().Model(rule.model), cutoff).Select("id").Limit(retentionBatchSize)
+		result := database.DB.Unscoped().Where("id IN (?)", batch).Delete(rule.model)
+		if result.Error != nil {
+			return deleted, result.Error
+		}
+		deleted += result.RowsAffected
+		if result.RowsAffected < retentionBatchSize {
+			return deleted, nil
+		}
+	}
+}
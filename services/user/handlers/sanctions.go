@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SanctionRequest struct {
+	Reason    string     `json:"reason" validate:"required"`
+	ExpiresAt *time.Time `json:"expires_at"` // Suspensions only; omit for an open-ended suspension
+}
+
+type ReinstateRequest struct {
+	Reason string `json:"reason"`
+}
+
+// @Summary Suspend user
+// @Description Suspend an account with a reason and optional expiry. All sessions are revoked and new logins are refused until it ends (admin only).
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body SanctionRequest true "Suspension"
+// @Success 200 {object} utils.Response{data=models.UserSanction}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /users/{id}/suspend [post]
+func (h *UserHandler) SuspendUser(c *fiber.Ctx) error {
+	return h.sanctionUser(c, models.AccountSuspended)
+}
+
+// @Summary Ban user
+// @Description Permanently ban an account with a reason. All sessions are revoked and a seller's listings are deactivated (admin only).
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body SanctionRequest true "Ban"
+// @Success 200 {object} utils.Response{data=models.UserSanction}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /users/{id}/ban [post]
+func (h *UserHandler) BanUser(c *fiber.Ctx) error {
+	return h.sanctionUser(c, models.AccountBanned)
+}
+
+// @Summary Reinstate user
+// @Description Lift a suspension or ban. Deactivated listings stay inactive until the seller re-lists them (admin only).
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body ReinstateRequest false "Reinstatement"
+// @Success 200 {object} utils.Response{data=models.UserSanction}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /users/{id}/reinstate [post]
+func (h *UserHandler) ReinstateUser(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid user ID")
+	}
+
+	adminID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req ReinstateRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return utils.ValidationErrorResponse(c, "Invalid request body")
+		}
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return utils.NotFoundResponse(c, "User not found")
+	}
+
+	if user.AccountStatus == models.AccountActive || user.AccountStatus == "" {
+		return utils.ValidationErrorResponse(c, "User is not suspended or banned")
+	}
+
+	sanction := models.UserSanction{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		UserID:    userID,
+		AdminID:   adminID,
+		Status:    models.AccountActive,
+		Reason:    req.Reason,
+	}
+
+	err = database.ActingAs(adminID).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"account_status":    models.AccountActive,
+			"suspended_until":   nil,
+			"suspension_reason": "",
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&sanction).Error
+	})
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to reinstate user", err)
+	}
+
+	redis.ClearUserSuspended(userID.String())
+
+	notifications.Send(userID, models.NotificationSecurity, "Account reinstated",
+		"Your account has been reinstated. You can log in again.")
+
+	return utils.SuccessResponse(c, "User reinstated successfully", sanction)
+}
+
+// @Summary Get user sanctions
+// @Description Get the suspension and ban history of an account (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} utils.Response{data=[]models.UserSanction}
+// @Router /users/{id}/sanctions [get]
+func (h *UserHandler) GetUserSanctions(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid user ID")
+	}
+
+	var sanctions []models.UserSanction
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&sanctions).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get sanctions", err)
+	}
+
+	return utils.SuccessResponse(c, "Sanctions retrieved successfully", sanctions)
+}
+
+// Helper functions
+
+func (h *UserHandler) sanctionUser(c *fiber.Ctx, status models.AccountStatus) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid user ID")
+	}
+
+	adminID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	if userID == adminID {
+		return utils.ValidationErrorResponse(c, "You cannot sanction your own account")
+	}
+
+	var req SanctionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.Reason == "" {
+		return utils.ValidationErrorResponse(c, "Reason is required")
+	}
+	if status == models.AccountBanned {
+		req.ExpiresAt = nil
+	} else if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return utils.ValidationErrorResponse(c, "Expiry must be in the future")
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return utils.NotFoundResponse(c, "User not found")
+	}
+
+	if user.AccountStatus == models.AccountBanned {
+		return utils.ValidationErrorResponse(c, "User is already banned")
+	}
+
+	sanction := models.UserSanction{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		UserID:    userID,
+		AdminID:   adminID,
+		Status:    status,
+		Reason:    req.Reason,
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	err = database.ActingAs(adminID).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"account_status":    status,
+			"suspended_until":   req.ExpiresAt,
+			"suspension_reason": req.Reason,
+		}).Error; err != nil {
+			return err
+		}
+
+		// Banned sellers stop selling straight away
+		if status == models.AccountBanned && user.HasRole(models.RoleSeller) {
+			if err := tx.Model(&models.Product{}).Where("seller_id = ? AND is_active = ?", userID, true).
+				Update("is_active", false).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&sanction).Error
+	})
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to update account status", err)
+	}
+
+	var ttl time.Duration
+	if req.ExpiresAt != nil {
+		ttl = time.Until(*req.ExpiresAt)
+	}
+	redis.MarkUserSuspended(userID.String(), ttl)
+	utils.RevokeTokens(userID)
+
+	message := fmt.Sprintf("Your account has been banned: %s", req.Reason)
+	if status == models.AccountSuspended {
+		message = fmt.Sprintf("Your account has been suspended: %s", req.Reason)
+		if req.ExpiresAt != nil {
+			message += fmt.Sprintf(" The suspension ends on %s.", req.ExpiresAt.Format("2006-01-02 15:04"))
+		}
+	}
+	notifications.Send(userID, models.NotificationSecurity, "Account "+string(status), message)
+
+	return utils.SuccessResponse(c, "Account "+string(status)+" successfully", sanction)
+}
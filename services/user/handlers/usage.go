@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"sort"
+
+	"playful-marketplace/shared/middleware"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Endpoints listed in the usage summary
+const topEndpointsLimit = 10
+
+type EndpointUsage struct {
+	Endpoint string `json:"endpoint"`
+	Calls    int64  `json:"calls"`
+}
+
+type UsageResponse struct {
+	UserID       uuid.UUID            `json:"user_id"`
+	TotalCalls   int64                `json:"total_calls"`
+	TopEndpoints []EndpointUsage      `json:"top_endpoints"` // Most called over the whole window
+	LastSeen     *models.APILastSeen  `json:"last_seen"`
+	Days         []models.APIUsageDay `json:"days"` // Oldest first
+}
+
+// @Summary Get API usage
+// @Description Get a user's API calls per day and endpoint, and the last endpoint they called (own account or admin)
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param days query int false "Days to include, up to 30" default(7)
+// @Success 200 {object} utils.Response{data=UsageResponse}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Router /users/{id}/usage [get]
+func (h *UserHandler) GetUsage(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid user ID")
+	}
+
+	if !h.canAccessAccount(c, userID, middleware.PermUsersRead) {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only view your own API usage", nil)
+	}
+
+	days := c.QueryInt("days", 7)
+	if days < 1 || days > 30 {
+		days = 7
+	}
+
+	usage, lastSeen, err := redis.GetAPIUsage(userID.String(), days)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get API usage", err)
+	}
+
+	response := UsageResponse{
+		UserID:   userID,
+		LastSeen: lastSeen,
+		Days:     usage,
+	}
+
+	totals := make(map[string]int64)
+	for _, day := range usage {
+		response.TotalCalls += day.Calls
+		for endpoint, calls := range day.Endpoints {
+			totals[endpoint] += calls
+		}
+	}
+	response.TopEndpoints = topEndpoints(totals, topEndpointsLimit)
+
+	return utils.SuccessResponse(c, "API usage retrieved successfully", response)
+}
+
+// Helper functions
+
+func topEndpoints(totals map[string]int64, limit int) []EndpointUsage {
+	endpoints := make([]EndpointUsage, 0, len(totals))
+	for endpoint, calls := range totals {
+		endpoints = append(endpoints, EndpointUsage{Endpoint: endpoint, Calls: calls})
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].Calls != endpoints[j].Calls {
+			return endpoints[i].Calls > endpoints[j].Calls
+		}
+		return endpoints[i].Endpoint < endpoints[j].Endpoint
+	})
+	if len(endpoints) > limit {
+		endpoints = endpoints[:limit]
+	}
+	return endpoints
+}
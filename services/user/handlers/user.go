@@ -1,9 +1,15 @@
 package handlers
 
 import (
+	"strings"
+
 	"playful-marketplace/shared/config"
 	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/jobs"
+	"playful-marketplace/shared/middleware"
 	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/storage"
+	"playful-marketplace/shared/users"
 	"playful-marketplace/shared/utils"
 
 	"github.com/gofiber/fiber/v2"
@@ -11,12 +17,19 @@ import (
 )
 
 type UserHandler struct {
-	config *config.Config
+	config  *config.Config
+	storage storage.Storage
 }
 
 type UpdateUserRequest struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	Name            string `json:"name"`
+	Email           string `json:"email"` // Starts an email change, applied once confirmed from the new address
+	MarketingOptOut *bool  `json:"marketing_opt_out"` // Opt out of re-engagement campaigns
+
+	DigestFrequency models.DigestFrequency `json:"digest_frequency"` // immediate, daily or weekly delivery of non-urgent notifications
+
+	PreferredLanguage string `json:"preferred_language"` // en or am
+	DisplayCurrency   string `json:"display_currency"`   // The base currency or one with a configured rate
 }
 
 type UserProfileResponse struct {
@@ -28,17 +41,24 @@ type UserProfileResponse struct {
 
 func NewUserHandler(cfg *config.Config) *UserHandler {
 	return &UserHandler{
-		config: cfg,
+		config:  cfg,
+		storage: storage.New(cfg.Storage),
 	}
 }
 
+// RegisterJobs registers the handlers for the jobs this service enqueues
+func (h *UserHandler) RegisterJobs() {
+	jobs.Handle(jobPurgeAccounts, h.purgeAccounts)
+	jobs.Handle(jobPurgeRetention, h.purgeRetention)
+}
+
 // @Summary Get user profile
 // @Description Get user profile with XP, badges, and level information
 // @Tags users
 // @Security BearerAuth
 // @Param id path string true "User ID"
 // @Success 200 {object} utils.Response{data=UserProfileResponse}
-// @Failure 404 {object} utils.Response
+// @Failure 404 {object} utils.Problem
 // @Router /users/{id} [get]
 func (h *UserHandler) GetUserProfile(c *fiber.Ctx) error {
 	userIDParam := c.Params("id")
@@ -77,15 +97,15 @@ func (h *UserHandler) GetUserProfile(c *fiber.Ctx) error {
 }
 
 // @Summary Update user profile
-// @Description Update user profile information
+// @Description Update user profile information. Language and currency preferences apply to responses once the token is refreshed. A new email is applied once confirmed from that address.
 // @Tags users
 // @Security BearerAuth
 // @Param id path string true "User ID"
 // @Param request body UpdateUserRequest true "Update user request"
 // @Success 200 {object} utils.Response{data=models.User}
-// @Failure 400 {object} utils.Response
-// @Failure 403 {object} utils.Response
-// @Failure 404 {object} utils.Response
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
 // @Router /users/{id} [put]
 func (h *UserHandler) UpdateUserProfile(c *fiber.Ctx) error {
 	userIDParam := c.Params("id")
@@ -115,15 +135,52 @@ func (h *UserHandler) UpdateUserProfile(c *fiber.Ctx) error {
 	if req.Name != "" {
 		user.Name = req.Name
 	}
-	if req.Email != "" {
-		user.Email = req.Email
+	if req.MarketingOptOut != nil {
+		user.MarketingOptOut = *req.MarketingOptOut
+	}
+	if req.DigestFrequency != "" {
+		switch req.DigestFrequency {
+		case models.DigestImmediate, models.DigestDaily, models.DigestWeekly:
+			user.DigestFrequency = req.DigestFrequency
+		default:
+			return utils.ValidationErrorResponse(c, "Digest frequency must be immediate, daily or weekly")
+		}
+	}
+	if req.PreferredLanguage != "" {
+		if !utils.IsSupportedLanguage(req.PreferredLanguage) {
+			return utils.ValidationErrorResponse(c, "Preferred language must be en or am")
+		}
+		user.PreferredLanguage = req.PreferredLanguage
+	}
+	if req.DisplayCurrency != "" {
+		if !utils.IsSupportedCurrency(req.DisplayCurrency, h.config) {
+			return utils.ValidationErrorResponse(c, "Display currency is not supported")
+		}
+		user.DisplayCurrency = strings.ToUpper(req.DisplayCurrency)
+	}
+
+	// Email changes wait for confirmation from the new address
+	emailPending := false
+	if req.Email != "" && !strings.EqualFold(strings.TrimSpace(req.Email), user.Email) {
+		if err := h.beginEmailChange(&user, req.Email); err != nil {
+			return emailChangeErrorResponse(c, err)
+		}
+		emailPending = true
 	}
 
 	// Save changes
-	if err := database.DB.Save(&user).Error; err != nil {
+	if err := database.ActingAs(currentUserID).Save(&user).Error; err != nil {
 		return utils.InternalServerErrorResponse(c, "Failed to update user", err)
 	}
+	users.Invalidate(user.ID)
+	if req.MarketingOptOut != nil {
+		database.DB.Model(&models.NotificationPreferences{}).Where("user_id = ?", user.ID).
+			Update("marketing", !user.MarketingOptOut)
+	}
 
+	if emailPending {
+		return utils.SuccessResponse(c, "User profile updated, confirm the new email address to apply it", user)
+	}
 	return utils.SuccessResponse(c, "User profile updated successfully", user)
 }
 
@@ -135,7 +192,7 @@ func (h *UserHandler) UpdateUserProfile(c *fiber.Ctx) error {
 // @Param limit query int false "Number of transactions to return" default(20)
 // @Param offset query int false "Number of transactions to skip" default(0)
 // @Success 200 {object} utils.Response{data=[]models.XPTransaction}
-// @Failure 404 {object} utils.Response
+// @Failure 404 {object} utils.Problem
 // @Router /users/{id}/xp-history [get]
 func (h *UserHandler) GetXPHistory(c *fiber.Ctx) error {
 	userIDParam := c.Params("id")
@@ -177,7 +234,7 @@ func (h *UserHandler) GetXPHistory(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Param id path string true "User ID"
 // @Success 200 {object} utils.Response{data=[]models.UserBadge}
-// @Failure 404 {object} utils.Response
+// @Failure 404 {object} utils.Problem
 // @Router /users/{id}/badges [get]
 func (h *UserHandler) GetUserBadges(c *fiber.Ctx) error {
 	userIDParam := c.Params("id")
@@ -207,7 +264,7 @@ func (h *UserHandler) GetUserBadges(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Param id path string true "User ID"
 // @Success 200 {object} utils.Response{data=map[string]interface{}}
-// @Failure 404 {object} utils.Response
+// @Failure 404 {object} utils.Problem
 // @Router /users/{id}/stats [get]
 func (h *UserHandler) GetUserStats(c *fiber.Ctx) error {
 	userIDParam := c.Params("id")
@@ -274,7 +331,7 @@ func (h *UserHandler) GetUserStats(c *fiber.Ctx) error {
 // @Param limit query int false "Number of users to return" default(10)
 // @Param offset query int false "Number of users to skip" default(0)
 // @Success 200 {object} utils.Response{data=[]models.User}
-// @Failure 400 {object} utils.Response
+// @Failure 400 {object} utils.Problem
 // @Router /users/search [get]
 func (h *UserHandler) SearchUsers(c *fiber.Ctx) error {
 	query := c.Query("q")
@@ -300,3 +357,53 @@ func (h *UserHandler) SearchUsers(c *fiber.Ctx) error {
 
 	return utils.SuccessResponse(c, "Users found successfully", users)
 }
+
+// @Summary Get user login history
+// @Description Get login attempts for a user (own account or admin)
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param limit query int false "Number of events to return" default(20)
+// @Param offset query int false "Number of events to skip" default(0)
+// @Success 200 {object} utils.Response{data=[]models.LoginEvent}
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /users/{id}/login-history [get]
+func (h *UserHandler) GetLoginHistory(c *fiber.Ctx) error {
+	userIDParam := c.Params("id")
+	userID, err := uuid.Parse(userIDParam)
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid user ID")
+	}
+
+	// Users can only see their own login history unless they are an admin
+	currentUserID, _ := c.Locals("user_id").(uuid.UUID)
+	userRole, _ := c.Locals("user_role").(models.UserRole)
+	if currentUserID != userID && !middleware.HasPermission(userRole, middleware.PermUsersRead) {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only view your own login history", nil)
+	}
+
+	limit := c.QueryInt("limit", 20)
+	offset := c.QueryInt("offset", 0)
+
+	if limit > 100 {
+		limit = 100 // Cap at 100 for performance
+	}
+
+	// Check if user exists
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return utils.NotFoundResponse(c, "User not found")
+	}
+
+	var events []models.LoginEvent
+	if err := database.DB.Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&events).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get login history", err)
+	}
+
+	return utils.SuccessResponse(c, "Login history retrieved successfully", events)
+}
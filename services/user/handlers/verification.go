@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/middleware"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var errVerificationReviewed = errors.New("verification has already been reviewed")
+
+var verificationDocumentTypes = map[string]bool{
+	"national_id":           true,
+	"passport":              true,
+	"business_registration": true,
+}
+
+var verificationDocumentExtensions = map[string]string{
+	"image/jpeg":      "jpg",
+	"image/png":       "png",
+	"application/pdf": "pdf",
+}
+
+type SellerVerificationReviewRequest struct {
+	Decision string `json:"decision" validate:"required"` // approve or reject
+	Notes    string `json:"notes"`
+}
+
+type SellerVerificationListResponse struct {
+	Verifications []models.SellerVerification `json:"verifications"`
+	Total         int64                       `json:"total"`
+	Page          int                         `json:"page"`
+	Limit         int                         `json:"limit"`
+}
+
+// @Summary Submit seller verification
+// @Description Upload an identity document as multipart form field "document" (JPEG, PNG or PDF) with its "document_type" (national_id, passport or business_registration). An admin reviews it and approval earns the verified seller badge (own seller account only).
+// @Tags users
+// @Security BearerAuth
+// @Accept multipart/form-data
+// @Param id path string true "User ID"
+// @Param document_type formData string true "Document type"
+// @Param document formData file true "Identity document"
+// @Success 201 {object} utils.Response{data=models.SellerVerification}
+// @Failure 400 {object} utils.Problem
+// @Failure 403 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Router /users/{id}/verification [post]
+func (h *UserHandler) SubmitSellerVerification(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid user ID")
+	}
+
+	currentUserID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok || currentUserID != userID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only verify your own account", nil)
+	}
+	if role, _ := c.Locals("user_role").(models.UserRole); role != models.RoleSeller {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "Only sellers can be verified", nil)
+	}
+
+	documentType := c.FormValue("document_type")
+	if !verificationDocumentTypes[documentType] {
+		return utils.ValidationErrorResponse(c, "Document type must be national_id, passport or business_registration")
+	}
+
+	file, err := c.FormFile("document")
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Document file is required")
+	}
+
+	maxBytes := int64(h.config.Storage.MaxDocumentMB) << 20
+	if file.Size > maxBytes {
+		return utils.ValidationErrorResponse(c, fmt.Sprintf("Document must be at most %d MB", h.config.Storage.MaxDocumentMB))
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Failed to read document file")
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, maxBytes+1))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Failed to read document file")
+	}
+
+	// Trust the file's contents over the client's declared type
+	contentType := http.DetectContentType(data)
+	extension, ok := verificationDocumentExtensions[contentType]
+	if !ok {
+		return utils.ValidationErrorResponse(c, "Document must be a JPEG, PNG or PDF")
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return utils.NotFoundResponse(c, "User not found")
+	}
+	if user.SellerVerified {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "Your account is already verified", nil)
+	}
+
+	var count int64
+	database.DB.Model(&models.SellerVerification{}).
+		Where("user_id = ? AND status = ?", userID, models.SellerVerificationPending).Count(&count)
+	if count > 0 {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "You already have a verification under review", nil)
+	}
+
+	verification := models.SellerVerification{
+		BaseModel:    models.BaseModel{ID: uuid.New()},
+		UserID:       userID,
+		DocumentType: documentType,
+		Status:       models.SellerVerificationPending,
+	}
+
+	// Keyed by the verification ID so document URLs can't be guessed from the user ID
+	verification.DocumentURL, err = h.storage.Put(fmt.Sprintf("verifications/%s/%s.%s", userID, verification.ID, extension), contentType, data)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to store document", err)
+	}
+
+	if err := database.DB.Create(&verification).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to submit verification", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Verification submitted. We'll notify you once it has been reviewed.",
+		Data:    verification,
+	})
+}
+
+// @Summary Get seller verification
+// @Description Get a seller's most recent verification submission (own account or admin)
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} utils.Response{data=models.SellerVerification}
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /users/{id}/verification [get]
+func (h *UserHandler) GetSellerVerification(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid user ID")
+	}
+
+	if !h.canAccessAccount(c, userID, middleware.PermUsersRead) {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only view your own verification", nil)
+	}
+
+	var verification models.SellerVerification
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at DESC").First(&verification).Error; err != nil {
+		return utils.NotFoundResponse(c, "No seller verification found")
+	}
+
+	return utils.SuccessResponse(c, "Verification retrieved successfully", verification)
+}
+
+// @Summary Get seller verifications
+// @Description Get seller verifications awaiting review (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Param status query string false "Filter by status" default(pending)
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} utils.Response{data=SellerVerificationListResponse}
+// @Router /admin/seller-verifications [get]
+func (h *UserHandler) GetSellerVerifications(c *fiber.Ctx) error {
+	status := c.Query("status", string(models.SellerVerificationPending))
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+
+	if page < 1 {
+		page = 1
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	query := database.DB.Model(&models.SellerVerification{}).Where("status = ?", status)
+
+	var total int64
+	query.Count(&total)
+
+	var verifications []models.SellerVerification
+	if err := query.Preload("User").
+		Order("created_at ASC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&verifications).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get seller verifications", err)
+	}
+
+	response := SellerVerificationListResponse{
+		Verifications: verifications,
+		Total:         total,
+		Page:          page,
+		Limit:         limit,
+	}
+
+	return utils.SuccessResponse(c, "Seller verifications retrieved successfully", response)
+}
+
+// @Summary Review seller verification
+// @Description Approve or reject a seller verification (admin only). Approval marks the seller as verified on their products and storefront.
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Verification ID"
+// @Param request body SellerVerificationReviewRequest true "Review decision"
+// @Success 200 {object} utils.Response{data=models.SellerVerification}
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /admin/seller-verifications/{id} [post]
+func (h *UserHandler) ReviewSellerVerification(c *fiber.Ctx) error {
+	verificationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid verification ID")
+	}
+
+	adminID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return utils.UnauthorizedResponse(c, "User ID not found")
+	}
+
+	var req SellerVerificationReviewRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid request body")
+	}
+
+	if req.Decision != "approve" && req.Decision != "reject" {
+		return utils.ValidationErrorResponse(c, "Decision must be 'approve' or 'reject'")
+	}
+
+	var verification models.SellerVerification
+	if err := database.DB.First(&verification, verificationID).Error; err != nil {
+		return utils.NotFoundResponse(c, "Verification not found")
+	}
+
+	status := models.SellerVerificationRejected
+	if req.Decision == "approve" {
+		status = models.SellerVerificationApproved
+	}
+
+	err = database.ActingAs(adminID).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&verification).Where("status = ?", models.SellerVerificationPending).Updates(map[string]interface{}{
+			"status":      status,
+			"reviewed_by": adminID,
+			"reviewed_at": time.Now(),
+			"notes":       req.Notes,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errVerificationReviewed
+		}
+
+		if status != models.SellerVerificationApproved {
+			return nil
+		}
+		return tx.Model(&models.User{}).Where("id = ?", verification.UserID).Update("seller_verified", true).Error
+	})
+	if errors.Is(err, errVerificationReviewed) {
+		return utils.ValidationErrorResponse(c, "Verification has already been reviewed")
+	}
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to review verification", err)
+	}
+
+	if status == models.SellerVerificationApproved {
+		notifications.Send(verification.UserID, models.NotificationAccount, "You're a verified seller",
+			"Your identity was confirmed. Buyers now see the verified badge on your products and store.")
+	} else {
+		message := "We couldn't verify your identity from the document you submitted."
+		if req.Notes != "" {
+			message += " " + req.Notes
+		}
+		notifications.Send(verification.UserID, models.NotificationAccount, "Seller verification declined", message)
+	}
+
+	database.DB.First(&verification, verification.ID)
+
+	return utils.SuccessResponse(c, "Verification reviewed successfully", verification)
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/middleware"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// @Summary Get wishlist
+// @Description Get the products a user saved for later, most recent first (own account or admin)
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param limit query int false "Number of items to return" default(20)
+// @Param offset query int false "Number of items to skip" default(0)
+// @Success 200 {object} utils.Response{data=[]models.WishlistItem}
+// @Failure 403 {object} utils.Problem
+// @Router /users/{id}/wishlist [get]
+func (h *UserHandler) GetWishlist(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid user ID")
+	}
+
+	if !h.canAccessAccount(c, userID, middleware.PermUsersRead) {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only view your own wishlist", nil)
+	}
+
+	limit := c.QueryInt("limit", 20)
+	offset := c.QueryInt("offset", 0)
+
+	if limit > 100 {
+		limit = 100 // Cap at 100 for performance
+	}
+
+	var items []models.WishlistItem
+	if err := database.DB.Preload("Product").Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&items).Error; err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to get wishlist", err)
+	}
+
+	return utils.SuccessResponse(c, "Wishlist retrieved successfully", items)
+}
+
+// @Summary Save product to wishlist
+// @Description Save a product for later. The user is told when its price drops (own account only).
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param productId path string true "Product ID"
+// @Success 201 {object} utils.Response{data=models.WishlistItem}
+// @Failure 403 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /users/{id}/wishlist/{productId} [post]
+func (h *UserHandler) AddToWishlist(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid user ID")
+	}
+
+	currentUserID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok || currentUserID != userID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only change your own wishlist", nil)
+	}
+
+	productID, err := uuid.Parse(c.Params("productId"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid product ID")
+	}
+
+	var product models.Product
+	if err := database.DB.Where("id = ? AND is_active = ?", productID, true).First(&product).Error; err != nil {
+		return utils.NotFoundResponse(c, "Product not found")
+	}
+
+	item := models.WishlistItem{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		UserID:    userID,
+		ProductID: productID,
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&item)
+		if result.Error != nil {
+			return result.Error
+		}
+		// Saving the same product twice doesn't count it twice
+		if result.RowsAffected == 0 {
+			return tx.Where("user_id = ? AND product_id = ?", userID, productID).First(&item).Error
+		}
+		return tx.Model(&models.Product{}).Where("id = ?", productID).
+			Update("saved_count", gorm.Expr("saved_count + 1")).Error
+	})
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to save product", err)
+	}
+
+	item.Product = product
+	return c.Status(fiber.StatusCreated).JSON(utils.Response{
+		Success: true,
+		Message: "Product saved to wishlist",
+		Data:    item,
+	})
+}
+
+// @Summary Remove product from wishlist
+// @Description Remove a saved product (own account only)
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param productId path string true "Product ID"
+// @Success 200 {object} utils.Response
+// @Failure 403 {object} utils.Problem
+// @Router /users/{id}/wishlist/{productId} [delete]
+func (h *UserHandler) RemoveFromWishlist(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid user ID")
+	}
+
+	currentUserID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok || currentUserID != userID {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "You can only change your own wishlist", nil)
+	}
+
+	productID, err := uuid.Parse(c.Params("productId"))
+	if err != nil {
+		return utils.ValidationErrorResponse(c, "Invalid product ID")
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Where("user_id = ? AND product_id = ?", userID, productID).Delete(&models.WishlistItem{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Model(&models.Product{}).Where("id = ?", productID).
+			Update("saved_count", gorm.Expr("GREATEST(saved_count - 1, 0)")).Error
+	})
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to remove product", err)
+	}
+
+	return utils.SuccessResponse(c, "Product removed from wishlist", nil)
+}
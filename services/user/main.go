@@ -2,13 +2,17 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"playful-marketplace/services/user/handlers"
 	"playful-marketplace/services/user/routes"
 	"playful-marketplace/shared/config"
 	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/jobs"
 	"playful-marketplace/shared/middleware"
 	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/scheduler"
+	"playful-marketplace/shared/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/recover"
@@ -35,27 +39,28 @@ func main() {
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
-		AppName: "Playful Marketplace User Service",
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			code := fiber.StatusInternalServerError
-			if e, ok := err.(*fiber.Error); ok {
-				code = e.Code
-			}
-			return c.Status(code).JSON(fiber.Map{
-				"success": false,
-				"message": "Internal Server Error",
-				"error":   err.Error(),
-			})
-		},
+		AppName:      "Playful Marketplace User Service",
+		ErrorHandler: utils.FiberErrorHandler,
 	})
 
 	// Middleware
+	app.Use(middleware.TraceMiddleware())
 	app.Use(recover.New())
 	app.Use(middleware.CORSMiddleware())
 	app.Use(middleware.LoggingMiddleware())
+	app.Use(middleware.FaultMiddleware(cfg))
+	app.Use(middleware.MaintenanceMiddleware(cfg, "user"))
+	app.Use(middleware.RateLimitMiddleware(cfg))
+	app.Use(middleware.UsageMiddleware())
 
 	// Initialize handlers
 	userHandler := handlers.NewUserHandler(cfg)
+	userHandler.RegisterJobs()
+	jobs.Start(cfg.Jobs)
+
+	// Background jobs
+	scheduler.Every("account-purge", 24*time.Hour, userHandler.ScheduleAccountPurge)
+	scheduler.Every("retention-purge", 24*time.Hour, userHandler.ScheduleRetentionPurge)
 
 	// Health check
 	app.Get("/health", func(c *fiber.Ctx) error {
@@ -15,7 +15,67 @@ func SetupUserRoutes(api fiber.Router, userHandler *handlers.UserHandler, cfg *c
 	users.Get("/search", userHandler.SearchUsers)
 	users.Get("/:id", userHandler.GetUserProfile)
 	users.Put("/:id", userHandler.UpdateUserProfile)
+	users.Post("/:id/email", userHandler.RequestEmailChange)
+	users.Post("/:id/email/confirm", userHandler.ConfirmEmailChange)
+	users.Delete("/:id/email", userHandler.CancelEmailChange)
+	users.Post("/:id/avatar", userHandler.UploadAvatar)
 	users.Get("/:id/xp-history", userHandler.GetXPHistory)
 	users.Get("/:id/badges", userHandler.GetUserBadges)
 	users.Get("/:id/stats", userHandler.GetUserStats)
+	users.Get("/:id/analytics", userHandler.GetSpendingAnalytics)
+	users.Get("/:id/activity", userHandler.GetActivity)
+	users.Get("/:id/login-history", userHandler.GetLoginHistory)
+	users.Get("/:id/usage", userHandler.GetUsage)
+	users.Get("/:id/notification-preferences", userHandler.GetNotificationPreferences)
+	users.Put("/:id/notification-preferences", userHandler.UpdateNotificationPreferences)
+
+	// Saved products
+	users.Get("/:id/wishlist", userHandler.GetWishlist)
+	users.Post("/:id/wishlist/:productId", userHandler.AddToWishlist)
+	users.Delete("/:id/wishlist/:productId", userHandler.RemoveFromWishlist)
+
+	// Phone numbers saved for mobile payments, verified by a code sent to each
+	users.Get("/:id/payment-phones", userHandler.GetPaymentPhones)
+	users.Post("/:id/payment-phones", userHandler.AddPaymentPhone)
+	users.Post("/:id/payment-phones/:phoneId/verify", userHandler.VerifyPaymentPhone)
+	users.Put("/:id/payment-phones/:phoneId", userHandler.UpdatePaymentPhone)
+	users.Delete("/:id/payment-phones/:phoneId", userHandler.DeletePaymentPhone)
+
+	// Seller identity verification
+	users.Post("/:id/verification", userHandler.SubmitSellerVerification)
+	users.Get("/:id/verification", userHandler.GetSellerVerification)
+
+	// Account deletion and data export
+	users.Delete("/:id/account", userHandler.DeleteAccount)
+	users.Get("/:id/export", userHandler.ExportAccount)
+
+	// Admin suspensions and bans
+	usersWrite := middleware.PermissionMiddleware(middleware.PermUsersWrite)
+	users.Post("/:id/suspend", usersWrite, userHandler.SuspendUser)
+	users.Post("/:id/ban", usersWrite, userHandler.BanUser)
+	users.Post("/:id/reinstate", usersWrite, userHandler.ReinstateUser)
+	users.Get("/:id/sanctions", middleware.PermissionMiddleware(middleware.PermUsersRead), userHandler.GetUserSanctions)
+
+	// Admin request allowances for heavy API users
+	users.Put("/:id/rate-limit-tier", usersWrite, userHandler.UpdateRateLimitTier)
+
+	// Audit history of profile changes for support and disputes
+	users.Get("/:id/changes", middleware.PermissionMiddleware(middleware.PermUsersRead), userHandler.GetUserChanges)
+
+	// Admin review of seller verifications
+	admin := api.Group("/admin/seller-verifications", middleware.AuthMiddleware(cfg), middleware.PermissionMiddleware(middleware.PermUsersRead))
+	admin.Get("/", userHandler.GetSellerVerifications)
+	admin.Post("/:id", usersWrite, userHandler.ReviewSellerVerification)
+
+	// Purging of long-deleted accounts
+	purges := api.Group("/admin/accounts/purges", middleware.AuthMiddleware(cfg), middleware.PermissionMiddleware(middleware.PermUsersRead))
+	purges.Get("/", userHandler.GetAccountPurges)
+	purges.Get("/:id", userHandler.GetAccountPurge)
+	purges.Post("/", usersWrite, userHandler.StartAccountPurge)
+
+	// Deleting logs and history past their retention period
+	retention := api.Group("/admin/retention/purges", middleware.AuthMiddleware(cfg), middleware.PermissionMiddleware(middleware.PermUsersRead))
+	retention.Get("/", userHandler.GetRetentionPurges)
+	retention.Get("/:id", userHandler.GetRetentionPurge)
+	retention.Post("/", usersWrite, userHandler.StartRetentionPurge)
 }
@@ -0,0 +1,41 @@
+package activity
+
+import (
+	"log"
+	"strings"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+
+	"github.com/google/uuid"
+)
+
+// Record adds an event to the user's activity timeline. The timeline is
+// best-effort, so failures are logged rather than failing the action that
+// caused them.
+func Record(userID uuid.UUID, activityType models.ActivityType, title, reference string) {
+	event := models.ActivityEvent{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		UserID:    userID,
+		Type:      activityType,
+		Title:     title,
+		Reference: reference,
+	}
+	if err := database.DB.Create(&event).Error; err != nil {
+		log.Printf("Failed to record %s activity for user %s: %v", activityType, userID, err)
+	}
+}
+
+// BadgeEarned records a badge award
+func BadgeEarned(userID uuid.UUID, badge *models.Badge) {
+	Record(userID, models.ActivityBadgeEarned, "Earned the "+badge.Name+" badge", badge.ID.String())
+}
+
+// LevelUp records the user reaching a new level
+func LevelUp(userID uuid.UUID, level models.UserLevel) {
+	name := string(level)
+	if name != "" {
+		name = strings.ToUpper(name[:1]) + name[1:]
+	}
+	Record(userID, models.ActivityLevelUp, "Reached "+name+" level", "")
+}
@@ -0,0 +1,137 @@
+package analytics
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Funnel events, in the order buyers move through them
+const (
+	EventImpression = "impression"
+	EventView       = "view"
+	EventAddToCart  = "add_to_cart"
+	EventPurchase   = "purchase"
+)
+
+// Daily counter column for each event
+var eventColumns = map[string]string{
+	EventImpression: "impressions",
+	EventView:       "views",
+	EventAddToCart:  "add_to_carts",
+	EventPurchase:   "purchases",
+}
+
+// IsEvent reports whether the name is a known funnel event.
+func IsEvent(event string) bool {
+	_, ok := eventColumns[event]
+	return ok
+}
+
+// Track counts a funnel event for each product in the background. It is
+// safe to call from request handlers; failures are logged and dropped.
+func Track(event string, productIDs ...uuid.UUID) {
+	column, ok := eventColumns[event]
+	if !ok || len(productIDs) == 0 {
+		return
+	}
+
+	go func() {
+		if err := increment(column, productIDs); err != nil {
+			log.Printf("Failed to track %s for %d product(s): %v", event, len(productIDs), err)
+		}
+	}()
+}
+
+// Longest query kept, longer ones are truncated
+const maxSearchQueryLength = 100
+
+// NormalizeQuery lowercases a search query and collapses its whitespace so
+// equivalent searches are counted together.
+func NormalizeQuery(query string) string {
+	query = strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	if len(query) > maxSearchQueryLength {
+		query = strings.TrimSpace(query[:maxSearchQueryLength])
+	}
+	return query
+}
+
+// TrackSearch counts a search and how many products it found in the
+// background. Failures are logged and dropped.
+func TrackSearch(query string, results int64) {
+	query = NormalizeQuery(query)
+	if query == "" {
+		return
+	}
+
+	go func() {
+		if err := incrementSearch(query, results); err != nil {
+			log.Printf("Failed to track search %q: %v", query, err)
+		}
+	}()
+}
+
+func incrementSearch(query string, results int64) error {
+	zeroResults := 0
+	if results == 0 {
+		zeroResults = 1
+	}
+
+	stat := map[string]interface{}{
+		"id":           uuid.New(),
+		"query":        query,
+		"date":         time.Now().UTC().Truncate(24 * time.Hour),
+		"searches":     1,
+		"zero_results": zeroResults,
+		"last_results": results,
+		"created_at":   time.Now(),
+		"updated_at":   time.Now(),
+	}
+
+	return database.DB.Model(&models.SearchStat{}).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "query"}, {Name: "date"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "searches"}, Value: gorm.Expr("search_stats.searches + 1")},
+			{Column: clause.Column{Name: "zero_results"}, Value: gorm.Expr("search_stats.zero_results + EXCLUDED.zero_results")},
+			{Column: clause.Column{Name: "last_results"}, Value: gorm.Expr("EXCLUDED.last_results")},
+			{Column: clause.Column{Name: "updated_at"}, Value: gorm.Expr("EXCLUDED.updated_at")},
+		},
+	}).Create(stat).Error
+}
+
+func increment(column string, productIDs []uuid.UUID) error {
+	day := time.Now().UTC().Truncate(24 * time.Hour)
+
+	// The same product may appear more than once in a batch
+	counts := make(map[uuid.UUID]int)
+	for _, productID := range productIDs {
+		counts[productID]++
+	}
+
+	stats := make([]map[string]interface{}, 0, len(counts))
+	for productID, count := range counts {
+		stats = append(stats, map[string]interface{}{
+			"id":         uuid.New(),
+			"product_id": productID,
+			"date":       day,
+			column:       count,
+			"created_at": time.Now(),
+			"updated_at": time.Now(),
+		})
+	}
+
+	return database.DB.Model(&models.ProductFunnelStat{}).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "product_id"}, {Name: "date"}},
+		DoUpdates: clause.Set{{
+			Column: clause.Column{Name: column},
+			Value:  gorm.Expr("product_funnel_stats." + column + " + EXCLUDED." + column),
+		}},
+	}).Create(stats).Error
+}
@@ -0,0 +1,139 @@
+package analytics
+
+import (
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+)
+
+// Cohort is the customers who signed up in one month and what they have
+// bought since. Purchases are orders with a completed payment.
+type Cohort struct {
+	Month            time.Time `json:"month"`             // Signup month
+	Customers        int64     `json:"customers"`         // Accounts created in the month, without guest checkouts
+	Purchasers       int64     `json:"purchasers"`        // Customers with a purchase
+	RepeatPurchasers int64     `json:"repeat_purchasers"` // Customers with two or more purchases
+	RepeatRate       float64   `json:"repeat_rate"`       // Repeat purchasers as a fraction of purchasers
+	Orders           int64     `json:"orders"`
+	Revenue          float64   `json:"revenue"`
+	LTV              float64   `json:"ltv"` // Revenue per customer to date
+
+	// Indexed by months since signup, the signup month first
+	Retention     []float64 `json:"retention"`      // Fraction of customers purchasing in the month
+	CumulativeLTV []float64 `json:"cumulative_ltv"` // Revenue per customer by the end of the month
+}
+
+// cohortSize is a cohort's customer and purchaser counts
+type cohortSize struct {
+	Month            time.Time
+	Customers        int64
+	Purchasers       int64
+	RepeatPurchasers int64
+}
+
+// cohortMonth is a cohort's purchases in one month since signup
+type cohortMonth struct {
+	Month   time.Time
+	Offset  int
+	Buyers  int64
+	Orders  int64
+	Revenue float64
+}
+
+// Cohorts groups customers who signed up from the month of from until end
+// into monthly cohorts, with their retention and lifetime value up to now.
+func Cohorts(from, end time.Time) ([]Cohort, error) {
+	from = time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	var sizes []cohortSize
+	if err := database.DB.Raw(`
+		SELECT date_trunc('month', users.created_at) AS month,
+			COUNT(*) AS customers,
+			COUNT(*) FILTER (WHERE purchases.orders >= 1) AS purchasers,
+			COUNT(*) FILTER (WHERE purchases.orders >= 2) AS repeat_purchasers
+		FROM users
+		LEFT JOIN (
+			SELECT orders.buyer_id, COUNT(*) AS orders
+			FROM orders
+			JOIN payments ON payments.order_id = orders.id AND payments.status = ?
+			WHERE orders.deleted_at IS NULL
+			GROUP BY orders.buyer_id
+		) purchases ON purchases.buyer_id = users.id
+		WHERE users.created_at >= ? AND users.created_at < ? AND users.role <> ? AND users.deleted_at IS NULL
+		GROUP BY month
+		ORDER BY month ASC`,
+		models.PaymentCompleted, from, end, models.RoleGuest).Scan(&sizes).Error; err != nil {
+		return nil, err
+	}
+
+	var months []cohortMonth
+	if err := database.DB.Raw(`
+		SELECT date_trunc('month', users.created_at) AS month,
+			((EXTRACT(YEAR FROM orders.created_at) - EXTRACT(YEAR FROM users.created_at)) * 12 +
+				EXTRACT(MONTH FROM orders.created_at) - EXTRACT(MONTH FROM users.created_at))::int AS "offset",
+			COUNT(DISTINCT orders.buyer_id) AS buyers,
+			COUNT(*) AS orders,
+			SUM(orders.total_amount) AS revenue
+		FROM orders
+		JOIN users ON users.id = orders.buyer_id
+		JOIN payments ON payments.order_id = orders.id AND payments.status = ?
+		WHERE users.created_at >= ? AND users.created_at < ? AND users.role <> ?
+			AND users.deleted_at IS NULL AND orders.deleted_at IS NULL
+		GROUP BY month, "offset"`,
+		models.PaymentCompleted, from, end, models.RoleGuest).Scan(&months).Error; err != nil {
+		return nil, err
+	}
+
+	byMonth := make(map[time.Time][]cohortMonth, len(sizes))
+	for _, m := range months {
+		byMonth[m.Month.UTC()] = append(byMonth[m.Month.UTC()], m)
+	}
+
+	now := time.Now().UTC()
+	cohorts := make([]Cohort, 0, len(sizes))
+	for _, size := range sizes {
+		month := size.Month.UTC()
+		cohort := Cohort{
+			Month:            month,
+			Customers:        size.Customers,
+			Purchasers:       size.Purchasers,
+			RepeatPurchasers: size.RepeatPurchasers,
+		}
+		if size.Purchasers > 0 {
+			cohort.RepeatRate = float64(size.RepeatPurchasers) / float64(size.Purchasers)
+		}
+
+		// One entry for every month the cohort has existed, so far
+		elapsed := (now.Year()-month.Year())*12 + int(now.Month()-month.Month()) + 1
+		cohort.Retention = make([]float64, elapsed)
+		revenue := make([]float64, elapsed)
+		for _, m := range byMonth[month] {
+			cohort.Orders += m.Orders
+			cohort.Revenue += m.Revenue
+			if m.Offset < 0 || m.Offset >= elapsed {
+				continue
+			}
+			revenue[m.Offset] = m.Revenue
+			if size.Customers > 0 {
+				cohort.Retention[m.Offset] = float64(m.Buyers) / float64(size.Customers)
+			}
+		}
+
+		cohort.CumulativeLTV = make([]float64, elapsed)
+		var total float64
+		for i, r := range revenue {
+			total += r
+			if size.Customers > 0 {
+				cohort.CumulativeLTV[i] = total / float64(size.Customers)
+			}
+		}
+		if size.Customers > 0 {
+			cohort.LTV = cohort.Revenue / float64(size.Customers)
+		}
+
+		cohorts = append(cohorts, cohort)
+	}
+
+	return cohorts, nil
+}
@@ -0,0 +1,199 @@
+package analytics
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/config"
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// sellerCounts are a seller's order counts over the window
+type sellerCounts struct {
+	SellerID      uuid.UUID
+	Orders        int
+	ShipmentsDue  int
+	ShippedOnTime int
+	Cancellations int
+}
+
+// RecalculateSellerPerformance snapshots every seller's metrics for today
+// and warns sellers who have newly fallen below the standards. Running it
+// again the same day replaces the snapshot.
+func RecalculateSellerPerformance(sla config.SellerSLAConfig) error {
+	now := time.Now()
+	today := now.UTC().Truncate(24 * time.Hour)
+	since := now.AddDate(0, 0, -sla.WindowDays)
+	shipWithin := time.Duration(sla.ShipWithinHours) * time.Hour
+
+	// One row per order and seller, as a seller only sees their own items
+	var counts []sellerCounts
+	if err := database.DB.Raw(`
+		WITH seller_orders AS (
+			SELECT products.seller_id, orders.id, orders.created_at,
+				orders.status = ? OR bool_and(order_items.status = ?) AS cancelled,
+				MIN(shipments.shipped_at) AS shipped_at
+			FROM order_items
+			JOIN orders ON orders.id = order_items.order_id AND orders.deleted_at IS NULL
+			JOIN products ON products.id = order_items.product_id
+			LEFT JOIN shipments ON shipments.id = order_items.shipment_id
+			WHERE order_items.deleted_at IS NULL AND orders.created_at >= ? AND orders.status NOT IN ?
+			GROUP BY products.seller_id, orders.id, orders.created_at, orders.status
+		)
+		SELECT seller_id,
+			COUNT(*) AS orders,
+			COUNT(*) FILTER (WHERE NOT cancelled AND (shipped_at IS NOT NULL OR created_at < ?)) AS shipments_due,
+			COUNT(*) FILTER (WHERE NOT cancelled AND shipped_at <= created_at + ?::interval) AS shipped_on_time,
+			COUNT(*) FILTER (WHERE cancelled) AS cancellations
+		FROM seller_orders
+		GROUP BY seller_id`,
+		models.OrderCancelled, models.ItemCancelled, since,
+		[]models.OrderStatus{models.OrderPending, models.OrderOnHold},
+		now.Add(-shipWithin), fmt.Sprintf("%d hours", sla.ShipWithinHours),
+	).Scan(&counts).Error; err != nil {
+		return fmt.Errorf("failed to count seller orders: %w", err)
+	}
+
+	// Sellers measured before but without orders since drop back to new
+	measured := make(map[uuid.UUID]bool, len(counts))
+	for _, count := range counts {
+		measured[count.SellerID] = true
+	}
+	var previouslyMeasured []uuid.UUID
+	database.DB.Model(&models.SellerPerformance{}).Distinct("seller_id").
+		Where("date >= ?", since).Pluck("seller_id", &previouslyMeasured)
+	for _, sellerID := range previouslyMeasured {
+		if !measured[sellerID] {
+			counts = append(counts, sellerCounts{SellerID: sellerID})
+		}
+	}
+
+	var disputes []struct {
+		SellerID uuid.UUID
+		Count    int
+	}
+	if err := database.DB.Model(&models.OrderIssue{}).
+		Select("seller_id, COUNT(*) AS count").
+		Where("workflow = ? AND created_at >= ?", models.WorkflowDispute, since).
+		Group("seller_id").
+		Scan(&disputes).Error; err != nil {
+		return fmt.Errorf("failed to count disputes: %w", err)
+	}
+	disputesBySeller := make(map[uuid.UUID]int, len(disputes))
+	for _, d := range disputes {
+		disputesBySeller[d.SellerID] = d.Count
+	}
+
+	for _, count := range counts {
+		performance := scoreSeller(count, disputesBySeller[count.SellerID], sla)
+		performance.ID = uuid.New()
+		performance.Date = today
+
+		// Warn once when a seller falls below the standards, not every night
+		var previous models.SellerPerformance
+		wasWarned := database.DB.Where("seller_id = ? AND date < ?", count.SellerID, today).
+			Order("date DESC").First(&previous).Error == nil && previous.Standing == models.StandingWarning
+
+		if err := database.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "seller_id"}, {Name: "date"}},
+			UpdateAll: true,
+		}).Create(&performance).Error; err != nil {
+			log.Printf("Failed to save performance for seller %s: %v", count.SellerID, err)
+			continue
+		}
+
+		if performance.Standing == models.StandingWarning && !wasWarned {
+			warnSeller(&performance)
+		}
+	}
+
+	log.Printf("Recalculated performance for %d seller(s)", len(counts))
+	return nil
+}
+
+// LatestSellerPerformance returns the seller's most recent snapshot
+func LatestSellerPerformance(sellerID uuid.UUID) (*models.SellerPerformance, error) {
+	var performance models.SellerPerformance
+	if err := database.DB.Where("seller_id = ?", sellerID).Order("date DESC").First(&performance).Error; err != nil {
+		return nil, err
+	}
+	return &performance, nil
+}
+
+// PerksEligible reports whether the seller met every standard at the last
+// recalculation. Sellers not yet measured don't get perks.
+func PerksEligible(sellerID uuid.UUID) bool {
+	performance, err := LatestSellerPerformance(sellerID)
+	return err == nil && performance.PerksEligible
+}
+
+func scoreSeller(count sellerCounts, disputes int, sla config.SellerSLAConfig) models.SellerPerformance {
+	performance := models.SellerPerformance{
+		SellerID:      count.SellerID,
+		WindowDays:    sla.WindowDays,
+		Orders:        count.Orders,
+		ShipmentsDue:  count.ShipmentsDue,
+		ShippedOnTime: count.ShippedOnTime,
+		Cancellations: count.Cancellations,
+		Disputes:      disputes,
+		Standing:      models.StandingNew,
+	}
+
+	performance.OnTimeShippingRate = 1
+	if count.ShipmentsDue > 0 {
+		performance.OnTimeShippingRate = float64(count.ShippedOnTime) / float64(count.ShipmentsDue)
+	}
+	if count.Orders > 0 {
+		performance.CancellationRate = float64(count.Cancellations) / float64(count.Orders)
+		performance.DisputeRate = float64(disputes) / float64(count.Orders)
+	}
+
+	if count.Orders < sla.MinOrders {
+		return performance
+	}
+
+	var breaches []string
+	if performance.OnTimeShippingRate < sla.MinOnTimeRate {
+		breaches = append(breaches, "on_time_shipping")
+	}
+	if performance.CancellationRate > sla.MaxCancellationRate {
+		breaches = append(breaches, "cancellation")
+	}
+	if performance.DisputeRate > sla.MaxDisputeRate {
+		breaches = append(breaches, "dispute")
+	}
+
+	performance.Breaches = strings.Join(breaches, ",")
+	if len(breaches) == 0 {
+		performance.Standing = models.StandingGood
+		performance.PerksEligible = true
+	} else {
+		performance.Standing = models.StandingWarning
+	}
+	return performance
+}
+
+func warnSeller(performance *models.SellerPerformance) {
+	var missed []string
+	for _, breach := range strings.Split(performance.Breaches, ",") {
+		switch breach {
+		case "on_time_shipping":
+			missed = append(missed, fmt.Sprintf("%.0f%% of orders shipped on time", performance.OnTimeShippingRate*100))
+		case "cancellation":
+			missed = append(missed, fmt.Sprintf("%.0f%% of orders cancelled", performance.CancellationRate*100))
+		case "dispute":
+			missed = append(missed, fmt.Sprintf("%.0f%% of orders disputed", performance.DisputeRate*100))
+		}
+	}
+
+	notifications.Send(performance.SellerID, models.NotificationAccount, "Your seller performance needs attention",
+		fmt.Sprintf("Over the last %d days: %s. Sellers below our standards lose perks such as featured placement until they recover.",
+			performance.WindowDays, strings.Join(missed, ", ")))
+}
@@ -0,0 +1,73 @@
+package captcha
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"playful-marketplace/shared/config"
+)
+
+var (
+	ErrMissingToken = errors.New("captcha token is required")
+	ErrFailed       = errors.New("captcha verification failed")
+)
+
+// Server-side verification endpoints per provider. Both accept the same
+// form fields and answer with {"success": bool}.
+var verifyURLs = map[string]string{
+	"hcaptcha":  "https://api.hcaptcha.com/siteverify",
+	"turnstile": "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+}
+
+var client = &http.Client{Timeout: 5 * time.Second}
+
+// Enabled reports whether a CAPTCHA provider is configured
+func Enabled(cfg config.CaptchaConfig) bool {
+	return cfg.Provider != ""
+}
+
+// Verify checks a client's CAPTCHA token with the configured provider. It is
+// a no-op when no provider is configured.
+func Verify(cfg config.CaptchaConfig, token, remoteIP string) error {
+	if !Enabled(cfg) {
+		return nil
+	}
+	if token == "" {
+		return ErrMissingToken
+	}
+
+	verifyURL, ok := verifyURLs[cfg.Provider]
+	if !ok {
+		return fmt.Errorf("unknown captcha provider %q", cfg.Provider)
+	}
+
+	form := url.Values{
+		"secret":   {cfg.SecretKey},
+		"response": {token},
+		"remoteip": {remoteIP},
+	}
+	resp, err := client.PostForm(verifyURL, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("captcha provider returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if !result.Success {
+		return ErrFailed
+	}
+	return nil
+}
@@ -3,15 +3,40 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Database DatabaseConfig
-	Redis    RedisConfig
-	JWT      JWTConfig
-	Server   ServerConfig
+	Database    DatabaseConfig
+	Redis       RedisConfig
+	JWT         JWTConfig
+	Server      ServerConfig
+	Fraud       FraudConfig
+	Orders      OrderConfig
+	Admin       AdminConfig
+	Moderation  ModerationConfig
+	Payments    PaymentConfig
+	OAuth       OAuthConfig
+	Captcha     CaptchaConfig
+	Referrals   ReferralConfig
+	XP          XPConfig
+	Sessions    SessionConfig
+	Storage     StorageConfig
+	Jobs        JobsConfig
+	Locale      LocaleConfig
+	Faults      FaultConfig
+	Maintenance MaintenanceConfig
+	RateLimits  RateLimitConfig
+	Accounts    AccountConfig
+	Regions     RegionConfig
+	SellerSLA   SellerSLAConfig
+	Promotions  PromotionConfig
+	Feeds       FeedConfig
+	Retention   RetentionConfig
 }
 
 type DatabaseConfig struct {
@@ -28,11 +53,49 @@ type RedisConfig struct {
 	Port     string
 	Password string
 	DB       int
+
+	// Topology: "standalone" uses Host and Port, "sentinel" and "cluster"
+	// connect to the nodes in Addrs
+	Mode             string
+	Addrs            []string // host:port of each sentinel or cluster node
+	MasterName       string   // Sentinel master set name
+	SentinelPassword string
+	Username         string
+	TLS              bool
+	TLSSkipVerify    bool // Only for self-signed development certificates
+
+	// Pool tuning, zero values keep the client defaults
+	PoolSize        int
+	MinIdleConns    int
+	DialTimeout     time.Duration
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	MaxRetries      int           // Retries per command, which rides out failovers
+	MaxRetryBackoff time.Duration // Longest wait between retries
+
+	// Degraded mode: while Redis is unreachable, services keep serving with
+	// JWT-only auth and no caching instead of failing requests
+	Degrade             bool          // Also lets services start while Redis is down
+	HealthCheckInterval time.Duration // How often Redis is probed for an outage or a recovery
+
+	// In-process LRU in front of Redis for hot, read-mostly keys. Only keys
+	// starting with one of LocalCachePrefixes are held locally.
+	LocalCache         bool
+	LocalCacheSize     int           // Most entries held per process
+	LocalCacheTTL      time.Duration // Upper bound on staleness if an invalidation is missed
+	LocalCachePrefixes []string
 }
 
 type JWTConfig struct {
-	Secret     string
-	ExpiryHours int
+	Secret            string
+	ExpiryHours       int // Default token lifetime
+	GuestExpiryHours  int
+	BuyerExpiryHours  int // Per-role lifetimes, 0 falls back to ExpiryHours
+	SellerExpiryHours int
+	AdminExpiryHours  int
+
+	// Feature flags embedded in tokens, keyed by role
+	FeatureFlags map[string][]string
 }
 
 type ServerConfig struct {
@@ -40,6 +103,196 @@ type ServerConfig struct {
 	Host string
 }
 
+type FraudConfig struct {
+	ReviewThreshold int
+}
+
+type AdminConfig struct {
+	Phone string // Phone number of the seeded default admin, no admin is seeded when empty
+	Name  string
+}
+
+type ModerationConfig struct {
+	Mode        string // "block" rejects flagged content, "flag" stores it for review
+	WordListDir string // Directory of extra <lang>.txt word lists, one word per line
+	APIURL      string // Optional external moderation API
+	APIKey      string
+	VisionURL   string // Optional image moderation API
+}
+
+type PaymentConfig struct {
+	PlatformFeePercent float64 // Commission withheld from seller payouts
+}
+
+// CaptchaConfig selects the CAPTCHA checked on signup and OTP requests.
+// An empty provider disables the check, e.g. for local development.
+type CaptchaConfig struct {
+	Provider  string // "hcaptcha" or "turnstile"
+	SecretKey string
+}
+
+// OAuthConfig holds the client IDs ID tokens must be issued for. An empty
+// client ID disables that provider.
+type OAuthConfig struct {
+	GoogleClientID string
+	AppleClientID  string
+}
+
+// StorageConfig points uploads at an S3-compatible bucket. Without a bucket
+// files are written to LocalDir instead, e.g. for local development.
+type StorageConfig struct {
+	Endpoint          string // e.g. https://s3.eu-west-1.amazonaws.com or a MinIO URL
+	Region            string
+	Bucket            string
+	AccessKey         string
+	SecretKey         string
+	PublicURL         string // Base URL files are served from, defaults to the bucket URL
+	LocalDir          string
+	MaxAvatarMB       int
+	MaxDocumentMB     int // Seller verification documents
+	MaxProductImageMB int
+}
+
+// LocaleConfig sets the currency prices are stored in and the rates used to
+// hint display amounts in other currencies
+type LocaleConfig struct {
+	BaseCurrency  string
+	CurrencyRates map[string]float64 // Units of the currency per unit of BaseCurrency
+}
+
+// RegionConfig names the region requests default to and where deployments
+// keep the definitions of extra regions
+type RegionConfig struct {
+	Default string // Region code, e.g. ET
+	Dir     string // One JSON file per region, empty uses only the built-in regions
+}
+
+// FaultConfig injects latency, errors and unreachable dependencies into
+// matching routes, for resilience testing in staging. Never enable it in
+// production.
+type FaultConfig struct {
+	Enabled          bool
+	Key              string        // When set, only requests sending it in X-Fault-Injection are affected
+	Rules            []string      // "METHOD /path-prefix latency=200ms error_rate=0.1 blackhole=redis"
+	BlackholeTimeout time.Duration // How long a blackholed dependency hangs before failing
+}
+
+// MaintenanceConfig puts the service into maintenance mode from startup.
+// Admins can also toggle it at runtime, per service or for all of them.
+type MaintenanceConfig struct {
+	Enabled    bool
+	Message    string
+	RetryAfter time.Duration // Sent to clients in the Retry-After header
+}
+
+// RateLimitConfig caps requests per caller in each window. Signed-in users
+// get the limit of the tier on their account, anonymous callers are
+// counted per IP address.
+type RateLimitConfig struct {
+	Enabled     bool
+	Window      time.Duration
+	Anonymous   int
+	Standard    int
+	PowerSeller int
+	Partner     int
+}
+
+// Limit returns the requests allowed per window for the tier. An empty tier
+// is an anonymous caller.
+func (r RateLimitConfig) Limit(tier string) int {
+	switch tier {
+	case "":
+		return r.Anonymous
+	case "power_seller":
+		return r.PowerSeller
+	case "partner":
+		return r.Partner
+	default:
+		return r.Standard
+	}
+}
+
+// JobsConfig tunes the background job workers each service runs
+type JobsConfig struct {
+	Workers      int
+	PollInterval time.Duration // How often idle workers look for due jobs
+	MaxAttempts  int           // Runs before a job is marked failed
+	Timeout      time.Duration // After this a running job is assumed lost and picked up again
+}
+
+type AccountConfig struct {
+	PurgeAfterDays int  // Days a deleted account is kept before it's purged for good, 0 disables the purge
+	PurgeDryRun    bool // Scheduled purges only report what they would remove
+}
+
+// RetentionConfig sets how many days logs and history are kept before the
+// retention purge deletes them for good. 0 keeps them forever.
+type RetentionConfig struct {
+	OTPLogDays       int  // Phone login attempts
+	SessionDays      int  // Logout and refresh events, and devices not seen since
+	NotificationDays int  // Delivered notifications
+	AuditLogDays     int  // Profile change history
+	DryRun           bool // Scheduled purges only report what they would delete
+}
+
+type SessionConfig struct {
+	MaxActive   int    // Live sessions allowed per user, 0 means unlimited
+	LimitPolicy string // What a login over the limit does: evict_oldest or reject
+}
+
+type ReferralConfig struct {
+	SellerQualifyingSales int     // Delivered sales a referred seller needs before the referrer is rewarded
+	SellerCommissionShare float64 // Percent of the platform fees on those sales paid to the referrer, 0 disables it
+	SellerRewardXP        int     // XP awarded to the referrer, 0 disables it
+}
+
+// XPConfig sets the XP orders earn. Admins preview changes against past
+// orders before deploying them.
+type XPConfig struct {
+	BuyerPer100  float64 // XP a buyer earns per 100 spent on a delivered order
+	SellerPer100 float64 // XP a seller earns per 100 of delivered sales
+	FirstOrder   int     // XP for a buyer's first order, 0 disables it
+}
+
+// SellerSLAConfig sets the operational standards sellers are measured
+// against nightly. Sellers meeting all of them keep their perks; sellers
+// missing any are warned.
+type SellerSLAConfig struct {
+	WindowDays          int     // Days of orders each recalculation looks back over
+	MinOrders           int     // Orders in the window before a seller is measured at all
+	ShipWithinHours     int     // Hours after an order is placed by which it counts as shipped on time
+	MinOnTimeRate       float64 // Share of orders shipped on time, 0-1
+	MaxCancellationRate float64
+	MaxDisputeRate      float64
+}
+
+// PromotionConfig prices sponsored placements. Sellers prepay a budget
+// that each click is charged against.
+type PromotionConfig struct {
+	MinBidPerClick float64
+	MinBudget      float64
+	Slots          int // Sponsored products shown above search and category results
+}
+
+// FeedConfig sets up the sitemap and shopping catalog feed written to
+// storage for search engines and ad platforms
+type FeedConfig struct {
+	StorefrontURL  string        // Product links point at the web storefront
+	UpdateInterval time.Duration // How often changed products are written out
+}
+
+type OrderConfig struct {
+	AutoConfirmDays           int           // Days after delivery before receipt is confirmed automatically
+	ReviewRequestDelayHours   int           // Hours after receipt confirmation before asking for a review
+	CartReminderHours         int           // Hours a cart sits untouched before the buyer is reminded
+	CartReminderCouponPercent float64       // Discount on the final reminder's coupon, 0 disables it
+	LateAfterDays             int           // Days an undelivered order can be open before the buyer can report it late
+	IssueResponseHours        int           // Hours the seller has to respond to a reported issue
+	IssueResolutionHours      int           // Hours before a reported issue should be resolved
+	ProtectionAfterDays       int           // Days an undelivered order paid online can be open before buyer protection covers it
+	ReservationTTL            time.Duration // How long an unpaid order holds its stock before it's cancelled
+}
+
 func LoadConfig() *Config {
 	// Load .env file if it exists
 	if err := godotenv.Load(); err != nil {
@@ -59,16 +312,172 @@ func LoadConfig() *Config {
 			Host:     getEnv("REDIS_HOST", "localhost"),
 			Port:     getEnv("REDIS_PORT", "6379"),
 			Password: getEnv("REDIS_PASSWORD", ""),
-			DB:       0,
+			DB:       getEnvInt("REDIS_DB", 0),
+
+			Mode:             getEnv("REDIS_MODE", "standalone"),
+			Addrs:            getEnvList("REDIS_ADDRS"),
+			MasterName:       getEnv("REDIS_MASTER_NAME", ""),
+			SentinelPassword: getEnv("REDIS_SENTINEL_PASSWORD", ""),
+			Username:         getEnv("REDIS_USERNAME", ""),
+			TLS:              getEnvBool("REDIS_TLS", false),
+			TLSSkipVerify:    getEnvBool("REDIS_TLS_SKIP_VERIFY", false),
+
+			PoolSize:        getEnvInt("REDIS_POOL_SIZE", 0),
+			MinIdleConns:    getEnvInt("REDIS_MIN_IDLE_CONNS", 0),
+			DialTimeout:     getEnvDuration("REDIS_DIAL_TIMEOUT", 5*time.Second),
+			ReadTimeout:     getEnvDuration("REDIS_READ_TIMEOUT", 3*time.Second),
+			WriteTimeout:    getEnvDuration("REDIS_WRITE_TIMEOUT", 3*time.Second),
+			MaxRetries:      getEnvInt("REDIS_MAX_RETRIES", 5),
+			MaxRetryBackoff: getEnvDuration("REDIS_MAX_RETRY_BACKOFF", time.Second),
+
+			Degrade:             getEnvBool("REDIS_DEGRADE", true),
+			HealthCheckInterval: getEnvDuration("REDIS_HEALTH_CHECK_INTERVAL", 5*time.Second),
+
+			LocalCache:         getEnvBool("CACHE_LOCAL_ENABLED", false),
+			LocalCacheSize:     getEnvInt("CACHE_LOCAL_SIZE", 1000),
+			LocalCacheTTL:      getEnvDuration("CACHE_LOCAL_TTL", 30*time.Second),
+			LocalCachePrefixes: getEnvList("CACHE_LOCAL_PREFIXES"),
 		},
 		JWT: JWTConfig{
-			Secret:      getEnv("JWT_SECRET", "your-super-secret-jwt-key"),
-			ExpiryHours: 24,
+			Secret:            getEnv("JWT_SECRET", "your-super-secret-jwt-key"),
+			ExpiryHours:       getEnvInt("JWT_EXPIRY_HOURS", 24),
+			GuestExpiryHours:  getEnvInt("GUEST_TOKEN_EXPIRY_HOURS", 72),
+			BuyerExpiryHours:  getEnvInt("BUYER_TOKEN_EXPIRY_HOURS", 0),
+			SellerExpiryHours: getEnvInt("SELLER_TOKEN_EXPIRY_HOURS", 0),
+			AdminExpiryHours:  getEnvInt("ADMIN_TOKEN_EXPIRY_HOURS", 0),
+			FeatureFlags: map[string][]string{
+				"buyer":  getEnvList("FEATURE_FLAGS_BUYER"),
+				"seller": getEnvList("FEATURE_FLAGS_SELLER"),
+				"admin":  getEnvList("FEATURE_FLAGS_ADMIN"),
+			},
 		},
 		Server: ServerConfig{
 			Port: getEnv("PORT", "8080"),
 			Host: getEnv("HOST", "0.0.0.0"),
 		},
+		Fraud: FraudConfig{
+			ReviewThreshold: getEnvInt("FRAUD_REVIEW_THRESHOLD", 60),
+		},
+		Orders: OrderConfig{
+			AutoConfirmDays:           getEnvInt("ORDER_AUTO_CONFIRM_DAYS", 7),
+			ReviewRequestDelayHours:   getEnvInt("REVIEW_REQUEST_DELAY_HOURS", 24),
+			CartReminderHours:         getEnvInt("CART_REMINDER_HOURS", 24),
+			CartReminderCouponPercent: getEnvFloat("CART_REMINDER_COUPON_PERCENT", 5),
+			LateAfterDays:             getEnvInt("ORDER_LATE_AFTER_DAYS", 7),
+			IssueResponseHours:        getEnvInt("ORDER_ISSUE_RESPONSE_HOURS", 24),
+			IssueResolutionHours:      getEnvInt("ORDER_ISSUE_RESOLUTION_HOURS", 72),
+			ProtectionAfterDays:       getEnvInt("ORDER_PROTECTION_AFTER_DAYS", 14),
+			ReservationTTL:            getEnvDuration("ORDER_RESERVATION_TTL", 30*time.Minute),
+		},
+		Admin: AdminConfig{
+			Phone: getEnv("ADMIN_PHONE", ""),
+			Name:  getEnv("ADMIN_NAME", "Marketplace Admin"),
+		},
+		Moderation: ModerationConfig{
+			Mode:        getEnv("MODERATION_MODE", "block"),
+			WordListDir: getEnv("MODERATION_WORDLIST_DIR", ""),
+			APIURL:      getEnv("MODERATION_API_URL", ""),
+			APIKey:      getEnv("MODERATION_API_KEY", ""),
+			VisionURL:   getEnv("MODERATION_VISION_API_URL", ""),
+		},
+		Payments: PaymentConfig{
+			PlatformFeePercent: getEnvFloat("PLATFORM_FEE_PERCENT", 5),
+		},
+		OAuth: OAuthConfig{
+			GoogleClientID: getEnv("GOOGLE_CLIENT_ID", ""),
+			AppleClientID:  getEnv("APPLE_CLIENT_ID", ""),
+		},
+		Captcha: CaptchaConfig{
+			Provider:  getEnv("CAPTCHA_PROVIDER", ""),
+			SecretKey: getEnv("CAPTCHA_SECRET_KEY", ""),
+		},
+		Regions: RegionConfig{
+			Default: getEnv("DEFAULT_REGION", "ET"),
+			Dir:     getEnv("REGIONS_DIR", ""),
+		},
+		Accounts: AccountConfig{
+			PurgeAfterDays: getEnvInt("ACCOUNT_PURGE_AFTER_DAYS", 30),
+			PurgeDryRun:    getEnvBool("ACCOUNT_PURGE_DRY_RUN", false),
+		},
+		Retention: RetentionConfig{
+			OTPLogDays:       getEnvInt("RETENTION_OTP_LOG_DAYS", 90),
+			SessionDays:      getEnvInt("RETENTION_SESSION_DAYS", 180),
+			NotificationDays: getEnvInt("RETENTION_NOTIFICATION_DAYS", 365),
+			AuditLogDays:     getEnvInt("RETENTION_AUDIT_LOG_DAYS", 730),
+			DryRun:           getEnvBool("RETENTION_DRY_RUN", false),
+		},
+		Sessions: SessionConfig{
+			MaxActive:   getEnvInt("MAX_ACTIVE_SESSIONS", 0),
+			LimitPolicy: getEnv("SESSION_LIMIT_POLICY", "evict_oldest"),
+		},
+		Storage: StorageConfig{
+			Endpoint:          getEnv("STORAGE_ENDPOINT", ""),
+			Region:            getEnv("STORAGE_REGION", "us-east-1"),
+			Bucket:            getEnv("STORAGE_BUCKET", ""),
+			AccessKey:         getEnv("STORAGE_ACCESS_KEY", ""),
+			SecretKey:         getEnv("STORAGE_SECRET_KEY", ""),
+			PublicURL:         getEnv("STORAGE_PUBLIC_URL", ""),
+			LocalDir:          getEnv("STORAGE_LOCAL_DIR", "./uploads"),
+			MaxAvatarMB:       getEnvInt("MAX_AVATAR_MB", 2),
+			MaxDocumentMB:     getEnvInt("MAX_DOCUMENT_MB", 5),
+			MaxProductImageMB: getEnvInt("MAX_PRODUCT_IMAGE_MB", 5),
+		},
+		Jobs: JobsConfig{
+			Workers:      getEnvInt("JOB_WORKERS", 4),
+			PollInterval: getEnvDuration("JOB_POLL_INTERVAL", time.Second),
+			MaxAttempts:  getEnvInt("JOB_MAX_ATTEMPTS", 5),
+			Timeout:      getEnvDuration("JOB_TIMEOUT", 5*time.Minute),
+		},
+		Locale: LocaleConfig{
+			BaseCurrency:  getEnv("BASE_CURRENCY", "ETB"),
+			CurrencyRates: getEnvRates("CURRENCY_RATES"),
+		},
+		Faults: FaultConfig{
+			Enabled:          getEnvBool("FAULT_INJECTION_ENABLED", false),
+			Key:              getEnv("FAULT_INJECTION_KEY", ""),
+			Rules:            getEnvList("FAULT_INJECTION_RULES"),
+			BlackholeTimeout: getEnvDuration("FAULT_BLACKHOLE_TIMEOUT", 10*time.Second),
+		},
+		Maintenance: MaintenanceConfig{
+			Enabled:    getEnvBool("MAINTENANCE_MODE", false),
+			Message:    getEnv("MAINTENANCE_MESSAGE", "We're making some improvements. Browsing still works, please try again shortly."),
+			RetryAfter: getEnvDuration("MAINTENANCE_RETRY_AFTER", 5*time.Minute),
+		},
+		RateLimits: RateLimitConfig{
+			Enabled:     getEnvBool("RATE_LIMIT_ENABLED", true),
+			Window:      getEnvDuration("RATE_LIMIT_WINDOW", time.Minute),
+			Anonymous:   getEnvInt("RATE_LIMIT_ANONYMOUS", 60),
+			Standard:    getEnvInt("RATE_LIMIT_STANDARD", 120),
+			PowerSeller: getEnvInt("RATE_LIMIT_POWER_SELLER", 600),
+			Partner:     getEnvInt("RATE_LIMIT_PARTNER", 3000),
+		},
+		Referrals: ReferralConfig{
+			SellerQualifyingSales: getEnvInt("SELLER_REFERRAL_QUALIFYING_SALES", 5),
+			SellerCommissionShare: getEnvFloat("SELLER_REFERRAL_COMMISSION_SHARE", 20),
+			SellerRewardXP:        getEnvInt("SELLER_REFERRAL_REWARD_XP", 500),
+		},
+		SellerSLA: SellerSLAConfig{
+			WindowDays:          getEnvInt("SELLER_SLA_WINDOW_DAYS", 90),
+			MinOrders:           getEnvInt("SELLER_SLA_MIN_ORDERS", 10),
+			ShipWithinHours:     getEnvInt("SELLER_SLA_SHIP_WITHIN_HOURS", 48),
+			MinOnTimeRate:       getEnvFloat("SELLER_SLA_MIN_ON_TIME_RATE", 0.9),
+			MaxCancellationRate: getEnvFloat("SELLER_SLA_MAX_CANCELLATION_RATE", 0.05),
+			MaxDisputeRate:      getEnvFloat("SELLER_SLA_MAX_DISPUTE_RATE", 0.02),
+		},
+		Promotions: PromotionConfig{
+			MinBidPerClick: getEnvFloat("PROMOTION_MIN_BID_PER_CLICK", 2),
+			MinBudget:      getEnvFloat("PROMOTION_MIN_BUDGET", 100),
+			Slots:          getEnvInt("PROMOTION_SLOTS", 2),
+		},
+		Feeds: FeedConfig{
+			StorefrontURL:  getEnv("STOREFRONT_URL", "http://localhost:3000"),
+			UpdateInterval: getEnvDuration("FEED_UPDATE_INTERVAL", 15*time.Minute),
+		},
+		XP: XPConfig{
+			BuyerPer100:  getEnvFloat("BUYER_XP_PER_100", 5),
+			SellerPer100: getEnvFloat("SELLER_XP_PER_100", 10),
+			FirstOrder:   getEnvInt("FIRST_ORDER_XP", 50),
+		},
 	}
 }
 
@@ -78,3 +487,67 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if intValue, err := strconv.Atoi(value); err == nil {
+			return intValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvList reads a comma-separated list, skipping empty entries
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvRates reads comma-separated CODE:rate pairs such as "USD:0.0069",
+// skipping malformed entries
+func getEnvRates(key string) map[string]float64 {
+	rates := make(map[string]float64)
+	for _, item := range getEnvList(key) {
+		code, value, ok := strings.Cut(item, ":")
+		if !ok {
+			continue
+		}
+		if rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && rate > 0 {
+			rates[strings.ToUpper(strings.TrimSpace(code))] = rate
+		}
+	}
+	return rates
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvDuration reads a Go duration such as "500ms" or "5s"
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
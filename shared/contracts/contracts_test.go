@@ -0,0 +1,45 @@
+package contracts
+
+import (
+	"testing"
+
+	"playful-marketplace/shared/contracts/contracttest"
+	"playful-marketplace/shared/contracts/openapi"
+)
+
+func TestTypesMatchOpenAPI(t *testing.T) {
+	payment := openapi.Load(t, "payment")
+	payment.AssertType(t, payment.RequestSchema(t, "POST", "/payments/initiate"), InitiatePaymentRequest{})
+	payment.AssertType(t, payment.ResponseData(t, "POST", "/payments/initiate", 200), PaymentResponse{})
+
+	gamification := openapi.Load(t, "gamification")
+	gamification.AssertType(t, gamification.RequestSchema(t, "POST", "/gamify/xp"), AwardXPRequest{})
+}
+
+// Pacts may only record requests and responses the provider documents.
+// Interactions the provider rejects are checked by replaying them instead.
+func TestPactsMatchOpenAPI(t *testing.T) {
+	for _, provider := range []string{"payment", "gamification"} {
+		doc := openapi.Load(t, provider)
+		pact := contracttest.Load(t, "order", provider)
+
+		for _, interaction := range pact.Interactions {
+			if interaction.Response.Status >= 400 {
+				continue
+			}
+
+			method, path := interaction.Request.Method, interaction.Request.Path
+			if err := doc.Validate(t, doc.RequestSchema(t, method, path), interaction.Request.Body); err != nil {
+				t.Errorf("%s: request doesn't match the %s OpenAPI document: %v", interaction.Description, provider, err)
+			}
+
+			schema := doc.ResponseData(t, method, path, interaction.Response.Status)
+			if schema == nil || len(interaction.Response.Body) == 0 {
+				continue
+			}
+			if err := doc.Validate(t, schema, interaction.Response.Body); err != nil {
+				t.Errorf("%s: response doesn't match the %s OpenAPI document: %v", interaction.Description, provider, err)
+			}
+		}
+	}
+}
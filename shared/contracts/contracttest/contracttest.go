@@ -0,0 +1,148 @@
+// Package contracttest holds the consumer-driven contracts between
+// services. Each pact records the requests a consumer sends and the
+// responses it relies on; the consumer's tests check it still sends them
+// and the provider's tests check it still accepts and answers them, so a
+// change on either side fails the build.
+package contracttest
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+//go:embed pacts/*.json
+var pacts embed.FS
+
+// Pact is the set of interactions a consumer has with a provider
+type Pact struct {
+	Consumer     string        `json:"consumer"`
+	Provider     string        `json:"provider"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one request the consumer sends and the response it expects
+type Interaction struct {
+	Description string   `json:"description"`
+	Request     Request  `json:"request"`
+	Response    Response `json:"response"`
+}
+
+type Request struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body"`
+}
+
+type Response struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// Load reads the pact between consumer and provider
+func Load(t testing.TB, consumer, provider string) Pact {
+	t.Helper()
+
+	data, err := pacts.ReadFile(fmt.Sprintf("pacts/%s-%s.json", consumer, provider))
+	if err != nil {
+		t.Fatalf("no pact between %s and %s: %v", consumer, provider, err)
+	}
+	var pact Pact
+	if err := json.Unmarshal(data, &pact); err != nil {
+		t.Fatalf("invalid pact between %s and %s: %v", consumer, provider, err)
+	}
+	return pact
+}
+
+// Interaction returns the interaction with the description
+func (p Pact) Interaction(t testing.TB, description string) Interaction {
+	t.Helper()
+
+	for _, interaction := range p.Interactions {
+		if interaction.Description == description {
+			return interaction
+		}
+	}
+	t.Fatalf("pact between %s and %s has no interaction %q", p.Consumer, p.Provider, description)
+	return Interaction{}
+}
+
+// DecodeRequest decodes the request body into v, failing on fields v
+// doesn't have, which the provider would silently drop
+func (i Interaction) DecodeRequest(t testing.TB, v interface{}) {
+	t.Helper()
+	decodeStrict(t, i.Description+" request", i.Request.Body, v)
+}
+
+// DecodeResponse decodes the response body into v, failing on fields v
+// doesn't have, which the consumer relies on
+func (i Interaction) DecodeResponse(t testing.TB, v interface{}) {
+	t.Helper()
+	decodeStrict(t, i.Description+" response", i.Response.Body, v)
+}
+
+// Replay sends the interaction's request to the provider's app and checks
+// the status matches the recorded response
+func (i Interaction) Replay(t testing.TB, app *fiber.App) {
+	t.Helper()
+
+	req := httptest.NewRequest(i.Request.Method, i.Request.Path, bytes.NewReader(i.Request.Body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s: %v", i.Description, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != i.Response.Status {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("%s: got status %d, want %d: %s", i.Description, resp.StatusCode, i.Response.Status, body)
+	}
+}
+
+// AssertRequest checks that the request the consumer builds has exactly
+// the fields recorded for the interaction
+func (i Interaction) AssertRequest(t testing.TB, v interface{}) {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("%s: %v", i.Description, err)
+	}
+	got, want := fields(t, data), fields(t, i.Request.Body)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("%s: consumer sends fields %v, pact records %v", i.Description, got, want)
+	}
+}
+
+func decodeStrict(t testing.TB, what string, body json.RawMessage, v interface{}) {
+	t.Helper()
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		t.Fatalf("%s doesn't match %T: %v", what, v, err)
+	}
+}
+
+func fields(t testing.TB, body []byte) []string {
+	t.Helper()
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(body, &object); err != nil {
+		t.Fatalf("body isn't a JSON object: %v", err)
+	}
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,31 @@
+// Package contracts holds the request types services exchange, so the
+// provider and its consumers compile against the same definition and a
+// change to either side breaks the build rather than the other service.
+// Their wire format is specified by the OpenAPI documents in openapi.
+package contracts
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidXPAward = errors.New("user ID, amount, and reason are required")
+
+// AwardXPRequest is what the gamification service accepts on POST
+// /gamify/xp, and what the order service sends when purchases, sales and
+// badges earn XP
+type AwardXPRequest struct {
+	UserID    uuid.UUID `json:"user_id" validate:"required"`
+	Amount    int       `json:"amount" validate:"required"`
+	Reason    string    `json:"reason" validate:"required"`
+	Reference string    `json:"reference"` // ID of the order, payment or campaign behind the award
+}
+
+// Validate checks the fields the gamification service requires
+func (r AwardXPRequest) Validate() error {
+	if r.UserID == uuid.Nil || r.Amount == 0 || r.Reason == "" {
+		return ErrInvalidXPAward
+	}
+	return nil
+}
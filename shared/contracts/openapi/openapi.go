@@ -0,0 +1,335 @@
+// Package openapi holds the OpenAPI documents for the endpoints services
+// call on each other. The documents are the source of truth for those
+// requests and responses: tests check that the shared contract types, the
+// pacts recorded against each provider and the provider's routes all agree
+// with them. Endpoints only clients call are documented by the Swagger
+// annotations on their handlers instead.
+package openapi
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+//go:embed specs/*.json
+var specs embed.FS
+
+// Document is the subset of an OpenAPI 3 document the contracts use
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Paths      map[string]map[string]Operation `json:"paths"` // Path, then lower-case method
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	RequestBody *Body                `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Body struct {
+	Content map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema supports the JSON Schema keywords the documents use
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Enum                 []string           `json:"enum"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	Description          string             `json:"description"`
+}
+
+// Load reads the service's OpenAPI document
+func Load(t testing.TB, service string) Document {
+	t.Helper()
+
+	data, err := specs.ReadFile(fmt.Sprintf("specs/%s.json", service))
+	if err != nil {
+		t.Fatalf("no OpenAPI document for %s: %v", service, err)
+	}
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid OpenAPI document for %s: %v", service, err)
+	}
+	return doc
+}
+
+// Operation returns the operation on method and path
+func (d Document) Operation(t testing.TB, method, path string) Operation {
+	t.Helper()
+
+	operation, ok := d.Paths[path][strings.ToLower(method)]
+	if !ok {
+		t.Fatalf("OpenAPI document has no %s %s", method, path)
+	}
+	return operation
+}
+
+// RequestSchema returns the JSON body schema of the request on method and path
+func (d Document) RequestSchema(t testing.TB, method, path string) *Schema {
+	t.Helper()
+
+	operation := d.Operation(t, method, path)
+	if operation.RequestBody == nil || operation.RequestBody.Content[fiber.MIMEApplicationJSON].Schema == nil {
+		t.Fatalf("%s %s has no JSON request body", method, path)
+	}
+	return d.resolve(t, operation.RequestBody.Content[fiber.MIMEApplicationJSON].Schema)
+}
+
+// ResponseData returns the schema of the data field of the JSON response
+// with the status, the payload inside the utils.Response envelope. Nil
+// means the response has no documented body.
+func (d Document) ResponseData(t testing.TB, method, path string, status int) *Schema {
+	t.Helper()
+
+	response, ok := d.Operation(t, method, path).Responses[fmt.Sprint(status)]
+	if !ok {
+		t.Fatalf("%s %s has no %d response", method, path, status)
+	}
+	schema := response.Content[fiber.MIMEApplicationJSON].Schema
+	if schema == nil {
+		return nil
+	}
+	return d.resolve(t, d.resolve(t, schema).Properties["data"])
+}
+
+// Schema returns the component schema with the name
+func (d Document) Schema(t testing.TB, name string) *Schema {
+	t.Helper()
+	return d.resolve(t, &Schema{Ref: "#/components/schemas/" + name})
+}
+
+func (d Document) resolve(t testing.TB, schema *Schema) *Schema {
+	t.Helper()
+
+	if schema == nil || schema.Ref == "" {
+		return schema
+	}
+	name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+	resolved, ok := d.Components.Schemas[name]
+	if !ok {
+		t.Fatalf("OpenAPI document has no schema %s", schema.Ref)
+	}
+	return d.resolve(t, resolved)
+}
+
+// Validate checks a JSON body against the schema
+func (d Document) Validate(t testing.TB, schema *Schema, body json.RawMessage) error {
+	t.Helper()
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return err
+	}
+	return d.validate(t, schema, value, "body")
+}
+
+func (d Document) validate(t testing.TB, schema *Schema, value interface{}, at string) error {
+	schema = d.resolve(t, schema)
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an object", at)
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				return fmt.Errorf("%s is missing required field %s", at, name)
+			}
+		}
+		for name, field := range object {
+			property, ok := schema.Properties[name]
+			if !ok {
+				if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+					return fmt.Errorf("%s has undocumented field %s", at, name)
+				}
+				continue
+			}
+			if err := d.validate(t, property, field, at+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an array", at)
+		}
+		for i, item := range items {
+			if err := d.validate(t, schema.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", at)
+		}
+		if schema.Format == "uuid" {
+			if _, err := uuid.Parse(s); err != nil {
+				return fmt.Errorf("%s must be a UUID", at)
+			}
+		}
+		if len(schema.Enum) > 0 && !contains(schema.Enum, s) {
+			return fmt.Errorf("%s must be one of %v", at, schema.Enum)
+		}
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("%s must be a number", at)
+		}
+		if schema.Type == "integer" && n != math.Trunc(n) {
+			return fmt.Errorf("%s must be an integer", at)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", at)
+		}
+	}
+	return nil
+}
+
+// AssertType checks that a Go type has exactly the schema's properties,
+// with matching JSON types, and requires the same fields. A field is
+// required when it's tagged validate:"required"; types without validate
+// tags, like responses, require every field not marked omitempty.
+func (d Document) AssertType(t testing.TB, schema *Schema, v interface{}) {
+	t.Helper()
+
+	schema = d.resolve(t, schema)
+	typ := reflect.TypeOf(v)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	validated := false
+	for i := 0; i < typ.NumField(); i++ {
+		if _, ok := typ.Field(i).Tag.Lookup("validate"); ok {
+			validated = true
+		}
+	}
+
+	var properties, required []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")
+		if tag[0] == "-" || tag[0] == "" {
+			continue
+		}
+		name := tag[0]
+		properties = append(properties, name)
+
+		if validated {
+			if strings.Contains(field.Tag.Get("validate"), "required") {
+				required = append(required, name)
+			}
+		} else if !contains(tag[1:], "omitempty") {
+			required = append(required, name)
+		}
+
+		property, ok := schema.Properties[name]
+		if !ok {
+			continue
+		}
+		if want, got := d.resolve(t, property).Type, jsonType(field.Type); want != got {
+			t.Errorf("%s.%s is a JSON %s, the schema documents a %s", typ.Name(), field.Name, got, want)
+		}
+	}
+
+	documented := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		documented = append(documented, name)
+	}
+	sort.Strings(properties)
+	sort.Strings(documented)
+	if fmt.Sprint(properties) != fmt.Sprint(documented) {
+		t.Errorf("%s has fields %v, the schema documents %v", typ.Name(), properties, documented)
+	}
+
+	sort.Strings(required)
+	documentedRequired := append([]string(nil), schema.Required...)
+	sort.Strings(documentedRequired)
+	if fmt.Sprint(required) != fmt.Sprint(documentedRequired) {
+		t.Errorf("%s requires %v, the schema requires %v", typ.Name(), required, documentedRequired)
+	}
+}
+
+// AssertRoutes checks that the app serves every documented operation, with
+// the document's paths taken relative to prefix
+func (d Document) AssertRoutes(t testing.TB, app *fiber.App, prefix string) {
+	t.Helper()
+
+	served := make(map[string]bool)
+	for _, route := range app.GetRoutes(true) {
+		served[route.Method+" "+route.Path] = true
+	}
+
+	for path, operations := range d.Paths {
+		// OpenAPI writes path parameters as {id}, Fiber as :id
+		route := prefix + path
+		route = strings.NewReplacer("{", ":", "}", "").Replace(route)
+		for method := range operations {
+			if !served[strings.ToUpper(method)+" "+route] {
+				t.Errorf("%s %s is documented but not served", strings.ToUpper(method), prefix+path)
+			}
+		}
+	}
+}
+
+// jsonType returns the JSON Schema type a Go type encodes as
+func jsonType(typ reflect.Type) string {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == reflect.TypeOf(uuid.UUID{}) {
+		return "string"
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+	return "object"
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package contracts
+
+import (
+	"errors"
+
+	"playful-marketplace/shared/models"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidPaymentRequest = errors.New("order ID and payment method are required")
+
+// InitiatePaymentRequest is what the payment service accepts on POST
+// /payments/initiate, and what the order service prefills for unpaid orders
+type InitiatePaymentRequest struct {
+	OrderID uuid.UUID            `json:"order_id" validate:"required"`
+	Method  models.PaymentMethod `json:"method" validate:"required"`
+	Phone   string               `json:"phone,omitempty"` // Required for mobile payments, unless payment_phone_id is given
+
+	PaymentPhoneID *uuid.UUID `json:"payment_phone_id,omitempty"` // A verified phone saved under /users/:id/payment-phones
+}
+
+// Validate checks the fields the payment service requires. Whether the
+// method needs a phone depends on the order's region, which only the
+// payment service checks.
+func (r InitiatePaymentRequest) Validate() error {
+	if r.OrderID == uuid.Nil || r.Method == "" {
+		return ErrInvalidPaymentRequest
+	}
+	return nil
+}
+
+// PaymentResponse is what the payment service returns once a payment is
+// initiated. TransactionID is what GET /payments/status/:id takes.
+type PaymentResponse struct {
+	TransactionID string `json:"transaction_id"`
+	Reference     string `json:"reference"`
+	Status        string `json:"status"`
+	Message       string `json:"message"`
+	RedirectURL   string `json:"redirect_url,omitempty"`
+}
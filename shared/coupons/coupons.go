@@ -0,0 +1,230 @@
+package coupons
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidCoupon = errors.New("coupon code is not valid")
+	ErrCouponExpired = errors.New("coupon has expired")
+	ErrCouponUsed    = errors.New("coupon has already been used")
+	ErrNotApplicable = errors.New("coupon does not apply to any item in this order")
+	ErrCodeTaken     = errors.New("coupon code is already in use")
+)
+
+// Issue creates a single-use coupon for a user.
+func Issue(userID uuid.UUID, percent float64, validDays int, source string) (*models.Coupon, error) {
+	code, err := generateCode()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().AddDate(0, 0, validDays)
+	coupon := models.Coupon{
+		BaseModel:       models.BaseModel{ID: uuid.New()},
+		Code:            code,
+		UserID:          &userID,
+		DiscountPercent: percent,
+		UsageLimit:      1,
+		ExpiresAt:       &expiresAt,
+		Source:          source,
+	}
+
+	if err := database.DB.Create(&coupon).Error; err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// IssueShared creates a multi-use coupon anyone can redeem. Seller coupons
+// set sellerID; platform coupons leave it nil. An empty code is generated.
+func IssueShared(sellerID *uuid.UUID, code string, percent, maxDiscount float64, usageLimit int, expiresAt *time.Time) (*models.Coupon, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		generated, err := generateCode()
+		if err != nil {
+			return nil, err
+		}
+		code = generated
+	}
+
+	var count int64
+	database.DB.Unscoped().Model(&models.Coupon{}).Where("code = ?", code).Count(&count)
+	if count > 0 {
+		return nil, ErrCodeTaken
+	}
+
+	source := "admin"
+	if sellerID != nil {
+		source = "seller"
+	}
+
+	coupon := models.Coupon{
+		BaseModel:       models.BaseModel{ID: uuid.New()},
+		Code:            code,
+		SellerID:        sellerID,
+		DiscountPercent: percent,
+		MaxDiscount:     maxDiscount,
+		UsageLimit:      usageLimit,
+		ExpiresAt:       expiresAt,
+		Source:          source,
+	}
+
+	if err := database.DB.Create(&coupon).Error; err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// Apply validates a coupon for the user inside the checkout transaction and
+// returns the discount and the subtotal it was taken from. Seller coupons
+// only discount that seller's share of sellerSubtotals. The coupon row is
+// locked until the transaction ends so concurrent checkouts can't both
+// redeem it.
+func Apply(tx *gorm.DB, code string, userID uuid.UUID, sellerSubtotals map[uuid.UUID]float64) (*models.Coupon, float64, float64, error) {
+	var coupon models.Coupon
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("code = ?", strings.ToUpper(strings.TrimSpace(code))).
+		First(&coupon).Error; err != nil {
+		return nil, 0, 0, ErrInvalidCoupon
+	}
+
+	if coupon.UserID != nil && *coupon.UserID != userID {
+		return nil, 0, 0, ErrInvalidCoupon
+	}
+	if coupon.ExpiresAt != nil && time.Now().After(*coupon.ExpiresAt) {
+		return nil, 0, 0, ErrCouponExpired
+	}
+	if coupon.UsedCount >= coupon.UsageLimit {
+		return nil, 0, 0, ErrCouponUsed
+	}
+
+	var subtotal float64
+	if coupon.SellerID != nil {
+		subtotal = sellerSubtotals[*coupon.SellerID]
+		if subtotal == 0 {
+			return nil, 0, 0, ErrNotApplicable
+		}
+	} else {
+		for _, amount := range sellerSubtotals {
+			subtotal += amount
+		}
+	}
+
+	discount := subtotal * coupon.DiscountPercent / 100
+	if coupon.MaxDiscount > 0 && discount > coupon.MaxDiscount {
+		discount = coupon.MaxDiscount
+	}
+
+	return &coupon, math.Round(discount*100) / 100, subtotal, nil
+}
+
+// Redeem records the coupon against the order, attributing the discounted
+// subtotal as revenue. Buyers with no earlier order (from the issuing seller,
+// for seller coupons) count as new.
+func Redeem(tx *gorm.DB, coupon *models.Coupon, orderID, userID uuid.UUID, discount, subtotal float64) error {
+	if err := tx.Model(coupon).Update("used_count", gorm.Expr("used_count + 1")).Error; err != nil {
+		return err
+	}
+
+	previous := tx.Model(&models.Order{}).
+		Where("orders.buyer_id = ? AND orders.id <> ? AND orders.status <> ?", userID, orderID, models.OrderCancelled)
+	if coupon.SellerID != nil {
+		previous = previous.
+			Joins("JOIN order_items ON order_items.order_id = orders.id").
+			Joins("JOIN products ON products.id = order_items.product_id").
+			Where("products.seller_id = ?", *coupon.SellerID)
+	}
+	var previousOrders int64
+	if err := previous.Count(&previousOrders).Error; err != nil {
+		return err
+	}
+
+	redemption := models.CouponRedemption{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		CouponID:  coupon.ID,
+		OrderID:   orderID,
+		UserID:    userID,
+		Discount:  discount,
+		Revenue:   math.Round((subtotal-discount)*100) / 100,
+		NewBuyer:  previousOrders == 0,
+	}
+	return tx.Create(&redemption).Error
+}
+
+// Release reverses the coupon redemption on a cancelled order, freeing the
+// use for the buyer again. Orders without a coupon are left alone.
+func Release(tx *gorm.DB, orderID uuid.UUID) error {
+	var redemption models.CouponRedemption
+	if err := tx.Where("order_id = ?", orderID).First(&redemption).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if err := tx.Model(&models.Coupon{}).Where("id = ? AND used_count > 0", redemption.CouponID).
+		Update("used_count", gorm.Expr("used_count - 1")).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&redemption).Error
+}
+
+// Stats summarises a coupon's redemptions
+type Stats struct {
+	CouponID        uuid.UUID `json:"coupon_id"`
+	Redemptions     int64     `json:"redemptions"`
+	Discount        float64   `json:"discount"`
+	Revenue         float64   `json:"revenue"`
+	NewBuyers       int64     `json:"new_buyers"`
+	ReturningBuyers int64     `json:"returning_buyers"`
+}
+
+// GetStats returns redemption stats for each coupon, skipping redemptions
+// whose order was later cancelled.
+func GetStats(couponIDs []uuid.UUID) (map[uuid.UUID]Stats, error) {
+	var rows []Stats
+	err := database.DB.Model(&models.CouponRedemption{}).
+		Select(`coupon_redemptions.coupon_id,
+			COUNT(*) AS redemptions,
+			COALESCE(SUM(coupon_redemptions.discount), 0) AS discount,
+			COALESCE(SUM(coupon_redemptions.revenue), 0) AS revenue,
+			COUNT(*) FILTER (WHERE coupon_redemptions.new_buyer) AS new_buyers,
+			COUNT(*) FILTER (WHERE NOT coupon_redemptions.new_buyer) AS returning_buyers`).
+		Joins("JOIN orders ON orders.id = coupon_redemptions.order_id").
+		Where("coupon_redemptions.coupon_id IN ? AND orders.status <> ?", couponIDs, models.OrderCancelled).
+		Group("coupon_redemptions.coupon_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[uuid.UUID]Stats, len(couponIDs))
+	for _, id := range couponIDs {
+		stats[id] = Stats{CouponID: id}
+	}
+	for _, row := range rows {
+		stats[row.CouponID] = row
+	}
+	return stats, nil
+}
+
+func generateCode() (string, error) {
+	raw := make([]byte, 4)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return "PM-" + strings.ToUpper(hex.EncodeToString(raw)), nil
+}
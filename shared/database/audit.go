@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Profile columns whose changes are audited. Counters, login bookkeeping and
+// secrets are left out.
+var auditedUserColumns = []string{
+	"phone", "name", "email", "pending_email", "role", "roles", "is_active",
+	"marketing_opt_out", "seller_verified", "referral_code", "digest_frequency",
+	"avatar_url", "preferred_language", "display_currency", "totp_enabled",
+	"account_status", "suspended_until", "suspension_reason",
+}
+
+const auditSnapshotKey = "audit:users_before"
+
+type actorKey struct{}
+
+// ActingAs returns a database handle whose changes to user profiles are
+// attributed to the actor in the audit log. Changes made through DB are
+// recorded as made by the system.
+func ActingAs(actorID uuid.UUID) *gorm.DB {
+	return DB.WithContext(context.WithValue(context.Background(), actorKey{}, actorID))
+}
+
+// registerAuditCallbacks snapshots the users an update matches before it
+// runs and records which audited columns changed once it has
+func registerAuditCallbacks(db *gorm.DB) error {
+	if err := db.Callback().Update().Before("gorm:update").Register("audit:snapshot_users", snapshotUsers); err != nil {
+		return err
+	}
+	return db.Callback().Update().After("gorm:update").Register("audit:record_user_changes", recordUserChanges)
+}
+
+func snapshotUsers(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || stmt.Schema.Table != "users" || !touchesAuditedColumns(stmt) {
+		return
+	}
+
+	query := db.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&models.User{})
+	conditions := false
+	if where, ok := stmt.Clauses["WHERE"].Expression.(clause.Where); ok && len(where.Exprs) > 0 {
+		query = query.Clauses(where)
+		conditions = true
+	}
+	if stmt.ReflectValue.Kind() == reflect.Struct && stmt.Schema.PrioritizedPrimaryField != nil {
+		if id, zero := stmt.Schema.PrioritizedPrimaryField.ValueOf(stmt.Context, stmt.ReflectValue); !zero {
+			query = query.Where("id = ?", id)
+			conditions = true
+		}
+	}
+	// Updates without conditions are refused by gorm anyway
+	if !conditions {
+		return
+	}
+
+	var before []models.User
+	if err := query.Find(&before).Error; err != nil {
+		log.Printf("Failed to snapshot users for the audit log: %v", err)
+		return
+	}
+	db.InstanceSet(auditSnapshotKey, before)
+}
+
+func recordUserChanges(db *gorm.DB) {
+	value, ok := db.InstanceGet(auditSnapshotKey)
+	if !ok || db.Error != nil {
+		return
+	}
+	before := value.([]models.User)
+	if len(before) == 0 {
+		return
+	}
+
+	ids := make([]uuid.UUID, len(before))
+	for i, user := range before {
+		ids[i] = user.ID
+	}
+
+	session := db.Session(&gorm.Session{NewDB: true})
+	var after []models.User
+	if err := session.Unscoped().Where("id IN ?", ids).Find(&after).Error; err != nil {
+		log.Printf("Failed to read updated users for the audit log: %v", err)
+		return
+	}
+	updated := make(map[uuid.UUID]*models.User, len(after))
+	for i := range after {
+		updated[after[i].ID] = &after[i]
+	}
+
+	var actorID *uuid.UUID
+	if id, ok := db.Statement.Context.Value(actorKey{}).(uuid.UUID); ok {
+		actorID = &id
+	}
+
+	var changes []models.UserChange
+	for i := range before {
+		user, ok := updated[before[i].ID]
+		if !ok {
+			continue
+		}
+		for _, column := range auditedUserColumns {
+			field := db.Statement.Schema.LookUpField(column)
+			if field == nil {
+				continue
+			}
+			oldValue := auditValue(db.Statement.Context, field, &before[i])
+			newValue := auditValue(db.Statement.Context, field, user)
+			if oldValue == newValue {
+				continue
+			}
+			changes = append(changes, models.UserChange{
+				BaseModel: models.BaseModel{ID: uuid.New()},
+				UserID:    user.ID,
+				ActorID:   actorID,
+				Field:     column,
+				OldValue:  oldValue,
+				NewValue:  newValue,
+			})
+		}
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	// Written in the update's transaction so it rolls back with it
+	if err := session.Create(&changes).Error; err != nil {
+		log.Printf("Failed to record user changes: %v", err)
+	}
+}
+
+// touchesAuditedColumns reports whether the update may set an audited
+// column. Map updates name their columns; struct updates may set any.
+func touchesAuditedColumns(stmt *gorm.Statement) bool {
+	updates, ok := stmt.Dest.(map[string]interface{})
+	if !ok {
+		return true
+	}
+	for name := range updates {
+		column := name
+		if field := stmt.Schema.LookUpField(name); field != nil {
+			column = field.DBName
+		}
+		for _, audited := range auditedUserColumns {
+			if column == audited {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// auditValue formats a user's field for the audit log. Nil pointers are
+// empty and times are RFC 3339.
+func auditValue(ctx context.Context, field *schema.Field, user *models.User) string {
+	value, _ := field.ValueOf(ctx, reflect.ValueOf(user).Elem())
+
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return ""
+		}
+		rv = rv.Elem()
+	}
+
+	switch v := rv.Interface().(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case models.UserRoles:
+		roles := make([]string, len(v))
+		for i, role := range v {
+			roles[i] = string(role)
+		}
+		return strings.Join(roles, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
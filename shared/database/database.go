@@ -33,26 +33,135 @@ func Connect(cfg *config.Config) error {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	// Audit changes to user profiles
+	if err := registerAuditCallbacks(DB); err != nil {
+		return fmt.Errorf("failed to register audit callbacks: %w", err)
+	}
+
 	log.Println("Database connected successfully")
 	return nil
 }
 
 func Migrate() error {
+	// Shipments were unique per order before partial fulfillment
+	if DB.Migrator().HasIndex(&models.Shipment{}, "idx_shipments_order_id") {
+		if err := DB.Migrator().DropIndex(&models.Shipment{}, "idx_shipments_order_id"); err != nil {
+			return fmt.Errorf("failed to drop shipment order index: %w", err)
+		}
+	}
+
 	err := DB.AutoMigrate(
 		&models.User{},
 		&models.Product{},
 		&models.Order{},
 		&models.OrderItem{},
+		&models.StockReservation{},
 		&models.Payment{},
+		&models.InvoiceSequence{},
+		&models.Invoice{},
 		&models.Badge{},
 		&models.UserBadge{},
 		&models.XPTransaction{},
+		&models.Notification{},
+		&models.UserDevice{},
+		&models.LoginEvent{},
+		&models.FraudAssessment{},
+		&models.ReturnPolicy{},
+		&models.ShippingSettings{},
+		&models.OrderShipping{},
+		&models.ReturnRequest{},
+		&models.InventorySyncConfig{},
+		&models.InventoryChangeLog{},
+		&models.Shipment{},
+		&models.Review{},
+		&models.ReviewSolicitation{},
+		&models.CategoryRequirement{},
+		&models.ContentFlag{},
+		&models.RecoveryCode{},
+		&models.APIKey{},
+		&models.WebhookSubscription{},
+		&models.ProductFunnelStat{},
+		&models.SearchStat{},
+		&models.DuplicateListing{},
+		&models.SellerProfile{},
+		&models.ReviewVote{},
+		&models.AccountPurge{},
+		&models.RetentionPurge{},
+		&models.LedgerEntry{},
+		&models.Coupon{},
+		&models.CouponRedemption{},
+		&models.Campaign{},
+		&models.CampaignSend{},
+		&models.Cart{},
+		&models.CartItem{},
+		&models.PhoneChange{},
+		&models.UserChange{},
+		&models.RestockSubscription{},
+		&models.Identity{},
+		&models.SellerApplication{},
+		&models.PriceTier{},
+		&models.PriceHistory{},
+		&models.ScheduledPriceChange{},
+		&models.Offer{},
+		&models.UserSanction{},
+		&models.SellerReferral{},
+		&models.StoreStaff{},
+		&models.Store{},
+		&models.StoreMember{},
+		&models.OrderIssue{},
+		&models.WishlistItem{},
+		&models.PaymentPhone{},
+		&models.Job{},
+		&models.NotificationPreferences{},
+		&models.SellerVerification{},
+		&models.ActivityEvent{},
+		&models.Announcement{},
+		&models.LeaderboardAdjustment{},
+		&models.SellerPerformance{},
+		&models.UserTag{},
+		&models.Segment{},
+		&models.SegmentMember{},
+		&models.Promotion{},
+		&models.AccountRecovery{},
+		&models.ProductSlugRedirect{},
+		&models.FeedFile{},
+		&models.ProductVariant{},
+		&models.ProductImage{},
 	)
 
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	// Accounts had a single role before buyer and seller profiles could be combined
+	if err := DB.Exec(`UPDATE users SET roles = CASE WHEN role = ? THEN ARRAY[?, ?] ELSE ARRAY[role] END
+		WHERE roles IS NULL OR cardinality(roles) = 0`, models.RoleSeller, models.RoleBuyer, models.RoleSeller).Error; err != nil {
+		return fmt.Errorf("failed to backfill user roles: %w", err)
+	}
+
+	// Cart items were unique per product before products had variants
+	if err := DB.Exec("DROP INDEX IF EXISTS idx_cart_item_product").Error; err != nil {
+		return fmt.Errorf("failed to drop cart item index: %w", err)
+	}
+
+	// Products had a single image before they had a gallery
+	if err := DB.Exec(`INSERT INTO product_images (id, product_id, url, position, is_primary, created_at, updated_at)
+		SELECT gen_random_uuid(), id, image_url, 0, true, NOW(), NOW() FROM products
+		WHERE image_url <> '' AND NOT EXISTS (SELECT 1 FROM product_images WHERE product_images.product_id = products.id)`).Error; err != nil {
+		return fmt.Errorf("failed to backfill product images: %w", err)
+	}
+
+	// Products had no price history before it was tracked
+	if err := DB.Exec(`INSERT INTO price_histories (id, product_id, price, source, effective_at, created_at, updated_at)
+		SELECT gen_random_uuid(), id, price, ?, created_at, NOW(), NOW() FROM products
+		WHERE NOT EXISTS (SELECT 1 FROM price_histories WHERE price_histories.product_id = products.id)`, models.PriceSourceListing).Error; err != nil {
+		return fmt.Errorf("failed to backfill price history: %w", err)
+	}
+
+	if err := migrateSearch(); err != nil {
+		return err
+	}
+
 	// Seed initial badges
 	seedBadges()
 
@@ -109,3 +218,41 @@ func seedBadges() {
 		}
 	}
 }
+
+// exampleAdminPhone is the placeholder once shipped as the default admin
+// phone. Seeding an admin with it would hand the account to anyone who
+// requests a login code for it.
+const exampleAdminPhone = "+251900000000"
+
+// SeedAdmin creates the default admin user if no admin exists yet. Nothing
+// is seeded unless ADMIN_PHONE is set.
+func SeedAdmin(cfg *config.Config) error {
+	if cfg.Admin.Phone == "" {
+		return nil
+	}
+	if cfg.Admin.Phone == exampleAdminPhone {
+		return fmt.Errorf("ADMIN_PHONE is still the example value %s, set it to a phone you control", exampleAdminPhone)
+	}
+
+	var adminCount int64
+	DB.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&adminCount)
+	if adminCount > 0 {
+		return nil
+	}
+
+	admin := models.User{
+		Phone:    cfg.Admin.Phone,
+		Name:     cfg.Admin.Name,
+		Role:     models.RoleAdmin,
+		Roles:    models.RolesFor(models.RoleAdmin),
+		Level:    models.LevelBronze,
+		IsActive: true,
+	}
+
+	if err := DB.Create(&admin).Error; err != nil {
+		return fmt.Errorf("failed to seed admin user: %w", err)
+	}
+
+	log.Printf("Seeded default admin user with phone %s", admin.Phone)
+	return nil
+}
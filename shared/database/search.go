@@ -0,0 +1,37 @@
+package database
+
+import "fmt"
+
+// Product search runs on a tsvector column kept current by a trigger, with
+// trigram indexes on the name for typo tolerance. The 'simple' configuration
+// doesn't stem, so names in any script are indexed as written.
+var searchMigrations = []string{
+	`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+	`ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector tsvector`,
+	`CREATE OR REPLACE FUNCTION products_search_vector() RETURNS trigger AS $$
+	BEGIN
+		NEW.search_vector :=
+			setweight(to_tsvector('simple', COALESCE(NEW.name, '')), 'A') ||
+			setweight(to_tsvector('simple', COALESCE(NEW.category, '')), 'B') ||
+			setweight(to_tsvector('simple', COALESCE(NEW.description, '')), 'C');
+		RETURN NEW;
+	END
+	$$ LANGUAGE plpgsql`,
+	`DROP TRIGGER IF EXISTS products_search_vector ON products`,
+	`CREATE TRIGGER products_search_vector BEFORE INSERT OR UPDATE OF name, category, description ON products
+		FOR EACH ROW EXECUTE FUNCTION products_search_vector()`,
+	`CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING GIN (search_vector)`,
+	`CREATE INDEX IF NOT EXISTS idx_products_name_trgm ON products USING GIN (name gin_trgm_ops)`,
+
+	// Products created before the trigger existed
+	`UPDATE products SET name = name WHERE search_vector IS NULL`,
+}
+
+func migrateSearch() error {
+	for _, statement := range searchMigrations {
+		if err := DB.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to migrate product search: %w", err)
+		}
+	}
+	return nil
+}
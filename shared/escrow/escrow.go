@@ -0,0 +1,93 @@
+package escrow
+
+import (
+	"errors"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/ledger"
+	"playful-marketplace/shared/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPaymentNotCompleted = errors.New("payment has not been completed")
+	ErrProofRequired       = errors.New("proof of delivery is required for cash on delivery orders")
+	ErrDisputed            = errors.New("order has an open dispute")
+	ErrAlreadyRefunded     = errors.New("payment has already been refunded")
+)
+
+// Release releases held payment funds for an order to its sellers,
+// recording the platform fee and seller payouts in the ledger.
+// Cash on delivery orders require captured proof of delivery, and funds
+// stay held while the order has an open dispute.
+func Release(orderID uuid.UUID, feePercent float64) error {
+	var payment models.Payment
+	if err := database.DB.Where("order_id = ? AND status = ?", orderID, models.PaymentCompleted).First(&payment).Error; err != nil {
+		return ErrPaymentNotCompleted
+	}
+
+	if payment.EscrowStatus == models.EscrowReleased {
+		return nil
+	}
+
+	var disputes int64
+	database.DB.Model(&models.OrderIssue{}).
+		Where("order_id = ? AND workflow = ? AND status = ?", orderID, models.WorkflowDispute, models.IssueOpen).
+		Count(&disputes)
+	if disputes > 0 {
+		return ErrDisputed
+	}
+
+	// Every shipment of a cash order needs proof of delivery
+	if payment.Method == models.PaymentCash {
+		var shipments []models.Shipment
+		if err := database.DB.Where("order_id = ?", orderID).Find(&shipments).Error; err != nil || len(shipments) == 0 {
+			return ErrProofRequired
+		}
+		for _, shipment := range shipments {
+			if !shipment.HasProof() {
+				return ErrProofRequired
+			}
+		}
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&payment).Updates(map[string]interface{}{
+			"escrow_status":      models.EscrowReleased,
+			"escrow_released_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		return ledger.RecordRelease(tx, &payment, feePercent)
+	})
+}
+
+// PayProtectionClaim pays a buyer protection claim's payout amount back to
+// the buyer and marks the order's payment refunded, so its escrow can no
+// longer be released. Held funds are returned from escrow; released ones
+// are covered by the protection fund.
+func PayProtectionClaim(tx *gorm.DB, issue *models.OrderIssue) error {
+	var payment models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_id = ? AND status IN ?", issue.OrderID, []models.PaymentStatus{models.PaymentCompleted, models.PaymentRefunded}).
+		First(&payment).Error; err != nil {
+		return ErrPaymentNotCompleted
+	}
+	if payment.Status == models.PaymentRefunded {
+		return ErrAlreadyRefunded
+	}
+
+	fromEscrow := payment.EscrowStatus == models.EscrowHeld
+	updates := map[string]interface{}{"status": models.PaymentRefunded}
+	if fromEscrow {
+		updates["escrow_status"] = models.EscrowRefunded
+	}
+	if err := tx.Model(&payment).Updates(updates).Error; err != nil {
+		return err
+	}
+	return ledger.RecordProtectionPayout(tx, issue, &payment, fromEscrow)
+}
@@ -0,0 +1,340 @@
+package feeds
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/config"
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/storage"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Products are split across one shard per leading hex digit of their ID, so
+// each file stays well under the 50,000 URL sitemap limit and a change only
+// rewrites the shard holding the product.
+const Shards = 16
+
+// Gallery images listed per catalog item besides the primary one, the most
+// Google Merchant Center accepts
+const maxAdditionalImages = 10
+
+const (
+	sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	googleNamespace  = "http://base.google.com/ns/1.0"
+	sitemapIndexKey  = "sitemaps/sitemap.xml"
+)
+
+// Generator writes the sitemap and catalog feed of active products to storage
+type Generator struct {
+	storage    storage.Storage
+	storefront string
+	currency   string
+}
+
+func New(cfg *config.Config) *Generator {
+	return &Generator{
+		storage:    storage.New(cfg.Storage),
+		storefront: strings.TrimRight(cfg.Feeds.StorefrontURL, "/"),
+		currency:   cfg.Locale.BaseCurrency,
+	}
+}
+
+// Rebuild writes every shard and the sitemap index
+func (g *Generator) Rebuild() error {
+	for shard := 0; shard < Shards; shard++ {
+		if err := g.writeShard(shard); err != nil {
+			return err
+		}
+	}
+	return g.writeIndex()
+}
+
+// Update rewrites the shards holding products changed or deleted since the
+// shard was last written. Shards that were never written are written too.
+func (g *Generator) Update() (int, error) {
+	var files []models.FeedFile
+	if err := database.DB.Where("kind = ?", models.FeedCatalog).Find(&files).Error; err != nil {
+		return 0, err
+	}
+
+	generatedAt := make(map[int]time.Time, len(files))
+	since := time.Now()
+	for _, file := range files {
+		generatedAt[file.Shard] = file.GeneratedAt
+		if file.GeneratedAt.Before(since) {
+			since = file.GeneratedAt
+		}
+	}
+
+	dirty := make(map[int]bool)
+	for shard := 0; shard < Shards; shard++ {
+		if _, ok := generatedAt[shard]; !ok {
+			dirty[shard] = true
+		}
+	}
+
+	var changed []struct {
+		Shard     string
+		ChangedAt time.Time
+	}
+	if err := database.DB.Unscoped().Model(&models.Product{}).
+		Select("LEFT(id::text, 1) AS shard, GREATEST(updated_at, COALESCE(deleted_at, updated_at)) AS changed_at").
+		Where("updated_at > ? OR deleted_at > ?", since, since).
+		Scan(&changed).Error; err != nil {
+		return 0, err
+	}
+	for _, product := range changed {
+		shard := shardIndex(product.Shard)
+		if shard >= 0 && product.ChangedAt.After(generatedAt[shard]) {
+			dirty[shard] = true
+		}
+	}
+
+	if len(dirty) == 0 {
+		return 0, nil
+	}
+	for shard := 0; shard < Shards; shard++ {
+		if !dirty[shard] {
+			continue
+		}
+		if err := g.writeShard(shard); err != nil {
+			return 0, err
+		}
+	}
+	return len(dirty), g.writeIndex()
+}
+
+// writeShard writes the sitemap and catalog feed for the products in shard
+func (g *Generator) writeShard(shard int) error {
+	// Taken before reading so products changed while writing are picked up
+	// by the next update
+	started := time.Now()
+
+	var products []models.Product
+	if err := database.DB.Preload("Seller").Preload("Images", "quarantined = ? AND is_primary = ?", false, false, func(db *gorm.DB) *gorm.DB {
+		return db.Order("position ASC")
+	}).
+		Where("is_active = ? AND slug IS NOT NULL AND LEFT(id::text, 1) = ?", true, shardPrefix(shard)).
+		Order("id").Find(&products).Error; err != nil {
+		return err
+	}
+
+	sitemap := urlSet{Namespace: sitemapNamespace}
+	catalog := rss{Version: "2.0", GoogleNamespace: googleNamespace, Channel: channel{
+		Title:       "Playful Marketplace",
+		Link:        g.storefront,
+		Description: "Products for sale on Playful Marketplace",
+	}}
+	for i := range products {
+		product := &products[i]
+		sitemap.URLs = append(sitemap.URLs, sitemapURL{
+			Loc:     g.productURL(product),
+			LastMod: product.UpdatedAt.UTC().Format(time.RFC3339),
+		})
+		// Ad platforms reject items without an image
+		if product.ImageURL != "" {
+			catalog.Channel.Items = append(catalog.Channel.Items, g.catalogItem(product))
+		}
+	}
+
+	sitemapURL, err := g.put(fmt.Sprintf("sitemaps/products-%x.xml", shard), sitemap)
+	if err != nil {
+		return err
+	}
+	if err := saveFile(models.FeedSitemap, shard, sitemapURL, len(sitemap.URLs), started); err != nil {
+		return err
+	}
+
+	catalogURL, err := g.put(fmt.Sprintf("feeds/products-%x.xml", shard), catalog)
+	if err != nil {
+		return err
+	}
+	return saveFile(models.FeedCatalog, shard, catalogURL, len(catalog.Channel.Items), started)
+}
+
+// writeIndex lists the sitemap shards so search engines need only one URL
+func (g *Generator) writeIndex() error {
+	started := time.Now()
+
+	var files []models.FeedFile
+	if err := database.DB.Where("kind = ?", models.FeedSitemap).Order("shard").Find(&files).Error; err != nil {
+		return err
+	}
+
+	index := sitemapIndex{Namespace: sitemapNamespace}
+	for _, file := range files {
+		index.Sitemaps = append(index.Sitemaps, sitemapRef{
+			Loc:     file.URL,
+			LastMod: file.GeneratedAt.UTC().Format(time.RFC3339),
+		})
+	}
+
+	indexURL, err := g.put(sitemapIndexKey, index)
+	if err != nil {
+		return err
+	}
+	return saveFile(models.FeedSitemapIndex, 0, indexURL, len(index.Sitemaps), started)
+}
+
+func (g *Generator) put(key string, doc interface{}) (string, error) {
+	body, err := xml.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	data := append([]byte(xml.Header), body...)
+
+	fileURL, err := g.storage.Put(key, "application/xml", data)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return fileURL, nil
+}
+
+func (g *Generator) productURL(product *models.Product) string {
+	return g.storefront + "/products/" + url.PathEscape(*product.Slug)
+}
+
+func (g *Generator) catalogItem(product *models.Product) catalogItem {
+	item := catalogItem{
+		ID:          product.ID.String(),
+		Title:       product.Name,
+		Description: product.Description,
+		Link:        g.productURL(product),
+		ImageLink:   product.ImageURL,
+		Price:       fmt.Sprintf("%.2f %s", product.Price, g.currency),
+		Condition:   "new",
+		Brand:       product.Attributes["brand"],
+		ProductType: product.Category,
+		// Sellers don't record GTINs or part numbers
+		IdentifierExists: "no",
+	}
+	for i, image := range product.Images {
+		if i == maxAdditionalImages {
+			break
+		}
+		item.AdditionalImageLinks = append(item.AdditionalImageLinks, image.URL)
+	}
+	// Sales that haven't ended are listed with their dates, so the sale
+	// price shows only while it's charged
+	if product.SalePrice != nil && *product.SalePrice < product.Price && (product.SaleEndsAt == nil || product.SaleEndsAt.After(time.Now())) {
+		item.SalePrice = fmt.Sprintf("%.2f %s", *product.SalePrice, g.currency)
+		if product.SaleStartsAt != nil || product.SaleEndsAt != nil {
+			start, end := product.CreatedAt, time.Now().AddDate(1, 0, 0)
+			if product.SaleStartsAt != nil {
+				start = *product.SaleStartsAt
+			}
+			if product.SaleEndsAt != nil {
+				end = *product.SaleEndsAt
+			}
+			item.SalePriceDates = start.UTC().Format(time.RFC3339) + "/" + end.UTC().Format(time.RFC3339)
+		}
+	}
+	if item.Brand == "" && product.Seller != nil {
+		item.Brand = product.Seller.Name
+	}
+
+	switch {
+	case product.Stock > 0:
+		item.Availability = "in_stock"
+	case product.Availability == models.AvailabilityBackorder:
+		item.Availability = "backorder"
+	case product.Availability == models.AvailabilityPreorder:
+		item.Availability = "preorder"
+	default:
+		item.Availability = "out_of_stock"
+	}
+	if product.Stock <= 0 && product.ExpectedAt != nil && product.AcceptsBackorders() {
+		item.AvailabilityDate = product.ExpectedAt.UTC().Format(time.RFC3339)
+	}
+	return item
+}
+
+func saveFile(kind models.FeedKind, shard int, fileURL string, items int, generatedAt time.Time) error {
+	file := models.FeedFile{
+		Kind:        kind,
+		Shard:       shard,
+		URL:         fileURL,
+		Items:       items,
+		GeneratedAt: generatedAt,
+	}
+	return database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "kind"}, {Name: "shard"}},
+		DoUpdates: clause.AssignmentColumns([]string{"url", "items", "generated_at", "updated_at"}),
+	}).Create(&file).Error
+}
+
+func shardPrefix(shard int) string {
+	return fmt.Sprintf("%x", shard)
+}
+
+func shardIndex(prefix string) int {
+	for shard := 0; shard < Shards; shard++ {
+		if shardPrefix(shard) == prefix {
+			return shard
+		}
+	}
+	return -1
+}
+
+type urlSet struct {
+	XMLName   xml.Name     `xml:"urlset"`
+	Namespace string       `xml:"xmlns,attr"`
+	URLs      []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+type sitemapIndex struct {
+	XMLName   xml.Name     `xml:"sitemapindex"`
+	Namespace string       `xml:"xmlns,attr"`
+	Sitemaps  []sitemapRef `xml:"sitemap"`
+}
+
+type sitemapRef struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// rss is the RSS 2.0 product feed read by both Google Merchant Center and
+// Facebook catalogs
+type rss struct {
+	XMLName         xml.Name `xml:"rss"`
+	Version         string   `xml:"version,attr"`
+	GoogleNamespace string   `xml:"xmlns:g,attr"`
+	Channel         channel  `xml:"channel"`
+}
+
+type channel struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	Description string        `xml:"description"`
+	Items       []catalogItem `xml:"item"`
+}
+
+type catalogItem struct {
+	ID                   string   `xml:"g:id"`
+	Title                string   `xml:"g:title"`
+	Description          string   `xml:"g:description"`
+	Link                 string   `xml:"g:link"`
+	ImageLink            string   `xml:"g:image_link"`
+	AdditionalImageLinks []string `xml:"g:additional_image_link"`
+	Availability         string   `xml:"g:availability"`
+	AvailabilityDate     string   `xml:"g:availability_date,omitempty"`
+	Price                string   `xml:"g:price"`
+	SalePrice            string   `xml:"g:sale_price,omitempty"`
+	SalePriceDates       string   `xml:"g:sale_price_effective_date,omitempty"`
+	Condition            string   `xml:"g:condition"`
+	Brand                string   `xml:"g:brand,omitempty"`
+	ProductType          string   `xml:"g:product_type,omitempty"`
+	IdentifierExists     string   `xml:"g:identifier_exists"`
+}
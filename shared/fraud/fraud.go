@@ -0,0 +1,214 @@
+package fraud
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/regions"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Stages at which a checkout is scored
+const (
+	StageOrder   = "order"
+	StagePayment = "payment"
+
+	// StageSettlement checks a completed payment against the order it paid for
+	StageSettlement = "settlement"
+)
+
+// Rule weights
+const (
+	velocityWeight       = 30 // More than 3 orders in the last hour
+	failedPaymentsWeight = 30 // 3+ failed payments in the last 24 hours
+	newAccountWeight     = 20 // Account younger than 24 hours
+	disposableWeight     = 25 // Disposable email domain
+	phoneMismatchWeight  = 15 // Payment phone differs from account phone
+	regionMismatchWeight = 15 // Account phone is from another region than the order
+	largeFirstBuyWeight  = 15 // First order over ₵5000
+	settlementWeight     = 50 // Order changed after its payment amount was fixed
+)
+
+var disposableDomains = map[string]bool{
+	"mailinator.com":    true,
+	"10minutemail.com":  true,
+	"guerrillamail.com": true,
+	"tempmail.com":      true,
+	"yopmail.com":       true,
+	"trashmail.com":     true,
+}
+
+type Assessment struct {
+	Score   int
+	Reasons []string
+}
+
+func (a *Assessment) add(reason string, weight int) {
+	a.Score += weight
+	a.Reasons = append(a.Reasons, reason)
+}
+
+// ScoreOrder evaluates a newly created order against the fraud rules, in
+// the transaction that creates it.
+func ScoreOrder(tx *gorm.DB, user *models.User, order *models.Order) Assessment {
+	var assessment Assessment
+
+	since := time.Now().Add(-time.Hour)
+	var recentOrders int64
+	tx.Model(&models.Order{}).
+		Where("buyer_id = ? AND created_at >= ?", user.ID, since).
+		Count(&recentOrders)
+	if recentOrders > 3 {
+		assessment.add("order_velocity", velocityWeight)
+	}
+
+	scoreAccount(&assessment, user)
+
+	// Guests have no account phone, only the contact number they check out with
+	phone := user.Phone
+	if order.ContactPhone != "" {
+		phone = order.ContactPhone
+	}
+	if phone != "" {
+		if region, ok := regions.Get(order.Region); ok && !region.ValidPhone(phone) {
+			assessment.add("region_mismatch", regionMismatchWeight)
+		}
+	}
+
+	var previousOrders int64
+	tx.Model(&models.Order{}).
+		Where("buyer_id = ? AND id != ?", user.ID, order.ID).
+		Count(&previousOrders)
+	if previousOrders == 0 && order.TotalAmount > 5000 {
+		assessment.add("large_first_order", largeFirstBuyWeight)
+	}
+
+	return assessment
+}
+
+// ScorePayment evaluates a payment attempt against the fraud rules.
+func ScorePayment(user *models.User, phone string) Assessment {
+	var assessment Assessment
+
+	since := time.Now().Add(-24 * time.Hour)
+	var failedPayments int64
+	database.DB.Model(&models.Payment{}).
+		Joins("JOIN orders ON payments.order_id = orders.id").
+		Where("orders.buyer_id = ? AND payments.status = ? AND payments.created_at >= ?", user.ID, models.PaymentFailed, since).
+		Count(&failedPayments)
+	if failedPayments >= 3 {
+		assessment.add("repeated_failed_payments", failedPaymentsWeight)
+	}
+
+	if phone != "" && phone != user.Phone {
+		assessment.add("phone_mismatch", phoneMismatchWeight)
+	}
+
+	scoreAccount(&assessment, user)
+
+	return assessment
+}
+
+// ScoreSettlement re-verifies an order's current total against the amount
+// its payment collected. Any reason returned means the order changed after
+// checkout, through cancelled items, released stock, a reversed coupon or a
+// cancelled order, and must be reviewed before it's confirmed. The order's Items must be
+// loaded.
+func ScoreSettlement(order *models.Order, amount float64) Assessment {
+	var assessment Assessment
+
+	if order.Status == models.OrderCancelled {
+		assessment.add("order_cancelled", settlementWeight)
+	}
+
+	if !sameAmount(order.TotalAmount, amount) {
+		assessment.add("total_mismatch", settlementWeight)
+	}
+
+	// Rebuild the total the way checkout did from what's left of the order
+	var total float64
+	for _, item := range order.Items {
+		if item.Status != models.ItemCancelled {
+			total += item.Price * float64(item.Quantity)
+		}
+	}
+	total -= order.DiscountAmount
+	region, ok := regions.Get(order.Region)
+	if !ok {
+		region = regions.Default()
+	}
+	if !region.Tax.Inclusive {
+		total += order.TaxAmount
+	}
+	total += order.ShippingAmount
+	if !sameAmount(total, order.TotalAmount) {
+		assessment.add("items_changed", settlementWeight)
+	}
+
+	// Stock released while the payment was outstanding may have sold since
+	var released int64
+	database.DB.Model(&models.StockReservation{}).
+		Where("order_id = ? AND status = ?", order.ID, models.ReservationReleased).
+		Count(&released)
+	if released > 0 {
+		assessment.add("stock_released", settlementWeight)
+	}
+
+	// The discount must still be backed by the coupon's redemption
+	if order.CouponCode != "" || order.DiscountAmount > 0 {
+		var redemption models.CouponRedemption
+		if err := database.DB.Where("order_id = ?", order.ID).First(&redemption).Error; err != nil ||
+			!sameAmount(redemption.Discount, order.DiscountAmount) {
+			assessment.add("coupon_changed", settlementWeight)
+		}
+	}
+
+	return assessment
+}
+
+// sameAmount compares two money amounts to the cent
+func sameAmount(a, b float64) bool {
+	return math.Abs(a-b) < 0.005
+}
+
+func scoreAccount(assessment *Assessment, user *models.User) {
+	if time.Since(user.CreatedAt) < 24*time.Hour {
+		assessment.add("new_account", newAccountWeight)
+	}
+
+	if at := strings.LastIndex(user.Email, "@"); at >= 0 {
+		if disposableDomains[strings.ToLower(user.Email[at+1:])] {
+			assessment.add("disposable_email", disposableWeight)
+		}
+	}
+}
+
+// Flag queues the order for manual review without changing its status.
+func Flag(tx *gorm.DB, order *models.Order, stage string, assessment Assessment) error {
+	record := models.FraudAssessment{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		OrderID:   order.ID,
+		UserID:    order.BuyerID,
+		Stage:     stage,
+		Score:     assessment.Score,
+		Reasons:   strings.Join(assessment.Reasons, ","),
+		Status:    models.FraudReviewPending,
+	}
+
+	return tx.Create(&record).Error
+}
+
+// Hold places the order on hold and queues it for manual review.
+func Hold(tx *gorm.DB, order *models.Order, stage string, assessment Assessment) error {
+	if err := Flag(tx, order, stage, assessment); err != nil {
+		return err
+	}
+
+	order.Status = models.OrderOnHold
+	return tx.Model(&models.Order{}).Where("id = ?", order.ID).Update("status", models.OrderOnHold).Error
+}
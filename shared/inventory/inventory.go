@@ -0,0 +1,170 @@
+package inventory
+
+import (
+	"fmt"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// allocation is a backordered or pre-ordered item that received stock
+type allocation struct {
+	BuyerID     uuid.UUID
+	OrderNumber string
+}
+
+// AllocateBackorders hands newly arrived stock to order items waiting on the
+// product, oldest order first, and returns the stock left over. Allocation
+// stops at the first item that doesn't fit so later orders can't jump the
+// queue.
+func AllocateBackorders(productID uuid.UUID) (int, error) {
+	var product models.Product
+	var allocated []allocation
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, productID).Error; err != nil {
+			return err
+		}
+
+		items, err := waitingItems(tx, "order_items.product_id = ? AND order_items.variant_id IS NULL", productID)
+		if err != nil {
+			return err
+		}
+
+		stock, err := allocate(tx, items, product.Stock, &allocated)
+		if err != nil || stock == product.Stock {
+			return err
+		}
+		product.Stock = stock
+		return tx.Model(&product).Update("stock", stock).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	notifyAllocated(allocated, product.Name)
+	return product.Stock, nil
+}
+
+// AllocateVariantBackorders is AllocateBackorders for the items waiting on
+// one variant of a product
+func AllocateVariantBackorders(variantID uuid.UUID) (int, error) {
+	var variant models.ProductVariant
+	var product models.Product
+	var allocated []allocation
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&variant, variantID).Error; err != nil {
+			return err
+		}
+		if err := tx.Select("id", "name").First(&product, variant.ProductID).Error; err != nil {
+			return err
+		}
+
+		items, err := waitingItems(tx, "order_items.variant_id = ?", variantID)
+		if err != nil {
+			return err
+		}
+
+		stock, err := allocate(tx, items, variant.Stock, &allocated)
+		if err != nil || stock == variant.Stock {
+			return err
+		}
+		variant.Stock = stock
+		if err := tx.Model(&variant).Update("stock", stock).Error; err != nil {
+			return err
+		}
+		return SyncVariantStock(tx, product.ID)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	notifyAllocated(allocated, fmt.Sprintf("%s (%s)", product.Name, variant.Label()))
+	return variant.Stock, nil
+}
+
+// SyncVariantStock sets a product's stock to the total of its active
+// variants'. Products without variants keep their own stock.
+func SyncVariantStock(tx *gorm.DB, productID uuid.UUID) error {
+	var variants int64
+	if err := tx.Model(&models.ProductVariant{}).Where("product_id = ?", productID).Count(&variants).Error; err != nil {
+		return err
+	}
+	if variants == 0 {
+		return nil
+	}
+
+	return tx.Model(&models.Product{}).Where("id = ?", productID).Update("stock",
+		tx.Model(&models.ProductVariant{}).Select("COALESCE(SUM(stock), 0)").
+			Where("product_id = ? AND is_active = ?", productID, true)).Error
+}
+
+// waitingItems returns the backordered and pre-ordered items matching the
+// condition that are still waiting for stock, oldest order first
+func waitingItems(tx *gorm.DB, condition string, args ...interface{}) ([]models.OrderItem, error) {
+	var items []models.OrderItem
+	err := tx.Preload("Order").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where(condition, args...).
+		Where("order_items.awaiting_stock = ?", true).
+		Where("order_items.status IN ?", []models.OrderItemStatus{models.ItemBackordered, models.ItemPreordered}).
+		Where("orders.status NOT IN ?", []models.OrderStatus{models.OrderCancelled, models.OrderOnHold}).
+		Order("orders.created_at ASC").
+		Find(&items).Error
+	return items, err
+}
+
+// allocate releases items in order while stock lasts and returns what's left
+func allocate(tx *gorm.DB, items []models.OrderItem, stock int, allocated *[]allocation) (int, error) {
+	for _, item := range items {
+		if item.Quantity > stock {
+			break
+		}
+		if err := tx.Model(&item).Updates(map[string]interface{}{
+			"awaiting_stock": false,
+			"status":         models.ItemPending,
+		}).Error; err != nil {
+			return stock, err
+		}
+		stock -= item.Quantity
+		*allocated = append(*allocated, allocation{BuyerID: item.Order.BuyerID, OrderNumber: item.Order.OrderNumber})
+	}
+	return stock, nil
+}
+
+func notifyAllocated(allocated []allocation, name string) {
+	for _, a := range allocated {
+		notifications.Send(a.BuyerID, models.NotificationOrder, "Your item is in stock",
+			fmt.Sprintf("%s from order %s is now in stock and will ship soon.", name, a.OrderNumber))
+	}
+}
+
+// AllocateItemBackorders allocates stock to the items waiting alongside an
+// order item, which wait on its variant when it has one
+func AllocateItemBackorders(productID uuid.UUID, variantID *uuid.UUID) (int, error) {
+	if variantID != nil {
+		return AllocateVariantBackorders(*variantID)
+	}
+	return AllocateBackorders(productID)
+}
+
+// Restock puts quantity units back into stock inside tx, into the variant's
+// stock when one is given
+func Restock(tx *gorm.DB, productID uuid.UUID, variantID *uuid.UUID, quantity int) error {
+	if variantID == nil {
+		return tx.Model(&models.Product{}).Where("id = ?", productID).
+			Update("stock", gorm.Expr("stock + ?", quantity)).Error
+	}
+
+	if err := tx.Model(&models.ProductVariant{}).Where("id = ?", *variantID).
+		Update("stock", gorm.Expr("stock + ?", quantity)).Error; err != nil {
+		return err
+	}
+	return SyncVariantStock(tx, productID)
+}
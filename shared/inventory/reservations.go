@@ -0,0 +1,134 @@
+package inventory
+
+import (
+	"errors"
+	"time"
+
+	"playful-marketplace/shared/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInsufficientStock is returned when stock can't be taken for an order
+var ErrInsufficientStock = errors.New("not enough stock left for the order")
+
+// Reserve records the stock the order's items took at checkout, held until
+// the order is paid or the reservation expires. Items waiting for stock
+// took none and aren't reserved.
+func Reserve(tx *gorm.DB, orderID uuid.UUID, items []models.OrderItem, ttl time.Duration) error {
+	expiresAt := time.Now().Add(ttl)
+
+	var reservations []models.StockReservation
+	for _, item := range items {
+		if item.AwaitingStock {
+			continue
+		}
+		reservations = append(reservations, models.StockReservation{
+			BaseModel:   models.BaseModel{ID: uuid.New()},
+			OrderID:     orderID,
+			OrderItemID: item.ID,
+			ProductID:   item.ProductID,
+			VariantID:   item.VariantID,
+			Quantity:    item.Quantity,
+			Status:      models.ReservationHeld,
+			ExpiresAt:   expiresAt,
+		})
+	}
+	if len(reservations) == 0 {
+		return nil
+	}
+	return tx.Create(&reservations).Error
+}
+
+// ConfirmReservations keeps the stock held for a paid order for good
+func ConfirmReservations(db *gorm.DB, orderID uuid.UUID) error {
+	return db.Model(&models.StockReservation{}).
+		Where("order_id = ? AND status = ?", orderID, models.ReservationHeld).
+		Update("status", models.ReservationConfirmed).Error
+}
+
+// ExtendReservations restarts the expiry of the stock held for an order
+func ExtendReservations(db *gorm.DB, orderID uuid.UUID, ttl time.Duration) error {
+	return db.Model(&models.StockReservation{}).
+		Where("order_id = ? AND status = ?", orderID, models.ReservationHeld).
+		Update("expires_at", time.Now().Add(ttl)).Error
+}
+
+// ReleaseReservations puts the order's reservations in the given statuses
+// back into stock inside tx and returns them, so the caller can allocate
+// the stock to waiting backorders once tx commits.
+func ReleaseReservations(tx *gorm.DB, orderID uuid.UUID, statuses ...models.ReservationStatus) ([]models.StockReservation, error) {
+	var reservations []models.StockReservation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_id = ? AND status IN ?", orderID, statuses).
+		Find(&reservations).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, reservation := range reservations {
+		if err := Restock(tx, reservation.ProductID, reservation.VariantID, reservation.Quantity); err != nil {
+			return nil, err
+		}
+		if err := tx.Model(&reservation).Updates(map[string]interface{}{
+			"status":      models.ReservationReleased,
+			"released_at": now,
+		}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return reservations, nil
+}
+
+// Rereserve takes stock again for an order whose reservations were released,
+// all of it or none, returning ErrInsufficientStock when it has run out.
+func Rereserve(tx *gorm.DB, orderID uuid.UUID, ttl time.Duration) error {
+	var reservations []models.StockReservation
+	if err := tx.Where("order_id = ? AND status = ?", orderID, models.ReservationReleased).
+		Find(&reservations).Error; err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	for _, reservation := range reservations {
+		if err := Take(tx, reservation.ProductID, reservation.VariantID, reservation.Quantity); err != nil {
+			return err
+		}
+		if err := tx.Model(&reservation).Updates(map[string]interface{}{
+			"status":      models.ReservationHeld,
+			"expires_at":  expiresAt,
+			"released_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Take removes quantity units from stock, from the variant's when one is
+// given, failing with ErrInsufficientStock rather than going below zero
+func Take(tx *gorm.DB, productID uuid.UUID, variantID *uuid.UUID, quantity int) error {
+	if variantID == nil {
+		result := tx.Model(&models.Product{}).Where("id = ? AND stock >= ?", productID, quantity).
+			Update("stock", gorm.Expr("stock - ?", quantity))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInsufficientStock
+		}
+		return nil
+	}
+
+	result := tx.Model(&models.ProductVariant{}).Where("id = ? AND stock >= ?", *variantID, quantity).
+		Update("stock", gorm.Expr("stock - ?", quantity))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInsufficientStock
+	}
+	return SyncVariantStock(tx, productID)
+}
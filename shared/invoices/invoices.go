@@ -0,0 +1,125 @@
+package invoices
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Number formats an invoice number in the seller's series
+func Number(sellerID uuid.UUID, number int64) string {
+	return fmt.Sprintf("INV-%s-%06d", strings.ToUpper(sellerID.String()[:8]), number)
+}
+
+// Issue invoices each seller on a paid order for their share of it, from
+// their own number sequence. Sellers already invoiced for the order are
+// skipped, so issuing again only returns the invoices that are new.
+func Issue(orderID uuid.UUID) ([]models.Invoice, error) {
+	var order models.Order
+	if err := database.DB.Preload("Items.Product").Preload("Shipping").First(&order, orderID).Error; err != nil {
+		return nil, err
+	}
+
+	var sellers []uuid.UUID
+	subtotals := make(map[uuid.UUID]float64)
+	for _, item := range order.Items {
+		if item.Status == models.ItemCancelled {
+			continue
+		}
+		sellerID := item.Product.SellerID
+		if _, ok := subtotals[sellerID]; !ok {
+			sellers = append(sellers, sellerID)
+		}
+		subtotals[sellerID] += item.Price * float64(item.Quantity)
+	}
+
+	shipping := make(map[uuid.UUID]float64, len(order.Shipping))
+	for _, charge := range order.Shipping {
+		shipping[charge.SellerID] = charge.Fee
+	}
+
+	// Seller coupons come off the issuing seller's invoice
+	var sellerCoupon models.Coupon
+	hasSellerCoupon := order.DiscountAmount > 0 &&
+		database.DB.Where("code = ? AND seller_id IS NOT NULL", order.CouponCode).First(&sellerCoupon).Error == nil
+
+	var issued []models.Invoice
+	for _, sellerID := range sellers {
+		invoice := models.Invoice{
+			BaseModel: models.BaseModel{ID: uuid.New()},
+			OrderID:   order.ID,
+			SellerID:  sellerID,
+			Subtotal:  roundAmount(subtotals[sellerID]),
+			Shipping:  shipping[sellerID],
+		}
+		if hasSellerCoupon && *sellerCoupon.SellerID == sellerID {
+			invoice.Discount = order.DiscountAmount
+		}
+		invoice.Total = roundAmount(invoice.Subtotal - invoice.Discount + invoice.Shipping)
+
+		ok, err := issue(&invoice)
+		if err != nil {
+			return issued, err
+		}
+		if ok {
+			issued = append(issued, invoice)
+		}
+	}
+	return issued, nil
+}
+
+// issue numbers and saves the invoice unless the seller already invoiced
+// the order. The number is taken from the sequence row under a lock in the
+// same transaction that saves the invoice, so numbers are allocated in
+// order and a failed invoice gives its number back.
+func issue(invoice *models.Invoice) (bool, error) {
+	issued := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.InvoiceSequence{SellerID: invoice.SellerID, NextNumber: 1}).Error; err != nil {
+			return err
+		}
+
+		var sequence models.InvoiceSequence
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("seller_id = ?", invoice.SellerID).First(&sequence).Error; err != nil {
+			return err
+		}
+
+		// Checked under the lock so concurrent payment completions can't both invoice
+		var existing int64
+		if err := tx.Model(&models.Invoice{}).
+			Where("order_id = ? AND seller_id = ?", invoice.OrderID, invoice.SellerID).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return nil
+		}
+
+		invoice.Number = sequence.NextNumber
+		invoice.InvoiceNumber = Number(invoice.SellerID, sequence.NextNumber)
+		invoice.IssuedAt = time.Now()
+		if err := tx.Create(invoice).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&sequence).Update("next_number", sequence.NextNumber+1).Error; err != nil {
+			return err
+		}
+		issued = true
+		return nil
+	})
+	return issued, err
+}
+
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
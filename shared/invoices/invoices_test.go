@@ -0,0 +1,41 @@
+package invoices
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestNumber(t *testing.T) {
+	sellerID := uuid.MustParse("3f1d7c2a-8b4e-4f6a-9c1d-2e5b7a9c0d13")
+
+	tests := []struct {
+		number int64
+		want   string
+	}{
+		{1, "INV-3F1D7C2A-000001"},
+		{42, "INV-3F1D7C2A-000042"},
+		{1234567, "INV-3F1D7C2A-1234567"},
+	}
+	for _, tt := range tests {
+		if got := Number(sellerID, tt.number); got != tt.want {
+			t.Errorf("Number(%d) = %s, want %s", tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestRoundAmount(t *testing.T) {
+	tests := []struct {
+		amount, want float64
+	}{
+		{1043.478, 1043.48},
+		{0.1 + 0.2, 0.3},
+		{19.994, 19.99},
+		{2400, 2400},
+	}
+	for _, tt := range tests {
+		if got := roundAmount(tt.amount); got != tt.want {
+			t.Errorf("roundAmount(%v) = %v, want %v", tt.amount, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"playful-marketplace/shared/config"
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/scheduler"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Handler runs one job from its JSON payload. Returning an error, or
+// panicking, retries the job with backoff until it runs out of attempts.
+// A job can run more than once if its worker dies mid-run, so handlers
+// should be safe to repeat.
+type Handler func(payload []byte) error
+
+var ErrNotFailed = errors.New("only failed jobs can be retried")
+
+// How long finished jobs are kept for inspection
+const completedRetention = 7 * 24 * time.Hour
+
+var (
+	handlers    = make(map[string]Handler)
+	maxAttempts = 5
+)
+
+// Handle registers the handler for a kind of job. Workers only pick up
+// kinds registered in their own process, so each service runs the jobs it
+// enqueues. Register handlers before calling Start.
+func Handle(kind string, handler Handler) {
+	handlers[kind] = handler
+}
+
+// Enqueue stores a job to run as soon as a worker is free
+func Enqueue(kind string, payload interface{}) error {
+	return EnqueueIn(kind, payload, 0)
+}
+
+// EnqueueIn stores a job to run once delay has passed
+func EnqueueIn(kind string, payload interface{}, delay time.Duration) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	job := models.Job{
+		BaseModel:   models.BaseModel{ID: uuid.New()},
+		Kind:        kind,
+		Payload:     string(data),
+		Status:      models.JobPending,
+		RunAt:       time.Now().Add(delay),
+		MaxAttempts: maxAttempts,
+	}
+	if err := database.DB.Create(&job).Error; err != nil {
+		log.Printf("Failed to enqueue %s job: %v", kind, err)
+		return err
+	}
+	return nil
+}
+
+// Start launches the workers for the registered kinds
+func Start(cfg config.JobsConfig) {
+	if cfg.MaxAttempts > 0 {
+		maxAttempts = cfg.MaxAttempts
+	}
+	if len(handlers) == 0 {
+		return
+	}
+
+	kinds := make([]string, 0, len(handlers))
+	for kind := range handlers {
+		kinds = append(kinds, kind)
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		go work(cfg, kinds)
+	}
+	scheduler.Every("jobs-prune", 24*time.Hour, prune)
+
+	log.Printf("Started %d job workers for %v", cfg.Workers, kinds)
+}
+
+// Retry puts a failed job back in the queue with a fresh set of attempts
+func Retry(jobID uuid.UUID) (*models.Job, error) {
+	var job models.Job
+	if err := database.DB.First(&job, jobID).Error; err != nil {
+		return nil, err
+	}
+	if job.Status != models.JobFailed {
+		return nil, ErrNotFailed
+	}
+
+	job.Status = models.JobPending
+	job.Attempts = 0
+	job.RunAt = time.Now()
+	if err := database.DB.Model(&job).Select("status", "attempts", "run_at").Updates(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func work(cfg config.JobsConfig, kinds []string) {
+	for {
+		job, err := claim(kinds, cfg.Timeout)
+		if err != nil {
+			log.Printf("Failed to claim job: %v", err)
+		}
+		if job == nil {
+			time.Sleep(cfg.PollInterval)
+			continue
+		}
+		run(job)
+	}
+}
+
+// claim takes the oldest due job, including running ones whose worker let
+// the lease lapse. SKIP LOCKED keeps workers across replicas from waiting
+// on each other's picks.
+func claim(kinds []string, timeout time.Duration) (*models.Job, error) {
+	var job models.Job
+	now := time.Now()
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("kind IN ?", kinds).
+			Where("((status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?))",
+				models.JobPending, now, models.JobRunning, now).
+			Order("run_at ASC").
+			First(&job).Error; err != nil {
+			return err
+		}
+
+		lockedUntil := now.Add(timeout)
+		job.Attempts++
+		return tx.Model(&job).Updates(map[string]interface{}{
+			"status":       models.JobRunning,
+			"attempts":     job.Attempts,
+			"locked_until": lockedUntil,
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func run(job *models.Job) {
+	err := call(handlers[job.Kind], job.Payload)
+	if err == nil {
+		database.DB.Model(job).Updates(map[string]interface{}{
+			"status":       models.JobCompleted,
+			"completed_at": time.Now(),
+			"locked_until": nil,
+		})
+		return
+	}
+
+	log.Printf("Job %s (%s) attempt %d/%d failed: %v", job.ID, job.Kind, job.Attempts, job.MaxAttempts, err)
+	updates := map[string]interface{}{
+		"status":       models.JobPending,
+		"run_at":       time.Now().Add(backoff(job.Attempts)),
+		"locked_until": nil,
+		"last_error":   err.Error(),
+	}
+	if job.Attempts >= job.MaxAttempts {
+		updates["status"] = models.JobFailed
+	}
+	database.DB.Model(job).Updates(updates)
+}
+
+func call(handler Handler, payload string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler([]byte(payload))
+}
+
+// backoff waits 10s, 40s, 90s, ... before the next attempt
+func backoff(attempts int) time.Duration {
+	return time.Duration(attempts*attempts) * 10 * time.Second
+}
+
+func prune() {
+	database.DB.Unscoped().Where("status = ? AND completed_at < ?", models.JobCompleted, time.Now().Add(-completedRetention)).
+		Delete(&models.Job{})
+}
@@ -0,0 +1,167 @@
+package ledger
+
+import (
+	"fmt"
+	"math"
+
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/stores"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Record writes ledger entries. Entries are keyed by reference, so
+// recording the same movement twice is a no-op.
+func Record(db *gorm.DB, entries ...models.LedgerEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	for i := range entries {
+		if entries[i].ID == uuid.Nil {
+			entries[i].ID = uuid.New()
+		}
+	}
+
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "reference"}},
+		DoNothing: true,
+	}).Create(&entries).Error
+}
+
+// RecordPayment records captured buyer funds.
+func RecordPayment(db *gorm.DB, payment *models.Payment) error {
+	return Record(db, models.LedgerEntry{
+		Type:        models.LedgerPayment,
+		Reference:   "payment:" + payment.ID.String(),
+		OrderID:     payment.OrderID,
+		PaymentID:   &payment.ID,
+		Amount:      payment.Amount,
+		Description: fmt.Sprintf("%s payment", payment.Method),
+	})
+}
+
+// RecordRelease splits released escrow funds into a platform fee and a
+// payout for each seller on the order.
+func RecordRelease(db *gorm.DB, payment *models.Payment, feePercent float64) error {
+	var sellerTotals []struct {
+		SellerID uuid.UUID
+		Total    float64
+	}
+	if err := db.Model(&models.OrderItem{}).
+		Select("products.seller_id, SUM(order_items.price * order_items.quantity) AS total").
+		Joins("JOIN products ON products.id = order_items.product_id").
+		Where("order_items.order_id = ?", payment.OrderID).
+		Group("products.seller_id").
+		Scan(&sellerTotals).Error; err != nil {
+		return err
+	}
+
+	var order models.Order
+	orderErr := db.First(&order, payment.OrderID).Error
+
+	// Seller coupons are funded by the issuing seller out of their payout
+	var sellerCoupon models.Coupon
+	sellerFunded := orderErr == nil && order.DiscountAmount > 0 &&
+		db.Where("code = ? AND seller_id IS NOT NULL", order.CouponCode).First(&sellerCoupon).Error == nil
+	if sellerFunded {
+		for i := range sellerTotals {
+			if sellerTotals[i].SellerID == *sellerCoupon.SellerID {
+				sellerTotals[i].Total -= order.DiscountAmount
+			}
+		}
+	}
+
+	// Sellers collect their shipping fees in full, without a platform fee
+	var charges []models.OrderShipping
+	if err := db.Where("order_id = ?", payment.OrderID).Find(&charges).Error; err != nil {
+		return err
+	}
+	shipping := make(map[uuid.UUID]float64, len(charges))
+	for _, charge := range charges {
+		shipping[charge.SellerID] = charge.Fee
+	}
+
+	var entries []models.LedgerEntry
+	for _, seller := range sellerTotals {
+		sellerID := seller.SellerID
+		storeID := stores.StoreID(db, sellerID)
+		fee := roundAmount(seller.Total * feePercent / 100)
+
+		entries = append(entries,
+			models.LedgerEntry{
+				Type:        models.LedgerPlatformFee,
+				Reference:   fmt.Sprintf("fee:%s:%s", payment.ID, sellerID),
+				OrderID:     payment.OrderID,
+				PaymentID:   &payment.ID,
+				SellerID:    &sellerID,
+				StoreID:     storeID,
+				Amount:      fee,
+				Description: fmt.Sprintf("%.2f%% platform fee", feePercent),
+			},
+			models.LedgerEntry{
+				Type:        models.LedgerSellerPayout,
+				Reference:   fmt.Sprintf("payout:%s:%s", payment.ID, sellerID),
+				OrderID:     payment.OrderID,
+				PaymentID:   &payment.ID,
+				SellerID:    &sellerID,
+				StoreID:     storeID,
+				Amount:      roundAmount(seller.Total - fee + shipping[sellerID]),
+				Description: "Seller payout",
+			},
+		)
+	}
+
+	// Otherwise sellers are paid on full item prices and the platform funds the discount
+	if orderErr == nil && order.DiscountAmount > 0 && !sellerFunded {
+		entries = append(entries, models.LedgerEntry{
+			Type:        models.LedgerDiscount,
+			Reference:   "discount:" + payment.ID.String(),
+			OrderID:     payment.OrderID,
+			PaymentID:   &payment.ID,
+			Amount:      order.DiscountAmount,
+			Description: "Coupon " + order.CouponCode,
+		})
+	}
+
+	return Record(db, entries...)
+}
+
+// RecordRefund records a refund issued for a return.
+func RecordRefund(db *gorm.DB, returnRequest *models.ReturnRequest) error {
+	return Record(db, models.LedgerEntry{
+		Type:        models.LedgerRefund,
+		Reference:   "refund:" + returnRequest.ID.String(),
+		OrderID:     returnRequest.OrderID,
+		ReturnID:    &returnRequest.ID,
+		SellerID:    &returnRequest.SellerID,
+		StoreID:     stores.StoreID(db, returnRequest.SellerID),
+		Amount:      returnRequest.RefundAmount,
+		Description: "Refund for " + returnRequest.RMANumber,
+	})
+}
+
+// RecordProtectionPayout records a buyer protection claim paid to the buyer.
+// Funds still held in escrow go back to the buyer; once they have been
+// released to sellers the platform's protection fund pays instead.
+func RecordProtectionPayout(db *gorm.DB, issue *models.OrderIssue, payment *models.Payment, fromEscrow bool) error {
+	entry := models.LedgerEntry{
+		Type:        models.LedgerProtection,
+		Reference:   "protection:" + issue.ID.String(),
+		OrderID:     issue.OrderID,
+		PaymentID:   &payment.ID,
+		Amount:      issue.PayoutAmount,
+		Description: "Buyer protection paid from the protection fund",
+	}
+	if fromEscrow {
+		entry.Type = models.LedgerEscrowRefund
+		entry.Description = "Buyer protection paid from held escrow"
+	}
+	return Record(db, entry)
+}
+
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,142 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/webhooks"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/google/uuid"
+)
+
+// authCheckInterval is how often a live connection re-checks that the
+// session it was opened with is still good
+const authCheckInterval = time.Minute
+
+// EventType names what happened to one of a seller's orders
+type EventType string
+
+const (
+	EventConnected        EventType = "connected" // Sent first, once the seller is subscribed
+	EventOrderCreated     EventType = "order.created"
+	EventPaymentCompleted EventType = "payment.completed"
+	EventPaymentFailed    EventType = "payment.failed"
+)
+
+// Event is pushed to a seller's live order dashboard. Amount and Items only
+// count the seller's own items on the order.
+type Event struct {
+	Type        EventType          `json:"type"`
+	OrderID     *uuid.UUID         `json:"order_id,omitempty"`
+	OrderNumber string             `json:"order_number,omitempty"`
+	OrderStatus models.OrderStatus `json:"order_status,omitempty"`
+	Amount      float64            `json:"amount,omitempty"`
+	Items       int                `json:"items,omitempty"`
+	At          time.Time          `json:"at"`
+}
+
+// PublishOrder tells every seller with items on the order about the event,
+// on their live dashboard and through their webhook subscriptions
+func PublishOrder(orderID uuid.UUID, eventType EventType) {
+	var order models.Order
+	if err := database.DB.Preload("Items.Product").First(&order, orderID).Error; err != nil {
+		log.Printf("Failed to load order %s for live event: %v", orderID, err)
+		return
+	}
+
+	var sellers []uuid.UUID
+	events := make(map[uuid.UUID]*Event)
+	for _, item := range order.Items {
+		if item.Status == models.ItemCancelled {
+			continue
+		}
+		sellerID := item.Product.SellerID
+		event, ok := events[sellerID]
+		if !ok {
+			event = &Event{
+				Type:        eventType,
+				OrderID:     &order.ID,
+				OrderNumber: order.OrderNumber,
+				OrderStatus: order.Status,
+				At:          time.Now(),
+			}
+			events[sellerID] = event
+			sellers = append(sellers, sellerID)
+		}
+		event.Amount += item.Price * float64(item.Quantity)
+		event.Items += item.Quantity
+	}
+
+	for _, sellerID := range sellers {
+		publish(sellerID, events[sellerID])
+		webhooks.Dispatch(sellerID, string(eventType), events[sellerID])
+	}
+}
+
+func publish(sellerID uuid.UUID, event *Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	if err := redis.PublishSellerEvent(sellerID, payload); err != nil {
+		log.Printf("Failed to publish live event to seller %s: %v", sellerID, err)
+	}
+}
+
+// Stream pushes the seller's events to conn until the client goes away, or
+// until authorized reports the session it was opened with has ended.
+// Events are fanned out through Redis, so the seller gets them whichever
+// instance they're connected to.
+func Stream(conn *websocket.Conn, sellerID uuid.UUID, authorized func() bool) {
+	pubsub := redis.SubscribeSellerEvents(sellerID)
+	defer pubsub.Close()
+
+	// Wait for the subscription so nothing published after "connected" is missed
+	if _, err := pubsub.Receive(context.Background()); err != nil {
+		log.Printf("Failed to subscribe seller %s to live events: %v", sellerID, err)
+		return
+	}
+
+	done := watch(conn)
+
+	hello, _ := json.Marshal(Event{Type: EventConnected, At: time.Now()})
+	if err := writeText(conn, hello); err != nil {
+		return
+	}
+
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+	recheck := time.NewTicker(authCheckInterval)
+	defer recheck.Stop()
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-done:
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			if err := writeText(conn, []byte(msg.Payload)); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+		case <-recheck.C:
+			// Logged out, revoked, suspended or no longer a seller
+			if !authorized() {
+				closeWith(conn, websocket.ClosePolicyViolation, "session ended")
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package live
+
+import (
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingInterval   = pongWait * 9 / 10
+	maxMessageSize = 4096
+)
+
+// watch reads from the connection so pings and close frames are answered,
+// and returns a channel closed once the client hangs up or stops answering
+// pings. Messages the client sends are discarded.
+func watch(conn *websocket.Conn) <-chan struct{} {
+	done := make(chan struct{})
+
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	return done
+}
+
+// writeText sends a text message
+func writeText(conn *websocket.Conn, data []byte) error {
+	conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// closeWith tells the client why the server is ending the connection
+func closeWith(conn *websocket.Conn, code int, reason string) {
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(writeWait))
+}
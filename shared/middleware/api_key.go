@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"time"
+
+	"playful-marketplace/shared/config"
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyMiddleware authenticates server-to-server callers by the X-API-Key
+// header. The key must carry the resource scope for the request: GET and
+// HEAD need "<resource>:read", everything else "<resource>:write". The key
+// acts on behalf of its owner in their active profile, so ownership checks
+// in handlers still apply.
+func APIKeyMiddleware(resource string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(APIKeyHeader)
+		if key == "" {
+			return utils.UnauthorizedResponse(c, "API key required")
+		}
+
+		var apiKey models.APIKey
+		if err := database.DB.Where("key_hash = ?", utils.HashAPIKey(key)).First(&apiKey).Error; err != nil || !apiKey.IsUsable() {
+			return utils.UnauthorizedResponse(c, "Invalid or revoked API key")
+		}
+
+		scope := requestScope(c, resource)
+		if !grants(apiKey.ScopeList(), scope) {
+			return utils.ErrorResponse(c, fiber.StatusForbidden, "API key is missing the "+scope+" scope", nil)
+		}
+
+		var owner models.User
+		if err := database.DB.First(&owner, apiKey.OwnerID).Error; err != nil || !owner.IsActive {
+			return utils.UnauthorizedResponse(c, "API key owner is not active")
+		}
+		if isSuspended(owner.ID) {
+			return utils.ErrorResponse(c, fiber.StatusForbidden, "Account is suspended", nil)
+		}
+
+		// Store owner info in context, same as AuthMiddleware
+		c.Locals("user_id", owner.ID)
+		c.Locals("user_phone", owner.Phone)
+		c.Locals("user_role", owner.Role)
+		c.Locals("user_roles", owner.Roles)
+		c.Locals("api_key", &apiKey)
+
+		go database.DB.Model(&models.APIKey{}).Where("id = ?", apiKey.ID).Update("last_used_at", time.Now())
+
+		return c.Next()
+	}
+}
+
+// AuthOrAPIKeyMiddleware accepts either a user JWT or an API key scoped to
+// the resource.
+func AuthOrAPIKeyMiddleware(cfg *config.Config, resource string) fiber.Handler {
+	jwtAuth := ScopedAuthMiddleware(cfg, resource)
+	keyAuth := APIKeyMiddleware(resource)
+
+	return func(c *fiber.Ctx) error {
+		if c.Get(APIKeyHeader) != "" {
+			return keyAuth(c)
+		}
+		return jwtAuth(c)
+	}
+}
+
+// requestScope returns the scope a request needs on the resource
+func requestScope(c *fiber.Ctx, resource string) string {
+	if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+		return resource + ":read"
+	}
+	return resource + ":write"
+}
@@ -1,17 +1,36 @@
 package middleware
 
 import (
+	"errors"
+	"log"
 	"strings"
 
 	"playful-marketplace/shared/config"
+	"playful-marketplace/shared/database"
 	"playful-marketplace/shared/models"
 	"playful-marketplace/shared/redis"
 	"playful-marketplace/shared/utils"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
+// AuthMiddleware authenticates a user JWT. Tokens minted with limited
+// scopes and store tokens are refused; routes that accept them use
+// ScopedAuthMiddleware.
 func AuthMiddleware(cfg *config.Config) fiber.Handler {
+	return authenticate(cfg, "")
+}
+
+// ScopedAuthMiddleware authenticates a user JWT like AuthMiddleware, and
+// also accepts limited-scope tokens that carry the resource scope for the
+// request: GET and HEAD need "<resource>:read", everything else
+// "<resource>:write".
+func ScopedAuthMiddleware(cfg *config.Config, resource string) fiber.Handler {
+	return authenticate(cfg, resource)
+}
+
+func authenticate(cfg *config.Config, resource string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
@@ -29,22 +48,89 @@ func AuthMiddleware(cfg *config.Config) fiber.Handler {
 			return utils.UnauthorizedResponse(c, "Invalid token")
 		}
 
-		// Check if session exists in Redis
+		// Check if session exists in Redis. While Redis is down the token
+		// stands on its own: ValidateJWT has checked its signature, expiry
+		// and revocation, the last against the database.
 		session, err := redis.GetSession(token)
-		if err != nil {
+		if errors.Is(err, redis.ErrUnavailable) {
+			session = &models.Session{UserID: claims.UserID, Token: token}
+			if claims.ExpiresAt != nil {
+				session.ExpiresAt = claims.ExpiresAt.Time
+			}
+			if claims.IssuedAt != nil {
+				session.CreatedAt = claims.IssuedAt.Time
+			}
+		} else if err != nil {
 			return utils.UnauthorizedResponse(c, "Session expired or invalid")
 		}
 
+		// Suspended and banned users are locked out even with a live session
+		if isSuspended(claims.UserID) {
+			return utils.ErrorResponse(c, fiber.StatusForbidden, "Account is suspended", nil)
+		}
+
+		// Store tokens act for a seller, never on the seller's account
+		if claims.ActorID != nil && resource == "" {
+			return utils.ErrorResponse(c, fiber.StatusForbidden, "Store tokens cannot be used on this endpoint", nil)
+		}
+
+		// Limited-scope tokens only work where the route group opts in
+		if len(claims.Scopes) > 0 {
+			if resource == "" {
+				return utils.ErrorResponse(c, fiber.StatusForbidden, "Token scopes do not allow this endpoint", nil)
+			}
+			if scope := requestScope(c, resource); !grants(claims.Scopes, scope) {
+				return utils.ErrorResponse(c, fiber.StatusForbidden, "Token is missing the "+scope+" scope", nil)
+			}
+		}
+
 		// Store user info in context
 		c.Locals("user_id", claims.UserID)
 		c.Locals("user_phone", claims.Phone)
 		c.Locals("user_role", claims.Role)
+		c.Locals("user_roles", claims.Roles)
+		c.Locals("seller_verified", claims.SellerVerified)
+		c.Locals("features", claims.Features)
+		c.Locals("language", claims.Language)
+		c.Locals("currency", claims.Currency)
+		c.Locals("token_scopes", claims.Scopes)
+		if claims.ActorID != nil {
+			c.Locals("actor_id", *claims.ActorID)
+		}
 		c.Locals("session", session)
 
 		return c.Next()
 	}
 }
 
+// isSuspended checks the suspension flag in Redis, or the account itself
+// while Redis is unavailable
+func isSuspended(userID uuid.UUID) bool {
+	if redis.Available() {
+		return redis.IsUserSuspended(userID.String())
+	}
+	var user models.User
+	if err := database.DB.Select("account_status", "suspended_until").First(&user, userID).Error; err != nil {
+		return false
+	}
+	return user.IsSuspended()
+}
+
+// OptionalAuthMiddleware authenticates requests that carry a token and lets
+// anonymous requests through, for endpoints that tailor public content
+func OptionalAuthMiddleware(cfg *config.Config) fiber.Handler {
+	auth := AuthMiddleware(cfg)
+	return func(c *fiber.Ctx) error {
+		if c.Get(fiber.HeaderAuthorization) == "" {
+			return c.Next()
+		}
+		return auth(c)
+	}
+}
+
+// RoleMiddleware requires the active profile to be one of the roles.
+// Accounts holding an allowed role under another profile are told to switch
+// rather than refused outright.
 func RoleMiddleware(allowedRoles ...models.UserRole) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		userRole, ok := c.Locals("user_role").(models.UserRole)
@@ -58,15 +144,37 @@ func RoleMiddleware(allowedRoles ...models.UserRole) fiber.Handler {
 			}
 		}
 
+		userRoles, _ := c.Locals("user_roles").(models.UserRoles)
+		for _, role := range allowedRoles {
+			if userRoles.Has(role) {
+				return utils.ErrorResponse(c, fiber.StatusForbidden, "Switch to your "+string(role)+" profile to use this endpoint", nil)
+			}
+		}
+
 		return utils.ErrorResponse(c, fiber.StatusForbidden, "Insufficient permissions", nil)
 	}
 }
 
+// FeatureMiddleware requires the feature flag to be embedded in the token.
+// Must run after AuthMiddleware.
+func FeatureMiddleware(feature string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		features, _ := c.Locals("features").([]string)
+		for _, f := range features {
+			if f == feature {
+				return c.Next()
+			}
+		}
+
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "Feature not enabled", nil)
+	}
+}
+
 func CORSMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		c.Set("Access-Control-Allow-Origin", "*")
 		c.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
+		c.Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-API-Key, X-Request-ID")
 
 		if c.Method() == "OPTIONS" {
 			return c.SendStatus(fiber.StatusOK)
@@ -89,7 +197,7 @@ func LoggingMiddleware() fiber.Handler {
 		
 		// Log format: METHOD PATH IP STATUS
 		if !strings.HasPrefix(path, "/health") { // Don't log health checks
-			fiber.DefaultLogger.Printf("%s %s %s %d\n", method, path, ip, status)
+			log.Printf("%s %s %s %d", method, path, ip, status)
 		}
 		
 		return err
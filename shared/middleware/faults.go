@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/config"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// faultRule is one parsed entry of config.FaultConfig.Rules
+type faultRule struct {
+	method    string // "*" matches any method
+	prefix    string
+	latency   time.Duration
+	errorRate float64 // 0 to 1
+	blackhole string  // Dependency to treat as unreachable, e.g. redis or database
+}
+
+func (r faultRule) matches(c *fiber.Ctx) bool {
+	return (r.method == "*" || r.method == c.Method()) && strings.HasPrefix(c.Path(), r.prefix)
+}
+
+// FaultMiddleware injects the faults configured for matching routes. The
+// first matching rule applies. Blackholed dependencies are simulated at the
+// route: the request hangs for the blackhole timeout and then fails as the
+// handler would when the dependency can't be reached.
+func FaultMiddleware(cfg *config.Config) fiber.Handler {
+	if !cfg.Faults.Enabled {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	var rules []faultRule
+	for _, spec := range cfg.Faults.Rules {
+		rule, err := parseFaultRule(spec)
+		if err != nil {
+			log.Printf("Ignoring fault rule %q: %v", spec, err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	log.Printf("Fault injection enabled with %d rules", len(rules))
+
+	return func(c *fiber.Ctx) error {
+		if cfg.Faults.Key != "" && c.Get("X-Fault-Injection") != cfg.Faults.Key {
+			return c.Next()
+		}
+
+		for _, rule := range rules {
+			if !rule.matches(c) {
+				continue
+			}
+
+			if rule.latency > 0 {
+				c.Append("X-Fault-Injected", "latency")
+				time.Sleep(rule.latency)
+			}
+			if rule.blackhole != "" {
+				c.Append("X-Fault-Injected", "blackhole:"+rule.blackhole)
+				time.Sleep(cfg.Faults.BlackholeTimeout)
+				return utils.ErrorResponse(c, fiber.StatusServiceUnavailable,
+					fmt.Sprintf("Service dependency %s is unavailable", rule.blackhole), nil)
+			}
+			if rule.errorRate > 0 && rand.Float64() < rule.errorRate {
+				c.Append("X-Fault-Injected", "error")
+				return utils.InternalServerErrorResponse(c, "Injected fault", nil)
+			}
+			break
+		}
+
+		return c.Next()
+	}
+}
+
+// parseFaultRule reads "METHOD /path-prefix key=value ..." where the keys are
+// latency, error_rate and blackhole
+func parseFaultRule(spec string) (faultRule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) < 3 {
+		return faultRule{}, fmt.Errorf("expected method, path prefix and at least one fault")
+	}
+
+	rule := faultRule{method: strings.ToUpper(fields[0]), prefix: fields[1]}
+	for _, field := range fields[2:] {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return faultRule{}, fmt.Errorf("fault %q is not key=value", field)
+		}
+
+		switch key {
+		case "latency":
+			latency, err := time.ParseDuration(value)
+			if err != nil {
+				return faultRule{}, fmt.Errorf("invalid latency: %w", err)
+			}
+			rule.latency = latency
+		case "error_rate":
+			rate, err := strconv.ParseFloat(value, 64)
+			if err != nil || rate < 0 || rate > 1 {
+				return faultRule{}, fmt.Errorf("error_rate must be between 0 and 1")
+			}
+			rule.errorRate = rate
+		case "blackhole":
+			rule.blackhole = value
+		default:
+			return faultRule{}, fmt.Errorf("unknown fault %q", key)
+		}
+	}
+	return rule, nil
+}
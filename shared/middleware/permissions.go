@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"strings"
+
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Permissions are "resource:action" strings. A "*" action grants every
+// action on the resource and a bare "*" grants everything.
+const (
+	PermProductsRead   = "products:read"
+	PermProductsWrite  = "products:write"
+	PermOrdersRead     = "orders:read"
+	PermOrdersCreate   = "orders:create"
+	PermOrdersWrite    = "orders:write"
+	PermPaymentsRead   = "payments:read"
+	PermPaymentsWrite  = "payments:write"
+	PermReviewsWrite   = "reviews:write"
+	PermInventoryWrite = "inventory:write"
+	PermCatalogWrite   = "catalog:write"
+	PermUsersRead      = "users:read"
+	PermUsersWrite     = "users:write"
+	PermFraudReview    = "fraud:review"
+	PermModeration     = "moderation:review"
+	PermReportsRead    = "reports:read"
+	PermCampaignsWrite = "campaigns:write"
+	PermSegmentsRead   = "segments:read" // Segment members, for services targeting campaigns
+	PermGamifyWrite    = "gamification:write"
+	PermTokensWrite    = "tokens:write" // Token introspection for other services
+	PermSupport        = "support:manage"
+	PermJobsManage     = "jobs:manage"
+	PermMaintenance    = "maintenance:manage"
+)
+
+// Permission matrix per role
+var rolePermissions = map[models.UserRole][]string{
+	models.RoleBuyer: {
+		PermProductsRead,
+		PermOrdersRead,
+		PermOrdersCreate,
+		PermPaymentsRead,
+		PermPaymentsWrite,
+		PermReviewsWrite,
+	},
+	models.RoleSeller: {
+		PermProductsRead,
+		PermProductsWrite,
+		PermOrdersRead,
+		PermOrdersWrite,
+		PermPaymentsRead,
+		PermInventoryWrite,
+	},
+	models.RoleAdmin: {
+		"*",
+	},
+	models.RoleGuest: {
+		PermProductsRead,
+		PermOrdersRead,
+		PermOrdersCreate,
+		PermPaymentsRead,
+		PermPaymentsWrite,
+	},
+}
+
+// HasPermission reports whether the role grants the permission.
+func HasPermission(role models.UserRole, permission string) bool {
+	return grants(rolePermissions[role], permission)
+}
+
+// RolePermissions returns the permissions granted to the role.
+func RolePermissions(role models.UserRole) []string {
+	return append([]string(nil), rolePermissions[role]...)
+}
+
+// grants reports whether any of the granted permissions covers the permission.
+func grants(granted []string, permission string) bool {
+	resource := strings.SplitN(permission, ":", 2)[0]
+
+	for _, g := range granted {
+		if g == "*" || g == permission || g == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionMiddleware requires the authenticated user's role to grant all
+// of the given permissions. Must run after AuthMiddleware.
+func PermissionMiddleware(permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userRole, ok := c.Locals("user_role").(models.UserRole)
+		if !ok {
+			return utils.UnauthorizedResponse(c, "User role not found")
+		}
+
+		// API keys and limited-scope tokens are further limited to their scopes
+		apiKey, _ := c.Locals("api_key").(*models.APIKey)
+		tokenScopes, _ := c.Locals("token_scopes").([]string)
+
+		for _, permission := range permissions {
+			if !HasPermission(userRole, permission) {
+				return utils.ErrorResponse(c, fiber.StatusForbidden, "Insufficient permissions", nil)
+			}
+			if apiKey != nil && !grants(apiKey.ScopeList(), permission) {
+				return utils.ErrorResponse(c, fiber.StatusForbidden, "API key is missing the "+permission+" scope", nil)
+			}
+			if len(tokenScopes) > 0 && !grants(tokenScopes, permission) {
+				return utils.ErrorResponse(c, fiber.StatusForbidden, "Token is missing the "+permission+" scope", nil)
+			}
+		}
+
+		return c.Next()
+	}
+}
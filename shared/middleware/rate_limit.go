@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"playful-marketplace/shared/config"
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// RateLimitMiddleware caps each caller's requests per window. Signed-in
+// users are limited by the tier in their token, and API keys by their
+// owner's tier, sharing one allowance per account; anonymous callers are
+// counted per IP address. It identifies callers itself since authentication
+// is applied per route group, and lets requests through when Redis is
+// unreachable.
+func RateLimitMiddleware(cfg *config.Config) fiber.Handler {
+	limits := cfg.RateLimits
+
+	return func(c *fiber.Ctx) error {
+		if !limits.Enabled || c.Path() == "/health" {
+			return c.Next()
+		}
+
+		subject, tier := rateLimitCaller(c, cfg)
+		limit := limits.Limit(string(tier))
+
+		windowStart := time.Now().Truncate(limits.Window)
+		count, err := redis.CountRequest(subject, windowStart, limits.Window)
+		if err != nil {
+			log.Printf("Failed to count request for rate limiting: %v", err)
+			return c.Next()
+		}
+
+		remaining := limit - int(count)
+		if remaining < 0 {
+			remaining = 0
+		}
+		reset := windowStart.Add(limits.Window)
+		c.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+
+		if int(count) > limit {
+			retryAfter := int(time.Until(reset).Seconds()) + 1
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+			return utils.ProblemResponse(c, utils.Problem{
+				Status: fiber.StatusTooManyRequests,
+				Code:   "rate_limited",
+				Detail: "Too many requests, please slow down",
+				Data: fiber.Map{
+					"limit":       limit,
+					"retry_after": retryAfter,
+				},
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// rateLimitCaller names who the request counts against and their tier. An
+// empty tier is an anonymous caller. Invalid credentials count as anonymous,
+// authentication rejects them later.
+func rateLimitCaller(c *fiber.Ctx, cfg *config.Config) (string, models.RateLimitTier) {
+	if key := c.Get(APIKeyHeader); key != "" {
+		var owner struct {
+			OwnerID       uuid.UUID
+			RateLimitTier models.RateLimitTier
+		}
+		if err := database.DB.Model(&models.APIKey{}).
+			Select("api_keys.owner_id, users.rate_limit_tier").
+			Joins("JOIN users ON users.id = api_keys.owner_id").
+			Where("api_keys.key_hash = ?", utils.HashAPIKey(key)).
+			Take(&owner).Error; err == nil {
+			return "user:" + owner.OwnerID.String(), tierOrStandard(owner.RateLimitTier)
+		}
+	} else if token := utils.ExtractTokenFromHeader(c.Get(fiber.HeaderAuthorization)); token != "" {
+		if claims, err := utils.ValidateJWT(token, cfg); err == nil {
+			return "user:" + claims.UserID.String(), tierOrStandard(claims.RateLimitTier)
+		}
+	}
+
+	return "ip:" + c.IP(), ""
+}
+
+// tierOrStandard treats accounts without a tier, such as tokens issued
+// before tiers existed, as standard
+func tierOrStandard(tier models.RateLimitTier) models.RateLimitTier {
+	if tier == "" {
+		return models.RateLimitStandard
+	}
+	return tier
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// TraceMiddleware gives each request a trace ID, reusing the X-Request-ID a
+// gateway or client sent, and echoes it in the response header so error
+// reports can be matched to logs.
+func TraceMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		traceID := c.Get(fiber.HeaderXRequestID)
+		if traceID == "" || len(traceID) > 128 {
+			traceID = uuid.NewString()
+		}
+
+		c.Locals("trace_id", traceID)
+		c.Set(fiber.HeaderXRequestID, traceID)
+
+		return c.Next()
+	}
+}
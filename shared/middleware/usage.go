@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"log"
+	"time"
+
+	"playful-marketplace/shared/redis"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// UsageMiddleware counts each authenticated request towards the caller's API
+// usage. It reads the user after the handler runs, since authentication is
+// applied per route group. Recording happens in the background so Redis
+// latency doesn't slow responses.
+func UsageMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+
+		userID, ok := c.Locals("user_id").(uuid.UUID)
+		if !ok {
+			return err
+		}
+
+		// Route patterns keep the per endpoint counts bounded
+		endpoint := c.Method() + " " + c.Route().Path
+		at := time.Now()
+		go func() {
+			if err := redis.RecordAPIUsage(userID.String(), endpoint, at); err != nil {
+				log.Printf("Failed to record API usage for user %s: %v", userID, err)
+			}
+		}()
+
+		return err
+	}
+}
@@ -1,6 +1,11 @@
 package models
 
 import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,8 +26,66 @@ type UserRole string
 const (
 	RoleBuyer  UserRole = "buyer"
 	RoleSeller UserRole = "seller"
+	RoleAdmin  UserRole = "admin"
+	RoleGuest  UserRole = "guest" // Anonymous checkout session, converted via /auth/guest/convert
 )
 
+// UserRoles is the set of profiles an account holds, stored as a Postgres
+// text array
+type UserRoles []UserRole
+
+// RolesFor returns the profiles of a new account signing up as the role.
+// Sellers can always shop as buyers too.
+func RolesFor(role UserRole) UserRoles {
+	if role == RoleSeller {
+		return UserRoles{RoleBuyer, RoleSeller}
+	}
+	return UserRoles{role}
+}
+
+// Has reports whether the set holds the role
+func (r UserRoles) Has(role UserRole) bool {
+	for _, held := range r {
+		if held == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Value stores the set as a Postgres array literal
+func (r UserRoles) Value() (driver.Value, error) {
+	roles := make([]string, len(r))
+	for i, role := range r {
+		roles[i] = string(role)
+	}
+	return "{" + strings.Join(roles, ",") + "}", nil
+}
+
+// Scan reads the set from a Postgres array literal
+func (r *UserRoles) Scan(src interface{}) error {
+	var literal string
+	switch v := src.(type) {
+	case nil:
+		*r = nil
+		return nil
+	case string:
+		literal = v
+	case []byte:
+		literal = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into UserRoles", src)
+	}
+
+	*r = nil
+	for _, role := range strings.Split(strings.Trim(literal, "{}"), ",") {
+		if role != "" {
+			*r = append(*r, UserRole(role))
+		}
+	}
+	return nil
+}
+
 // User levels based on XP
 type UserLevel string
 
@@ -55,6 +118,7 @@ const (
 	OrderShipped    OrderStatus = "shipped"
 	OrderDelivered  OrderStatus = "delivered"
 	OrderCancelled  OrderStatus = "cancelled"
+	OrderOnHold     OrderStatus = "on_hold" // Held for fraud review
 )
 
 // Payment status
@@ -67,6 +131,15 @@ const (
 	PaymentRefunded  PaymentStatus = "refunded"
 )
 
+// Escrow status of payment funds owed to sellers
+type EscrowStatus string
+
+const (
+	EscrowHeld     EscrowStatus = "held"
+	EscrowReleased EscrowStatus = "released"
+	EscrowRefunded EscrowStatus = "refunded" // Returned to the buyer by a buyer protection claim
+)
+
 // Payment method
 type PaymentMethod string
 
@@ -82,13 +155,35 @@ type User struct {
 	Phone       string    `json:"phone" gorm:"uniqueIndex;not null"`
 	Name        string    `json:"name" gorm:"not null"`
 	Email       string    `json:"email" gorm:"uniqueIndex"`
-	Role        UserRole  `json:"role" gorm:"not null"`
+	PendingEmail          string     `json:"pending_email,omitempty"` // Applied once confirmed from the new address
+	PendingEmailExpiresAt *time.Time `json:"pending_email_expires_at,omitempty"`
+	EmailVerifiedAt       *time.Time `json:"email_verified_at,omitempty"` // Set once the address is confirmed by the user or a login provider
+	Role        UserRole  `json:"role" gorm:"not null"`     // Active profile, the one new sessions act as
+	Roles       UserRoles `json:"roles" gorm:"type:text[]"` // Every profile the account holds, switched between with /auth/profile
 	Level       UserLevel `json:"level" gorm:"default:'bronze'"`
 	TotalXP     int       `json:"total_xp" gorm:"default:0"`
 	TotalSpent  float64   `json:"total_spent" gorm:"default:0"`
 	TotalSales  float64   `json:"total_sales" gorm:"default:0"`
 	IsActive    bool      `json:"is_active" gorm:"default:true"`
 	LastLoginAt *time.Time `json:"last_login_at"`
+	TOTPSecret  string    `json:"-"`
+	TOTPEnabled bool      `json:"totp_enabled" gorm:"default:false"`
+	MarketingOptOut bool  `json:"marketing_opt_out" gorm:"default:false"`
+	SellerVerified  bool  `json:"seller_verified" gorm:"default:false"` // Identity confirmed through a seller verification
+	ReferralCode    *string `json:"referral_code,omitempty" gorm:"uniqueIndex"` // Sellers share it to refer new sellers
+	TokensValidAfter *time.Time `json:"-"` // Tokens issued before this are rejected
+	DigestFrequency  DigestFrequency `json:"digest_frequency" gorm:"default:'daily'"` // Delivery of non-urgent notifications
+	LastDigestAt     *time.Time      `json:"-"`
+	AvatarURL          string `json:"avatar_url"`
+	AvatarThumbnailURL string `json:"avatar_thumbnail_url"`
+	PreferredLanguage  string `json:"preferred_language" gorm:"default:'en'"` // Response messages when the client sends no Accept-Language
+	DisplayCurrency    string `json:"display_currency" gorm:"default:'ETB'"`
+	RateLimitTier      RateLimitTier `json:"rate_limit_tier" gorm:"default:'standard'"` // Set by admins for heavy API users
+
+	// Account standing set by admins
+	AccountStatus    AccountStatus `json:"account_status" gorm:"default:'active'"`
+	SuspendedUntil   *time.Time    `json:"suspended_until,omitempty"` // Nil for bans and open-ended suspensions
+	SuspensionReason string        `json:"suspension_reason,omitempty"`
 	
 	// Relationships
 	Products []Product `json:"products,omitempty" gorm:"foreignKey:SellerID"`
@@ -96,21 +191,304 @@ type User struct {
 	Badges   []UserBadge `json:"badges,omitempty" gorm:"foreignKey:UserID"`
 }
 
+// Request allowance of a signed-in user's calls
+type RateLimitTier string
+
+const (
+	RateLimitStandard    RateLimitTier = "standard"
+	RateLimitPowerSeller RateLimitTier = "power_seller" // Sellers using bulk and sync APIs
+	RateLimitPartner     RateLimitTier = "partner"      // Integration partners
+)
+
+// IsValid reports whether the tier is one of the known tiers
+func (t RateLimitTier) IsValid() bool {
+	switch t {
+	case RateLimitStandard, RateLimitPowerSeller, RateLimitPartner:
+		return true
+	}
+	return false
+}
+
+// Account standing
+type AccountStatus string
+
+const (
+	AccountActive    AccountStatus = "active"
+	AccountSuspended AccountStatus = "suspended"
+	AccountBanned    AccountStatus = "banned"
+)
+
+// HasRole reports whether the account holds the profile
+func (u *User) HasRole(role UserRole) bool {
+	return u.Role == role || u.Roles.Has(role)
+}
+
+// IsSuspended reports whether the user is banned or serving a suspension
+func (u *User) IsSuspended() bool {
+	switch u.AccountStatus {
+	case AccountBanned:
+		return true
+	case AccountSuspended:
+		return u.SuspendedUntil == nil || time.Now().Before(*u.SuspendedUntil)
+	}
+	return false
+}
+
 // Product model
 type Product struct {
 	BaseModel
 	Name        string  `json:"name" gorm:"not null"`
+	SKU         string  `json:"sku" gorm:"index"`
 	Description string  `json:"description"`
 	Price       float64 `json:"price" gorm:"not null"`
 	Stock       int     `json:"stock" gorm:"default:0"`
 	Category    string  `json:"category"`
-	ImageURL    string  `json:"image_url"`
+	ImageURL    string  `json:"image_url"` // Mirrors the primary image, for listings and feeds
+	Attributes  map[string]string `json:"attributes,omitempty" gorm:"serializer:json"`
 	IsActive    bool    `json:"is_active" gorm:"default:true"`
 	SellerID    uuid.UUID `json:"seller_id" gorm:"not null"`
+	StoreID     *uuid.UUID `json:"store_id" gorm:"index"` // Business that owns the listing
+
+	// Selling beyond stock
+	Availability ProductAvailability `json:"availability" gorm:"default:'in_stock'"`
+	ExpectedAt   *time.Time          `json:"expected_at"` // When backordered or pre-ordered stock is due
+
+	InventoryEditedAt *time.Time `json:"-"` // Last stock or price change by the seller, which inventory sync treats as conflicting; orders don't count
+
+	// Low stock alerts to the seller
+	LowStockThreshold int        `json:"low_stock_threshold" gorm:"default:0"` // Stock at or below which the seller is alerted, 0 only alerts when sold out
+	StockAlert        StockLevel `json:"-" gorm:"default:''"`                  // Level the seller was last alerted about, cleared once restocked
+
+	// Purchase quantity rules, 0 means no limit
+	MinOrderQuantity int `json:"min_order_quantity" gorm:"default:0"` // Per order
+	MaxOrderQuantity int `json:"max_order_quantity" gorm:"default:0"` // Per order
+	PerCustomerLimit int `json:"per_customer_limit" gorm:"default:0"` // Across all of a buyer's orders
+
+	SavedCount int `json:"saved_count" gorm:"default:0"` // Users with the product on their wishlist
+
+	// Sale pricing, in effect from SaleStartsAt until SaleEndsAt
+	SalePrice       *float64   `json:"sale_price"`
+	SaleStartsAt    *time.Time `json:"sale_starts_at"`                     // Empty starts the sale as soon as it's set
+	SaleEndsAt      *time.Time `json:"sale_ends_at"`                       // Empty runs the sale until it's removed
+	OnSale          bool       `json:"on_sale" gorm:"-"`                   // Filled in on read
+	DiscountPercent int        `json:"discount_percent,omitempty" gorm:"-"` // Off Price while on sale, filled in on read
+
+	// Storefront URL and search engine metadata
+	Slug            *string `json:"slug" gorm:"uniqueIndex"` // Assigned from the name when not chosen by the seller
+	MetaTitle       string  `json:"meta_title"`
+	MetaDescription string  `json:"meta_description"`
 	
 	// Relationships
-	Seller     User        `json:"seller,omitempty" gorm:"foreignKey:SellerID"`
+	Seller     *SellerSummary `json:"seller,omitempty" gorm:"foreignKey:SellerID"`
 	OrderItems []OrderItem `json:"order_items,omitempty" gorm:"foreignKey:ProductID"`
+	PriceTiers []PriceTier `json:"price_tiers,omitempty" gorm:"foreignKey:ProductID"`
+	Variants   []ProductVariant `json:"variants,omitempty" gorm:"foreignKey:ProductID"`
+	Images     []ProductImage   `json:"images,omitempty" gorm:"foreignKey:ProductID"`
+}
+
+// ProductImage model for a product's photo gallery, shown in Position order
+// with the primary image first in listings
+type ProductImage struct {
+	BaseModel
+	ProductID    uuid.UUID `json:"product_id" gorm:"not null;index"`
+	URL          string    `json:"url" gorm:"not null"`
+	ThumbnailURL string    `json:"thumbnail_url"` // Empty for images added by URL
+	AltText      string    `json:"alt_text"`
+	Position     int       `json:"position" gorm:"default:0"`
+	IsPrimary    bool      `json:"is_primary" gorm:"default:false"`
+	Quarantined  bool      `json:"-" gorm:"default:false"` // Hidden while held for moderation
+}
+
+// ProductVariant model for one option of a product, such as a size and
+// color or a storage capacity. A product with variants is bought by variant
+// and its Stock is the total of its active variants'.
+type ProductVariant struct {
+	BaseModel
+	ProductID  uuid.UUID         `json:"product_id" gorm:"not null;index"`
+	SKU        string            `json:"sku" gorm:"index"`
+	Attributes map[string]string `json:"attributes" gorm:"serializer:json"` // e.g. size: M, color: red
+	Price      *float64          `json:"price"`                             // Overrides the product price when set
+	Stock      int               `json:"stock" gorm:"default:0"`
+	IsActive   bool              `json:"is_active" gorm:"default:true"`
+
+	InventoryEditedAt *time.Time `json:"-"` // Last stock or price change by the seller, which inventory sync treats as conflicting
+}
+
+// Label names the variant by its attribute values, e.g. "M / red"
+func (v *ProductVariant) Label() string {
+	keys := make([]string, 0, len(v.Attributes))
+	for key := range v.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		values[i] = v.Attributes[key]
+	}
+	return strings.Join(values, " / ")
+}
+
+// ProductSlugRedirect keeps a product's previous slug working after it
+// changes, so old storefront links redirect to the new one
+type ProductSlugRedirect struct {
+	BaseModel
+	Slug      string    `json:"slug" gorm:"uniqueIndex;not null"`
+	ProductID uuid.UUID `json:"product_id" gorm:"not null;index"`
+}
+
+// Kind of file published for search engines and ad platforms
+type FeedKind string
+
+const (
+	FeedSitemap      FeedKind = "sitemap"       // Product URLs for search engines
+	FeedSitemapIndex FeedKind = "sitemap_index" // Lists the sitemap shards
+	FeedCatalog      FeedKind = "catalog"       // Google Merchant and Facebook catalog feed
+)
+
+// FeedFile records a published feed file. Products are split across shards
+// by ID so a change only rewrites the shard holding it.
+type FeedFile struct {
+	BaseModel
+	Kind        FeedKind  `json:"kind" gorm:"not null;uniqueIndex:idx_feed_file_kind_shard"`
+	Shard       int       `json:"shard" gorm:"not null;uniqueIndex:idx_feed_file_kind_shard"`
+	URL         string    `json:"url"`
+	Items       int       `json:"items"`
+	GeneratedAt time.Time `json:"generated_at"` // Products changed after this aren't in the file yet
+}
+
+// PriceTier model for quantity-break discounts on a product
+type PriceTier struct {
+	BaseModel
+	ProductID       uuid.UUID `json:"product_id" gorm:"not null;uniqueIndex:idx_price_tier_product_qty"`
+	MinQuantity     int       `json:"min_quantity" gorm:"not null;uniqueIndex:idx_price_tier_product_qty"`
+	DiscountPercent float64   `json:"discount_percent" gorm:"not null"`
+}
+
+// Where a price change came from
+type PriceSource string
+
+const (
+	PriceSourceListing  PriceSource = "listing"        // Set when the product was listed
+	PriceSourceSeller   PriceSource = "seller"         // Edited by the seller
+	PriceSourceSync     PriceSource = "inventory_sync" // Pushed by the seller's POS or ERP
+	PriceSourceSchedule PriceSource = "schedule"       // A scheduled price change took effect
+)
+
+// PriceHistory model for every price a product has had, so sale prices can
+// be compared with what it actually sold for before
+type PriceHistory struct {
+	BaseModel
+	ProductID     uuid.UUID   `json:"product_id" gorm:"not null;index:idx_price_history_product"`
+	PreviousPrice *float64    `json:"previous_price"` // Nil for the listing price
+	Price         float64     `json:"price" gorm:"not null"`
+	Source        PriceSource `json:"source" gorm:"not null"`
+	ChangedBy     *uuid.UUID  `json:"-"`
+	EffectiveAt   time.Time   `json:"effective_at" gorm:"not null;index:idx_price_history_product"`
+}
+
+// Scheduled price change status
+type PriceChangeStatus string
+
+const (
+	PriceChangePending   PriceChangeStatus = "pending"
+	PriceChangeApplied   PriceChangeStatus = "applied"
+	PriceChangeCancelled PriceChangeStatus = "cancelled"
+)
+
+// ScheduledPriceChange model for a price a seller set to take effect later
+type ScheduledPriceChange struct {
+	BaseModel
+	ProductID   uuid.UUID         `json:"product_id" gorm:"not null;index"`
+	Price       float64           `json:"price" gorm:"not null"`
+	EffectiveAt time.Time         `json:"effective_at" gorm:"not null;index"`
+	Status      PriceChangeStatus `json:"status" gorm:"default:'pending';index"`
+	CreatedBy   uuid.UUID         `json:"created_by" gorm:"not null"`
+	AppliedAt   *time.Time        `json:"applied_at"`
+}
+
+// Product availability when out of stock
+type ProductAvailability string
+
+const (
+	AvailabilityInStock   ProductAvailability = "in_stock"  // Only sells what is in stock
+	AvailabilityBackorder ProductAvailability = "backorder" // Sells beyond stock while more is on the way
+	AvailabilityPreorder  ProductAvailability = "preorder"  // Sells ahead of its release
+)
+
+// StockLevel is how low a product's stock is against its low stock threshold
+type StockLevel string
+
+const (
+	StockLevelLow StockLevel = "low"          // At or below the threshold
+	StockLevelOut StockLevel = "out_of_stock" // None left
+)
+
+// SaleActive reports whether the sale price is in effect at the time
+func (p *Product) SaleActive(at time.Time) bool {
+	if p.SalePrice == nil || *p.SalePrice >= p.Price {
+		return false
+	}
+	if p.SaleStartsAt != nil && at.Before(*p.SaleStartsAt) {
+		return false
+	}
+	return p.SaleEndsAt == nil || at.Before(*p.SaleEndsAt)
+}
+
+// EffectivePrice returns the price charged at the time: the sale price
+// during a sale, Price otherwise
+func (p *Product) EffectivePrice(at time.Time) float64 {
+	if p.SaleActive(at) {
+		return *p.SalePrice
+	}
+	return p.Price
+}
+
+// FillSale sets OnSale and DiscountPercent for the current time
+func (p *Product) FillSale() {
+	p.OnSale = p.SaleActive(time.Now())
+	p.DiscountPercent = 0
+	if p.OnSale && p.Price > 0 {
+		p.DiscountPercent = int(math.Round((p.Price - *p.SalePrice) / p.Price * 100))
+	}
+}
+
+// UnitPrice returns the price per unit when buying quantity units now,
+// applying any sale and then the best price tier reached. PriceTiers must
+// be loaded.
+func (p *Product) UnitPrice(quantity int) float64 {
+	return p.tierPrice(p.EffectivePrice(time.Now()), quantity)
+}
+
+// VariantUnitPrice is UnitPrice for a variant, starting from the variant's
+// own price when it overrides the product's. A sale takes the same share
+// off the variant's price as off the product's.
+func (p *Product) VariantUnitPrice(variant *ProductVariant, quantity int) float64 {
+	if variant == nil || variant.Price == nil {
+		return p.UnitPrice(quantity)
+	}
+	price := *variant.Price
+	if now := time.Now(); p.SaleActive(now) {
+		price = math.Round(price*p.EffectivePrice(now)/p.Price*100) / 100
+	}
+	return p.tierPrice(price, quantity)
+}
+
+func (p *Product) tierPrice(price float64, quantity int) float64 {
+	var discount float64
+	best := 0
+	for _, tier := range p.PriceTiers {
+		if quantity >= tier.MinQuantity && tier.MinQuantity > best {
+			best = tier.MinQuantity
+			discount = tier.DiscountPercent
+		}
+	}
+	return math.Round(price*(100-discount)) / 100
+}
+
+// AcceptsBackorders reports whether the product can be bought without stock
+func (p *Product) AcceptsBackorders() bool {
+	return p.Availability == AvailabilityBackorder || p.Availability == AvailabilityPreorder
 }
 
 // Order model
@@ -122,40 +500,130 @@ type Order struct {
 	Status      OrderStatus `json:"status" gorm:"default:'pending'"`
 	ShippingAddress string  `json:"shipping_address"`
 	Notes       string      `json:"notes"`
+	DeliveredAt *time.Time  `json:"delivered_at"`
+	ReceiptConfirmedAt *time.Time `json:"receipt_confirmed_at"`
+	RewardsProcessedAt *time.Time `json:"-"` // When delivery XP, badges and seller sales were credited, so they're credited once
+	AutoConfirmed      bool       `json:"auto_confirmed" gorm:"default:false"`
+	CouponCode         string     `json:"coupon_code,omitempty"`
+	DiscountAmount     float64    `json:"discount_amount" gorm:"default:0"`
+	ContactPhone       string     `json:"contact_phone,omitempty"`    // Guest checkout contact number
+	Region             string     `json:"region" gorm:"default:'ET'"` // Region code the order was placed in
+	DeliveryZone       string     `json:"delivery_zone,omitempty"`
+	TaxAmount          float64    `json:"tax_amount" gorm:"default:0"` // Part of TotalAmount, on top of prices unless the region's tax is inclusive
+	ShippingAmount     float64    `json:"shipping_amount" gorm:"default:0"` // Part of TotalAmount, the sellers' shipping fees
+
+	// Gift details
+	IsGift             bool   `json:"is_gift" gorm:"default:false"`
+	GiftRecipientName  string `json:"gift_recipient_name,omitempty"`
+	GiftRecipientPhone string `json:"gift_recipient_phone,omitempty"`
+	GiftMessage        string `json:"gift_message,omitempty"`
 	
 	// Relationships
 	Buyer      User        `json:"buyer,omitempty" gorm:"foreignKey:BuyerID"`
 	Items      []OrderItem `json:"items,omitempty" gorm:"foreignKey:OrderID"`
 	Payment    *Payment    `json:"payment,omitempty" gorm:"foreignKey:OrderID"`
+	Shipping   []OrderShipping `json:"shipping,omitempty" gorm:"foreignKey:OrderID"`
 }
 
+// Order item fulfillment status
+type OrderItemStatus string
+
+const (
+	ItemPending     OrderItemStatus = "pending"
+	ItemBackordered OrderItemStatus = "backordered"
+	ItemPreordered  OrderItemStatus = "preordered"
+	ItemShipped     OrderItemStatus = "shipped"
+	ItemDelivered   OrderItemStatus = "delivered"
+	ItemCancelled   OrderItemStatus = "cancelled"
+)
+
 // OrderItem model
 type OrderItem struct {
 	BaseModel
-	OrderID   uuid.UUID `json:"order_id" gorm:"not null"`
-	ProductID uuid.UUID `json:"product_id" gorm:"not null"`
-	Quantity  int       `json:"quantity" gorm:"not null"`
-	Price     float64   `json:"price" gorm:"not null"` // Price at time of order
+	OrderID    uuid.UUID       `json:"order_id" gorm:"not null"`
+	ProductID  uuid.UUID       `json:"product_id" gorm:"not null"`
+	VariantID  *uuid.UUID      `json:"variant_id" gorm:"index"` // Set for products sold by variant
+	Quantity   int             `json:"quantity" gorm:"not null"`
+	Price      float64         `json:"price" gorm:"not null"` // Price at time of order
+	Status     OrderItemStatus `json:"status" gorm:"default:'pending'"`
+	ShipmentID *uuid.UUID      `json:"shipment_id" gorm:"index"` // Set once the item ships
+
+	// Backorders and pre-orders are bought without stock and allocated when it arrives
+	AwaitingStock bool       `json:"awaiting_stock" gorm:"default:false;index"`
+	ExpectedAt    *time.Time `json:"expected_at"`
 	
 	// Relationships
-	Order   Order   `json:"order,omitempty" gorm:"foreignKey:OrderID"`
-	Product Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Order   Order           `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+	Product Product         `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Variant *ProductVariant `json:"variant,omitempty" gorm:"foreignKey:VariantID"`
+}
+
+// Stock reservation status
+type ReservationStatus string
+
+const (
+	ReservationHeld      ReservationStatus = "held"      // Stock taken for an unpaid order
+	ReservationConfirmed ReservationStatus = "confirmed" // The order was paid for
+	ReservationReleased  ReservationStatus = "released"  // Stock went back to inventory
+)
+
+// StockReservation model for the stock an order item took at checkout. It's
+// released back to inventory if the payment fails or the order goes unpaid
+// past ExpiresAt.
+type StockReservation struct {
+	BaseModel
+	OrderID     uuid.UUID         `json:"order_id" gorm:"not null;index"`
+	OrderItemID uuid.UUID         `json:"order_item_id" gorm:"uniqueIndex;not null"`
+	ProductID   uuid.UUID         `json:"product_id" gorm:"not null"`
+	VariantID   *uuid.UUID        `json:"variant_id"`
+	Quantity    int               `json:"quantity" gorm:"not null"`
+	Status      ReservationStatus `json:"status" gorm:"default:'held';index"`
+	ExpiresAt   time.Time         `json:"expires_at" gorm:"index"`
+	ReleasedAt  *time.Time        `json:"released_at"`
 }
 
 // Payment model
 type Payment struct {
 	BaseModel
-	OrderID       uuid.UUID     `json:"order_id" gorm:"not null"`
-	Amount        float64       `json:"amount" gorm:"not null"`
-	Method        PaymentMethod `json:"method" gorm:"not null"`
-	Status        PaymentStatus `json:"status" gorm:"default:'pending'"`
-	TransactionID string        `json:"transaction_id"`
-	Reference     string        `json:"reference"`
-	
+	OrderID          uuid.UUID     `json:"order_id" gorm:"not null"`
+	Amount           float64       `json:"amount" gorm:"not null"`
+	Method           PaymentMethod `json:"method" gorm:"not null"`
+	Status           PaymentStatus `json:"status" gorm:"default:'pending'"`
+	TransactionID    string        `json:"transaction_id"`
+	Reference        string        `json:"reference"`
+	EscrowStatus     EscrowStatus  `json:"escrow_status" gorm:"default:'held'"`
+	EscrowReleasedAt *time.Time    `json:"escrow_released_at"`
+
 	// Relationships
 	Order Order `json:"order,omitempty" gorm:"foreignKey:OrderID"`
 }
 
+// InvoiceSequence model for the next invoice number in a seller's series.
+// Numbers are allocated under a row lock in the transaction that issues
+// the invoice, so a seller's invoices are numbered without gaps.
+type InvoiceSequence struct {
+	SellerID   uuid.UUID `json:"seller_id" gorm:"type:uuid;primary_key"`
+	NextNumber int64     `json:"next_number" gorm:"not null;default:1"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Invoice model for a seller's invoice on their share of a paid order
+type Invoice struct {
+	BaseModel
+	OrderID       uuid.UUID `json:"order_id" gorm:"uniqueIndex:idx_invoice_order_seller;not null"`
+	SellerID      uuid.UUID `json:"seller_id" gorm:"uniqueIndex:idx_invoice_order_seller;uniqueIndex:idx_invoice_seller_number;not null"`
+	Number        int64     `json:"number" gorm:"uniqueIndex:idx_invoice_seller_number;not null"` // Sequential within the seller's series
+	InvoiceNumber string    `json:"invoice_number" gorm:"not null"`                              // Number as printed, e.g. INV-1A2B3C4D-000042
+	Subtotal      float64   `json:"subtotal"`
+	Discount      float64   `json:"discount"` // The seller's own coupon
+	Shipping      float64   `json:"shipping"`
+	Total         float64   `json:"total"`
+	IssuedAt      time.Time `json:"issued_at"`
+
+	// Relationships
+	Order *Order `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+}
+
 // Badge model
 type Badge struct {
 	BaseModel
@@ -210,3 +678,1208 @@ type LeaderboardEntry struct {
 	Level    UserLevel `json:"level"`
 	BadgeCount int     `json:"badge_count"`
 }
+
+// Admin corrections to leaderboard entries
+type LeaderboardAction string
+
+const (
+	LeaderboardAdjust  LeaderboardAction = "adjust"  // Score shifted by a delta that later updates keep applying
+	LeaderboardRemove  LeaderboardAction = "remove"  // Entry taken off and kept off, e.g. for fraud
+	LeaderboardRestore LeaderboardAction = "restore" // Removed entry allowed back on
+)
+
+// LeaderboardAdjustment audits an admin correcting a leaderboard entry
+type LeaderboardAdjustment struct {
+	BaseModel
+	Leaderboard   string            `json:"leaderboard" gorm:"not null;index"`
+	UserID        uuid.UUID         `json:"user_id" gorm:"not null;index"`
+	AdminID       uuid.UUID         `json:"admin_id" gorm:"not null"`
+	Action        LeaderboardAction `json:"action" gorm:"not null"`
+	Delta         float64           `json:"delta"`
+	PreviousScore float64           `json:"previous_score"`
+	NewScore      float64           `json:"new_score"`
+	Reason        string            `json:"reason" gorm:"not null"`
+}
+
+// MaintenanceWindow pauses writes to one service, or to all of them, while
+// reads stay available
+type MaintenanceWindow struct {
+	Service    string     `json:"service"` // A service name, or "all"
+	Message    string     `json:"message"`
+	RetryAfter int        `json:"retry_after"` // Seconds clients should wait before retrying
+	StartedBy  *uuid.UUID `json:"started_by,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	EndsAt     *time.Time `json:"ends_at,omitempty"` // Nil when it lasts until an admin turns it off
+}
+
+// APIUsageDay counts a user's API calls on one day, in total and per
+// endpoint. Kept in Redis for a month.
+type APIUsageDay struct {
+	Date      string           `json:"date"` // YYYY-MM-DD, UTC
+	Calls     int64            `json:"calls"`
+	Endpoints map[string]int64 `json:"endpoints"` // Keyed by method and route, e.g. "GET /api/v1/products/:id"
+}
+
+// APILastSeen is the most recent endpoint a user called
+type APILastSeen struct {
+	Endpoint string    `json:"endpoint"`
+	At       time.Time `json:"at"`
+}
+
+// Notification types
+type NotificationType string
+
+const (
+	NotificationSecurity   NotificationType = "security"
+	NotificationOrder      NotificationType = "order"
+	NotificationReview     NotificationType = "review"
+	NotificationModeration NotificationType = "moderation"
+	NotificationMarketing  NotificationType = "marketing"
+	NotificationRestock    NotificationType = "restock"
+	NotificationAccount    NotificationType = "account"
+	NotificationAnnouncement NotificationType = "announcement" // Marketplace-wide notices such as planned downtime
+	NotificationInventory  NotificationType = "inventory"    // A seller's stock running low
+
+	// Non-urgent types, batched into digests along with an XP summary
+	NotificationLeaderboard NotificationType = "leaderboard"
+	NotificationPriceDrop   NotificationType = "price_drop"
+	NotificationDigest      NotificationType = "digest"
+)
+
+// Activity event types shown on a user's profile timeline
+type ActivityType string
+
+const (
+	ActivityOrderPlaced   ActivityType = "order_placed" // Only shown to the user themselves
+	ActivityBadgeEarned   ActivityType = "badge_earned"
+	ActivityLevelUp       ActivityType = "level_up"
+	ActivityReviewWritten ActivityType = "review_written"
+)
+
+// ActivityEvent model for the significant moments on a user's timeline
+type ActivityEvent struct {
+	BaseModel
+	UserID    uuid.UUID    `json:"user_id" gorm:"not null;index"`
+	Type      ActivityType `json:"type" gorm:"not null"`
+	Title     string       `json:"title" gorm:"not null"`
+	Reference string       `json:"reference"` // ID of the order, badge or review behind the event
+}
+
+// How often non-urgent notifications are delivered
+type DigestFrequency string
+
+const (
+	DigestImmediate DigestFrequency = "immediate"
+	DigestDaily     DigestFrequency = "daily"
+	DigestWeekly    DigestFrequency = "weekly"
+)
+
+// Notification model for messages delivered to users
+type Notification struct {
+	BaseModel
+	UserID  uuid.UUID        `json:"user_id" gorm:"not null;index"`
+	Type    NotificationType `json:"type" gorm:"not null"`
+	Title   string           `json:"title" gorm:"not null"`
+	Message string           `json:"message"`
+	Link    string           `json:"link"` // Deep link into the app
+	ReadAt  *time.Time       `json:"read_at"`
+
+	DigestPending bool `json:"digest_pending" gorm:"default:false;index"` // Held for the user's next digest instead of being pushed
+
+	// Relationships
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// NotificationPreferences model for which notifications a user receives and
+// how. Security and account notices ignore the category toggles.
+type NotificationPreferences struct {
+	BaseModel
+	UserID       uuid.UUID `json:"user_id" gorm:"not null;uniqueIndex"`
+	OrderUpdates bool      `json:"order_updates"` // Orders, payments, reviews and restock alerts
+	Marketing    bool      `json:"marketing"`     // Campaigns and price drops, mirrors User.MarketingOptOut
+	Gamification bool      `json:"gamification"`  // Leaderboard changes and XP in digests
+
+	// Channels notifications are pushed on besides the in-app inbox
+	SMS   bool `json:"sms"`
+	Email bool `json:"email"`
+	Push  bool `json:"push"`
+}
+
+// UserDevice model for devices a user has logged in from
+type UserDevice struct {
+	BaseModel
+	UserID      uuid.UUID `json:"user_id" gorm:"not null;uniqueIndex:idx_user_device"`
+	Fingerprint string    `json:"fingerprint" gorm:"not null;uniqueIndex:idx_user_device"`
+	UserAgent   string    `json:"user_agent"`
+	LastIP      string    `json:"last_ip"`
+	IsTrusted   bool      `json:"is_trusted" gorm:"default:false"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+
+	// Relationships
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// Login event types
+type LoginEventType string
+
+const (
+	LoginEventLogin   LoginEventType = "login"
+	LoginEventLogout  LoginEventType = "logout"
+	LoginEventRefresh LoginEventType = "refresh"
+)
+
+// LoginEvent model for auditing logins, failed attempts, logouts and token refreshes
+type LoginEvent struct {
+	BaseModel
+	Event         LoginEventType `json:"event" gorm:"index;default:'login'"`
+	UserID        *uuid.UUID     `json:"user_id" gorm:"index"`
+	Phone         string         `json:"phone" gorm:"index"`
+	IP            string         `json:"ip"`
+	UserAgent     string         `json:"user_agent"`
+	Fingerprint   string         `json:"fingerprint"`
+	Success       bool           `json:"success"`
+	FailureReason string         `json:"failure_reason,omitempty"`
+}
+
+// PhoneChange model for auditing verified phone number changes
+type PhoneChange struct {
+	BaseModel
+	UserID    uuid.UUID `json:"user_id" gorm:"not null;index"`
+	OldPhone  string    `json:"old_phone" gorm:"not null"`
+	NewPhone  string    `json:"new_phone" gorm:"not null"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+}
+
+// Account recovery status
+type AccountRecoveryStatus string
+
+const (
+	RecoveryPendingVerification AccountRecoveryStatus = "pending_verification" // Codes sent to the email and new phone
+	RecoveryPendingReview       AccountRecoveryStatus = "pending_review"
+	RecoveryApproved            AccountRecoveryStatus = "approved"
+	RecoveryRejected            AccountRecoveryStatus = "rejected"
+	RecoveryCancelled           AccountRecoveryStatus = "cancelled" // Replaced by a newer request
+)
+
+// AccountRecovery is a request to move an account to a new phone number
+// after losing the old one. The requester proves the account's email and
+// the new number, submits identity evidence, and an admin approves it.
+type AccountRecovery struct {
+	BaseModel
+	UserID             uuid.UUID             `json:"user_id" gorm:"not null;index"`
+	Email              string                `json:"email" gorm:"not null"`
+	OldPhone           string                `json:"old_phone" gorm:"not null"`
+	NewPhone           string                `json:"new_phone" gorm:"not null"`
+	Status             AccountRecoveryStatus `json:"status" gorm:"default:'pending_verification';index"`
+	VerifiedAt         *time.Time            `json:"verified_at"` // Both codes confirmed
+	IDDocumentURL      string                `json:"id_document_url"`
+	RecentOrderNumber  string                `json:"recent_order_number"`
+	OrderNumberMatches bool                  `json:"order_number_matches"` // One of the account's orders, for the reviewer
+	Details            string                `json:"details"`              // Anything else the requester wants the reviewer to know
+	IP                 string                `json:"ip"`
+	UserAgent          string                `json:"user_agent"`
+	ReviewedBy         *uuid.UUID            `json:"reviewed_by"`
+	ReviewedAt         *time.Time            `json:"reviewed_at"`
+	Notes              string                `json:"notes"`
+
+	// Relationships
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// UserChange audits one profile field of a user changing, for support and
+// dispute resolution. Recorded by the database layer on every update.
+type UserChange struct {
+	BaseModel
+	UserID   uuid.UUID  `json:"user_id" gorm:"not null;index"`
+	ActorID  *uuid.UUID `json:"actor_id" gorm:"index"` // Nil for changes made by the system
+	Field    string     `json:"field" gorm:"not null;index"`
+	OldValue string     `json:"old_value"`
+	NewValue string     `json:"new_value"`
+}
+
+// Fraud review status
+type FraudReviewStatus string
+
+const (
+	FraudReviewPending  FraudReviewStatus = "pending"
+	FraudReviewApproved FraudReviewStatus = "approved"
+	FraudReviewRejected FraudReviewStatus = "rejected"
+)
+
+// FraudAssessment model for high-risk checkouts held for manual review
+type FraudAssessment struct {
+	BaseModel
+	OrderID    uuid.UUID         `json:"order_id" gorm:"not null;index"`
+	UserID     uuid.UUID         `json:"user_id" gorm:"not null;index"`
+	Stage      string            `json:"stage" gorm:"not null"` // order, payment or settlement
+	Score      int               `json:"score"`
+	Reasons    string            `json:"reasons"` // Comma-separated rule names
+	Status     FraudReviewStatus `json:"status" gorm:"default:'pending';index"`
+	ReviewedBy *uuid.UUID        `json:"reviewed_by"`
+	ReviewedAt *time.Time        `json:"reviewed_at"`
+	Notes      string            `json:"notes"`
+
+	// Relationships
+	Order Order `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+	User  User  `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// ReturnPolicy model for seller-configured return rules
+type ReturnPolicy struct {
+	BaseModel
+	SellerID             uuid.UUID `json:"seller_id" gorm:"uniqueIndex;not null"`
+	WindowDays           int       `json:"window_days"` // 0 means no returns, new policies start at returns.DefaultWindowDays
+	RestockingFeePercent float64   `json:"restocking_fee_percent" gorm:"default:0"`
+	FinalSaleCategories  string    `json:"final_sale_categories"` // Comma-separated categories
+	Notes                string    `json:"notes"`
+}
+
+// ShippingSettings model for seller-configured shipping rules
+type ShippingSettings struct {
+	BaseModel
+	SellerID              uuid.UUID `json:"seller_id" gorm:"uniqueIndex;not null"`
+	HandlingDays          int       `json:"handling_days" gorm:"default:2"`           // Days from order to shipment
+	ShippingFee           float64   `json:"shipping_fee" gorm:"default:0"`            // Charged once per order on the seller's items
+	FreeShippingThreshold float64   `json:"free_shipping_threshold" gorm:"default:0"` // Seller subtotal that waives the fee, 0 for none
+	Zones                 string    `json:"zones"`                                    // Comma-separated delivery zone codes shipped to, empty for every zone
+}
+
+// OrderShipping model for the shipping each seller charged on an order
+type OrderShipping struct {
+	BaseModel
+	OrderID  uuid.UUID `json:"order_id" gorm:"uniqueIndex:idx_order_shipping_seller;not null"`
+	SellerID uuid.UUID `json:"seller_id" gorm:"uniqueIndex:idx_order_shipping_seller;not null"`
+	Fee      float64   `json:"fee" gorm:"default:0"`
+	ShipBy   time.Time `json:"ship_by"` // Order time plus the seller's handling time
+}
+
+// SellerProfile model for the business details buyers see next to a
+// seller's listings
+type SellerProfile struct {
+	BaseModel
+	SellerID     uuid.UUID         `json:"seller_id" gorm:"uniqueIndex;not null"`
+	StoreName    string            `json:"store_name" gorm:"not null"`
+	Description  string            `json:"description"`
+	LogoURL      string            `json:"logo_url"`
+	OpeningHours map[string]string `json:"opening_hours,omitempty" gorm:"serializer:json"` // Keyed by weekday, e.g. "mon": "09:00-18:00" or "closed"
+	SocialLinks  map[string]string `json:"social_links,omitempty" gorm:"serializer:json"`  // Keyed by network, e.g. "telegram": "https://t.me/..."
+}
+
+// SellerSummary is the public view of a seller returned with their listings.
+// It reads the users table but leaves out contact and account details.
+type SellerSummary struct {
+	ID             uuid.UUID `json:"id"`
+	Name           string    `json:"name"`
+	Level          UserLevel `json:"level"`
+	SellerVerified bool      `json:"seller_verified"`
+	AvatarURL      string    `json:"avatar_url"`
+
+	// Relationships
+	Profile      *SellerProfile `json:"profile,omitempty" gorm:"foreignKey:SellerID"`
+	ReturnPolicy *ReturnPolicy  `json:"return_policy,omitempty" gorm:"foreignKey:SellerID"` // Loaded on product detail
+}
+
+func (SellerSummary) TableName() string {
+	return "users"
+}
+
+// Return request status
+type ReturnStatus string
+
+const (
+	ReturnRequested ReturnStatus = "requested"
+	ReturnApproved  ReturnStatus = "approved"
+	ReturnRejected  ReturnStatus = "rejected"
+	ReturnReceived  ReturnStatus = "received"
+	ReturnRefunded  ReturnStatus = "refunded"
+)
+
+// ReturnRequest model (RMA) for returning order items
+type ReturnRequest struct {
+	BaseModel
+	RMANumber     string       `json:"rma_number" gorm:"uniqueIndex;not null"`
+	OrderID       uuid.UUID    `json:"order_id" gorm:"not null;index"`
+	OrderItemID   uuid.UUID    `json:"order_item_id" gorm:"not null"`
+	BuyerID       uuid.UUID    `json:"buyer_id" gorm:"not null;index"`
+	SellerID      uuid.UUID    `json:"seller_id" gorm:"not null;index"`
+	Quantity      int          `json:"quantity" gorm:"not null"`
+	Reason        string       `json:"reason" gorm:"not null"`
+	Status        ReturnStatus `json:"status" gorm:"default:'requested'"`
+	RestockingFee float64      `json:"restocking_fee"`
+	RefundAmount  float64      `json:"refund_amount"`
+	SellerNotes   string       `json:"seller_notes"`
+
+	// Relationships
+	OrderItem OrderItem `json:"order_item,omitempty" gorm:"foreignKey:OrderItemID"`
+}
+
+// Inventory sync conflict strategies
+type ConflictStrategy string
+
+const (
+	ConflictNewestWins      ConflictStrategy = "newest_wins"      // Apply only if source change is newer than local edit
+	ConflictSourceWins      ConflictStrategy = "source_wins"      // External system is the source of truth
+	ConflictMarketplaceWins ConflictStrategy = "marketplace_wins" // Never overwrite local edits made after last sync
+)
+
+// InventorySyncConfig model for external POS/ERP integrations per seller
+type InventorySyncConfig struct {
+	BaseModel
+	SellerID         uuid.UUID        `json:"seller_id" gorm:"uniqueIndex;not null"`
+	WebhookSecret    string           `json:"-" gorm:"not null"`
+	ConflictStrategy ConflictStrategy `json:"conflict_strategy" gorm:"default:'newest_wins'"`
+	LastSyncAt       *time.Time       `json:"last_sync_at"`
+}
+
+// InventoryChangeLog model recording every externally pushed change
+type InventoryChangeLog struct {
+	BaseModel
+	SellerID        uuid.UUID  `json:"seller_id" gorm:"not null;index"`
+	ProductID       *uuid.UUID `json:"product_id" gorm:"index"`
+	SKU             string     `json:"sku" gorm:"index"`
+	Source          string     `json:"source"` // api or webhook
+	Field           string     `json:"field"`  // stock or price
+	OldValue        float64    `json:"old_value"`
+	NewValue        float64    `json:"new_value"`
+	Applied         bool       `json:"applied"`
+	Conflict        bool       `json:"conflict"`
+	Message         string     `json:"message"`
+	SourceTimestamp *time.Time `json:"source_timestamp"`
+}
+
+// Proof of delivery types
+type DeliveryProofType string
+
+const (
+	ProofPhoto     DeliveryProofType = "photo"
+	ProofSignature DeliveryProofType = "signature"
+	ProofOTP       DeliveryProofType = "otp"
+)
+
+// Shipment model for order delivery tracking and proof of delivery. An order
+// can ship in several parts.
+type Shipment struct {
+	BaseModel
+	OrderID           uuid.UUID         `json:"order_id" gorm:"index:idx_shipment_order;not null"`
+	Carrier           string            `json:"carrier"`
+	TrackingNumber    string            `json:"tracking_number"`
+	ShippedAt         *time.Time        `json:"shipped_at"`
+	DeliveredAt       *time.Time        `json:"delivered_at"`
+	DeliveryOTPHash   string            `json:"-"`
+	ProofType         DeliveryProofType `json:"proof_type"`
+	ProofPhotoURL     string            `json:"proof_photo_url"`
+	ProofSignatureURL string            `json:"proof_signature_url"`
+	ProofOTPVerified  bool              `json:"proof_otp_verified"`
+	ProofCapturedBy   *uuid.UUID        `json:"proof_captured_by"`
+	ProofCapturedAt   *time.Time        `json:"proof_captured_at"`
+
+	// Relationships
+	Order Order       `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+	Items []OrderItem `json:"items,omitempty" gorm:"foreignKey:ShipmentID"`
+}
+
+// HasProof reports whether proof of delivery has been captured
+func (s *Shipment) HasProof() bool {
+	return s.ProofCapturedAt != nil
+}
+
+// Review model for buyer product reviews
+type Review struct {
+	BaseModel
+	ProductID uuid.UUID `json:"product_id" gorm:"not null;uniqueIndex:idx_review_product_buyer"`
+	BuyerID   uuid.UUID `json:"buyer_id" gorm:"not null;uniqueIndex:idx_review_product_buyer"`
+	OrderID   uuid.UUID `json:"order_id" gorm:"not null"`
+	Rating    int       `json:"rating" gorm:"not null"` // 1-5
+	Comment   string    `json:"comment"`
+	ImageURL  string    `json:"image_url"`
+
+	// The seller's one public reply
+	SellerReply         string     `json:"seller_reply,omitempty"`
+	SellerRepliedAt     *time.Time `json:"seller_replied_at,omitempty"`
+	SellerReplyEditedAt *time.Time `json:"seller_reply_edited_at,omitempty"`
+
+	// Vote totals, kept in step with ReviewVote rows
+	HelpfulCount    int `json:"helpful_count" gorm:"default:0"`
+	NotHelpfulCount int `json:"not_helpful_count" gorm:"default:0"`
+
+	// Relationships
+	Buyer   User    `json:"buyer,omitempty" gorm:"foreignKey:BuyerID"`
+	Product Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+}
+
+// ReviewVote model for a user's helpful or not-helpful vote on a review
+type ReviewVote struct {
+	BaseModel
+	ReviewID uuid.UUID `json:"review_id" gorm:"not null;uniqueIndex:idx_review_vote_user"`
+	UserID   uuid.UUID `json:"user_id" gorm:"not null;uniqueIndex:idx_review_vote_user"`
+	Helpful  bool      `json:"helpful"`
+}
+
+// Review solicitation status
+type SolicitationStatus string
+
+const (
+	SolicitationScheduled  SolicitationStatus = "scheduled"
+	SolicitationSent       SolicitationStatus = "sent"
+	SolicitationSuppressed SolicitationStatus = "suppressed"
+)
+
+// ReviewSolicitation model for review-request notifications after delivery
+type ReviewSolicitation struct {
+	BaseModel
+	OrderID     uuid.UUID          `json:"order_id" gorm:"uniqueIndex;not null"`
+	BuyerID     uuid.UUID          `json:"buyer_id" gorm:"not null"`
+	SendAt      time.Time          `json:"send_at" gorm:"index"`
+	Status      SolicitationStatus `json:"status" gorm:"default:'scheduled';index"`
+	ProcessedAt *time.Time         `json:"processed_at"`
+}
+
+// CategoryRequirement model for per-category listing rules
+type CategoryRequirement struct {
+	BaseModel
+	Category           string     `json:"category" gorm:"uniqueIndex;not null"`
+	RequiredAttributes string     `json:"required_attributes"` // Comma-separated attribute keys
+	MinImages          int        `json:"min_images" gorm:"default:0"`
+	MinPrice           float64    `json:"min_price" gorm:"default:0"` // 0 means no lower bound
+	MaxPrice           float64    `json:"max_price" gorm:"default:0"` // 0 means no upper bound
+	UpdatedBy          *uuid.UUID `json:"updated_by"`
+}
+
+// Content flag status
+type ContentFlagStatus string
+
+const (
+	ContentFlagPending  ContentFlagStatus = "pending"
+	ContentFlagApproved ContentFlagStatus = "approved"
+	ContentFlagRemoved  ContentFlagStatus = "removed"
+)
+
+// ContentFlag model for user content held for moderator review
+type ContentFlag struct {
+	BaseModel
+	ContentType string            `json:"content_type" gorm:"not null;index"` // product, review, chat_message, product_image, review_image
+	ContentID   uuid.UUID         `json:"content_id" gorm:"not null;index"`
+	UserID      uuid.UUID         `json:"user_id" gorm:"not null"`
+	Matches     string            `json:"matches"`             // Comma-separated matched terms or categories
+	Source      string            `json:"source"`              // wordlist, api or vision
+	ImageURL    string            `json:"image_url,omitempty"` // Quarantined image, restored if approved
+	Status      ContentFlagStatus `json:"status" gorm:"default:'pending';index"`
+	ReviewedBy  *uuid.UUID        `json:"reviewed_by"`
+	ReviewedAt  *time.Time        `json:"reviewed_at"`
+}
+
+// Duplicate listing review status
+type DuplicateListingStatus string
+
+const (
+	DuplicatePending   DuplicateListingStatus = "pending"
+	DuplicateMerged    DuplicateListingStatus = "merged"
+	DuplicateDismissed DuplicateListingStatus = "dismissed"
+)
+
+// DuplicateListing model for a seller's listing that looks like a copy of
+// one of their older listings, suggested for merging
+type DuplicateListing struct {
+	BaseModel
+	ProductID  uuid.UUID              `json:"product_id" gorm:"not null;uniqueIndex:idx_duplicate_pair"`  // The newer listing
+	OriginalID uuid.UUID              `json:"original_id" gorm:"not null;uniqueIndex:idx_duplicate_pair"` // Kept when merged
+	SellerID   uuid.UUID              `json:"seller_id" gorm:"not null;index"`
+	Similarity float64                `json:"similarity"` // Name trigram similarity, 0 to 1
+	SameImage  bool                   `json:"same_image"`
+	Status     DuplicateListingStatus `json:"status" gorm:"default:'pending';index"`
+	ReviewedBy *uuid.UUID             `json:"reviewed_by"`
+	ReviewedAt *time.Time             `json:"reviewed_at"`
+
+	// Relationships
+	Product  Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Original Product `json:"original,omitempty" gorm:"foreignKey:OriginalID"`
+}
+
+// RecoveryCode model for hashed two-factor backup codes
+type RecoveryCode struct {
+	BaseModel
+	UserID   uuid.UUID  `json:"user_id" gorm:"not null;index"`
+	CodeHash string     `json:"-" gorm:"not null"`
+	UsedAt   *time.Time `json:"used_at"`
+}
+
+// APIKey model for scoped server-to-server credentials
+type APIKey struct {
+	BaseModel
+	OwnerID    uuid.UUID  `json:"owner_id" gorm:"not null;index"`
+	Name       string     `json:"name" gorm:"not null"`
+	Prefix     string     `json:"prefix"` // Leading characters of the key, for identification
+	KeyHash    string     `json:"-" gorm:"uniqueIndex;not null"`
+	Scopes     string     `json:"scopes"` // Comma-separated permissions
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// ScopeList returns the key's scopes as a slice
+func (k *APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return nil
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+// IsUsable reports whether the key is neither revoked nor expired
+func (k *APIKey) IsUsable() bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || time.Now().Before(*k.ExpiresAt)
+}
+
+// WebhookSubscription model for a partner endpoint receiving its owner's events
+type WebhookSubscription struct {
+	BaseModel
+	OwnerID         uuid.UUID  `json:"owner_id" gorm:"not null;index"`
+	URL             string     `json:"url" gorm:"not null"`
+	Secret          string     `json:"-" gorm:"not null"` // Signs each delivery
+	EventTypes      string     `json:"event_types"`       // Comma-separated, empty for every event
+	Filter          string     `json:"filter"`            // Expression the payload must match, empty for every payload
+	PayloadVersion  int        `json:"payload_version" gorm:"not null;default:1"`
+	IsActive        bool       `json:"is_active" gorm:"default:true"`
+	LastDeliveredAt *time.Time `json:"last_delivered_at"`
+}
+
+// EventTypeList returns the subscribed event types as a slice
+func (s *WebhookSubscription) EventTypeList() []string {
+	if s.EventTypes == "" {
+		return nil
+	}
+	return strings.Split(s.EventTypes, ",")
+}
+
+// ProductFunnelStat model for daily per-product conversion funnel counters
+type ProductFunnelStat struct {
+	BaseModel
+	ProductID   uuid.UUID `json:"product_id" gorm:"not null;uniqueIndex:idx_funnel_product_date"`
+	Date        time.Time `json:"date" gorm:"type:date;not null;uniqueIndex:idx_funnel_product_date"`
+	Impressions int       `json:"impressions" gorm:"default:0"`
+	Views       int       `json:"views" gorm:"default:0"`
+	AddToCarts  int       `json:"add_to_carts" gorm:"default:0"`
+	Purchases   int       `json:"purchases" gorm:"default:0"`
+}
+
+// Where a sponsored product is shown
+type PromotionPlacement string
+
+const (
+	PlacementSearch   PromotionPlacement = "search"   // Above search results the product matches
+	PlacementCategory PromotionPlacement = "category" // Above its category's listing
+)
+
+// Promotion status
+type PromotionStatus string
+
+const (
+	PromotionPendingPayment PromotionStatus = "pending_payment"
+	PromotionActive         PromotionStatus = "active" // Paid, shown while inside its window
+	PromotionPaused         PromotionStatus = "paused"
+	PromotionExhausted      PromotionStatus = "exhausted" // Budget spent
+	PromotionCancelled      PromotionStatus = "cancelled"
+)
+
+// Promotion model for a sponsored placement a seller bought for a product.
+// The budget is paid up front and each click is charged against it.
+type Promotion struct {
+	BaseModel
+	SellerID    uuid.UUID          `json:"seller_id" gorm:"not null;index"`
+	ProductID   uuid.UUID          `json:"product_id" gorm:"not null;index"`
+	Placement   PromotionPlacement `json:"placement" gorm:"not null;index:idx_promotion_serving"`
+	Status      PromotionStatus    `json:"status" gorm:"default:'pending_payment';index:idx_promotion_serving"`
+	StartsAt    time.Time          `json:"starts_at" gorm:"not null"`
+	EndsAt      time.Time          `json:"ends_at" gorm:"not null"`
+	BidPerClick float64            `json:"bid_per_click" gorm:"not null"`
+	Budget      float64            `json:"budget" gorm:"not null"`
+	Spent       float64            `json:"spent" gorm:"default:0"`
+	Impressions int                `json:"impressions" gorm:"default:0"`
+	Clicks      int                `json:"clicks" gorm:"default:0"`
+
+	// Budget payment, taken through the payment service
+	PaymentMethod PaymentMethod `json:"payment_method,omitempty"`
+	TransactionID string        `json:"transaction_id,omitempty"`
+	Reference     string        `json:"reference,omitempty"`
+	PaidAt        *time.Time    `json:"paid_at"`
+
+	// Relationships
+	Product Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+}
+
+// Seller standing against the performance standards
+type SellerStanding string
+
+const (
+	StandingNew     SellerStanding = "new"     // Too few orders to be measured yet
+	StandingGood    SellerStanding = "good"    // Meets every standard
+	StandingWarning SellerStanding = "warning" // Misses at least one standard
+)
+
+// SellerPerformance model for a seller's operational metrics over a
+// trailing window, snapshotted nightly so sellers can see their history
+type SellerPerformance struct {
+	BaseModel
+	SellerID      uuid.UUID `json:"seller_id" gorm:"not null;uniqueIndex:idx_seller_performance_date"`
+	Date          time.Time `json:"date" gorm:"type:date;not null;uniqueIndex:idx_seller_performance_date;index"`
+	WindowDays    int       `json:"window_days"`
+	Orders        int       `json:"orders"`        // Orders with the seller's items placed in the window
+	ShipmentsDue  int       `json:"shipments_due"` // Orders shipped or past their ship-by time
+	ShippedOnTime int       `json:"shipped_on_time"`
+	Cancellations int       `json:"cancellations"`
+	Disputes      int       `json:"disputes"`
+
+	OnTimeShippingRate float64 `json:"on_time_shipping_rate"` // Of ShipmentsDue
+	CancellationRate   float64 `json:"cancellation_rate"`     // Of Orders
+	DisputeRate        float64 `json:"dispute_rate"`          // Of Orders
+
+	Standing      SellerStanding `json:"standing" gorm:"index"`
+	PerksEligible bool           `json:"perks_eligible"`     // Featured placement and other perks
+	Breaches      string         `json:"breaches,omitempty"` // Standards missed, comma-separated
+}
+
+// SearchStat model for daily counters of each normalized search query
+type SearchStat struct {
+	BaseModel
+	Query       string    `json:"query" gorm:"not null;uniqueIndex:idx_search_query_date"`
+	Date        time.Time `json:"date" gorm:"type:date;not null;uniqueIndex:idx_search_query_date;index"`
+	Searches    int       `json:"searches" gorm:"default:0"`
+	ZeroResults int       `json:"zero_results" gorm:"default:0"` // Searches that found no products
+	LastResults int64     `json:"last_results" gorm:"default:0"` // Products found by the latest search
+}
+
+// Ledger entry types
+type LedgerEntryType string
+
+const (
+	LedgerPayment      LedgerEntryType = "payment"       // Buyer funds captured
+	LedgerPlatformFee  LedgerEntryType = "platform_fee"  // Marketplace commission on released funds
+	LedgerSellerPayout LedgerEntryType = "seller_payout" // Released funds owed to a seller
+	LedgerRefund       LedgerEntryType = "refund"        // Funds returned to a buyer
+	LedgerDiscount     LedgerEntryType = "discount"      // Coupon discounts funded by the platform
+	LedgerReferral     LedgerEntryType = "referral"      // Referral commission owed to a seller by the platform
+	LedgerEscrowRefund LedgerEntryType = "escrow_refund" // Held funds returned to a buyer by a protection claim
+	LedgerProtection   LedgerEntryType = "protection"    // Protection claims paid out of the platform's protection fund
+)
+
+// LedgerEntry model for the marketplace money movement ledger
+type LedgerEntry struct {
+	BaseModel
+	Type        LedgerEntryType `json:"type" gorm:"not null;index"`
+	Reference   string          `json:"reference" gorm:"uniqueIndex;not null"` // Makes recording idempotent
+	OrderID     uuid.UUID       `json:"order_id" gorm:"not null;index"`
+	PaymentID   *uuid.UUID      `json:"payment_id"`
+	ReturnID    *uuid.UUID      `json:"return_id"`
+	SellerID    *uuid.UUID      `json:"seller_id" gorm:"index"`
+	StoreID     *uuid.UUID      `json:"store_id" gorm:"index"` // Store whose balance the entry counts towards
+	Amount      float64         `json:"amount" gorm:"not null"`
+	Description string          `json:"description"`
+}
+
+// Coupon model for percentage discounts issued to users
+type Coupon struct {
+	BaseModel
+	Code            string     `json:"code" gorm:"uniqueIndex;not null"`
+	UserID          *uuid.UUID `json:"user_id" gorm:"index"`   // Nil means anyone can redeem it
+	SellerID        *uuid.UUID `json:"seller_id" gorm:"index"` // Seller coupons only discount that seller's items
+	DiscountPercent float64    `json:"discount_percent" gorm:"not null"`
+	MaxDiscount     float64    `json:"max_discount" gorm:"default:0"` // 0 means no cap
+	UsageLimit      int        `json:"usage_limit" gorm:"default:1"`
+	UsedCount       int        `json:"used_count" gorm:"default:0"`
+	ExpiresAt       *time.Time `json:"expires_at"`
+	Source          string     `json:"source"` // campaign, abandoned_cart, admin, seller
+}
+
+// CouponRedemption model for coupons applied to orders
+type CouponRedemption struct {
+	BaseModel
+	CouponID uuid.UUID `json:"coupon_id" gorm:"not null;index"`
+	OrderID  uuid.UUID `json:"order_id" gorm:"not null;uniqueIndex"`
+	UserID   uuid.UUID `json:"user_id" gorm:"not null"`
+	Discount float64   `json:"discount" gorm:"not null"`
+	Revenue  float64   `json:"revenue" gorm:"default:0"`       // Attributed revenue after the discount
+	NewBuyer bool      `json:"new_buyer" gorm:"default:false"` // First order from the buyer, with the seller for seller coupons
+}
+
+// Campaign model for re-engagement campaigns targeting dormant users
+type Campaign struct {
+	BaseModel
+	Name            string     `json:"name" gorm:"not null"`
+	InactiveDays    int        `json:"inactive_days" gorm:"not null"` // Users inactive at least this long are targeted
+	Title           string     `json:"title" gorm:"not null"`
+	Message         string     `json:"message" gorm:"not null"`
+	BonusXP         int        `json:"bonus_xp" gorm:"default:0"`
+	CouponPercent   float64    `json:"coupon_percent" gorm:"default:0"`
+	CouponValidDays int        `json:"coupon_valid_days" gorm:"default:14"`
+	MaxSendsPerUser int        `json:"max_sends_per_user" gorm:"default:1"`
+	CooldownDays    int        `json:"cooldown_days" gorm:"default:30"` // Minimum days between sends to the same user
+	DailySendLimit  int        `json:"daily_send_limit" gorm:"default:1000"`
+	IsActive        bool       `json:"is_active" gorm:"default:true"`
+	LastRunAt       *time.Time `json:"last_run_at"`
+}
+
+// Announcement kinds
+type AnnouncementKind string
+
+const (
+	AnnouncementCampaign AnnouncementKind = "campaign" // Promotions, pushed as marketing
+	AnnouncementNotice   AnnouncementKind = "notice"   // Downtime and policy notices, pushed to everyone in the audience
+)
+
+// Announcement audiences
+type AnnouncementAudience string
+
+const (
+	AudienceEveryone AnnouncementAudience = "everyone"
+	AudienceBuyers   AnnouncementAudience = "buyers"
+	AudienceSellers  AnnouncementAudience = "sellers"
+)
+
+// Announcement model for admin-managed banners shown in the app between
+// StartsAt and EndsAt, optionally pushed as a notification when they start
+type Announcement struct {
+	BaseModel
+	Title    string               `json:"title" gorm:"not null"`
+	Body     string               `json:"body" gorm:"not null"`
+	Kind     AnnouncementKind     `json:"kind" gorm:"default:'notice'"`
+	Audience AnnouncementAudience `json:"audience" gorm:"default:'everyone'"`
+	Link     string               `json:"link"` // Deep link the banner opens
+	StartsAt time.Time            `json:"starts_at" gorm:"not null;index"`
+	EndsAt   *time.Time           `json:"ends_at"` // Nil shows it until it's deactivated
+	Push     bool                 `json:"push" gorm:"default:false"`
+	PushedAt *time.Time           `json:"pushed_at"`
+	IsActive bool                 `json:"is_active" gorm:"default:true"`
+}
+
+// UserTag model for a label admins put on a user for marketing, e.g. "vip"
+type UserTag struct {
+	BaseModel
+	UserID    uuid.UUID `json:"user_id" gorm:"not null;uniqueIndex:idx_user_tag"`
+	Tag       string    `json:"tag" gorm:"not null;uniqueIndex:idx_user_tag;index"`
+	CreatedBy uuid.UUID `json:"created_by"`
+}
+
+// SegmentRules select the users in a segment. Every rule set must match;
+// unset rules match everyone.
+type SegmentRules struct {
+	Roles               []UserRole  `json:"roles,omitempty"`  // Any of these profiles, defaults to buyers and sellers
+	Levels              []UserLevel `json:"levels,omitempty"` // Any of these levels
+	MinTotalSpent       *float64    `json:"min_total_spent,omitempty"`
+	MaxTotalSpent       *float64    `json:"max_total_spent,omitempty"`
+	LastOrderWithinDays *int        `json:"last_order_within_days,omitempty"` // Ordered within the last N days
+	NoOrderForDays      *int        `json:"no_order_for_days,omitempty"`      // Ordered before, but not in the last N days
+	Categories          []string    `json:"categories,omitempty"`             // Bought in any of these categories
+	MinCategoryOrders   int         `json:"min_category_orders,omitempty"`    // Orders in those categories, defaults to 1
+	Tags                []string    `json:"tags,omitempty"`                   // Tagged with any of these
+}
+
+// Segment model for a rule-defined group of users marketing targets.
+// Memberships are materialized nightly and whenever the rules change.
+type Segment struct {
+	BaseModel
+	Name           string       `json:"name" gorm:"uniqueIndex;not null"`
+	Description    string       `json:"description"`
+	Rules          SegmentRules `json:"rules" gorm:"serializer:json"`
+	IsActive       bool         `json:"is_active" gorm:"default:true"` // Inactive segments aren't refreshed
+	MemberCount    int          `json:"member_count" gorm:"default:0"`
+	MaterializedAt *time.Time   `json:"materialized_at"`
+	CreatedBy      uuid.UUID    `json:"created_by"`
+}
+
+// SegmentMember model for a user in a segment as of its last materialization
+type SegmentMember struct {
+	BaseModel
+	SegmentID uuid.UUID `json:"segment_id" gorm:"not null;uniqueIndex:idx_segment_member"`
+	UserID    uuid.UUID `json:"user_id" gorm:"not null;uniqueIndex:idx_segment_member;index"`
+}
+
+// CampaignSend model for each campaign message delivered to a user
+type CampaignSend struct {
+	BaseModel
+	CampaignID uuid.UUID  `json:"campaign_id" gorm:"not null;index:idx_campaign_send_user"`
+	UserID     uuid.UUID  `json:"user_id" gorm:"not null;index:idx_campaign_send_user"`
+	CouponID   *uuid.UUID `json:"coupon_id"`
+	BonusXP    int        `json:"bonus_xp"`
+}
+
+// Cart model, kept server-side so guests and signed-in users share one flow
+type Cart struct {
+	BaseModel
+	UserID         uuid.UUID  `json:"user_id" gorm:"not null;uniqueIndex"`
+	LastActivityAt time.Time  `json:"last_activity_at"`
+	RemindersSent  int        `json:"reminders_sent" gorm:"default:0"` // Abandoned-cart reminders since the cart was last emptied
+	LastRemindedAt *time.Time `json:"last_reminded_at"`
+
+	// Relationships
+	Items []CartItem `json:"items,omitempty" gorm:"foreignKey:CartID"`
+}
+
+// CartItem model
+type CartItem struct {
+	BaseModel
+	CartID    uuid.UUID  `json:"cart_id" gorm:"not null;uniqueIndex:idx_cart_item_variant"`
+	ProductID uuid.UUID  `json:"product_id" gorm:"not null;uniqueIndex:idx_cart_item_variant"`
+	VariantID *uuid.UUID `json:"variant_id" gorm:"uniqueIndex:idx_cart_item_variant"`
+	Quantity  int        `json:"quantity" gorm:"not null"`
+
+	// Relationships
+	Product Product         `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Variant *ProductVariant `json:"variant,omitempty" gorm:"foreignKey:VariantID"`
+}
+
+// RestockSubscription model for buyers waiting on an out-of-stock product
+type RestockSubscription struct {
+	BaseModel
+	ProductID  uuid.UUID  `json:"product_id" gorm:"not null;uniqueIndex:idx_restock_product_user"`
+	UserID     uuid.UUID  `json:"user_id" gorm:"not null;uniqueIndex:idx_restock_product_user"`
+	NotifiedAt *time.Time `json:"notified_at"` // Set once the buyer has been told it is back
+}
+
+// WishlistItem model for a product a user saved for later
+type WishlistItem struct {
+	BaseModel
+	UserID    uuid.UUID `json:"user_id" gorm:"not null;uniqueIndex:idx_wishlist_user_product"`
+	ProductID uuid.UUID `json:"product_id" gorm:"not null;uniqueIndex:idx_wishlist_user_product;index"`
+
+	Product Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+}
+
+// PaymentPhone model for a phone number a user saved for mobile payments.
+// It can only be paid with once verified by a code sent to it.
+type PaymentPhone struct {
+	BaseModel
+	UserID     uuid.UUID  `json:"user_id" gorm:"not null;uniqueIndex:idx_payment_phone_user_phone"`
+	Label      string     `json:"label"` // e.g. "My Telebirr"
+	Phone      string     `json:"phone" gorm:"not null;uniqueIndex:idx_payment_phone_user_phone"`
+	VerifiedAt *time.Time `json:"verified_at"`
+}
+
+// Identity model linking a user to an external login provider
+type Identity struct {
+	BaseModel
+	UserID   uuid.UUID `json:"user_id" gorm:"not null;index"`
+	Provider string    `json:"provider" gorm:"not null;uniqueIndex:idx_identity_provider_subject"`
+	Subject  string    `json:"-" gorm:"not null;uniqueIndex:idx_identity_provider_subject"` // Provider's user ID
+	Email    string    `json:"email"`
+}
+
+// Seller application review status
+type SellerApplicationStatus string
+
+const (
+	SellerApplicationPending  SellerApplicationStatus = "pending"
+	SellerApplicationApproved SellerApplicationStatus = "approved"
+	SellerApplicationRejected SellerApplicationStatus = "rejected"
+)
+
+// SellerApplication model for buyers asking to become sellers
+type SellerApplication struct {
+	BaseModel
+	UserID        uuid.UUID               `json:"user_id" gorm:"not null;index"`
+	BusinessName  string                  `json:"business_name" gorm:"not null"`
+	IDDocumentURL string                  `json:"id_document_url" gorm:"not null"`
+	PayoutMethod  string                  `json:"payout_method" gorm:"not null"` // bank or mobile_money
+	PayoutAccount string                  `json:"payout_account" gorm:"not null"`
+	ReferrerID    *uuid.UUID              `json:"referrer_id"` // Seller whose referral code was used
+	Status        SellerApplicationStatus `json:"status" gorm:"default:'pending';index"`
+	ReviewedBy    *uuid.UUID              `json:"reviewed_by"`
+	ReviewedAt    *time.Time              `json:"reviewed_at"`
+	Notes         string                  `json:"notes"`
+
+	// Relationships
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// Seller verification (KYC) review status
+type SellerVerificationStatus string
+
+const (
+	SellerVerificationPending  SellerVerificationStatus = "pending"
+	SellerVerificationApproved SellerVerificationStatus = "approved"
+	SellerVerificationRejected SellerVerificationStatus = "rejected"
+)
+
+// SellerVerification model for identity documents sellers submit to earn
+// the verified badge
+type SellerVerification struct {
+	BaseModel
+	UserID       uuid.UUID                `json:"user_id" gorm:"not null;index"`
+	DocumentType string                   `json:"document_type" gorm:"not null"` // national_id, passport or business_registration
+	DocumentURL  string                   `json:"document_url" gorm:"not null"`
+	Status       SellerVerificationStatus `json:"status" gorm:"default:'pending';index"`
+	ReviewedBy   *uuid.UUID               `json:"reviewed_by"`
+	ReviewedAt   *time.Time               `json:"reviewed_at"`
+	Notes        string                   `json:"notes"`
+
+	// Relationships
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// Offer negotiation status
+type OfferStatus string
+
+const (
+	OfferPending   OfferStatus = "pending"   // Waiting for the seller
+	OfferCountered OfferStatus = "countered" // Waiting for the buyer
+	OfferAccepted  OfferStatus = "accepted"  // Checkout link issued
+	OfferDeclined  OfferStatus = "declined"
+	OfferExpired   OfferStatus = "expired"
+	OfferRedeemed  OfferStatus = "redeemed" // Checked out
+)
+
+// Offer model for buyer price proposals on a product
+type Offer struct {
+	BaseModel
+	ProductID    uuid.UUID   `json:"product_id" gorm:"not null;index"`
+	BuyerID      uuid.UUID   `json:"buyer_id" gorm:"not null;index"`
+	SellerID     uuid.UUID   `json:"seller_id" gorm:"not null;index"`
+	Quantity     int         `json:"quantity" gorm:"not null"`
+	OfferPrice   float64     `json:"offer_price" gorm:"not null"` // Buyer's unit price
+	CounterPrice *float64    `json:"counter_price"`               // Seller's unit price
+	AgreedPrice  *float64    `json:"agreed_price"`
+	Message      string      `json:"message"`
+	Status       OfferStatus `json:"status" gorm:"default:'pending';index"`
+	ExpiresAt    time.Time   `json:"expires_at"` // Deadline for the current response, then for checkout
+
+	CheckoutToken string     `json:"-" gorm:"index"`
+	OrderID       *uuid.UUID `json:"order_id"`
+
+	// Relationships
+	Product Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+}
+
+// IsOpen reports whether the offer is still waiting for a response
+func (o *Offer) IsOpen() bool {
+	return (o.Status == OfferPending || o.Status == OfferCountered) && time.Now().Before(o.ExpiresAt)
+}
+
+// UserSanction model recording admin suspensions, bans and reinstatements
+type UserSanction struct {
+	BaseModel
+	UserID    uuid.UUID     `json:"user_id" gorm:"not null;index"`
+	AdminID   uuid.UUID     `json:"admin_id" gorm:"not null"`
+	Status    AccountStatus `json:"status" gorm:"not null"` // Status applied
+	Reason    string        `json:"reason"`
+	ExpiresAt *time.Time    `json:"expires_at"`
+}
+
+// Seller referral status
+type ReferralStatus string
+
+const (
+	ReferralPending  ReferralStatus = "pending"  // Referred seller hasn't reached the qualifying sales yet
+	ReferralRewarded ReferralStatus = "rewarded"
+)
+
+// SellerReferral model tracking a seller who joined with another seller's referral code
+type SellerReferral struct {
+	BaseModel
+	ReferrerID   uuid.UUID      `json:"referrer_id" gorm:"not null;index"`
+	ReferredID   uuid.UUID      `json:"referred_id" gorm:"not null;uniqueIndex"`
+	Status       ReferralStatus `json:"status" gorm:"default:'pending';index"`
+	Sales        int            `json:"sales" gorm:"default:0"`        // Delivered sales counted so far
+	SalesAmount  float64        `json:"sales_amount" gorm:"default:0"` // Value of the counted sales
+	RewardXP     int            `json:"reward_xp" gorm:"default:0"`
+	RewardAmount float64        `json:"reward_amount" gorm:"default:0"` // Commission share paid to the referrer
+	RewardedAt   *time.Time     `json:"rewarded_at"`
+
+	// Relationships
+	Referred User `json:"referred,omitempty" gorm:"foreignKey:ReferredID"`
+}
+
+// Store staff permissions a seller can grant
+type StaffPermission string
+
+const (
+	StaffPermProducts StaffPermission = "products" // Manage listings and inventory
+	StaffPermOrders   StaffPermission = "orders"   // Fulfill orders
+)
+
+// Store staff membership status
+type StaffStatus string
+
+const (
+	StaffInvited  StaffStatus = "invited"
+	StaffActive   StaffStatus = "active"
+	StaffDeclined StaffStatus = "declined"
+	StaffRemoved  StaffStatus = "removed"
+)
+
+// StoreStaff model for users a seller has invited to help run their store
+type StoreStaff struct {
+	BaseModel
+	SellerID    uuid.UUID   `json:"seller_id" gorm:"not null;index"`
+	Phone       string      `json:"phone" gorm:"not null;index"` // Invitee, who may not have an account yet
+	UserID      *uuid.UUID  `json:"user_id" gorm:"index"`        // Set once the invitation is accepted
+	Permissions string      `json:"permissions" gorm:"not null"` // Comma-separated staff permissions
+	Status      StaffStatus `json:"status" gorm:"default:'invited';index"`
+	ExpiresAt   time.Time   `json:"expires_at"` // Invitation deadline
+	AcceptedAt  *time.Time  `json:"accepted_at"`
+
+	// Relationships
+	Seller User `json:"seller,omitempty" gorm:"foreignKey:SellerID"`
+}
+
+// PermissionList returns the staff member's permissions as a slice
+func (s *StoreStaff) PermissionList() []StaffPermission {
+	if s.Permissions == "" {
+		return nil
+	}
+	var list []StaffPermission
+	for _, p := range strings.Split(s.Permissions, ",") {
+		list = append(list, StaffPermission(p))
+	}
+	return list
+}
+
+// Store model for the business identity selling on the marketplace. The owner's
+// seller account lists its products; balances and payouts belong to the store.
+type Store struct {
+	BaseModel
+	OwnerID       uuid.UUID `json:"owner_id" gorm:"not null;uniqueIndex"` // Seller account the store sells through
+	Name          string    `json:"name" gorm:"not null"`
+	Slug          string    `json:"slug" gorm:"uniqueIndex;not null"`
+	Description   string    `json:"description"`
+	LogoURL       string    `json:"logo_url"`
+	PayoutMethod  string    `json:"payout_method,omitempty"` // bank or mobile_money
+	PayoutAccount string    `json:"payout_account,omitempty"`
+	IsActive      bool      `json:"is_active" gorm:"default:true"`
+	Verified      bool      `json:"verified" gorm:"-"` // Owner passed seller verification, filled in on read
+
+	// Relationships
+	Members []StoreMember `json:"members,omitempty" gorm:"foreignKey:StoreID"`
+}
+
+// Store member roles
+type StoreMemberRole string
+
+const (
+	StoreOwner   StoreMemberRole = "owner"
+	StoreManager StoreMemberRole = "manager" // Full access to the store, including payouts
+)
+
+// StoreMember model linking users to the store they co-own or manage
+type StoreMember struct {
+	BaseModel
+	StoreID uuid.UUID       `json:"store_id" gorm:"not null;uniqueIndex:idx_store_member"`
+	UserID  uuid.UUID       `json:"user_id" gorm:"not null;uniqueIndex:idx_store_member"`
+	Role    StoreMemberRole `json:"role" gorm:"not null"`
+
+	// Relationships
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// Order issue types buyers can report
+type OrderIssueType string
+
+const (
+	IssueItemMissing OrderIssueType = "item_missing"
+	IssueDamaged     OrderIssueType = "damaged"
+	IssueWrongItem   OrderIssueType = "wrong_item"
+	IssueLate        OrderIssueType = "late"
+	IssueOther       OrderIssueType = "other"
+	IssueProtection  OrderIssueType = "buyer_protection" // Paid online but never delivered
+)
+
+// Workflows an order issue is routed to
+type IssueWorkflow string
+
+const (
+	WorkflowRefund     IssueWorkflow = "refund"     // Seller refunds the item through a return request
+	WorkflowDispute    IssueWorkflow = "dispute"    // Escrow stays held while support mediates
+	WorkflowSupport    IssueWorkflow = "support"    // Handled by the support team
+	WorkflowProtection IssueWorkflow = "protection" // Checked against shipments and paid out by buyer protection
+)
+
+// Order issue statuses
+type IssueStatus string
+
+const (
+	IssueOpen     IssueStatus = "open"
+	IssueResolved IssueStatus = "resolved"
+)
+
+// OrderIssue model for problems buyers report on an order
+type OrderIssue struct {
+	BaseModel
+	OrderID      uuid.UUID      `json:"order_id" gorm:"not null;index"`
+	OrderItemID  *uuid.UUID     `json:"order_item_id"`
+	BuyerID      uuid.UUID      `json:"buyer_id" gorm:"not null;index"`
+	SellerID     uuid.UUID      `json:"seller_id" gorm:"not null;index"`
+	Type         OrderIssueType `json:"type" gorm:"not null"`
+	Workflow     IssueWorkflow  `json:"workflow" gorm:"not null;index"`
+	Status       IssueStatus    `json:"status" gorm:"default:'open';index"`
+	Description  string         `json:"description"`
+	ReturnID     *uuid.UUID     `json:"return_id" gorm:"index"` // Return request opened by the refund workflow
+	Response     string         `json:"response"`               // Seller's reply
+	Resolution   string         `json:"resolution"`
+	ResolvedBy   *uuid.UUID     `json:"resolved_by"`
+	PayoutAmount float64        `json:"payout_amount" gorm:"default:0"` // Paid to the buyer by buyer protection
+
+	// Resolution SLAs
+	RespondBy   time.Time  `json:"respond_by"`
+	ResolveBy   time.Time  `json:"resolve_by"`
+	RespondedAt *time.Time `json:"responded_at"`
+	ResolvedAt  *time.Time `json:"resolved_at"`
+	SLABreached bool       `json:"sla_breached" gorm:"default:false;index"`
+
+	// Relationships
+	Order Order `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+}
+
+// Job status
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"
+	JobRunning   JobStatus = "running"
+	JobCompleted JobStatus = "completed"
+	JobFailed    JobStatus = "failed" // Out of attempts, waiting for an admin to retry
+)
+
+// Job model for background work that has to survive restarts
+type Job struct {
+	BaseModel
+	Kind        string     `json:"kind" gorm:"not null;index"`
+	Payload     string     `json:"payload"` // JSON handed to the kind's handler
+	Status      JobStatus  `json:"status" gorm:"default:'pending';index:idx_job_due"`
+	RunAt       time.Time  `json:"run_at" gorm:"not null;index:idx_job_due"`
+	Attempts    int        `json:"attempts" gorm:"default:0"`
+	MaxAttempts int        `json:"max_attempts"`
+	LockedUntil *time.Time `json:"locked_until"` // Lease held by the worker running it
+	LastError   string     `json:"last_error"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// AccountPurge model for the summary report of one run of the deleted
+// account purge
+type AccountPurge struct {
+	BaseModel
+	DryRun         bool       `json:"dry_run" gorm:"default:false"`
+	DeletedBefore  time.Time  `json:"deleted_before"` // Accounts deleted before this were eligible
+	TriggeredBy    *uuid.UUID `json:"triggered_by"`   // Nil for scheduled runs
+	Users          int        `json:"users"`          // Accounts purged, or that would be in a dry run
+	Skipped        int        `json:"skipped"`        // Accounts that couldn't be purged
+	Orders         int64      `json:"orders"`         // Orders detached from purged accounts
+	Payments       int64      `json:"payments"`
+	XPTransactions int64      `json:"xp_transactions"`
+	Errors         string     `json:"errors"` // Why each skipped account was skipped, one per line
+	CompletedAt    *time.Time `json:"completed_at"`
+}
+
+// RetentionPurge model for the report of a run deleting logs and history
+// older than the configured retention periods
+type RetentionPurge struct {
+	BaseModel
+	DryRun        bool       `json:"dry_run" gorm:"default:false"`
+	TriggeredBy   *uuid.UUID `json:"triggered_by"`   // Nil for scheduled runs
+	OTPLogs       int64      `json:"otp_logs"`       // Rows deleted, or that would be in a dry run
+	Sessions      int64      `json:"sessions"`       // Session events and stale devices
+	Notifications int64      `json:"notifications"`
+	AuditLogs     int64      `json:"audit_logs"`
+	Errors        string     `json:"errors"` // Categories that failed part way, one per line
+	CompletedAt   *time.Time `json:"completed_at"`
+}
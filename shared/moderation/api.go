@@ -0,0 +1,82 @@
+package moderation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// APIProvider checks content against an external moderation service.
+// The service receives {"text": "..."} and responds with
+// {"flagged": bool, "categories": ["..."]}.
+type APIProvider struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+func NewAPIProvider(url, apiKey string) *APIProvider {
+	return &APIProvider{
+		url:    url,
+		apiKey: apiKey,
+		client: &http.Client{Timeout: 3 * time.Second},
+	}
+}
+
+func (p *APIProvider) Check(text string) (Result, error) {
+	return p.post(map[string]string{"text": text}, "api")
+}
+
+// VisionProvider checks images against an external vision moderation
+// service. It receives {"image_url": "..."} and responds like APIProvider.
+type VisionProvider struct {
+	*APIProvider
+}
+
+func NewVisionProvider(url, apiKey string) *VisionProvider {
+	provider := NewAPIProvider(url, apiKey)
+	provider.client.Timeout = 30 * time.Second
+	return &VisionProvider{APIProvider: provider}
+}
+
+func (p *VisionProvider) ScanImage(imageURL string) (Result, error) {
+	return p.post(map[string]string{"image_url": imageURL}, "vision")
+}
+
+func (p *APIProvider) post(payload map[string]string, source string) (Result, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Result{}, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("moderation API returned status %d", resp.StatusCode)
+	}
+
+	var apiResult struct {
+		Flagged    bool     `json:"flagged"`
+		Categories []string `json:"categories"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&apiResult); err != nil {
+		return Result{}, err
+	}
+
+	return Result{Flagged: apiResult.Flagged, Matches: apiResult.Categories, Source: source}, nil
+}
@@ -0,0 +1,80 @@
+package moderation
+
+import (
+	"fmt"
+	"log"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+
+	"github.com/google/uuid"
+)
+
+// ImageScanner checks an image for prohibited content.
+type ImageScanner interface {
+	ScanImage(imageURL string) (Result, error)
+}
+
+var imageScanners []ImageScanner
+
+// RegisterImageScanner adds a vision provider used by ScanImageAsync.
+func RegisterImageScanner(scanner ImageScanner) {
+	imageScanners = append(imageScanners, scanner)
+}
+
+// ScanImageAsync scans an uploaded image in the background. If a scanner
+// flags it, quarantine is called to hide the image, a flag is stored with
+// the original URL for review, and the uploader and moderators are notified.
+func ScanImageAsync(contentType string, contentID, userID uuid.UUID, imageURL string, quarantine func() error) {
+	if imageURL == "" || len(imageScanners) == 0 {
+		return
+	}
+
+	go func() {
+		for _, scanner := range imageScanners {
+			result, err := scanner.ScanImage(imageURL)
+			if err != nil {
+				log.Printf("Image scan failed for %s %s: %v", contentType, contentID, err)
+				continue
+			}
+			if !result.Flagged {
+				continue
+			}
+
+			if err := quarantine(); err != nil {
+				log.Printf("Failed to quarantine image for %s %s: %v", contentType, contentID, err)
+				return
+			}
+
+			flag := models.ContentFlag{
+				BaseModel:   models.BaseModel{ID: uuid.New()},
+				ContentType: contentType,
+				ContentID:   contentID,
+				UserID:      userID,
+				Matches:     joinMatches(result.Matches),
+				Source:      result.Source,
+				ImageURL:    imageURL,
+				Status:      models.ContentFlagPending,
+			}
+			if err := database.DB.Create(&flag).Error; err != nil {
+				log.Printf("Failed to store image flag for %s %s: %v", contentType, contentID, err)
+				return
+			}
+
+			notifications.Send(userID, models.NotificationModeration, "Image held for review",
+				"One of your uploaded images was flagged by our content checks and is hidden until a moderator reviews it.")
+			notifyModerators(fmt.Sprintf("A %s was quarantined and is waiting for review.", contentType))
+			return
+		}
+	}()
+}
+
+func notifyModerators(message string) {
+	var moderatorIDs []uuid.UUID
+	database.DB.Model(&models.User{}).Where("role = ? AND is_active = ?", models.RoleAdmin, true).Pluck("id", &moderatorIDs)
+
+	for _, moderatorID := range moderatorIDs {
+		notifications.Send(moderatorID, models.NotificationModeration, "Content awaiting moderation", message)
+	}
+}
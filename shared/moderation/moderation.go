@@ -0,0 +1,222 @@
+package moderation
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"playful-marketplace/shared/config"
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+
+	"github.com/google/uuid"
+)
+
+// Moderation modes
+const (
+	ModeBlock = "block"
+	ModeFlag  = "flag"
+)
+
+// Content types recorded on flags
+const (
+	ContentProduct      = "product"
+	ContentReview       = "review"
+	ContentChatMessage  = "chat_message"
+	ContentProductImage = "product_image"
+	ContentReviewImage  = "review_image"
+	ContentReviewReply  = "review_reply"
+)
+
+// Result of checking a piece of content
+type Result struct {
+	Flagged bool
+	Matches []string
+	Source  string
+}
+
+// Provider checks text for disallowed content. The built-in word list is
+// always used; an external provider can be registered alongside it.
+type Provider interface {
+	Check(text string) (Result, error)
+}
+
+var (
+	mode      = ModeBlock
+	wordList  = newWordListProvider(defaultWordLists)
+	providers []Provider
+)
+
+// Init configures the filter from config. Services call it once at startup.
+func Init(cfg *config.Config) {
+	if cfg.Moderation.Mode == ModeFlag {
+		mode = ModeFlag
+	}
+
+	if cfg.Moderation.WordListDir != "" {
+		lists, err := loadWordLists(cfg.Moderation.WordListDir)
+		if err != nil {
+			log.Printf("Failed to load moderation word lists: %v", err)
+		}
+		for lang, words := range lists {
+			wordList.add(lang, words)
+		}
+	}
+
+	if cfg.Moderation.APIURL != "" {
+		Register(NewAPIProvider(cfg.Moderation.APIURL, cfg.Moderation.APIKey))
+	}
+
+	if cfg.Moderation.VisionURL != "" {
+		RegisterImageScanner(NewVisionProvider(cfg.Moderation.VisionURL, cfg.Moderation.APIKey))
+	}
+}
+
+// Register adds an external provider consulted after the word list.
+func Register(provider Provider) {
+	providers = append(providers, provider)
+}
+
+// Check runs the texts through the word list and any registered providers.
+// Provider errors are logged and skipped so an outage never blocks content.
+func Check(texts ...string) Result {
+	text := strings.Join(texts, "\n")
+
+	result, _ := wordList.Check(text)
+	if result.Flagged {
+		return result
+	}
+
+	for _, provider := range providers {
+		providerResult, err := provider.Check(text)
+		if err != nil {
+			log.Printf("Moderation provider failed: %v", err)
+			continue
+		}
+		if providerResult.Flagged {
+			return providerResult
+		}
+	}
+
+	return Result{}
+}
+
+// Blocked reports whether flagged content should be rejected outright.
+func Blocked(result Result) bool {
+	return result.Flagged && mode == ModeBlock
+}
+
+// Flag records flagged content for moderator review.
+func Flag(contentType string, contentID, userID uuid.UUID, result Result) error {
+	flag := models.ContentFlag{
+		BaseModel:   models.BaseModel{ID: uuid.New()},
+		ContentType: contentType,
+		ContentID:   contentID,
+		UserID:      userID,
+		Matches:     joinMatches(result.Matches),
+		Source:      result.Source,
+		Status:      models.ContentFlagPending,
+	}
+	return database.DB.Create(&flag).Error
+}
+
+func joinMatches(matches []string) string {
+	return strings.Join(matches, ",")
+}
+
+// Word list provider
+
+type wordListProvider struct {
+	words map[string]string // normalized word -> language
+}
+
+func newWordListProvider(lists map[string][]string) *wordListProvider {
+	provider := &wordListProvider{words: make(map[string]string)}
+	for lang, words := range lists {
+		provider.add(lang, words)
+	}
+	return provider
+}
+
+func (p *wordListProvider) add(lang string, words []string) {
+	for _, word := range words {
+		if word = normalize(word); word != "" {
+			p.words[word] = lang
+		}
+	}
+}
+
+func (p *wordListProvider) Check(text string) (Result, error) {
+	var matches []string
+	seen := make(map[string]bool)
+
+	// Whole-word matching avoids flagging innocent words that contain a term
+	for _, token := range strings.FieldsFunc(normalize(text), isSeparator) {
+		if _, ok := p.words[token]; ok && !seen[token] {
+			seen[token] = true
+			matches = append(matches, token)
+		}
+	}
+
+	return Result{Flagged: len(matches) > 0, Matches: matches, Source: "wordlist"}, nil
+}
+
+// Common character substitutions used to dodge filters
+var substitutions = strings.NewReplacer(
+	"0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s",
+)
+
+// normalize lowercases text and undoes the substitutions, only within words
+// that have letters in them: prices, phone numbers and order numbers are
+// left alone rather than read as letters.
+func normalize(text string) string {
+	tokens := strings.FieldsFunc(strings.ToLower(text), isTokenSeparator)
+	for i, token := range tokens {
+		if strings.IndexFunc(token, unicode.IsLetter) >= 0 {
+			tokens[i] = substitutions.Replace(token)
+		}
+	}
+	return strings.Join(tokens, " ")
+}
+
+// isTokenSeparator splits words before substitution, keeping the digits and
+// symbols that stand in for letters
+func isTokenSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsMark(r) && !unicode.IsDigit(r) && r != '@' && r != '$'
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsMark(r)
+}
+
+// loadWordLists reads <lang>.txt files with one word per line; lines
+// starting with # are comments.
+func loadWordLists(dir string) (map[string][]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return nil, err
+	}
+
+	lists := make(map[string][]string)
+	for _, path := range files {
+		file, err := os.Open(path)
+		if err != nil {
+			return lists, err
+		}
+
+		lang := strings.TrimSuffix(filepath.Base(path), ".txt")
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				lists[lang] = append(lists[lang], line)
+			}
+		}
+		file.Close()
+	}
+
+	return lists, nil
+}
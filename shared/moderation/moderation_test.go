@@ -0,0 +1,75 @@
+package moderation
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"Great Product!", "great product"},
+		{"b1tch", "bitch"},
+		{"$h1t", "shit"},
+		{"sh!t", "sh t"},
+		{"4ssh0le", "asshole"},
+		{"a@b.com", "aab com"},
+		{"Call +251911000000", "call 251911000000"},
+		{"Order ORD-20240101-123456", "order ord 20240101 123456"},
+		{"Only 1500 ETB", "only 1500 etb"},
+		{"ሸርሙጣ!", "ሸርሙጣ"},
+	}
+	for _, tt := range tests {
+		if got := normalize(tt.text); got != tt.want {
+			t.Errorf("normalize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestWordListCheck(t *testing.T) {
+	provider := newWordListProvider(defaultWordLists)
+
+	tests := []struct {
+		text    string
+		matches []string
+	}{
+		{"Lovely scarf, fast delivery", nil},
+		{"Call me on +251911000000 about order ORD-20240101-123456", nil},
+		{"Costs 5000 birr", nil},
+		{"Dried shitake from Scunthorpe", nil}, // Terms inside innocent words
+		{"What a B1TCH", []string{"bitch"}},
+		{"$hit product, total bullshit, shit", []string{"shit", "bullshit"}},
+		{"አንተ ዲቃላ", []string{"ዲቃላ"}},
+		{"you dikala", []string{"dikala"}},
+	}
+	for _, tt := range tests {
+		result, err := provider.Check(tt.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Flagged != (len(tt.matches) > 0) || !reflect.DeepEqual(result.Matches, tt.matches) {
+			t.Errorf("Check(%q) = %+v, want matches %v", tt.text, result, tt.matches)
+		}
+	}
+}
+
+func TestLoadWordLists(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "om.txt"), []byte("# Afaan Oromo\n\nfirst\n  second  \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.md"), []byte("ignored"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	lists, err := loadWordLists(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string][]string{"om": {"first", "second"}}; !reflect.DeepEqual(lists, want) {
+		t.Errorf("got %v, want %v", lists, want)
+	}
+}
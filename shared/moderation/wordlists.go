@@ -0,0 +1,33 @@
+package moderation
+
+// Built-in word lists keyed by language. Deployments extend these, or add
+// languages, with MODERATION_WORDLIST_DIR rather than editing this file.
+// Amharic is listed in Ethiopic script along with its common Latin
+// spellings.
+var defaultWordLists = map[string][]string{
+	"am": {
+		"ሸርሙጣ",
+		"ሸርሙጦች",
+		"ዲቃላ",
+		"ዲቃሎች",
+		"sharmuta",
+		"shermuta",
+		"dikala",
+		"diqala",
+	},
+	"en": {
+		"asshole",
+		"bastard",
+		"bitch",
+		"bullshit",
+		"cunt",
+		"dickhead",
+		"fuck",
+		"fucker",
+		"fucking",
+		"motherfucker",
+		"shit",
+		"slut",
+		"whore",
+	},
+}
@@ -0,0 +1,140 @@
+package notifications
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Most items of one type listed in a digest before it's summarised
+const digestItemsPerType = 5
+
+// IsDigestible reports whether notifications of the type can wait for a digest
+func IsDigestible(notificationType models.NotificationType) bool {
+	switch notificationType {
+	case models.NotificationLeaderboard, models.NotificationPriceDrop:
+		return true
+	}
+	return false
+}
+
+// SendDigests delivers one summary of held notifications and XP earned to
+// each user whose daily or weekly digest is due. Users who switched to
+// immediate delivery get anything still held. Run periodically by the
+// scheduler.
+func SendDigests() {
+	now := time.Now()
+
+	var users []models.User
+	database.DB.Where("is_active = ? AND role <> ?", true, models.RoleGuest).
+		Where("(digest_frequency = ? AND (last_digest_at IS NULL OR last_digest_at <= ?)) OR "+
+			"(digest_frequency = ? AND (last_digest_at IS NULL OR last_digest_at <= ?)) OR digest_frequency = ?",
+			models.DigestDaily, now.Add(-24*time.Hour), models.DigestWeekly, now.AddDate(0, 0, -7), models.DigestImmediate).
+		Where("EXISTS (SELECT 1 FROM notifications WHERE notifications.user_id = users.id AND notifications.digest_pending = ?) OR "+
+			"(digest_frequency <> ? AND EXISTS (SELECT 1 FROM xp_transactions WHERE xp_transactions.user_id = users.id AND xp_transactions.created_at > COALESCE(users.last_digest_at, ?)))",
+			true, models.DigestImmediate, now.AddDate(0, 0, -7)).
+		Limit(500).
+		Find(&users)
+
+	for i := range users {
+		if err := sendDigest(&users[i], now); err != nil {
+			log.Printf("Failed to send digest to user %s: %v", users[i].ID, err)
+		}
+	}
+}
+
+func sendDigest(user *models.User, now time.Time) error {
+	title := "Your daily digest"
+	since := now.Add(-24 * time.Hour)
+	switch user.DigestFrequency {
+	case models.DigestWeekly:
+		title = "Your weekly digest"
+		since = now.AddDate(0, 0, -7)
+	case models.DigestImmediate:
+		title = "Notifications you missed"
+	}
+	if user.LastDigestAt != nil && user.LastDigestAt.After(since) {
+		since = *user.LastDigestAt
+	}
+
+	var pending []models.Notification
+	if err := database.DB.Where("user_id = ? AND digest_pending = ?", user.ID, true).
+		Order("created_at ASC").Find(&pending).Error; err != nil {
+		return err
+	}
+
+	prefs := preferencesFor(user)
+
+	var lines []string
+	if user.DigestFrequency != models.DigestImmediate && prefs.Gamification {
+		var xp int64
+		database.DB.Model(&models.XPTransaction{}).
+			Where("user_id = ? AND created_at > ?", user.ID, since).
+			Select("COALESCE(SUM(amount), 0)").
+			Scan(&xp)
+		if xp > 0 {
+			lines = append(lines, fmt.Sprintf("You earned %d XP.", xp))
+		}
+	}
+
+	byType := make(map[models.NotificationType][]models.Notification)
+	for _, notification := range pending {
+		byType[notification.Type] = append(byType[notification.Type], notification)
+	}
+
+	// Only the latest leaderboard position is still news
+	if changes := byType[models.NotificationLeaderboard]; len(changes) > 0 {
+		lines = append(lines, changes[len(changes)-1].Message)
+	}
+	if drops := byType[models.NotificationPriceDrop]; len(drops) > 0 {
+		for i, drop := range drops {
+			if i == digestItemsPerType {
+				lines = append(lines, fmt.Sprintf("...and %d more price drops.", len(drops)-i))
+				break
+			}
+			lines = append(lines, drop.Message)
+		}
+	}
+
+	ids := make([]uuid.UUID, len(pending))
+	for i, notification := range pending {
+		ids[i] = notification.ID
+	}
+
+	digest := models.Notification{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		UserID:    user.ID,
+		Type:      models.NotificationDigest,
+		Title:     title,
+		Message:   strings.Join(lines, "\n"),
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if len(ids) > 0 {
+			if err := tx.Model(&models.Notification{}).Where("id IN ?", ids).
+				Update("digest_pending", false).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(user).Update("last_digest_at", now).Error; err != nil {
+			return err
+		}
+		if len(lines) == 0 {
+			return nil
+		}
+		return tx.Create(&digest).Error
+	})
+	if err != nil || len(lines) == 0 {
+		return err
+	}
+
+	deliver(prefs, &digest)
+	return nil
+}
@@ -0,0 +1,69 @@
+package notifications
+
+import (
+	"fmt"
+	"log"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+
+	"github.com/google/uuid"
+)
+
+// Send stores a notification for the user and dispatches it.
+func Send(userID uuid.UUID, notificationType models.NotificationType, title, message string) error {
+	return SendWithLink(userID, notificationType, title, message, "")
+}
+
+// SendWithLink is like Send but attaches a deep link for the app to open.
+// Types the user turned off are dropped, and non-urgent notifications are
+// held for the user's digest unless they chose immediate delivery.
+func SendWithLink(userID uuid.UUID, notificationType models.NotificationType, title, message, link string) error {
+	var user models.User
+	if err := database.DB.Select("id", "digest_frequency", "marketing_opt_out").First(&user, userID).Error; err != nil {
+		return fmt.Errorf("failed to load user: %w", err)
+	}
+	prefs := preferencesFor(&user)
+	if !Allows(prefs, notificationType) {
+		return nil
+	}
+
+	notification := models.Notification{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		UserID:    userID,
+		Type:      notificationType,
+		Title:     title,
+		Message:   message,
+		Link:      link,
+	}
+
+	if IsDigestible(notificationType) && user.DigestFrequency != models.DigestImmediate {
+		notification.DigestPending = true
+	}
+
+	if err := database.DB.Create(&notification).Error; err != nil {
+		return fmt.Errorf("failed to store notification: %w", err)
+	}
+	if notification.DigestPending {
+		return nil
+	}
+
+	deliver(prefs, &notification)
+	return nil
+}
+
+// SendSMS delivers a message to a phone number that may not belong to a user,
+// such as a gift recipient.
+func SendSMS(phone, message string) error {
+	// In production, deliver via SMS provider
+	log.Printf("SMS to %s: %s", phone, message)
+	return nil
+}
+
+// SendEmail delivers a message to an email address that may not be
+// confirmed yet, such as the new address in an email change.
+func SendEmail(address, subject, message string) error {
+	// In production, deliver via email provider
+	log.Printf("Email to %s: %s - %s", address, subject, message)
+	return nil
+}
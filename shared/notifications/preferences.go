@@ -0,0 +1,72 @@
+package notifications
+
+import (
+	"log"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+
+	"github.com/google/uuid"
+)
+
+// PreferencesFor returns the user's saved notification preferences, or the
+// defaults when they haven't saved any
+func PreferencesFor(userID uuid.UUID) models.NotificationPreferences {
+	var user models.User
+	database.DB.Select("id", "marketing_opt_out").First(&user, userID)
+	return preferencesFor(&user)
+}
+
+func preferencesFor(user *models.User) models.NotificationPreferences {
+	var prefs models.NotificationPreferences
+	if err := database.DB.Where("user_id = ?", user.ID).First(&prefs).Error; err == nil {
+		return prefs
+	}
+
+	// Everything on, except marketing for users who opted out before
+	// preferences existed
+	return models.NotificationPreferences{
+		UserID:       user.ID,
+		OrderUpdates: true,
+		Marketing:    !user.MarketingOptOut,
+		Gamification: true,
+		SMS:          true,
+		Email:        true,
+		Push:         true,
+	}
+}
+
+// Allows reports whether the user wants notifications of the type at all
+func Allows(prefs models.NotificationPreferences, notificationType models.NotificationType) bool {
+	switch notificationType {
+	case models.NotificationOrder, models.NotificationReview, models.NotificationRestock, models.NotificationInventory:
+		return prefs.OrderUpdates
+	case models.NotificationMarketing, models.NotificationPriceDrop:
+		return prefs.Marketing
+	case models.NotificationLeaderboard:
+		return prefs.Gamification
+	}
+	return true
+}
+
+// deliver pushes a stored notification on the channels the user enabled.
+// Security notices always go by SMS so a hijacked account can't silence
+// them.
+func deliver(prefs models.NotificationPreferences, notification *models.Notification) {
+	var channels []string
+	if prefs.Push {
+		channels = append(channels, "push")
+	}
+	if prefs.SMS || notification.Type == models.NotificationSecurity {
+		channels = append(channels, "sms")
+	}
+	if prefs.Email {
+		channels = append(channels, "email")
+	}
+
+	// In production, deliver via SMS/push/email providers
+	for _, channel := range channels {
+		log.Printf("Notification [%s] via %s to user %s: %s - %s",
+			notification.Type, channel, notification.UserID, notification.Title, notification.Message)
+	}
+}
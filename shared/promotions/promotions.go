@@ -0,0 +1,139 @@
+package promotions
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/redis"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrNotPayable = errors.New("promotion is not awaiting payment")
+
+// Live selects promotions that can be shown now: paid, inside their window
+// and with budget left for another click
+func Live(placement models.PromotionPlacement) func(db *gorm.DB) *gorm.DB {
+	now := time.Now()
+
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Model(&models.Promotion{}).
+			Where("promotions.placement = ? AND promotions.status = ?", placement, models.PromotionActive).
+			Where("promotions.starts_at <= ? AND promotions.ends_at > ?", now, now).
+			Where("promotions.spent + promotions.bid_per_click <= promotions.budget")
+	}
+}
+
+// Sponsored returns up to limit live promotions whose products match, highest
+// bid first, with their products loaded, and counts an impression for each.
+// Matching is applied to the products table.
+func Sponsored(placement models.PromotionPlacement, matching func(db *gorm.DB) *gorm.DB, limit int) []models.Promotion {
+	if limit <= 0 {
+		return nil
+	}
+
+	var candidates []models.Promotion
+	if err := database.DB.Scopes(Live(placement)).
+		Joins("JOIN products ON products.id = promotions.product_id AND products.deleted_at IS NULL AND products.is_active = ?", true).
+		Scopes(matching).
+		Order("promotions.bid_per_click DESC, RANDOM()").
+		Limit(limit * 3).
+		Find(&candidates).Error; err != nil {
+		log.Printf("Failed to load sponsored products: %v", err)
+		return nil
+	}
+
+	// A product promoted more than once takes one slot
+	shown := make(map[uuid.UUID]bool)
+	var sponsored []models.Promotion
+	for _, promotion := range candidates {
+		if len(sponsored) == limit {
+			break
+		}
+		if shown[promotion.ProductID] {
+			continue
+		}
+		shown[promotion.ProductID] = true
+		sponsored = append(sponsored, promotion)
+	}
+	if len(sponsored) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(sponsored))
+	productIDs := make([]uuid.UUID, len(sponsored))
+	for i, promotion := range sponsored {
+		ids[i] = promotion.ID
+		productIDs[i] = promotion.ProductID
+	}
+
+	var products []models.Product
+	database.DB.Preload("Seller.Profile").Where("id IN ?", productIDs).Find(&products)
+	byID := make(map[uuid.UUID]models.Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+	for i := range sponsored {
+		sponsored[i].Product = byID[sponsored[i].ProductID]
+	}
+
+	go func() {
+		if err := database.DB.Model(&models.Promotion{}).Where("id IN ?", ids).
+			Update("impressions", gorm.Expr("impressions + 1")).Error; err != nil {
+			log.Printf("Failed to count impressions for %d promotion(s): %v", len(ids), err)
+		}
+	}()
+
+	return sponsored
+}
+
+// Click counts a click on a sponsored product and charges the bid against
+// the budget. Repeat clicks by the same viewer within the click window are
+// counted as free. The promotion is marked exhausted once it can't afford
+// another click. Reports whether the click was charged.
+func Click(promotion *models.Promotion, viewer string) (bool, error) {
+	if !redis.AcquireLock(redis.PromotionClickKey(promotion.ID, viewer)) {
+		return false, nil
+	}
+
+	result := database.DB.Model(&models.Promotion{}).
+		Where("id = ? AND status = ? AND spent + bid_per_click <= budget", promotion.ID, models.PromotionActive).
+		Updates(map[string]interface{}{
+			"clicks": gorm.Expr("clicks + 1"),
+			"spent":  gorm.Expr("spent + bid_per_click"),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	if err := database.DB.Model(&models.Promotion{}).
+		Where("id = ? AND status = ? AND spent + bid_per_click > budget", promotion.ID, models.PromotionActive).
+		Update("status", models.PromotionExhausted).Error; err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// MarkPaid activates a promotion once its budget payment completes
+func MarkPaid(db *gorm.DB, promotionID uuid.UUID) error {
+	result := db.Model(&models.Promotion{}).
+		Where("id = ? AND status = ?", promotionID, models.PromotionPendingPayment).
+		Updates(map[string]interface{}{
+			"status":  models.PromotionActive,
+			"paid_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotPayable
+	}
+	return nil
+}
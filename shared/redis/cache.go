@@ -0,0 +1,320 @@
+package redis
+
+import (
+	"container/list"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"playful-marketplace/shared/config"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache stores JSON-encoded values under registered keys, each expiring
+// after its key's TTL
+type Cache interface {
+	Set(key Key, value interface{}) error
+	Get(key Key, dest interface{}) error
+	Delete(key Key) error
+	Exists(key Key) bool
+}
+
+// invalidationChannel carries the keys each instance writes or deletes so
+// the others drop their local copies
+const invalidationChannel = "cache:invalidate"
+
+// Keys held locally when CACHE_LOCAL_PREFIXES isn't set
+var defaultLocalPrefixes = []string{productPrefix, categoriesKey}
+
+// cache backs the package-level helpers. Connect swaps in the tiered cache
+// when the local tier is enabled.
+var cache Cache = remoteCache{}
+
+// Cache management. While Redis is unavailable the cache is a no-op:
+// writes are dropped, returning ErrUnavailable for callers that rely on
+// them, and every read misses. Deletes are replayed once it's back so
+// nothing changed during the outage is served stale.
+func Set(key Key, value interface{}) error {
+	if !Available() {
+		return ErrUnavailable
+	}
+	return cache.Set(key, value)
+}
+
+func Get(key Key, dest interface{}) error {
+	if !Available() {
+		return ErrUnavailable
+	}
+	return cache.Get(key, dest)
+}
+
+func Delete(key Key) error {
+	if !Available() {
+		rememberStaleKey(key.Name)
+		return nil
+	}
+	return cache.Delete(key)
+}
+
+// Take reads a key and deletes it in one step, for single-use values like
+// tickets that two requests must not both redeem. It goes straight to Redis.
+func Take(key Key, dest interface{}) error {
+	if !Available() {
+		return ErrUnavailable
+	}
+	data, err := Client.GetDel(ctx, key.Name).Bytes()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dest)
+}
+
+func Exists(key Key) bool {
+	if !Available() {
+		return false
+	}
+	return cache.Exists(key)
+}
+
+// GetMany reads several keys in one pipeline, decoding each hit into
+// dest(i), and reports which keys were found. It goes straight to Redis, so
+// it suits keys outside the local tier.
+func GetMany(keys []Key, dest func(i int) interface{}) []bool {
+	pipe := Client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Get(ctx, key.Name)
+	}
+	pipe.Exec(ctx)
+
+	found := make([]bool, len(keys))
+	for i, cmd := range cmds {
+		data, err := cmd.Bytes()
+		if err != nil {
+			continue
+		}
+		found[i] = json.Unmarshal(data, dest(i)) == nil
+	}
+	return found
+}
+
+// SetMany writes several keys in one pipeline
+func SetMany(keys []Key, values []interface{}) error {
+	pipe := Client.Pipeline()
+	for i, key := range keys {
+		data, err := json.Marshal(values[i])
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, key.Name, data, key.TTL)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// remoteCache reads and writes Redis directly
+type remoteCache struct{}
+
+func (remoteCache) Set(key Key, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return Client.Set(ctx, key.Name, data, key.TTL).Err()
+}
+
+func (remoteCache) Get(key Key, dest interface{}) error {
+	data, err := Client.Get(ctx, key.Name).Result()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(data), dest)
+}
+
+func (remoteCache) Delete(key Key) error {
+	return Client.Del(ctx, key.Name).Err()
+}
+
+func (remoteCache) Exists(key Key) bool {
+	count, _ := Client.Exists(ctx, key.Name).Result()
+	return count > 0
+}
+
+// tieredCache serves hot keys from an in-process LRU and everything else
+// from Redis. Writes go to Redis first and are announced on
+// invalidationChannel; a missed announcement (e.g. while the subscription
+// reconnects) leaves a stale copy for at most ttl.
+type tieredCache struct {
+	remoteCache
+	local    *lru
+	prefixes []string
+	ttl      time.Duration
+	instance string // Tags our own announcements so we don't evict what we just wrote
+}
+
+func newTieredCache(cfg config.RedisConfig) *tieredCache {
+	prefixes := cfg.LocalCachePrefixes
+	if len(prefixes) == 0 {
+		prefixes = defaultLocalPrefixes
+	}
+	return &tieredCache{
+		local:    newLRU(cfg.LocalCacheSize),
+		prefixes: prefixes,
+		ttl:      cfg.LocalCacheTTL,
+		instance: uuid.New().String(),
+	}
+}
+
+func (c *tieredCache) Set(key Key, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if err := Client.Set(ctx, key.Name, data, key.TTL).Err(); err != nil {
+		return err
+	}
+	if c.hot(key) {
+		c.invalidate(key)
+		ttl := c.ttl
+		if key.TTL > 0 && key.TTL < ttl {
+			ttl = key.TTL
+		}
+		c.local.set(key.Name, data, ttl)
+	}
+	return nil
+}
+
+func (c *tieredCache) Get(key Key, dest interface{}) error {
+	if !c.hot(key) {
+		return c.remoteCache.Get(key, dest)
+	}
+	if data, ok := c.local.get(key.Name); ok {
+		return json.Unmarshal(data, dest)
+	}
+
+	data, err := Client.Get(ctx, key.Name).Bytes()
+	if err != nil {
+		return err
+	}
+	ttl := c.ttl
+	if remaining, err := Client.PTTL(ctx, key.Name).Result(); err == nil && remaining > 0 && remaining < ttl {
+		ttl = remaining
+	}
+	c.local.set(key.Name, data, ttl)
+	return json.Unmarshal(data, dest)
+}
+
+func (c *tieredCache) Delete(key Key) error {
+	err := Client.Del(ctx, key.Name).Err()
+	if c.hot(key) {
+		c.local.delete(key.Name)
+		c.invalidate(key)
+	}
+	return err
+}
+
+func (c *tieredCache) Exists(key Key) bool {
+	if c.hot(key) {
+		if _, ok := c.local.get(key.Name); ok {
+			return true
+		}
+	}
+	return c.remoteCache.Exists(key)
+}
+
+func (c *tieredCache) hot(key Key) bool {
+	for _, prefix := range c.prefixes {
+		if strings.HasPrefix(key.Name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidate tells the other instances to drop their copy of key
+func (c *tieredCache) invalidate(key Key) {
+	Client.Publish(ctx, invalidationChannel, c.instance+"|"+key.Name)
+}
+
+// listen evicts keys announced by other instances. The client resubscribes
+// on its own after a dropped connection.
+func (c *tieredCache) listen() {
+	pubsub := Client.Subscribe(ctx, invalidationChannel)
+	for msg := range pubsub.Channel(redis.WithChannelSize(1000)) {
+		instance, key, ok := strings.Cut(msg.Payload, "|")
+		if !ok || instance == c.instance {
+			continue
+		}
+		c.local.delete(key)
+	}
+}
+
+type lruEntry struct {
+	key       string
+	data      []byte
+	expiresAt time.Time
+}
+
+// lru is a fixed-size, least recently used map of encoded values
+type lru struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // Front is the most recently used
+	items map[string]*list.Element
+}
+
+func newLRU(size int) *lru {
+	if size <= 0 {
+		size = 1000
+	}
+	return &lru{size: size, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (l *lru) get(key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		l.order.Remove(elem)
+		delete(l.items, key)
+		return nil, false
+	}
+	l.order.MoveToFront(elem)
+	return entry.data, true
+}
+
+func (l *lru) set(key string, data []byte, ttl time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := &lruEntry{key: key, data: data, expiresAt: time.Now().Add(ttl)}
+	if elem, ok := l.items[key]; ok {
+		elem.Value = entry
+		l.order.MoveToFront(elem)
+		return
+	}
+	l.items[key] = l.order.PushFront(entry)
+	for l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (l *lru) delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.items[key]; ok {
+		l.order.Remove(elem)
+		delete(l.items, key)
+	}
+}
@@ -0,0 +1,143 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrUnavailable is returned straight away for commands issued while Redis
+// is down, rather than after the client's timeouts and retries
+var ErrUnavailable = errors.New("redis is unavailable")
+
+// Deletes remembered during an outage at most, to replay on recovery
+const maxStaleKeys = 10000
+
+var (
+	available atomic.Bool
+
+	staleMu   sync.Mutex
+	staleKeys = make(map[string]struct{})
+)
+
+type probeKey struct{}
+
+// Available reports whether Redis is reachable. While it isn't, services
+// degrade instead of failing requests: auth validates JWTs on their own,
+// caching is a no-op and leaderboards are read from the database.
+func Available() bool {
+	return available.Load()
+}
+
+// setAvailable records a change in Redis's reachability, alerting on each
+// transition
+func setAvailable(up bool, cause error) {
+	if available.Swap(up) == up {
+		return
+	}
+	if up {
+		log.Printf("ALERT: Redis is reachable again, leaving degraded mode")
+		go replayStaleKeys()
+		return
+	}
+	log.Printf("ALERT: Redis is unavailable (%v), running in degraded mode: JWT-only auth, no caching, leaderboards from the database", cause)
+}
+
+// monitor probes Redis on an interval to notice outages no request has hit
+// yet, and recoveries, which nothing else would try while degraded
+func monitor(interval time.Duration) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		probe, cancel := context.WithTimeout(context.WithValue(ctx, probeKey{}, true), interval)
+		err := Client.Ping(probe).Err()
+		cancel()
+		setAvailable(err == nil, err)
+	}
+}
+
+// degradationHook fails commands fast while Redis is down and notices an
+// outage from the first command to lose its connection
+type degradationHook struct{}
+
+func (degradationHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (degradationHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if !Available() && ctx.Value(probeKey{}) == nil {
+			cmd.SetErr(ErrUnavailable)
+			return ErrUnavailable
+		}
+		err := next(ctx, cmd)
+		if connectionLost(err) {
+			setAvailable(false, err)
+		}
+		return err
+	}
+}
+
+func (degradationHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if !Available() {
+			for _, cmd := range cmds {
+				cmd.SetErr(ErrUnavailable)
+			}
+			return ErrUnavailable
+		}
+		err := next(ctx, cmds)
+		if connectionLost(err) {
+			setAvailable(false, err)
+		}
+		return err
+	}
+}
+
+// connectionLost tells network failures apart from errors Redis answered
+// with, such as a missing key
+func connectionLost(err error) bool {
+	if err == nil || errors.Is(err, redis.Nil) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// rememberStaleKey records a cache delete that couldn't reach Redis, so the
+// stale value doesn't outlive the outage
+func rememberStaleKey(name string) {
+	staleMu.Lock()
+	defer staleMu.Unlock()
+	if len(staleKeys) < maxStaleKeys {
+		staleKeys[name] = struct{}{}
+	}
+}
+
+func replayStaleKeys() {
+	staleMu.Lock()
+	names := make([]string, 0, len(staleKeys))
+	for name := range staleKeys {
+		names = append(names, name)
+	}
+	staleKeys = make(map[string]struct{})
+	staleMu.Unlock()
+
+	for _, name := range names {
+		cache.Delete(Key{Name: name})
+	}
+	if len(names) > 0 {
+		log.Printf("Deleted %d cache keys changed while Redis was unavailable", len(names))
+	}
+}
@@ -0,0 +1,170 @@
+package redis
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Key names a value stored in Redis along with how long it lives, so the
+// lifetime is set once here rather than at every call site
+type Key struct {
+	Name string
+	TTL  time.Duration // 0 keeps the value until it's deleted
+}
+
+// Lifetimes of cached values
+const (
+	ProductTTL          = 5 * time.Minute
+	CategoriesTTL       = time.Hour
+	OTPTTL              = 5 * time.Minute
+	PhoneChangeTTL      = 10 * time.Minute
+	AccountRecoveryTTL  = 30 * time.Minute
+	PromotionClickTTL   = 30 * time.Minute
+	EmailChangeTTL      = 24 * time.Hour
+	PaymentSessionTTL   = 30 * time.Minute
+	TokensValidAfterTTL = time.Hour
+	TOTPUsedTTL         = 2 * time.Minute // Covers the drift window a code is accepted in
+	UserSummaryTTL      = 5 * time.Minute
+	TrendingSearchesTTL = 10 * time.Minute
+	APIUsageTTL         = 31 * 24 * time.Hour
+	BuyerAnalyticsTTL   = 30 * time.Minute
+	LiveTicketTTL       = 30 * time.Second
+)
+
+const (
+	productPrefix = "product:"
+	categoriesKey = "product_categories"
+)
+
+// ProductKey holds a product's detail payload
+func ProductKey(productID uuid.UUID) Key {
+	return Key{Name: productPrefix + productID.String(), TTL: ProductTTL}
+}
+
+// CategoriesKey holds the list of active product categories
+func CategoriesKey() Key {
+	return Key{Name: categoriesKey, TTL: CategoriesTTL}
+}
+
+// TrendingSearchesKey holds the most searched queries over the given window
+func TrendingSearchesKey(days, limit int) Key {
+	return Key{Name: fmt.Sprintf("trending_searches:%d:%d", days, limit), TTL: TrendingSearchesTTL}
+}
+
+// UserSummaryKey holds the public profile shown next to a user on leaderboards
+func UserSummaryKey(userID uuid.UUID) Key {
+	return Key{Name: fmt.Sprintf("user_summary:%s", userID), TTL: UserSummaryTTL}
+}
+
+// BuyerAnalyticsKey holds a buyer's spending analytics for a calendar year
+func BuyerAnalyticsKey(userID uuid.UUID, year int) Key {
+	return Key{Name: fmt.Sprintf("buyer_analytics:%s:%d", userID, year), TTL: BuyerAnalyticsTTL}
+}
+
+// OTPKey holds the login code last sent to a phone number
+func OTPKey(phone string) Key {
+	return Key{Name: fmt.Sprintf("otp:%s", phone), TTL: OTPTTL}
+}
+
+// PhoneChangeKey holds a user's pending phone number change
+func PhoneChangeKey(userID uuid.UUID) Key {
+	return Key{Name: fmt.Sprintf("phone_change:%s", userID), TTL: PhoneChangeTTL}
+}
+
+// PaymentPhoneKey holds the code sent to verify a saved payment phone
+func PaymentPhoneKey(paymentPhoneID uuid.UUID) Key {
+	return Key{Name: fmt.Sprintf("payment_phone:%s", paymentPhoneID), TTL: PhoneChangeTTL}
+}
+
+// PromotionClickKey marks a viewer's click on a sponsored product, so
+// repeat clicks within the window aren't charged again
+func PromotionClickKey(promotionID uuid.UUID, viewer string) Key {
+	return Key{Name: fmt.Sprintf("promotion_click:%s:%s", promotionID, viewer), TTL: PromotionClickTTL}
+}
+
+// AccountRecoveryKey holds the codes sent to verify an account recovery
+func AccountRecoveryKey(recoveryID uuid.UUID) Key {
+	return Key{Name: fmt.Sprintf("account_recovery:%s", recoveryID), TTL: AccountRecoveryTTL}
+}
+
+// EmailChangeKey holds a user's pending email address change
+func EmailChangeKey(userID uuid.UUID) Key {
+	return Key{Name: fmt.Sprintf("email_change:%s", userID), TTL: EmailChangeTTL}
+}
+
+// PaymentSessionKey holds an initiated payment until it completes or fails
+func PaymentSessionKey(transactionID string) Key {
+	return Key{Name: fmt.Sprintf("payment_session:%s", transactionID), TTL: PaymentSessionTTL}
+}
+
+// TOTPUsedKey marks a two-factor code as spent
+func TOTPUsedKey(userID uuid.UUID, step int64) Key {
+	return Key{Name: fmt.Sprintf("totp_used:%s:%d", userID, step), TTL: TOTPUsedTTL}
+}
+
+// LiveTicketKey holds the seller and session a one-time live dashboard
+// ticket was issued to
+func LiveTicketKey(ticket string) Key {
+	return Key{Name: "live_ticket:" + ticket, TTL: LiveTicketTTL}
+}
+
+// SchedulerKey guards a scheduled job so only one instance runs each tick
+func SchedulerKey(job string, interval time.Duration) Key {
+	return Key{Name: "scheduler:" + job, TTL: interval}
+}
+
+// Keys whose lifetime follows the session or sanction they belong to
+
+func maintenanceKey(service string) string {
+	return "maintenance:" + service
+}
+
+func sessionKey(token string) string {
+	return fmt.Sprintf("session:%s", token)
+}
+
+func userSessionsKey(userID string) string {
+	return fmt.Sprintf("user_sessions:%s", userID)
+}
+
+func staffSessionsKey(staffID string) string {
+	return fmt.Sprintf("staff_sessions:%s", staffID)
+}
+
+func suspendedKey(userID string) string {
+	return fmt.Sprintf("suspended:%s", userID)
+}
+
+func tokensValidAfterKey(userID string) Key {
+	return Key{Name: fmt.Sprintf("tokens_valid_after:%s", userID), TTL: TokensValidAfterTTL}
+}
+
+func apiUsageKey(userID, day string) Key {
+	return Key{Name: fmt.Sprintf("api_usage:%s:%s", userID, day), TTL: APIUsageTTL}
+}
+
+func apiLastSeenKey(userID string) Key {
+	return Key{Name: fmt.Sprintf("api_last_seen:%s", userID), TTL: APIUsageTTL}
+}
+
+func rateLimitKey(subject string, windowStart time.Time, window time.Duration) Key {
+	return Key{Name: fmt.Sprintf("rate_limit:%s:%d", subject, windowStart.Unix()), TTL: window}
+}
+
+func leaderboardKey(leaderboardType string) string {
+	return fmt.Sprintf("leaderboard:%s", leaderboardType)
+}
+
+func leaderboardOffsetsKey(leaderboardType string) string {
+	return fmt.Sprintf("leaderboard_offsets:%s", leaderboardType)
+}
+
+func leaderboardExcludedKey(leaderboardType string) string {
+	return fmt.Sprintf("leaderboard_excluded:%s", leaderboardType)
+}
+
+func lockKey(key Key) string {
+	return fmt.Sprintf("lock:%s", key.Name)
+}
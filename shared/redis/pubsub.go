@@ -0,0 +1,22 @@
+package redis
+
+import (
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+func sellerEventsChannel(sellerID uuid.UUID) string {
+	return "seller_events:" + sellerID.String()
+}
+
+// PublishSellerEvent sends an encoded event to every instance holding a live
+// connection for the seller. Sellers with no one connected miss it.
+func PublishSellerEvent(sellerID uuid.UUID, payload []byte) error {
+	return Client.Publish(ctx, sellerEventsChannel(sellerID), payload).Err()
+}
+
+// SubscribeSellerEvents receives the events published for the seller until
+// the subscription is closed
+func SubscribeSellerEvents(sellerID uuid.UUID) *redis.PubSub {
+	return Client.Subscribe(ctx, sellerEventsChannel(sellerID))
+}
@@ -2,36 +2,113 @@ package redis
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 	"time"
 
 	"playful-marketplace/shared/config"
 	"playful-marketplace/shared/models"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
-var Client *redis.Client
+// Client works the same against a single node, a Sentinel-managed
+// primary or a cluster
+var Client redis.UniversalClient
 var ctx = context.Background()
 
 func Connect(cfg *config.Config) error {
-	Client = redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%s", cfg.Redis.Host, cfg.Redis.Port),
-		Password: cfg.Redis.Password,
-		DB:       cfg.Redis.DB,
-	})
+	client, err := newClient(cfg.Redis)
+	if err != nil {
+		return err
+	}
+	Client = client
+	Client.AddHook(degradationHook{})
+	available.Store(true)
 
-	// Test connection
-	_, err := Client.Ping(ctx).Result()
+	// Test connection. In degraded mode the service starts anyway and
+	// picks Redis up once it's reachable.
+	_, err = Client.Ping(ctx).Result()
 	if err != nil {
-		return fmt.Errorf("failed to connect to Redis: %w", err)
+		if !cfg.Redis.Degrade {
+			return fmt.Errorf("failed to connect to Redis: %w", err)
+		}
+		setAvailable(false, err)
 	}
+	go monitor(cfg.Redis.HealthCheckInterval)
 
-	fmt.Println("Redis connected successfully")
+	if cfg.Redis.LocalCache {
+		local := newTieredCache(cfg.Redis)
+		go local.listen()
+		cache = local
+	}
+
+	if Available() {
+		fmt.Printf("Redis connected successfully (%s)\n", cfg.Redis.Mode)
+	}
 	return nil
 }
 
+// newClient builds the client for the configured topology. Commands that
+// fail on a dropped connection or a failover in progress are retried with
+// backoff, and the Sentinel client follows the primary when it moves.
+func newClient(cfg config.RedisConfig) (redis.UniversalClient, error) {
+	opts := &redis.UniversalOptions{
+		Addrs:            cfg.Addrs,
+		DB:               cfg.DB,
+		Username:         cfg.Username,
+		Password:         cfg.Password,
+		SentinelPassword: cfg.SentinelPassword,
+		MasterName:       cfg.MasterName,
+		MaxRetries:       cfg.MaxRetries,
+		MaxRetryBackoff:  cfg.MaxRetryBackoff,
+		DialTimeout:      cfg.DialTimeout,
+		ReadTimeout:      cfg.ReadTimeout,
+		WriteTimeout:     cfg.WriteTimeout,
+		PoolSize:         cfg.PoolSize,
+		MinIdleConns:     cfg.MinIdleConns,
+	}
+	if cfg.TLS {
+		opts.TLSConfig = &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: cfg.TLSSkipVerify,
+		}
+	}
+
+	switch cfg.Mode {
+	case "", "standalone":
+		opts.Addrs = []string{fmt.Sprintf("%s:%s", cfg.Host, cfg.Port)}
+		return redis.NewClient(opts.Simple()), nil
+	case "sentinel":
+		if cfg.MasterName == "" || len(cfg.Addrs) == 0 {
+			return nil, fmt.Errorf("redis sentinel mode needs REDIS_MASTER_NAME and REDIS_ADDRS")
+		}
+		return redis.NewFailoverClient(opts.Failover()), nil
+	case "cluster":
+		if len(cfg.Addrs) == 0 {
+			return nil, fmt.Errorf("redis cluster mode needs REDIS_ADDRS")
+		}
+		return redis.NewClusterClient(opts.Cluster()), nil
+	}
+	return nil, fmt.Errorf("unknown redis mode %q", cfg.Mode)
+}
+
+// deleteKeys deletes keys with one command each, since in a cluster they
+// can live in different slots. The pipeline still makes one round trip
+// per node.
+func deleteKeys(keys []string) error {
+	pipe := Client.Pipeline()
+	for _, key := range keys {
+		pipe.Del(ctx, key)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
 // Session management
 func SetSession(session *models.Session) error {
 	sessionData, err := json.Marshal(session)
@@ -39,14 +116,23 @@ func SetSession(session *models.Session) error {
 		return err
 	}
 
-	key := fmt.Sprintf("session:%s", session.Token)
+	key := sessionKey(session.Token)
 	duration := time.Until(session.ExpiresAt)
 	
-	return Client.Set(ctx, key, sessionData, duration).Err()
+	if err := Client.Set(ctx, key, sessionData, duration).Err(); err != nil {
+		return err
+	}
+
+	// Track the user's tokens so all sessions can be revoked at once
+	userKey := userSessionsKey(session.UserID.String())
+	Client.SAdd(ctx, userKey, session.Token)
+	Client.Expire(ctx, userKey, duration)
+
+	return nil
 }
 
 func GetSession(token string) (*models.Session, error) {
-	key := fmt.Sprintf("session:%s", token)
+	key := sessionKey(token)
 	sessionData, err := Client.Get(ctx, key).Result()
 	if err != nil {
 		return nil, err
@@ -58,96 +144,343 @@ func GetSession(token string) (*models.Session, error) {
 }
 
 func DeleteSession(token string) error {
-	key := fmt.Sprintf("session:%s", token)
+	key := sessionKey(token)
 	return Client.Del(ctx, key).Err()
 }
 
-// Leaderboard management
-func SetLeaderboardEntry(leaderboardType string, userID string, score float64, userData map[string]interface{}) error {
-	// Add to sorted set for ranking
-	err := Client.ZAdd(ctx, fmt.Sprintf("leaderboard:%s", leaderboardType), redis.Z{
-		Score:  score,
-		Member: userID,
-	}).Err()
+// DeleteUserSessions revokes every session belonging to a user
+func DeleteUserSessions(userID string) error {
+	userKey := userSessionsKey(userID)
+	tokens, err := Client.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(tokens)+1)
+	for _, token := range tokens {
+		keys = append(keys, sessionKey(token))
+	}
+	keys = append(keys, userKey)
+
+	return deleteKeys(keys)
+}
+
+// GetUserSessions returns the user's live sessions, oldest first, pruning
+// logged out and expired tokens from the index
+func GetUserSessions(userID string) ([]*models.Session, error) {
+	userKey := userSessionsKey(userID)
+	tokens, err := Client.SMembers(ctx, userKey).Result()
 	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*models.Session, 0, len(tokens))
+	for _, token := range tokens {
+		session, err := GetSession(token)
+		if err != nil {
+			Client.SRem(ctx, userKey, token)
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+// TrackStaffSession records a store token issued to a staff member so it
+// can be revoked when their access changes
+func TrackStaffSession(staffID, token string, ttl time.Duration) error {
+	key := staffSessionsKey(staffID)
+	if err := Client.SAdd(ctx, key, token).Err(); err != nil {
 		return err
 	}
+	return Client.Expire(ctx, key, ttl).Err()
+}
 
-	// Store user data
-	userDataJSON, err := json.Marshal(userData)
+// DeleteStaffSessions revokes every store token issued to a staff member
+func DeleteStaffSessions(staffID string) error {
+	key := staffSessionsKey(staffID)
+	tokens, err := Client.SMembers(ctx, key).Result()
 	if err != nil {
 		return err
 	}
 
-	return Client.HSet(ctx, fmt.Sprintf("leaderboard:%s:users", leaderboardType), userID, userDataJSON).Err()
+	keys := make([]string, 0, len(tokens)+1)
+	for _, token := range tokens {
+		keys = append(keys, sessionKey(token))
+	}
+	keys = append(keys, key)
+
+	return deleteKeys(keys)
+}
+
+// MarkUserSuspended blocks the user's tokens until ttl passes, or
+// indefinitely when ttl is 0
+func MarkUserSuspended(userID string, ttl time.Duration) error {
+	return Client.Set(ctx, suspendedKey(userID), "1", ttl).Err()
+}
+
+func ClearUserSuspended(userID string) error {
+	return Client.Del(ctx, suspendedKey(userID)).Err()
+}
+
+func IsUserSuspended(userID string) bool {
+	count, _ := Client.Exists(ctx, suspendedKey(userID)).Result()
+	return count > 0
+}
+
+// SetTokensValidAfter caches when the user's tokens were last revoked, as a
+// unix timestamp, or 0 when they never were
+func SetTokensValidAfter(userID string, unix int64) error {
+	key := tokensValidAfterKey(userID)
+	return Client.Set(ctx, key.Name, unix, key.TTL).Err()
+}
+
+// GetTokensValidAfter returns the cached revocation timestamp
+func GetTokensValidAfter(userID string) (int64, error) {
+	return Client.Get(ctx, tokensValidAfterKey(userID).Name).Int64()
+}
+
+// Maintenance mode
+
+// SetMaintenance pauses writes to the window's service until ttl passes, or
+// until it's cleared when ttl is 0
+func SetMaintenance(window models.MaintenanceWindow, ttl time.Duration) error {
+	data, err := json.Marshal(window)
+	if err != nil {
+		return err
+	}
+	return Client.Set(ctx, maintenanceKey(window.Service), data, ttl).Err()
+}
+
+// GetMaintenance returns the active maintenance window for the service, or
+// nil when there is none
+func GetMaintenance(service string) (*models.MaintenanceWindow, error) {
+	data, err := Client.Get(ctx, maintenanceKey(service)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var window models.MaintenanceWindow
+	if err := json.Unmarshal([]byte(data), &window); err != nil {
+		return nil, err
+	}
+	return &window, nil
+}
+
+func ClearMaintenance(service string) error {
+	return Client.Del(ctx, maintenanceKey(service)).Err()
+}
+
+// API usage
+
+// Hash field holding the day's total alongside the per-endpoint counts
+const apiUsageTotalField = "_total"
+
+// RecordAPIUsage counts a call to the endpoint in the user's usage for the
+// day and remembers it as the last endpoint they called
+func RecordAPIUsage(userID, endpoint string, at time.Time) error {
+	day := apiUsageKey(userID, at.UTC().Format("2006-01-02"))
+	last := apiLastSeenKey(userID)
+
+	pipe := Client.Pipeline()
+	pipe.HIncrBy(ctx, day.Name, apiUsageTotalField, 1)
+	pipe.HIncrBy(ctx, day.Name, endpoint, 1)
+	pipe.Expire(ctx, day.Name, day.TTL)
+	pipe.HSet(ctx, last.Name, "endpoint", endpoint, "at", at.Unix())
+	pipe.Expire(ctx, last.Name, last.TTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetAPIUsage returns the user's usage for each of the last days, oldest
+// first, and the last endpoint they called, which is nil when unknown
+func GetAPIUsage(userID string, days int) ([]models.APIUsageDay, *models.APILastSeen, error) {
+	today := time.Now().UTC()
+	pipe := Client.Pipeline()
+	dates := make([]string, days)
+	results := make([]*redis.MapStringStringCmd, days)
+	for i := range dates {
+		dates[i] = today.AddDate(0, 0, i-days+1).Format("2006-01-02")
+		results[i] = pipe.HGetAll(ctx, apiUsageKey(userID, dates[i]).Name)
+	}
+	lastSeen := pipe.HGetAll(ctx, apiLastSeenKey(userID).Name)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, nil, err
+	}
+
+	usage := make([]models.APIUsageDay, days)
+	for i, result := range results {
+		usage[i] = models.APIUsageDay{Date: dates[i], Endpoints: make(map[string]int64)}
+		for field, value := range result.Val() {
+			count, _ := strconv.ParseInt(value, 10, 64)
+			if field == apiUsageTotalField {
+				usage[i].Calls = count
+			} else {
+				usage[i].Endpoints[field] = count
+			}
+		}
+	}
+
+	var last *models.APILastSeen
+	if fields := lastSeen.Val(); fields["endpoint"] != "" {
+		unix, _ := strconv.ParseInt(fields["at"], 10, 64)
+		last = &models.APILastSeen{Endpoint: fields["endpoint"], At: time.Unix(unix, 0)}
+	}
+
+	return usage, last, nil
+}
+
+// Leaderboard management
+
+// SetLeaderboardEntry records the user's score. Only IDs are ranked here;
+// names and levels are looked up when the leaderboard is read.
+func SetLeaderboardEntry(leaderboardType string, userID string, score float64) error {
+	return Client.ZAdd(ctx, leaderboardKey(leaderboardType), redis.Z{
+		Score:  score,
+		Member: userID,
+	}).Err()
+}
+
+// GetLeaderboardRank returns the user's 1-based position on the
+// leaderboard, or 0 when they aren't on it
+func GetLeaderboardRank(leaderboardType string, userID string) int {
+	rank, err := Client.ZRevRank(ctx, leaderboardKey(leaderboardType), userID).Result()
+	if err != nil {
+		return 0
+	}
+	return int(rank) + 1
 }
 
+// GetLeaderboard returns the top entries with their user ID, rank and score
 func GetLeaderboard(leaderboardType string, limit int) ([]models.LeaderboardEntry, error) {
 	// Get top users from sorted set (descending order)
-	members, err := Client.ZRevRangeWithScores(ctx, fmt.Sprintf("leaderboard:%s", leaderboardType), 0, int64(limit-1)).Result()
+	members, err := Client.ZRevRangeWithScores(ctx, leaderboardKey(leaderboardType), 0, int64(limit-1)).Result()
 	if err != nil {
 		return nil, err
 	}
 
-	var entries []models.LeaderboardEntry
+	entries := make([]models.LeaderboardEntry, 0, len(members))
 	for i, member := range members {
-		userID := member.Member.(string)
-		
-		// Get user data
-		userDataJSON, err := Client.HGet(ctx, fmt.Sprintf("leaderboard:%s:users", leaderboardType), userID).Result()
+		userID, err := uuid.Parse(member.Member)
 		if err != nil {
 			continue
 		}
+		entries = append(entries, models.LeaderboardEntry{
+			UserID: userID,
+			Score:  member.Score,
+			Rank:   i + 1,
+		})
+	}
 
-		var userData map[string]interface{}
-		if err := json.Unmarshal([]byte(userDataJSON), &userData); err != nil {
-			continue
-		}
+	return entries, nil
+}
 
-		entry := models.LeaderboardEntry{
-			Rank:  i + 1,
-			Score: member.Score,
-		}
+// GetLeaderboardPage returns entries from the offset onwards with the
+// number of entries on the leaderboard, for admins paging through it
+func GetLeaderboardPage(leaderboardType string, offset, limit int) ([]models.LeaderboardEntry, int64, error) {
+	key := leaderboardKey(leaderboardType)
+	total, err := Client.ZCard(ctx, key).Result()
+	if err != nil {
+		return nil, 0, err
+	}
 
-		// Parse user data
-		if name, ok := userData["name"].(string); ok {
-			entry.Name = name
-		}
-		if level, ok := userData["level"].(string); ok {
-			entry.Level = models.UserLevel(level)
-		}
-		if badgeCount, ok := userData["badge_count"].(float64); ok {
-			entry.BadgeCount = int(badgeCount)
-		}
+	members, err := Client.ZRevRangeWithScores(ctx, key, int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, 0, err
+	}
 
-		entries = append(entries, entry)
+	entries := make([]models.LeaderboardEntry, 0, len(members))
+	for i, member := range members {
+		userID, err := uuid.Parse(member.Member)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, models.LeaderboardEntry{
+			UserID: userID,
+			Score:  member.Score,
+			Rank:   offset + i + 1,
+		})
 	}
 
-	return entries, nil
+	return entries, total, nil
 }
 
-// Cache management
-func Set(key string, value interface{}, expiration time.Duration) error {
-	data, err := json.Marshal(value)
+// GetLeaderboardScore returns the user's score, and false when they aren't
+// on the leaderboard
+func GetLeaderboardScore(leaderboardType string, userID string) (float64, bool) {
+	score, err := Client.ZScore(ctx, leaderboardKey(leaderboardType), userID).Result()
 	if err != nil {
-		return err
+		return 0, false
 	}
-	return Client.Set(ctx, key, data, expiration).Err()
+	return score, true
 }
 
-func Get(key string, dest interface{}) error {
-	data, err := Client.Get(ctx, key).Result()
+// AdjustLeaderboardEntry shifts the user's score by delta, now and on every
+// later update, and returns the new score
+func AdjustLeaderboardEntry(leaderboardType string, userID string, delta float64) (float64, error) {
+	if err := Client.HIncrByFloat(ctx, leaderboardOffsetsKey(leaderboardType), userID, delta).Err(); err != nil {
+		return 0, err
+	}
+	return Client.ZIncrBy(ctx, leaderboardKey(leaderboardType), delta, userID).Result()
+}
+
+// GetLeaderboardOffset returns the admin adjustment applied to the user's score
+func GetLeaderboardOffset(leaderboardType string, userID string) float64 {
+	offset, err := Client.HGet(ctx, leaderboardOffsetsKey(leaderboardType), userID).Float64()
 	if err != nil {
+		return 0
+	}
+	return offset
+}
+
+// ExcludeLeaderboardEntry takes the user off the leaderboard and keeps
+// later updates from putting them back
+func ExcludeLeaderboardEntry(leaderboardType string, userID string) error {
+	if err := Client.SAdd(ctx, leaderboardExcludedKey(leaderboardType), userID).Err(); err != nil {
 		return err
 	}
-	return json.Unmarshal([]byte(data), dest)
+	return Client.ZRem(ctx, leaderboardKey(leaderboardType), userID).Err()
 }
 
-func Delete(key string) error {
-	return Client.Del(ctx, key).Err()
+// IncludeLeaderboardEntry lets an excluded user back on the leaderboard at
+// their next update
+func IncludeLeaderboardEntry(leaderboardType string, userID string) error {
+	return Client.SRem(ctx, leaderboardExcludedKey(leaderboardType), userID).Err()
 }
 
-func Exists(key string) bool {
-	count, _ := Client.Exists(ctx, key).Result()
-	return count > 0
+// IsLeaderboardExcluded reports whether an admin took the user off the leaderboard
+func IsLeaderboardExcluded(leaderboardType string, userID string) bool {
+	excluded, err := Client.SIsMember(ctx, leaderboardExcludedKey(leaderboardType), userID).Result()
+	return err == nil && excluded
+}
+
+// Rate limiting
+
+// CountRequest adds a request to the subject's count for the fixed window
+// starting at windowStart and returns the count so far
+func CountRequest(subject string, windowStart time.Time, window time.Duration) (int64, error) {
+	key := rateLimitKey(subject, windowStart, window)
+
+	pipe := Client.TxPipeline()
+	count := pipe.Incr(ctx, key.Name)
+	pipe.Expire(ctx, key.Name, key.TTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return count.Val(), nil
+}
+
+// Distributed locks
+
+// AcquireLock takes the lock named by key until its TTL passes
+func AcquireLock(key Key) bool {
+	ok, err := Client.SetNX(ctx, lockKey(key), time.Now().Unix(), key.TTL).Result()
+	return err == nil && ok
 }
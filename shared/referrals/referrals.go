@@ -0,0 +1,199 @@
+package referrals
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/config"
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/ledger"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/notifications"
+	"playful-marketplace/shared/stores"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidCode = errors.New("referral code is not valid")
+
+// SellerCode returns the seller's referral code, generating one the first
+// time it's asked for.
+func SellerCode(user *models.User) (string, error) {
+	if user.ReferralCode != nil {
+		return *user.ReferralCode, nil
+	}
+
+	raw := make([]byte, 4)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	code := "SR-" + strings.ToUpper(hex.EncodeToString(raw))
+
+	// Another request may have generated one first
+	result := database.DB.Model(user).Where("referral_code IS NULL").Update("referral_code", code)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		if err := database.DB.Select("referral_code").First(user, user.ID).Error; err != nil || user.ReferralCode == nil {
+			return "", fmt.Errorf("failed to load referral code: %w", err)
+		}
+		return *user.ReferralCode, nil
+	}
+
+	user.ReferralCode = &code
+	return code, nil
+}
+
+// ResolveCode finds the active seller who owns a referral code.
+func ResolveCode(code string) (*models.User, error) {
+	var referrer models.User
+	if err := database.DB.Where("referral_code = ? AND ? = ANY(roles) AND is_active = ?",
+		strings.ToUpper(strings.TrimSpace(code)), models.RoleSeller, true).
+		First(&referrer).Error; err != nil {
+		return nil, ErrInvalidCode
+	}
+	return &referrer, nil
+}
+
+// Create records that referredID joined as a seller through referrerID.
+// A seller can only be referred once.
+func Create(db *gorm.DB, referrerID, referredID uuid.UUID) error {
+	if referrerID == referredID {
+		return nil
+	}
+
+	referral := models.SellerReferral{
+		BaseModel:  models.BaseModel{ID: uuid.New()},
+		ReferrerID: referrerID,
+		ReferredID: referredID,
+		Status:     models.ReferralPending,
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "referred_id"}},
+		DoNothing: true,
+	}).Create(&referral).Error
+}
+
+// RecordDeliveredSale updates a referred seller's progress after one of their
+// orders is delivered and rewards the referrer once they qualify. Progress
+// is recounted from delivered orders, so repeated calls for the same order
+// are harmless.
+func RecordDeliveredSale(cfg *config.Config, sellerID, orderID uuid.UUID) error {
+	var referral models.SellerReferral
+	if err := database.DB.Where("referred_id = ? AND status = ?", sellerID, models.ReferralPending).
+		First(&referral).Error; err != nil {
+		return nil
+	}
+
+	qualifying := cfg.Referrals.SellerQualifyingSales
+	if qualifying < 1 {
+		qualifying = 1
+	}
+
+	// The referred seller's first delivered orders since joining
+	var sales []struct {
+		OrderID uuid.UUID
+		Amount  float64
+	}
+	if err := database.DB.Model(&models.OrderItem{}).
+		Select("order_items.order_id, SUM(order_items.price * order_items.quantity) AS amount").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Joins("JOIN products ON products.id = order_items.product_id").
+		Where("products.seller_id = ? AND orders.status = ? AND orders.delivered_at >= ?",
+			sellerID, models.OrderDelivered, referral.CreatedAt).
+		Group("order_items.order_id").
+		Order("MIN(orders.delivered_at) ASC").
+		Limit(qualifying).
+		Scan(&sales).Error; err != nil {
+		return err
+	}
+
+	var salesAmount float64
+	for _, sale := range sales {
+		salesAmount += sale.Amount
+	}
+	salesAmount = math.Round(salesAmount*100) / 100
+
+	if len(sales) < qualifying {
+		return database.DB.Model(&referral).Updates(map[string]interface{}{
+			"sales":        len(sales),
+			"sales_amount": salesAmount,
+		}).Error
+	}
+
+	fees := salesAmount * cfg.Payments.PlatformFeePercent / 100
+	rewardAmount := math.Round(fees*cfg.Referrals.SellerCommissionShare) / 100
+	rewardXP := cfg.Referrals.SellerRewardXP
+	now := time.Now()
+	rewarded := false
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Guard against two deliveries qualifying the referral at once
+		result := tx.Model(&referral).Where("status = ?", models.ReferralPending).Updates(map[string]interface{}{
+			"status":        models.ReferralRewarded,
+			"sales":         len(sales),
+			"sales_amount":  salesAmount,
+			"reward_xp":     rewardXP,
+			"reward_amount": rewardAmount,
+			"rewarded_at":   now,
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		rewarded = true
+
+		if rewardAmount > 0 {
+			if err := ledger.Record(tx, models.LedgerEntry{
+				Type:        models.LedgerReferral,
+				Reference:   "referral:" + referral.ID.String(),
+				OrderID:     orderID,
+				SellerID:    &referral.ReferrerID,
+				StoreID:     stores.StoreID(tx, referral.ReferrerID),
+				Amount:      rewardAmount,
+				Description: fmt.Sprintf("Seller referral commission (%.0f%% of platform fees)", cfg.Referrals.SellerCommissionShare),
+			}); err != nil {
+				return err
+			}
+		}
+
+		if rewardXP > 0 {
+			if err := tx.Create(&models.XPTransaction{
+				BaseModel: models.BaseModel{ID: uuid.New()},
+				UserID:    referral.ReferrerID,
+				Amount:    rewardXP,
+				Reason:    "Seller Referral",
+				Reference: referral.ID.String(),
+			}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.User{}).Where("id = ?", referral.ReferrerID).
+				Update("total_xp", gorm.Expr("total_xp + ?", rewardXP)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if rewarded && (rewardAmount > 0 || rewardXP > 0) {
+		message := fmt.Sprintf("A seller you referred completed %d sales.", len(sales))
+		if rewardAmount > 0 {
+			message += fmt.Sprintf(" %.2f commission has been added to your payouts.", rewardAmount)
+		}
+		if rewardXP > 0 {
+			message += fmt.Sprintf(" You earned %d XP.", rewardXP)
+		}
+		notifications.Send(referral.ReferrerID, models.NotificationAccount, "Referral reward earned", message)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package regions
+
+import "playful-marketplace/shared/models"
+
+// Built-in regions. Deployments add or override regions with JSON files in
+// REGIONS_DIR rather than editing this file.
+func builtinRegions() map[string]Region {
+	return map[string]Region{
+		"ET": {
+			Code:          "ET",
+			Name:          "Ethiopia",
+			Currency:      "ETB",
+			PhonePrefixes: []string{"+2519", "+2517"},
+			PhoneLength:   12,
+			Tax: TaxRules{
+				Name:      "VAT",
+				Rate:      15,
+				Inclusive: true,
+			},
+			PaymentMethods: []PaymentMethod{
+				{
+					Method:        models.PaymentTelebirr,
+					Name:          "Telebirr",
+					Description:   "Pay using Telebirr mobile wallet",
+					Icon:          "telebirr-icon.png",
+					RequiresPhone: true,
+					ProcessingFee: 0.02,
+				},
+				{
+					Method:        models.PaymentCBEBirr,
+					Name:          "CBE Birr",
+					Description:   "Pay using Commercial Bank of Ethiopia mobile banking",
+					Icon:          "cbe-icon.png",
+					RequiresPhone: true,
+					ProcessingFee: 0.015,
+				},
+				{
+					Method:        models.PaymentCash,
+					Name:          "Cash on Delivery",
+					Description:   "Pay with cash when your order is delivered",
+					Icon:          "cash-icon.png",
+					RequiresPhone: false,
+					ProcessingFee: 0,
+				},
+			},
+			DeliveryZones: []DeliveryZone{
+				{Code: "addis_ababa", Name: "Addis Ababa", Cities: []string{"Addis Ababa"}, DeliveryDays: 2},
+				{Code: "major_cities", Name: "Major cities", Cities: []string{"Adama", "Bahir Dar", "Dire Dawa", "Gondar", "Hawassa", "Mekelle"}, DeliveryDays: 4},
+				{Code: "rest_of_country", Name: "Rest of the country", DeliveryDays: 7},
+			},
+		},
+	}
+}
@@ -0,0 +1,218 @@
+package regions
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"playful-marketplace/shared/config"
+	"playful-marketplace/shared/models"
+)
+
+// Region describes what the marketplace needs to operate in a country:
+// its currency, tax, payment methods, phone numbers and delivery zones.
+type Region struct {
+	Code           string          `json:"code"` // ISO 3166-1 alpha-2, e.g. ET
+	Name           string          `json:"name"`
+	Currency       string          `json:"currency"`       // ISO 4217, e.g. ETB
+	PhonePrefixes  []string        `json:"phone_prefixes"` // E.164 prefixes of the region's mobile numbers, e.g. +2519
+	PhoneLength    int             `json:"phone_length"`   // Digits in a full number including the country code, 0 skips the check
+	Tax            TaxRules        `json:"tax"`
+	PaymentMethods []PaymentMethod `json:"payment_methods"`
+	DeliveryZones  []DeliveryZone  `json:"delivery_zones"`
+}
+
+// TaxRules sets the sales tax charged on orders
+type TaxRules struct {
+	Name          string             `json:"name"`           // Shown to buyers, e.g. VAT
+	Rate          float64            `json:"rate"`           // Percent
+	Inclusive     bool               `json:"inclusive"`      // Prices already include the tax
+	CategoryRates map[string]float64 `json:"category_rates"` // Product categories taxed at another rate, 0 exempts them
+}
+
+// PaymentMethod is a way buyers in the region can pay
+type PaymentMethod struct {
+	Method        models.PaymentMethod `json:"method"`
+	Name          string               `json:"name"`
+	Description   string               `json:"description"`
+	Icon          string               `json:"icon"`
+	RequiresPhone bool                 `json:"requires_phone"`
+	ProcessingFee float64              `json:"processing_fee"` // Fraction of the amount, e.g. 0.02 for 2%
+}
+
+// DeliveryZone groups the cities orders ship to within the region
+type DeliveryZone struct {
+	Code         string   `json:"code"`
+	Name         string   `json:"name"`
+	Cities       []string `json:"cities"`        // Empty for the zone covering everywhere else
+	DeliveryDays int      `json:"delivery_days"` // Typical days from shipping to delivery
+}
+
+var (
+	defaultCode = "ET"
+	regions     = builtinRegions()
+)
+
+// Payment methods the payment service has an integration for. Regions can
+// offer any of them; a new provider needs code as well as config.
+var integratedMethods = map[models.PaymentMethod]bool{
+	models.PaymentTelebirr: true,
+	models.PaymentCBEBirr:  true,
+	models.PaymentCash:     true,
+}
+
+// Init configures the regions from config. Every JSON file in the regions
+// directory defines one region, replacing a built-in one with the same code.
+// Services call it once at startup.
+func Init(cfg *config.Config) {
+	if cfg.Regions.Dir != "" {
+		if err := loadRegions(cfg.Regions.Dir); err != nil {
+			log.Printf("Failed to load regions: %v", err)
+		}
+	}
+
+	if code := strings.ToUpper(cfg.Regions.Default); code != "" {
+		if _, ok := regions[code]; ok {
+			defaultCode = code
+		} else {
+			log.Printf("Default region %s is not configured, using %s", code, defaultCode)
+		}
+	}
+}
+
+// Get returns the region with the code
+func Get(code string) (Region, bool) {
+	region, ok := regions[strings.ToUpper(code)]
+	return region, ok
+}
+
+// Default returns the region used when a request doesn't name one
+func Default() Region {
+	return regions[defaultCode]
+}
+
+// All returns every configured region, ordered by code
+func All() []Region {
+	all := make([]Region, 0, len(regions))
+	for _, region := range regions {
+		all = append(all, region)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Code < all[j].Code })
+	return all
+}
+
+// ForPhone returns the region a phone number belongs to
+func ForPhone(phone string) (Region, bool) {
+	for _, region := range All() {
+		if region.ValidPhone(phone) {
+			return region, true
+		}
+	}
+	return Region{}, false
+}
+
+// ValidPhone reports whether the phone is a full E.164 number from the region
+func (r Region) ValidPhone(phone string) bool {
+	if !strings.HasPrefix(phone, "+") {
+		return false
+	}
+	digits := phone[1:]
+	for _, d := range digits {
+		if d < '0' || d > '9' {
+			return false
+		}
+	}
+	if r.PhoneLength > 0 && len(digits) != r.PhoneLength {
+		return false
+	}
+
+	for _, prefix := range r.PhonePrefixes {
+		if strings.HasPrefix(phone, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// PaymentMethod returns the payment method if the region supports it
+func (r Region) PaymentMethod(method models.PaymentMethod) (PaymentMethod, bool) {
+	for _, m := range r.PaymentMethods {
+		if m.Method == method {
+			return m, true
+		}
+	}
+	return PaymentMethod{}, false
+}
+
+// Zone returns the delivery zone with the code
+func (r Region) Zone(code string) (DeliveryZone, bool) {
+	for _, zone := range r.DeliveryZones {
+		if strings.EqualFold(zone.Code, code) {
+			return zone, true
+		}
+	}
+	return DeliveryZone{}, false
+}
+
+// TaxRate returns the tax percent charged on a product category
+func (r Region) TaxRate(category string) float64 {
+	for c, rate := range r.Tax.CategoryRates {
+		if strings.EqualFold(c, category) {
+			return rate
+		}
+	}
+	return r.Tax.Rate
+}
+
+// TaxOn returns the tax on an amount spent in a product category. For
+// inclusive tax it's the share of the amount that is tax, otherwise it's
+// charged on top.
+func (r Region) TaxOn(amount float64, category string) float64 {
+	rate := r.TaxRate(category)
+	if rate <= 0 {
+		return 0
+	}
+
+	tax := amount * rate / 100
+	if r.Tax.Inclusive {
+		tax = amount - amount/(1+rate/100)
+	}
+	return math.Round(tax*100) / 100
+}
+
+func loadRegions(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		var region Region
+		if err := json.Unmarshal(data, &region); err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(file), err)
+		}
+		if region.Code == "" || region.Currency == "" {
+			return fmt.Errorf("%s: code and currency are required", filepath.Base(file))
+		}
+		for _, method := range region.PaymentMethods {
+			if !integratedMethods[method.Method] {
+				return fmt.Errorf("%s: payment method %s has no integration", filepath.Base(file), method.Method)
+			}
+		}
+
+		region.Code = strings.ToUpper(region.Code)
+		region.Currency = strings.ToUpper(region.Currency)
+		regions[region.Code] = region
+	}
+	return nil
+}
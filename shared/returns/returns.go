@@ -0,0 +1,47 @@
+package returns
+
+import (
+	"strings"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+
+	"github.com/google/uuid"
+)
+
+// Defaults used when a seller has not configured a return policy
+const (
+	DefaultWindowDays = 7
+)
+
+// PolicyForSeller returns the seller's configured return policy, or the
+// marketplace default if none is set.
+func PolicyForSeller(sellerID uuid.UUID) models.ReturnPolicy {
+	var policy models.ReturnPolicy
+	if err := database.DB.Where("seller_id = ?", sellerID).First(&policy).Error; err != nil {
+		return models.ReturnPolicy{
+			SellerID:   sellerID,
+			WindowDays: DefaultWindowDays,
+		}
+	}
+	return policy
+}
+
+// IsFinalSale reports whether the category is excluded from returns.
+func IsFinalSale(policy models.ReturnPolicy, category string) bool {
+	if category == "" {
+		return false
+	}
+
+	for _, finalSale := range strings.Split(policy.FinalSaleCategories, ",") {
+		if strings.EqualFold(strings.TrimSpace(finalSale), category) {
+			return true
+		}
+	}
+	return false
+}
+
+// RestockingFee calculates the fee withheld from a refund.
+func RestockingFee(policy models.ReturnPolicy, amount float64) float64 {
+	return amount * policy.RestockingFeePercent / 100
+}
@@ -0,0 +1,38 @@
+package scheduler
+
+import (
+	"log"
+	"time"
+
+	"playful-marketplace/shared/redis"
+)
+
+// Every runs job on a fixed interval in the background. A Redis lock
+// ensures only one service replica runs the job per interval.
+func Every(name string, interval time.Duration, job func()) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if !redis.AcquireLock(redis.SchedulerKey(name, interval)) {
+				continue
+			}
+			run(name, job)
+		}
+	}()
+
+	log.Printf("Scheduled job %s every %s", name, interval)
+}
+
+func run(name string, job func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Scheduled job %s panicked: %v", name, r)
+		}
+	}()
+
+	start := time.Now()
+	job()
+	log.Printf("Scheduled job %s completed in %s", name, time.Since(start))
+}
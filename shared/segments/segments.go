@@ -0,0 +1,170 @@
+package segments
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+
+	"gorm.io/gorm"
+)
+
+// Audience selects the active users an announcement is for
+func Audience(audience models.AnnouncementAudience) func(db *gorm.DB) *gorm.DB {
+	roles := models.UserRoles{models.RoleBuyer, models.RoleSeller}
+	switch audience {
+	case models.AudienceBuyers:
+		roles = models.UserRoles{models.RoleBuyer}
+	case models.AudienceSellers:
+		roles = models.UserRoles{models.RoleSeller}
+	}
+
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Model(&models.User{}).Where("is_active = ? AND roles && ?", true, roles)
+	}
+}
+
+// Dormant selects active buyers and sellers who have not logged in for at
+// least the given number of days. Users who never logged in count from signup.
+func Dormant(days int) func(db *gorm.DB) *gorm.DB {
+	cutoff := time.Now().AddDate(0, 0, -days)
+
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Model(&models.User{}).
+			Where("is_active = ? AND roles && ?", true, models.UserRoles{models.RoleBuyer, models.RoleSeller}).
+			Where("COALESCE(last_login_at, created_at) < ?", cutoff)
+	}
+}
+
+// Order statuses that count as a purchase
+var purchasedStatuses = []models.OrderStatus{
+	models.OrderConfirmed,
+	models.OrderProcessing,
+	models.OrderShipped,
+	models.OrderDelivered,
+}
+
+// Validate checks the rules are well-formed before a segment is saved
+func Validate(rules models.SegmentRules) error {
+	for _, role := range rules.Roles {
+		if role != models.RoleBuyer && role != models.RoleSeller && role != models.RoleAdmin {
+			return fmt.Errorf("unknown role %q", role)
+		}
+	}
+	for _, level := range rules.Levels {
+		switch level {
+		case models.LevelBronze, models.LevelSilver, models.LevelGold, models.LevelPlatinum:
+		default:
+			return fmt.Errorf("unknown level %q", level)
+		}
+	}
+	if (rules.MinTotalSpent != nil && *rules.MinTotalSpent < 0) || (rules.MaxTotalSpent != nil && *rules.MaxTotalSpent < 0) {
+		return errors.New("total spent cannot be negative")
+	}
+	if rules.MinTotalSpent != nil && rules.MaxTotalSpent != nil && *rules.MaxTotalSpent < *rules.MinTotalSpent {
+		return errors.New("max_total_spent must not be below min_total_spent")
+	}
+	if (rules.LastOrderWithinDays != nil && *rules.LastOrderWithinDays <= 0) || (rules.NoOrderForDays != nil && *rules.NoOrderForDays <= 0) {
+		return errors.New("order date rules must be a positive number of days")
+	}
+	if rules.MinCategoryOrders < 0 {
+		return errors.New("min_category_orders cannot be negative")
+	}
+	return nil
+}
+
+// Matching selects the active users the rules match
+func Matching(rules models.SegmentRules) func(db *gorm.DB) *gorm.DB {
+	roles := models.UserRoles{models.RoleBuyer, models.RoleSeller}
+	if len(rules.Roles) > 0 {
+		roles = models.UserRoles(rules.Roles)
+	}
+
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Model(&models.User{}).Where("users.is_active = ? AND users.roles && ?", true, roles)
+
+		if len(rules.Levels) > 0 {
+			db = db.Where("users.level IN ?", rules.Levels)
+		}
+		if rules.MinTotalSpent != nil {
+			db = db.Where("users.total_spent >= ?", *rules.MinTotalSpent)
+		}
+		if rules.MaxTotalSpent != nil {
+			db = db.Where("users.total_spent <= ?", *rules.MaxTotalSpent)
+		}
+
+		lastOrder := "(SELECT MAX(orders.created_at) FROM orders WHERE orders.buyer_id = users.id AND orders.status IN ? AND orders.deleted_at IS NULL)"
+		if rules.LastOrderWithinDays != nil {
+			db = db.Where(lastOrder+" >= ?", purchasedStatuses, time.Now().AddDate(0, 0, -*rules.LastOrderWithinDays))
+		}
+		if rules.NoOrderForDays != nil {
+			db = db.Where(lastOrder+" < ?", purchasedStatuses, time.Now().AddDate(0, 0, -*rules.NoOrderForDays))
+		}
+
+		if len(rules.Categories) > 0 {
+			minOrders := rules.MinCategoryOrders
+			if minOrders == 0 {
+				minOrders = 1
+			}
+			db = db.Where(`users.id IN (
+				SELECT orders.buyer_id FROM orders
+				JOIN order_items ON order_items.order_id = orders.id
+				JOIN products ON products.id = order_items.product_id
+				WHERE products.category IN ? AND orders.status IN ? AND orders.deleted_at IS NULL
+				GROUP BY orders.buyer_id
+				HAVING COUNT(DISTINCT orders.id) >= ?)`, rules.Categories, purchasedStatuses, minOrders)
+		}
+
+		if len(rules.Tags) > 0 {
+			db = db.Where("users.id IN (SELECT user_id FROM user_tags WHERE tag IN ? AND deleted_at IS NULL)", rules.Tags)
+		}
+		return db
+	}
+}
+
+// Materialize replaces the segment's memberships with the users its rules
+// match now and returns how many there are
+func Materialize(segment *models.Segment) (int, error) {
+	var count int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("segment_id = ?", segment.ID).Delete(&models.SegmentMember{}).Error; err != nil {
+			return err
+		}
+
+		matching := tx.Session(&gorm.Session{NewDB: true}).Scopes(Matching(segment.Rules)).Select("users.id")
+		result := tx.Exec(`INSERT INTO segment_members (segment_id, user_id, created_at, updated_at)
+			SELECT ?, matching.id, NOW(), NOW() FROM (?) AS matching`, segment.ID, matching)
+		if result.Error != nil {
+			return result.Error
+		}
+		count = result.RowsAffected
+
+		now := time.Now()
+		segment.MemberCount = int(count)
+		segment.MaterializedAt = &now
+		return tx.Model(segment).Updates(map[string]interface{}{
+			"member_count":    segment.MemberCount,
+			"materialized_at": now,
+		}).Error
+	})
+	return int(count), err
+}
+
+// MaterializeAll refreshes every active segment
+func MaterializeAll() {
+	var active []models.Segment
+	if err := database.DB.Where("is_active = ?", true).Find(&active).Error; err != nil {
+		log.Printf("Failed to load segments: %v", err)
+		return
+	}
+
+	for i := range active {
+		if _, err := Materialize(&active[i]); err != nil {
+			log.Printf("Failed to materialize segment %s: %v", active[i].Name, err)
+		}
+	}
+	log.Printf("Materialized %d segment(s)", len(active))
+}
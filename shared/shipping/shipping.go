@@ -0,0 +1,70 @@
+package shipping
+
+import (
+	"strings"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+
+	"github.com/google/uuid"
+)
+
+// Defaults used when a seller has not configured shipping
+const (
+	DefaultHandlingDays = 2
+)
+
+// SettingsForSeller returns the seller's configured shipping settings, or
+// the marketplace default if none are set.
+func SettingsForSeller(sellerID uuid.UUID) models.ShippingSettings {
+	var settings models.ShippingSettings
+	if err := database.DB.Where("seller_id = ?", sellerID).First(&settings).Error; err != nil {
+		return models.ShippingSettings{
+			SellerID:     sellerID,
+			HandlingDays: DefaultHandlingDays,
+		}
+	}
+	return settings
+}
+
+// Zones returns the delivery zone codes the seller ships to, empty when
+// they ship to every zone.
+func Zones(settings models.ShippingSettings) []string {
+	var zones []string
+	for _, zone := range strings.Split(settings.Zones, ",") {
+		if zone = strings.TrimSpace(zone); zone != "" {
+			zones = append(zones, zone)
+		}
+	}
+	return zones
+}
+
+// ShipsTo reports whether the seller ships to the delivery zone. Sellers
+// limited to some zones can't ship to an order without one.
+func ShipsTo(settings models.ShippingSettings, zone string) bool {
+	zones := Zones(settings)
+	if len(zones) == 0 {
+		return true
+	}
+
+	for _, z := range zones {
+		if strings.EqualFold(z, zone) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fee calculates the shipping charged on the seller's subtotal of an order.
+func Fee(settings models.ShippingSettings, subtotal float64) float64 {
+	if settings.FreeShippingThreshold > 0 && subtotal >= settings.FreeShippingThreshold {
+		return 0
+	}
+	return settings.ShippingFee
+}
+
+// ShipBy returns when an order placed at the time has to ship by.
+func ShipBy(settings models.ShippingSettings, placedAt time.Time) time.Time {
+	return placedAt.AddDate(0, 0, settings.HandlingDays)
+}
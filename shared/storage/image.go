@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+
+	// Decoders for the upload formats accepted
+	_ "image/gif"
+	_ "image/png"
+)
+
+var ErrUnsupportedImage = errors.New("image must be a JPEG, PNG or GIF")
+
+// Largest width or height accepted, to keep decoding memory bounded
+const maxImageDimension = 8000
+
+// DecodeImage checks the upload is an image in a supported format
+func DecodeImage(data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if cfg.Width > maxImageDimension || cfg.Height > maxImageDimension {
+		return nil, errors.New("image dimensions are too large")
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	return img, nil
+}
+
+// SquareThumbnail center-crops the image to a square and scales it to size
+// pixels, returned as a JPEG. Each output pixel averages the source pixels
+// it covers, which is plenty for avatars. Transparency is flattened onto
+// white.
+func SquareThumbnail(img image.Image, size int) ([]byte, error) {
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	if side == 0 {
+		return nil, ErrUnsupportedImage
+	}
+	originX := bounds.Min.X + (bounds.Dx()-side)/2
+	originY := bounds.Min.Y + (bounds.Dy()-side)/2
+
+	if side < size {
+		size = side
+	}
+
+	thumb := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0 := originY + y*side/size
+		y1 := originY + (y+1)*side/size
+		for x := 0; x < size; x++ {
+			x0 := originX + x*side/size
+			x1 := originX + (x+1)*side/size
+
+			var r, g, b, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					// Colors are alpha-premultiplied, so adding the missing
+					// coverage as white composites onto a white background
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					white := uint64(0xffff - pa)
+					r, g, b = r+uint64(pr)+white, g+uint64(pg)+white, b+uint64(pb)+white
+					n++
+				}
+			}
+
+			offset := thumb.PixOffset(x, y)
+			thumb.Pix[offset] = uint8(r / n >> 8)
+			thumb.Pix[offset+1] = uint8(g / n >> 8)
+			thumb.Pix[offset+2] = uint8(b / n >> 8)
+			thumb.Pix[offset+3] = 0xff
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
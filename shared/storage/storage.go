@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"playful-marketplace/shared/config"
+)
+
+// Storage saves uploaded files and returns the URL they are served from.
+type Storage interface {
+	Put(key, contentType string, data []byte) (string, error)
+}
+
+// New returns S3-compatible storage when a bucket is configured, and local
+// disk storage otherwise.
+func New(cfg config.StorageConfig) Storage {
+	if cfg.Bucket != "" {
+		return NewS3Storage(cfg)
+	}
+	return &LocalStorage{dir: cfg.LocalDir, publicURL: strings.TrimRight(cfg.PublicURL, "/")}
+}
+
+// LocalStorage writes files under a directory, for development without a
+// bucket. Something else has to serve the directory at the public URL.
+type LocalStorage struct {
+	dir       string
+	publicURL string
+}
+
+func (s *LocalStorage) Put(key, contentType string, data []byte) (string, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", key, err)
+	}
+
+	if s.publicURL == "" {
+		return "/" + key, nil
+	}
+	return s.publicURL + "/" + key, nil
+}
@@ -0,0 +1,110 @@
+package stores
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrStoreExists = errors.New("seller already has a store")
+
+var slugInvalid = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Create sets up the store a seller account sells through, with the seller
+// as its owner, and moves the seller's existing listings onto it.
+func Create(tx *gorm.DB, ownerID uuid.UUID, name, payoutMethod, payoutAccount string) (*models.Store, error) {
+	var count int64
+	tx.Model(&models.Store{}).Where("owner_id = ?", ownerID).Count(&count)
+	if count > 0 {
+		return nil, ErrStoreExists
+	}
+
+	slug, err := uniqueSlug(tx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	store := models.Store{
+		BaseModel:     models.BaseModel{ID: uuid.New()},
+		OwnerID:       ownerID,
+		Name:          name,
+		Slug:          slug,
+		PayoutMethod:  payoutMethod,
+		PayoutAccount: payoutAccount,
+		IsActive:      true,
+	}
+	if err := tx.Create(&store).Error; err != nil {
+		return nil, err
+	}
+
+	owner := models.StoreMember{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		StoreID:   store.ID,
+		UserID:    ownerID,
+		Role:      models.StoreOwner,
+	}
+	if err := tx.Create(&owner).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Model(&models.Product{}).Where("seller_id = ? AND store_id IS NULL", ownerID).
+		Update("store_id", store.ID).Error; err != nil {
+		return nil, err
+	}
+
+	return &store, nil
+}
+
+// ForSeller returns the store a seller account sells through.
+func ForSeller(db *gorm.DB, sellerID uuid.UUID) (*models.Store, error) {
+	var store models.Store
+	if err := db.Where("owner_id = ?", sellerID).First(&store).Error; err != nil {
+		return nil, err
+	}
+	return &store, nil
+}
+
+// StoreID returns the ID of the seller's store, or nil when they have none.
+func StoreID(db *gorm.DB, sellerID uuid.UUID) *uuid.UUID {
+	store, err := ForSeller(db, sellerID)
+	if err != nil {
+		return nil
+	}
+	return &store.ID
+}
+
+// MemberRole returns the user's role in the store, if they belong to it.
+func MemberRole(storeID, userID uuid.UUID) (models.StoreMemberRole, bool) {
+	var member models.StoreMember
+	if err := database.DB.Where("store_id = ? AND user_id = ?", storeID, userID).First(&member).Error; err != nil {
+		return "", false
+	}
+	return member.Role, true
+}
+
+func uniqueSlug(tx *gorm.DB, name string) (string, error) {
+	base := strings.Trim(slugInvalid.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if base == "" {
+		base = "store"
+	}
+
+	slug := base
+	for i := 2; i < 100; i++ {
+		var count int64
+		if err := tx.Model(&models.Store{}).Where("slug = ?", slug).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return slug, nil
+		}
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+	return fmt.Sprintf("%s-%s", base, uuid.New().String()[:8]), nil
+}
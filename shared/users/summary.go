@@ -0,0 +1,86 @@
+package users
+
+import (
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/redis"
+
+	"github.com/google/uuid"
+)
+
+// Summary is the public profile shown next to a user on leaderboards
+type Summary struct {
+	ID         uuid.UUID        `json:"id"`
+	Name       string           `json:"name"`
+	Level      models.UserLevel `json:"level"`
+	BadgeCount int              `json:"badge_count"`
+}
+
+// Summaries looks up many users at once. Recently seen users come from
+// the cache; the rest are read in one query for the users and one for
+// their badge counts, then cached.
+func Summaries(userIDs []uuid.UUID) map[uuid.UUID]Summary {
+	summaries := make(map[uuid.UUID]Summary, len(userIDs))
+	if len(userIDs) == 0 {
+		return summaries
+	}
+
+	keys := make([]redis.Key, len(userIDs))
+	for i, userID := range userIDs {
+		keys[i] = redis.UserSummaryKey(userID)
+	}
+	cached := make([]Summary, len(userIDs))
+	found := redis.GetMany(keys, func(i int) interface{} { return &cached[i] })
+
+	var missing []uuid.UUID
+	for i, userID := range userIDs {
+		if found[i] {
+			summaries[userID] = cached[i]
+		} else {
+			missing = append(missing, userID)
+		}
+	}
+	if len(missing) == 0 {
+		return summaries
+	}
+
+	var users []models.User
+	database.DB.Select("id", "name", "level").Where("id IN ?", missing).Find(&users)
+
+	var badgeCounts []struct {
+		UserID uuid.UUID
+		Count  int
+	}
+	database.DB.Model(&models.UserBadge{}).
+		Select("user_id, COUNT(*) AS count").
+		Where("user_id IN ?", missing).
+		Group("user_id").
+		Scan(&badgeCounts)
+	badges := make(map[uuid.UUID]int, len(badgeCounts))
+	for _, row := range badgeCounts {
+		badges[row.UserID] = row.Count
+	}
+
+	keys = keys[:0]
+	values := make([]interface{}, 0, len(users))
+	for _, user := range users {
+		summary := Summary{
+			ID:         user.ID,
+			Name:       user.Name,
+			Level:      user.Level,
+			BadgeCount: badges[user.ID],
+		}
+		summaries[user.ID] = summary
+		keys = append(keys, redis.UserSummaryKey(user.ID))
+		values = append(values, summary)
+	}
+	redis.SetMany(keys, values)
+
+	return summaries
+}
+
+// Invalidate drops the cached summary after the user's name, level or
+// badges change
+func Invalidate(userID uuid.UUID) {
+	redis.Delete(redis.UserSummaryKey(userID))
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// API keys look like pk_<prefix><secret>; the prefix identifies the key
+// in listings without revealing it.
+const (
+	apiKeyPrefix     = "pk_"
+	APIKeyPrefixSize = 8
+)
+
+// GenerateAPIKey returns a new random API key and its display prefix.
+func GenerateAPIKey() (key string, prefix string, err error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+
+	key = apiKeyPrefix + hex.EncodeToString(raw)
+	return key, key[:len(apiKeyPrefix)+APIKeyPrefixSize], nil
+}
+
+// HashAPIKey returns the hash stored in place of the key.
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
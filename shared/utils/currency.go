@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"strings"
+
+	"playful-marketplace/shared/config"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CurrencyFormat tells clients how to display the amounts in a response.
+// Amounts are always in Currency; multiply by Rate for DisplayCurrency.
+type CurrencyFormat struct {
+	Currency        string  `json:"currency"`
+	DisplayCurrency string  `json:"display_currency"`
+	Rate            float64 `json:"rate"`
+	Symbol          string  `json:"symbol"` // Of the display currency
+	Decimals        int     `json:"decimals"`
+	SymbolFirst     bool    `json:"symbol_first"`
+	Locale          string  `json:"locale"` // For client-side number formatting, e.g. Intl.NumberFormat
+}
+
+type currencyStyle struct {
+	symbol      string
+	decimals    int
+	symbolFirst bool
+}
+
+var currencyStyles = map[string]currencyStyle{
+	"ETB": {symbol: "Br", decimals: 2, symbolFirst: true},
+	"USD": {symbol: "$", decimals: 2, symbolFirst: true},
+	"EUR": {symbol: "€", decimals: 2, symbolFirst: false},
+	"GBP": {symbol: "£", decimals: 2, symbolFirst: true},
+	"KES": {symbol: "KSh", decimals: 2, symbolFirst: true},
+}
+
+// IsSupportedCurrency reports whether amounts can be displayed in code: the
+// base currency or one with a configured rate
+func IsSupportedCurrency(code string, cfg *config.Config) bool {
+	code = strings.ToUpper(code)
+	if code == cfg.Locale.BaseCurrency {
+		return true
+	}
+	_, ok := cfg.Locale.CurrencyRates[code]
+	return ok
+}
+
+// CurrencyHint returns the formatting hints for the request, using the
+// display currency from the user's token. Without a configured rate for it
+// amounts are displayed in the base currency.
+func CurrencyHint(c *fiber.Ctx, cfg *config.Config) CurrencyFormat {
+	base := cfg.Locale.BaseCurrency
+	display := base
+	rate := 1.0
+	if preferred, ok := c.Locals("currency").(string); ok && preferred != base {
+		if r, ok := cfg.Locale.CurrencyRates[preferred]; ok {
+			display, rate = preferred, r
+		}
+	}
+
+	style, ok := currencyStyles[display]
+	if !ok {
+		style = currencyStyle{symbol: display, decimals: 2, symbolFirst: true}
+	}
+
+	return CurrencyFormat{
+		Currency:        base,
+		DisplayCurrency: display,
+		Rate:            rate,
+		Symbol:          style.symbol,
+		Decimals:        style.decimals,
+		SymbolFirst:     style.symbolFirst,
+		Locale:          Language(c),
+	}
+}
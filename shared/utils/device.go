@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DeviceFingerprint derives a stable identifier for the calling device.
+// Clients may send an X-Device-ID header; otherwise the user agent and
+// accept-language headers are used.
+func DeviceFingerprint(c *fiber.Ctx) string {
+	source := c.Get("X-Device-ID")
+	if source == "" {
+		source = c.Get("User-Agent") + "|" + c.Get("Accept-Language")
+	}
+
+	hash := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// Language messages fall back to when nothing else applies
+const DefaultLanguage = "en"
+
+// Languages response messages are available in
+var supportedLanguages = []string{"en", "am"}
+
+// Translations of response messages, keyed by the English message. Messages
+// without a translation are returned in English.
+var translations = map[string]map[string]string{
+	"am": {
+		"User ID not found":                          "የተጠቃሚ መለያ አልተገኘም",
+		"Invalid request body":                       "ልክ ያልሆነ የጥያቄ ይዘት",
+		"User not found":                             "ተጠቃሚው አልተገኘም",
+		"Invalid user ID":                            "ልክ ያልሆነ የተጠቃሚ መለያ",
+		"Invalid product ID":                         "ልክ ያልሆነ የምርት መለያ",
+		"Invalid order ID":                           "ልክ ያልሆነ የትዕዛዝ መለያ",
+		"Order not found":                            "ትዕዛዙ አልተገኘም",
+		"Product not found":                          "ምርቱ አልተገኘም",
+		"Store not found":                            "መደብሩ አልተገኘም",
+		"Insufficient permissions":                   "በቂ ፈቃድ የለዎትም",
+		"Authorization header required":              "የፈቃድ ራስጌ ያስፈልጋል",
+		"Invalid token":                              "ልክ ያልሆነ ቶከን",
+		"Account is suspended":                       "መለያው ታግዷል",
+		"Phone number is required":                   "ስልክ ቁጥር ያስፈልጋል",
+		"Quantity must be at least 1":                "ብዛቱ ቢያንስ 1 መሆን አለበት",
+		"Requested quantity exceeds available stock": "የተጠየቀው ብዛት ካለው ክምችት ይበልጣል",
+		"Search query is required":                   "የፍለጋ ቃል ያስፈልጋል",
+		"OTP sent successfully":                      "የማረጋገጫ ኮድ ተልኳል",
+		"Login successful":                           "በተሳካ ሁኔታ ገብተዋል",
+		"Logout successful":                          "በተሳካ ሁኔታ ወጥተዋል",
+		"User created successfully":                  "ተጠቃሚው በተሳካ ሁኔታ ተፈጥሯል",
+		"User profile retrieved successfully":        "የተጠቃሚው መገለጫ ተገኝቷል",
+		"User profile updated successfully":          "የተጠቃሚው መገለጫ ተዘምኗል",
+		"Products retrieved successfully":            "ምርቶቹ ተገኝተዋል",
+		"Products found successfully":                "ምርቶቹ ተገኝተዋል",
+		"Product retrieved successfully":             "ምርቱ ተገኝቷል",
+		"Categories retrieved successfully":          "ምድቦቹ ተገኝተዋል",
+		"Cart retrieved successfully":                "ጋሪው ተገኝቷል",
+		"Cart updated successfully":                  "ጋሪው ተዘምኗል",
+		"Cart cleared successfully":                  "ጋሪው ባዶ ሆኗል",
+		"Item added to cart":                         "እቃው ወደ ጋሪ ተጨምሯል",
+		"Item removed from cart":                     "እቃው ከጋሪ ተወግዷል",
+		"Order created successfully":                 "ትዕዛዙ በተሳካ ሁኔታ ተፈጥሯል",
+		"Order retrieved successfully":               "ትዕዛዙ ተገኝቷል",
+		"Orders retrieved successfully":              "ትዕዛዞቹ ተገኝተዋል",
+		"Payment initiated successfully":             "ክፍያው ተጀምሯል",
+		"Payment status retrieved successfully":      "የክፍያው ሁኔታ ተገኝቷል",
+		"Payment methods retrieved successfully":     "የክፍያ ዘዴዎቹ ተገኝተዋል",
+	},
+}
+
+// IsSupportedLanguage reports whether response messages are available in lang
+func IsSupportedLanguage(lang string) bool {
+	for _, supported := range supportedLanguages {
+		if lang == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// Language picks the language for the request: the best match for the
+// Accept-Language header, then the user's preferred language from their
+// token, then DefaultLanguage.
+func Language(c *fiber.Ctx) string {
+	if c.Get(fiber.HeaderAcceptLanguage) != "" {
+		if lang := c.AcceptsLanguages(supportedLanguages...); lang != "" {
+			return lang
+		}
+	}
+	if lang, ok := c.Locals("language").(string); ok && IsSupportedLanguage(lang) {
+		return lang
+	}
+	return DefaultLanguage
+}
+
+// Translate returns message in the request's language when a translation exists
+func Translate(c *fiber.Ctx, message string) string {
+	return translate(Language(c), message)
+}
+
+// localize translates a response message and labels the response with the
+// language it was answered in
+func localize(c *fiber.Ctx, message string) string {
+	lang := Language(c)
+	c.Set(fiber.HeaderContentLanguage, lang)
+	return translate(lang, message)
+}
+
+func translate(lang, message string) string {
+	if translated, ok := translations[lang][message]; ok {
+		return translated
+	}
+	return message
+}
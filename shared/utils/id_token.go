@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCProvider describes an OpenID Connect identity provider whose ID tokens
+// we accept.
+type OIDCProvider struct {
+	Name     string
+	Issuers  []string
+	JWKSURL  string
+	ClientID string
+}
+
+var (
+	GoogleProvider = OIDCProvider{
+		Name:    "google",
+		Issuers: []string{"https://accounts.google.com", "accounts.google.com"},
+		JWKSURL: "https://www.googleapis.com/oauth2/v3/certs",
+	}
+	AppleProvider = OIDCProvider{
+		Name:    "apple",
+		Issuers: []string{"https://appleid.apple.com"},
+		JWKSURL: "https://appleid.apple.com/auth/keys",
+	}
+)
+
+type IDTokenClaims struct {
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"` // Apple sends "true" as a string
+	Name          string      `json:"name"`
+	jwt.RegisteredClaims
+}
+
+// IsEmailVerified reports whether the provider verified the email address
+func (c *IDTokenClaims) IsEmailVerified() bool {
+	switch v := c.EmailVerified.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+// VerifyIDToken checks an ID token's signature against the provider's
+// published keys and validates its issuer, audience and expiry.
+func VerifyIDToken(provider OIDCProvider, tokenString string) (*IDTokenClaims, error) {
+	if provider.ClientID == "" {
+		return nil, fmt.Errorf("%s login is not configured", provider.Name)
+	}
+
+	claims := &IDTokenClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return publicKey(provider.JWKSURL, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithAudience(provider.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, issuer := range provider.Issuers {
+		if claims.Issuer == issuer {
+			if claims.Subject == "" {
+				return nil, fmt.Errorf("token has no subject")
+			}
+			return claims, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected issuer: %s", claims.Issuer)
+}
+
+// Provider signing keys, cached per JWKS URL
+var jwks = struct {
+	sync.Mutex
+	keys    map[string]map[string]*rsa.PublicKey
+	fetched map[string]time.Time
+}{
+	keys:    map[string]map[string]*rsa.PublicKey{},
+	fetched: map[string]time.Time{},
+}
+
+const (
+	jwksTTL          = time.Hour
+	jwksMinRefetch   = time.Minute // Throttle refetches for unknown key IDs
+	jwksFetchTimeout = 5 * time.Second
+)
+
+func publicKey(url, kid string) (*rsa.PublicKey, error) {
+	jwks.Lock()
+	defer jwks.Unlock()
+
+	age := time.Since(jwks.fetched[url])
+	key, ok := jwks.keys[url][kid]
+	if ok && age < jwksTTL {
+		return key, nil
+	}
+
+	// Providers rotate keys, so refetch when the key is unknown or stale
+	if !ok && age < jwksMinRefetch {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	keys, err := fetchJWKS(url)
+	if err != nil {
+		if ok {
+			return key, nil // Fall back to the cached key
+		}
+		return nil, err
+	}
+	jwks.keys[url] = keys
+	jwks.fetched[url] = time.Now()
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func fetchJWKS(url string) (map[string]*rsa.PublicKey, error) {
+	client := &http.Client{Timeout: jwksFetchTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching signing keys returned status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
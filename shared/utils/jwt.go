@@ -1,11 +1,14 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
 	"playful-marketplace/shared/config"
+	"playful-marketplace/shared/database"
 	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/redis"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
@@ -14,20 +17,69 @@ import (
 type Claims struct {
 	UserID uuid.UUID        `json:"user_id"`
 	Phone  string           `json:"phone"`
-	Role   models.UserRole  `json:"role"`
+	Role   models.UserRole  `json:"role"`            // Active profile that role checks apply to
+	Roles  models.UserRoles `json:"roles,omitempty"` // Every profile the account holds
+
+	// Extra claims so downstream services can authorize without a lookup
+	SellerVerified bool     `json:"seller_verified,omitempty"`
+	Features       []string `json:"features,omitempty"`
+
+	// Profile preferences for localizing responses
+	Language string `json:"lang,omitempty"`
+	Currency string `json:"currency,omitempty"`
+
+	// Request allowance the rate limiter applies, read without a lookup
+	RateLimitTier models.RateLimitTier `json:"rate_tier,omitempty"`
+
+	// Limits the token below its role; empty means every permission of the role
+	Scopes []string `json:"scopes,omitempty"`
+
+	// Staff member acting for the seller in UserID, set on store tokens
+	ActorID *uuid.UUID `json:"actor_id,omitempty"`
+
 	jwt.RegisteredClaims
 }
 
 func GenerateJWT(user *models.User, cfg *config.Config) (string, error) {
-	expirationTime := time.Now().Add(time.Duration(cfg.JWT.ExpiryHours) * time.Hour)
+	return GenerateScopedJWT(user, cfg, nil, TokenTTL(user.Role, cfg))
+}
+
+// GenerateScopedJWT issues a token limited to the given permission scopes
+func GenerateScopedJWT(user *models.User, cfg *config.Config, scopes []string, ttl time.Duration) (string, error) {
+	return generateJWT(user, cfg, scopes, nil, ttl)
+}
+
+// GenerateStoreJWT issues a token for a staff member to act on the seller's
+// store, limited to the scopes their staff permissions allow
+func GenerateStoreJWT(seller *models.User, actorID uuid.UUID, cfg *config.Config, scopes []string, ttl time.Duration) (string, error) {
+	return generateJWT(seller, cfg, scopes, &actorID, ttl)
+}
+
+func generateJWT(user *models.User, cfg *config.Config, scopes []string, actorID *uuid.UUID, ttl time.Duration) (string, error) {
+	expirationTime := time.Now().Add(ttl)
+
+	// A token issued in the second the user's tokens were revoked in is
+	// dated at the end of it, so it isn't caught by the revocation
+	issuedAt := time.Now()
+	if validAfter, err := tokensValidAfter(user.ID); err == nil && validAfter > issuedAt.Unix() {
+		issuedAt = time.Unix(validAfter, 0)
+	}
 	
 	claims := &Claims{
 		UserID: user.ID,
 		Phone:  user.Phone,
 		Role:   user.Role,
+		Roles:  user.Roles,
+		SellerVerified: user.Role == models.RoleSeller && user.SellerVerified,
+		Features:       cfg.JWT.FeatureFlags[string(user.Role)],
+		Language:       user.PreferredLanguage,
+		Currency:       user.DisplayCurrency,
+		RateLimitTier:  user.RateLimitTier,
+		Scopes:         scopes,
+		ActorID:        actorID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
 			Issuer:    "playful-marketplace",
 		},
 	}
@@ -36,6 +88,25 @@ func GenerateJWT(user *models.User, cfg *config.Config) (string, error) {
 	return token.SignedString([]byte(cfg.JWT.Secret))
 }
 
+// TokenTTL returns how long tokens issued to the role stay valid
+func TokenTTL(role models.UserRole, cfg *config.Config) time.Duration {
+	hours := 0
+	switch role {
+	case models.RoleGuest:
+		hours = cfg.JWT.GuestExpiryHours
+	case models.RoleBuyer:
+		hours = cfg.JWT.BuyerExpiryHours
+	case models.RoleSeller:
+		hours = cfg.JWT.SellerExpiryHours
+	case models.RoleAdmin:
+		hours = cfg.JWT.AdminExpiryHours
+	}
+	if hours <= 0 {
+		hours = cfg.JWT.ExpiryHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
 func ValidateJWT(tokenString string, cfg *config.Config) (*Claims, error) {
 	claims := &Claims{}
 	
@@ -54,9 +125,67 @@ func ValidateJWT(tokenString string, cfg *config.Config) (*Claims, error) {
 		return nil, fmt.Errorf("invalid token")
 	}
 
+	// Store tokens also die when the acting staff member's tokens are revoked
+	if revokedBefore(claims.UserID, claims.IssuedAt) {
+		return nil, ErrTokenRevoked
+	}
+	if claims.ActorID != nil && revokedBefore(*claims.ActorID, claims.IssuedAt) {
+		return nil, ErrTokenRevoked
+	}
+
 	return claims, nil
 }
 
+// RevokeTokens signs the user out everywhere. Besides dropping their
+// sessions, it records a not-before time in the database so tokens issued
+// earlier stay rejected even if Redis loses the sessions index. JWTs only
+// carry whole seconds, so the time is rounded up to the next second to
+// catch tokens issued earlier in the same second.
+func RevokeTokens(userID uuid.UUID) error {
+	validAfter := time.Now().Truncate(time.Second).Add(time.Second)
+	if err := database.DB.Model(&models.User{}).Where("id = ?", userID).
+		Update("tokens_valid_after", validAfter).Error; err != nil {
+		return err
+	}
+	redis.SetTokensValidAfter(userID.String(), validAfter.Unix())
+	return redis.DeleteUserSessions(userID.String())
+}
+
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// revokedBefore reports whether tokens the user was issued at issuedAt have
+// since been revoked. Tokens are rejected when that can't be looked up.
+func revokedBefore(userID uuid.UUID, issuedAt *jwt.NumericDate) bool {
+	validAfter, err := tokensValidAfter(userID)
+	if err != nil {
+		return true
+	}
+	if validAfter == 0 {
+		return false
+	}
+	return issuedAt == nil || issuedAt.Unix() < validAfter
+}
+
+// tokensValidAfter returns the Unix time the user's tokens must be issued
+// at or after, 0 when they were never revoked. The database is the source
+// of truth; Redis only caches it.
+func tokensValidAfter(userID uuid.UUID) (int64, error) {
+	if validAfter, err := redis.GetTokensValidAfter(userID.String()); err == nil {
+		return validAfter, nil
+	}
+
+	var user models.User
+	if err := database.DB.Select("tokens_valid_after").Where("id = ?", userID).First(&user).Error; err != nil {
+		return 0, err
+	}
+	var validAfter int64
+	if user.TokensValidAfter != nil {
+		validAfter = user.TokensValidAfter.Unix()
+	}
+	redis.SetTokensValidAfter(userID.String(), validAfter)
+	return validAfter, nil
+}
+
 func ExtractTokenFromHeader(authHeader string) string {
 	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
 		return authHeader[7:]
@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Content type of error responses
+const ProblemContentType = "application/problem+json"
+
+// Prefix of problem type URIs, followed by the problem's code
+const problemTypePrefix = "urn:playful-marketplace:problem:"
+
+// Problem is an RFC 7807 problem details body. Success, Message, Error and
+// Data repeat the response envelope so clients reading it keep working.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`               // Stable, machine-readable, e.g. not_found
+	TraceID  string       `json:"trace_id,omitempty"` // Matches the X-Request-ID response header
+	Errors   []FieldError `json:"errors,omitempty"`
+
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// FieldError explains why one request field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error codes for statuses that don't set their own
+var statusCodes = map[int]string{
+	fiber.StatusBadRequest:            "bad_request",
+	fiber.StatusUnauthorized:          "unauthorized",
+	fiber.StatusPaymentRequired:       "payment_required",
+	fiber.StatusForbidden:             "forbidden",
+	fiber.StatusNotFound:              "not_found",
+	fiber.StatusMethodNotAllowed:      "method_not_allowed",
+	fiber.StatusConflict:              "conflict",
+	fiber.StatusGone:                  "gone",
+	fiber.StatusRequestEntityTooLarge: "payload_too_large",
+	fiber.StatusUnprocessableEntity:   "unprocessable_entity",
+	fiber.StatusTooManyRequests:       "rate_limited",
+	fiber.StatusInternalServerError:   "internal_error",
+	fiber.StatusBadGateway:            "bad_gateway",
+	fiber.StatusServiceUnavailable:    "service_unavailable",
+	fiber.StatusGatewayTimeout:        "gateway_timeout",
+}
+
+// ProblemResponse sends the problem as application/problem+json. Status is
+// required; the type, title, code, instance and trace ID are filled in when
+// unset, and the detail is localized.
+func ProblemResponse(c *fiber.Ctx, problem Problem) error {
+	if problem.Code == "" {
+		problem.Code = statusCode(problem.Status)
+	}
+	if problem.Type == "" {
+		problem.Type = problemTypePrefix + problem.Code
+	}
+	if problem.Title == "" {
+		problem.Title = http.StatusText(problem.Status)
+	}
+	if problem.Instance == "" {
+		problem.Instance = c.Path()
+	}
+	if problem.TraceID == "" {
+		problem.TraceID = TraceID(c)
+	}
+	for i := range problem.Errors {
+		problem.Errors[i].Message = Translate(c, problem.Errors[i].Message)
+	}
+
+	problem.Detail = localize(c, problem.Detail)
+	problem.Success = false
+	problem.Message = problem.Detail
+
+	return c.Status(problem.Status).JSON(problem, ProblemContentType)
+}
+
+// FieldErrorsResponse rejects a request with the fields that failed validation
+func FieldErrorsResponse(c *fiber.Ctx, message string, errs ...FieldError) error {
+	return ProblemResponse(c, Problem{
+		Status: fiber.StatusBadRequest,
+		Code:   "validation_failed",
+		Detail: message,
+		Errors: errs,
+	})
+}
+
+// FiberErrorHandler answers errors handlers return instead of writing a
+// response, such as fiber's own 404s and body limit errors, with a problem.
+// Services install it as their fiber.Config ErrorHandler.
+func FiberErrorHandler(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	message := "Internal Server Error"
+	if e, ok := err.(*fiber.Error); ok {
+		status = e.Code
+		message = e.Message
+	}
+
+	problem := Problem{Status: status, Detail: message}
+	if status >= fiber.StatusInternalServerError {
+		problem.Error = err.Error()
+	}
+	return ProblemResponse(c, problem)
+}
+
+// TraceID returns the request's trace ID, set by the trace middleware or
+// forwarded by a gateway
+func TraceID(c *fiber.Ctx) string {
+	if id, ok := c.Locals("trace_id").(string); ok {
+		return id
+	}
+	return c.Get(fiber.HeaderXRequestID)
+}
+
+func statusCode(status int) string {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
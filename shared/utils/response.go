@@ -12,22 +12,24 @@ type Response struct {
 func SuccessResponse(c *fiber.Ctx, message string, data interface{}) error {
 	return c.JSON(Response{
 		Success: true,
-		Message: message,
+		Message: localize(c, message),
 		Data:    data,
 	})
 }
 
+// ErrorResponse sends an application/problem+json error with the code for
+// the status
 func ErrorResponse(c *fiber.Ctx, statusCode int, message string, err error) error {
-	response := Response{
-		Success: false,
-		Message: message,
+	problem := Problem{
+		Status: statusCode,
+		Detail: message,
 	}
-	
+
 	if err != nil {
-		response.Error = err.Error()
+		problem.Error = err.Error()
 	}
-	
-	return c.Status(statusCode).JSON(response)
+
+	return ProblemResponse(c, problem)
 }
 
 func ValidationErrorResponse(c *fiber.Ctx, message string) error {
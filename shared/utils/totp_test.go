@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Base32 of the RFC 6238 SHA1 test key "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateTOTPVectors(t *testing.T) {
+	// Last six digits of the RFC 6238 appendix B SHA1 codes
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, v := range vectors {
+		step, ok := ValidateTOTP(rfcSecret, v.code, time.Unix(v.unix, 0))
+		if !ok {
+			t.Errorf("code %s at %d rejected", v.code, v.unix)
+			continue
+		}
+		if want := v.unix / totpPeriod; step != want {
+			t.Errorf("code %s at %d matched step %d, want %d", v.code, v.unix, step, want)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	at := time.Unix(1111111111, 0)
+	step := at.Unix() / totpPeriod
+	key, err := totpEncoding.DecodeString(rfcSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for offset := int64(-totpSkew); offset <= totpSkew; offset++ {
+		code := totpCode(key, uint64(step+offset))
+		if got, ok := ValidateTOTP(rfcSecret, code, at); !ok || got != step+offset {
+			t.Errorf("code for step offset %d: got (%d, %v)", offset, got, ok)
+		}
+	}
+
+	for _, offset := range []int64{-totpSkew - 1, totpSkew + 1} {
+		code := totpCode(key, uint64(step+offset))
+		if _, ok := ValidateTOTP(rfcSecret, code, at); ok && code != totpCode(key, uint64(step)) {
+			t.Errorf("code for step offset %d accepted", offset)
+		}
+	}
+}
+
+func TestValidateTOTPRejects(t *testing.T) {
+	at := time.Unix(1111111111, 0)
+	tests := []struct {
+		name, secret, code string
+	}{
+		{"wrong code", rfcSecret, "000000"},
+		{"short code", rfcSecret, "50471"},
+		{"long code", rfcSecret, "14050471"},
+		{"invalid secret", "not base32!", "050471"},
+	}
+	for _, tt := range tests {
+		if _, ok := ValidateTOTP(tt.secret, tt.code, at); ok {
+			t.Errorf("%s: accepted", tt.name)
+		}
+	}
+
+	// Secrets are accepted in any case and with surrounding space
+	if _, ok := ValidateTOTP(" "+strings.ToLower(rfcSecret)+"\n", "050471", at); !ok {
+		t.Error("lower case secret with whitespace rejected")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Fatalf("secret %q decodes to %d bytes: %v", secret, len(key), err)
+	}
+}
@@ -0,0 +1,378 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Filters are a small JMESPath-style expression language evaluated against
+// the delivery's JSON payload, e.g.
+//
+//	data.amount >= `100` && data.order_status != 'cancelled'
+//
+// Fields are addressed with dotted paths. Literals are 'raw strings',
+// `JSON` in backticks, bare numbers, true, false and null. Expressions
+// combine with ==, !=, <, <=, >, >=, &&, ||, ! and parentheses. A bare path
+// matches when its value is truthy: anything but null, false and empty
+// strings, arrays or objects. Ordering comparisons only match numbers.
+
+// maxFilterLength keeps filters cheap to evaluate on every delivery
+const maxFilterLength = 512
+
+// Filter is a compiled filter expression
+type Filter struct {
+	root node
+}
+
+// CompileFilter parses a filter expression, returning an error that
+// describes where it is malformed
+func CompileFilter(expression string) (*Filter, error) {
+	if len(expression) > maxFilterLength {
+		return nil, fmt.Errorf("filter is longer than %d characters", maxFilterLength)
+	}
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.peek().text, p.peek().pos)
+	}
+	return &Filter{root: root}, nil
+}
+
+// Match reports whether the JSON payload satisfies the filter
+func (f *Filter) Match(payload []byte) bool {
+	var document interface{}
+	if err := json.Unmarshal(payload, &document); err != nil {
+		return false
+	}
+	return truthy(f.root.eval(document))
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPath
+	tokenLiteral
+	tokenOperator
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value interface{} // Literal value
+	pos   int
+}
+
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!"}
+
+func tokenize(expression string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expression); {
+		ch := rune(expression[i])
+		switch {
+		case unicode.IsSpace(ch):
+			i++
+		case ch == '(':
+			tokens = append(tokens, token{kind: tokenOpen, text: "(", pos: i})
+			i++
+		case ch == ')':
+			tokens = append(tokens, token{kind: tokenClose, text: ")", pos: i})
+			i++
+		case ch == '\'':
+			end := strings.IndexByte(expression[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			text := expression[i+1 : i+1+end]
+			tokens = append(tokens, token{kind: tokenLiteral, text: text, value: text, pos: i})
+			i += end + 2
+		case ch == '`':
+			end := strings.IndexByte(expression[i+1:], '`')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated literal at position %d", i)
+			}
+			text := expression[i+1 : i+1+end]
+			var value interface{}
+			if err := json.Unmarshal([]byte(text), &value); err != nil {
+				return nil, fmt.Errorf("invalid JSON literal at position %d", i)
+			}
+			tokens = append(tokens, token{kind: tokenLiteral, text: text, value: value, pos: i})
+			i += end + 2
+		case ch == '-' || unicode.IsDigit(ch):
+			end := i + 1
+			for end < len(expression) && (unicode.IsDigit(rune(expression[end])) || expression[end] == '.') {
+				end++
+			}
+			number, err := strconv.ParseFloat(expression[i:end], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number at position %d", i)
+			}
+			tokens = append(tokens, token{kind: tokenLiteral, text: expression[i:end], value: number, pos: i})
+			i = end
+		case ch == '_' || unicode.IsLetter(ch):
+			end := i + 1
+			for end < len(expression) && isPathChar(rune(expression[end])) {
+				end++
+			}
+			text := expression[i:end]
+			switch text {
+			case "true":
+				tokens = append(tokens, token{kind: tokenLiteral, text: text, value: true, pos: i})
+			case "false":
+				tokens = append(tokens, token{kind: tokenLiteral, text: text, value: false, pos: i})
+			case "null":
+				tokens = append(tokens, token{kind: tokenLiteral, text: text, pos: i})
+			default:
+				if strings.HasSuffix(text, ".") || strings.Contains(text, "..") {
+					return nil, fmt.Errorf("invalid field path %q at position %d", text, i)
+				}
+				tokens = append(tokens, token{kind: tokenPath, text: text, pos: i})
+			}
+			i = end
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(expression[i:], op) {
+					tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected %q at position %d", ch, i)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, text: "end of filter", pos: len(expression)}), nil
+}
+
+func isPathChar(ch rune) bool {
+	return ch == '_' || ch == '.' || unicode.IsLetter(ch) || unicode.IsDigit(ch)
+}
+
+type parser struct {
+	tokens []token
+	next   int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) operator(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenOperator {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.next++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.operator("||"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.operator("&&"); !ok {
+			return left, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+}
+
+func (p *parser) parseNot() (node, error) {
+	if _, ok := p.operator("!"); ok {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.operator("==", "!=", "<", "<=", ">", ">=")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return compareNode{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenPath:
+		p.next++
+		return pathNode(strings.Split(t.text, ".")), nil
+	case tokenLiteral:
+		p.next++
+		return literalNode{t.value}, nil
+	case tokenOpen:
+		p.next++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokenClose {
+			return nil, fmt.Errorf("expected ) at position %d", p.peek().pos)
+		}
+		p.next++
+		return inner, nil
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+}
+
+type node interface {
+	eval(document interface{}) interface{}
+}
+
+type pathNode []string
+
+func (n pathNode) eval(document interface{}) interface{} {
+	value := document
+	for _, field := range n {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[field]
+	}
+	return value
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n literalNode) eval(interface{}) interface{} {
+	return n.value
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n notNode) eval(document interface{}) interface{} {
+	return !truthy(n.operand.eval(document))
+}
+
+type andNode struct {
+	left, right node
+}
+
+func (n andNode) eval(document interface{}) interface{} {
+	return truthy(n.left.eval(document)) && truthy(n.right.eval(document))
+}
+
+type orNode struct {
+	left, right node
+}
+
+func (n orNode) eval(document interface{}) interface{} {
+	return truthy(n.left.eval(document)) || truthy(n.right.eval(document))
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n compareNode) eval(document interface{}) interface{} {
+	left, right := n.left.eval(document), n.right.eval(document)
+	switch n.op {
+	case "==":
+		return equal(left, right)
+	case "!=":
+		return !equal(left, right)
+	}
+
+	a, ok := left.(float64)
+	if !ok {
+		return false
+	}
+	b, ok := right.(float64)
+	if !ok {
+		return false
+	}
+	switch n.op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	default:
+		return a >= b
+	}
+}
+
+func equal(a, b interface{}) bool {
+	encodedA, errA := json.Marshal(a)
+	encodedB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(encodedA) == string(encodedB)
+}
+
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package webhooks
+
+import (
+	"strings"
+	"testing"
+)
+
+const orderPayload = `{
+	"event": "order.updated",
+	"data": {
+		"amount": 1250.5,
+		"order_status": "shipped",
+		"gift": false,
+		"coupon": "",
+		"items": [{"sku": "GABI-001"}],
+		"buyer": {"region": "ET"}
+	}
+}`
+
+func TestFilterMatch(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{"data.amount >= `100`", true},
+		{"data.amount > 2000", false},
+		{"data.amount <= 1250.5 && data.amount < 1251", true},
+		{"data.amount > -1", true},
+		{"data.order_status == 'shipped'", true},
+		{"data.order_status != 'cancelled'", true},
+		{"data.buyer.region == 'ET'", true},
+		{"data.buyer.region == `\"ET\"`", true},
+		{"data.items", true},
+		{"data.coupon", false},
+		{"data.gift", false},
+		{"!data.gift", true},
+		{"data.missing", false},
+		{"data.missing == null", true},
+		{"data.gift == false", true},
+		{"data.order_status == 'cancelled' || data.amount > 1000", true},
+		{"!(data.order_status == 'shipped' && data.amount > 1000)", false},
+		{"data.order_status > 1", false}, // Ordering only compares numbers
+		{"data.items == `[{\"sku\": \"GABI-001\"}]`", true},
+	}
+	for _, tt := range tests {
+		filter, err := CompileFilter(tt.expression)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expression, err)
+			continue
+		}
+		if got := filter.Match([]byte(orderPayload)); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.expression, got, tt.want)
+		}
+	}
+}
+
+func TestFilterInvalidPayload(t *testing.T) {
+	filter, err := CompileFilter("!data.gift")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.Match([]byte("not json")) {
+		t.Error("matched a payload that isn't JSON")
+	}
+}
+
+func TestCompileFilterErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		err        string
+	}{
+		{"data.status == 'shipped", "unterminated string at position 15"},
+		{"data.amount > `100", "unterminated literal at position 14"},
+		{"data.amount > `{bad}`", "invalid JSON literal at position 14"},
+		{"data. == 1", `invalid field path "data." at position 0`},
+		{"data..amount", `invalid field path "data..amount" at position 0`},
+		{"data.amount > 1.2.3", "invalid number at position 14"},
+		{"data.amount = 1", `unexpected '=' at position 12`},
+		{"(data.amount > 1", "expected ) at position 16"},
+		{"data.amount > 1)", `unexpected ")" at position 15`},
+		{"data.amount &&", `unexpected "end of filter" at position 14`},
+		{strings.Repeat("a", maxFilterLength+1), "filter is longer than 512 characters"},
+	}
+	for _, tt := range tests {
+		_, err := CompileFilter(tt.expression)
+		if err == nil {
+			t.Errorf("%s: compiled", tt.expression)
+			continue
+		}
+		if err.Error() != tt.err {
+			t.Errorf("%s: got error %q, want %q", tt.expression, err, tt.err)
+		}
+	}
+}
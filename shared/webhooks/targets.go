@@ -0,0 +1,87 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedTarget is returned for endpoints on loopback, private or
+// link-local addresses, which would let subscribers reach into the cluster
+var ErrBlockedTarget = errors.New("webhook endpoint resolves to a non-public address")
+
+// Shared address space carriers and some clusters use internally, which
+// net.IP doesn't count as private
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// client delivers webhooks. Addresses are checked when each connection is
+// dialed, after DNS resolution, so a hostname can't be pointed at an
+// internal address once the subscription is saved. Redirects aren't
+// followed.
+var client = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+					return fmt.Errorf("%w: %s", ErrBlockedTarget, host)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// ValidateURL checks that endpoint is an absolute https URL that isn't
+// obviously internal. Hostnames are checked again on every delivery.
+func ValidateURL(endpoint string) error {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Scheme != "https" || parsed.Hostname() == "" {
+		return errors.New("URL must be an absolute https URL")
+	}
+	if parsed.User != nil {
+		return errors.New("URL must not contain credentials")
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+	if ip := net.ParseIP(host); ip != nil {
+		if !publicIP(ip) {
+			return ErrBlockedTarget
+		}
+		return nil
+	}
+	if host == "localhost" || !strings.Contains(host, ".") {
+		return ErrBlockedTarget
+	}
+	for _, suffix := range []string{".localhost", ".local", ".internal"} {
+		if strings.HasSuffix(host, suffix) {
+			return ErrBlockedTarget
+		}
+	}
+	return nil
+}
+
+func publicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsMulticast() || sharedAddressSpace.Contains(ip) {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 0 {
+		return false // "This network"
+	}
+	return ip.IsGlobalUnicast()
+}
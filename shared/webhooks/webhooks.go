@@ -0,0 +1,208 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/jobs"
+	"playful-marketplace/shared/models"
+
+	"github.com/google/uuid"
+)
+
+// EventTypes are the events subscribers can select, the same ones pushed to
+// sellers' live order dashboards
+var EventTypes = []string{"order.created", "payment.completed", "payment.failed"}
+
+// PayloadVersions are the delivery payload shapes subscribers can pin. A new
+// version is added whenever Envelope or an event's data changes shape, so
+// existing subscribers keep receiving the shape they were built against.
+var PayloadVersions = []int{1}
+
+// CurrentPayloadVersion is the version new subscriptions get by default
+const CurrentPayloadVersion = 1
+
+const jobDeliver = "webhooks.deliver"
+
+// Envelope is the version 1 delivery payload
+type Envelope struct {
+	ID        uuid.UUID   `json:"id"` // The same across retries, for deduplication
+	Type      string      `json:"type"`
+	Version   int         `json:"version"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+type deliveryJob struct {
+	SubscriptionID uuid.UUID `json:"subscription_id"`
+	EventType      string    `json:"event_type"`
+	Payload        string    `json:"payload"`
+}
+
+// RegisterJobs registers the delivery handler. Every service that
+// dispatches events registers it, since workers only run their own jobs.
+func RegisterJobs() {
+	jobs.Handle(jobDeliver, deliver)
+}
+
+// IsEventType reports whether subscribers can select the event type
+func IsEventType(eventType string) bool {
+	for _, known := range EventTypes {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPayloadVersion reports whether deliveries can be made in the version
+func IsPayloadVersion(version int) bool {
+	for _, known := range PayloadVersions {
+		if known == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Dispatch queues a delivery of the event to each of the owner's active
+// subscriptions that selected its type and whose filter matches it
+func Dispatch(ownerID uuid.UUID, eventType string, data interface{}) {
+	var subscriptions []models.WebhookSubscription
+	if err := database.DB.Where("owner_id = ? AND is_active = ?", ownerID, true).Find(&subscriptions).Error; err != nil {
+		log.Printf("Failed to load webhook subscriptions for %s: %v", ownerID, err)
+		return
+	}
+
+	eventID := uuid.New()
+	createdAt := time.Now()
+
+	for _, subscription := range subscriptions {
+		if !subscribed(&subscription, eventType) {
+			continue
+		}
+
+		// Only version 1 exists so far; later versions are shaped here
+		payload, err := json.Marshal(Envelope{
+			ID:        eventID,
+			Type:      eventType,
+			Version:   subscription.PayloadVersion,
+			CreatedAt: createdAt,
+			Data:      data,
+		})
+		if err != nil {
+			log.Printf("Failed to encode %s webhook: %v", eventType, err)
+			return
+		}
+
+		if subscription.Filter != "" {
+			filter, err := CompileFilter(subscription.Filter)
+			if err != nil || !filter.Match(payload) {
+				continue
+			}
+		}
+
+		jobs.Enqueue(jobDeliver, deliveryJob{
+			SubscriptionID: subscription.ID,
+			EventType:      eventType,
+			Payload:        string(payload),
+		})
+	}
+}
+
+func subscribed(subscription *models.WebhookSubscription, eventType string) bool {
+	types := subscription.EventTypeList()
+	if len(types) == 0 {
+		return true
+	}
+	for _, selected := range types {
+		if selected == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns the signature sent in X-Signature: the hex HMAC-SHA256 of
+// the X-Timestamp value, a dot and the body. Receivers reject old
+// timestamps and repeated envelope IDs to stop replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignatureTolerance is how far a signed timestamp may be from the
+// receiver's clock before the request is treated as a replay
+const SignatureTolerance = 5 * time.Minute
+
+// Verify checks an inbound request signed the way Sign signs deliveries,
+// given its X-Timestamp and X-Signature values. Timestamps further than
+// SignatureTolerance from now are refused.
+func Verify(secret, timestamp, signature string, body []byte) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := time.Since(time.Unix(ts, 0)); age > SignatureTolerance || age < -SignatureTolerance {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, ts, body)), []byte(signature))
+}
+
+// deliver posts one event to a subscriber. Failed deliveries are retried
+// by the job queue with backoff.
+func deliver(payload []byte) error {
+	var job deliveryJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+
+	var subscription models.WebhookSubscription
+	if err := database.DB.First(&subscription, job.SubscriptionID).Error; err != nil {
+		return nil // Deleted since the event was queued
+	}
+	if !subscription.IsActive {
+		return nil
+	}
+
+	if err := ValidateURL(subscription.URL); err != nil {
+		return err
+	}
+
+	body := []byte(job.Payload)
+	req, err := http.NewRequest(http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Type", job.EventType)
+	req.Header.Set("X-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Signature", Sign(subscription.Secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook endpoint returned status %d", resp.StatusCode)
+	}
+
+	now := time.Now()
+	database.DB.Model(&subscription).Update("last_delivered_at", now)
+	return nil
+}
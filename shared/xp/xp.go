@@ -0,0 +1,31 @@
+// Package xp computes the XP orders earn, so the order service awarding it
+// and the gamification service previewing rule changes agree.
+package xp
+
+import "playful-marketplace/shared/config"
+
+// Rules sets the XP earned from orders
+type Rules struct {
+	BuyerPer100  float64 `json:"buyer_xp_per_100"`  // Per 100 spent on a delivered order
+	SellerPer100 float64 `json:"seller_xp_per_100"` // Per 100 of delivered sales
+	FirstOrder   int     `json:"first_order_xp"`
+}
+
+// FromConfig returns the rules in effect
+func FromConfig(cfg *config.Config) Rules {
+	return Rules{
+		BuyerPer100:  cfg.XP.BuyerPer100,
+		SellerPer100: cfg.XP.SellerPer100,
+		FirstOrder:   cfg.XP.FirstOrder,
+	}
+}
+
+// Purchase returns the XP a buyer earns for a delivered order of the amount
+func (r Rules) Purchase(amount float64) int {
+	return int(amount / 100 * r.BuyerPer100)
+}
+
+// Sale returns the XP a seller earns for delivered sales of the amount
+func (r Rules) Sale(amount float64) int {
+	return int(amount / 100 * r.SellerPer100)
+}
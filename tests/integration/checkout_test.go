@@ -0,0 +1,167 @@
+//go:build integration
+
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"playful-marketplace/shared/contracts"
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/invoices"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/xp"
+
+	"github.com/google/uuid"
+)
+
+// TestCheckoutToRewards follows an order from the cart through payment and
+// delivery, checking the XP and badges the services award along the way
+func TestCheckoutToRewards(t *testing.T) {
+	seller, sellerToken := createUser(t, models.RoleSeller, "+251911000101")
+	buyer, buyerToken := createUser(t, models.RoleBuyer, "+251911000102")
+
+	product := models.Product{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		Name:      "Handwoven Gabi",
+		SKU:       "GABI-001",
+		Price:     1200,
+		Stock:     5,
+		Category:  "home",
+		IsActive:  true,
+		SellerID:  seller.ID,
+	}
+	if err := database.DB.Create(&product).Error; err != nil {
+		t.Fatalf("Failed to create product: %v", err)
+	}
+
+	// Checkout from the cart
+	item := map[string]interface{}{"product_id": product.ID, "quantity": 2}
+	if status := call(t, orderApp, buyerToken, http.MethodPost, "/api/v1/cart/items", item, nil); status != http.StatusOK && status != http.StatusCreated {
+		t.Fatalf("Adding to cart returned %d", status)
+	}
+
+	var order struct {
+		models.Order
+		PaymentRequest *contracts.InitiatePaymentRequest `json:"payment_request"`
+	}
+	checkout := map[string]interface{}{
+		"shipping_address": "Bole Road, Addis Ababa",
+		"delivery_zone":    "addis_ababa",
+	}
+	if status := call(t, orderApp, buyerToken, http.MethodPost, "/api/v1/orders", checkout, &order); status != http.StatusCreated {
+		t.Fatalf("Checkout returned %d", status)
+	}
+	if order.Status != models.OrderPending {
+		t.Fatalf("New order is %s, want pending", order.Status)
+	}
+	if order.PaymentRequest == nil || order.PaymentRequest.OrderID != order.ID {
+		t.Fatalf("New order has payment request %+v", order.PaymentRequest)
+	}
+
+	// The first order earns its XP and badge in the background
+	eventually(t, "the first order badge", func() bool {
+		return hasBadge(t, buyerToken, buyer.ID, models.BadgeFirstOrder)
+	})
+
+	// Pay with the prefilled request, then check the status until the
+	// provider settles it
+	pay := *order.PaymentRequest
+	pay.Method = models.PaymentTelebirr
+	pay.Phone = buyer.Phone
+	var payment contracts.PaymentResponse
+	if status := call(t, paymentApp, buyerToken, http.MethodPost, "/api/v1/payments/initiate", pay, &payment); status != http.StatusOK {
+		t.Fatalf("Initiating payment returned %d", status)
+	}
+	eventually(t, "the payment to complete", func() bool {
+		var settled struct {
+			Status models.PaymentStatus `json:"status"`
+		}
+		call(t, paymentApp, buyerToken, http.MethodGet, "/api/v1/payments/status/"+payment.TransactionID, nil, &settled)
+		return settled.Status == models.PaymentCompleted
+	})
+
+	var confirmed models.Order
+	if err := database.DB.First(&confirmed, order.ID).Error; err != nil {
+		t.Fatalf("Failed to load order: %v", err)
+	}
+	if confirmed.Status != models.OrderConfirmed {
+		t.Fatalf("Paid order is %s, want confirmed", confirmed.Status)
+	}
+	eventually(t, "the payment XP", func() bool {
+		return xpAwarded(buyer.ID, "Payment Completed")
+	})
+
+	// Confirmed orders invoice the seller once, from the seller's sequence
+	var invoice models.Invoice
+	eventually(t, "the seller invoice", func() bool {
+		return database.DB.Where("order_id = ? AND seller_id = ?", order.ID, seller.ID).First(&invoice).Error == nil
+	})
+	if want := invoices.Number(seller.ID, 1); invoice.InvoiceNumber != want {
+		t.Errorf("Invoice number is %s, want %s", invoice.InvoiceNumber, want)
+	}
+	if again, err := invoices.Issue(order.ID); err != nil || len(again) != 0 {
+		t.Errorf("Issuing again returned %d invoices: %v", len(again), err)
+	}
+
+	// The seller ships and delivers, which credits the sale
+	for _, status := range []models.OrderStatus{models.OrderShipped, models.OrderDelivered} {
+		update := map[string]interface{}{"status": status}
+		if code := call(t, orderApp, sellerToken, http.MethodPut, "/api/v1/orders/"+order.ID.String()+"/status", update, nil); code != http.StatusOK {
+			t.Fatalf("Marking the order %s returned %d", status, code)
+		}
+	}
+
+	rules := xp.FromConfig(cfg)
+	if rules.Purchase(confirmed.TotalAmount) > 0 {
+		eventually(t, "the delivered order XP", func() bool {
+			return xpAwarded(buyer.ID, "Order Completed")
+		})
+	}
+	if rules.Sale(2*product.Price) > 0 {
+		eventually(t, "the sale XP", func() bool {
+			return xpAwarded(seller.ID, "Product Sale")
+		})
+	}
+
+	// Rewards are credited once, and the XP the gamification service
+	// reports adds up to the awards
+	eventually(t, "the delivered order to be rewarded", func() bool {
+		var processed models.Order
+		return database.DB.First(&processed, order.ID).Error == nil && processed.RewardsProcessedAt != nil
+	})
+	for _, user := range []models.User{buyer, seller} {
+		var awarded int64
+		database.DB.Model(&models.XPTransaction{}).Where("user_id = ?", user.ID).
+			Select("COALESCE(SUM(amount), 0)").Scan(&awarded)
+
+		var profile struct {
+			TotalXP int64 `json:"total_xp"`
+		}
+		if status := call(t, gamificationApp, buyerToken, http.MethodGet, "/api/v1/gamify/xp/"+user.ID.String(), nil, &profile); status != http.StatusOK {
+			t.Fatalf("Getting XP returned %d", status)
+		}
+		if profile.TotalXP != awarded {
+			t.Errorf("%s has %d XP, awarded %d", user.Role, profile.TotalXP, awarded)
+		}
+	}
+}
+
+func hasBadge(t *testing.T, token string, userID uuid.UUID, badgeType models.BadgeType) bool {
+	t.Helper()
+
+	var badges []models.UserBadge
+	call(t, gamificationApp, token, http.MethodGet, "/api/v1/gamify/badges/"+userID.String(), nil, &badges)
+	for _, badge := range badges {
+		if badge.Badge.Type == badgeType {
+			return true
+		}
+	}
+	return false
+}
+
+func xpAwarded(userID uuid.UUID, reason string) bool {
+	var count int64
+	database.DB.Model(&models.XPTransaction{}).Where("user_id = ? AND reason = ?", userID, reason).Count(&count)
+	return count > 0
+}
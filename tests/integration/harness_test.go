@@ -0,0 +1,272 @@
+//go:build integration
+
+// Package integration runs the order, payment and gamification services
+// in one process against real Postgres and Redis containers. Run it with
+//
+//	go test -tags integration ./tests/integration/...
+//
+// It needs a running Docker daemon and is skipped without one.
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	gamificationHandlers "playful-marketplace/services/gamification/handlers"
+	gamificationRoutes "playful-marketplace/services/gamification/routes"
+	orderHandlers "playful-marketplace/services/order/handlers"
+	orderRoutes "playful-marketplace/services/order/routes"
+	paymentHandlers "playful-marketplace/services/payment/handlers"
+	paymentRoutes "playful-marketplace/services/payment/routes"
+	"playful-marketplace/shared/config"
+	"playful-marketplace/shared/database"
+	"playful-marketplace/shared/jobs"
+	"playful-marketplace/shared/models"
+	"playful-marketplace/shared/redis"
+	"playful-marketplace/shared/regions"
+	"playful-marketplace/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	postgresImage = "postgres:15-alpine"
+	redisImage    = "redis:7-alpine"
+	startTimeout  = time.Minute
+)
+
+var (
+	cfg             *config.Config
+	orderApp        *fiber.App
+	paymentApp      *fiber.App
+	gamificationApp *fiber.App
+)
+
+func TestMain(m *testing.M) {
+	if err := exec.Command("docker", "info").Run(); err != nil {
+		log.Printf("Skipping integration tests, Docker isn't available: %v", err)
+		os.Exit(0)
+	}
+
+	var containers []string
+	code := func() int {
+		postgres, postgresPort, err := startContainer(postgresImage, "5432",
+			"POSTGRES_USER=postgres", "POSTGRES_PASSWORD=password", "POSTGRES_DB=playful_marketplace")
+		if postgres != "" {
+			containers = append(containers, postgres)
+		}
+		if err != nil {
+			log.Printf("Failed to start Postgres: %v", err)
+			return 1
+		}
+		redisID, redisPort, err := startContainer(redisImage, "6379")
+		if redisID != "" {
+			containers = append(containers, redisID)
+		}
+		if err != nil {
+			log.Printf("Failed to start Redis: %v", err)
+			return 1
+		}
+		if err := waitReady(postgres, "pg_isready", "-h", "127.0.0.1", "-U", "postgres"); err != nil {
+			log.Printf("Postgres didn't start: %v", err)
+			return 1
+		}
+		if err := waitReady(redisID, "redis-cli", "ping"); err != nil {
+			log.Printf("Redis didn't start: %v", err)
+			return 1
+		}
+
+		os.Setenv("DB_HOST", "127.0.0.1")
+		os.Setenv("DB_PORT", postgresPort)
+		os.Setenv("REDIS_HOST", "127.0.0.1")
+		os.Setenv("REDIS_PORT", redisPort)
+		os.Setenv("REDIS_DEGRADE", "false")
+		os.Setenv("JOB_POLL_INTERVAL", "100ms")
+		if err := setup(); err != nil {
+			log.Printf("Failed to start services: %v", err)
+			return 1
+		}
+		return m.Run()
+	}()
+
+	for _, id := range containers {
+		exec.Command("docker", "rm", "-f", id).Run()
+	}
+	os.Exit(code)
+}
+
+// setup connects to the containers and builds each service's app the way
+// its main does, with every service's jobs handled in this process
+func setup() error {
+	cfg = config.LoadConfig()
+	if err := database.Connect(cfg); err != nil {
+		return err
+	}
+	if err := database.Migrate(); err != nil {
+		return err
+	}
+	if err := redis.Connect(cfg); err != nil {
+		return err
+	}
+	regions.Init(cfg)
+
+	orderHandler := orderHandlers.NewOrderHandler(cfg)
+	orderHandler.RegisterJobs()
+	orderApp = newApp(func(api fiber.Router) { orderRoutes.SetupOrderRoutes(api, orderHandler, cfg) })
+
+	paymentHandler := paymentHandlers.NewPaymentHandler(cfg)
+	paymentHandler.RegisterJobs()
+	paymentApp = newApp(func(api fiber.Router) { paymentRoutes.SetupPaymentRoutes(api, paymentHandler, cfg) })
+
+	gamificationHandler := gamificationHandlers.NewGamificationHandler(cfg)
+	gamificationHandler.RegisterJobs()
+	gamificationApp = newApp(func(api fiber.Router) {
+		gamificationRoutes.SetupGamificationRoutes(api, gamificationHandler, cfg)
+	})
+
+	jobs.Start(cfg.Jobs)
+	return nil
+}
+
+func newApp(setupRoutes func(api fiber.Router)) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: utils.FiberErrorHandler})
+	setupRoutes(app.Group("/api/v1"))
+	return app
+}
+
+// startContainer runs image with port published on a free local port,
+// returning the container ID and that port
+func startContainer(image, port string, env ...string) (string, string, error) {
+	args := []string{"run", "-d", "--rm", "-p", "127.0.0.1::" + port}
+	for _, variable := range env {
+		args = append(args, "-e", variable)
+	}
+	out, err := exec.Command("docker", append(args, image)...).Output()
+	if err != nil {
+		return "", "", fmt.Errorf("docker run %s: %w", image, err)
+	}
+	id := strings.TrimSpace(string(out))
+
+	out, err = exec.Command("docker", "port", id, port+"/tcp").Output()
+	if err != nil {
+		return id, "", fmt.Errorf("docker port %s: %w", image, err)
+	}
+	mapping := strings.SplitN(strings.TrimSpace(string(out)), "\n", 2)[0]
+	_, hostPort, err := net.SplitHostPort(mapping)
+	if err != nil {
+		return id, "", fmt.Errorf("unexpected port mapping %q: %w", mapping, err)
+	}
+	return id, hostPort, nil
+}
+
+// waitReady runs the readiness command in the container until it succeeds
+func waitReady(id string, command ...string) error {
+	deadline := time.Now().Add(startTimeout)
+	for {
+		err := exec.Command("docker", append([]string{"exec", id}, command...)...).Run()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// createUser stores an established account with the role, and returns it
+// with a signed-in session token
+func createUser(t *testing.T, role models.UserRole, phone string) (models.User, string) {
+	t.Helper()
+
+	user := models.User{
+		BaseModel: models.BaseModel{ID: uuid.New(), CreatedAt: time.Now().AddDate(0, -1, 0)},
+		Phone:     phone,
+		Name:      fmt.Sprintf("Test %s", role),
+		Email:     fmt.Sprintf("%s@example.com", uuid.NewString()),
+		Role:      role,
+		Roles:     models.UserRoles{role},
+	}
+	if err := database.DB.Create(&user).Error; err != nil {
+		t.Fatalf("Failed to create %s: %v", role, err)
+	}
+
+	token, err := utils.GenerateJWT(&user, cfg)
+	if err != nil {
+		t.Fatalf("Failed to sign in %s: %v", role, err)
+	}
+	session := &models.Session{
+		UserID:    user.ID,
+		Token:     token,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(utils.TokenTTL(role, cfg)),
+	}
+	if err := redis.SetSession(session); err != nil {
+		t.Fatalf("Failed to store session for %s: %v", role, err)
+	}
+	return user, token
+}
+
+// call sends a JSON request to the app as the token's user, decodes the
+// response data into out when given, and returns the status
+func call(t *testing.T, app *fiber.App, token, method, path string, body, out interface{}) int {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	if resp.StatusCode >= 400 {
+		t.Logf("%s %s: %d %s", method, path, resp.StatusCode, data)
+	}
+	if out != nil && resp.StatusCode < 400 {
+		envelope := utils.Response{Data: out}
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			t.Fatalf("%s %s: invalid response %s: %v", method, path, data, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// eventually polls until done reports true, for work left to the job
+// workers
+func eventually(t *testing.T, what string, done func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(30 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}